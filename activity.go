@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+Activity はコミット・プルリクエスト・Issue・リリース・スターを共通の形式で表す構造体
+種類ごとに異なるGitHub APIのレスポンスをこの形式に正規化することで、
+フロントエンドは1つのフィードとして時系列に並べて表示できる
+*/
+type Activity struct {
+	Kind      string                 `json:"kind"`      // アクティビティの種類（activityKind* 定数のいずれか）
+	Repo      string                 `json:"repo"`      // リポジトリ名（例: "my-project"）
+	Actor     string                 `json:"actor"`     // 操作したユーザー名（コミットの場合は作成者名）
+	Timestamp time.Time              `json:"timestamp"` // アクティビティの発生日時
+	Payload   map[string]interface{} `json:"payload"`   // 種類ごとの詳細情報（タイトル、URLなど）
+}
+
+const (
+	activityKindCommit      = "commit"       // コミット
+	activityKindPullRequest = "pull_request" // プルリクエスト
+	activityKindIssue       = "issue"        // Issue
+	activityKindRelease     = "release"      // リリース
+	activityKindStar        = "star"         // スター
+)
+
+/* activityKinds はサポートするアクティビティの種類の一覧（kindフィルターの検証に使用） */
+var activityKinds = []string{
+	activityKindCommit,
+	activityKindPullRequest,
+	activityKindIssue,
+	activityKindRelease,
+	activityKindStar,
+}
+
+/* githubUser はGitHub APIレスポンスに含まれるユーザー情報（loginのみ使用） */
+type githubUser struct {
+	Login string `json:"login"` // GitHubのユーザー名
+}
+
+/*
+PullRequest はGitHub APIから取得するプルリクエスト情報を表す構造体
+API仕様: https://docs.github.com/ja/rest/pulls/pulls#list-pull-requests
+*/
+type PullRequest struct {
+	Number    int        `json:"number"`     // PR番号
+	Title     string     `json:"title"`      // PRのタイトル
+	State     string     `json:"state"`      // "open" または "closed"
+	HTMLURL   string     `json:"html_url"`   // GitHubのPRページURL
+	User      githubUser `json:"user"`       // PRの作成者
+	CreatedAt time.Time  `json:"created_at"` // 作成日時
+	MergedAt  *time.Time `json:"merged_at"`  // マージ日時（未マージの場合はnull）
+}
+
+/*
+Issue はGitHub APIから取得するIssue情報を表す構造体
+API仕様: https://docs.github.com/ja/rest/issues/issues#list-repository-issues
+*/
+type Issue struct {
+	Number    int        `json:"number"`     // Issue番号
+	Title     string     `json:"title"`      // Issueのタイトル
+	State     string     `json:"state"`      // "open" または "closed"
+	HTMLURL   string     `json:"html_url"`   // GitHubのIssueページURL
+	User      githubUser `json:"user"`       // Issueの作成者
+	CreatedAt time.Time  `json:"created_at"` // 作成日時
+	ClosedAt  *time.Time `json:"closed_at"`  // クローズ日時（オープン中の場合はnull）
+	/*
+		PullRequestはIssue APIがPRも返すため、その判別に使用する
+		PRの場合のみ値が入る（Issueの場合はnull）
+	*/
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+/*
+Release はGitHub APIから取得するリリース情報を表す構造体
+API仕様: https://docs.github.com/ja/rest/releases/releases#list-releases
+*/
+type Release struct {
+	TagName     string     `json:"tag_name"`     // タグ名（例: "v1.0.0"）
+	Name        string     `json:"name"`         // リリース名
+	HTMLURL     string     `json:"html_url"`     // GitHubのリリースページURL
+	Author      githubUser `json:"author"`       // リリースの作成者
+	Draft       bool       `json:"draft"`        // ドラフトかどうか
+	Prerelease  bool       `json:"prerelease"`   // プレリリースかどうか
+	PublishedAt *time.Time `json:"published_at"` // 公開日時（ドラフトの場合はnull）
+}
+
+/*
+Stargazer はスター日時付きのスターゲイザー情報を表す構造体
+Acceptヘッダーに githubAcceptStar を指定した場合のレスポンス形式
+API仕様: https://docs.github.com/ja/rest/activity/starring#list-stargazers
+*/
+type Stargazer struct {
+	StarredAt time.Time  `json:"starred_at"` // スターされた日時
+	User      githubUser `json:"user"`       // スターしたユーザー
+}
+
+/* activityParams は /api/activity のクエリパラメータ */
+type activityParams struct {
+	Kind    string `form:"kind" binding:"activitykinds"`
+	Repo    string `form:"repo"`
+	Page    *int   `form:"page" binding:"omitempty,min=1"`
+	PerPage *int   `form:"per_page" binding:"omitempty,min=1"`
+}
+
+/* validateFields はper_pageの上限とページの深さを検証する */
+func (p *activityParams) validateFields() []FieldError {
+	page, perPage := 1, defaultPerPage
+	if p.Page != nil {
+		page = *p.Page
+	}
+	if p.PerPage != nil {
+		perPage = *p.PerPage
+	}
+	return paginationFieldErrors(page, perPage, "narrow the feed with kind or repo instead")
+}
+
+/* ActivityPage はページネーションを指定した場合（または件数が上限を超えた場合）の /api/activity のレスポンス */
+type ActivityPage struct {
+	Activities []Activity `json:"activities"`        // このページのアクティビティ（新しい順）
+	Page       int        `json:"page"`              // ページ番号（1始まり）
+	PerPage    int        `json:"per_page"`          // 1ページあたりの件数
+	Total      int        `json:"total"`             // 全件数
+	Warning    string     `json:"warning,omitempty"` // レスポンスの大きさの上限を超えたためページネーションに切り替えた場合の警告
+}
+
+/*
+getActivity は全リポジトリのアクティビティを統合して返すAPIハンドラー
+処理の流れ:
+1. kindクエリパラメータから取得対象の種類を決定
+2. fetchRepositories()で全公開リポジトリを取得（repoパラメータ指定時は絞り込み）
+3. 各リポジトリについて対象種類のアクティビティを取得してActivityに正規化
+4. 新しい順にソートしてJSON形式で返却
+
+クエリパラメータ:
+  kind string - 取得する種類（カンマ区切りで複数指定可、例: "commit,release"）
+                省略時はすべての種類を取得
+  repo string - リポジトリ名で絞り込む（省略時は全リポジトリ）
+  page, per_page int - ページ番号と1ページあたりの件数（デフォルト: 30件、per_pageはMAX_PER_PAGEまで）
+
+レスポンス:
+  成功時: 200 OK, []Activity（新しい順）
+         page・per_pageを指定した場合、または配列がMAX_RESPONSE_ITEMS・MAX_RESPONSE_BYTESを超える場合は ActivityPage
+         ページのURLを Link ヘッダー（rel="first"・"prev"・"next"・"last"）、全件数を X-Total-Count に設定する
+  失敗時: 422 Unprocessable Entity（不正なkind・per_pageが上限を超える・MAX_PAGINATION_DEPTHを超えるページ）, 500 Internal Server Error
+*/
+func getActivity(c *gin.Context) {
+	var params activityParams
+	if !bindQuery(c, &params) {
+		return
+	}
+	/* bindingのactivitykindsルールで読み込めることを確認済み */
+	kinds, _ := parseActivityKinds(params.Kind)
+	repoFilter := params.Repo
+
+	log.Info().Strs("kinds", kinds).Str("repo", repoFilter).Msg("Fetching activity feed")
+
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	/* nilスライスはJSONでnullになるため、空配列で初期化する */
+	activities := []Activity{}
+	for _, repo := range repos {
+		if repoFilter != "" && repo.Name != repoFilter {
+			continue
+		}
+		for _, kind := range kinds {
+			items, err := fetchRepositoryActivity(repo, kind)
+			if err != nil {
+				/* getGitHistoryと同様、個別の失敗はログ出力のみで処理を継続する */
+				log.Warn().
+					Err(err).
+					Str("repository", repo.Name).
+					Str("kind", kind).
+					Msg("Failed to fetch activity for repository")
+				continue
+			}
+			activities = append(activities, items...)
+		}
+	}
+
+	/* コミットのメッセージにREDACTION_RULESを適用する（ペイロードはリクエストごとに作成したもの） */
+	redact := requestRedaction(c)
+	for _, activity := range activities {
+		if message, ok := activity.Payload["message"].(string); ok && activity.Kind == activityKindCommit {
+			activity.Payload["message"] = redact.message(message)
+		}
+	}
+
+	/* 種類が混在するため、発生日時で新しい順に並べ替える */
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].Timestamp.After(activities[j].Timestamp)
+	})
+
+	log.Info().Int("total_activities", len(activities)).Msg("Returning activity feed")
+	page := ActivityPage{Page: 1, PerPage: defaultPerPage, Total: len(activities)}
+	if params.Page == nil && params.PerPage == nil {
+		perPage, exceeded := responseLimit(activities, len(activities))
+		if !exceeded {
+			respondJSON(c, http.StatusOK, activities)
+			return
+		}
+		log.Warn().Int("total_activities", len(activities)).Int("per_page", perPage).Msg("Activity feed exceeds response size limit, paginating")
+		/* 次のページのLinkがper_pageの上限で拒否されないよう、MAX_PER_PAGEまでにする */
+		page.PerPage, page.Warning = min(perPage, maxPerPage), oversizedResponseWarning
+	}
+	if params.Page != nil {
+		page.Page = *params.Page
+	}
+	if params.PerPage != nil {
+		page.PerPage = *params.PerPage
+	}
+
+	start := (page.Page - 1) * page.PerPage
+	if start > len(activities) {
+		start = len(activities)
+	}
+	end := start + page.PerPage
+	if end > len(activities) {
+		end = len(activities)
+	}
+	page.Activities = activities[start:end]
+	setPageLinks(c, page.Page, page.PerPage, page.Total)
+	respondJSON(c, http.StatusOK, page)
+}
+
+/*
+parseActivityKinds はkindクエリパラメータを解析し、取得対象の種類の一覧を返す
+
+引数:
+  raw string - カンマ区切りの種類（空文字の場合はすべての種類）
+
+戻り値:
+  []string - 重複を除いた種類の一覧
+  error - サポートしていない種類が含まれる場合のエラー
+*/
+func parseActivityKinds(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return activityKinds, nil
+	}
+
+	var kinds []string
+	seen := map[string]bool{}
+	for _, k := range strings.Split(raw, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		if !isActivityKind(k) {
+			return nil, fmt.Errorf("unsupported activity kind: %s (supported: %s)", k, strings.Join(activityKinds, ", "))
+		}
+		seen[k] = true
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+/* isActivityKind は指定された文字列がサポートするアクティビティの種類かどうかを返す */
+func isActivityKind(kind string) bool {
+	for _, k := range activityKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+fetchRepositoryActivity は1つのリポジトリから指定された種類のアクティビティを取得し、
+Activity形式に正規化して返す
+
+引数:
+  repo Repository - 対象リポジトリ
+  kind string - アクティビティの種類（activityKind* 定数）
+
+戻り値:
+  []Activity - 正規化されたアクティビティ
+  error - GitHub APIの呼び出しに失敗した場合のエラー
+
+注意:
+  - 種類ごとにGitHub APIを1回呼び出すため、レート制限の消費に注意
+  - 各種類とも最大100件（per_page=100）まで
+*/
+func fetchRepositoryActivity(repo Repository, kind string) ([]Activity, error) {
+	switch kind {
+	case activityKindCommit:
+		commits, err := fetchCommits(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		activities := make([]Activity, 0, len(commits))
+		for _, commit := range commits {
+			activities = append(activities, Activity{
+				Kind:      activityKindCommit,
+				Repo:      repo.Name,
+				Actor:     commit.Commit.Author.Name,
+				Timestamp: commit.Commit.Author.Date,
+				Payload: map[string]interface{}{
+					"sha":     commit.SHA,
+					"message": commit.Commit.Message,
+					"url":     commit.HTMLURL,
+				},
+			})
+		}
+		return activities, nil
+
+	case activityKindPullRequest:
+		pulls, err := fetchPullRequests(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		activities := make([]Activity, 0, len(pulls))
+		for _, pr := range pulls {
+			activities = append(activities, Activity{
+				Kind:      activityKindPullRequest,
+				Repo:      repo.Name,
+				Actor:     pr.User.Login,
+				Timestamp: pr.CreatedAt,
+				Payload: map[string]interface{}{
+					"number":    pr.Number,
+					"title":     pr.Title,
+					"state":     pr.State,
+					"merged_at": pr.MergedAt,
+					"url":       pr.HTMLURL,
+				},
+			})
+		}
+		return activities, nil
+
+	case activityKindIssue:
+		issues, err := fetchIssues(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		activities := make([]Activity, 0, len(issues))
+		for _, issue := range issues {
+			activities = append(activities, Activity{
+				Kind:      activityKindIssue,
+				Repo:      repo.Name,
+				Actor:     issue.User.Login,
+				Timestamp: issue.CreatedAt,
+				Payload: map[string]interface{}{
+					"number":    issue.Number,
+					"title":     issue.Title,
+					"state":     issue.State,
+					"closed_at": issue.ClosedAt,
+					"url":       issue.HTMLURL,
+				},
+			})
+		}
+		return activities, nil
+
+	case activityKindRelease:
+		releases, err := fetchReleases(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		activities := make([]Activity, 0, len(releases))
+		for _, release := range releases {
+			/* ドラフトは公開されていないためフィードに含めない */
+			if release.Draft || release.PublishedAt == nil {
+				continue
+			}
+			activities = append(activities, Activity{
+				Kind:      activityKindRelease,
+				Repo:      repo.Name,
+				Actor:     release.Author.Login,
+				Timestamp: *release.PublishedAt,
+				Payload: map[string]interface{}{
+					"tag_name":   release.TagName,
+					"name":       release.Name,
+					"prerelease": release.Prerelease,
+					"url":        release.HTMLURL,
+				},
+			})
+		}
+		return activities, nil
+
+	case activityKindStar:
+		stargazers, err := fetchStargazers(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		activities := make([]Activity, 0, len(stargazers))
+		for _, star := range stargazers {
+			activities = append(activities, Activity{
+				Kind:      activityKindStar,
+				Repo:      repo.Name,
+				Actor:     star.User.Login,
+				Timestamp: star.StarredAt,
+				Payload: map[string]interface{}{
+					"url": repo.HTMLURL,
+				},
+			})
+		}
+		return activities, nil
+	}
+
+	return nil, fmt.Errorf("unsupported activity kind: %s", kind)
+}
+
+/*
+fetchPullRequests は指定されたリポジトリのプルリクエスト（オープン・クローズ両方、最大100件）を取得する
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+*/
+func fetchPullRequests(repoFullName string) ([]PullRequest, error) {
+	var pulls []PullRequest
+	url := fmt.Sprintf("%s/repos/%s/pulls?state=all&per_page=100", githubAPIBase, repoFullName)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, "", &pulls); err != nil {
+		return nil, err
+	}
+	return pulls, nil
+}
+
+/*
+fetchReleases は指定されたリポジトリのリリース（ドラフトを含む、最大100件）を取得する
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+*/
+func fetchReleases(repoFullName string) ([]Release, error) {
+	var releases []Release
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", githubAPIBase, repoFullName)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, "", &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+/*
+fetchStargazers は指定されたリポジトリのスターゲイザーをスター日時付きで取得する（最大100件）
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+*/
+func fetchStargazers(repoFullName string) ([]Stargazer, error) {
+	var stargazers []Stargazer
+	url := fmt.Sprintf("%s/repos/%s/stargazers?per_page=100", githubAPIBase, repoFullName)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, githubAcceptStar, &stargazers); err != nil {
+		return nil, err
+	}
+	return stargazers, nil
+}
+
+/*
+fetchIssues は指定されたリポジトリのIssue（オープン・クローズ両方、最大100件）を取得する
+Issue APIはPRも返すため、PRはpull_request種類に任せて除外する
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+*/
+func fetchIssues(repoFullName string) ([]Issue, error) {
+	var all []Issue
+	url := fmt.Sprintf("%s/repos/%s/issues?state=all&per_page=100", githubAPIBase, repoFullName)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, "", &all); err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, 0, len(all))
+	for _, issue := range all {
+		if issue.PullRequest == nil {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+getActivityMetrics はコミットのデータをPrometheusのテキスト形式で返すエクスポーター（/metrics/activity）
+サーバー自身のメトリクス（/metrics）とは別に、リポジトリごとのコミット数や最終コミット日時を公開し、
+Grafanaなどで「リポジトリXに7日間コミットがない」といったアラートを設定できるようにする
+
+スクレイプのたびにリポジトリ一覧とコミットを取得する（githubGetのキャッシュ・ETagを経由するため、
+GITHUB_CACHE_TTL以内のスクレイプはGitHubにリクエストしない）
+
+メトリクス:
+  giter_activity_up - リポジトリ一覧を取得できた場合は1
+  giter_commits_total{repo} - 取得したコミット数（1リポジトリあたり最新100件まで）
+  giter_commits_last_7_days{repo} - 直近7日間のコミット数
+  giter_last_commit_timestamp{repo} - 最も新しいコミットの日時（Unix時間、秒）
+  giter_repository_fetch_success{repo} - コミットを取得できた場合は1
+
+アラートの例（PromQL）:
+  time() - giter_last_commit_timestamp{repo="my-project"} > 7 * 86400
+
+レスポンス:
+  200 OK, text/plain; version=0.0.4
+*/
+func getActivityMetrics(c *gin.Context) {
+	up := newGaugeVec("giter_activity_up", "Whether the repository list could be fetched from GitHub (1) or not (0).")
+	commitsTotal := newGaugeVec("giter_commits_total", "Number of commits fetched for the repository (latest 100 at most).", "repo")
+	commitsWeek := newGaugeVec("giter_commits_last_7_days", "Number of commits in the last 7 days.", "repo")
+	lastCommit := newGaugeVec("giter_last_commit_timestamp", "Unix timestamp of the latest commit in the repository.", "repo")
+	fetchSuccess := newGaugeVec("giter_repository_fetch_success", "Whether the commits for the repository could be fetched (1) or not (0).", "repo")
+	gauges := []*GaugeVec{up, commitsTotal, commitsWeek, lastCommit, fetchSuccess}
+
+	/*
+		スクレイプを待たせないよう、通常のAPIリクエストと同じレーンで実行する
+		（backgroundのレーンはレート制限の残り回数が少ない間やメンテナンス中に止まり、スクレイプがタイムアウトする）
+	*/
+	repos, err := fetchRepositoriesAt(jobPriorityNormal)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch repositories for activity metrics")
+		up.Set(0)
+	} else {
+		up.Set(1)
+	}
+	/* /metrics/activity は誰でも収集できるため、PRIVACY_MODEに従いプライベートリポジトリを除く */
+	repos = backgroundVisibility().repositories(repos)
+
+	since := time.Now().AddDate(0, 0, -7)
+	results := fetchCommitsConcurrently(repos, jobPriorityNormal)
+	for i, repo := range repos {
+		if results[i].Err != nil {
+			fetchSuccess.Set(0, repo.Name)
+			continue
+		}
+		fetchSuccess.Set(1, repo.Name)
+
+		commits := results[i].Commits
+		var latest time.Time
+		week := 0
+		for _, commit := range commits {
+			date := commit.Commit.Author.Date
+			if date.After(latest) {
+				latest = date
+			}
+			if date.After(since) {
+				week++
+			}
+		}
+		commitsTotal.Set(float64(len(commits)), repo.Name)
+		commitsWeek.Set(float64(week), repo.Name)
+		/* コミットが1件もないリポジトリは最終コミット日時を出力しない（0を出力するとアラートが誤検知するため） */
+		if !latest.IsZero() {
+			lastCommit.Set(float64(latest.Unix()), repo.Name)
+		}
+	}
+
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	for _, g := range gauges {
+		g.writeTo(c.Writer)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+/*
+adminToken は管理者APIの認証に使用するトークン
+環境変数 ADMIN_TOKEN で設定する。"Authorization: Bearer <トークン>" で送ると、管理者のロールで認証される
+管理者の認証方式（ADMIN_TOKEN・adminのAPI_KEYS・OAuthの管理者）がない場合、管理者APIはすべて無効になる
+認証の流れとルートごとの方針はauth.goを参照
+*/
+var adminToken = getEnv("ADMIN_TOKEN", "")
+
+/*
+isAdminRequest はリクエストの閲覧者が管理者のロールで認証されているかを返す
+APIキー・OAuthのセッションのいずれで認証されていてもよい
+*/
+func isAdminRequest(c *gin.Context) bool {
+	return requestPrincipal(c).Role >= roleAdmin
+}
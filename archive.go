@@ -0,0 +1,194 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* archiveFilePrefix はアーカイブファイル名の接頭辞（例: commits-2023-04.json.gz） */
+	archiveFilePrefix = "commits-"
+	/* archiveFileSuffix はアーカイブファイルの拡張子（gzip圧縮したJSON） */
+	archiveFileSuffix = ".json.gz"
+	/* archiveMonthFormat はアーカイブファイル名に含める年月の形式 */
+	archiveMonthFormat = "2006-01"
+)
+
+/*
+archiveHotMonths はレスポンスにそのまま含める（ホットな）期間の月数
+環境変数 ARCHIVE_HOT_MONTHS で設定する（デフォルト: 0 = アーカイブしない）
+これより古いコミットは月ごとの圧縮ファイルに移し、?include_archive=true の場合のみ読み込む
+*/
+var archiveHotMonths = getEnvInt("ARCHIVE_HOT_MONTHS", 0)
+
+/* archiveDir はアーカイブファイルの保存先ディレクトリ（DATA_DIR/archive） */
+func archiveDir() string {
+	return filepath.Join(dataDir, "archive")
+}
+
+/* archiveKey はアーカイブ内でコミットを識別するキー（リポジトリ名とSHA） */
+func archiveKey(commit CommitHistory) string {
+	return commit.RepositoryName + "/" + commit.CommitSHA
+}
+
+/* archiveMonth はコミットを格納するアーカイブファイルの年月（UTC）を返す */
+func archiveMonth(commit CommitHistory) string {
+	return commit.CommitTime.UTC().Format(archiveMonthFormat)
+}
+
+/*
+archiveCutoff はホットな期間の開始日時を返す
+これより前のコミットがアーカイブの対象になる
+*/
+func archiveCutoff(now time.Time, months int) time.Time {
+	return now.UTC().AddDate(0, -months, 0)
+}
+
+/*
+archiveColdCommits はホットな期間より古いコミットをアーカイブ（history）に移し、残りのコミットを返す
+アーカイブは年月（UTC）ごとで、まだ保存されていないコミットがある場合のみ書き込む
+ARCHIVE_HOT_MONTHSが0以下の場合は何もせずそのまま返す
+
+引数:
+  commits []CommitHistory - GitHubから取得したコミット
+
+戻り値:
+  []CommitHistory - ホットな期間のコミット（元の順序を保つ）
+
+注意:
+  - アーカイブの書き込みに失敗した場合は、古いコミットを失わないようホットな側に残す
+*/
+func archiveColdCommits(commits []CommitHistory) []CommitHistory {
+	if archiveHotMonths <= 0 {
+		return commits
+	}
+	cutoff := archiveCutoff(time.Now(), archiveHotMonths)
+
+	var hot []CommitHistory
+	cold := map[string][]CommitHistory{}
+	for _, commit := range commits {
+		if commit.CommitTime.Before(cutoff) {
+			cold[archiveMonth(commit)] = append(cold[archiveMonth(commit)], commit)
+			continue
+		}
+		hot = append(hot, commit)
+	}
+
+	for month, monthCommits := range cold {
+		if err := appendArchive(month, monthCommits); err != nil {
+			log.Error().Err(err).Str("month", month).Msg("Failed to archive commits")
+			hot = append(hot, monthCommits...)
+		}
+	}
+	return hot
+}
+
+/* appendArchive は年月のアーカイブにまだ含まれていないコミットを追加する */
+func appendArchive(month string, commits []CommitHistory) error {
+	added, err := history.Append(month, commits)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		log.Info().Str("month", month).Int("added", added).Msg("Archived commits")
+	}
+	return nil
+}
+
+/* readArchiveFile はアーカイブファイルを読み込む（ファイルが存在しない場合は空） */
+func readArchiveFile(path string) ([]CommitHistory, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", path, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	var commits []CommitHistory
+	if err := json.NewDecoder(gz).Decode(&commits); err != nil {
+		return nil, fmt.Errorf("failed to decode archive %s: %w", path, err)
+	}
+	return commits, nil
+}
+
+/*
+writeArchiveFile はコミットをgzip圧縮したJSONとしてアーカイブファイルに書き込む
+writeTableFileと同様、一時ファイルに書き込んでからリネームする
+*/
+func writeArchiveFile(path string, commits []CommitHistory) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to write archive %s: %w", path, err)
+	}
+
+	gz := gzip.NewWriter(f)
+	err = json.NewEncoder(gz).Encode(commits)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write archive %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace archive %s: %w", path, err)
+	}
+	return nil
+}
+
+/*
+loadArchivedCommits はアーカイブ（history）のすべてのコミットを読み込む
+?include_archive=true のリクエストでのみ呼び出す
+
+戻り値:
+  []CommitHistory - アーカイブ済みのコミット（新しい月から順に、各月の中は新しい順、孤立したコミットはOrphanedを付ける）
+  error - アーカイブの読み込みに失敗した場合のエラー
+*/
+func loadArchivedCommits() ([]CommitHistory, error) {
+	archived, err := history.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	return branchHeads.markOrphans(archived), nil
+}
+
+/*
+mergeArchivedCommits はホットなコミットにアーカイブ済みのコミットを追加する
+両方に含まれるコミット（アーカイブ後にGitHubから再取得したもの）は1件にまとめる
+*/
+func mergeArchivedCommits(hot, archived []CommitHistory) []CommitHistory {
+	seen := make(map[string]bool, len(hot))
+	for _, commit := range hot {
+		seen[archiveKey(commit)] = true
+	}
+	merged := hot
+	for _, commit := range archived {
+		if !seen[archiveKey(commit)] {
+			seen[archiveKey(commit)] = true
+			merged = append(merged, commit)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* auditTable は管理者の操作の監査ログを保存するテーブル名 */
+	auditTable = "audit_log"
+)
+
+/*
+AuditEntry は監査ログの1件
+データの削除など、取り消せない管理者の操作を記録する
+*/
+type AuditEntry struct {
+	ID        string         `json:"id"`                   // エントリーID（ランダムな32文字の16進数）
+	Time      time.Time      `json:"time"`                 // 操作した日時
+	Action    string         `json:"action"`               // 操作の種類（例: "data.delete"）
+	Target    string         `json:"target"`               // 操作の対象（例: 削除したユーザー・閲覧者ID）
+	Actor     string         `json:"actor,omitempty"`      // 操作した閲覧者（"認証方式:名前"、例: "api_key:admin"）
+	ClientIP  string         `json:"client_ip"`            // 操作したクライアントのIPアドレス
+	RequestID string         `json:"request_id,omitempty"` // リクエストID（ログと突き合わせる）
+	Details   map[string]int `json:"details,omitempty"`    // 操作の結果（例: 種類ごとの削除件数）
+}
+
+/*
+auditStore は監査ログを保持するストア
+audit_logテーブルに永続化される
+*/
+type auditStore struct {
+	mu sync.Mutex
+	/* Entries は古い順の監査ログ */
+	Entries []AuditEntry `json:"entries"`
+}
+
+/* auditLog はアプリケーション全体で共有する監査ログ */
+var auditLog = &auditStore{Entries: []AuditEntry{}}
+
+func init() {
+	registerTable(auditTable, loadAuditLog)
+}
+
+/*
+loadAuditLog はaudit_logテーブルから監査ログを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadAuditLog() error {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+
+	auditLog.Entries = nil
+	if err := loadTable(auditTable, auditLog); err != nil {
+		return err
+	}
+	if auditLog.Entries == nil {
+		auditLog.Entries = []AuditEntry{}
+	}
+	return nil
+}
+
+/* save は監査ログをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *auditStore) save() {
+	if err := saveTable(auditTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save audit log")
+	}
+}
+
+/*
+record はリクエストの操作を監査ログに追加する
+
+引数:
+  c *gin.Context - 操作したリクエスト（クライアントのIPアドレスとリクエストIDの取得に使用）
+  action string - 操作の種類
+  target string - 操作の対象
+  details map[string]int - 操作の結果
+
+戻り値:
+  AuditEntry - 追加したエントリー
+*/
+func (s *auditStore) record(c *gin.Context, action, target string, details map[string]int) AuditEntry {
+	entry := AuditEntry{
+		ID:        newSessionID(),
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Target:    target,
+		Actor:     principalActor(requestPrincipal(c)),
+		ClientIP:  c.ClientIP(),
+		RequestID: requestID(c),
+		Details:   details,
+	}
+
+	s.mu.Lock()
+	s.Entries = append(s.Entries, entry)
+	s.save()
+	s.mu.Unlock()
+
+	log.Info().Str("audit_id", entry.ID).Str("action", action).Str("target", target).Msg("Audit entry recorded")
+	return entry
+}
+
+/* list は監査ログを新しい順に返す */
+func (s *auditStore) list() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]AuditEntry, 0, len(s.Entries))
+	for i := len(s.Entries) - 1; i >= 0; i-- {
+		entries = append(entries, s.Entries[i])
+	}
+	return entries
+}
+
+/*
+getAuditLog は監査ログを返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"entries": []AuditEntry（新しい順）}
+*/
+func getAuditLog(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{"entries": auditLog.list()})
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+認証のミドルウェアチェーン
+リクエストの認証情報をauthenticatorsの順に確認して閲覧者（Principal）を決め、
+ルートごとの方針（authPolicies）で必要なロールを満たすかを判定する
+新しい認証方式はauthenticatorを実装してauthenticatorsに追加する
+*/
+
+/* 認証方式 */
+const (
+	authSchemeAPIKey    = "api_key"   // Authorization: Bearer <APIキー>（ADMIN_TOKEN・API_KEYS）
+	authSchemeSession   = "session"   // GitHubのOAuthでログインしたセッション（authsession.go）
+	authSchemeShare     = "share"     // 署名した共有URLのトークン（/share/:token）
+	authSchemeAnonymous = "anonymous" // 認証情報のない閲覧者（セッションのクッキーのみ）
+)
+
+const (
+	/* principalContextKey はgin.Contextに閲覧者を保存する際のキー */
+	principalContextKey = "principal"
+	/* authErrorContextKey はgin.Contextに認証情報の検証のエラーを保存する際のキー */
+	authErrorContextKey = "auth_error"
+)
+
+/*
+authRole は閲覧者のロール（値が大きいほど権限が多い）
+*/
+type authRole int
+
+const (
+	roleAnonymous authRole = iota // 公開リポジトリのデータの閲覧と、自分のセッションの状態（通知の既読・設定など）の変更
+	roleReader                    // プライベートリポジトリを含むデータの閲覧
+	roleAdmin                     // 管理者APIを含むすべての操作
+)
+
+/* authRoleNames はロールの名前（API_KEYS・OAUTH_USERSとレスポンスで使用） */
+var authRoleNames = []string{"anonymous", "reader", "admin"}
+
+func (r authRole) String() string {
+	if r < 0 || int(r) >= len(authRoleNames) {
+		return "unknown"
+	}
+	return authRoleNames[r]
+}
+
+/* MarshalText はロールをJSONに名前で出力する */
+func (r authRole) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+/* parseAuthRole はロールの名前を読み込む */
+func parseAuthRole(name string) (authRole, bool) {
+	for i, n := range authRoleNames {
+		if n == name {
+			return authRole(i), true
+		}
+	}
+	return roleAnonymous, false
+}
+
+/*
+Principal はリクエストの閲覧者
+*/
+type Principal struct {
+	Scheme  string     `json:"scheme"`          // 認証方式（authScheme* 定数）
+	Subject string     `json:"subject"`         // APIキーの名前、GitHubのログイン名、または閲覧者ID（匿名の場合）
+	Role    authRole   `json:"role"`            // ロール
+	Share   *ShareView `json:"share,omitempty"` // 共有URLの範囲（共有URLのトークンの場合のみ）
+}
+
+var (
+	/* errInvalidCredentials は認証情報が送られたが、いずれの認証方式でも検証できない場合のエラー */
+	errInvalidCredentials = errors.New("invalid credentials")
+	/* errAuthenticationRequired はルートの方針で認証が必要なリクエストに認証情報がない場合のエラー */
+	errAuthenticationRequired = errors.New("authentication required")
+	/* errInsufficientRole は認証した閲覧者のロールがルートの方針を満たさない場合のエラー */
+	errInsufficientRole = errors.New("insufficient permissions")
+)
+
+/*
+authenticator は1つの認証方式
+authenticateは、その方式の認証情報がない場合は (nil, nil)、あるが検証できない場合はエラーを返す
+*/
+type authenticator interface {
+	scheme() string
+	authenticate(c *gin.Context) (*Principal, error)
+}
+
+/*
+authenticators は順に確認する認証方式
+最初に閲覧者を返した方式を使用し、どの方式でもない場合は匿名の閲覧者とする
+*/
+var authenticators = []authenticator{apiKeyAuthenticator{}, sessionAuthenticator{}, shareAuthenticator{}}
+
+/*
+apiKey はAuthorization: Bearer で送るAPIキー
+*/
+type apiKey struct {
+	Name string   // キーの名前（監査ログ・Principalに使用）
+	Key  string   // キー
+	Role authRole // ロール
+}
+
+/*
+apiKeys は認証に使用するAPIキー
+ADMIN_TOKEN（名前 "admin"、ロール admin）と、環境変数 API_KEYS のキーを使用する
+API_KEYS は "名前:キー:ロール" のカンマ区切り（ロールは reader または admin、省略時は reader）
+例: API_KEYS="dashboard:s3cr3t:reader,ci:t0ken:admin"
+*/
+var apiKeys = loadAPIKeys(adminToken, getEnv("API_KEYS", ""))
+
+/* loadAPIKeys はADMIN_TOKENとAPI_KEYSからAPIキーを読み込む（不正な項目は警告を出して無視する） */
+func loadAPIKeys(admin, raw string) []apiKey {
+	keys := []apiKey{}
+	if admin != "" {
+		keys = append(keys, apiKey{Name: "admin", Key: admin, Role: roleAdmin})
+	}
+	for _, item := range splitList(raw) {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			log.Warn().Str("entry", parts[0]).Msg("Ignoring invalid API_KEYS entry")
+			continue
+		}
+		role := roleReader
+		if len(parts) == 3 {
+			var ok bool
+			if role, ok = parseAuthRole(parts[2]); !ok || role == roleAnonymous {
+				log.Warn().Str("entry", parts[0]).Msg("Ignoring API_KEYS entry with an unknown role")
+				continue
+			}
+		}
+		keys = append(keys, apiKey{Name: parts[0], Key: parts[1], Role: role})
+	}
+	return keys
+}
+
+/* apiKeyAuthenticator は Authorization: Bearer <APIキー> で認証する */
+type apiKeyAuthenticator struct{}
+
+func (apiKeyAuthenticator) scheme() string { return authSchemeAPIKey }
+
+/*
+authenticate はBearerトークンをAPIキーと照合する
+タイミング攻撃を防ぐため、比較には subtle.ConstantTimeCompare を使用し、すべてのキーと比較する
+*/
+func (apiKeyAuthenticator) authenticate(c *gin.Context) (*Principal, error) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return nil, nil
+	}
+	var matched *apiKey
+	for i := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(apiKeys[i].Key)) == 1 && matched == nil {
+			matched = &apiKeys[i]
+		}
+	}
+	if matched == nil {
+		return nil, errInvalidCredentials
+	}
+	return &Principal{Scheme: authSchemeAPIKey, Subject: matched.Name, Role: matched.Role}, nil
+}
+
+/* shareAuthenticator は共有URL（/share/:token）のトークンで認証する */
+type shareAuthenticator struct{}
+
+func (shareAuthenticator) scheme() string { return authSchemeShare }
+
+/* authenticate は共有URLのルートのみ、パスのトークンの署名と有効期限を検証する */
+func (shareAuthenticator) authenticate(c *gin.Context) (*Principal, error) {
+	if strings.TrimPrefix(c.FullPath(), basePath) != "/share/:token" {
+		return nil, nil
+	}
+	view, err := verifyShareToken(c.Param("token"))
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Scheme: authSchemeShare, Subject: viewerID(c), Role: roleAnonymous, Share: &view}, nil
+}
+
+/*
+authRoutePolicy はルートの認証の方針
+*/
+type authRoutePolicy struct {
+	Pattern string   // "メソッド ルート"（メソッドの "*" はすべて、ルートの末尾の "/*" は配下すべて）
+	Role    authRole // 必要なロール
+	Schemes []string // 受け付ける認証方式（nilの場合はすべて）
+}
+
+/*
+authPolicies はルート（BASE_PATHを除くルートのパターン）ごとの認証の方針
+上から順に照合し、最初に一致した方針を適用する
+
+注意:
+  - どれにも一致しない場合、GET・HEAD・OPTIONSは匿名の閲覧者に許可し、それ以外のメソッドはreader以上のロールが必要
+  - 匿名の閲覧者が自分のセッションの状態を変更するルートは、ここで明示的に許可する
+*/
+var authPolicies = []authRoutePolicy{
+	/* 管理者API（共有URLのトークンでは操作できない） */
+	{Pattern: "* /api/admin/*", Role: roleAdmin, Schemes: []string{authSchemeAPIKey, authSchemeSession}},
+	{Pattern: "POST /api/share", Role: roleAdmin, Schemes: []string{authSchemeAPIKey, authSchemeSession}},
+	{Pattern: "POST /api/reports", Role: roleAdmin, Schemes: []string{authSchemeAPIKey, authSchemeSession}},
+	/* ログイン・ログアウト */
+	{Pattern: "* /auth/*", Role: roleAnonymous},
+	/* 閲覧者（セッション）ごとの状態の変更 */
+	{Pattern: "POST /api/git-history/new/ack", Role: roleAnonymous},
+	{Pattern: "POST /api/notifications/read-all", Role: roleAnonymous},
+	{Pattern: "POST /api/notifications/:id/read", Role: roleAnonymous},
+	{Pattern: "PUT /api/notifications/preferences", Role: roleAnonymous},
+	{Pattern: "PUT /api/preferences", Role: roleAnonymous},
+	{Pattern: "* /api/saved-searches/*", Role: roleAnonymous},
+	{Pattern: "POST /api/saved-searches", Role: roleAnonymous},
+	/* 読み取りのみのPOST（サブリクエストはそれぞれの方針で認証する） */
+	{Pattern: "POST /api/batch", Role: roleAnonymous},
+	{Pattern: "POST /grafana/*", Role: roleAnonymous},
+}
+
+/* matchAuthPolicy はリクエストのメソッドとルートに一致する方針を返す */
+func matchAuthPolicy(method, route string) authRoutePolicy {
+	for _, policy := range authPolicies {
+		m, pattern, _ := strings.Cut(policy.Pattern, " ")
+		if m != "*" && m != method {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && (route == prefix || strings.HasPrefix(route, prefix+"/")) || pattern == route {
+			return policy
+		}
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return authRoutePolicy{Role: roleAnonymous}
+	}
+	return authRoutePolicy{Role: roleReader}
+}
+
+/*
+authenticateRequest はauthenticatorsの順に認証情報を確認し、閲覧者を返す
+
+戻り値:
+  Principal - 閲覧者（どの方式でも認証できない場合は匿名の閲覧者）
+  error - 送られた認証情報を検証できなかった場合の最初のエラー（匿名として扱う場合も返す）
+*/
+func authenticateRequest(c *gin.Context) (Principal, error) {
+	var firstErr error
+	for _, auth := range authenticators {
+		principal, err := auth.authenticate(c)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if principal != nil {
+			return *principal, nil
+		}
+	}
+	return Principal{Scheme: authSchemeAnonymous, Subject: viewerID(c), Role: roleAnonymous}, firstErr
+}
+
+/*
+requestPrincipal はリクエストの閲覧者を返す
+authMiddlewareを通過していない場合（ミドルウェアより前の処理など）は、その場で認証する
+*/
+func requestPrincipal(c *gin.Context) Principal {
+	if principal, ok := c.Value(principalContextKey).(Principal); ok {
+		return principal
+	}
+	principal, err := authenticateRequest(c)
+	c.Set(principalContextKey, principal)
+	c.Set(authErrorContextKey, err)
+	return principal
+}
+
+/* requestAuthError はリクエストの認証情報を検証できなかった場合のエラーを返す（ない場合はnil） */
+func requestAuthError(c *gin.Context) error {
+	requestPrincipal(c)
+	err, _ := c.Value(authErrorContextKey).(error)
+	return err
+}
+
+/* adminConfigured は管理者のロールで認証できる方式（ADMIN_TOKEN・adminのAPIキー・OAuthの管理者）があるかを返す */
+func adminConfigured() bool {
+	for _, key := range apiKeys {
+		if key.Role == roleAdmin {
+			return true
+		}
+	}
+	return oauthEnabled() && oauthHasAdmin()
+}
+
+/*
+authMiddleware は認証のチェーンで閲覧者を決め、ルートの方針を満たさないリクエストを拒否するミドルウェア
+
+レスポンス:
+  管理者のロールが必要なルートで、管理者として認証する方式がない場合: 403 Forbidden（管理者APIが無効）
+  認証情報がない・検証できない場合: 401 Unauthorized
+  ロールが足りない・受け付けない認証方式の場合: 403 Forbidden
+  認証の失敗が続いてロック中の場合: 429 Too Many Requests（authlockout.go）
+
+注意:
+  - sessionMiddlewareの後に登録する（セッションで認証するため）
+  - 検証できない認証情報は、匿名の閲覧者に許可したルートでは無視する（従来どおり匿名として扱う）
+*/
+func authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lockoutKeys := authLockoutKeys(c)
+		if lockoutKeys != nil && rejectLockedOut(c, lockoutKeys) {
+			return
+		}
+		principal := requestPrincipal(c)
+		if principal.Scheme == authSchemeAPIKey {
+			authLockouts.succeed(lockoutKeys[0])
+		} else if errors.Is(requestAuthError(c), errInvalidCredentials) {
+			authLockouts.fail(c, authSchemeAPIKey, lockoutKeys...)
+		}
+		route := strings.TrimPrefix(c.FullPath(), basePath)
+		if route == "" {
+			/* どのルートにも一致しないリクエストは404を返すハンドラーに任せる */
+			c.Next()
+			return
+		}
+		policy := matchAuthPolicy(c.Request.Method, route)
+		if principal.Role >= policy.Role && (policy.Schemes == nil || containsString(policy.Schemes, principal.Scheme)) {
+			c.Next()
+			return
+		}
+
+		switch {
+		case policy.Role == roleAdmin && !adminConfigured():
+			abortWithError(c, http.StatusForbidden, "admin API is disabled (set ADMIN_TOKEN to enable)")
+			return
+		case principal.Scheme == authSchemeAnonymous:
+			message := errAuthenticationRequired.Error()
+			if err := requestAuthError(c); err != nil {
+				message = errInvalidCredentials.Error()
+				if policy.Role == roleAdmin {
+					message = "invalid admin token"
+				}
+			}
+			log.Warn().Str("path", c.Request.URL.Path).Str("client_ip", c.ClientIP()).Str("reason", message).Msg("Rejected unauthenticated request")
+			c.Header("WWW-Authenticate", `Bearer realm="giter"`)
+			abortWithError(c, http.StatusUnauthorized, message)
+			return
+		}
+		log.Warn().
+			Str("path", c.Request.URL.Path).
+			Str("scheme", principal.Scheme).
+			Str("subject", principal.Subject).
+			Str("role", principal.Role.String()).
+			Msg("Rejected request with insufficient permissions")
+		abortWithError(c, http.StatusForbidden, errInsufficientRole.Error())
+	}
+}
+
+/*
+getAuthSession は閲覧者の認証の状態を返すAPIハンドラー（GET /auth/session）
+フロントエンドがログイン中かどうか・ロールを表示するために使用する
+
+レスポンス:
+  200 OK, {"principal": Principal, "oauth_enabled": bool}
+*/
+func getAuthSession(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{"principal": requestPrincipal(c), "oauth_enabled": oauthEnabled()})
+}
+
+/* principalActor は監査ログに記録する閲覧者（匿名の場合は空文字） */
+func principalActor(p Principal) string {
+	if p.Scheme == authSchemeAnonymous {
+		return ""
+	}
+	return p.Scheme + ":" + p.Subject
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+認証の総当たり対策
+APIキーの照合とGitHubでのログイン（/auth/*）の失敗をIPアドレスごと・認証情報ごとに数え、
+AUTH_LOCKOUT_THRESHOLD回失敗すると一定時間ロックする。ロックするたびに期間を2倍にする（上限 AUTH_LOCKOUT_MAX）
+ロック中は認証を試みるリクエストを照合せずに429で拒否し、ロックしたことを監査ログに記録する
+*/
+
+const (
+	/* authLockoutMaxEntries は保持する失敗の記録の最大件数（超えた場合は古い記録から削除する） */
+	authLockoutMaxEntries = 10000
+)
+
+var (
+	/* authLockoutThreshold はロックするまでの失敗の回数（環境変数 AUTH_LOCKOUT_THRESHOLD、0の場合は無効） */
+	authLockoutThreshold = getEnvInt("AUTH_LOCKOUT_THRESHOLD", 5)
+	/* authLockoutWindow は失敗を数える期間（環境変数 AUTH_LOCKOUT_WINDOW）。最後の失敗からこれだけ経つと回数を0に戻す */
+	authLockoutWindow = parseDurationEnv("AUTH_LOCKOUT_WINDOW", 15*time.Minute)
+	/* authLockoutBase は最初のロックの期間（環境変数 AUTH_LOCKOUT_BASE） */
+	authLockoutBase = parseDurationEnv("AUTH_LOCKOUT_BASE", time.Minute)
+	/* authLockoutMax はロックの期間の上限（環境変数 AUTH_LOCKOUT_MAX）。最後の失敗からこれだけ経つとロックの回数も0に戻す */
+	authLockoutMax = parseDurationEnv("AUTH_LOCKOUT_MAX", 24*time.Hour)
+)
+
+var (
+	authFailuresTotal = newCounterVec(
+		"giter_auth_failures_total",
+		"Failed authentication attempts, by scheme (api_key or session).",
+		"scheme",
+	)
+	authLockoutsTotal = newCounterVec(
+		"giter_auth_lockouts_total",
+		"Lockouts started after repeated authentication failures, by kind (ip, key or login).",
+		"kind",
+	)
+)
+
+/*
+AuthLockout は1つのIPアドレス・認証情報の失敗の記録
+*/
+type AuthLockout struct {
+	Key         string     `json:"key"`          // 対象（"ip:<IPアドレス>"・"key:<APIキーのSHA-256の先頭16文字>"・"login:<ログイン名>"）
+	Failures    int        `json:"failures"`     // 期間内に続けて失敗した回数
+	Lockouts    int        `json:"lockouts"`     // ロックした回数（次のロックの期間はAUTH_LOCKOUT_BASE × 2^回数）
+	LastFailure time.Time  `json:"last_failure"` // 最後に失敗した日時
+	LockedUntil *time.Time `json:"locked_until"` // ロックが解除される日時（ロックしていない場合はnull）
+}
+
+/*
+authLockoutStore は認証の失敗の記録を保持するストア
+インスタンスごとにメモリ上で保持する（再起動すると解除される）
+*/
+type authLockoutStore struct {
+	mu      sync.Mutex
+	entries map[string]*AuthLockout
+}
+
+/* authLockouts はアプリケーション全体で共有する認証の失敗の記録 */
+var authLockouts = &authLockoutStore{entries: map[string]*AuthLockout{}}
+
+/* apiKeyFingerprint は送られたAPIキーを記録に使う値に変換する（キーそのものは保持しない） */
+func apiKeyFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "key:" + hex.EncodeToString(sum[:])[:16]
+}
+
+/* entry はkeyの記録を返す（最後の失敗から期間が経っている場合は回数を0に戻す）。s.muを取得した状態で呼び出す */
+func (s *authLockoutStore) entry(key string, now time.Time) *AuthLockout {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if now.Sub(e.LastFailure) >= authLockoutMax && (e.LockedUntil == nil || !now.Before(*e.LockedUntil)) {
+		delete(s.entries, key)
+		return nil
+	}
+	if now.Sub(e.LastFailure) >= authLockoutWindow {
+		e.Failures = 0
+	}
+	return e
+}
+
+/*
+lockedUntil はkeysのいずれかがロック中であれば、最も遅い解除の日時を返す
+
+戻り値:
+  time.Time - ロックが解除される日時
+  bool - いずれかがロック中かどうか
+*/
+func (s *authLockoutStore) lockedUntil(now time.Time, keys ...string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var until time.Time
+	for _, key := range keys {
+		if e := s.entry(key, now); e != nil && e.LockedUntil != nil && now.Before(*e.LockedUntil) && e.LockedUntil.After(until) {
+			until = *e.LockedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+/*
+fail は認証の失敗を記録し、回数がAUTH_LOCKOUT_THRESHOLDに達したkeyをロックする
+ロックした場合は監査ログ（auth.lockout）に記録する
+
+引数:
+  c *gin.Context - 失敗したリクエスト（監査ログに使用）
+  scheme string - 失敗した認証方式（メトリクスのラベル）
+  keys ...string - 失敗を数える対象（IPアドレスと認証情報）
+*/
+func (s *authLockoutStore) fail(c *gin.Context, scheme string, keys ...string) {
+	authFailuresTotal.Inc(scheme)
+	if authLockoutThreshold <= 0 {
+		return
+	}
+	now := time.Now().UTC()
+
+	type lockout struct {
+		key      string
+		failures int
+		duration time.Duration
+	}
+	var locked []lockout
+	s.mu.Lock()
+	for _, key := range keys {
+		e := s.entry(key, now)
+		if e == nil {
+			s.prune(now)
+			e = &AuthLockout{Key: key}
+			s.entries[key] = e
+		}
+		e.Failures++
+		e.LastFailure = now
+		if e.Failures < authLockoutThreshold {
+			continue
+		}
+		duration := time.Duration(float64(authLockoutBase) * math.Pow(2, float64(e.Lockouts)))
+		if duration <= 0 || duration > authLockoutMax {
+			duration = authLockoutMax
+		}
+		until := now.Add(duration)
+		e.Failures, e.Lockouts, e.LockedUntil = 0, e.Lockouts+1, &until
+		locked = append(locked, lockout{key: key, failures: authLockoutThreshold, duration: duration})
+	}
+	s.mu.Unlock()
+
+	for _, l := range locked {
+		kind, _, _ := strings.Cut(l.key, ":")
+		authLockoutsTotal.Inc(kind)
+		log.Warn().Str("key", l.key).Str("scheme", scheme).Dur("duration", l.duration).Msg("Locked out after repeated authentication failures")
+		auditLog.record(c, "auth.lockout", l.key, map[string]int{"failures": l.failures, "lockout_seconds": int(l.duration.Seconds())})
+	}
+}
+
+/* succeed は認証に成功したIPアドレスの失敗の回数を0に戻す（ロックの回数は残す） */
+func (s *authLockoutStore) succeed(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := s.entries[key]; ok {
+			e.Failures = 0
+		}
+	}
+}
+
+/* prune は記録がauthLockoutMaxEntries件以上の場合、ロック中ではない古い記録から削除する。s.muを取得した状態で呼び出す */
+func (s *authLockoutStore) prune(now time.Time) {
+	if len(s.entries) < authLockoutMaxEntries {
+		return
+	}
+	idle := make([]*AuthLockout, 0, len(s.entries))
+	for _, e := range s.entries {
+		if e.LockedUntil == nil || !now.Before(*e.LockedUntil) {
+			idle = append(idle, e)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].LastFailure.Before(idle[j].LastFailure) })
+	for _, e := range idle[:max(0, min(len(idle), len(s.entries)-authLockoutMaxEntries+1))] {
+		delete(s.entries, e.Key)
+	}
+}
+
+/* unlock はkeyの記録を削除し、削除したかどうかを返す */
+func (s *authLockoutStore) unlock(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok {
+		return false
+	}
+	delete(s.entries, key)
+	return true
+}
+
+/*
+authLockoutKeys はリクエストの認証の試みについて、失敗を数える対象を返す
+Bearerトークンを送ったリクエストはIPアドレスとトークン、GitHubでのログインはIPアドレスのみ
+
+戻り値:
+  []string - 対象（認証を試みていないリクエストの場合はnil）
+*/
+func authLockoutKeys(c *gin.Context) []string {
+	ip := "ip:" + c.ClientIP()
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return []string{ip, apiKeyFingerprint(token)}
+	}
+	if route := strings.TrimPrefix(c.FullPath(), basePath); strings.HasPrefix(route, "/auth/github") {
+		return []string{ip}
+	}
+	return nil
+}
+
+/*
+rejectLockedOut はロック中のIPアドレス・認証情報からの認証の試みを429 Too Many Requestsで拒否する
+authMiddlewareで、認証情報を照合する前に呼び出す
+
+戻り値:
+  bool - 拒否した場合はtrue
+*/
+func rejectLockedOut(c *gin.Context, keys []string) bool {
+	until, locked := authLockouts.lockedUntil(time.Now().UTC(), keys...)
+	if !locked {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+	abortWithError(c, http.StatusTooManyRequests, "too many failed authentication attempts")
+	return true
+}
+
+/*
+getAuthLockouts はロック中・失敗を数えているIPアドレスと認証情報を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"lockouts": []AuthLockout（最後の失敗が新しい順）}
+
+注意:
+  - 記録はインスタンスごと。複数レプリカの場合は giter_auth_lockouts_total で確認する
+*/
+func getAuthLockouts(c *gin.Context) {
+	now := time.Now().UTC()
+	authLockouts.mu.Lock()
+	entries := make([]AuthLockout, 0, len(authLockouts.entries))
+	for key := range authLockouts.entries {
+		if e := authLockouts.entry(key, now); e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	authLockouts.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastFailure.After(entries[j].LastFailure) })
+	respondJSON(c, http.StatusOK, gin.H{"lockouts": entries})
+}
+
+/*
+deleteAuthLockout はIPアドレス・認証情報のロックを解除する管理者APIハンドラー
+
+クエリパラメータ:
+  key string - 解除する対象（例: "ip:203.0.113.10"）
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 404 Not Found（記録がない）
+*/
+func deleteAuthLockout(c *gin.Context) {
+	key := c.Query("key")
+	if !authLockouts.unlock(key) {
+		respondError(c, http.StatusNotFound, "lockout not found")
+		return
+	}
+	auditLog.record(c, "auth.unlock", key, nil)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* lockoutRequest はテストで送るリクエストの接続元とX-Forwarded-For */
+type lockoutRequest struct {
+	remoteAddr string
+	xff        string
+}
+
+func TestAuthLockoutIgnoresUntrustedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		failed  func(i int) lockoutRequest // i回目の失敗したリクエスト
+		checks  map[lockoutRequest]int     // 失敗の後、正しいトークンで送ったリクエストと期待するステータス
+	}{
+		{
+			name:   "rotating X-Forwarded-For does not escape the lockout",
+			failed: func(i int) lockoutRequest { return lockoutRequest{"192.0.2.1:40000", "198.51.100." + strconv.Itoa(i)} },
+			checks: map[lockoutRequest]int{
+				{"192.0.2.1:40001", "198.51.100.200"}: http.StatusTooManyRequests,
+			},
+		},
+		{
+			name:   "forged X-Forwarded-For does not lock out another client",
+			failed: func(int) lockoutRequest { return lockoutRequest{"192.0.2.1:40000", "203.0.113.10"} },
+			checks: map[lockoutRequest]int{
+				{"203.0.113.10:40000", ""}: http.StatusOK,
+				{"192.0.2.1:40001", ""}:    http.StatusTooManyRequests,
+			},
+		},
+		{
+			name:    "trusted proxy forwards the client address",
+			proxies: []string{"10.0.0.1"},
+			failed:  func(int) lockoutRequest { return lockoutRequest{"10.0.0.1:40000", "203.0.113.10"} },
+			checks: map[lockoutRequest]int{
+				{"10.0.0.1:40001", "203.0.113.11"}: http.StatusOK,
+				{"10.0.0.1:40002", "203.0.113.10"}: http.StatusTooManyRequests,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			/* ロックは監査ログに記録するため、DATA_DIRは一時ディレクトリに向ける */
+			dir, proxies, token, keys, threshold := dataDir, trustedProxies, adminToken, apiKeys, authLockoutThreshold
+			dataDir, trustedProxies, adminToken, authLockoutThreshold = t.TempDir(), tt.proxies, testAdminToken, 5
+			apiKeys = loadAPIKeys(adminToken, "")
+			authLockouts.entries = map[string]*AuthLockout{}
+			t.Cleanup(func() {
+				dataDir, trustedProxies, adminToken, apiKeys, authLockoutThreshold = dir, proxies, token, keys, threshold
+				authLockouts.entries = map[string]*AuthLockout{}
+			})
+			gin.SetMode(gin.TestMode)
+			r := newRouter(&backupHandlers{})
+
+			/* 失敗ごとにトークンを変え、IPアドレスの記録のみでロックされるようにする */
+			for i := 0; i < authLockoutThreshold; i++ {
+				if code := serveLockoutRequest(r, tt.failed(i), "wrong-token-"+strconv.Itoa(i)); code != http.StatusUnauthorized {
+					t.Fatalf("failure %d: status %d, want 401", i, code)
+				}
+			}
+			for req, want := range tt.checks {
+				if code := serveLockoutRequest(r, req, testAdminToken); code != want {
+					t.Errorf("%+v: status %d, want %d", req, code, want)
+				}
+			}
+		})
+	}
+}
+
+/* serveLockoutRequest はreqの接続元・X-Forwarded-ForからBearerトークンを付けてロックの一覧を取得し、ステータスを返す */
+func serveLockoutRequest(r *gin.Engine, req lockoutRequest, token string) int {
+	httpReq := httptest.NewRequest(http.MethodGet, "/api/admin/auth/lockouts", nil)
+	httpReq.RemoteAddr = req.remoteAddr
+	if req.xff != "" {
+		httpReq.Header.Set("X-Forwarded-For", req.xff)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httpReq)
+	return w.Code
+}
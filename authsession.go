@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+GitHubのOAuthによるログイン（認証方式 session）
+ログインしたGitHubのユーザーをOAUTH_USERSのロールで認証し、セッションIDに紐付けて保存する
+GITHUB_OAUTH_CLIENT_ID・GITHUB_OAUTH_CLIENT_SECRETが未設定の場合は無効
+*/
+
+const (
+	/* authSessionsTable はログインしたセッションを保存するテーブル名 */
+	authSessionsTable = "auth_sessions"
+	/* oauthStateCookieName はOAuthのstate（CSRF対策）を保存するクッキー名 */
+	oauthStateCookieName = "giter_oauth_state"
+	/* oauthStateMaxAge はstateのクッキーの有効期間（秒）。ログインの画面で操作する時間 */
+	oauthStateMaxAge = 10 * 60
+	/* githubOAuthBase はGitHubのOAuthのエンドポイントのURL */
+	githubOAuthBase = "https://github.com/login/oauth"
+)
+
+var (
+	/* oauthClientID・oauthClientSecret はGitHubのOAuth Appのクライアント情報（環境変数 GITHUB_OAUTH_CLIENT_ID・GITHUB_OAUTH_CLIENT_SECRET） */
+	oauthClientID     = getEnv("GITHUB_OAUTH_CLIENT_ID", "")
+	oauthClientSecret = getEnv("GITHUB_OAUTH_CLIENT_SECRET", "")
+	/*
+		oauthUsers はログインを許可するGitHubのユーザーとロール
+		環境変数 OAUTH_USERS で設定する（"ログイン名:ロール" のカンマ区切り、ロールの省略時は reader）
+		デフォルトはGITHUB_USERNAMEのユーザーのみ（ロール admin）
+	*/
+	oauthUsers = parseOAuthUsers(getEnv("OAUTH_USERS", username+":admin"))
+	/*
+		authSessionTTL はログインしたセッションの有効期間
+		環境変数 AUTH_SESSION_TTL で変更可能（デフォルト: 168h）
+	*/
+	authSessionTTL = parseDurationEnv("AUTH_SESSION_TTL", 7*24*time.Hour)
+)
+
+/* parseOAuthUsers はOAUTH_USERSを読み込む（ログイン名は大文字・小文字を区別しない） */
+func parseOAuthUsers(raw string) map[string]authRole {
+	users := map[string]authRole{}
+	for _, item := range splitList(raw) {
+		login, name, hasRole := strings.Cut(item, ":")
+		role := roleReader
+		if hasRole {
+			var ok bool
+			if role, ok = parseAuthRole(name); !ok || role == roleAnonymous {
+				log.Warn().Str("entry", item).Msg("Ignoring OAUTH_USERS entry with an unknown role")
+				continue
+			}
+		}
+		if login != "" {
+			users[strings.ToLower(login)] = role
+		}
+	}
+	return users
+}
+
+/* oauthEnabled はGitHubのOAuthによるログインが有効かどうかを返す */
+func oauthEnabled() bool {
+	return oauthClientID != "" && oauthClientSecret != ""
+}
+
+/* oauthHasAdmin はOAUTH_USERSに管理者のロールのユーザーがいるかを返す */
+func oauthHasAdmin() bool {
+	for _, role := range oauthUsers {
+		if role == roleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+AuthSession はログインしたセッション
+*/
+type AuthSession struct {
+	Login     string    `json:"login"`      // GitHubのログイン名
+	Role      authRole  `json:"role"`       // ログインした時点のロール
+	CreatedAt time.Time `json:"created_at"` // ログインした日時
+	ExpiresAt time.Time `json:"expires_at"` // 有効期限
+}
+
+/*
+authSessionStore はログインしたセッションを保持するストア
+auth_sessionsテーブルに永続化される
+キーはセッションIDのSHA-256（保存データからセッションのクッキーを復元できないようにする）
+*/
+type authSessionStore struct {
+	mu       sync.Mutex
+	Sessions map[string]AuthSession `json:"sessions"`
+}
+
+/* authSessions はアプリケーション全体で共有するログインしたセッションのストア */
+var authSessions = &authSessionStore{Sessions: map[string]AuthSession{}}
+
+func init() {
+	registerTable(authSessionsTable, loadAuthSessions)
+}
+
+/*
+loadAuthSessions はauth_sessionsテーブルからセッションを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadAuthSessions() error {
+	authSessions.mu.Lock()
+	defer authSessions.mu.Unlock()
+
+	authSessions.Sessions = map[string]AuthSession{}
+	if err := loadTable(authSessionsTable, authSessions); err != nil {
+		return err
+	}
+	if authSessions.Sessions == nil {
+		authSessions.Sessions = map[string]AuthSession{}
+	}
+	return nil
+}
+
+/* authSessionKey はセッションIDから保存に使うキーを返す */
+func authSessionKey(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
+/* get はセッションIDのログインしたセッションを返す（有効期限切れの場合はfalse） */
+func (s *authSessionStore) get(sessionID string, now time.Time) (AuthSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.Sessions[authSessionKey(sessionID)]
+	if !ok || !now.Before(session.ExpiresAt) {
+		return AuthSession{}, false
+	}
+	return session, true
+}
+
+/* put はセッションIDにログインしたセッションを保存する（有効期限切れのセッションは削除する） */
+func (s *authSessionStore) put(sessionID string, session AuthSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, existing := range s.Sessions {
+		if !session.CreatedAt.Before(existing.ExpiresAt) {
+			delete(s.Sessions, key)
+		}
+	}
+	s.Sessions[authSessionKey(sessionID)] = session
+	if err := saveTable(authSessionsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save auth sessions")
+	}
+}
+
+/* remove はセッションIDのログインしたセッションを削除し、削除したかどうかを返す */
+func (s *authSessionStore) remove(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := authSessionKey(sessionID)
+	if _, ok := s.Sessions[key]; !ok {
+		return false
+	}
+	delete(s.Sessions, key)
+	if err := saveTable(authSessionsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save auth sessions")
+	}
+	return true
+}
+
+/* sessionAuthenticator はGitHubのOAuthでログインしたセッションで認証する */
+type sessionAuthenticator struct{}
+
+func (sessionAuthenticator) scheme() string { return authSchemeSession }
+
+/*
+authenticate はセッションのクッキーに紐付くログインを確認する
+ログインした後にOAUTH_USERSから外されたユーザーは認証しない（ロールは現在のOAUTH_USERSに従う）
+*/
+func (sessionAuthenticator) authenticate(c *gin.Context) (*Principal, error) {
+	id := viewerID(c)
+	if !oauthEnabled() || id == "" {
+		return nil, nil
+	}
+	session, ok := authSessions.get(id, time.Now())
+	if !ok {
+		return nil, nil
+	}
+	role, allowed := oauthUsers[strings.ToLower(session.Login)]
+	if !allowed {
+		return nil, nil
+	}
+	return &Principal{Scheme: authSchemeSession, Subject: session.Login, Role: role}, nil
+}
+
+/* oauthCallbackURL はGitHubのOAuth Appに登録するコールバックURLを返す */
+func oauthCallbackURL(c *gin.Context) string {
+	return siteBaseURL(c) + appPath("/auth/github/callback")
+}
+
+/*
+startGitHubLogin はGitHubのログインの画面にリダイレクトするハンドラー（GET /auth/github）
+
+レスポンス:
+  成功時: 302 Found（GitHubの認可の画面）
+  失敗時: 404 Not Found（OAuthが無効）
+*/
+func startGitHubLogin(c *gin.Context) {
+	if !oauthEnabled() {
+		respondError(c, http.StatusNotFound, "GitHub login is not enabled")
+		return
+	}
+	state := newSessionID()
+	/* GitHubからのリダイレクト（他サイトからの遷移）で送られるよう、SESSION_COOKIE_SAMESITEによらずLaxにする */
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookieName, state, oauthStateMaxAge, appPath("/auth/"), "", c.Request.TLS != nil, true)
+
+	query := url.Values{}
+	query.Set("client_id", oauthClientID)
+	query.Set("redirect_uri", oauthCallbackURL(c))
+	query.Set("state", state)
+	query.Set("allow_signup", "false")
+	c.Redirect(http.StatusFound, githubOAuthBase+"/authorize?"+query.Encode())
+}
+
+/* oauthTokenResponse はアクセストークンのエンドポイントのレスポンス */
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+}
+
+/*
+exchangeOAuthCode は認可コードをアクセストークンと交換し、ログインしたユーザーのログイン名を返す
+アクセストークンはログイン名の確認のみに使い、保存しない
+*/
+func exchangeOAuthCode(c *gin.Context, code string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", oauthClientID)
+	form.Set("client_secret", oauthClientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", oauthCallbackURL(c))
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, githubOAuthBase+"/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := upstreamClient(upstreamOpOAuth)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var token oauthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("OAuth token exchange failed: %s", token.Error)
+	}
+
+	req, err = http.NewRequestWithContext(c.Request.Context(), http.MethodGet, githubAPIBase+"/user", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", githubAcceptV3)
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub user request failed: %s", resp.Status)
+	}
+	var user githubUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
+
+/*
+githubLoginCallback はGitHubの認可の後に呼び出されるハンドラー（GET /auth/github/callback）
+stateを確認してログイン名を取得し、OAUTH_USERSのユーザーであればセッションにログインを保存する
+
+クエリパラメータ:
+  code string - 認可コード
+  state string - startGitHubLoginで発行したstate
+
+レスポンス:
+  成功時: 302 Found（トップページ）
+  失敗時: 400 Bad Request（stateが一致しない）, 403 Forbidden（OAUTH_USERSにいない）, 404 Not Found（OAuthが無効）, 502 Bad Gateway（GitHubとの通信に失敗）
+
+注意:
+  - セッション固定攻撃を防ぐため、ログインの際にセッションIDを発行し直す
+  - stateが一致しない・OAUTH_USERSにいない場合は認証の失敗として数える（authlockout.go）
+*/
+func githubLoginCallback(c *gin.Context) {
+	if !oauthEnabled() {
+		respondError(c, http.StatusNotFound, "GitHub login is not enabled")
+		return
+	}
+	state, err := c.Cookie(oauthStateCookieName)
+	c.SetCookie(oauthStateCookieName, "", -1, appPath("/auth/"), "", c.Request.TLS != nil, true)
+	if err != nil || state == "" || c.Query("state") != state || c.Query("code") == "" {
+		authLockouts.fail(c, authSchemeSession, "ip:"+c.ClientIP())
+		respondError(c, http.StatusBadRequest, "invalid OAuth state")
+		return
+	}
+
+	login, err := exchangeOAuthCode(c, c.Query("code"))
+	if err != nil {
+		log.Warn().Err(err).Msg("GitHub login failed")
+		respondError(c, http.StatusBadGateway, "failed to sign in with GitHub")
+		return
+	}
+	role, ok := oauthUsers[strings.ToLower(login)]
+	if !ok {
+		log.Warn().Str("login", login).Str("client_ip", c.ClientIP()).Msg("Rejected GitHub login of a user not in OAUTH_USERS")
+		authLockouts.fail(c, authSchemeSession, "ip:"+c.ClientIP(), "login:"+strings.ToLower(login))
+		respondError(c, http.StatusForbidden, "this GitHub account is not allowed to sign in")
+		return
+	}
+
+	authLockouts.succeed("ip:" + c.ClientIP())
+	id := newSessionID()
+	setSessionCookie(c, id)
+	c.Set(sessionContextKey, id)
+	now := time.Now().UTC()
+	authSessions.put(id, AuthSession{Login: login, Role: role, CreatedAt: now, ExpiresAt: now.Add(authSessionTTL)})
+	principal := Principal{Scheme: authSchemeSession, Subject: login, Role: role}
+	c.Set(principalContextKey, principal)
+
+	auditLog.record(c, "auth.login", login, nil)
+	log.Info().Str("login", login).Str("role", role.String()).Msg("Signed in with GitHub")
+	c.Redirect(http.StatusFound, appPath("/"))
+}
+
+/*
+postLogout はログインしたセッションを削除するハンドラー（POST /auth/logout）
+
+レスポンス:
+  204 No Content（ログインしていない場合も同じ）
+*/
+func postLogout(c *gin.Context) {
+	principal := requestPrincipal(c)
+	if authSessions.remove(viewerID(c)) && principal.Scheme == authSchemeSession {
+		auditLog.record(c, "auth.logout", principal.Subject, nil)
+	}
+	c.Status(http.StatusNoContent)
+}
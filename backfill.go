@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* backfillTable はバックフィルの進捗（リポジトリごとのチェックポイント）を保存するテーブル名 */
+	backfillTable = "backfill"
+)
+
+/*
+backfillRateReserve はバックフィルなどのbackgroundのジョブの実行中も残しておくGitHub APIのリクエスト数
+環境変数 BACKFILL_RATE_RESERVE で変更可能（デフォルト: 500）
+残り回数がこれ以下になると、画面表示などのリクエストを妨げないようリセット時刻まで待機する
+（ジョブキューもbackgroundのレーンのジョブを取り出さない）
+実際に残す回数はGitHubが返す上限の一部までに抑える（rateReserveを参照）
+*/
+var backfillRateReserve = getEnvInt("BACKFILL_RATE_RESERVE", 500)
+
+/*
+rateReserveMaxShare はbackgroundのジョブのために残すリクエスト数の上限（GitHubが返す1時間あたりの上限に対する割合）
+認証なしの上限（60回）ではBACKFILL_RATE_RESERVEのデフォルトが上限を超え、backgroundのジョブが常に止まるのを防ぐ
+*/
+const rateReserveMaxShare = 0.25
+
+/*
+rateReserve はレート制限の状態に対して実際に残すリクエスト数を返す
+BACKFILL_RATE_RESERVEと、GitHubが返す上限（X-RateLimit-Limit）のrateReserveMaxShareのうち小さい方
+*/
+func rateReserve(state RateLimitState) int {
+	if state.Limit <= 0 {
+		return backfillRateReserve
+	}
+	return min(backfillRateReserve, int(float64(state.Limit)*rateReserveMaxShare))
+}
+
+/*
+backfillAutoResume は起動時に未完了のバックフィルを自動で再開するかどうか
+環境変数 BACKFILL_AUTO_RESUME で変更可能（デフォルト: true）
+*/
+var backfillAutoResume = getEnvBool("BACKFILL_AUTO_RESUME", true)
+
+/*
+BackfillCheckpoint はリポジトリごとのバックフィルの進捗
+ページ単位で保存するため、中断しても次に取得するページから再開できる
+*/
+type BackfillCheckpoint struct {
+	NextPage  int       `json:"next_page"`       // 次に取得するページ（1始まり）
+	Commits   int       `json:"commits"`         // 取得済みのコミット数
+	LastSHA   string    `json:"last_sha"`        // 最後に取得したページの最も古いコミットのSHA
+	ETag      string    `json:"etag"`            // 最後に取得したページのETag
+	Done      bool      `json:"done"`            // 最後のページまで取得した場合はtrue
+	Error     string    `json:"error,omitempty"` // 最後に発生したエラー（次回の実行で同じページから再試行する）
+	UpdatedAt time.Time `json:"updated_at"`      // 最後にチェックポイントを更新した日時
+}
+
+/*
+BackfillStatus はバックフィルジョブの状態
+*/
+type BackfillStatus struct {
+	Running      bool                          `json:"running"`      // 実行中の場合はtrue
+	Until        time.Time                     `json:"until"`        // 取得対象の終端（ジョブの開始日時、再開時も同じ値を使いページの位置をずらさない）
+	StartedAt    *time.Time                    `json:"started_at"`   // 最後に実行（または再開）した日時
+	FinishedAt   *time.Time                    `json:"finished_at"`  // すべてのリポジトリの取得を終えた日時（未完了の場合はnull）
+	Repositories map[string]BackfillCheckpoint `json:"repositories"` // リポジトリ名ごとのチェックポイント
+}
+
+/*
+backfillStore はバックフィルの状態を保持するストア
+backfillテーブルに永続化される
+*/
+type backfillStore struct {
+	mu     sync.Mutex
+	Status BackfillStatus `json:"status"`
+}
+
+/* backfill はアプリケーション全体で共有するバックフィルの状態 */
+var backfill = &backfillStore{Status: BackfillStatus{Repositories: map[string]BackfillCheckpoint{}}}
+
+func init() {
+	registerTable(backfillTable, loadBackfill)
+}
+
+/*
+loadBackfill はbackfillテーブルからバックフィルの状態を復元する
+実行中に停止した場合もRunningは保存されたままのため、読み込み時にfalseに戻す
+（POST /api/admin/backfill または ./giter backfill で続きから再開できる）
+*/
+func loadBackfill() error {
+	backfill.mu.Lock()
+	defer backfill.mu.Unlock()
+
+	backfill.Status = BackfillStatus{}
+	if err := loadTable(backfillTable, backfill); err != nil {
+		return err
+	}
+	if backfill.Status.Repositories == nil {
+		backfill.Status.Repositories = map[string]BackfillCheckpoint{}
+	}
+	backfill.Status.Running = false
+	return nil
+}
+
+/* save はバックフィルの状態をテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *backfillStore) save() {
+	if err := saveTable(backfillTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save backfill checkpoints")
+	}
+}
+
+/* status はバックフィルの状態のコピーを返す */
+func (s *backfillStore) status() BackfillStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.Status
+	status.Repositories = make(map[string]BackfillCheckpoint, len(s.Status.Repositories))
+	for name, cp := range s.Status.Repositories {
+		status.Repositories[name] = cp
+	}
+	return status
+}
+
+/*
+begin はバックフィルの開始を記録する
+前回のジョブがすべて完了している場合（または初回）は新しいジョブとしてチェックポイントを作り直し、
+未完了の場合は保存されたチェックポイントから再開する
+
+戻り値:
+  bool - すでに実行中の場合はfalse
+*/
+func (s *backfillStore) begin(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Status.Running {
+		return false
+	}
+	if s.Status.StartedAt == nil || s.Status.FinishedAt != nil {
+		s.Status = BackfillStatus{Until: now, Repositories: map[string]BackfillCheckpoint{}}
+	}
+	s.Status.Running = true
+	s.Status.StartedAt = &now
+	s.save()
+	return true
+}
+
+/* checkpoint はリポジトリの現在のチェックポイントを返す（未登録の場合は1ページ目から） */
+func (s *backfillStore) checkpoint(repo string) BackfillCheckpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, ok := s.Status.Repositories[repo]
+	if !ok {
+		cp = BackfillCheckpoint{NextPage: 1}
+	}
+	return cp
+}
+
+/* update はリポジトリのチェックポイントを更新して保存する */
+func (s *backfillStore) update(repo string, cp BackfillCheckpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp.UpdatedAt = time.Now()
+	s.Status.Repositories[repo] = cp
+	s.save()
+}
+
+/*
+finish はバックフィルの終了を記録する
+すべてのリポジトリが完了した場合のみFinishedAtを設定し、次回の実行は新しいジョブになる
+*/
+func (s *backfillStore) finish() BackfillStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Status.Running = false
+	done := true
+	for _, cp := range s.Status.Repositories {
+		if !cp.Done {
+			done = false
+		}
+	}
+	if done {
+		now := time.Now()
+		s.Status.FinishedAt = &now
+	}
+	s.save()
+	return s.Status
+}
+
+/*
+waitForRateBudget はGitHub APIの残りリクエスト数が残す回数（rateReserve）以下の間、リセット時刻まで待機する
+レート制限の状態をまだ受け取っていない場合は待機しない
+*/
+func waitForRateBudget() {
+	state := currentRateLimit()
+	if state.UpdatedAt.IsZero() || state.Remaining > rateReserve(state) {
+		return
+	}
+	wait := time.Until(state.Reset)
+	if wait <= 0 {
+		return
+	}
+	log.Info().
+		Int("remaining", state.Remaining).
+		Int("reserve", rateReserve(state)).
+		Time("reset", state.Reset).
+		Msg("Backfill paused until GitHub rate limit resets")
+	/* リセット時刻の直後はヘッダーの更新が遅れる場合があるため、1秒余裕をみる */
+	time.Sleep(wait + time.Second)
+}
+
+/*
+runBackfill は全リポジトリの全コミットをページ単位で取得し、アーカイブ（data/archive）に保存する
+通常の /api/git-history はリポジトリごとに最新100件のみのため、
+完全な履歴は ?include_archive=true でバックフィルの結果から読み込む
+
+戻り値:
+  BackfillStatus - 終了時の状態
+  error - すでに実行中、またはリポジトリ一覧を取得できなかった場合のエラー
+
+注意:
+  - リポジトリごとのエラーはチェックポイントに記録して次のリポジトリに進む
+    （失敗したリポジトリは次回の実行で同じページから再試行する）
+  - ページはジョブ開始時のUntilまでのコミットに固定し、再開しても新しいコミットでページの位置がずれないようにする
+*/
+func runBackfill() (BackfillStatus, error) {
+	if !backfill.begin(time.Now()) {
+		return backfill.status(), fmt.Errorf("backfill is already running")
+	}
+	return backfillAll()
+}
+
+/*
+backfillAll はbeginで開始を記録したバックフィルを実行し、終了を記録する
+複数のレプリカで実行している場合は、backfillロックを取得した1つだけが実行する
+*/
+func backfillAll() (BackfillStatus, error) {
+	var status BackfillStatus
+	var err error
+	if !runExclusive(lockNameBackfill, func() { status, err = backfillRepositories() }) {
+		return backfill.finish(), fmt.Errorf("failed to acquire backfill lock (running on another replica?)")
+	}
+	return status, err
+}
+
+/* backfillRepositories は全リポジトリをチェックポイントの続きから取得する */
+func backfillRepositories() (BackfillStatus, error) {
+	repos, err := fetchRepositoriesAt(jobPriorityBackground)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for backfill")
+		return backfill.finish(), err
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+
+	until := backfill.status().Until
+	log.Info().Int("repositories", len(repos)).Time("until", until).Msg("Backfill started")
+	for _, repo := range repos {
+		backfillRepository(repo, until)
+	}
+
+	status := backfill.finish()
+	log.Info().Bool("completed", status.FinishedAt != nil).Msg("Backfill finished")
+	return status, nil
+}
+
+/*
+backfillRepository は1つのリポジトリのコミットをチェックポイントの続きから最後のページまで取得する
+各ページはbackfill-pageジョブとしてbackgroundのレーンで取得し、画面表示のための取得を先に実行させる
+*/
+func backfillRepository(repo Repository, until time.Time) {
+	cp := backfill.checkpoint(repo.Name)
+	for !cp.Done {
+		waitForRateBudget()
+
+		url := fmt.Sprintf("%s/repos/%s/commits?until=%s&per_page=100&page=%d",
+			githubAPIBase, repo.FullName, until.UTC().Format(time.RFC3339), cp.NextPage)
+		var commits []Commit
+		err := runJob(jobPriorityBackground, jobKindBackfillPage, repo.FullName, func() error {
+			return fetchGitHubJSON(upstreamOpCommits, url, githubAcceptV3, &commits)
+		})
+		if err == nil {
+			err = archiveBackfilledCommits(repo.Name, commits)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("repository", repo.Name).Int("page", cp.NextPage).Msg("Backfill failed for repository")
+			cp.Error = err.Error()
+			backfill.update(repo.Name, cp)
+			return
+		}
+
+		cp.Commits += len(commits)
+		if len(commits) > 0 {
+			cp.LastSHA = commits[len(commits)-1].SHA
+		}
+		cp.ETag = cachedETag(url, githubAcceptV3)
+		cp.NextPage++
+		cp.Done = len(commits) < 100
+		cp.Error = ""
+		backfill.update(repo.Name, cp)
+		log.Debug().Str("repository", repo.Name).Int("page", cp.NextPage-1).Int("commits", cp.Commits).Msg("Backfill page stored")
+	}
+}
+
+/* archiveBackfilledCommits は取得したコミットを年月ごとのアーカイブ（history）に追加する */
+func archiveBackfilledCommits(repoName string, commits []Commit) error {
+	byMonth := map[string][]CommitHistory{}
+	for _, commit := range commits {
+		history := newCommitHistory(repoName, commit)
+		byMonth[archiveMonth(history)] = append(byMonth[archiveMonth(history)], history)
+	}
+
+	for month, monthCommits := range byMonth {
+		if err := appendArchive(month, monthCommits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+resumeBackfill は未完了のバックフィル（実行中に再起動した場合など）をバックグラウンドで再開する
+サーバーの起動時に呼び出す。BACKFILL_AUTO_RESUME=false の場合や、未完了のジョブがない場合は何もしない
+*/
+func resumeBackfill() {
+	status := backfill.status()
+	if !backfillAutoResume || status.StartedAt == nil || status.FinishedAt != nil {
+		return
+	}
+	if !backfill.begin(time.Now()) {
+		return
+	}
+	log.Info().Time("until", status.Until).Msg("Resuming unfinished backfill")
+	go func() {
+		if _, err := backfillAll(); err != nil {
+			log.Error().Err(err).Msg("Backfill failed")
+		}
+	}()
+}
+
+/*
+getBackfill はバックフィルの状態を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, BackfillStatus
+*/
+func getBackfill(c *gin.Context) {
+	respondJSON(c, http.StatusOK, backfill.status())
+}
+
+/*
+postBackfill はバックフィルをバックグラウンドで開始する管理者APIハンドラー
+未完了のジョブがある場合は保存されたチェックポイントから再開する
+
+レスポンス:
+  成功時: 202 Accepted, BackfillStatus（開始時の状態）
+  失敗時: 409 Conflict（すでに実行中）
+*/
+func postBackfill(c *gin.Context) {
+	/* 同時に複数のリクエストが来ても1つだけ開始するよう、開始の記録はハンドラー内で行う */
+	if !backfill.begin(time.Now()) {
+		respondError(c, http.StatusConflict, "backfill is already running")
+		return
+	}
+	go func() {
+		if _, err := backfillAll(); err != nil {
+			log.Error().Err(err).Msg("Backfill failed")
+		}
+	}()
+	respondJSON(c, http.StatusAccepted, backfill.status())
+}
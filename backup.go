@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* backupNamePrefix はバックアップファイル名の接頭辞 */
+	backupNamePrefix = "giter-backup-"
+	/* backupNameTimeFormat はバックアップファイル名に含める日時の形式（名前順 = 作成順になる） */
+	backupNameTimeFormat = "20060102-150405"
+)
+
+/*
+BackupConfig はバックアップの設定
+環境変数から loadBackupConfig で読み込む
+*/
+type BackupConfig struct {
+	Interval  time.Duration // 定期バックアップの間隔（0の場合は定期実行しない）
+	Retention int           // 保持するバックアップの世代数（0以下の場合は削除しない）
+	Dir       string        // ローカル保存先ディレクトリ
+	S3Bucket  string        // S3バケット名（設定時はローカルではなくS3に保存）
+	S3Prefix  string        // S3バケット内のキーの接頭辞
+}
+
+/*
+loadBackupConfig は環境変数からバックアップ設定を読み込む
+
+使用する環境変数:
+  BACKUP_INTERVAL  - 定期バックアップの間隔（例: "24h"、未設定時は定期実行しない）
+  BACKUP_RETENTION - 保持する世代数（デフォルト: 7）
+  BACKUP_DIR       - ローカル保存先（デフォルト: "backups"）
+  BACKUP_S3_BUCKET - S3バケット名（認証情報は newS3ClientFromEnv を参照）
+  BACKUP_S3_PREFIX - S3キーの接頭辞（デフォルト: "backups/"）
+*/
+func loadBackupConfig() (BackupConfig, error) {
+	cfg := BackupConfig{
+		Retention: getEnvInt("BACKUP_RETENTION", 7),
+		Dir:       getEnv("BACKUP_DIR", "backups"),
+		S3Bucket:  getEnv("BACKUP_S3_BUCKET", ""),
+		S3Prefix:  getEnv("BACKUP_S3_PREFIX", "backups/"),
+	}
+	if raw := getEnv("BACKUP_INTERVAL", ""); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return cfg, fmt.Errorf("invalid BACKUP_INTERVAL: %s", raw)
+		}
+		cfg.Interval = interval
+	}
+	return cfg, nil
+}
+
+/*
+newBackupStorage は設定に応じたバックアップの保存先を作成する
+BACKUP_S3_BUCKETが設定されている場合はS3、それ以外はローカルディレクトリのBlobStoreを使用する
+バックアップ名がそのままBlobStoreのキーになる
+*/
+func newBackupStorage(cfg BackupConfig) (BlobStore, error) {
+	if cfg.S3Bucket != "" {
+		return newS3BlobStore(cfg.S3Bucket, cfg.S3Prefix)
+	}
+	return newLocalBlobStore(cfg.Dir), nil
+}
+
+/*
+listBackups は保存先にあるバックアップ名の一覧を返す
+保存先にバックアップ以外のファイルがあっても対象に含めない
+*/
+func listBackups(storage BlobStore) ([]string, error) {
+	keys, err := storage.List("")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, key := range keys {
+		if isBackupName(key) {
+			names = append(names, key)
+		}
+	}
+	return names, nil
+}
+
+/*
+isBackupName はファイル名がこのアプリケーションが作成したバックアップの形式かどうかを返す
+パス区切りを含む名前を拒否することで、復元時のパストラバーサルも防ぐ
+*/
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupNamePrefix) &&
+		strings.HasSuffix(name, ".zip") &&
+		!strings.ContainsAny(name, `/\`)
+}
+
+/*
+createBackup は保存データのバックアップを作成し、保持世代数を超えた古いバックアップを削除する
+バックアップの内容はエクスポートAPIと同じzipアーカイブ（buildExportArchive）
+
+戻り値:
+  string - 作成したバックアップ名（例: giter-backup-20260214-030000.zip）
+  error - アーカイブ作成または保存に失敗した場合のエラー
+
+注意:
+  - 古いバックアップの削除に失敗してもバックアップ自体は成功として扱い、ログ出力のみ行う
+*/
+func createBackup(storage BlobStore, retention int) (string, error) {
+	archive, err := buildExportArchive()
+	if err != nil {
+		return "", err
+	}
+
+	name := backupNamePrefix + time.Now().Format(backupNameTimeFormat) + ".zip"
+	if err := storage.Put(name, archive, "application/zip"); err != nil {
+		return "", fmt.Errorf("failed to store backup %s: %w", name, err)
+	}
+	log.Info().Str("backup", name).Int("size", len(archive)).Msg("Backup created")
+
+	if err := pruneBackups(storage, retention); err != nil {
+		log.Warn().Err(err).Msg("Failed to prune old backups")
+	}
+	return name, nil
+}
+
+/*
+pruneBackups は新しい順にretention件を残し、それより古いバックアップを削除する
+バックアップ名は日時を含むため、名前の降順が新しい順になる
+*/
+func pruneBackups(storage BlobStore, retention int) error {
+	if retention <= 0 {
+		return nil
+	}
+	names, err := listBackups(storage)
+	if err != nil {
+		return err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names[min(retention, len(names)):] {
+		if err := storage.Delete(name); err != nil {
+			return fmt.Errorf("failed to delete backup %s: %w", name, err)
+		}
+		log.Info().Str("backup", name).Msg("Old backup deleted")
+	}
+	return nil
+}
+
+/*
+restoreBackup は指定したバックアップから保存データを復元する
+
+引数:
+  name string - バックアップ名（"latest" の場合は最新のバックアップ）
+*/
+func restoreBackup(storage BlobStore, name string) (ExportMetadata, error) {
+	if name == "latest" {
+		names, err := listBackups(storage)
+		if err != nil {
+			return ExportMetadata{}, err
+		}
+		if len(names) == 0 {
+			return ExportMetadata{}, fmt.Errorf("no backups found")
+		}
+		sort.Strings(names)
+		name = names[len(names)-1]
+	}
+	if !isBackupName(name) {
+		return ExportMetadata{}, fmt.Errorf("invalid backup name: %s", name)
+	}
+
+	data, err := storage.Get(name)
+	if err != nil {
+		return ExportMetadata{}, fmt.Errorf("failed to read backup %s: %w", name, err)
+	}
+	meta, err := restoreExportArchive(data)
+	if err != nil {
+		return meta, err
+	}
+	log.Info().Str("backup", name).Strs("tables", meta.Tables).Int("history", meta.History).Msg("Backup restored")
+	return meta, nil
+}
+
+/*
+startBackupScheduler は設定された間隔で定期的にバックアップを作成するゴルーチンを起動する
+Intervalが0の場合は何もしない
+*/
+func startBackupScheduler(cfg BackupConfig, storage BlobStore) {
+	if cfg.Interval <= 0 {
+		return
+	}
+	log.Info().Dur("interval", cfg.Interval).Int("retention", cfg.Retention).Msg("Backup scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			/* 複数のレプリカで実行している場合は、ロックを取得した1つだけがバックアップを作成する */
+			runExclusive(lockNameBackup, func() {
+				if _, err := createBackup(storage, cfg.Retention); err != nil {
+					log.Error().Err(err).Msg("Scheduled backup failed")
+				}
+			})
+		}
+	}()
+}
+
+/*
+backupHandlers はバックアップ関連の管理者APIハンドラーをまとめる構造体
+起動時に作成した保存先と設定を各ハンドラーで共有する
+*/
+type backupHandlers struct {
+	cfg     BackupConfig
+	storage BlobStore
+}
+
+/*
+list は保存されているバックアップの一覧を返す
+
+レスポンス:
+  200 OK, {"backups": [バックアップ名（新しい順）]}
+*/
+func (h *backupHandlers) list(c *gin.Context) {
+	names, err := listBackups(h.storage)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	if names == nil {
+		names = []string{}
+	}
+	respondJSON(c, http.StatusOK, gin.H{"backups": names})
+}
+
+/*
+create はバックアップを即座に作成する
+
+レスポンス:
+  成功時: 201 Created, {"backup": 作成したバックアップ名}
+  失敗時: 500 Internal Server Error
+*/
+func (h *backupHandlers) create(c *gin.Context) {
+	name, err := createBackup(h.storage, h.cfg.Retention)
+	if err != nil {
+		log.Error().Err(err).Msg("Manual backup failed")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(c, http.StatusCreated, gin.H{"backup": name})
+}
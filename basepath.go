@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+basePath はアプリケーション全体をマウントするURLパスの接頭辞（環境変数 BASE_PATH）
+リバースプロキシのサブパス（例: https://example.com/giter/）で公開する場合に "/giter" のように設定する
+未設定の場合は空文字（ルートで公開）
+
+注意:
+  - リバースプロキシは接頭辞を取り除かずに転送すること（/giter/api/... のまま受け取る）
+  - 先頭のスラッシュは補い、末尾のスラッシュは取り除く（"giter/" -> "/giter"）
+*/
+var basePath = normalizeBasePath(getEnv("BASE_PATH", ""))
+
+/* normalizeBasePath はBASE_PATHの値を "/接頭辞" の形式（ルートの場合は空文字）に正規化する */
+func normalizeBasePath(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), "/")
+	if value == "" {
+		return ""
+	}
+	return "/" + value
+}
+
+/*
+appPath はアプリケーション内のパスにBASE_PATHを付けたURLパスを返す
+テンプレート以外（マニフェスト、テーマのCSS、リンクの書き換えなど）でURLを組み立てる際に使用する
+
+例: BASE_PATH=/giter の場合 appPath("/api/git-history") -> "/giter/api/git-history"
+*/
+func appPath(path string) string {
+	return basePath + path
+}
+
+/*
+redirectToBasePath はBASE_PATH設定時にルート（"/"）へのアクセスをBASE_PATHにリダイレクトするハンドラー
+リバースプロキシを経由せずに直接アクセスした場合の案内用
+*/
+func redirectToBasePath(c *gin.Context) {
+	c.Redirect(http.StatusFound, appPath("/"))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+batchMaxRequests は POST /api/batch の1回のリクエストに含められるサブリクエストの最大数
+環境変数 BATCH_MAX_REQUESTS で変更可能（デフォルト: 20）
+*/
+var batchMaxRequests = getEnvInt("BATCH_MAX_REQUESTS", 20)
+
+/* batchForwardedHeaders はサブリクエストに引き継ぐリクエストヘッダー（認証・セッション・言語・機能フラグ） */
+var batchForwardedHeaders = []string{"Authorization", "Cookie", "Accept-Language", featureOverrideHeader}
+
+/*
+batchSubRequest はバッチに含める1つのGETリクエスト
+*/
+type batchSubRequest struct {
+	ID     string            `json:"id"`     // 結果を識別する任意の文字列（省略時は空文字）
+	Path   string            `json:"path"`   // APIのパス（例: "/api/stats/health"、BASE_PATHとクエリ文字列は含めない）
+	Params map[string]string `json:"params"` // クエリパラメータ
+}
+
+/* batchRequest は POST /api/batch のリクエストボディ（サブリクエストの配列） */
+type batchRequest []batchSubRequest
+
+/* validateFields はサブリクエストの数とパスを検証する */
+func (req *batchRequest) validateFields() []FieldError {
+	var fields []FieldError
+	if len(*req) == 0 || len(*req) > batchMaxRequests {
+		fields = append(fields, FieldError{Field: "requests", Rule: "max", Message: fmt.Sprintf("must contain 1 to %d requests", batchMaxRequests)})
+	}
+	for i, sub := range *req {
+		field := fmt.Sprintf("[%d].path", i)
+		switch {
+		case !strings.HasPrefix(sub.Path, "/api/") || path.Clean(sub.Path) != sub.Path || strings.ContainsAny(sub.Path, "?#"):
+			fields = append(fields, FieldError{Field: field, Rule: "path", Message: "must be an /api/ path without a query string"})
+		case sub.Path == "/api/batch":
+			fields = append(fields, FieldError{Field: field, Rule: "path", Message: "cannot nest batch requests"})
+		}
+	}
+	return fields
+}
+
+/*
+BatchResponse はサブリクエストの結果
+*/
+type BatchResponse struct {
+	ID     string      `json:"id"`     // リクエストで指定したID
+	Path   string      `json:"path"`   // リクエストしたパス
+	Status int         `json:"status"` // HTTPステータスコード
+	Body   interface{} `json:"body"`   // レスポンスボディ（JSONの場合はそのまま、それ以外は文字列）
+}
+
+/*
+batchHandler は複数のGETリクエストをまとめて実行するAPIハンドラーを返す
+ダッシュボードが概要・カレンダー・最近のコミットなどを1回の往復で取得するために使用する
+
+引数:
+  engine *gin.Engine - サブリクエストを処理するエンジン（通常のリクエストと同じミドルウェア・ハンドラーを通す）
+
+リクエストボディ:
+  [{"id": "health", "path": "/api/stats/health", "params": {"repo": "giter"}}, {"id": "recent", "path": "/api/git-history", "params": {"per_page": "10"}}]
+
+レスポンス:
+  成功時: 200 OK, {"responses": [BatchResponse（リクエストと同じ順）]}（サブリクエストの失敗は各要素のstatusで返す）
+  失敗時: 400 Bad Request（不正なJSON）, 422 Unprocessable Entity（サブリクエストの数・パスが不正）
+
+注意:
+  - サブリクエストはsyncWorkers個まで並行して実行する
+  - Authorization・Cookie・Accept-Language・X-Giter-Features ヘッダーを引き継ぐため、PRIVACY_MODEや管理者の認証は個別のリクエストと同じに適用される
+  - サブリクエストのリクエストIDは "<バッチのリクエストID>.<インデックス>"
+*/
+func batchHandler(engine *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		responses := make([]BatchResponse, len(req))
+		runConcurrently(len(req), func(i int) {
+			responses[i] = runBatchSubRequest(c, engine, i, req[i])
+		})
+		respondJSON(c, http.StatusOK, gin.H{"responses": responses})
+	}
+}
+
+/* runBatchSubRequest はサブリクエストをエンジンで処理し、結果を返す */
+func runBatchSubRequest(c *gin.Context, engine *gin.Engine, i int, sub batchSubRequest) BatchResponse {
+	query := url.Values{}
+	for name, value := range sub.Params {
+		query.Set(name, value)
+	}
+	target := appPath(sub.Path)
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	r := httptest.NewRequest(http.MethodGet, target, nil).WithContext(c.Request.Context())
+	r.RemoteAddr = c.Request.RemoteAddr
+	for _, name := range batchForwardedHeaders {
+		if value := c.GetHeader(name); value != "" {
+			r.Header.Set(name, value)
+		}
+	}
+	r.Header.Set(requestIDHeader, fmt.Sprintf("%s.%d", requestID(c), i))
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, r)
+
+	res := BatchResponse{ID: sub.ID, Path: sub.Path, Status: rec.Code}
+	body := rec.Body.Bytes()
+	if strings.Contains(rec.Header().Get("Content-Type"), "json") && json.Valid(body) {
+		res.Body = json.RawMessage(body)
+	} else {
+		res.Body = string(body)
+	}
+	return res
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+errBlobNotFound は指定したキーのオブジェクトが存在しない場合のエラー
+ローカル・S3どちらの実装でも同じエラーを返す
+*/
+var errBlobNotFound = errors.New("blob not found")
+
+/*
+BlobStore はサイズの大きいデータ（エクスポート、バックアップ、生成した画像など）の
+保存先を抽象化するインターフェース
+キーは "exports/giter-export-20260214.zip" のようにスラッシュ区切りの相対パス
+*/
+type BlobStore interface {
+	/* Put はデータを保存する（同じキーが存在する場合は上書き） */
+	Put(key string, data []byte, contentType string) error
+	/* Get はデータを取得する（存在しない場合は errBlobNotFound） */
+	Get(key string) ([]byte, error)
+	/* List は接頭辞に一致するキーの一覧をソートして返す */
+	List(prefix string) ([]string, error)
+	/* Delete はデータを削除する */
+	Delete(key string) error
+}
+
+/*
+newBlobStoreFromEnv は環境変数の設定に応じたBlobStoreを作成する
+
+使用する環境変数:
+  BLOB_STORAGE   - "local"（デフォルト）または "s3"
+  BLOB_DIR       - localの保存先ディレクトリ（デフォルト: "blobs"）
+  BLOB_S3_BUCKET - s3のバケット名（s3の場合は必須）
+  BLOB_S3_PREFIX - s3のキーの接頭辞（デフォルト: なし）
+  S3の認証情報・エンドポイントは newS3ClientFromEnv を参照（AWS_* 環境変数）
+*/
+func newBlobStoreFromEnv() (BlobStore, error) {
+	switch kind := getEnv("BLOB_STORAGE", "local"); kind {
+	case "local":
+		return newLocalBlobStore(getEnv("BLOB_DIR", "blobs")), nil
+	case "s3":
+		bucket := getEnv("BLOB_S3_BUCKET", "")
+		if bucket == "" {
+			return nil, fmt.Errorf("BLOB_S3_BUCKET is required when BLOB_STORAGE=s3")
+		}
+		return newS3BlobStore(bucket, getEnv("BLOB_S3_PREFIX", ""))
+	default:
+		return nil, fmt.Errorf("unsupported BLOB_STORAGE: %s (supported: local, s3)", kind)
+	}
+}
+
+/*
+validBlobKey はキーが安全な相対パスかどうかを返す
+ローカル実装でルートディレクトリの外を読み書きされないよう、
+絶対パス・".." を含むキー・空のキーを拒否する
+*/
+func validBlobKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+/* localBlobStore はローカルディレクトリにデータを保存するBlobStoreの実装 */
+type localBlobStore struct {
+	root string
+}
+
+/* newLocalBlobStore はrootディレクトリを保存先とするBlobStoreを作成する */
+func newLocalBlobStore(root string) *localBlobStore {
+	return &localBlobStore{root: root}
+}
+
+func (s *localBlobStore) path(key string) (string, error) {
+	if !validBlobKey(key) {
+		return "", fmt.Errorf("invalid blob key: %s", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *localBlobStore) Put(key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	/* 書き込み途中のファイルが読まれないよう、一時ファイルからリネームする */
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s *localBlobStore) Get(key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+func (s *localBlobStore) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (s *localBlobStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+/* s3BlobStore はS3互換バケットにデータを保存するBlobStoreの実装 */
+type s3BlobStore struct {
+	client *s3Client
+	prefix string // バケット内のキーの接頭辞（例: "giter/"）
+}
+
+/* newS3BlobStore はbucketのprefix配下を保存先とするBlobStoreを作成する */
+func newS3BlobStore(bucket, prefix string) (*s3BlobStore, error) {
+	client, err := newS3ClientFromEnv(bucket)
+	if err != nil {
+		return nil, err
+	}
+	/* キーとの結合を単純な連結で行えるよう、接頭辞の末尾を "/" にそろえる */
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &s3BlobStore{client: client, prefix: prefix}, nil
+}
+
+func (s *s3BlobStore) Put(key string, data []byte, contentType string) error {
+	if !validBlobKey(key) {
+		return fmt.Errorf("invalid blob key: %s", key)
+	}
+	return s.client.PutObject(s.prefix+key, data, contentType)
+}
+
+func (s *s3BlobStore) Get(key string) ([]byte, error) {
+	if !validBlobKey(key) {
+		return nil, fmt.Errorf("invalid blob key: %s", key)
+	}
+	data, err := s.client.GetObject(s.prefix+key)
+	if errors.Is(err, errObjectNotFound) {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+func (s *s3BlobStore) List(prefix string) ([]string, error) {
+	objects, err := s.client.ListObjects(s.prefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, strings.TrimPrefix(obj.Key, s.prefix))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *s3BlobStore) Delete(key string) error {
+	if !validBlobKey(key) {
+		return fmt.Errorf("invalid blob key: %s", key)
+	}
+	return s.client.DeleteObject(s.prefix+key)
+}
+
+/*
+blobs はアプリケーション全体で共有するBlobStore
+main関数の起動時に newBlobStoreFromEnv で初期化する
+*/
+var blobs BlobStore = newLocalBlobStore("blobs")
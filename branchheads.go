@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* branchHeadsTable はリポジトリごとのデフォルトブランチ・先頭のコミットと、孤立したコミットを保存するテーブル名 */
+	branchHeadsTable = "branch_heads"
+	/* maxBranchChanges は保持するブランチの変更の最大件数（古いものから削除） */
+	maxBranchChanges = 100
+)
+
+/* ブランチの変更の種類（BranchChange.Kind） */
+const (
+	branchChangeForcePush     = "force_push"     // 強制プッシュなどで、前回の先頭のコミットがブランチから外れた
+	branchChangeDefaultBranch = "default_branch" // デフォルトブランチが別のブランチに変わった
+)
+
+/*
+BranchHead はリポジトリのデフォルトブランチと、最後の同期で取得した先頭のコミット
+*/
+type BranchHead struct {
+	DefaultBranch string    `json:"default_branch"` // デフォルトブランチ名（例: "main"）
+	HeadSHA       string    `json:"head_sha"`       // 先頭のコミットのSHA（空のリポジトリの場合は空文字）
+	UpdatedAt     time.Time `json:"updated_at"`     // 最後に先頭のコミット・ブランチが変わった日時
+}
+
+/*
+BranchChange は検出した強制プッシュ・デフォルトブランチの変更
+*/
+type BranchChange struct {
+	Repo       string    `json:"repo"`        // リポジトリ名
+	Kind       string    `json:"kind"`        // 変更の種類（branchChange* 定数）
+	FromBranch string    `json:"from_branch"` // 変更前のデフォルトブランチ名
+	ToBranch   string    `json:"to_branch"`   // 変更後のデフォルトブランチ名
+	FromSHA    string    `json:"from_sha"`    // 変更前の先頭のコミットのSHA
+	ToSHA      string    `json:"to_sha"`      // 変更後の先頭のコミットのSHA
+	Orphaned   int       `json:"orphaned"`    // ブランチから外れ、孤立したコミットとして記録したコミット数
+	DetectedAt time.Time `json:"detected_at"` // 検出した日時
+}
+
+/*
+branchHeadStore はリポジトリごとのブランチの状態を保持するストア
+branch_headsテーブルに永続化される
+*/
+type branchHeadStore struct {
+	mu      sync.Mutex
+	Heads   map[string]BranchHead `json:"heads"`   // リポジトリ名ごとのブランチの状態
+	Recent  map[string][]string   `json:"recent"`  // リポジトリ名ごとの最後の同期で取得したコミットのSHA（新しい順、孤立したコミットの判定に使用）
+	Orphans map[string]time.Time  `json:"orphans"` // 孤立したコミットのarchiveKeyと検出した日時
+	Changes []BranchChange        `json:"changes"` // 検出した変更（新しい順）
+}
+
+/* branchHeads はアプリケーション全体で共有するブランチの状態のストア */
+var branchHeads = newBranchHeadStore()
+
+/* newBranchHeadStore は空のストアを作成する */
+func newBranchHeadStore() *branchHeadStore {
+	return &branchHeadStore{Heads: map[string]BranchHead{}, Recent: map[string][]string{}, Orphans: map[string]time.Time{}}
+}
+
+func init() {
+	registerTable(branchHeadsTable, loadBranchHeads)
+}
+
+/*
+loadBranchHeads はbranch_headsテーブルからストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadBranchHeads() error {
+	branchHeads.mu.Lock()
+	defer branchHeads.mu.Unlock()
+
+	branchHeads.Heads, branchHeads.Recent, branchHeads.Orphans, branchHeads.Changes = nil, nil, nil, nil
+	if err := loadTable(branchHeadsTable, branchHeads); err != nil {
+		return err
+	}
+	fresh := newBranchHeadStore()
+	if branchHeads.Heads == nil {
+		branchHeads.Heads = fresh.Heads
+	}
+	if branchHeads.Recent == nil {
+		branchHeads.Recent = fresh.Recent
+	}
+	if branchHeads.Orphans == nil {
+		branchHeads.Orphans = fresh.Orphans
+	}
+	return nil
+}
+
+/* save はストアをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *branchHeadStore) save() {
+	if err := saveTable(branchHeadsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save branch heads")
+	}
+}
+
+/*
+observe は同期で取得したコミット一覧を前回の先頭のコミットと比較し、強制プッシュ・デフォルトブランチの変更を検出する
+検出した場合は、ブランチから外れたコミットを孤立したコミットとして記録し、検索インデックス・集計のビューを作り直す
+（アーカイブのコミットは削除せず、孤立したコミットは以降の集計・検索から除き、アーカイブを返す際に orphaned を付ける）
+
+引数:
+  repo Repository - 同期したリポジトリ（デフォルトブランチの比較に使用）
+  commits []Commit - デフォルトブランチから取得したコミット（新しい順、最大100件）
+
+注意:
+  - 前回の先頭のコミットが取得した範囲（100件）にない場合のみ、GitHubのcompare APIで早送りか強制プッシュかを判定する
+  - compare APIの呼び出しに失敗した場合は状態を更新せず、次の同期で再び判定する
+*/
+func (s *branchHeadStore) observe(repo Repository, commits []Commit) {
+	current := make(map[string]bool, len(commits))
+	shas := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		current[commit.SHA] = true
+		shas = append(shas, commit.SHA)
+	}
+	head := ""
+	if len(shas) > 0 {
+		head = shas[0]
+	}
+
+	s.mu.Lock()
+	prev, known := s.Heads[repo.Name]
+	recent := s.Recent[repo.Name]
+	s.mu.Unlock()
+
+	branchChanged := known && prev.DefaultBranch != "" && repo.DefaultBranch != "" && prev.DefaultBranch != repo.DefaultBranch
+	if known && prev.HeadSHA == head && !branchChanged {
+		return
+	}
+
+	var orphaned []string
+	if known && prev.HeadSHA != "" && !current[prev.HeadSHA] {
+		mergeBase := ""
+		if !containsAnyString(recent, current) && len(commits) >= 100 {
+			/* 取得した範囲に共通のコミットがなく、範囲より前で分岐した（または早送りした）可能性がある */
+			status, base, err := compareCommits(repo.FullName, prev.HeadSHA, head)
+			if err != nil {
+				log.Warn().Err(err).Str("repository", repo.Name).Msg("Failed to compare branch heads")
+				return
+			}
+			if status == "ahead" || status == "identical" {
+				recent = nil
+			}
+			mergeBase = base
+		}
+		for _, sha := range recent {
+			if current[sha] || sha == mergeBase {
+				break
+			}
+			orphaned = append(orphaned, sha)
+		}
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	s.Heads[repo.Name] = BranchHead{DefaultBranch: repo.DefaultBranch, HeadSHA: head, UpdatedAt: now}
+	s.Recent[repo.Name] = shas
+	/* 取り消した強制プッシュ（元の先頭のコミットを再びプッシュした場合）で、ブランチに戻ったコミットは孤立から外す */
+	restored := 0
+	for sha := range current {
+		key := repo.Name + "/" + shortSHA(sha)
+		if _, ok := s.Orphans[key]; ok {
+			delete(s.Orphans, key)
+			restored++
+		}
+	}
+	for _, sha := range orphaned {
+		s.Orphans[repo.Name+"/"+shortSHA(sha)] = now
+	}
+	if len(orphaned) > 0 || branchChanged {
+		kind := branchChangeForcePush
+		if branchChanged {
+			kind = branchChangeDefaultBranch
+		}
+		change := BranchChange{
+			Repo:       repo.Name,
+			Kind:       kind,
+			FromBranch: prev.DefaultBranch,
+			ToBranch:   repo.DefaultBranch,
+			FromSHA:    prev.HeadSHA,
+			ToSHA:      head,
+			Orphaned:   len(orphaned),
+			DetectedAt: now,
+		}
+		s.Changes = append([]BranchChange{change}, s.Changes...)
+		if len(s.Changes) > maxBranchChanges {
+			s.Changes = s.Changes[:maxBranchChanges]
+		}
+		log.Warn().
+			Str("repository", repo.Name).
+			Str("kind", kind).
+			Str("from_sha", prev.HeadSHA).
+			Str("to_sha", head).
+			Int("orphaned", len(orphaned)).
+			Msg("Branch history rewritten")
+	}
+	s.save()
+	s.mu.Unlock()
+
+	if len(orphaned) > 0 || restored > 0 {
+		commitSearch.reset()
+		statsViews.reset()
+	}
+}
+
+/* withoutOrphans は孤立したコミットを除いたコミットを返す（集計・検索のビューをアーカイブから作る際に使用） */
+func (s *branchHeadStore) withoutOrphans(commits []CommitHistory) []CommitHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.Orphans) == 0 {
+		return commits
+	}
+	kept := make([]CommitHistory, 0, len(commits))
+	for _, commit := range commits {
+		if _, ok := s.Orphans[archiveKey(commit)]; !ok {
+			kept = append(kept, commit)
+		}
+	}
+	return kept
+}
+
+/* markOrphans は孤立したコミットにOrphanedを付ける（アーカイブのコミットを返す際に使用） */
+func (s *branchHeadStore) markOrphans(commits []CommitHistory) []CommitHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range commits {
+		if _, ok := s.Orphans[archiveKey(commits[i])]; ok {
+			commits[i].Orphaned = true
+		}
+	}
+	return commits
+}
+
+/* reset はすべてのブランチの状態と孤立したコミットを削除し、削除したリポジトリ数を返す（workspaceのデータの削除で使用） */
+func (s *branchHeadStore) reset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.Heads)
+	fresh := newBranchHeadStore()
+	s.Heads, s.Recent, s.Orphans, s.Changes = fresh.Heads, fresh.Recent, fresh.Orphans, nil
+	s.save()
+	return n
+}
+
+/* containsAnyString はvaluesのいずれかがsetに含まれるかどうかを返す */
+func containsAnyString(values []string, set map[string]bool) bool {
+	for _, v := range values {
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
+
+/* shortSHA はコミットハッシュをCommitHistoryと同じ7文字に短縮する */
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+/*
+compareCommits はGitHubのcompare APIで2つのコミットの関係を取得する
+API仕様: https://docs.github.com/ja/rest/commits/commits#compare-two-commits
+
+戻り値:
+  string - baseから見たheadの状態（"ahead"・"behind"・"diverged"・"identical"、baseがブランチから消えた場合は"missing"）
+  string - 共通の祖先のコミットのSHA（"missing"の場合は空文字）
+  error - リクエストに失敗した場合のエラー
+*/
+func compareCommits(repoFullName, base, head string) (string, string, error) {
+	url := fmt.Sprintf("%s/repos/%s/compare/%s...%s", githubAPIBase, repoFullName, base, head)
+	resp, err := githubGet(upstreamOpCommits, url, githubAcceptV3)
+	if err != nil {
+		captureUpstreamFailure(upstreamOpCommits, url, err)
+		return "", "", err
+	}
+	/* 強制プッシュで参照されなくなったコミットは、ガベージコレクション後に404を返す */
+	if resp.StatusCode == http.StatusNotFound {
+		return "missing", "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(resp.Body))
+		captureUpstreamFailure(upstreamOpCommits, url, err)
+		return "", "", err
+	}
+	var comparison struct {
+		Status          string `json:"status"`
+		MergeBaseCommit struct {
+			SHA string `json:"sha"`
+		} `json:"merge_base_commit"`
+	}
+	if err := json.Unmarshal(resp.Body, &comparison); err != nil {
+		return "", "", err
+	}
+	return comparison.Status, comparison.MergeBaseCommit.SHA, nil
+}
+
+/*
+BranchHeadsResponse は GET /api/admin/branches のレスポンス
+*/
+type BranchHeadsResponse struct {
+	Heads    map[string]BranchHead `json:"heads"`    // リポジトリ名ごとのデフォルトブランチと先頭のコミット
+	Changes  []BranchChange        `json:"changes"`  // 検出した強制プッシュ・デフォルトブランチの変更（新しい順、最大100件）
+	Orphaned []string              `json:"orphaned"` // 孤立したコミット（"<リポジトリ名>/<短縮SHA>"、名前順）
+}
+
+/*
+getBranchHeads はリポジトリごとのブランチの状態と、検出した変更を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, BranchHeadsResponse
+*/
+func getBranchHeads(c *gin.Context) {
+	branchHeads.mu.Lock()
+	resp := BranchHeadsResponse{
+		Heads:    make(map[string]BranchHead, len(branchHeads.Heads)),
+		Changes:  append([]BranchChange{}, branchHeads.Changes...),
+		Orphaned: make([]string, 0, len(branchHeads.Orphans)),
+	}
+	for name, head := range branchHeads.Heads {
+		resp.Heads[name] = head
+	}
+	for key := range branchHeads.Orphans {
+		resp.Orphaned = append(resp.Orphaned, key)
+	}
+	branchHeads.mu.Unlock()
+
+	sort.Strings(resp.Orphaned)
+	respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* 機能ごとのGitHub APIのリクエスト数の割り当て（レート制限の予算） */
+const (
+	budgetFeatureHistory  = "history"  // コミット履歴の画面（リポジトリ一覧・コミットの取得）
+	budgetFeatureStats    = "stats"    // ヘルススコアとリポジトリの詳細ページ（リポジトリの詳細・ブランチ・CIのチェック結果）
+	budgetFeatureActivity = "activity" // アクティビティ（PR・Issue・リリース・スター）
+	budgetFeatureProxy    = "proxy"    // /proxy/github による中継
+)
+
+/*
+budgetFeatures は操作の種類（upstreamOp* 定数）ごとの予算の機能
+ここにない操作（ヘルスチェックなど）は予算の対象外
+*/
+var budgetFeatures = map[string]string{
+	upstreamOpRepositories: budgetFeatureHistory,
+	upstreamOpCommits:      budgetFeatureHistory,
+	upstreamOpRepository:   budgetFeatureStats,
+	upstreamOpActivity:     budgetFeatureActivity,
+	upstreamOpProxy:        budgetFeatureProxy,
+}
+
+/*
+budgetShares は機能ごとに割り当てるレート制限の割合（%）
+環境変数 RATE_BUDGET_SHARES で変更可能（例: "history=60,stats=20,activity=10,proxy=10"、空文字の場合は予算を使用しない）
+*/
+var budgetShares = parseBudgetShares(getEnv("RATE_BUDGET_SHARES", "history=60,stats=20,activity=10,proxy=10"))
+
+/* parseBudgetShares は "機能=割合" のカンマ区切りのリストを読み込む（不正な項目は警告を出して無視する） */
+func parseBudgetShares(raw string) map[string]int {
+	shares := map[string]int{}
+	total := 0
+	for _, item := range splitList(raw) {
+		name, value, _ := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		share, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || share < 0 || !containsString(budgetFeatureNames(), name) {
+			log.Warn().Str("entry", item).Msg("Ignoring invalid RATE_BUDGET_SHARES entry")
+			continue
+		}
+		shares[name] = share
+		total += share
+	}
+	if total > 100 {
+		log.Warn().Int("total", total).Msg("RATE_BUDGET_SHARES add up to more than 100%")
+	}
+	return shares
+}
+
+/* budgetFeatureNames は予算の機能の一覧を返す（名前順） */
+func budgetFeatureNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, feature := range budgetFeatures {
+		if !seen[feature] {
+			seen[feature] = true
+			names = append(names, feature)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var rateBudgetDeniedTotal = newCounterVec(
+	"giter_rate_budget_denied_total",
+	"GitHub API requests held back because the feature exceeded its rate limit budget.",
+	"feature",
+)
+
+/*
+rateBudget はレート制限の期間（リセット時刻まで）ごとの機能別のリクエスト数
+*/
+var rateBudget = struct {
+	mu     sync.Mutex
+	reset  time.Time      // 集計中の期間のリセット時刻（X-RateLimit-Reset）
+	used   map[string]int // 機能ごとにGitHubに送信したリクエスト数
+	denied map[string]int // 機能ごとに予算を超えたため送信しなかったリクエスト数
+}{used: map[string]int{}, denied: map[string]int{}}
+
+/* rollBudgetWindow はリセット時刻が変わった場合に集計を初期化する（呼び出し元でmuをロックしていること） */
+func rollBudgetWindow(state RateLimitState) {
+	if !state.Reset.Equal(rateBudget.reset) {
+		rateBudget.reset = state.Reset
+		rateBudget.used = map[string]int{}
+		rateBudget.denied = map[string]int{}
+	}
+}
+
+/* budgetAllowance は機能に割り当てるリクエスト数を返す */
+func budgetAllowance(limit int, feature string) int {
+	return limit * budgetShares[feature] / 100
+}
+
+/*
+budgetAllows は操作の種類のリクエストをGitHubに送信してよいかを返す
+割り当て以内の場合は常に送信する。割り当てを超えた場合も、ほかの機能の未使用の割り当てを
+残り回数から差し引いてなお余裕がある場合は送信する（使われていない割り当ては借りられる、ソフトな予算）
+
+注意:
+  - レート制限の状態が未取得の場合・予算の対象外の操作の場合は常にtrue
+  - 送信した場合はbudgetRecordで記録すること
+*/
+func budgetAllows(op string) bool {
+	feature, ok := budgetFeatures[op]
+	state := currentRateLimit()
+	if !ok || len(budgetShares) == 0 || state.UpdatedAt.IsZero() {
+		return true
+	}
+
+	rateBudget.mu.Lock()
+	defer rateBudget.mu.Unlock()
+	rollBudgetWindow(state)
+
+	if rateBudget.used[feature] < budgetAllowance(state.Limit, feature) {
+		return true
+	}
+	reserved := 0
+	for other := range budgetShares {
+		if other == feature {
+			continue
+		}
+		if unused := budgetAllowance(state.Limit, other) - rateBudget.used[other]; unused > 0 {
+			reserved += unused
+		}
+	}
+	if state.Remaining > reserved {
+		return true
+	}
+	rateBudget.denied[feature]++
+	rateBudgetDeniedTotal.Inc(feature)
+	return false
+}
+
+/* budgetRecord はGitHubに送信したリクエストを機能のリクエスト数に加える（304はレート制限を消費しないため除く） */
+func budgetRecord(op string, status int) {
+	feature, ok := budgetFeatures[op]
+	if !ok || status == http.StatusNotModified {
+		return
+	}
+	rateBudget.mu.Lock()
+	defer rateBudget.mu.Unlock()
+	rollBudgetWindow(currentRateLimit())
+	rateBudget.used[feature]++
+}
+
+/* FeatureBudget は1つの機能の予算の状態 */
+type FeatureBudget struct {
+	Feature   string `json:"feature"`   // 機能（budgetFeature* 定数）
+	Share     int    `json:"share"`     // 割り当ての割合（%）
+	Allowance int    `json:"allowance"` // 今の期間の割り当て（リクエスト数）
+	Used      int    `json:"used"`      // 今の期間に送信したリクエスト数
+	Denied    int    `json:"denied"`    // 今の期間に予算を超えたため送信しなかったリクエスト数
+	Over      bool   `json:"over"`      // 割り当てを使い切った場合はtrue（ほかの機能の未使用分を借りている）
+}
+
+/* BudgetStatus は /api/admin/budget のレスポンス */
+type BudgetStatus struct {
+	Enabled   bool            `json:"enabled"`    // RATE_BUDGET_SHARESが設定されている場合はtrue
+	RateLimit RateLimitState  `json:"rate_limit"` // GitHub APIのレート制限の状態
+	Features  []FeatureBudget `json:"features"`   // 機能ごとの予算（名前順）
+}
+
+/* budgetStatus は予算の状態を返す */
+func budgetStatus() BudgetStatus {
+	state := currentRateLimit()
+	rateBudget.mu.Lock()
+	defer rateBudget.mu.Unlock()
+	rollBudgetWindow(state)
+
+	status := BudgetStatus{Enabled: len(budgetShares) > 0, RateLimit: state, Features: []FeatureBudget{}}
+	for _, feature := range budgetFeatureNames() {
+		allowance := budgetAllowance(state.Limit, feature)
+		status.Features = append(status.Features, FeatureBudget{
+			Feature:   feature,
+			Share:     budgetShares[feature],
+			Allowance: allowance,
+			Used:      rateBudget.used[feature],
+			Denied:    rateBudget.denied[feature],
+			Over:      rateBudget.used[feature] >= allowance,
+		})
+	}
+	return status
+}
+
+/*
+getBudget は機能ごとのレート制限の予算の状態を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, BudgetStatus
+*/
+func getBudget(c *gin.Context) {
+	respondJSON(c, http.StatusOK, budgetStatus())
+}
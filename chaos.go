@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+障害注入（カオス）モード
+GitHub APIへのリクエストに一定の確率で遅延・タイムアウト・5xx・壊れたJSONを注入し、
+再試行やサーキットブレーカー、一部のリポジトリを取得できない場合の部分的な結果などを手元で確認できるようにする
+開発用の機能のため、CHAOS_ENABLED=true でも GIN_MODE=release の場合は有効にしない
+*/
+
+/* 注入する障害の種類（CHAOS_FAULTS に指定する名前） */
+const (
+	chaosFaultLatency   = "latency"   // CHAOS_LATENCYだけ遅らせてから実際にリクエストする
+	chaosFaultTimeout   = "timeout"   // レスポンスを返さず、クライアントのタイムアウトまで待たせる
+	chaosFaultServer    = "5xx"       // 503 Service Unavailable を返す
+	chaosFaultMalformed = "malformed" // 200 OK で途中で切れたJSONを返す
+)
+
+/* chaosFaults はCHAOS_FAULTSを指定しない場合に注入する障害の種類 */
+var chaosFaults = []string{chaosFaultLatency, chaosFaultTimeout, chaosFaultServer, chaosFaultMalformed}
+
+/*
+ChaosConfig は障害注入の設定
+
+使用する環境変数:
+  CHAOS_ENABLED     - true で有効にする（GIN_MODE=release の場合は無視する）
+  CHAOS_PROBABILITY - リクエストごとに障害を注入する確率（0〜1、デフォルト: 0.1）
+  CHAOS_FAULTS      - 注入する障害の種類（カンマ区切り、デフォルト: latency,timeout,5xx,malformed）
+  CHAOS_LATENCY     - latencyで追加する遅延（デフォルト: 2s）
+  CHAOS_OPERATIONS  - 対象の操作の種類（カンマ区切り、例: "commits,activity"、デフォルト: すべて）
+*/
+type ChaosConfig struct {
+	Probability float64       // 障害を注入する確率
+	Faults      []string      // 注入する障害の種類（この中から等確率で選ぶ）
+	Latency     time.Duration // latencyで追加する遅延
+	Operations  []string      // 対象の操作の種類（空の場合はすべて）
+}
+
+/*
+chaosConfig は障害注入の設定（無効の場合はnil）
+ロガーの設定後にログを出力できるよう、最初のHTTPクライアントの作成時に読み込む
+*/
+var (
+	chaosConfig     *ChaosConfig
+	chaosConfigOnce sync.Once
+)
+
+/* loadChaosConfig は環境変数から障害注入の設定を読み込む（無効の場合はnil） */
+func loadChaosConfig() *ChaosConfig {
+	if !getEnvBool("CHAOS_ENABLED", false) {
+		return nil
+	}
+	if os.Getenv("GIN_MODE") == "release" {
+		log.Warn().Msg("CHAOS_ENABLED is ignored in release mode")
+		return nil
+	}
+
+	cfg := &ChaosConfig{
+		Probability: 0.1,
+		Faults:      chaosFaults,
+		Latency:     parseDurationEnv("CHAOS_LATENCY", 2*time.Second),
+		Operations:  splitList(getEnv("CHAOS_OPERATIONS", "")),
+	}
+	if value := getEnv("CHAOS_PROBABILITY", ""); value != "" {
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p < 0 || p > 1 {
+			log.Warn().Str("value", value).Msg("Invalid CHAOS_PROBABILITY, using default")
+		} else {
+			cfg.Probability = p
+		}
+	}
+	if faults := splitList(getEnv("CHAOS_FAULTS", "")); len(faults) > 0 {
+		cfg.Faults = nil
+		for _, fault := range faults {
+			if !containsString(chaosFaults, fault) {
+				log.Warn().Str("fault", fault).Msg("Unknown chaos fault, skipping")
+				continue
+			}
+			cfg.Faults = append(cfg.Faults, fault)
+		}
+	}
+	if len(cfg.Faults) == 0 {
+		log.Warn().Msg("No valid CHAOS_FAULTS, chaos mode disabled")
+		return nil
+	}
+
+	log.Warn().
+		Float64("probability", cfg.Probability).
+		Strs("faults", cfg.Faults).
+		Dur("latency", cfg.Latency).
+		Strs("operations", cfg.Operations).
+		Msg("Chaos mode enabled: faults will be injected into GitHub API requests")
+	return cfg
+}
+
+/* chaosInjectedTotal は注入した障害の件数 */
+var chaosInjectedTotal = newCounterVec(
+	"giter_chaos_faults_injected_total",
+	"Faults injected into GitHub API requests by chaos mode.",
+	"operation", "fault",
+)
+
+/*
+chaosTransport はリクエストに障害を注入するRoundTripper
+注入しないリクエストはそのままbaseに渡す
+*/
+type chaosTransport struct {
+	op   string            // 操作の種類
+	cfg  *ChaosConfig      // 障害注入の設定
+	base http.RoundTripper // 実際にリクエストを送信するTransport
+}
+
+/*
+withChaos は障害注入が有効で、操作の種類が対象の場合にTransportを chaosTransport で包む
+それ以外の場合はbaseをそのまま返す
+*/
+func withChaos(op string, base http.RoundTripper) http.RoundTripper {
+	chaosConfigOnce.Do(func() { chaosConfig = loadChaosConfig() })
+	if chaosConfig == nil || (len(chaosConfig.Operations) > 0 && !containsString(chaosConfig.Operations, op)) {
+		return base
+	}
+	return &chaosTransport{op: op, cfg: chaosConfig, base: base}
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rand.Float64() >= t.cfg.Probability {
+		return t.base.RoundTrip(req)
+	}
+	fault := t.cfg.Faults[rand.Intn(len(t.cfg.Faults))]
+	chaosInjectedTotal.Inc(t.op, fault)
+	log.Debug().Str("operation", t.op).Str("fault", fault).Str("url", req.URL.String()).Msg("Injecting chaos fault")
+
+	switch fault {
+	case chaosFaultLatency:
+		select {
+		case <-time.After(t.cfg.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return t.base.RoundTrip(req)
+	case chaosFaultTimeout:
+		/* http.Client.Timeout（GITHUB_TIMEOUT_*_OVERALL）でキャンセルされるまで待つ */
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	case chaosFaultServer:
+		return chaosResponse(req, http.StatusServiceUnavailable, `{"message":"Service Unavailable (injected by chaos mode)"}`), nil
+	default:
+		return chaosResponse(req, http.StatusOK, `[{"sha": "chaos", "commit": {"message": `), nil
+	}
+}
+
+/* chaosResponse はGitHub APIを模したJSONのレスポンスを作成する */
+func chaosResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
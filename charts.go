@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+サーバーでレンダリングするグラフ（/charts/*）
+JavaScriptのグラフを表示できないREADMEやメールに埋め込むため、画像（PNG・SVG）として返す
+*/
+
+const (
+	/* defaultChartRange はrangeを指定しない場合のグラフの期間 */
+	defaultChartRange = "90d"
+	/* maxChartDays はrangeに指定できる日数の上限 */
+	maxChartDays = 365
+)
+
+/* グラフの種類（styleパラメータ） */
+const (
+	chartStyleBar  = "bar"  // 日ごとの棒グラフ
+	chartStyleLine = "line" // 日ごとの折れ線（スパークライン）
+)
+
+var (
+	chartBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255} // 背景
+	chartGrid       = color.RGBA{R: 229, G: 231, B: 235, A: 255} // 目盛りの線（最大値の1/4ごと）
+	chartBaseline   = color.RGBA{R: 156, G: 163, B: 175, A: 255} // 0の線
+)
+
+/*
+parseChartRange はグラフの期間（"90d" のような日数、または "12w" のような週数）を日数に変換する
+
+戻り値:
+  int - 日数（1〜maxChartDays）
+  error - 形式が不正な場合・範囲外の場合のエラー
+*/
+func parseChartRange(raw string) (int, error) {
+	value, unit := raw, 1
+	switch {
+	case strings.HasSuffix(raw, "d"):
+		value = strings.TrimSuffix(raw, "d")
+	case strings.HasSuffix(raw, "w"):
+		value, unit = strings.TrimSuffix(raw, "w"), 7
+	default:
+		return 0, fmt.Errorf("invalid range: %s", raw)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n*unit > maxChartDays {
+		return 0, fmt.Errorf("invalid range: %s", raw)
+	}
+	return n * unit, nil
+}
+
+/*
+dailySeries はnowの日付までのdays日間の日ごとのコミット数を古い順に返す
+日付はstatsLocationで区切る（dailyCommitCountsを参照）
+*/
+func dailySeries(commits []CommitHistory, days int, now time.Time) []int {
+	counts := dailyCommitCounts(commits)
+	today := now.In(statsLocation)
+	series := make([]int, days)
+	for i := range series {
+		series[i] = counts[today.AddDate(0, 0, i-days+1).Format(statsDateFormat)]
+	}
+	return series
+}
+
+/* chartParams は /charts/activity.png のクエリパラメータ */
+type chartParams struct {
+	Range  string `form:"range"`                                    // 期間（例: "90d", "12w"）
+	Repo   string `form:"repo"`                                     // 対象のリポジトリ名（省略時はすべてのリポジトリ）
+	Style  string `form:"style" binding:"omitempty,oneof=bar line"` // グラフの種類
+	Width  int    `form:"width" binding:"min=100,max=2000"`         // 画像の幅（ピクセル）
+	Height int    `form:"height" binding:"min=40,max=1000"`         // 画像の高さ（ピクセル）
+}
+
+/* validateFields はrangeを検証する */
+func (p *chartParams) validateFields() []FieldError {
+	if _, err := parseChartRange(p.Range); err != nil {
+		return []FieldError{{Field: "range", Rule: "range", Message: fmt.Sprintf("must be a number of days or weeks up to %d days (e.g. 90d, 12w)", maxChartDays)}}
+	}
+	return nil
+}
+
+/*
+chartSeries はグラフのクエリパラメータに対応する日ごとのコミット数を取得する
+失敗した場合はエラーレスポンスを返し済みで、falseを返す
+*/
+func chartSeries(c *gin.Context, repo string, days int) ([]int, bool) {
+	commits, repos, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch commits for chart")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return nil, false
+	}
+	if repo != "" {
+		if !containsString(repositoryNames(repos), repo) {
+			respondError(c, http.StatusNotFound, errRepositoryNotFound.Error())
+			return nil, false
+		}
+		commits = commitsForRepository(commits, repo)
+	}
+	return dailySeries(commits, days, time.Now()), true
+}
+
+/*
+renderActivityPNG は日ごとのコミット数のグラフをPNGで描画する
+文字（目盛りの数値など）は描画せず、最大値の1/4ごとの目盛りの線のみ描く
+
+引数:
+  series []int - 日ごとのコミット数（古い順）
+  style string - chartStyleBar または chartStyleLine
+  width, height int - 画像の大きさ（ピクセル）
+*/
+func renderActivityPNG(series []int, style string, width, height int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: chartBackground}, image.Point{}, draw.Src)
+
+	const padding = 4
+	plot := image.Rect(padding, padding, width-padding, height-padding)
+	peak := 1
+	for _, n := range series {
+		if n > peak {
+			peak = n
+		}
+	}
+	/* yはコミット数nの高さのピクセル位置（上が0） */
+	y := func(n int) int {
+		return plot.Max.Y - 1 - (plot.Dy()-1)*n/peak
+	}
+
+	for i := 1; i <= 4; i++ {
+		gy := plot.Max.Y - 1 - (plot.Dy()-1)*i/4
+		draw.Draw(img, image.Rect(plot.Min.X, gy, plot.Max.X, gy+1), &image.Uniform{C: chartGrid}, image.Point{}, draw.Src)
+	}
+
+	fill := themeColorRGBA()
+	slot := float64(plot.Dx()) / float64(len(series))
+	switch style {
+	case chartStyleLine:
+		prevX, prevY := 0, 0
+		for i, n := range series {
+			x := plot.Min.X + int(slot*(float64(i)+0.5))
+			if i > 0 {
+				drawLine(img, prevX, prevY, x, y(n), fill)
+			}
+			prevX, prevY = x, y(n)
+		}
+	default:
+		gap := 0
+		if slot >= 4 {
+			gap = 1
+		}
+		for i, n := range series {
+			if n == 0 {
+				continue
+			}
+			x0 := plot.Min.X + int(slot*float64(i))
+			x1 := plot.Min.X + int(slot*float64(i+1)) - gap
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			draw.Draw(img, image.Rect(x0, y(n), x1, plot.Max.Y), &image.Uniform{C: fill}, image.Point{}, draw.Src)
+		}
+	}
+	draw.Draw(img, image.Rect(plot.Min.X, plot.Max.Y-1, plot.Max.X, plot.Max.Y), &image.Uniform{C: chartBaseline}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/* drawLine は(x0, y0)から(x1, y1)まで太さ2ピクセルの線を描く（ブレゼンハムのアルゴリズム） */
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.SetRGBA(x0, y0, c)
+		img.SetRGBA(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+/* abs は整数の絶対値を返す */
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+/*
+getActivityChartPNG は日ごとのコミット数のグラフをPNGで返すハンドラー
+
+クエリパラメータ:
+  range string - 期間（"90d" のような日数または "12w" のような週数、最大365日、デフォルト: 90d）
+  repo string - 対象のリポジトリ名（省略時はすべてのリポジトリ）
+  style string - "bar"（棒グラフ、デフォルト）または "line"（折れ線）
+  width, height int - 画像の大きさ（デフォルト: 600x160）
+
+レスポンス:
+  成功時: 200 OK, image/png（15分キャッシュ）
+  失敗時: 422 Unprocessable Entity（不正なクエリパラメータ）, 404 Not Found（存在しないリポジトリ）, 502 Bad Gateway
+*/
+func getActivityChartPNG(c *gin.Context) {
+	params := chartParams{Range: defaultChartRange, Style: chartStyleBar, Width: 600, Height: 160}
+	if !bindQuery(c, &params) {
+		return
+	}
+	/* validateFieldsで読み込めることを確認済み */
+	days, _ := parseChartRange(params.Range)
+
+	series, ok := chartSeries(c, params.Repo, days)
+	if !ok {
+		return
+	}
+	data, err := renderActivityPNG(series, params.Style, params.Width, params.Height)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+/* sparklineParams は /charts/sparkline.svg のクエリパラメータ */
+type sparklineParams struct {
+	Days   int    `form:"days" binding:"min=1,max=365"`    // 期間（日数）
+	Repo   string `form:"repo"`                            // 対象のリポジトリ名（省略時はすべてのリポジトリ）
+	Width  int    `form:"width" binding:"min=20,max=1000"` // 画像の幅（ピクセル）
+	Height int    `form:"height" binding:"min=10,max=200"` // 画像の高さ（ピクセル）
+}
+
+/*
+renderSparklineSVG は日ごとのコミット数のスパークラインをSVGで描画する
+折れ線の下を薄く塗り、最終日（今日）の点を強調する
+*/
+func renderSparklineSVG(series []int, width, height int) []byte {
+	const padding = 2.0
+	peak := 1
+	for _, n := range series {
+		if n > peak {
+			peak = n
+		}
+	}
+	step := 0.0
+	if len(series) > 1 {
+		step = (float64(width) - 2*padding) / float64(len(series)-1)
+	}
+	bottom := float64(height) - padding
+	points := make([]string, len(series))
+	var lastX, lastY float64
+	for i, n := range series {
+		lastX = padding + step*float64(i)
+		lastY = bottom - (float64(height)-2*padding)*float64(n)/float64(peak)
+		points[i] = fmt.Sprintf("%.1f,%.1f", lastX, lastY)
+	}
+
+	stroke := themeColor
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%d commits in the last %d days">`,
+		width, height, width, height, sumInts(series), len(series))
+	fmt.Fprintf(&b, `<polygon points="%.1f,%.1f %s %.1f,%.1f" fill="%s" fill-opacity="0.15"/>`, padding, bottom, strings.Join(points, " "), lastX, bottom, stroke)
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round" stroke-linecap="round"/>`, strings.Join(points, " "), stroke)
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2" fill="%s"/>`, lastX, lastY, stroke)
+	b.WriteString("</svg>")
+	return []byte(b.String())
+}
+
+/* sumInts は整数の合計を返す */
+func sumInts(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+/*
+getSparklineSVG は日ごとのコミット数のスパークラインをSVGで返すハンドラー
+PNGのグラフより軽く、プロフィールのREADMEなどに小さく埋め込むのに向く
+
+クエリパラメータ:
+  days int - 期間（1〜365日、デフォルト: 30）
+  repo string - 対象のリポジトリ名（省略時はすべてのリポジトリ）
+  width, height int - 画像の大きさ（デフォルト: 120x24）
+
+レスポンス:
+  成功時: 200 OK, image/svg+xml（15分キャッシュ、ETag付き）、If-None-Matchが一致する場合は304 Not Modified
+  失敗時: 422 Unprocessable Entity（不正なクエリパラメータ）, 404 Not Found（存在しないリポジトリ）, 502 Bad Gateway
+*/
+func getSparklineSVG(c *gin.Context) {
+	params := sparklineParams{Days: 30, Width: 120, Height: 24}
+	if !bindQuery(c, &params) {
+		return
+	}
+	series, ok := chartSeries(c, params.Repo, params.Days)
+	if !ok {
+		return
+	}
+
+	data := renderSparklineSVG(series, params.Width, params.Height)
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "image/svg+xml", data)
+}
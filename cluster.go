@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+clusterHeartbeatInterval はインスタンスの生存情報を記録する間隔
+環境変数 CLUSTER_HEARTBEAT_INTERVAL で変更可能（デフォルト: 10秒）
+生存情報はこの3倍の期間、記録が途絶えると失効する
+*/
+var clusterHeartbeatInterval = parseDurationEnv("CLUSTER_HEARTBEAT_INTERVAL", 10*time.Second)
+
+/* processStartedAt はこのプロセスの起動日時 */
+var processStartedAt = time.Now().UTC()
+
+/*
+PeerHeartbeat はインスタンスの生存情報
+ロックのバックエンド（LOCK_BACKEND）に記録し、同じバックエンドを共有するインスタンスを把握する
+*/
+type PeerHeartbeat struct {
+	Owner     string    `json:"owner"`      // インスタンスの識別子（lockOwner）
+	Hostname  string    `json:"hostname"`   // ホスト名
+	PID       int       `json:"pid"`        // プロセスID
+	Version   string    `json:"version"`    // ビルドのバージョン
+	StartedAt time.Time `json:"started_at"` // 起動日時
+	BeatAt    time.Time `json:"beat_at"`    // 最後に生存情報を記録した日時
+}
+
+/* ClusterLock はロックごとの保持状況 */
+type ClusterLock struct {
+	Name       string     `json:"name"`         // ロック名
+	Holder     string     `json:"holder"`       // 保持しているインスタンス（保持されていない場合は空文字）
+	ExpiresAt  *time.Time `json:"expires_at"`   // 有効期限（保持されていない場合はnull）
+	HeldBySelf bool       `json:"held_by_self"` // このインスタンスが保持している場合はtrue
+}
+
+/* ClusterStatus は /api/admin/cluster のレスポンス */
+type ClusterStatus struct {
+	Backend   string          `json:"backend"`   // ロックのバックエンド（local / redis）
+	Self      string          `json:"self"`      // このインスタンスの識別子
+	Instances int             `json:"instances"` // 生存情報が有効なインスタンス数
+	Duplicate bool            `json:"duplicate"` // 同じホスト名の別のインスタンスがある場合はtrue
+	Locks     []ClusterLock   `json:"locks"`     // ロックごとの保持状況
+	Peers     []PeerHeartbeat `json:"peers"`     // 生存情報（起動日時の古い順）
+}
+
+/* knownPeers は前回の生存情報の記録で見つかったインスタンス（新しく加わった・消えたインスタンスをログに出力する） */
+var knownPeers = struct {
+	mu     sync.Mutex
+	owners map[string]bool
+}{owners: map[string]bool{}}
+
+/* selfHeartbeat はこのインスタンスの生存情報を作成する */
+func selfHeartbeat() PeerHeartbeat {
+	return PeerHeartbeat{
+		Owner:     lockOwner,
+		Hostname:  hostname(),
+		PID:       os.Getpid(),
+		Version:   version,
+		StartedAt: processStartedAt,
+		BeatAt:    time.Now().UTC(),
+	}
+}
+
+/*
+startClusterHeartbeat はclusterHeartbeatIntervalごとにこのインスタンスの生存情報を記録するゴルーチンを起動する
+間隔が0の場合は何もしない
+*/
+func startClusterHeartbeat() {
+	if clusterHeartbeatInterval <= 0 {
+		return
+	}
+	beat()
+	go func() {
+		ticker := time.NewTicker(clusterHeartbeatInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			beat()
+		}
+	}()
+}
+
+/*
+beat は生存情報を記録し、ほかのインスタンスの増減をログに出力する
+同じホスト名のインスタンスが見つかった場合は、誤って重複して起動した可能性があるため警告する
+*/
+func beat() {
+	if err := locker.Heartbeat(selfHeartbeat(), 3*clusterHeartbeatInterval); err != nil {
+		log.Warn().Err(err).Msg("Failed to record heartbeat")
+		return
+	}
+	peers, err := locker.Peers()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list peer heartbeats")
+		return
+	}
+
+	knownPeers.mu.Lock()
+	defer knownPeers.mu.Unlock()
+	current := map[string]bool{}
+	for _, peer := range peers {
+		current[peer.Owner] = true
+		if peer.Owner != lockOwner && !knownPeers.owners[peer.Owner] {
+			event := log.Info()
+			if peer.Hostname == hostname() {
+				event = log.Warn()
+			}
+			event.Str("peer", peer.Owner).Str("hostname", peer.Hostname).Int("pid", peer.PID).Msg("Peer instance joined")
+		}
+	}
+	for owner := range knownPeers.owners {
+		if !current[owner] {
+			log.Info().Str("peer", owner).Msg("Peer instance left")
+		}
+	}
+	knownPeers.owners = current
+}
+
+/* hasDuplicate は同じホスト名で別のインスタンスの生存情報があるかどうかを返す */
+func hasDuplicate(peers []PeerHeartbeat) bool {
+	for _, peer := range peers {
+		if peer.Owner != lockOwner && peer.Hostname == hostname() {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+getCluster はロックの保持状況とインスタンスの生存情報を返す管理者APIハンドラー
+どのインスタンスが定期ジョブ（バックフィル・スナップショットなど）を実行中かと、同じロックのバックエンドを共有するインスタンスを確認する
+
+レスポンス:
+  成功時: 200 OK, ClusterStatus
+  失敗時: 502 Bad Gateway（ロックのバックエンドに接続できない）
+
+注意:
+  - LOCK_BACKEND=local の場合、ロックと生存情報はプロセス内のみのため、ほかのインスタンスは表示されない
+*/
+func getCluster(c *gin.Context) {
+	status := ClusterStatus{
+		Backend: getEnv("LOCK_BACKEND", "local"),
+		Self:    lockOwner,
+		Locks:   []ClusterLock{},
+	}
+	for _, name := range lockNames {
+		lease, ok, err := locker.Holder(name)
+		if err != nil {
+			log.Error().Err(err).Str("lock", name).Msg("Failed to read lock holder")
+			respondError(c, http.StatusBadGateway, err.Error())
+			return
+		}
+		lock := ClusterLock{Name: name}
+		if ok {
+			lock.Holder = lease.Owner
+			lock.ExpiresAt = &lease.ExpiresAt
+			lock.HeldBySelf = lease.Owner == lockOwner
+		}
+		status.Locks = append(status.Locks, lock)
+	}
+
+	peers, err := locker.Peers()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list peer heartbeats")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if peers == nil {
+		peers = []PeerHeartbeat{}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].StartedAt.Before(peers[j].StartedAt) })
+	status.Peers = peers
+	status.Instances = len(peers)
+	status.Duplicate = hasDuplicate(peers)
+	respondJSON(c, http.StatusOK, status)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+/*
+runCommand はサーバーを起動せずに実行するサブコマンドを処理する
+例:
+  ./giter backup            - バックアップを即座に作成
+  ./giter restore latest    - 最新のバックアップから復元
+  ./giter restore <名前>     - 指定したバックアップから復元
+  ./giter list-backups      - バックアップの一覧を表示
+  ./giter backfill          - 全リポジトリの全コミットを取得してアーカイブに保存
+  ./giter export-site --out ./dist - ページとJSONを静的サイトとして書き出す
+  ./giter --migrate-only    - HistoryStoreのマイグレーションのみを適用
+  ./giter --rollback [番号]  - HistoryStoreのマイグレーションを戻す
+
+引数:
+  args []string - コマンドライン引数（プログラム名を除く）
+
+戻り値:
+  int - プロセスの終了コード（0: 成功、1: 失敗、2: 使い方の誤り）
+*/
+func runCommand(args []string) int {
+	switch args[0] {
+	case "backup", "restore", "list-backups":
+		return runBackupCommand(args)
+	case "backfill":
+		return runBackfillCommand()
+	case "export-site":
+		return runExportSiteCommand(args[1:])
+	case "--migrate-only":
+		return runMigrateCommand()
+	case "--rollback":
+		return runRollbackCommand(args[1:])
+	default:
+		printUsage()
+		return 2
+	}
+}
+
+/* runBackupCommand はバックアップ関連のサブコマンドを実行する */
+func runBackupCommand(args []string) int {
+	cfg, err := loadBackupConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	storage, err := newBackupStorage(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "backup":
+		name, err := createBackup(storage, cfg.Retention)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(name)
+
+	case "restore":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: giter restore <backup-name|latest>")
+			return 2
+		}
+		meta, err := restoreBackup(storage, args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("restored tables: %v (exported at %s)\n", meta.Tables, meta.ExportedAt.Format("2006-01-02 15:04:05"))
+
+	case "list-backups":
+		names, err := listBackups(storage)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	}
+	return 0
+}
+
+/*
+runBackfillCommand はバックフィルを実行し、リポジトリごとの結果を表示する
+中断した場合や失敗したリポジトリがある場合は、再度実行するとチェックポイントから再開する
+*/
+func runBackfillCommand() int {
+	status, err := runBackfill()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	names := make([]string, 0, len(status.Repositories))
+	for name := range status.Repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cp := status.Repositories[name]
+		if cp.Error != "" {
+			fmt.Printf("%s: %d commits (failed at page %d: %s)\n", name, cp.Commits, cp.NextPage, cp.Error)
+			continue
+		}
+		fmt.Printf("%s: %d commits\n", name, cp.Commits)
+	}
+	if status.FinishedAt == nil {
+		fmt.Fprintln(os.Stderr, "backfill incomplete; run again to resume")
+		return 1
+	}
+	return 0
+}
+
+/* printUsage はサブコマンドの使い方を標準エラー出力に表示する */
+func printUsage() {
+	fmt.Fprintln(os.Stderr, `usage: giter [command]
+
+引数なしで起動するとWebサーバーを起動します
+
+commands:
+  backup                         バックアップを作成
+  restore <backup-name|latest>   バックアップから復元
+  list-backups                   バックアップの一覧を表示
+  backfill                       全リポジトリの全コミットを取得してアーカイブに保存
+  export-site [--out <dir>]      ページとJSONを静的サイトとして書き出す（デフォルト: ./dist）
+  --migrate-only                 HistoryStoreのマイグレーションのみを適用
+  --rollback [version]           HistoryStoreのマイグレーションを指定したバージョンまで戻す（省略時: 1つ戻す）`)
+}
+
+/* isMigrationCommand はマイグレーションを適用・戻すサブコマンドかどうかを返す（起動時の自動適用を行わない） */
+func isMigrationCommand(arg string) bool {
+	return arg == "--migrate-only" || arg == "--rollback"
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+コミットメッセージの全文検索
+同期（/api/git-history と定期ジョブ）で取得したコミットを転置インデックスに追加し、
+フレーズ（"..."）・前方一致（語*）の検索と、BM25によるスコアの高い順の並び替えを行う
+アーカイブ（HistoryStore）のコミットは最初の検索の際にまとめて追加する
+*/
+
+/* BM25のパラメーター（一般的な値） */
+const (
+	bm25K1 = 1.2  // 語の出現回数による飽和の度合い
+	bm25B  = 0.75 // 文書の長さによる補正の度合い
+)
+
+/* commitPosting は語を含むコミットと、メッセージの中の語の位置 */
+type commitPosting struct {
+	doc       int   // commitSearchIndex.docsの添字
+	positions []int // 語の位置（searchTokensの何番目か、フレーズの照合に使用する）
+}
+
+/* commitDoc はインデックスに追加したコミット */
+type commitDoc struct {
+	commit CommitHistory
+	length int // メッセージの語数
+}
+
+/*
+commitSearchIndex はコミットメッセージの転置インデックス
+追加のみ行い、アーカイブの削除（保持期間・データの削除）の際はresetで作り直す
+*/
+type commitSearchIndex struct {
+	mu          sync.RWMutex
+	seeded      bool                       // アーカイブのコミットを追加済みかどうか
+	docs        []commitDoc                // 追加したコミット
+	keys        map[string]bool            // 追加したコミットのarchiveKey（重複の追加を防ぐ）
+	postings    map[string][]commitPosting // 語ごとの出現箇所（docの昇順）
+	terms       []string                   // 語のソート済みの一覧（前方一致の検索に使用）
+	totalLength int                        // 全コミットの語数の合計（平均の長さの計算に使用）
+}
+
+/* commitSearch はアプリケーション全体で共有するコミットメッセージの検索インデックス */
+var commitSearch = newCommitSearchIndex()
+
+/* newCommitSearchIndex は空のインデックスを作成する */
+func newCommitSearchIndex() *commitSearchIndex {
+	return &commitSearchIndex{keys: map[string]bool{}, postings: map[string][]commitPosting{}}
+}
+
+/*
+add はまだ追加していないコミットをインデックスに追加する（同期のたびに呼び出す）
+REDACTION_RULESでマスクする部分で検索できないよう、マスクした後のメッセージから語を取り出す
+
+戻り値:
+  int - 追加したコミット数
+*/
+func (idx *commitSearchIndex) add(commits []CommitHistory) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	added, newTerms := 0, false
+	for _, commit := range commits {
+		key := archiveKey(commit)
+		if idx.keys[key] {
+			continue
+		}
+		idx.keys[key] = true
+
+		doc := len(idx.docs)
+		tokens := searchTokens(redactionOn.message(commit.CommitMessage))
+		positions := map[string][]int{}
+		for i, token := range tokens {
+			positions[token] = append(positions[token], i)
+		}
+		for token, pos := range positions {
+			if _, ok := idx.postings[token]; !ok {
+				newTerms = true
+			}
+			idx.postings[token] = append(idx.postings[token], commitPosting{doc: doc, positions: pos})
+		}
+		idx.docs = append(idx.docs, commitDoc{commit: commit, length: len(tokens)})
+		idx.totalLength += len(tokens)
+		added++
+	}
+	if newTerms {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+	return added
+}
+
+/* reset はインデックスを空にし、次の検索でアーカイブから作り直す（アーカイブのコミットを削除した場合に使用） */
+func (idx *commitSearchIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.seeded = false
+	idx.docs = nil
+	idx.keys = map[string]bool{}
+	idx.postings = map[string][]commitPosting{}
+	idx.terms = nil
+	idx.totalLength = 0
+}
+
+/* seed はアーカイブのコミットをまだ追加していなければ追加する */
+func (idx *commitSearchIndex) seed() error {
+	idx.mu.RLock()
+	seeded := idx.seeded
+	idx.mu.RUnlock()
+	if seeded {
+		return nil
+	}
+
+	archived, err := history.LoadAll()
+	if err != nil {
+		return err
+	}
+	added := idx.add(branchHeads.withoutOrphans(archived))
+	idx.mu.Lock()
+	idx.seeded = true
+	idx.mu.Unlock()
+	log.Info().Int("added", added).Msg("Commit search index seeded from archive")
+	return nil
+}
+
+/*
+commitSearchClause は検索語の1つの条件
+空白で区切った部分ごとに作り、すべての条件に一致したコミットを返す
+*/
+type commitSearchClause struct {
+	tokens []string // 連続して出現する必要のある語（1語の場合は通常の語）
+	prefix bool     // trueの場合は tokens[0] で始まる語に一致する（"語*" の形式）
+}
+
+/*
+parseCommitSearchQuery は検索語を条件に分割する
+  - "fix login bug": 3つの語をすべて含む
+  - "\"login bug\"": "login" の直後に "bug" が続く（フレーズ）
+  - "auth*": "auth" で始まる語（auth, authentication など）を含む
+  - "my-project" や日本語の "履歴表示" のように複数の語に分かれる部分は、フレーズとして扱う
+*/
+func parseCommitSearchQuery(query string) []commitSearchClause {
+	clauses := []commitSearchClause{}
+	for len(query) > 0 {
+		query = strings.TrimLeft(query, " \t　")
+		if query == "" {
+			break
+		}
+		var part string
+		if query[0] == '"' {
+			end := strings.IndexByte(query[1:], '"')
+			if end < 0 {
+				part, query = query[1:], ""
+			} else {
+				part, query = query[1:end+1], query[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(query, " \t　")
+			if end < 0 {
+				end = len(query)
+			}
+			part, query = query[:end], query[end:]
+			if strings.HasSuffix(part, "*") {
+				if tokens := searchTokens(strings.TrimSuffix(part, "*")); len(tokens) == 1 {
+					clauses = append(clauses, commitSearchClause{tokens: tokens, prefix: true})
+					continue
+				}
+			}
+		}
+		if tokens := searchTokens(part); len(tokens) > 0 {
+			clauses = append(clauses, commitSearchClause{tokens: tokens})
+		}
+	}
+	return clauses
+}
+
+/* CommitSearchResult は検索結果の1件 */
+type CommitSearchResult struct {
+	CommitHistory
+	Score float64 `json:"score"` // BM25のスコア（大きいほど関連が高い）
+}
+
+/*
+search は条件にすべて一致するコミットをBM25のスコアの高い順に返す
+同じスコアの場合は新しいコミットを優先する
+
+引数:
+  clauses []commitSearchClause - parseCommitSearchQueryで作成した条件
+  include func(CommitHistory) bool - 結果に含めるコミット（閲覧者のデータの範囲・リポジトリの絞り込み）
+*/
+func (idx *commitSearchIndex) search(clauses []commitSearchClause, include func(CommitHistory) bool) []CommitSearchResult {
+	if len(clauses) == 0 {
+		return []CommitSearchResult{}
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(idx.docs) == 0 {
+		return []CommitSearchResult{}
+	}
+	avgLength := float64(idx.totalLength) / float64(len(idx.docs))
+
+	var scores map[int]float64
+	for _, clause := range clauses {
+		clauseScores := idx.matchClause(clause, avgLength)
+		if scores == nil {
+			scores = clauseScores
+			continue
+		}
+		/* すべての条件に一致したコミットのみ残す */
+		for doc, score := range scores {
+			if s, ok := clauseScores[doc]; ok {
+				scores[doc] = score + s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	results := []CommitSearchResult{}
+	for doc, score := range scores {
+		commit := idx.docs[doc].commit
+		if include(commit) {
+			results = append(results, CommitSearchResult{CommitHistory: commit, Score: math.Round(score*1000) / 1000})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return commitBefore(results[i].CommitHistory, results[j].CommitHistory)
+	})
+	return results
+}
+
+/*
+matchClause は1つの条件に一致するコミットとBM25のスコアを返す（呼び出し元でmuをロックしていること）
+前方一致の場合は一致した語ごと、フレーズの場合は各語のスコアを合計する
+*/
+func (idx *commitSearchIndex) matchClause(clause commitSearchClause, avgLength float64) map[int]float64 {
+	scores := map[int]float64{}
+	if clause.prefix {
+		prefix := clause.tokens[0]
+		for i := sort.SearchStrings(idx.terms, prefix); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], prefix); i++ {
+			for doc, score := range idx.termScores(idx.terms[i], avgLength) {
+				scores[doc] += score
+			}
+		}
+		return scores
+	}
+
+	first := idx.postings[clause.tokens[0]]
+	for _, posting := range first {
+		if len(clause.tokens) > 1 && !idx.phraseAt(posting, clause.tokens[1:]) {
+			continue
+		}
+		scores[posting.doc] = 0
+	}
+	for _, token := range clause.tokens {
+		termScores := idx.termScores(token, avgLength)
+		for doc := range scores {
+			scores[doc] += termScores[doc]
+		}
+	}
+	return scores
+}
+
+/* termScores は語を含むコミットごとのBM25のスコアを返す（呼び出し元でmuをロックしていること） */
+func (idx *commitSearchIndex) termScores(term string, avgLength float64) map[int]float64 {
+	postings := idx.postings[term]
+	n, df := float64(len(idx.docs)), float64(len(postings))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	scores := make(map[int]float64, len(postings))
+	for _, posting := range postings {
+		tf := float64(len(posting.positions))
+		length := float64(idx.docs[posting.doc].length)
+		scores[posting.doc] = idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgLength))
+	}
+	return scores
+}
+
+/* phraseAt はpostingの語の直後にrestの語が順に続く位置があるかどうかを返す（呼び出し元でmuをロックしていること） */
+func (idx *commitSearchIndex) phraseAt(posting commitPosting, rest []string) bool {
+	positions := make([]map[int]bool, len(rest))
+	for i, token := range rest {
+		positions[i] = map[int]bool{}
+		postings := idx.postings[token]
+		/* 出現箇所はdocの昇順に追加しているため二分探索できる */
+		j := sort.Search(len(postings), func(j int) bool { return postings[j].doc >= posting.doc })
+		if j == len(postings) || postings[j].doc != posting.doc {
+			return false
+		}
+		for _, pos := range postings[j].positions {
+			positions[i][pos] = true
+		}
+	}
+	for _, start := range posting.positions {
+		matched := true
+		for i := range rest {
+			if !positions[i][start+i+1] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+/* CommitSearchResponse は /api/search のレスポンス */
+type CommitSearchResponse struct {
+	Query   string               `json:"query"`    // 検索語
+	Total   int                  `json:"total"`    // 一致したコミット数
+	Page    int                  `json:"page"`     // ページ番号
+	PerPage int                  `json:"per_page"` // 1ページあたりの件数
+	Results []CommitSearchResult `json:"results"`  // スコアの高い順のコミット
+}
+
+/* commitSearchParams は /api/search のクエリパラメータ */
+type commitSearchParams struct {
+	Q       string `form:"q" binding:"required,max=200"`
+	Repo    string `form:"repo"`
+	Page    int    `form:"page" binding:"min=1"`
+	PerPage int    `form:"per_page" binding:"min=1"`
+}
+
+/* validateFields はper_pageの上限とページの深さを検証する */
+func (p *commitSearchParams) validateFields() []FieldError {
+	return paginationFieldErrors(p.Page, p.PerPage, "refine the search terms or filter by repo instead")
+}
+
+/*
+getCommitSearch はコミットメッセージを全文検索するAPIハンドラー
+インデックスは同期で取得したコミットとアーカイブのコミットを対象にする（GitHubへのリクエストはリポジトリ一覧のみ）
+
+クエリパラメータ:
+  q string - 検索語（必須、parseCommitSearchQueryを参照）
+  repo string - リポジトリ名で絞り込む
+  page, per_page int - ページ番号と1ページあたりの件数（デフォルト: 1, 30、per_pageはMAX_PER_PAGEまで）
+
+レスポンス:
+  成功時: 200 OK, CommitSearchResponse（Link・X-Total-Countヘッダーにページのリンクと全件数を設定する）
+  失敗時: 422 Unprocessable Entity（q未指定・per_pageが上限を超える・MAX_PAGINATION_DEPTHを超えるページ）, 500 Internal Server Error（アーカイブの読み込みに失敗）, 502 Bad Gateway
+
+注意:
+  - PRIVACY_MODE=anonymous の匿名の閲覧者にはプライベートリポジトリのコミットを含めない
+  - メッセージはレスポンスに返す段階でREDACTION_RULESを適用する
+*/
+func getCommitSearch(c *gin.Context) {
+	query := commitSearchParams{Page: 1, PerPage: defaultPerPage}
+	if !bindQuery(c, &query) {
+		return
+	}
+	repos, err := fetchRepositories()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for commit search")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err := commitSearch.seed(); err != nil {
+		log.Error().Err(err).Msg("Failed to seed commit search index")
+		respondError(c, http.StatusInternalServerError, "failed to load archived commits")
+		return
+	}
+
+	v := requestVisibility(c)
+	private := map[string]bool{}
+	for _, repo := range repos {
+		private[repo.Name] = repo.Private
+	}
+	results := commitSearch.search(parseCommitSearchQuery(query.Q), func(commit CommitHistory) bool {
+		return v.includes(commit.RepositoryName, private[commit.RepositoryName]) && (query.Repo == "" || commit.RepositoryName == query.Repo)
+	})
+
+	total := len(results)
+	start := (query.Page - 1) * query.PerPage
+	if start > total {
+		start = total
+	}
+	end := start + query.PerPage
+	if end > total {
+		end = total
+	}
+	page := results[start:end]
+	redact := requestRedaction(c)
+	for i := range page {
+		page[i].CommitMessage = redact.message(page[i].CommitMessage)
+	}
+	setPageLinks(c, query.Page, query.PerPage, total)
+	respondJSON(c, http.StatusOK, CommitSearchResponse{Query: query.Q, Total: total, Page: query.Page, PerPage: query.PerPage, Results: page})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+getEnv は環境変数の値を返す
+環境変数が未設定または空文字の場合はデフォルト値を返す
+
+引数:
+  key string - 環境変数名
+  defaultValue string - 未設定時に返すデフォルト値
+
+戻り値:
+  string - 環境変数の値またはデフォルト値
+*/
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+/*
+getEnvInt は環境変数の値を整数として返す
+未設定または整数として解釈できない場合はデフォルト値を返す
+*/
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+/*
+parseDurationEnv は環境変数の値を time.Duration として返す（例: "30s", "5m"）
+未設定または解釈できない場合はデフォルト値を返す
+*/
+func parseDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+/*
+getEnvBool は環境変数の値を真偽値として返す（"true", "1", "false", "0" など strconv.ParseBool の形式）
+未設定または解釈できない場合はデフォルト値を返す
+*/
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+Content-Security-Policy
+リクエストごとにnonceを生成し、nonceを付けたインラインスクリプトのみ実行を許可する（'unsafe-inline' を使わない）
+テンプレートでは <script nonce="{{.CSPNonce}}"> のように、ビューモデルのCSPNonceを付ける
+*/
+
+const (
+	/* cspNonceContextKey はgin.Contextにリクエストのnonceを保存する際のキー */
+	cspNonceContextKey = "csp_nonce"
+	/* cspScriptCDN は画面のスタイルに使用するTailwind CSSのスクリプトの配信元 */
+	cspScriptCDN = "https://cdn.tailwindcss.com"
+)
+
+/*
+cspReportOnly はポリシーを適用せず、違反の報告のみ行うかどうか
+環境変数 CSP_REPORT_ONLY で変更可能（デフォルト: false）
+独自のテーマ（THEME_CUSTOM_CSS）やリバースプロキシが挿入するスクリプトが動作するかを確認する際に使用する
+*/
+var cspReportOnly = getEnvBool("CSP_REPORT_ONLY", false)
+
+/*
+cspStyleSources はstyle-srcに許可するスタイルシートの配信元
+Tailwind CSSはページに<style>を挿入するため 'unsafe-inline' を含める（スクリプトには許可しない）
+THEME_CUSTOM_CSSが他のオリジンのURLの場合は、そのオリジンを加える
+*/
+func cspStyleSources() []string {
+	sources := []string{"'self'", "'unsafe-inline'"}
+	if u, err := url.Parse(themeCustomCSS); err == nil && u.Scheme != "" && u.Host != "" {
+		sources = append(sources, u.Scheme+"://"+u.Host)
+	}
+	return sources
+}
+
+/*
+contentSecurityPolicy はnonceとframe-ancestorsの値からContent-Security-Policyの値を作成する
+
+引数:
+  nonce string - インラインスクリプトに許可するnonce
+  frameAncestors string - iframeに表示できるサイト（通常は 'self'、埋め込み用のページはEMBED_ALLOWED_ORIGINSを含む）
+*/
+func contentSecurityPolicy(nonce, frameAncestors string) string {
+	directives := []string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + nonce + "' " + cspScriptCDN,
+		"style-src " + strings.Join(cspStyleSources(), " "),
+		"img-src 'self' data: https:",
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors " + frameAncestors,
+	}
+	return strings.Join(directives, "; ")
+}
+
+/*
+setContentSecurityPolicy はリクエストのnonceでContent-Security-Policyヘッダーを設定する
+CSP_REPORT_ONLYの場合は Content-Security-Policy-Report-Only ヘッダーで設定する
+*/
+func setContentSecurityPolicy(c *gin.Context, frameAncestors string) {
+	header := "Content-Security-Policy"
+	if cspReportOnly {
+		header = "Content-Security-Policy-Report-Only"
+	}
+	c.Header(header, contentSecurityPolicy(cspNonce(c), frameAncestors))
+}
+
+/*
+cspMiddleware はリクエストごとのnonceを生成し、Content-Security-Policyヘッダーを設定するミドルウェア
+nonceはwebRequestでビューモデルに渡す
+
+注意:
+  - すべてのレスポンスに設定する（JSONなどのレスポンスでは影響しない）
+  - 埋め込み用のページ（/embed/timeline）はハンドラーでframe-ancestorsを変えて設定し直す
+*/
+func cspMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(cspNonceContextKey, newCSPNonce())
+		setContentSecurityPolicy(c, "'self'")
+		c.Next()
+	}
+}
+
+/*
+cspNonce はリクエストのnonceを返す
+cspMiddlewareを通過していない場合（静的サイトの書き出しなど）は、その場で生成する
+*/
+func cspNonce(c *gin.Context) string {
+	if nonce := c.GetString(cspNonceContextKey); nonce != "" {
+		return nonce
+	}
+	nonce := newCSPNonce()
+	c.Set(cspNonceContextKey, nonce)
+	return nonce
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+CSRF（クロスサイトリクエストフォージェリ）対策
+画面から送る状態を変更するリクエスト（POST・PUT・DELETE）に、セッションごとのトークンを要求する
+トークンはセッションIDのHMACのため、サーバーに保存せずに検証できる
+*/
+
+const (
+	/* csrfHeader は画面のJavaScriptがトークンを送るヘッダー名 */
+	csrfHeader = "X-CSRF-Token"
+	/* csrfFormField はHTMLのフォームがトークンを送るフィールド名 */
+	csrfFormField = "csrf_token"
+)
+
+/*
+csrfSecret はトークンの署名に使用する鍵
+環境変数 CSRF_SECRET で設定する。未設定の場合は起動ごとにランダムな鍵を生成する
+（再起動すると、開いている画面のトークンは無効になる。複数レプリカでは同じ値を設定する）
+*/
+var csrfSecret = loadCSRFSecret(getEnv("CSRF_SECRET", ""))
+
+var csrfRejectionsTotal = newCounterVec(
+	"giter_csrf_rejections_total",
+	"State-changing browser requests rejected for a missing or invalid CSRF token, by route.",
+	"route",
+)
+
+/* loadCSRFSecret はCSRF_SECRETの値を返す（未設定の場合はランダムな鍵を生成する） */
+func loadCSRFSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		/* newSessionIDと同様、乱数生成の失敗は起動環境の異常とみなす */
+		panic(err)
+	}
+	return b
+}
+
+/* csrfTokenFor はセッションIDのトークンを返す */
+func csrfTokenFor(sessionID string) string {
+	mac := hmac.New(sha256.New, csrfSecret)
+	mac.Write([]byte("csrf:" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+/*
+csrfToken はリクエストのセッションのトークンを返す
+ページのビューモデル（web.Request）に渡し、<meta name="csrf-token"> に出力する
+*/
+func csrfToken(c *gin.Context) string {
+	return csrfTokenFor(viewerID(c))
+}
+
+/*
+csrfMiddleware はクッキーで識別されるブラウザからの状態を変更するリクエストに、CSRFトークンを要求するミドルウェア
+トークンは X-CSRF-Token ヘッダー、またはフォームの csrf_token フィールドで送る
+
+レスポンス:
+  トークンがない・一致しない場合: 403 Forbidden
+
+注意:
+  - GET・HEAD・OPTIONSは対象外（状態を変更しないため）
+  - Authorizationヘッダーで認証したリクエスト（APIキー）は対象外（ブラウザが自動で付けないため）
+    検証できないAuthorizationヘッダーは匿名として扱われ、クッキーの閲覧者で処理されるため対象にする
+  - どのルートにも一致しないリクエストは対象外（404を返すハンドラーに任せる）
+  - セッションのクッキーを送っていないリクエスト（curl・Grafanaなどのサーバーからの呼び出し）は対象外
+  - authMiddlewareの後に登録する（APIキーで認証したかを確認するため）
+*/
+func csrfMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if requestPrincipal(c).Scheme == authSchemeAPIKey || c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		if cookie, err := c.Cookie(sessionCookieName); err != nil || cookie != viewerID(c) {
+			c.Next()
+			return
+		}
+
+		token := c.GetHeader(csrfHeader)
+		if token == "" && strings.HasPrefix(c.ContentType(), "application/x-www-form-urlencoded") {
+			token = c.PostForm(csrfFormField)
+		}
+		if !hmac.Equal([]byte(token), []byte(csrfToken(c))) {
+			csrfRejectionsTotal.Inc(strings.TrimPrefix(c.FullPath(), basePath))
+			log.Warn().Str("path", c.Request.URL.Path).Str("client_ip", c.ClientIP()).Bool("token_present", token != "").Msg("Rejected request with an invalid CSRF token")
+			abortWithError(c, http.StatusForbidden, "invalid CSRF token")
+			return
+		}
+		c.Next()
+	}
+}
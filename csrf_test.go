@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	keys := apiKeys
+	apiKeys = loadAPIKeys(testAdminToken, "")
+	t.Cleanup(func() { apiKeys = keys })
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessionMiddleware(), csrfMiddleware())
+	r.GET("/state", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/state", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	session, other := newSessionID(), newSessionID()
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cookie string            // セッションのクッキー（空文字の場合は送らない）
+		header map[string]string // 追加するヘッダー
+		form   string            // フォームのcsrf_token（空文字の場合はフォームで送らない）
+		want   int
+	}{
+		{name: "GET without token", method: http.MethodGet, path: "/state", cookie: session, want: http.StatusOK},
+		{name: "no session cookie", method: http.MethodPost, path: "/state", want: http.StatusNoContent},
+		{name: "missing token", method: http.MethodPost, path: "/state", cookie: session, want: http.StatusForbidden},
+		{name: "token of another session", method: http.MethodPost, path: "/state", cookie: session, header: map[string]string{csrfHeader: csrfTokenFor(other)}, want: http.StatusForbidden},
+		{name: "tampered token", method: http.MethodPost, path: "/state", cookie: session, header: map[string]string{csrfHeader: csrfTokenFor(session) + "x"}, want: http.StatusForbidden},
+		{name: "header token", method: http.MethodPost, path: "/state", cookie: session, header: map[string]string{csrfHeader: csrfTokenFor(session)}, want: http.StatusNoContent},
+		{name: "form token", method: http.MethodPost, path: "/state", cookie: session, form: csrfTokenFor(session), want: http.StatusNoContent},
+		{name: "form token of another session", method: http.MethodPost, path: "/state", cookie: session, form: csrfTokenFor(other), want: http.StatusForbidden},
+		/* APIキーはブラウザが自動で付けないため対象外。検証できないヘッダーでは対象外にならない */
+		{name: "API key bypass", method: http.MethodPost, path: "/state", cookie: session, header: map[string]string{"Authorization": "Bearer " + testAdminToken}, want: http.StatusNoContent},
+		{name: "invalid Authorization header", method: http.MethodPost, path: "/state", cookie: session, header: map[string]string{"Authorization": "Bearer not-a-key"}, want: http.StatusForbidden},
+		{name: "unknown route", method: http.MethodPost, path: "/missing", cookie: session, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{csrfFormField: {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+			}
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+/* 匿名の閲覧者に許可したルートでは検証できないAuthorizationヘッダーを無視するため、CSRFトークンで拒否する */
+func TestCSRFIgnoresInvalidAuthorization(t *testing.T) {
+	dir := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() {
+		dataDir = dir
+		authLockouts.entries = map[string]*AuthLockout{}
+	})
+	gin.SetMode(gin.TestMode)
+	r := newRouter(&backupHandlers{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notifications/read-all", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: newSessionID()})
+	req.Header.Set("Authorization", "Bearer not-a-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", w.Code)
+	}
+}
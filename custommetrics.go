@@ -0,0 +1,523 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+カスタムメトリクス
+CUSTOM_METRICSに定義した集計（データセット・絞り込み・グループ化・集計方法）を /api/metrics/custom/:name で返す
+アプリを変更せずに、チームごとに必要な指標を追加するための機能
+*/
+
+/* 集計方法 */
+const (
+	customAggCount    = "count"    // レコード数
+	customAggDistinct = "distinct" // 項目の値の種類数（distinct:repo）
+	customAggSum      = "sum"      // 数値の合計（sum:open_hours）
+	customAggAvg      = "avg"      // 数値の平均
+	customAggMin      = "min"      // 数値の最小値
+	customAggMax      = "max"      // 数値の最大値
+	customAggMedian   = "median"   // 数値の中央値
+)
+
+/* customMetricNamePattern はメトリクス名の形式（URLのパスに使用するため英小文字・数字・アンダースコアのみ） */
+var customMetricNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{0,63}$`)
+
+/*
+customMetricRecord はデータセットの1件のレコード
+dimsは絞り込み・グループ化・distinctに使用する項目（ラベルのように複数の値を持つ場合がある）、
+numsは数値の集計に使用する項目（値のない項目は含めない）
+*/
+type customMetricRecord struct {
+	at   time.Time
+	dims map[string][]string
+	nums map[string]float64
+}
+
+/* customMetricSource はカスタムメトリクスのデータセット */
+type customMetricSource struct {
+	dims  []string                                         // 絞り込み・グループ化に使用できる項目
+	nums  []string                                         // 数値の集計に使用できる項目
+	fetch func(v visibility) ([]customMetricRecord, error) // データの範囲に含まれるレコードを取得する
+}
+
+/*
+customMetricSources はデータセットの一覧
+  - commits: 同期したコミット（リポジトリごとに最新100件まで）
+  - issues: Issue（プルリクエストを除く、リポジトリごとに最新100件まで）
+  - pulls: プルリクエスト（リポジトリごとに最新100件まで）
+*/
+var customMetricSources = map[string]customMetricSource{
+	"commits": {
+		dims:  []string{"repo", "author", "label", "month", "week", "day", "weekday", "hour"},
+		nums:  []string{"hour", "message_length"},
+		fetch: commitMetricRecords,
+	},
+	"issues": {
+		dims:  []string{"repo", "author", "state", "month", "closed_month"},
+		nums:  []string{"open_hours"},
+		fetch: issueMetricRecords,
+	},
+	"pulls": {
+		dims:  []string{"repo", "author", "state", "month", "merged_month"},
+		nums:  []string{"merge_hours"},
+		fetch: pullMetricRecords,
+	},
+}
+
+/* customMetricFilter は項目の値の絞り込み（いずれかの値に一致するレコードを残す） */
+type customMetricFilter struct {
+	field  string
+	values []string
+}
+
+/*
+CustomMetric はCUSTOM_METRICSに定義したカスタムメトリクス
+*/
+type CustomMetric struct {
+	Name        string               `json:"name"`             // メトリクス名
+	Source      string               `json:"source"`           // データセット（commits, issues, pulls）
+	Filter      string               `json:"filter,omitempty"` // 絞り込みの定義（例: "label:bugfix,repo:giter|cli"）
+	GroupBy     []string             `json:"group_by"`         // グループ化する項目（空の場合は全体のみ）
+	Aggregation string               `json:"aggregation"`      // 集計方法（例: "count", "avg:open_hours"）
+	Days        int                  `json:"days,omitempty"`   // 直近の日数に絞り込む（0の場合はすべて）
+	filters     []customMetricFilter // Filterを読み込んだ絞り込み
+}
+
+/*
+customMetrics はメトリクス名ごとのカスタムメトリクス
+環境変数 CUSTOM_METRICS で定義する（未設定の場合はなし）。parseCustomMetricsを参照
+*/
+var customMetrics = parseCustomMetrics(getEnv("CUSTOM_METRICS", ""))
+
+/*
+parseCustomMetrics は改行区切りのカスタムメトリクスの定義を読み込む
+各行は "メトリクス名 項目=値 ..." の形式
+  - source=commits: データセット（必須、commits / issues / pulls）
+  - filter=label:bugfix,repo:giter|cli: 絞り込み（","区切りの条件をすべて満たし、"|"区切りの値のいずれかに一致するレコード）
+  - group=repo,month: グループ化する項目
+  - agg=count: 集計方法（count / distinct:項目 / sum・avg・min・max・median:数値の項目、省略時はcount）
+  - days=90: 直近の日数に絞り込む（コミット日時・作成日時で判定）
+
+例:
+  CUSTOM_METRICS="bugfixes source=commits filter=label:bugfix group=repo
+  weekend_commits source=commits filter=weekday:sat|sun group=month days=180
+  issue_open_time source=issues filter=state:closed group=repo agg=median:open_hours"
+
+注意:
+  - 空行と "#" で始まる行は無視する
+  - データセットにない項目・未知の集計方法・形式が不正なメトリクス名を含む行は、警告を出力して無視する
+*/
+func parseCustomMetrics(raw string) map[string]CustomMetric {
+	metrics := map[string]CustomMetric{}
+	for _, line := range strings.Split(raw, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		metric, err := parseCustomMetric(fields)
+		if err != nil {
+			log.Warn().Err(err).Str("definition", strings.TrimSpace(line)).Msg("Ignoring invalid CUSTOM_METRICS entry")
+			continue
+		}
+		metrics[metric.Name] = metric
+	}
+	return metrics
+}
+
+/* parseCustomMetric は1行分の定義（空白で区切った項目）を読み込んで検証する */
+func parseCustomMetric(fields []string) (CustomMetric, error) {
+	metric := CustomMetric{Name: fields[0], GroupBy: []string{}, Aggregation: customAggCount}
+	if !customMetricNamePattern.MatchString(metric.Name) {
+		return metric, fmt.Errorf("invalid metric name: %s", metric.Name)
+	}
+	for _, field := range fields[1:] {
+		name, value, _ := strings.Cut(field, "=")
+		var err error
+		switch name {
+		case "source":
+			metric.Source = value
+		case "filter":
+			metric.Filter = value
+		case "group":
+			metric.GroupBy = splitList(value)
+		case "agg":
+			metric.Aggregation = value
+		case "days":
+			metric.Days, err = strconv.Atoi(value)
+			if err == nil && metric.Days < 0 {
+				err = fmt.Errorf("days must not be negative")
+			}
+		default:
+			err = fmt.Errorf("unknown field: %s", name)
+		}
+		if err != nil {
+			return metric, err
+		}
+	}
+
+	source, ok := customMetricSources[metric.Source]
+	if !ok {
+		return metric, fmt.Errorf("unknown source: %q", metric.Source)
+	}
+	for _, cond := range splitList(metric.Filter) {
+		field, values, _ := strings.Cut(cond, ":")
+		if !containsString(source.dims, field) || values == "" {
+			return metric, fmt.Errorf("invalid filter for %s: %s", metric.Source, cond)
+		}
+		metric.filters = append(metric.filters, customMetricFilter{field: field, values: strings.Split(values, "|")})
+	}
+	for _, field := range metric.GroupBy {
+		if !containsString(source.dims, field) {
+			return metric, fmt.Errorf("unknown group field for %s: %s", metric.Source, field)
+		}
+	}
+
+	op, field, _ := strings.Cut(metric.Aggregation, ":")
+	switch op {
+	case customAggCount:
+		if field != "" {
+			return metric, fmt.Errorf("count does not take a field")
+		}
+	case customAggDistinct:
+		if !containsString(source.dims, field) {
+			return metric, fmt.Errorf("unknown distinct field for %s: %s", metric.Source, field)
+		}
+	case customAggSum, customAggAvg, customAggMin, customAggMax, customAggMedian:
+		if !containsString(source.nums, field) {
+			return metric, fmt.Errorf("unknown numeric field for %s: %s", metric.Source, field)
+		}
+	default:
+		return metric, fmt.Errorf("unknown aggregation: %s", metric.Aggregation)
+	}
+	return metric, nil
+}
+
+/* matches はレコードが絞り込みの条件をすべて満たすかを返す */
+func (m CustomMetric) matches(record customMetricRecord, since time.Time) bool {
+	if m.Days > 0 && record.at.Before(since) {
+		return false
+	}
+	for _, filter := range m.filters {
+		matched := false
+		for _, value := range record.dims[filter.field] {
+			if containsString(filter.values, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+aggregate はレコードを集計方法で集計する
+
+戻り値:
+  *float64 - 集計した値（数値の集計で値を持つレコードがない場合はnil）
+*/
+func (m CustomMetric) aggregate(records []customMetricRecord) *float64 {
+	op, field, _ := strings.Cut(m.Aggregation, ":")
+	var result float64
+	switch op {
+	case customAggCount:
+		result = float64(len(records))
+	case customAggDistinct:
+		seen := map[string]bool{}
+		for _, record := range records {
+			for _, value := range record.dims[field] {
+				seen[value] = true
+			}
+		}
+		result = float64(len(seen))
+	default:
+		var values []float64
+		for _, record := range records {
+			if v, ok := record.nums[field]; ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		sort.Float64s(values)
+		switch op {
+		case customAggSum, customAggAvg:
+			for _, v := range values {
+				result += v
+			}
+			if op == customAggAvg {
+				result /= float64(len(values))
+			}
+		case customAggMin:
+			result = values[0]
+		case customAggMax:
+			result = values[len(values)-1]
+		case customAggMedian:
+			result = values[len(values)/2]
+			if len(values)%2 == 0 {
+				result = (values[len(values)/2-1] + result) / 2
+			}
+		}
+	}
+	result = math.Round(result*1000) / 1000
+	return &result
+}
+
+/* dimensionValues はグループ化に使用する項目の値（値がない場合は空文字の1件） */
+func (r customMetricRecord) dimensionValues(field string) []string {
+	if values := r.dims[field]; len(values) > 0 {
+		return values
+	}
+	return []string{""}
+}
+
+/* commitMetricRecords はcommitsデータセットのレコードを返す */
+func commitMetricRecords(v visibility) ([]customMetricRecord, error) {
+	commits, _, err := fetchVisibleCommitHistory(v)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]customMetricRecord, 0, len(commits))
+	for _, commit := range labelCommits(commits) {
+		t := commit.CommitTime.In(statsLocation)
+		records = append(records, customMetricRecord{
+			at: commit.CommitTime,
+			dims: map[string][]string{
+				"repo":    {commit.RepositoryName},
+				"author":  {commit.AuthorName},
+				"label":   commit.Labels,
+				"month":   {t.Format("2006-01")},
+				"week":    {isoWeekString(t)},
+				"day":     {t.Format("2006-01-02")},
+				"weekday": {strings.ToLower(t.Weekday().String()[:3])},
+				"hour":    {t.Format("15")},
+			},
+			nums: map[string]float64{
+				"hour":           float64(t.Hour()),
+				"message_length": float64(len([]rune(commit.CommitMessage))),
+			},
+		})
+	}
+	return records, nil
+}
+
+/*
+repositoryMetricRecords はデータの範囲に含まれるリポジトリごとにfetchを並行して実行し、レコードをまとめて返す
+取得に失敗したリポジトリは警告を出力して除く（すべて失敗した場合はエラー）
+*/
+func repositoryMetricRecords(v visibility, fetch func(repo Repository) ([]customMetricRecord, error)) ([]customMetricRecord, error) {
+	repos, err := fetchVisibleRepositories(v)
+	if err != nil {
+		return nil, err
+	}
+	results := make([][]customMetricRecord, len(repos))
+	errs := make([]error, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		results[i], errs[i] = fetch(repos[i])
+	})
+
+	var records []customMetricRecord
+	failed := 0
+	for i, repo := range repos {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Str("repository", repo.Name).Msg("Failed to fetch records for custom metric")
+			failed++
+			continue
+		}
+		records = append(records, results[i]...)
+	}
+	if failed > 0 && failed == len(repos) {
+		return nil, errs[0]
+	}
+	return records, nil
+}
+
+/* issueMetricRecords はissuesデータセットのレコードを返す（open_hoursはオープン中のIssueでは現在までの時間） */
+func issueMetricRecords(v visibility) ([]customMetricRecord, error) {
+	now := time.Now()
+	return repositoryMetricRecords(v, func(repo Repository) ([]customMetricRecord, error) {
+		issues, err := fetchIssues(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]customMetricRecord, 0, len(issues))
+		for _, issue := range issues {
+			record := customMetricRecord{
+				at: issue.CreatedAt,
+				dims: map[string][]string{
+					"repo":   {repo.Name},
+					"author": {issue.User.Login},
+					"state":  {issue.State},
+					"month":  {issue.CreatedAt.In(statsLocation).Format("2006-01")},
+				},
+				nums: map[string]float64{"open_hours": now.Sub(issue.CreatedAt).Hours()},
+			}
+			if issue.ClosedAt != nil {
+				record.dims["closed_month"] = []string{issue.ClosedAt.In(statsLocation).Format("2006-01")}
+				record.nums["open_hours"] = issue.ClosedAt.Sub(issue.CreatedAt).Hours()
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	})
+}
+
+/* pullMetricRecords はpullsデータセットのレコードを返す（stateはマージ済みの場合 "merged"） */
+func pullMetricRecords(v visibility) ([]customMetricRecord, error) {
+	return repositoryMetricRecords(v, func(repo Repository) ([]customMetricRecord, error) {
+		pulls, err := fetchPullRequests(repo.FullName)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]customMetricRecord, 0, len(pulls))
+		for _, pull := range pulls {
+			record := customMetricRecord{
+				at: pull.CreatedAt,
+				dims: map[string][]string{
+					"repo":   {repo.Name},
+					"author": {pull.User.Login},
+					"state":  {pull.State},
+					"month":  {pull.CreatedAt.In(statsLocation).Format("2006-01")},
+				},
+				nums: map[string]float64{},
+			}
+			if pull.MergedAt != nil {
+				record.dims["state"] = []string{"merged"}
+				record.dims["merged_month"] = []string{pull.MergedAt.In(statsLocation).Format("2006-01")}
+				record.nums["merge_hours"] = pull.MergedAt.Sub(pull.CreatedAt).Hours()
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	})
+}
+
+/* CustomMetricGroup はグループごとの集計結果 */
+type CustomMetricGroup struct {
+	Key     map[string]string `json:"key"`     // グループ化した項目の値（値がない場合は空文字）
+	Value   *float64          `json:"value"`   // 集計した値（数値の集計で値がない場合はnull）
+	Records int               `json:"records"` // グループのレコード数
+}
+
+/* CustomMetricResponse は /api/metrics/custom/:name のレスポンス */
+type CustomMetricResponse struct {
+	CustomMetric
+	GeneratedAt time.Time           `json:"generated_at"` // 集計した日時
+	Records     int                 `json:"records"`      // 絞り込み後のレコード数
+	Value       *float64            `json:"value"`        // 全体の集計した値
+	Groups      []CustomMetricGroup `json:"groups"`       // グループごとの集計結果（キー順、group_byがない場合は空配列）
+}
+
+/*
+evaluate はレコードを絞り込み、グループ化して集計する
+複数の値を持つ項目（label）でグループ化した場合、レコードはそれぞれの値のグループに含める
+*/
+func (m CustomMetric) evaluate(records []customMetricRecord, now time.Time) CustomMetricResponse {
+	since := now.AddDate(0, 0, -m.Days)
+	var matched []customMetricRecord
+	for _, record := range records {
+		if m.matches(record, since) {
+			matched = append(matched, record)
+		}
+	}
+	resp := CustomMetricResponse{CustomMetric: m, GeneratedAt: now, Records: len(matched), Value: m.aggregate(matched), Groups: []CustomMetricGroup{}}
+	if len(m.GroupBy) == 0 {
+		return resp
+	}
+
+	groups := map[string][]customMetricRecord{}
+	keys := map[string]map[string]string{}
+	for _, record := range matched {
+		/* グループ化する項目の値の組み合わせごとにレコードを加える */
+		combos := []map[string]string{{}}
+		for _, field := range m.GroupBy {
+			var next []map[string]string
+			for _, combo := range combos {
+				for _, value := range record.dimensionValues(field) {
+					key := map[string]string{field: value}
+					for k, v := range combo {
+						key[k] = v
+					}
+					next = append(next, key)
+				}
+			}
+			combos = next
+		}
+		for _, key := range combos {
+			parts := make([]string, len(m.GroupBy))
+			for i, field := range m.GroupBy {
+				parts[i] = key[field]
+			}
+			id := strings.Join(parts, "\x00")
+			groups[id] = append(groups[id], record)
+			keys[id] = key
+		}
+	}
+	ids := make([]string, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		resp.Groups = append(resp.Groups, CustomMetricGroup{Key: keys[id], Value: m.aggregate(groups[id]), Records: len(groups[id])})
+	}
+	return resp
+}
+
+/*
+getCustomMetrics はCUSTOM_METRICSに定義したカスタムメトリクスの一覧を返すAPIハンドラー
+
+レスポンス:
+  200 OK, {"metrics": []CustomMetric（メトリクス名順）}
+*/
+func getCustomMetrics(c *gin.Context) {
+	metrics := make([]CustomMetric, 0, len(customMetrics))
+	for _, metric := range customMetrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	respondJSON(c, http.StatusOK, gin.H{"metrics": metrics})
+}
+
+/*
+getCustomMetric はカスタムメトリクスを集計して返すAPIハンドラー
+
+パスパラメータ:
+  name string - メトリクス名
+
+レスポンス:
+  成功時: 200 OK, CustomMetricResponse
+  失敗時: 404 Not Found（定義がない）, 502 Bad Gateway（GitHubからデータを取得できない）
+
+注意:
+  - 閲覧者のデータの範囲（PRIVACY_MODE・閲覧者から隠すリポジトリ）に含まれるデータのみ集計する
+*/
+func getCustomMetric(c *gin.Context) {
+	metric, ok := customMetrics[c.Param("name")]
+	if !ok {
+		respondError(c, http.StatusNotFound, "custom metric not found")
+		return
+	}
+	records, err := customMetricSources[metric.Source].fetch(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Str("metric", metric.Name).Msg("Failed to fetch records for custom metric")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	respondJSON(c, http.StatusOK, metric.evaluate(records, time.Now()))
+}
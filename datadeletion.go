@@ -0,0 +1,482 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* dataDeletionTable はデータの削除に伴って保存する状態（共有URLの取り消し）のテーブル名 */
+	dataDeletionTable = "data_deletion"
+	/* auditActionDataDelete はデータの削除の監査ログの操作の種類 */
+	auditActionDataDelete = "data.delete"
+)
+
+/* 削除の範囲（DELETE /api/admin/data の user に指定した値の種類） */
+const (
+	dataScopeWorkspace = "workspace" // 取得対象のGitHubユーザー（username）の保存データすべて
+	dataScopeViewer    = "viewer"    // 1人の閲覧者（セッションID）のデータ
+)
+
+/*
+dataDeletionConfirmTTL は削除の確認トークンの有効期間
+環境変数 DATA_DELETION_CONFIRM_TTL で変更可能（デフォルト: 5分）
+*/
+var dataDeletionConfirmTTL = parseDurationEnv("DATA_DELETION_CONFIRM_TTL", 5*time.Minute)
+
+/*
+dataDeletionConfirmKey は確認トークンの署名（HMAC-SHA256）に使用する鍵
+起動ごとに生成するため、再起動前に発行した確認トークンは使用できない
+*/
+var dataDeletionConfirmKey = []byte(newSessionID())
+
+/*
+dataDeletionStore はデータの削除に伴って保存する状態
+data_deletionテーブルに永続化される
+*/
+type dataDeletionStore struct {
+	mu sync.Mutex
+	/* ShareTokensRevokedAt はこれより前に作成した共有URLを無効にする日時（ゼロ値の場合は取り消しなし） */
+	ShareTokensRevokedAt time.Time `json:"share_tokens_revoked_at"`
+}
+
+/* dataDeletions はアプリケーション全体で共有するデータの削除の状態 */
+var dataDeletions = &dataDeletionStore{}
+
+func init() {
+	registerTable(dataDeletionTable, loadDataDeletions)
+}
+
+/*
+loadDataDeletions はdata_deletionテーブルから状態を復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadDataDeletions() error {
+	dataDeletions.mu.Lock()
+	defer dataDeletions.mu.Unlock()
+
+	dataDeletions.ShareTokensRevokedAt = time.Time{}
+	return loadTable(dataDeletionTable, dataDeletions)
+}
+
+/* shareTokensRevokedAt は共有URLを取り消した日時を返す */
+func (s *dataDeletionStore) shareTokensRevokedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ShareTokensRevokedAt
+}
+
+/* revokeShareTokens はこれまでに作成したすべての共有URLを無効にする */
+func (s *dataDeletionStore) revokeShareTokens(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ShareTokensRevokedAt = now
+	if err := saveTable(dataDeletionTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save share token revocation")
+	}
+}
+
+/*
+DataDeletionPlan は確認トークンなしの DELETE /api/admin/data に返す削除の内容
+確認トークンを付けて同じリクエストを送信すると削除する
+*/
+type DataDeletionPlan struct {
+	ErrorResponse
+	User              string         `json:"user"`               // 削除の対象
+	Scope             string         `json:"scope"`              // 削除の範囲（"workspace" または "viewer"）
+	Counts            map[string]int `json:"counts"`             // 種類ごとの削除する件数
+	ConfirmationToken string         `json:"confirmation_token"` // ?confirm= に指定する確認トークン
+	ExpiresAt         time.Time      `json:"expires_at"`         // 確認トークンの有効期限
+}
+
+/* DataDeletionResult は DELETE /api/admin/data で削除した結果 */
+type DataDeletionResult struct {
+	User    string         `json:"user"`     // 削除の対象
+	Scope   string         `json:"scope"`    // 削除の範囲
+	Deleted map[string]int `json:"deleted"`  // 種類ごとの削除した件数
+	AuditID string         `json:"audit_id"` // 監査ログのエントリーID
+}
+
+/* dataDeletionQuery は DELETE /api/admin/data のクエリパラメータ */
+type dataDeletionQuery struct {
+	User    string `form:"user" binding:"required"` // 削除の対象（GitHubユーザー名または閲覧者ID）
+	Confirm string `form:"confirm"`                 // 確認トークン
+}
+
+/*
+dataScope は削除の対象の値から削除の範囲を判定する
+取得対象のGitHubユーザー名（大文字小文字を区別しない）はworkspace、セッションIDの形式はviewer
+
+戻り値:
+  string - 削除の範囲（どちらでもない場合は空文字）
+*/
+func dataScope(user string) string {
+	switch {
+	case strings.EqualFold(user, username):
+		return dataScopeWorkspace
+	case isValidSessionID(user):
+		return dataScopeViewer
+	default:
+		return ""
+	}
+}
+
+/* dataDeletionSignature は削除の対象と有効期限（Unix時間）に対する確認トークンの署名を返す */
+func dataDeletionSignature(user, expires string) []byte {
+	mac := hmac.New(sha256.New, dataDeletionConfirmKey)
+	mac.Write([]byte(auditActionDataDelete + "\n" + user + "\n" + expires))
+	return mac.Sum(nil)
+}
+
+/*
+signDataDeletion は削除の対象に対する確認トークンを作成する
+形式は "<有効期限のUnix時間>.<署名のbase64url>"
+*/
+func signDataDeletion(user string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + base64.RawURLEncoding.EncodeToString(dataDeletionSignature(user, expires))
+}
+
+/*
+verifyDataDeletion は確認トークンが削除の対象に対して発行したもので、有効期限内かどうかを返す
+
+注意:
+  - 署名の比較には hmac.Equal を使用し、タイミング攻撃を防ぐ
+*/
+func verifyDataDeletion(user, token string, now time.Time) bool {
+	expires, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, dataDeletionSignature(user, expires)) {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	return err == nil && now.Before(time.Unix(unix, 0))
+}
+
+/*
+countViewerData は閲覧者ごとのデータの件数を返す
+
+引数:
+  viewers map[string]bool - 対象の閲覧者ID（nilの場合はすべての閲覧者）
+*/
+func countViewerData(viewers map[string]bool) map[string]int {
+	counts := map[string]int{}
+	match := func(id string) bool { return viewers == nil || viewers[id] }
+
+	notifications.mu.Lock()
+	for id, inbox := range notifications.Inboxes {
+		if match(id) {
+			counts["notifications"] += len(inbox)
+		}
+	}
+	for id := range notifications.Preferences {
+		if match(id) {
+			counts["notification_preferences"]++
+		}
+	}
+	notifications.mu.Unlock()
+
+	viewerPreferences.mu.Lock()
+	for id := range viewerPreferences.Viewers {
+		if match(id) {
+			counts["preferences"]++
+		}
+	}
+	viewerPreferences.mu.Unlock()
+
+	viewerLastSeen.mu.Lock()
+	for id := range viewerLastSeen.Viewers {
+		if match(id) {
+			counts["last_seen"]++
+		}
+	}
+	viewerLastSeen.mu.Unlock()
+
+	savedSearches.mu.Lock()
+	for id, entries := range savedSearches.Viewers {
+		if match(id) {
+			counts["saved_searches"] += len(entries)
+		}
+	}
+	savedSearches.mu.Unlock()
+
+	redactionBypass.mu.Lock()
+	for id := range redactionBypass.sessions {
+		if match(id) {
+			counts["sessions"]++
+		}
+	}
+	redactionBypass.mu.Unlock()
+	return counts
+}
+
+/*
+deleteViewerData は閲覧者ごとのデータ（通知の受信箱と通知設定・表示設定・既読位置・保存した検索条件・管理者のセッションの設定）を削除する
+
+引数:
+  viewers map[string]bool - 対象の閲覧者ID（nilの場合はすべての閲覧者）
+
+戻り値:
+  map[string]int - 種類ごとの削除した件数
+*/
+func deleteViewerData(viewers map[string]bool) map[string]int {
+	counts := countViewerData(viewers)
+	match := func(id string) bool { return viewers == nil || viewers[id] }
+
+	notifications.mu.Lock()
+	for id := range notifications.Inboxes {
+		if match(id) {
+			delete(notifications.Inboxes, id)
+		}
+	}
+	for id := range notifications.Preferences {
+		if match(id) {
+			delete(notifications.Preferences, id)
+		}
+	}
+	notifications.save()
+	notifications.mu.Unlock()
+
+	viewerPreferences.mu.Lock()
+	for id := range viewerPreferences.Viewers {
+		if match(id) {
+			delete(viewerPreferences.Viewers, id)
+		}
+	}
+	viewerPreferences.save()
+	viewerPreferences.mu.Unlock()
+
+	viewerLastSeen.mu.Lock()
+	for id := range viewerLastSeen.Viewers {
+		if match(id) {
+			delete(viewerLastSeen.Viewers, id)
+		}
+	}
+	viewerLastSeen.save()
+	viewerLastSeen.mu.Unlock()
+
+	savedSearches.mu.Lock()
+	for id := range savedSearches.Viewers {
+		if match(id) {
+			delete(savedSearches.Viewers, id)
+		}
+	}
+	savedSearches.save()
+	savedSearches.mu.Unlock()
+
+	redactionBypass.mu.Lock()
+	for id := range redactionBypass.sessions {
+		if match(id) {
+			delete(redactionBypass.sessions, id)
+		}
+	}
+	redactionBypass.mu.Unlock()
+	return counts
+}
+
+/*
+countWorkspaceData はworkspaceの削除で削除するデータの件数を返す
+コミットはアーカイブ（HistoryStore）・スナップショット・同期の位置・バックフィルのチェックポイント・ブランチの状態・
+ログインしたセッション・レポート（PDFを含む）・送信待ちのWebhook・GitHub APIのキャッシュ・レスポンスのキャッシュ
+*/
+func countWorkspaceData() (map[string]int, error) {
+	archived, err := history.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	counts := countViewerData(nil)
+	counts["commits"] = len(archived)
+
+	snapshots.mu.Lock()
+	counts["snapshots"] = len(snapshots.Snapshots)
+	snapshots.mu.Unlock()
+
+	syncCursors.mu.Lock()
+	counts["sync_cursors"] = len(syncCursors.Repositories)
+	syncCursors.mu.Unlock()
+
+	backfill.mu.Lock()
+	counts["backfill_checkpoints"] = len(backfill.Status.Repositories)
+	backfill.mu.Unlock()
+
+	branchHeads.mu.Lock()
+	counts["branch_heads"] = len(branchHeads.Heads)
+	branchHeads.mu.Unlock()
+
+	authSessions.mu.Lock()
+	counts["auth_sessions"] = len(authSessions.Sessions)
+	authSessions.mu.Unlock()
+
+	reports.mu.Lock()
+	counts["reports"] = len(reports.Reports)
+	reports.mu.Unlock()
+
+	counts["webhook_deliveries"] = webhooks.pendingLen()
+	counts["cached_responses"] = githubCache.len()
+	counts["stored_responses"] = responseCacheLen()
+	return counts, nil
+}
+
+/*
+deleteWorkspaceData はworkspaceの保存データをすべて削除し、それまでに作成した共有URLを無効にする
+監査ログは削除しない
+
+戻り値:
+  map[string]int - 種類ごとの削除した件数（share_tokensは取り消した場合に1）
+  error - アーカイブの削除に失敗した場合のエラー（それまでに削除した分は削除済み）
+*/
+func deleteWorkspaceData(now time.Time) (map[string]int, error) {
+	counts := deleteViewerData(nil)
+
+	snapshots.mu.Lock()
+	counts["snapshots"] = len(snapshots.Snapshots)
+	snapshots.Snapshots = []Snapshot{}
+	if err := saveTable(snapshotsTable, snapshots); err != nil {
+		log.Error().Err(err).Msg("Failed to save snapshots")
+	}
+	snapshots.mu.Unlock()
+
+	syncCursors.mu.Lock()
+	counts["sync_cursors"] = len(syncCursors.Repositories)
+	syncCursors.Repositories = map[string]SyncCursor{}
+	syncCursors.save()
+	syncCursors.mu.Unlock()
+
+	backfill.mu.Lock()
+	counts["backfill_checkpoints"] = len(backfill.Status.Repositories)
+	backfill.Status = BackfillStatus{Repositories: map[string]BackfillCheckpoint{}}
+	backfill.save()
+	backfill.mu.Unlock()
+
+	notifications.mu.Lock()
+	notifications.LatestCommitTimes = map[string]time.Time{}
+	notifications.save()
+	notifications.mu.Unlock()
+
+	/* ログインしたセッションはGitHubのログイン名を含むため、すべてログアウトさせる */
+	authSessions.mu.Lock()
+	counts["auth_sessions"] = len(authSessions.Sessions)
+	authSessions.Sessions = map[string]AuthSession{}
+	if err := saveTable(authSessionsTable, authSessions); err != nil {
+		log.Error().Err(err).Msg("Failed to save auth sessions")
+	}
+	authSessions.mu.Unlock()
+
+	/* レポートと送信待ちのWebhookはコミットの内容を含む */
+	counts["reports"] = reports.purge()
+	counts["webhook_deliveries"] = webhooks.dropPending()
+
+	counts["branch_heads"] = branchHeads.reset()
+	counts["follower_snapshots"] = followers.reset()
+	counts["cached_responses"] = githubCache.reset()
+
+	dataDeletions.revokeShareTokens(now)
+	counts["share_tokens"] = 1
+
+	purged, err := history.Purge()
+	counts["commits"] = purged
+	commitSearch.reset()
+	statsViews.reset()
+	/* 削除したデータから作成したレスポンスを返さないよう、最後に破棄する */
+	counts["stored_responses"] = clearResponseCache()
+	return counts, err
+}
+
+/*
+deleteData は保存データを削除する管理者APIハンドラー（個人データの削除依頼への対応）
+1回目のリクエストでは削除せず、削除する件数と確認トークンを返す。確認トークンを ?confirm= に付けて再送すると削除し、監査ログに記録する
+
+クエリパラメータ:
+  user string - 削除の対象（必須）
+    取得対象のGitHubユーザー名: コミットのアーカイブ・スナップショット・同期の位置・バックフィルのチェックポイント・
+                                 GitHub APIのキャッシュ・すべての閲覧者のデータとログインしたセッションを削除し、共有URLを無効にする
+    閲覧者ID（giter_sessionクッキーの値）: その閲覧者の通知・通知設定・表示設定・既読位置を削除する
+  confirm string - 1回目のレスポンスの確認トークン
+
+レスポンス:
+  確認トークンなし: 428 Precondition Required, DataDeletionPlan
+  成功時: 200 OK, DataDeletionResult
+  失敗時: 422 Unprocessable Entity（userが不正、確認トークンが不正・期限切れ）,
+          409 Conflict（バックフィルの実行中）, 500 Internal Server Error（アーカイブの読み込み・削除に失敗）
+
+注意:
+  - バックアップ・BlobStoreに保存したエクスポートは削除しない
+  - 削除後も次の同期でGitHubから再び取得する。取得しないようにする場合はリポジトリを非公開にするか削除する
+*/
+func deleteData(c *gin.Context) {
+	var query dataDeletionQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	scope := dataScope(query.User)
+	if scope == "" {
+		respondValidationError(c, []FieldError{{Field: "user", Rule: "exists", Message: "must be the GitHub user or a viewer ID"}})
+		return
+	}
+
+	now := time.Now()
+	if query.Confirm == "" {
+		var counts map[string]int
+		if scope == dataScopeWorkspace {
+			var err error
+			if counts, err = countWorkspaceData(); err != nil {
+				log.Error().Err(err).Msg("Failed to count data for deletion")
+				respondError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+		} else {
+			counts = countViewerData(map[string]bool{query.User: true})
+		}
+		expiresAt := now.Add(dataDeletionConfirmTTL).UTC().Truncate(time.Second)
+		respondJSON(c, http.StatusPreconditionRequired, DataDeletionPlan{
+			ErrorResponse:     newErrorResponse(c, http.StatusPreconditionRequired, "confirmation required (resend with ?confirm=<confirmation_token>)"),
+			User:              query.User,
+			Scope:             scope,
+			Counts:            counts,
+			ConfirmationToken: signDataDeletion(query.User, expiresAt),
+			ExpiresAt:         expiresAt,
+		})
+		return
+	}
+	if !verifyDataDeletion(query.User, query.Confirm, now) {
+		respondValidationError(c, []FieldError{{Field: "confirm", Rule: "token", Message: "invalid or expired confirmation token"}})
+		return
+	}
+
+	/* 実行中のバックフィルがチェックポイントとアーカイブを書き戻さないよう、終わるまで削除しない */
+	if scope == dataScopeWorkspace && backfill.status().Running {
+		respondError(c, http.StatusConflict, "backfill is already running")
+		return
+	}
+
+	var deleted map[string]int
+	var err error
+	if scope == dataScopeWorkspace {
+		deleted, err = deleteWorkspaceData(now)
+	} else {
+		deleted = deleteViewerData(map[string]bool{query.User: true})
+	}
+	/* 途中で失敗した場合も、削除した分は監査ログに残す */
+	entry := auditLog.record(c, auditActionDataDelete, query.User, deleted)
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("Failed to delete data")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Info().Str("scope", scope).Str("audit_id", entry.ID).Msg("Data deleted")
+	respondJSON(c, http.StatusOK, DataDeletionResult{User: query.User, Scope: scope, Deleted: deleted, AuditID: entry.ID})
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* デプロイの取得元 */
+const (
+	deploymentSourceDeployments = "deployments" // GitHubのDeployments API
+	deploymentSourceReleases    = "releases"    // リリース（Deploymentsがないリポジトリ）
+	deploymentSourceTags        = "tags"        // タグ（Deployments・リリースがないリポジトリ）
+	deploymentSourceNone        = "none"        // デプロイを記録していない
+)
+
+const (
+	/* defaultDeploymentWeeks は集計する週数のデフォルト */
+	defaultDeploymentWeeks = 12
+	/* deploymentEnvironmentRelease, deploymentEnvironmentTag はリリース・タグから数えたデプロイの環境名 */
+	deploymentEnvironmentRelease = "release"
+	deploymentEnvironmentTag     = "tag"
+)
+
+/*
+Deployment はGitHub APIから取得するデプロイを表す構造体
+API仕様: https://docs.github.com/ja/rest/deployments/deployments#list-deployments
+*/
+type Deployment struct {
+	ID          int64     `json:"id"`          // デプロイID
+	SHA         string    `json:"sha"`         // デプロイしたコミット
+	Ref         string    `json:"ref"`         // デプロイしたブランチ・タグ
+	Environment string    `json:"environment"` // 環境名（例: "production"）
+	CreatedAt   time.Time `json:"created_at"`  // 作成日時
+}
+
+/*
+Tag はGitHub APIから取得するタグを表す構造体
+API仕様: https://docs.github.com/ja/rest/repos/repos#list-repository-tags
+*/
+type Tag struct {
+	Name   string `json:"name"` // タグ名
+	Commit struct {
+		SHA string `json:"sha"` // タグのコミット
+	} `json:"commit"`
+}
+
+/* deploymentParams は /api/stats/deployments のクエリパラメータ */
+type deploymentParams struct {
+	Weeks int    `form:"weeks" binding:"min=1,max=52"`
+	Repo  string `form:"repo"`
+}
+
+/* deploymentEvent は集計に使用する1件のデプロイ */
+type deploymentEvent struct {
+	environment string
+	at          time.Time
+}
+
+/* DeploymentFrequency はデプロイの回数と頻度 */
+type DeploymentFrequency struct {
+	Deployments  int            `json:"deployments"`  // デプロイの回数
+	PerWeek      float64        `json:"per_week"`     // 1週間あたりの回数（集計した週数の平均）
+	Environments map[string]int `json:"environments"` // 環境ごとの回数
+}
+
+/* RepositoryDeployments はリポジトリごとのデプロイの頻度 */
+type RepositoryDeployments struct {
+	Repo   string `json:"repo"`   // リポジトリ名
+	Source string `json:"source"` // デプロイの取得元（deployments, releases, tags）
+	DeploymentFrequency
+}
+
+/* WeeklyDeployments は1週間分のデプロイの回数 */
+type WeeklyDeployments struct {
+	Week         string         `json:"week"`         // ISO 8601の週（"2025-W30"形式）
+	Deployments  int            `json:"deployments"`  // デプロイの回数
+	Environments map[string]int `json:"environments"` // 環境ごとの回数
+}
+
+/* DeploymentStatsResponse は /api/stats/deployments のレスポンス */
+type DeploymentStatsResponse struct {
+	GeneratedAt        time.Time               `json:"generated_at"`                  // 集計した日時
+	Since              time.Time               `json:"since"`                         // 集計の期間の開始（最初の週の月曜日0時）
+	Total              DeploymentFrequency     `json:"total"`                         // 全リポジトリ
+	Weekly             []WeeklyDeployments     `json:"weekly"`                        // 週ごとの推移（古い順、今週を含む）
+	Repositories       []RepositoryDeployments `json:"repositories"`                  // リポジトリごと（リポジトリ名順、デプロイのないリポジトリは含めない）
+	FailedRepositories []string                `json:"failed_repositories,omitempty"` // デプロイを取得できなかったリポジトリ
+}
+
+/*
+fetchDeployments は指定されたリポジトリのデプロイ（最大100件）を取得する
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+*/
+func fetchDeployments(repoFullName string) ([]Deployment, error) {
+	var deployments []Deployment
+	url := fmt.Sprintf("%s/repos/%s/deployments?per_page=100", githubAPIBase, repoFullName)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, "", &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+/*
+fetchTags は指定されたリポジトリのタグ（最大100件）を取得する
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+*/
+func fetchTags(repoFullName string) ([]Tag, error) {
+	var tags []Tag
+	url := fmt.Sprintf("%s/repos/%s/tags?per_page=100", githubAPIBase, repoFullName)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, "", &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+/*
+repositoryDeployments はリポジトリのデプロイを取得する
+Deployments APIにデプロイがない場合はリリース、リリースもない場合はタグで代用する
+
+戻り値:
+  string - デプロイの取得元（deploymentSource* 定数）
+  []deploymentEvent - デプロイ（期間で絞り込む前）
+  error - 取得に失敗した場合のエラー
+
+注意:
+  - リリースはドラフトを除き、公開日時で数える（環境名は "release"）
+  - タグは日時を持たないため、タグのコミットが最新100件のコミットに含まれる場合のみ、コミット日時で数える（環境名は "tag"）
+*/
+func repositoryDeployments(repo Repository) (string, []deploymentEvent, error) {
+	deployments, err := fetchDeployments(repo.FullName)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(deployments) > 0 {
+		events := make([]deploymentEvent, 0, len(deployments))
+		for _, d := range deployments {
+			events = append(events, deploymentEvent{environment: d.Environment, at: d.CreatedAt})
+		}
+		return deploymentSourceDeployments, events, nil
+	}
+
+	releases, err := fetchReleases(repo.FullName)
+	if err != nil {
+		return "", nil, err
+	}
+	var events []deploymentEvent
+	for _, release := range releases {
+		if release.Draft || release.PublishedAt == nil {
+			continue
+		}
+		events = append(events, deploymentEvent{environment: deploymentEnvironmentRelease, at: *release.PublishedAt})
+	}
+	if len(events) > 0 {
+		return deploymentSourceReleases, events, nil
+	}
+
+	tags, err := fetchTags(repo.FullName)
+	if err != nil || len(tags) == 0 {
+		return deploymentSourceNone, nil, err
+	}
+	commits, err := fetchCommits(repo.FullName)
+	if err != nil {
+		return "", nil, err
+	}
+	committedAt := make(map[string]time.Time, len(commits))
+	for _, commit := range commits {
+		committedAt[commit.SHA] = commit.Commit.Author.Date
+	}
+	for _, tag := range tags {
+		if at, ok := committedAt[tag.Commit.SHA]; ok {
+			events = append(events, deploymentEvent{environment: deploymentEnvironmentTag, at: at})
+		}
+	}
+	return deploymentSourceTags, events, nil
+}
+
+/* finishPerWeek は集計した週数から1週間あたりの回数を計算する（小数第2位に丸める） */
+func (f *DeploymentFrequency) finishPerWeek(weeks int) {
+	f.PerWeek = math.Round(float64(f.Deployments)/float64(weeks)*100) / 100
+}
+
+/*
+getDeploymentStats はデプロイの頻度を全体・リポジトリごと・週ごと（STATS_TIMEZONE基準のISO 8601の週）に返すAPIハンドラー
+GitHubのDeploymentsを記録していないリポジトリは、リリース・タグをデプロイとみなす
+
+クエリパラメータ:
+  weeks int - 集計する週数（1〜52、デフォルト: 12、今週を含む）
+  repo string - リポジトリ名で絞り込む
+
+レスポンス:
+  成功時: 200 OK, DeploymentStatsResponse
+  失敗時: 422 Unprocessable Entity（不正なweeks）, 502 Bad Gateway（GitHubからリポジトリ一覧を取得できない）
+
+注意:
+  - GitHub APIから取得できるのは1リポジトリあたり最新100件までのため、デプロイの多いリポジトリでは古い週の一部が欠けることがある
+  - デプロイを取得できなかったリポジトリは failed_repositories に含め、集計から除く
+*/
+func getDeploymentStats(c *gin.Context) {
+	params := deploymentParams{Weeks: defaultDeploymentWeeks}
+	if !bindQuery(c, &params) {
+		return
+	}
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for deployment stats")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if name := params.Repo; name != "" {
+		var filtered []Repository
+		for _, repo := range repos {
+			if repo.Name == name {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+
+	sources := make([]string, len(repos))
+	events := make([][]deploymentEvent, len(repos))
+	errs := make([]error, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		sources[i], events[i], errs[i] = repositoryDeployments(repos[i])
+	})
+
+	now := time.Now().In(statsLocation)
+	since := weekStart(now).AddDate(0, 0, -7*(params.Weeks-1))
+	resp := DeploymentStatsResponse{
+		GeneratedAt:  now,
+		Since:        since,
+		Total:        DeploymentFrequency{Environments: map[string]int{}},
+		Weekly:       make([]WeeklyDeployments, params.Weeks),
+		Repositories: []RepositoryDeployments{},
+	}
+	index := map[string]int{}
+	for i := range resp.Weekly {
+		week := isoWeekString(since.AddDate(0, 0, 7*i))
+		resp.Weekly[i] = WeeklyDeployments{Week: week, Environments: map[string]int{}}
+		index[week] = i
+	}
+
+	for i, repo := range repos {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Str("repository", repo.Name).Msg("Failed to fetch deployments for deployment stats")
+			resp.FailedRepositories = append(resp.FailedRepositories, repo.Name)
+			continue
+		}
+		stats := RepositoryDeployments{Repo: repo.Name, Source: sources[i], DeploymentFrequency: DeploymentFrequency{Environments: map[string]int{}}}
+		for _, event := range events[i] {
+			if event.at.Before(since) || event.at.After(now) {
+				continue
+			}
+			w, ok := index[isoWeekString(event.at.In(statsLocation))]
+			if !ok {
+				continue
+			}
+			stats.Deployments++
+			stats.Environments[event.environment]++
+			resp.Total.Deployments++
+			resp.Total.Environments[event.environment]++
+			resp.Weekly[w].Deployments++
+			resp.Weekly[w].Environments[event.environment]++
+		}
+		if stats.Deployments == 0 {
+			continue
+		}
+		stats.finishPerWeek(params.Weeks)
+		resp.Repositories = append(resp.Repositories, stats)
+	}
+
+	resp.Total.finishPerWeek(params.Weeks)
+	sort.Slice(resp.Repositories, func(i, j int) bool { return resp.Repositories[i].Repo < resp.Repositories[j].Repo })
+	respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+非推奨のルートの利用状況
+DEPRECATED_ROUTES で非推奨にしたルートへのリクエストにDeprecation・Sunset・Warningヘッダーを付け、
+ルートごとのリクエスト数・最後に使われた日時・クライアント（User-Agent）を記録する
+GET /api/admin/deprecations で、古いルートを削除してよいか（まだ使われていないか）を確認できる
+*/
+
+const (
+	/* deprecationsTable はルートごとの利用状況を保存するテーブル名 */
+	deprecationsTable = "deprecations"
+	/* deprecationMaxClients はルートごとに記録するクライアントの数（超えた分は "other" にまとめる） */
+	deprecationMaxClients = 20
+	/* deprecationSaveInterval は利用状況をテーブルに保存する最短の間隔（リクエストごとに書き込まない） */
+	deprecationSaveInterval = time.Minute
+)
+
+/*
+deprecatedRoute は非推奨にしたルートの設定
+*/
+type deprecatedRoute struct {
+	Sunset      time.Time // 削除する予定の日時（ゼロ値の場合は未定）
+	Replacement string    // 代わりに使うルート（空文字の場合はなし）
+}
+
+/*
+deprecatedRoutes は非推奨にしたルート（BASE_PATHを除くルートのパターン）
+環境変数 DEPRECATED_ROUTES で設定する。parseDeprecatedRoutesを参照
+*/
+var deprecatedRoutes = parseDeprecatedRoutes(getEnv("DEPRECATED_ROUTES", ""))
+
+var deprecatedRequestsTotal = newCounterVec(
+	"giter_deprecated_requests_total",
+	"Requests to routes marked as deprecated by DEPRECATED_ROUTES, by route.",
+	"route",
+)
+
+/*
+parseDeprecatedRoutes は改行区切りの非推奨のルートを読み込む
+各行は "ルート 項目=値 ..." の形式
+  - sunset=2026-06-30: 削除する予定の日付（YYYY-MM-DD、UTC）またはRFC3339の日時
+  - replacement=/api/git-history/poll: 代わりに使うルート
+
+例:
+  DEPRECATED_ROUTES="/api/git-history/new sunset=2026-06-30 replacement=/api/git-history/poll
+  /api/repositories"
+
+注意:
+  - 解釈できない項目は警告を出力して無視する
+*/
+func parseDeprecatedRoutes(raw string) map[string]deprecatedRoute {
+	routes := map[string]deprecatedRoute{}
+	for _, line := range strings.Split(raw, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		route, dep := fields[0], deprecatedRoute{}
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(field, "=")
+			var err error
+			switch name {
+			case "sunset":
+				if dep.Sunset, err = time.Parse("2006-01-02", value); err != nil {
+					dep.Sunset, err = time.Parse(time.RFC3339, value)
+				}
+			case "replacement":
+				dep.Replacement = value
+			default:
+				err = fmt.Errorf("unknown field: %s", name)
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("route", route).Str("field", field).Msg("Ignoring invalid DEPRECATED_ROUTES entry")
+			}
+		}
+		routes[route] = dep
+	}
+	return routes
+}
+
+/*
+DeprecationUsage は非推奨のルートの利用状況
+*/
+type DeprecationUsage struct {
+	Requests    int            `json:"requests"`      // 記録を始めてからのリクエスト数
+	FirstUsedAt *time.Time     `json:"first_used_at"` // 最初に使われた日時（未使用の場合はnull）
+	LastUsedAt  *time.Time     `json:"last_used_at"`  // 最後に使われた日時（未使用の場合はnull）
+	Clients     map[string]int `json:"clients"`       // User-Agentごとのリクエスト数（最大deprecationMaxClients件、超えた分は "other"）
+}
+
+/*
+deprecationStore はルートごとの利用状況を保持するストア
+deprecationsテーブルに永続化される（保存はdeprecationSaveIntervalごと）
+*/
+type deprecationStore struct {
+	mu      sync.Mutex
+	Usage   map[string]*DeprecationUsage `json:"usage"` // ルートごとの利用状況
+	savedAt time.Time                    // 最後にテーブルに保存した日時
+}
+
+/* deprecations はアプリケーション全体で共有する非推奨のルートの利用状況 */
+var deprecations = &deprecationStore{Usage: map[string]*DeprecationUsage{}}
+
+func init() {
+	registerTable(deprecationsTable, loadDeprecations)
+}
+
+/*
+loadDeprecations はdeprecationsテーブルから利用状況を復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadDeprecations() error {
+	deprecations.mu.Lock()
+	defer deprecations.mu.Unlock()
+
+	deprecations.Usage = map[string]*DeprecationUsage{}
+	if err := loadTable(deprecationsTable, deprecations); err != nil {
+		return err
+	}
+	if deprecations.Usage == nil {
+		deprecations.Usage = map[string]*DeprecationUsage{}
+	}
+	return nil
+}
+
+/* record はルートへのリクエストを記録する（前回の保存からdeprecationSaveInterval以上経過した場合はテーブルに保存する） */
+func (s *deprecationStore) record(route, userAgent string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage, ok := s.Usage[route]
+	if !ok {
+		usage = &DeprecationUsage{Clients: map[string]int{}, FirstUsedAt: &now}
+		s.Usage[route] = usage
+	}
+	usage.Requests++
+	usage.LastUsedAt = &now
+	if userAgent == "" {
+		userAgent = "unknown"
+	}
+	if _, known := usage.Clients[userAgent]; !known && len(usage.Clients) >= deprecationMaxClients {
+		userAgent = "other"
+	}
+	usage.Clients[userAgent]++
+
+	if now.Sub(s.savedAt) >= deprecationSaveInterval {
+		s.savedAt = now
+		if err := saveTable(deprecationsTable, s); err != nil {
+			log.Error().Err(err).Msg("Failed to save deprecation usage")
+		}
+	}
+}
+
+/*
+deprecationMiddleware は非推奨のルートへのリクエストに警告のヘッダーを付け、利用状況を記録するミドルウェア
+  - Deprecation: true
+  - Sunset: 削除する予定の日時（RFC 8594、設定した場合のみ）
+  - Warning: 299 - "..."（代わりに使うルートを含む）
+
+注意:
+  - responseCacheMiddlewareの前に登録し、保持したレスポンスを返す場合も記録する
+*/
+func deprecationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := strings.TrimPrefix(c.FullPath(), basePath)
+		dep, ok := deprecatedRoutes[route]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		c.Header("Deprecation", "true")
+		warning := fmt.Sprintf("%s is deprecated", route)
+		if !dep.Sunset.IsZero() {
+			c.Header("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+			warning += fmt.Sprintf(" and will be removed after %s", dep.Sunset.UTC().Format("2006-01-02"))
+		}
+		if dep.Replacement != "" {
+			warning += fmt.Sprintf("; use %s instead", dep.Replacement)
+		}
+		c.Header("Warning", fmt.Sprintf("299 - %q", warning))
+
+		deprecatedRequestsTotal.Inc(route)
+		deprecations.record(route, c.Request.UserAgent(), time.Now().UTC())
+		c.Next()
+	}
+}
+
+/*
+DeprecatedRouteStatus は /api/admin/deprecations で返す非推奨のルートごとの状態
+*/
+type DeprecatedRouteStatus struct {
+	Route       string           `json:"route"`       // ルートのパターン
+	Sunset      *time.Time       `json:"sunset"`      // 削除する予定の日時（未定の場合はnull）
+	Replacement string           `json:"replacement"` // 代わりに使うルート
+	Usage       DeprecationUsage `json:"usage"`       // 利用状況
+}
+
+/*
+getDeprecations は非推奨のルートごとの設定と利用状況を返す管理者APIハンドラー
+last_used_atが十分に古いルートは、削除しても利用者に影響しない目安になる
+
+レスポンス:
+  200 OK, {"routes": []DeprecatedRouteStatus（ルート順）}
+
+注意:
+  - DEPRECATED_ROUTESから外したルートの利用状況は返さない（テーブルには残る）
+  - 利用状況はインスタンスごとに記録する。複数レプリカの合計は giter_deprecated_requests_total で確認する
+*/
+func getDeprecations(c *gin.Context) {
+	deprecations.mu.Lock()
+	routes := make([]DeprecatedRouteStatus, 0, len(deprecatedRoutes))
+	for route, dep := range deprecatedRoutes {
+		status := DeprecatedRouteStatus{Route: route, Replacement: dep.Replacement, Usage: DeprecationUsage{Clients: map[string]int{}}}
+		if !dep.Sunset.IsZero() {
+			sunset := dep.Sunset
+			status.Sunset = &sunset
+		}
+		if usage, ok := deprecations.Usage[route]; ok {
+			status.Usage = *usage
+			status.Usage.Clients = make(map[string]int, len(usage.Clients))
+			for client, n := range usage.Clients {
+				status.Usage.Clients[client] = n
+			}
+		}
+		routes = append(routes, status)
+	}
+	deprecations.mu.Unlock()
+
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	respondJSON(c, http.StatusOK, gin.H{"routes": routes})
+}
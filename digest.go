@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* digestTopRepos はダイジェストに含めるコミットの多いリポジトリの件数 */
+const digestTopRepos = 5
+
+/* isoWeekPattern はISO 8601の週の形式（例: "2025-W30"） */
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+/* errInvalidWeek はweekパラメータがISO 8601の週の形式でない場合のエラー */
+var errInvalidWeek = errors.New("invalid week (expected YYYY-Www, e.g. 2025-W30)")
+
+/* RepoCommitCount はリポジトリごとのコミット数 */
+type RepoCommitCount struct {
+	Repo    string `json:"repo"`    // リポジトリ名
+	Commits int    `json:"commits"` // コミット数
+}
+
+/* DailyCommitCount は日ごとのコミット数 */
+type DailyCommitCount struct {
+	Date    string `json:"date"`    // 日付（"2006-01-02"形式）
+	Commits int    `json:"commits"` // コミット数
+}
+
+/* DigestPullRequest はダイジェストの期間にマージされたプルリクエスト */
+type DigestPullRequest struct {
+	Repo     string    `json:"repo"`      // リポジトリ名
+	Number   int       `json:"number"`    // PR番号
+	Title    string    `json:"title"`     // タイトル
+	URL      string    `json:"url"`       // GitHubのPRページURL
+	MergedAt time.Time `json:"merged_at"` // マージ日時
+}
+
+/* DigestStar はダイジェストの期間に付いたスター */
+type DigestStar struct {
+	Repo      string    `json:"repo"`       // リポジトリ名
+	User      string    `json:"user"`       // スターしたユーザー
+	StarredAt time.Time `json:"starred_at"` // スターされた日時
+}
+
+/*
+WeeklyDigest は1週間（ISO 8601の週、月曜日始まり）の活動のまとめ
+*/
+type WeeklyDigest struct {
+	Week               string              `json:"week"`                 // 週（"2025-W30"形式）
+	Start              time.Time           `json:"start"`                // 週の開始日時（月曜日0時、STATS_TIMEZONE）
+	End                time.Time           `json:"end"`                  // 週の終了日時（翌週の月曜日0時）
+	Timezone           string              `json:"timezone"`             // 集計に使用したタイムゾーン
+	Commits            int                 `json:"commits"`              // コミット数
+	ActiveDays         int                 `json:"active_days"`          // コミットのあった日数
+	Daily              []DailyCommitCount  `json:"daily"`                // 日ごとのコミット数（月曜日から7日分）
+	TopRepositories    []RepoCommitCount   `json:"top_repositories"`     // コミットの多いリポジトリ（最大5件）
+	PullRequestsMerged []DigestPullRequest `json:"pull_requests_merged"` // マージされたプルリクエスト（新しい順）
+	NewStars           []DigestStar        `json:"new_stars"`            // 付いたスター（新しい順）
+}
+
+/*
+parseISOWeek はISO 8601の週（"2025-W30"）をその週の月曜日0時に変換する
+
+引数:
+  s string - 週の文字列
+  loc *time.Location - 週の区切りに使用するタイムゾーン
+
+戻り値:
+  time.Time - 週の開始日時
+  error - 形式が不正、または存在しない週番号の場合はerrInvalidWeek
+*/
+func parseISOWeek(s string, loc *time.Location) (time.Time, error) {
+	m := isoWeekPattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, errInvalidWeek
+	}
+	year, _ := strconv.Atoi(m[1])
+	week, _ := strconv.Atoi(m[2])
+
+	/* 1月4日を含む週が第1週（ISO 8601） */
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	start := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+	if y, w := start.ISOWeek(); week < 1 || y != year || w != week {
+		return time.Time{}, errInvalidWeek
+	}
+	return start, nil
+}
+
+/* weekStart はtを含むISO 8601の週の月曜日0時を返す */
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+/* isoWeekString はtを含むISO 8601の週を "2025-W30" 形式で返す */
+func isoWeekString(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+/* digestParams は /api/digest のクエリパラメータ */
+type digestParams struct {
+	Week string `form:"week" binding:"omitempty,isoweek"`
+}
+
+/*
+digestWeek はISO 8601の週（例: "2025-W30"）の開始日時を返す
+空文字の場合は前週（集計の終わった直近の週）
+*/
+func digestWeek(raw string) (time.Time, error) {
+	if raw != "" {
+		return parseISOWeek(raw, statsLocation)
+	}
+	return weekStart(time.Now().In(statsLocation)).AddDate(0, 0, -7), nil
+}
+
+/* digestWeekParam はクエリパラメータweekから対象の週の開始日時を返す */
+func digestWeekParam(c *gin.Context) (time.Time, error) {
+	return digestWeek(c.Query("week"))
+}
+
+/*
+buildWeeklyDigest は指定した週のダイジェストを作成する
+コミットはfetchCommitHistory、プルリクエストとスターはリポジトリごとにGitHub APIから取得する
+
+注意:
+  - いずれも1リポジトリあたり最新100件までのため、古い週ほど一部が欠けることがある
+  - プルリクエスト・スターの取得に失敗したリポジトリはログ出力のみで除外する
+  - vがvisibilityPublicの場合、プライベートリポジトリは含めない
+*/
+func buildWeeklyDigest(start time.Time, v visibility) (WeeklyDigest, error) {
+	end := start.AddDate(0, 0, 7)
+	digest := WeeklyDigest{
+		Week:               isoWeekString(start),
+		Start:              start,
+		End:                end,
+		Timezone:           statsLocation.String(),
+		Daily:              []DailyCommitCount{},
+		TopRepositories:    []RepoCommitCount{},
+		PullRequestsMerged: []DigestPullRequest{},
+		NewStars:           []DigestStar{},
+	}
+
+	commits, repos, err := fetchVisibleCommitHistory(v)
+	if err != nil {
+		return digest, err
+	}
+
+	perRepo := map[string]int{}
+	var inWeek []CommitHistory
+	for _, commit := range commits {
+		if commit.CommitTime.Before(start) || !commit.CommitTime.Before(end) {
+			continue
+		}
+		inWeek = append(inWeek, commit)
+		perRepo[commit.RepositoryName]++
+	}
+	digest.Commits = len(inWeek)
+
+	counts := dailyCommitCounts(inWeek)
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format(statsDateFormat)
+		digest.Daily = append(digest.Daily, DailyCommitCount{Date: date, Commits: counts[date]})
+		if counts[date] > 0 {
+			digest.ActiveDays++
+		}
+	}
+
+	for repo, n := range perRepo {
+		digest.TopRepositories = append(digest.TopRepositories, RepoCommitCount{Repo: repo, Commits: n})
+	}
+	sort.Slice(digest.TopRepositories, func(i, j int) bool {
+		a, b := digest.TopRepositories[i], digest.TopRepositories[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Repo < b.Repo
+	})
+	if len(digest.TopRepositories) > digestTopRepos {
+		digest.TopRepositories = digest.TopRepositories[:digestTopRepos]
+	}
+
+	pulls := make([][]DigestPullRequest, len(repos))
+	stars := make([][]DigestStar, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		repo := repos[i]
+		if list, err := fetchPullRequests(repo.FullName); err == nil {
+			for _, pr := range list {
+				if pr.MergedAt != nil && !pr.MergedAt.Before(start) && pr.MergedAt.Before(end) {
+					pulls[i] = append(pulls[i], DigestPullRequest{Repo: repo.Name, Number: pr.Number, Title: pr.Title, URL: pr.HTMLURL, MergedAt: *pr.MergedAt})
+				}
+			}
+		} else {
+			log.Warn().Err(err).Str("repository", repo.Name).Msg("Failed to fetch pull requests for digest")
+		}
+		if list, err := fetchStargazers(repo.FullName); err == nil {
+			for _, star := range list {
+				if !star.StarredAt.Before(start) && star.StarredAt.Before(end) {
+					stars[i] = append(stars[i], DigestStar{Repo: repo.Name, User: star.User.Login, StarredAt: star.StarredAt})
+				}
+			}
+		} else {
+			log.Warn().Err(err).Str("repository", repo.Name).Msg("Failed to fetch stargazers for digest")
+		}
+	})
+	for i := range repos {
+		digest.PullRequestsMerged = append(digest.PullRequestsMerged, pulls[i]...)
+		digest.NewStars = append(digest.NewStars, stars[i]...)
+	}
+	sort.Slice(digest.PullRequestsMerged, func(i, j int) bool {
+		return digest.PullRequestsMerged[i].MergedAt.After(digest.PullRequestsMerged[j].MergedAt)
+	})
+	sort.Slice(digest.NewStars, func(i, j int) bool {
+		return digest.NewStars[i].StarredAt.After(digest.NewStars[j].StarredAt)
+	})
+	return digest, nil
+}
+
+/*
+getDigest は1週間の活動のまとめを返すAPIハンドラー
+
+クエリパラメータ:
+  week string - 対象の週（ISO 8601、例: "2025-W30"、省略時は前週）
+
+レスポンス:
+  成功時: 200 OK, WeeklyDigest
+  失敗時: 422 Unprocessable Entity（不正なweek）, 502 Bad Gateway（GitHubから取得できない）
+*/
+func getDigest(c *gin.Context) {
+	var params digestParams
+	if !bindQuery(c, &params) {
+		return
+	}
+	/* bindingのisoweekルールで読み込めることを確認済み */
+	start, _ := digestWeek(params.Week)
+	digest, err := buildWeeklyDigest(start, requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build weekly digest")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	respondJSON(c, http.StatusOK, digest)
+}
+
+/*
+showWeeklyDigestPage は1週間の活動のまとめのページ（/digest/weekly）を表示するハンドラー
+/api/digest と同じ内容をサーバーでレンダリングするため、共有やメールへの貼り付けに使用できる
+
+クエリパラメータ:
+  week string - 対象の週（ISO 8601、省略時は前週）
+
+レスポンス:
+  成功時: 200 OK, digest.html
+  失敗時: 400 Bad Request / 502 Bad Gateway（エラーメッセージを表示したdigest.html）
+*/
+func showWeeklyDigestPage(c *gin.Context) {
+	req := webRequest(c)
+	start, err := digestWeekParam(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "digest.html", web.NewDigestErrorPage(siteInfo(), req, localize(c, err.Error(), nil)))
+		return
+	}
+	digest, err := buildWeeklyDigest(start, requestVisibility(c))
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to render weekly digest page")
+		c.HTML(http.StatusBadGateway, "digest.html", web.NewDigestErrorPage(siteInfo(), req, localize(c, "failed to fetch commit history from GitHub", nil)))
+		return
+	}
+
+	summary := web.DigestSummary{
+		Week:       digest.Week,
+		PrevWeek:   isoWeekString(start.AddDate(0, 0, -7)),
+		Start:      digest.Start,
+		End:        digest.End.AddDate(0, 0, -1),
+		Commits:    digest.Commits,
+		ActiveDays: digest.ActiveDays,
+	}
+	/* 翌週が未来でなければ「次の週」へのリンクを表示する */
+	if next := start.AddDate(0, 0, 7); !next.After(time.Now()) {
+		summary.NextWeek = isoWeekString(next)
+	}
+
+	days := make([]web.DigestDay, 0, len(digest.Daily))
+	for i, d := range digest.Daily {
+		days = append(days, web.DigestDay{Date: start.AddDate(0, 0, i), Commits: d.Commits})
+	}
+	repos := make([]web.DigestRepo, 0, len(digest.TopRepositories))
+	for _, r := range digest.TopRepositories {
+		repos = append(repos, web.DigestRepo{Name: r.Repo, Commits: r.Commits})
+	}
+	pulls := make([]web.DigestPull, 0, len(digest.PullRequestsMerged))
+	for _, pr := range digest.PullRequestsMerged {
+		pulls = append(pulls, web.DigestPull{Repo: pr.Repo, Number: pr.Number, Title: pr.Title, URL: pr.URL, MergedAt: pr.MergedAt})
+	}
+	stars := make([]web.DigestStar, 0, len(digest.NewStars))
+	for _, s := range digest.NewStars {
+		stars = append(stars, web.DigestStar{Repo: s.Repo, User: s.User, StarredAt: s.StarredAt})
+	}
+	c.HTML(http.StatusOK, "digest.html", web.NewDigestPage(siteInfo(), req, summary, days, repos, pulls, stars))
+}
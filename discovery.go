@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* knownReposTable は前回の検出時のリポジトリ一覧を保存するテーブル名 */
+	knownReposTable = "known_repositories"
+)
+
+/*
+repoDiscoveryInterval は新しく作成されたリポジトリを検出する間隔
+環境変数 REPO_DISCOVERY_INTERVAL で変更可能（デフォルト: 15m、0の場合は検出しない）
+*/
+var repoDiscoveryInterval = parseDurationEnv("REPO_DISCOVERY_INTERVAL", 15*time.Minute)
+
+/*
+KnownRepositories は前回の検出時にGitHubのリポジトリ一覧に含まれていたリポジトリ
+*/
+type KnownRepositories struct {
+	Repositories map[string]time.Time `json:"repositories"` // リポジトリ名と最初に検出した日時
+	CheckedAt    *time.Time           `json:"checked_at"`   // 最後に検出した日時（未実行の場合はnull）
+}
+
+/*
+knownReposStore は前回の検出時のリポジトリ一覧を保持するストア
+known_repositoriesテーブルに永続化される
+*/
+type knownReposStore struct {
+	mu sync.Mutex
+	KnownRepositories
+}
+
+/* knownRepos はアプリケーション全体で共有する検出済みのリポジトリ一覧 */
+var knownRepos = &knownReposStore{KnownRepositories: KnownRepositories{Repositories: map[string]time.Time{}}}
+
+func init() {
+	registerTable(knownReposTable, loadKnownRepos)
+}
+
+/*
+loadKnownRepos はknown_repositoriesテーブルからストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadKnownRepos() error {
+	knownRepos.mu.Lock()
+	defer knownRepos.mu.Unlock()
+
+	knownRepos.KnownRepositories = KnownRepositories{}
+	if err := loadTable(knownReposTable, &knownRepos.KnownRepositories); err != nil {
+		return err
+	}
+	if knownRepos.Repositories == nil {
+		knownRepos.Repositories = map[string]time.Time{}
+	}
+	return nil
+}
+
+/*
+replace はリポジトリ一覧を現在の一覧に置き換えて保存し、前回の一覧になかったリポジトリを返す
+一覧から消えたリポジトリ（削除・名前の変更）は取り除くため、同じ名前で作り直した場合も新しいリポジトリとして検出する
+
+戻り値:
+  []Repository - 新しく検出したリポジトリ（名前順、初回の検出の場合は空）
+  error - 保存できない場合のエラー
+*/
+func (s *knownReposStore) replace(repos []Repository, now time.Time) ([]Repository, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	/* 初回は既存のリポジトリをすべて新しいものとして扱わないよう、一覧を記録するだけにする */
+	first := s.CheckedAt == nil
+	var created []Repository
+	current := make(map[string]time.Time, len(repos))
+	for _, repo := range repos {
+		if seen, ok := s.Repositories[repo.Name]; ok {
+			current[repo.Name] = seen
+			continue
+		}
+		current[repo.Name] = now
+		if !first {
+			created = append(created, repo)
+		}
+	}
+	sort.Slice(created, func(i, j int) bool { return created[i].Name < created[j].Name })
+
+	s.Repositories = current
+	s.CheckedAt = &now
+	return created, saveTable(knownReposTable, &s.KnownRepositories)
+}
+
+/*
+discoverRepositories はGitHubのリポジトリ一覧を前回の一覧と比較し、新しく作成されたリポジトリを同期の対象に加える
+新しいリポジトリごとに、追加（tracked_repositories）・全コミットのバックフィル・同期・new_repository通知を行う
+
+戻り値:
+  []Repository - 新しく検出したリポジトリ
+  error - リポジトリ一覧を取得・保存できない場合のエラー
+
+注意:
+  - 管理者APIで除外したリポジトリは、検出しても追加・通知しない
+  - バックフィルを実行中の場合（backfillロックを取得できない場合）はバックフィルを省略する（次回の POST /api/admin/backfill で取得する）
+*/
+func discoverRepositories() ([]Repository, error) {
+	repos, err := fetchRepositoriesAt(jobPriorityBackground)
+	if err != nil {
+		return nil, err
+	}
+	/* 他のインスタンスがロックを保持している間に検出した場合に、同じリポジトリを再び検出しないよう読み込み直す */
+	if err := loadKnownRepos(); err != nil {
+		return nil, err
+	}
+	created, err := knownRepos.replace(repos, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	discovered := make([]Repository, 0, len(created))
+	for _, repo := range created {
+		if repoTracking.isUntracked(repo.Name) {
+			continue
+		}
+		discovered = append(discovered, repo)
+		if err := repoTracking.set(repo.Name, true); err != nil {
+			log.Error().Err(err).Str("repository", repo.Name).Msg("Failed to save tracked repositories")
+		}
+		notify(notificationKindNewRepository, repo.Name,
+			"新しいリポジトリを検出しました",
+			fmt.Sprintf("%s を同期の対象に追加しました", repo.FullName))
+
+		backfillDiscoveredRepository(repo)
+		if _, err := syncTrackedRepository(repo); err != nil {
+			log.Warn().Err(err).Str("repository", repo.Name).Msg("Failed to sync discovered repository")
+		}
+	}
+	log.Info().Int("repositories", len(repos)).Int("discovered", len(discovered)).Msg("Repository discovery finished")
+	return discovered, nil
+}
+
+/*
+backfillDiscoveredRepository は新しく検出したリポジトリの全コミットをアーカイブに保存する
+未完了のバックフィルがある場合は、そのジョブのUntilでページを固定し、再開時にチェックポイントの位置がずれないようにする
+*/
+func backfillDiscoveredRepository(repo Repository) {
+	until := time.Now()
+	if status := backfill.status(); status.StartedAt != nil && status.FinishedAt == nil {
+		until = status.Until
+	}
+	if !runExclusive(lockNameBackfill, func() { backfillRepository(repo, until) }) {
+		log.Info().Str("repository", repo.Name).Msg("Backfill is running; skipped backfill of discovered repository")
+	}
+}
+
+/*
+startRepoDiscoveryScheduler はrepoDiscoveryIntervalごとに新しいリポジトリを検出するゴルーチンを起動する
+間隔が0の場合は何もしない
+*/
+func startRepoDiscoveryScheduler() {
+	if repoDiscoveryInterval <= 0 {
+		return
+	}
+	log.Info().Dur("interval", repoDiscoveryInterval).Msg("Repository discovery scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(repoDiscoveryInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if pausedForMaintenance(lockNameDiscovery) {
+				continue
+			}
+			/* 複数のレプリカで同じ通知を作成しないよう、ロックを取得した1つだけが検出する */
+			runExclusive(lockNameDiscovery, func() {
+				if _, err := discoverRepositories(); err != nil {
+					log.Error().Err(err).Msg("Repository discovery failed")
+				}
+			})
+		}
+	}()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+/* コミットの作成者のメールアドレスの扱い（EMAIL_PRIVACY） */
+const (
+	emailPrivacyMask = "mask" // ローカル部の先頭1文字以外を隠す（例: j***@example.com）
+	emailPrivacyHash = "hash" // SHA-256（16進数）に置き換える
+	emailPrivacyOff  = "off"  // GitHubから取得したまま返す
+)
+
+var (
+	/*
+		emailPrivacy はコミットの作成者のメールアドレスをサーバーの外に出す前の変換方法
+		環境変数 EMAIL_PRIVACY で変更可能（デフォルト: mask）
+	*/
+	emailPrivacy = loadEmailPrivacy()
+	/*
+		emailHashSalt はhashの場合にメールアドレスの前に付ける文字列
+		環境変数 EMAIL_HASH_SALT で設定する（未設定の場合、よく使われるアドレスは辞書攻撃で推測できる）
+	*/
+	emailHashSalt = getEnv("EMAIL_HASH_SALT", "")
+)
+
+/* loadEmailPrivacy はEMAIL_PRIVACYを読み込む（不正な値の場合はmask） */
+func loadEmailPrivacy() string {
+	mode := getEnv("EMAIL_PRIVACY", emailPrivacyMask)
+	switch mode {
+	case emailPrivacyMask, emailPrivacyHash, emailPrivacyOff:
+		return mode
+	default:
+		log.Warn().Str("value", mode).Msg("Invalid EMAIL_PRIVACY, using mask")
+		return emailPrivacyMask
+	}
+}
+
+/*
+privateEmail はEMAIL_PRIVACYに従ってメールアドレスを変換する
+
+例:
+  mask: "jane.doe@example.com" -> "j***@example.com"
+  hash: "Jane.Doe@example.com" -> "8c3f…"（小文字にしてからSHA-256、同じアドレスは同じ値になる）
+
+注意:
+  - 空文字はそのまま返す
+  - "@" を含まない値は、maskの場合はすべて "***" にする
+*/
+func privateEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+	switch emailPrivacy {
+	case emailPrivacyOff:
+		return email
+	case emailPrivacyHash:
+		sum := sha256.Sum256([]byte(emailHashSalt + strings.ToLower(strings.TrimSpace(email))))
+		return hex.EncodeToString(sum[:])
+	default:
+		local, domain, ok := strings.Cut(email, "@")
+		if !ok || local == "" {
+			return "***"
+		}
+		return local[:1] + "***@" + domain
+	}
+}
+
+/*
+privateJSONEmails はJSONの "email" キーの文字列の値をすべてprivateEmailで変換する
+GitHub APIのレスポンスをそのまま中継する /proxy/github で使用する
+
+戻り値:
+  []byte - 変換したJSON（JSONでない場合やEMAIL_PRIVACY=offの場合は元のまま）
+*/
+func privateJSONEmails(body []byte) []byte {
+	if emailPrivacy == emailPrivacyOff || !bytes.Contains(body, []byte(`"email"`)) {
+		return body
+	}
+	/* IDなどの大きな数値の精度を保つため、数値はjson.Numberのまま読み込む */
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	converted, err := json.Marshal(privateEmailValues(value))
+	if err != nil {
+		return body
+	}
+	return converted
+}
+
+/* privateEmailValues はJSONの値を再帰的にたどり、"email" キーの文字列を変換する */
+func privateEmailValues(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if s, ok := item.(string); ok && key == "email" {
+				v[key] = privateEmail(s)
+				continue
+			}
+			v[key] = privateEmailValues(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = privateEmailValues(item)
+		}
+	}
+	return value
+}
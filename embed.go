@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* defaultEmbedLimit はlimitを指定しない場合に埋め込み用のタイムラインに表示するコミット数 */
+const defaultEmbedLimit = 10
+
+/*
+embedAllowedOrigins は埋め込み用のタイムラインをiframeで表示できるサイトのオリジン
+環境変数 EMBED_ALLOWED_ORIGINS でカンマ区切りで指定する（例: "https://example.com,https://*.example.net"）
+未設定の場合は同じオリジンのみ。"*" を指定するとすべてのサイトに埋め込める
+*/
+var embedAllowedOrigins = splitList(getEnv("EMBED_ALLOWED_ORIGINS", ""))
+
+/* embedParams は /embed/timeline のクエリパラメータ */
+type embedParams struct {
+	Repo  string `form:"repo"`                            // 対象のリポジトリ名（省略時はすべてのリポジトリ）
+	Limit int    `form:"limit" binding:"min=1,max=50"`    // 表示するコミット数
+	Theme string `form:"theme" binding:"omitempty,theme"` // 埋め込むサイトに合わせたテーマ（省略時は閲覧者の表示設定）
+}
+
+/*
+embedFrameAncestors はContent-Security-Policyの frame-ancestors の値を返す
+'self' にEMBED_ALLOWED_ORIGINSのオリジンを加える
+*/
+func embedFrameAncestors() string {
+	return strings.Join(append([]string{"'self'"}, embedAllowedOrigins...), " ")
+}
+
+/*
+showEmbedTimeline は他のサイトのiframeに埋め込むための最近のコミットのタイムラインを表示するハンドラー
+
+クエリパラメータ:
+  repo string - 対象のリポジトリ名（省略時はすべてのリポジトリ）
+  limit int - 表示するコミット数（1〜50、デフォルト: 10）
+  theme string - テーマ（省略時は閲覧者の表示設定またはDEFAULT_THEME）
+
+レスポンス:
+  成功時: 200 OK, embed.html
+  失敗時: 422 Unprocessable Entity（不正なクエリパラメータ）, 404 Not Found（存在しないリポジトリ）, 502 Bad Gateway
+  （いずれもエラーメッセージを表示したembed.html）
+
+注意:
+  - Content-Security-Policy の frame-ancestors でiframeに表示できるサイトをEMBED_ALLOWED_ORIGINSに制限する
+  - iframeのリクエストは匿名の閲覧者として扱う（requestVisibility・REDACTION_RULESを適用する）
+*/
+func showEmbedTimeline(c *gin.Context) {
+	setContentSecurityPolicy(c, embedFrameAncestors())
+
+	req := webRequest(c)
+	dashboardURL := siteBaseURL(c) + appPath("/")
+	params := embedParams{Limit: defaultEmbedLimit}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.HTML(http.StatusUnprocessableEntity, "embed.html", web.NewEmbedErrorPage(siteInfo(), req, localize(c, validationFailedMessage, nil), dashboardURL))
+		return
+	}
+	if params.Theme != "" {
+		req.Theme = themeAssets(params.Theme)
+	}
+
+	commits, repos, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to render embedded timeline")
+		c.HTML(http.StatusBadGateway, "embed.html", web.NewEmbedErrorPage(siteInfo(), req, localize(c, "failed to fetch commit history from GitHub", nil), dashboardURL))
+		return
+	}
+	if params.Repo != "" {
+		if !containsString(repositoryNames(repos), params.Repo) {
+			c.HTML(http.StatusNotFound, "embed.html", web.NewEmbedErrorPage(siteInfo(), req, localize(c, errRepositoryNotFound.Error(), nil), dashboardURL))
+			return
+		}
+		commits = commitsForRepository(commits, params.Repo)
+	}
+
+	/* fetchCommitHistoryのコミットはリポジトリごとに並んでいるため、日時で新しい順に並べ替えてから件数を絞る */
+	commits = append([]CommitHistory{}, commits...)
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].CommitTime.After(commits[j].CommitTime) })
+	if len(commits) > params.Limit {
+		commits = commits[:params.Limit]
+	}
+
+	redact := requestRedaction(c)
+	items := make([]web.CommitItem, 0, len(commits))
+	for _, commit := range commits {
+		message := strings.SplitN(redact.message(commit.CommitMessage), "\n", 2)[0]
+		if params.Repo == "" {
+			message = commit.RepositoryName + ": " + message
+		}
+		items = append(items, web.CommitItem{SHA: commit.CommitSHA, Message: message, Time: commit.CommitTime, URL: commit.CommitURL})
+	}
+	c.HTML(http.StatusOK, "embed.html", web.NewEmbedPage(siteInfo(), req, params.Repo, items, dashboardURL))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+jsonErrorPathPrefixes はHTMLのエラーページではなく常にJSONのエラーレスポンスを返すパスの接頭辞
+APIクライアントがAcceptヘッダーを送らない場合でも共通形式（ErrorResponse）で返すため
+*/
+var jsonErrorPathPrefixes = []string{"/api/", proxyPathPrefix + "/", "/grafana/"}
+
+/*
+wantsJSONError はエラーをJSONで返すべきリクエストかどうかを返す
+/api/* と /proxy/github/*、/grafana/* は常にJSON、それ以外はAcceptヘッダーでHTMLよりJSONを優先する場合にJSON
+（Acceptヘッダーがない場合やブラウザからのリクエストはHTML）
+*/
+func wantsJSONError(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	for _, prefix := range jsonErrorPathPrefixes {
+		if strings.HasPrefix(path, appPath(prefix)) {
+			return true
+		}
+	}
+	return c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+}
+
+/*
+respondErrorPage はリクエストに応じてHTMLのエラーページ、またはJSONのエラーレスポンスを返す
+JSONの場合はrespondErrorと同じ共通形式（ErrorResponse）を使用する
+
+引数:
+  c *gin.Context - リクエストのコンテキスト
+  status int - HTTPステータスコード
+  message string - エラーメッセージ（英語、リクエストの言語に翻訳して表示する）
+*/
+func respondErrorPage(c *gin.Context, status int, message string) {
+	if wantsJSONError(c) {
+		respondError(c, status, message)
+		return
+	}
+	recordServerError(c, status, message)
+	c.HTML(status, "error.html", web.NewErrorPage(siteInfo(), webRequest(c), status, localize(c, message, nil), requestID(c)))
+}
+
+/*
+notFoundHandler はどのルートにも一致しないリクエストのハンドラー（r.NoRoute）
+Gin標準のテキストの "404 page not found" の代わりに、エラーページまたはJSONを返す
+*/
+func notFoundHandler(c *gin.Context) {
+	respondErrorPage(c, http.StatusNotFound, "page not found")
+}
+
+/*
+methodNotAllowedHandler はパスは一致するがメソッドが一致しないリクエストのハンドラー（r.NoMethod）
+r.HandleMethodNotAllowed を有効にした場合のみ呼び出される
+*/
+func methodNotAllowedHandler(c *gin.Context) {
+	respondErrorPage(c, http.StatusMethodNotAllowed, "method not allowed")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	/* requestIDHeader はリクエストIDを受け渡すヘッダー名 */
+	requestIDHeader = "X-Request-ID"
+	/* requestIDContextKey はgin.ContextにリクエストIDを保存する際のキー */
+	requestIDContextKey = "request_id"
+)
+
+/*
+ErrorResponse はすべてのAPIで共通のエラーレスポンスの形式
+従来の {"error": "メッセージ"} と互換性を保ったまま、機械的に判別できるコードと
+ログ・エラー報告と突き合わせるためのリクエストIDを追加している
+*/
+type ErrorResponse struct {
+	Error     string `json:"error"`                // エラーメッセージ
+	Code      string `json:"code"`                 // エラーの種類（例: "not_found", "internal_error"）
+	RequestID string `json:"request_id,omitempty"` // リクエストID（X-Request-IDヘッダーと同じ値）
+	/* Details は検証に失敗した項目ごとの理由（422 Unprocessable Entity の場合のみ） */
+	Details []FieldError `json:"details,omitempty"`
+}
+
+/*
+errorCode はHTTPステータスコードからエラーコードを作成する
+例: 404 -> "not_found", 500 -> "internal_error"
+*/
+func errorCode(status int) string {
+	if status == http.StatusInternalServerError {
+		return "internal_error"
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+/*
+newErrorResponse はリクエストに対応する共通形式のエラーレスポンスを作成する
+メッセージはリクエストの言語に翻訳する（カタログにない動的なメッセージはそのまま返す）
+
+引数:
+  c *gin.Context - リクエストのコンテキスト（リクエストIDの取得に使用）
+  status int - HTTPステータスコード
+  message string - エラーメッセージ
+*/
+func newErrorResponse(c *gin.Context, status int, message string) ErrorResponse {
+	return ErrorResponse{
+		Error:     localize(c, message, nil),
+		Code:      errorCode(status),
+		RequestID: requestID(c),
+	}
+}
+
+/*
+respondError は共通形式のエラーレスポンスを返す
+5xxの場合はc.Errorにも記録し、sentryMiddlewareなどのミドルウェアから参照できるようにする
+*/
+func respondError(c *gin.Context, status int, message string) {
+	recordServerError(c, status, message)
+	respondJSON(c, status, newErrorResponse(c, status, message))
+}
+
+/* abortWithError は後続のハンドラーを実行せずに共通形式のエラーレスポンスを返す（ミドルウェア用） */
+func abortWithError(c *gin.Context, status int, message string) {
+	recordServerError(c, status, message)
+	c.Abort()
+	respondJSON(c, status, newErrorResponse(c, status, message))
+}
+
+/* recordServerError は5xxのエラーメッセージをgin.Contextのエラー一覧に追加する */
+func recordServerError(c *gin.Context, status int, message string) {
+	if status >= http.StatusInternalServerError {
+		_ = c.Error(errors.New(message))
+	}
+}
+
+/*
+requestIDMiddleware はリクエストごとにリクエストIDを割り当てるミドルウェア
+クライアント（またはリバースプロキシ）がX-Request-IDを指定した場合はその値を引き継ぎ、
+なければ新しく生成する。レスポンスのX-Request-IDヘッダーにも同じ値を返す
+*/
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newSessionID()
+		}
+		c.Set(requestIDContextKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+/* requestID はリクエストIDを返す（requestIDMiddlewareを通過していない場合は空文字） */
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+/*
+isValidRequestID は外部から受け取ったリクエストIDをそのまま使用してよいかを返す
+ログへの埋め込みを安全にするため、英数字と - _ . のみ、64文字以内に制限する
+*/
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
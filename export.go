@@ -0,0 +1,373 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* exportFormatVersion はエクスポートアーカイブの形式のバージョン（互換性のない変更時に上げる） */
+	exportFormatVersion = 1
+	/* exportMetadataFile はアーカイブ内のメタデータファイル名 */
+	exportMetadataFile = "metadata.json"
+	/* exportTablesDir はアーカイブ内でテーブルを格納するディレクトリ名 */
+	exportTablesDir = "tables"
+	/* exportHistoryFile はアーカイブ内でHistoryStoreのコミット（アーカイブ・バックフィル）を格納するファイル名 */
+	exportHistoryFile = "history.json"
+	/* maxImportSize はインポートで受け付けるアーカイブの最大サイズ（100MB） */
+	maxImportSize = 100 << 20
+)
+
+/*
+ExportMetadata はエクスポートアーカイブに含めるメタデータ
+インポート時に形式のバージョンと含まれるテーブルの確認に使用する
+*/
+type ExportMetadata struct {
+	FormatVersion int       `json:"format_version"` // アーカイブ形式のバージョン
+	App           string    `json:"app"`            // アプリケーション名（"giter"）
+	Username      string    `json:"username"`       // 対象のGitHubユーザー名
+	ExportedAt    time.Time `json:"exported_at"`    // エクスポート日時
+	Tables        []string  `json:"tables"`         // 含まれるテーブル名
+	History       int       `json:"history"`        // 含まれるHistoryStoreのコミット数（復元時は追加したコミット数）
+}
+
+/*
+buildExportArchive は保存されているすべてのテーブルとHistoryStoreのコミットをzipアーカイブにまとめる
+アーカイブの構成:
+  metadata.json        - ExportMetadata
+  tables/<テーブル名>.json - 各テーブルのJSON（保存形式そのまま）
+  history.json         - HistoryStoreのコミット（CommitHistoryの配列、新しい順）
+
+戻り値:
+  []byte - zipアーカイブの内容
+  error - テーブル・HistoryStoreの読み込みまたはzip作成に失敗した場合のエラー
+
+注意:
+  - まだ一度も保存されていないテーブル（ファイルなし）は含めない
+*/
+func buildExportArchive() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	meta := ExportMetadata{
+		FormatVersion: exportFormatVersion,
+		App:           "giter",
+		Username:      username,
+		ExportedAt:    time.Now(),
+		Tables:        []string{},
+	}
+
+	for _, name := range tableNames() {
+		data, err := readTableFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read table %s: %w", name, err)
+		}
+		if data == nil {
+			continue
+		}
+		w, err := zw.Create(path.Join(exportTablesDir, name+".json"))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		meta.Tables = append(meta.Tables, name)
+	}
+
+	/* アーカイブ・バックフィルで移したコミットはテーブルではなくHistoryStoreにあるため、別のファイルに含める */
+	commits, err := history.LoadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if commits == nil {
+		commits = []CommitHistory{}
+	}
+	historyJSON, err := json.Marshal(commits)
+	if err != nil {
+		return nil, err
+	}
+	w, err := zw.Create(exportHistoryFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(historyJSON); err != nil {
+		return nil, err
+	}
+	meta.History = len(commits)
+
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	w, err = zw.Create(exportMetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(metaJSON); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+restoreExportArchive はzipアーカイブからテーブルとHistoryStoreのコミットを復元する
+メタデータとすべてのテーブル・コミットを検証してから書き込むため、
+不正なアーカイブで一部のテーブルだけが置き換わることはない
+
+引数:
+  data []byte - zipアーカイブの内容
+
+戻り値:
+  ExportMetadata - アーカイブのメタデータ（Tablesは実際に復元したテーブル名、HistoryはHistoryStoreに追加したコミット数）
+  error - アーカイブ形式が不正、バージョン非対応、未知のテーブル、JSONが不正な場合のエラー
+
+注意:
+  - アーカイブに含まれないテーブルは変更しない
+  - 書き込み後にloadTablesでメモリ上の状態を置き換える
+  - HistoryStoreのコミットは置き換えずに追加する（保存済みのコミットはリポジトリ名とSHAで重複を除く）
+  - history.jsonを含まない古いアーカイブでは、HistoryStoreは変更しない
+*/
+func restoreExportArchive(data []byte) (ExportMetadata, error) {
+	var meta ExportMetadata
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return meta, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	tables := map[string][]byte{}
+	var commits []CommitHistory
+	foundMeta := false
+	for _, f := range zr.File {
+		content, err := readZipFile(f)
+		if err != nil {
+			return meta, err
+		}
+		if f.Name == exportHistoryFile {
+			if err := json.Unmarshal(content, &commits); err != nil {
+				return meta, fmt.Errorf("invalid %s: %w", exportHistoryFile, err)
+			}
+			continue
+		}
+		if f.Name == exportMetadataFile {
+			if err := json.Unmarshal(content, &meta); err != nil {
+				return meta, fmt.Errorf("invalid metadata: %w", err)
+			}
+			foundMeta = true
+			continue
+		}
+		dir, file := path.Split(f.Name)
+		if dir != exportTablesDir+"/" || !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		tables[strings.TrimSuffix(file, ".json")] = content
+	}
+
+	if !foundMeta {
+		return meta, fmt.Errorf("archive has no %s", exportMetadataFile)
+	}
+	if meta.FormatVersion != exportFormatVersion {
+		return meta, fmt.Errorf("unsupported archive format version: %d", meta.FormatVersion)
+	}
+
+	/* 書き込む前にすべてのテーブルを検証する */
+	for name, content := range tables {
+		if _, ok := tableLoaders[name]; !ok {
+			return meta, fmt.Errorf("unknown table in archive: %s", name)
+		}
+		if !json.Valid(content) {
+			return meta, fmt.Errorf("table %s is not valid JSON", name)
+		}
+	}
+
+	/* メタデータの一覧ではなく、実際に書き込んだテーブルを返す */
+	meta.Tables = []string{}
+	for name, content := range tables {
+		if err := writeTableFile(name, content); err != nil {
+			return meta, err
+		}
+		meta.Tables = append(meta.Tables, name)
+	}
+	sort.Strings(meta.Tables)
+	if err := loadTables(); err != nil {
+		return meta, err
+	}
+
+	meta.History = 0
+	months := map[string][]CommitHistory{}
+	for _, commit := range commits {
+		months[archiveMonth(commit)] = append(months[archiveMonth(commit)], commit)
+	}
+	for month, monthCommits := range months {
+		added, err := history.Append(month, monthCommits)
+		if err != nil {
+			return meta, fmt.Errorf("failed to restore history for %s: %w", month, err)
+		}
+		meta.History += added
+	}
+	return meta, nil
+}
+
+/* readZipFile はzipアーカイブ内の1ファイルの内容を読み込む */
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in archive: %w", f.Name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxImportSize))
+}
+
+/*
+exportData は保存データ一式をzipアーカイブとしてダウンロードさせる管理者APIハンドラー
+
+レスポンス:
+  成功時: 200 OK, application/zip（Content-Dispositionでファイル名を指定）
+  失敗時: 500 Internal Server Error
+*/
+func exportData(c *gin.Context) {
+	archive, err := buildExportArchive()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build export archive")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("giter-export-%s.zip", time.Now().Format("20060102-150405"))
+	log.Info().Int("size", len(archive)).Str("filename", filename).Msg("Exported data archive")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+/*
+importData はエクスポートしたzipアーカイブからデータを復元する管理者APIハンドラー
+アーカイブはmultipartの "archive" フィールド、またはリクエストボディそのものとして受け付ける
+
+レスポンス:
+  成功時: 200 OK, {"imported_tables": [...], "imported_history": HistoryStoreに追加したコミット数, "exported_at": エクスポート日時}
+  失敗時: 400 Bad Request（アーカイブ不正）, 413 Request Entity Too Large
+*/
+func importData(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var data []byte
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, ferr := c.Request.FormFile("archive")
+		if ferr != nil {
+			respondError(c, http.StatusBadRequest, "archive field is required")
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(c.Request.Body)
+	}
+	if err != nil {
+		respondError(c, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+
+	meta, err := restoreExportArchive(data)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to import data archive")
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.Info().Strs("tables", meta.Tables).Int("history", meta.History).Time("exported_at", meta.ExportedAt).Msg("Imported data archive")
+	respondJSON(c, http.StatusOK, gin.H{"imported_tables": meta.Tables, "imported_history": meta.History, "exported_at": meta.ExportedAt})
+}
+
+/* exportBlobPrefix はBlobStoreにエクスポートを保存する際のキーの接頭辞 */
+const exportBlobPrefix = "exports/"
+
+/*
+storeExport はエクスポートアーカイブを作成してBlobStoreに保存する管理者APIハンドラー
+データ量が大きくHTTPレスポンスで直接ダウンロードしにくい場合や、
+S3に置いて他のツールから取得したい場合に使用する
+
+レスポンス:
+  成功時: 201 Created, {"name": ファイル名, "key": BlobStoreのキー, "size": バイト数}
+  失敗時: 500 Internal Server Error
+*/
+func storeExport(c *gin.Context) {
+	archive, err := buildExportArchive()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build export archive")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	name := fmt.Sprintf("giter-export-%s.zip", time.Now().Format("20060102-150405"))
+	key := exportBlobPrefix + name
+	if err := blobs.Put(key, archive, "application/zip"); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Failed to store export archive")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Info().Str("key", key).Int("size", len(archive)).Msg("Stored export archive")
+	respondJSON(c, http.StatusCreated, gin.H{"name": name, "key": key, "size": len(archive)})
+}
+
+/*
+listStoredExports はBlobStoreに保存されたエクスポートの一覧を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"exports": [ファイル名（新しい順）]}
+*/
+func listStoredExports(c *gin.Context) {
+	keys, err := blobs.List(exportBlobPrefix)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	names := []string{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		names = append(names, strings.TrimPrefix(keys[i], exportBlobPrefix))
+	}
+	respondJSON(c, http.StatusOK, gin.H{"exports": names})
+}
+
+/*
+downloadStoredExport はBlobStoreに保存されたエクスポートをダウンロードさせる管理者APIハンドラー
+
+パスパラメータ:
+  name string - ファイル名（listStoredExportsで返される名前）
+
+レスポンス:
+  成功時: 200 OK, application/zip
+  失敗時: 404 Not Found
+*/
+func downloadStoredExport(c *gin.Context) {
+	name := c.Param("name")
+	data, err := blobs.Get(exportBlobPrefix + name)
+	if errors.Is(err, errBlobNotFound) {
+		respondError(c, http.StatusNotFound, "export not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Data(http.StatusOK, "application/zip", data)
+}
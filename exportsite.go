@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+静的サイトの書き出し（./giter export-site）
+サーバーと同じハンドラーをプロセス内で呼び出してページとJSONを取得し、ファイルに保存する
+GitHub Pagesなど、サーバーを動かさずに静的ファイルとして公開するために使用する
+*/
+
+/*
+sitePage は静的サイトに書き出す1つのURL
+
+注意:
+  - HTMLのページは "<パス>/index.html" に、JSONはパスのファイル名（拡張子なし）のまま保存する
+    （トップページが fetch(BASE_PATH + '/api/git-history') のように同じURLで読み込めるようにするため）
+*/
+type sitePage struct {
+	path string // BASE_PATHを除いたURLのパス
+	html bool   // HTMLのページの場合はtrue
+}
+
+/* file は出力先のディレクトリからの相対パスを返す */
+func (p sitePage) file() string {
+	if p.html {
+		return filepath.Join(filepath.FromSlash(p.path), "index.html")
+	}
+	return filepath.FromSlash(p.path)
+}
+
+/*
+newSiteEngine は静的サイトの書き出しに使用するGinエンジンを作成する
+ページの表示に必要なミドルウェアと、書き出すルートのみを登録する（管理者APIなどは含めない）
+*/
+func newSiteEngine() *gin.Engine {
+	r := gin.New()
+	r.Use(requestIDMiddleware(), sessionMiddleware(), featureFlagMiddleware(), i18nMiddleware())
+	r.SetFuncMap(web.FuncMap())
+	r.LoadHTMLGlob("templates/*")
+
+	app := r.Group(basePath)
+	app.GET("/", showIndexPage)
+	app.GET("/repos/:owner/:repo", showRepositoryPage)
+	app.GET("/digest/weekly", showWeeklyDigestPage)
+	app.GET("/wrapped/:year", showWrappedPage)
+	app.GET("/favicon.ico", getFavicon)
+	app.GET("/icons/:name", getIcon)
+	app.GET("/manifest.webmanifest", getManifest)
+	app.GET("/api/git-history", getGitHistory)
+	app.GET("/api/activity", getActivity)
+	app.GET("/api/stats/health", getHealthScores)
+	app.GET("/api/stats/forecast", getForecast)
+	app.GET("/api/stats/keywords", getKeywords)
+	app.GET("/api/stats/working-hours", getWorkingHours)
+	app.GET("/api/stats/labels", getLabelStats)
+	app.GET("/api/digest", getDigest)
+	app.GET("/api/wrapped/:year", getWrapped)
+	return r
+}
+
+/*
+sitePages は書き出すURLの一覧を返す
+リポジトリ詳細ページは公開リポジトリ（PRIVACY_MODE=off の場合はすべて）ごとに作成する
+*/
+func sitePages(repos []Repository, now time.Time) []sitePage {
+	year := strconv.Itoa(now.In(statsLocation).Year())
+	pages := []sitePage{
+		{path: "/api/git-history"},
+		{path: "/api/activity"},
+		{path: "/api/stats/health"},
+		{path: "/api/stats/forecast"},
+		{path: "/api/stats/keywords"},
+		{path: "/api/stats/working-hours"},
+		{path: "/api/stats/labels"},
+		{path: "/api/digest"},
+		{path: "/api/wrapped/" + year},
+		{path: "/favicon.ico"},
+		{path: "/manifest.webmanifest"},
+		{path: "/", html: true},
+		{path: "/digest/weekly", html: true},
+		{path: "/wrapped/" + year, html: true},
+	}
+	for _, size := range pwaIconSizes {
+		pages = append(pages, sitePage{path: fmt.Sprintf("/icons/icon-%d.png", size)})
+	}
+	for _, repo := range repos {
+		pages = append(pages, sitePage{path: "/repos/" + repo.FullName, html: true})
+	}
+	return pages
+}
+
+/*
+exportSite はページ・JSON・静的ファイルをoutDirに書き出す
+
+戻り値:
+  []string - 書き出したファイル（outDirからの相対パス）
+  []string - 200 OK以外を返したため書き出さなかったURLと理由
+  error - 出力先に書き込めない場合などのエラー
+
+注意:
+  - リクエストは匿名の閲覧者として処理するため、PRIVACY_MODE・REDACTION_RULESが適用される
+  - BASE_PATHを設定すると、ページ内のリンクをその接頭辞で書き出す（GitHub Pagesのプロジェクトサイトは "/<リポジトリ名>"）
+*/
+func exportSite(outDir string) ([]string, []string, error) {
+	if err := loadLocales(localesDir); err != nil {
+		return nil, nil, err
+	}
+	commits, repos, err := fetchVisibleCommitHistory(backgroundVisibility())
+	if err != nil {
+		return nil, nil, err
+	}
+	/* トップページのヘッダーの集計は/api/git-historyのジョブが非同期に更新するため、書き出す前に記録しておく */
+	recordSyncSummary(len(repos), commits, 0)
+
+	var written, skipped []string
+	engine := newSiteEngine()
+	for _, page := range sitePages(repos, time.Now()) {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, appPath(page.path), nil))
+		if rec.Code != http.StatusOK {
+			skipped = append(skipped, fmt.Sprintf("%s (%d)", page.path, rec.Code))
+			continue
+		}
+		file := page.file()
+		if err := writeSiteFile(filepath.Join(outDir, file), rec.Body.Bytes()); err != nil {
+			return written, skipped, err
+		}
+		written = append(written, file)
+		log.Debug().Str("path", page.path).Str("file", file).Msg("Exported site page")
+	}
+
+	static, err := copySiteDir("static", filepath.Join(outDir, "static"))
+	for _, file := range static {
+		written = append(written, filepath.Join("static", file))
+	}
+	return written, skipped, err
+}
+
+/* writeSiteFile は親ディレクトリを作成してファイルを書き込む */
+func writeSiteFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o644)
+}
+
+/*
+copySiteDir はディレクトリ（./static）の中身を出力先にコピーする
+
+戻り値:
+  []string - コピーしたファイル（srcからの相対パス）
+*/
+func copySiteDir(src, dst string) ([]string, error) {
+	var copied []string
+	err := filepath.WalkDir(src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := writeSiteFile(filepath.Join(dst, rel), data); err != nil {
+			return err
+		}
+		copied = append(copied, rel)
+		return nil
+	})
+	return copied, err
+}
+
+/*
+runExportSiteCommand は静的サイトを書き出し、書き出したファイルを表示する
+例:
+  ./giter export-site --out ./dist
+*/
+func runExportSiteCommand(args []string) int {
+	/* ルートの登録のデバッグ出力が書き出したファイルの一覧に混ざらないようにする */
+	gin.SetMode(gin.ReleaseMode)
+	flags := flag.NewFlagSet("export-site", flag.ContinueOnError)
+	out := flags.String("out", "./dist", "output directory")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	written, skipped, err := exportSite(*out)
+	for _, file := range written {
+		fmt.Println(file)
+	}
+	for _, page := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s\n", page)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(skipped) > 0 {
+		return 1
+	}
+	return 0
+}
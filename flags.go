@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* featureOverrideHeader は管理者がリクエスト単位でフラグを切り替えるためのヘッダー名 */
+	featureOverrideHeader = "X-Giter-Features"
+	/* featureFlagsContextKey はgin.Contextにリクエストで有効なフラグを保存する際のキー */
+	featureFlagsContextKey = "feature_flags"
+)
+
+/*
+機能フラグ名
+実験的な機能は、フラグを無効にしたまま（ダークローンチ）リリースし、
+管理者が個別に試してから全体で有効にする
+*/
+const (
+	featureGraphQL   = "graphql"   // GitHub GraphQL APIを使用した取得
+	featureSSE       = "sse"       // Server-Sent Eventsによる更新の配信
+	featureProviders = "providers" // GitHub以外のプロバイダー（GitLabなど）からの取得
+)
+
+/*
+FeatureFlag は1つの機能フラグの定義
+*/
+type FeatureFlag struct {
+	Name        string `json:"name"`        // フラグ名
+	Description string `json:"description"` // 説明
+	Default     bool   `json:"default"`     // FEATURE_FLAGSで指定しない場合の値
+}
+
+/* featureFlagDefinitions は定義済みの機能フラグ（未定義のフラグ名は設定・上書きともに無視する） */
+var featureFlagDefinitions = []FeatureFlag{
+	{Name: featureGraphQL, Description: "Fetch data through the GitHub GraphQL API", Default: false},
+	{Name: featureSSE, Description: "Stream updates with Server-Sent Events", Default: false},
+	{Name: featureProviders, Description: "Enable non-GitHub providers", Default: false},
+}
+
+/*
+featureFlags は設定から読み込んだ、全リクエストに適用するフラグの値
+環境変数 FEATURE_FLAGS で指定する
+*/
+var featureFlags = loadFeatureFlags(getEnv("FEATURE_FLAGS", ""))
+
+/*
+loadFeatureFlags はフラグの既定値に設定を適用する
+
+引数:
+  spec string - カンマ区切りの指定（例: "graphql,sse=off"）。parseFeatureSpecを参照
+
+戻り値:
+  map[string]bool - フラグ名と有効/無効
+*/
+func loadFeatureFlags(spec string) map[string]bool {
+	flags := map[string]bool{}
+	for _, def := range featureFlagDefinitions {
+		flags[def.Name] = def.Default
+	}
+	for name, enabled := range parseFeatureSpec(spec) {
+		flags[name] = enabled
+	}
+	return flags
+}
+
+/*
+parseFeatureSpec はカンマ区切りのフラグ指定を解析する
+  - "graphql" または "graphql=on" / "graphql=true" / "graphql=1": 有効
+  - "graphql=off" / "graphql=false" / "graphql=0" / "-graphql": 無効
+
+注意:
+  - 定義されていないフラグ名や解釈できない値は警告を出力して無視する
+*/
+func parseFeatureSpec(spec string) map[string]bool {
+	result := map[string]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(item, "=")
+		enabled := true
+		if strings.HasPrefix(name, "-") {
+			name, enabled = strings.TrimPrefix(name, "-"), false
+		} else if hasValue {
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "on", "true", "1":
+				enabled = true
+			case "off", "false", "0":
+				enabled = false
+			default:
+				log.Warn().Str("flag", item).Msg("Ignoring feature flag with invalid value")
+				continue
+			}
+		}
+
+		name = strings.TrimSpace(name)
+		if !isFeatureFlag(name) {
+			log.Warn().Str("flag", name).Msg("Ignoring unknown feature flag")
+			continue
+		}
+		result[name] = enabled
+	}
+	return result
+}
+
+/* isFeatureFlag は定義済みのフラグ名かどうかを返す */
+func isFeatureFlag(name string) bool {
+	for _, def := range featureFlagDefinitions {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+featureFlagMiddleware はリクエストで有効な機能フラグを決定するミドルウェア
+設定（FEATURE_FLAGS）の値をもとに、管理者のリクエスト（ADMIN_TOKENによる認証あり）に限り
+X-Giter-Featuresヘッダーで上書きできる
+
+例:
+  X-Giter-Features: graphql=on,sse=off
+
+注意:
+  - 管理者以外が指定したヘッダーは無視する（エラーにはしない）
+*/
+func featureFlagMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		flags := featureFlags
+		if header := c.GetHeader(featureOverrideHeader); header != "" && isAdminRequest(c) {
+			flags = make(map[string]bool, len(featureFlags))
+			for name, enabled := range featureFlags {
+				flags[name] = enabled
+			}
+			overrides := parseFeatureSpec(header)
+			for name, enabled := range overrides {
+				flags[name] = enabled
+			}
+			log.Debug().Interface("overrides", overrides).Str("path", c.Request.URL.Path).Msg("Feature flags overridden for request")
+		}
+		c.Set(featureFlagsContextKey, flags)
+		c.Next()
+	}
+}
+
+/*
+featureEnabled はリクエストで機能フラグが有効かどうかを返す
+featureFlagMiddlewareを通過していない場合は設定（FEATURE_FLAGS）の値を返す
+*/
+func featureEnabled(c *gin.Context, name string) bool {
+	if flags, ok := c.Get(featureFlagsContextKey); ok {
+		return flags.(map[string]bool)[name]
+	}
+	return featureFlags[name]
+}
+
+/*
+requireFeature は機能フラグが無効な場合に404を返すミドルウェア
+実験的なエンドポイントを、フラグが有効になるまで存在しないものとして扱う
+*/
+func requireFeature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !featureEnabled(c, name) {
+			abortWithError(c, http.StatusNotFound, "not found")
+			return
+		}
+		c.Next()
+	}
+}
+
+/*
+getFeatures はリクエストで有効な機能フラグの一覧を返すAPIハンドラー
+フロントエンドが実験的な機能の表示を切り替えるために使用する
+
+レスポンス:
+  200 OK, {"features": {"graphql": false, ...}, "definitions": [FeatureFlag]}
+*/
+func getFeatures(c *gin.Context) {
+	features := map[string]bool{}
+	for _, def := range featureFlagDefinitions {
+		features[def.Name] = featureEnabled(c, def.Name)
+	}
+	respondJSON(c, http.StatusOK, gin.H{"features": features, "definitions": featureFlagDefinitions})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* followersTable はフォロワー数・フォロー数のスナップショットを保存するテーブル名 */
+	followersTable = "followers"
+	/* maxFollowerSnapshots は保持するスナップショットの最大件数（古いものから削除） */
+	maxFollowerSnapshots = 1000
+)
+
+var (
+	/*
+		followersTrackList はフォロワーの一覧も記録し、増えた・減ったユーザーを返すかどうか
+		環境変数 FOLLOWERS_TRACK_LIST で変更可能（デフォルト: false、一覧の取得に100人ごとに1回のリクエストを使う）
+	*/
+	followersTrackList = getEnvBool("FOLLOWERS_TRACK_LIST", false)
+	/*
+		followersMaxPages はフォロワーの一覧を取得する最大ページ数（1ページ100人）
+		フォロワーがこれより多い場合は一覧を記録せず、数のみ記録する
+		環境変数 FOLLOWERS_MAX_PAGES で変更可能（デフォルト: 10）
+	*/
+	followersMaxPages = getEnvInt("FOLLOWERS_MAX_PAGES", 10)
+)
+
+/*
+FollowerSnapshot はある時点のフォロワー数・フォロー数
+前回から変わった場合のみ記録する（GitHubは履歴を提供しないため、同期のたびに比較する）
+*/
+type FollowerSnapshot struct {
+	At        time.Time `json:"at"`               // 記録した日時
+	Followers int       `json:"followers"`        // フォロワー数
+	Following int       `json:"following"`        // フォロー数
+	Gained    []string  `json:"gained,omitempty"` // 前回から増えたフォロワー（FOLLOWERS_TRACK_LISTの場合のみ）
+	Lost      []string  `json:"lost,omitempty"`   // 前回から減ったフォロワー（FOLLOWERS_TRACK_LISTの場合のみ）
+}
+
+/*
+followerStore はフォロワー数のスナップショットを保持するストア
+followersテーブルに永続化される
+*/
+type followerStore struct {
+	mu        sync.Mutex
+	Snapshots []FollowerSnapshot `json:"snapshots"` // スナップショット（古い順）
+	Logins    []string           `json:"logins"`    // 最後に取得したフォロワーの一覧（名前順、一覧を記録していない場合はnull）
+	checkedAt time.Time          // 最後に確認した日時（変化がなくても更新する、保存しない）
+}
+
+/* followers はアプリケーション全体で共有するフォロワー数のストア */
+var followers = &followerStore{}
+
+func init() {
+	registerTable(followersTable, loadFollowers)
+}
+
+/*
+loadFollowers はfollowersテーブルからストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadFollowers() error {
+	followers.mu.Lock()
+	defer followers.mu.Unlock()
+
+	followers.Snapshots, followers.Logins = nil, nil
+	return loadTable(followersTable, followers)
+}
+
+/*
+record はフォロワー数・フォロー数を前回のスナップショットと比較し、変わった場合のみ記録する
+
+引数:
+  followerCount int - フォロワー数
+  followingCount int - フォロー数
+  logins []string - フォロワーの一覧（名前順、一覧を記録しない場合はnil）
+  now time.Time - 記録する日時
+*/
+func (s *followerStore) record(followerCount, followingCount int, logins []string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkedAt = now
+	snapshot := FollowerSnapshot{At: now, Followers: followerCount, Following: followingCount}
+	/* 前回の一覧がある場合のみ差分を記録する（初回は基準として一覧のみ保存する） */
+	if logins != nil && s.Logins != nil {
+		snapshot.Gained, snapshot.Lost = diffLogins(s.Logins, logins)
+	}
+
+	changed := len(s.Snapshots) == 0 || len(snapshot.Gained) > 0 || len(snapshot.Lost) > 0
+	if last := len(s.Snapshots) - 1; last >= 0 {
+		changed = changed || s.Snapshots[last].Followers != followerCount || s.Snapshots[last].Following != followingCount
+	}
+	listChanged := logins != nil && s.Logins == nil
+	if logins != nil {
+		s.Logins = logins
+	}
+	if !changed && !listChanged {
+		return
+	}
+	if changed {
+		s.Snapshots = append(s.Snapshots, snapshot)
+		if len(s.Snapshots) > maxFollowerSnapshots {
+			s.Snapshots = s.Snapshots[len(s.Snapshots)-maxFollowerSnapshots:]
+		}
+	}
+	if err := saveTable(followersTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save follower snapshots")
+	}
+}
+
+/* reset はすべてのスナップショットを削除し、削除した件数を返す（workspaceのデータの削除で使用） */
+func (s *followerStore) reset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.Snapshots)
+	s.Snapshots, s.Logins, s.checkedAt = nil, nil, time.Time{}
+	if err := saveTable(followersTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save follower snapshots")
+	}
+	return n
+}
+
+/* diffLogins は名前順の2つの一覧から、増えたユーザーと減ったユーザーを返す */
+func diffLogins(before, after []string) (gained, lost []string) {
+	prev := make(map[string]bool, len(before))
+	for _, login := range before {
+		prev[login] = true
+	}
+	current := make(map[string]bool, len(after))
+	for _, login := range after {
+		current[login] = true
+		if !prev[login] {
+			gained = append(gained, login)
+		}
+	}
+	for _, login := range before {
+		if !current[login] {
+			lost = append(lost, login)
+		}
+	}
+	return gained, lost
+}
+
+/*
+fetchFollowerLogins はusernameのフォロワーの一覧を名前順で取得する
+
+戻り値:
+  []string - フォロワーのユーザー名（FOLLOWERS_MAX_PAGESを超える場合はnil）
+  error - 取得に失敗した場合のエラー
+*/
+func fetchFollowerLogins() ([]string, error) {
+	logins := []string{}
+	for page := 1; page <= followersMaxPages; page++ {
+		url := fmt.Sprintf("%s/users/%s/followers?per_page=100&page=%d", githubAPIBase, username, page)
+		var users []githubUser
+		if err := fetchGitHubJSON(upstreamOpRepository, url, githubAcceptV3, &users); err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			logins = append(logins, user.Login)
+		}
+		if len(users) < 100 {
+			sort.Strings(logins)
+			return logins, nil
+		}
+	}
+	log.Debug().Int("max_pages", followersMaxPages).Msg("Too many followers to track the list, recording counts only")
+	return nil, nil
+}
+
+/*
+recordFollowers はプロフィール（と、FOLLOWERS_TRACK_LISTの場合はフォロワーの一覧）を取得してスナップショットを記録する
+コミット履歴の同期のたびにfetch-followersジョブとして実行する（githubGetのキャッシュ・ETagを経由する）
+*/
+func recordFollowers() error {
+	profile, err := fetchProfile()
+	if err != nil {
+		return err
+	}
+	var logins []string
+	if followersTrackList {
+		if logins, err = fetchFollowerLogins(); err != nil {
+			return err
+		}
+	}
+	followers.record(profile.Followers, profile.Following, logins, time.Now().UTC())
+	return nil
+}
+
+/* followersQuery は GET /api/stats/followers のクエリパラメータ */
+type followersQuery struct {
+	Days int `form:"days" binding:"min=1,max=3650"` // 集計する直近の日数
+}
+
+/*
+FollowersResponse は /api/stats/followers のレスポンス
+*/
+type FollowersResponse struct {
+	Followers    int                `json:"followers"`     // 現在のフォロワー数
+	Following    int                `json:"following"`     // 現在のフォロー数
+	CheckedAt    *time.Time         `json:"checked_at"`    // 最後に確認した日時（起動後に未確認の場合はnull）
+	Days         int                `json:"days"`          // 集計した直近の日数
+	Gained       int                `json:"gained"`        // 期間内に増えたフォロワー数
+	Lost         int                `json:"lost"`          // 期間内に減ったフォロワー数
+	GainedLogins []string           `json:"gained_logins"` // 期間内に増えたフォロワー（FOLLOWERS_TRACK_LISTの場合のみ、それ以外はnull）
+	LostLogins   []string           `json:"lost_logins"`   // 期間内に減ったフォロワー（FOLLOWERS_TRACK_LISTの場合のみ、それ以外はnull）
+	Series       []FollowerSnapshot `json:"series"`        // 期間内のスナップショット（古い順、期間の開始時点の値を先頭に含む）
+}
+
+/*
+getFollowerStats はフォロワー数・フォロー数の推移と、期間内に増えた・減った数を返すAPIハンドラー
+
+クエリパラメータ:
+  days - 集計する直近の日数（1〜3650、デフォルト: 30）
+
+レスポンス:
+  成功時: 200 OK, FollowersResponse
+  失敗時: 422 Unprocessable Entity（daysが不正）
+
+注意:
+  - 記録は同期（GET /api/git-history）のたびに行う。記録を始める前の推移は返せない
+  - 一覧を記録している場合は一覧の差分、記録していない場合はスナップショットの数の増減から数える
+    （数の増減からは、同じ期間に増えて減ったフォロワーを数えられない）
+*/
+func getFollowerStats(c *gin.Context) {
+	query := followersQuery{Days: 30}
+	if !bindQuery(c, &query) {
+		return
+	}
+	since := time.Now().AddDate(0, 0, -query.Days)
+
+	followers.mu.Lock()
+	snapshots := append([]FollowerSnapshot{}, followers.Snapshots...)
+	checkedAt := followers.checkedAt
+	tracked := followers.Logins != nil
+	followers.mu.Unlock()
+
+	resp := FollowersResponse{Days: query.Days, Series: []FollowerSnapshot{}}
+	if !checkedAt.IsZero() {
+		resp.CheckedAt = &checkedAt
+	}
+	if tracked {
+		resp.GainedLogins, resp.LostLogins = []string{}, []string{}
+	}
+	for i, snapshot := range snapshots {
+		if snapshot.At.Before(since) {
+			/* 期間の開始時点の値として、期間の直前のスナップショットを先頭に含める */
+			if i+1 == len(snapshots) || !snapshots[i+1].At.Before(since) {
+				resp.Series = append(resp.Series, snapshot)
+			}
+			continue
+		}
+		resp.Series = append(resp.Series, snapshot)
+		if tracked {
+			resp.GainedLogins = append(resp.GainedLogins, snapshot.Gained...)
+			resp.LostLogins = append(resp.LostLogins, snapshot.Lost...)
+		} else if i > 0 {
+			delta := snapshot.Followers - snapshots[i-1].Followers
+			if delta > 0 {
+				resp.Gained += delta
+			} else {
+				resp.Lost -= delta
+			}
+		}
+	}
+	if tracked {
+		resp.Gained, resp.Lost = len(resp.GainedLogins), len(resp.LostLogins)
+	}
+	if n := len(snapshots); n > 0 {
+		resp.Followers, resp.Following = snapshots[n-1].Followers, snapshots[n-1].Following
+	}
+	respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	forecastModelLinear        = "linear"         // 線形回帰による予測（デフォルト）
+	forecastModelMovingAverage = "moving_average" // 移動平均による予測
+)
+
+const (
+	/* forecastMovingAverageDays は移動平均の対象とする日数 */
+	forecastMovingAverageDays = 7
+)
+
+/*
+forecastWindowDays は予測モデルの学習に使用する直近の日数
+環境変数 FORECAST_WINDOW_DAYS で変更可能（デフォルト: 28日）
+*/
+var forecastWindowDays = getEnvInt("FORECAST_WINDOW_DAYS", 28)
+
+/* ForecastDay は予測した1日分のコミット数 */
+type ForecastDay struct {
+	Date      string  `json:"date"`      // 日付（"2006-01-02"形式）
+	Projected float64 `json:"projected"` // 予測したコミット数
+}
+
+/*
+StreakForecast は連続コミット日数（ストリーク）を月末まで維持するために必要な日数
+*/
+type StreakForecast struct {
+	Current        int  `json:"current"`         // 現在の連続日数
+	CommittedToday bool `json:"committed_today"` // 今日すでにコミットしているか
+	DaysNeeded     int  `json:"days_needed"`     // 月末まで維持するためにコミットが必要な日数（今日を含む）
+}
+
+/*
+ForecastResponse は /api/stats/forecast のレスポンス
+*/
+type ForecastResponse struct {
+	Month              string         `json:"month"`               // 対象の月（"2006-01"形式）
+	Timezone           string         `json:"timezone"`            // 日付の集計に使用したタイムゾーン
+	Model              string         `json:"model"`               // 予測モデル（forecastModel* 定数）
+	DaysInMonth        int            `json:"days_in_month"`       // 月の日数
+	DaysElapsed        int            `json:"days_elapsed"`        // 経過した日数（今日を含む）
+	MonthToDate        int            `json:"month_to_date"`       // 今月これまでのコミット数
+	ProjectedRemaining float64        `json:"projected_remaining"` // 明日から月末までの予測コミット数
+	ProjectedTotal     float64        `json:"projected_total"`     // 月末時点の予測コミット数
+	Daily              []ForecastDay  `json:"daily"`               // 明日から月末までの日ごとの予測
+	Streak             StreakForecast `json:"streak"`              // ストリークの維持に必要な日数
+}
+
+/*
+forecastDaily は過去の日ごとのコミット数から、続くdays日分のコミット数を予測する
+
+引数:
+  history []float64 - 古い順の日ごとのコミット数（最後の要素が今日）
+  days int - 予測する日数
+  model string - 予測モデル（forecastModel* 定数）
+
+戻り値:
+  []float64 - 翌日から順の予測コミット数（負の値は0にする）
+*/
+func forecastDaily(history []float64, days int, model string) []float64 {
+	projected := make([]float64, days)
+	if len(history) == 0 {
+		return projected
+	}
+
+	switch model {
+	case forecastModelMovingAverage:
+		window := history
+		if len(window) > forecastMovingAverageDays {
+			window = window[len(window)-forecastMovingAverageDays:]
+		}
+		sum := 0.0
+		for _, v := range window {
+			sum += v
+		}
+		for i := range projected {
+			projected[i] = sum / float64(len(window))
+		}
+	default:
+		/* 最小二乗法で y = a + b*x を求める（x は history のインデックス） */
+		n := float64(len(history))
+		var sumX, sumY, sumXY, sumXX float64
+		for i, y := range history {
+			x := float64(i)
+			sumX += x
+			sumY += y
+			sumXY += x * y
+			sumXX += x * x
+		}
+		slope := 0.0
+		if d := n*sumXX - sumX*sumX; d != 0 {
+			slope = (n*sumXY - sumX*sumY) / d
+		}
+		intercept := (sumY - slope*sumX) / n
+		for i := range projected {
+			projected[i] = math.Max(0, intercept+slope*float64(len(history)+i))
+		}
+	}
+	return projected
+}
+
+/* roundTenth は小数点以下1桁に丸める */
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+/* forecastParams は /api/stats/forecast のクエリパラメータ */
+type forecastParams struct {
+	Model string `form:"model" binding:"oneof=linear moving_average"`
+}
+
+/*
+getForecast は今月の残りの日のコミット数を予測するAPIハンドラー
+直近forecastWindowDays日間の日ごとのコミット数から、月末時点の合計とストリークの維持に必要な日数を返す
+日ごとのコミット数は集計のビュー（statsViews、アーカイブのコミットを含む）から取得する
+
+クエリパラメータ:
+  model string - 予測モデル（"linear"（デフォルト）または "moving_average"）
+
+レスポンス:
+  成功時: 200 OK, ForecastResponse
+  失敗時: 422 Unprocessable Entity（未対応のmodel）, 500 Internal Server Error（アーカイブを読み込めない）, 502 Bad Gateway（GitHubから取得できない）
+*/
+func getForecast(c *gin.Context) {
+	params := forecastParams{Model: forecastModelLinear}
+	if !bindQuery(c, &params) {
+		return
+	}
+	model := params.Model
+
+	if !refreshStatsViews(c) {
+		return
+	}
+
+	now := time.Now().In(statsLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, statsLocation)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, statsLocation)
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+	counts := statsViews.dailyCounts(requestVisibility(c), "")
+
+	window := forecastWindowDays
+	if window < 1 {
+		window = 1
+	}
+	history := make([]float64, window)
+	for i := range history {
+		history[i] = float64(counts[today.AddDate(0, 0, i-window+1).Format(statsDateFormat)])
+	}
+
+	resp := ForecastResponse{
+		Month:       monthStart.Format("2006-01"),
+		Timezone:    statsLocation.String(),
+		Model:       model,
+		DaysInMonth: daysInMonth,
+		DaysElapsed: now.Day(),
+		Daily:       []ForecastDay{},
+	}
+	for d := monthStart; !d.After(today); d = d.AddDate(0, 0, 1) {
+		resp.MonthToDate += counts[d.Format(statsDateFormat)]
+	}
+
+	remaining := daysInMonth - now.Day()
+	for i, v := range forecastDaily(history, remaining, model) {
+		resp.Daily = append(resp.Daily, ForecastDay{
+			Date:      today.AddDate(0, 0, i+1).Format(statsDateFormat),
+			Projected: roundTenth(v),
+		})
+		resp.ProjectedRemaining += v
+	}
+	resp.ProjectedTotal = roundTenth(float64(resp.MonthToDate) + resp.ProjectedRemaining)
+	resp.ProjectedRemaining = roundTenth(resp.ProjectedRemaining)
+
+	streak, committedToday := currentStreak(counts, today)
+	resp.Streak = StreakForecast{Current: streak, CommittedToday: committedToday, DaysNeeded: remaining}
+	if !committedToday {
+		resp.Streak.DaysNeeded++
+	}
+
+	respondJSON(c, http.StatusOK, resp)
+}
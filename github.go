@@ -0,0 +1,414 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* githubAcceptV3 はGitHub API v3のレスポンス形式を指定するAcceptヘッダーの値 */
+	githubAcceptV3 = "application/vnd.github.v3+json"
+	/*
+		githubAcceptStar はスター日時（starred_at）を含めてスターゲイザーを取得するためのAcceptヘッダー
+		通常のv3形式ではスターしたユーザー情報のみで日時が含まれない
+	*/
+	githubAcceptStar = "application/vnd.github.star+json"
+	/* githubAcceptHTML はMarkdown（README）をGitHubが変換したHTMLで取得するためのAcceptヘッダー */
+	githubAcceptHTML = "application/vnd.github.html"
+)
+
+const (
+	cacheStatusMiss        = "MISS"        // キャッシュなし、GitHubから取得した
+	cacheStatusHit         = "HIT"         // 有効期間内のキャッシュを返した（GitHubへのリクエストなし）
+	cacheStatusRevalidated = "REVALIDATED" // 条件付きリクエストで304が返り、キャッシュを返した
+	cacheStatusStale       = "STALE"       // レート制限・通信エラーのため期限切れのキャッシュを返した
+)
+
+/*
+githubCacheTTL はキャッシュしたレスポンスを再検証せずに返す期間
+環境変数 GITHUB_CACHE_TTL で変更可能（デフォルト: 60秒）
+期間を過ぎたキャッシュはETagによる条件付きリクエストで再検証する
+*/
+var githubCacheTTL = parseDurationEnv("GITHUB_CACHE_TTL", 60*time.Second)
+
+/*
+githubAPIBase はGitHub REST API v3のベースURL（末尾の "/" なし）
+環境変数 GITHUB_API_BASE で変更可能（デフォルト: https://api.github.com）
+GitHub Enterprise Server（https://ghe.example.com/api/v3）や、テストで記録したレスポンスを返すサーバーを指定する
+*/
+var githubAPIBase = strings.TrimRight(getEnv("GITHUB_API_BASE", "https://api.github.com"), "/")
+
+/*
+githubToken はGitHub APIのリクエストにBearerトークンとして付けるアクセストークン
+環境変数 GITHUB_TOKEN、またはトークンを書いたファイルのパス GITHUB_TOKEN_FILE（Docker・Kubernetesのシークレット用）で設定する
+設定するとレート制限が1時間あたり60回から5,000回になる（未設定の場合は認証なしでリクエストする）
+*/
+var githubToken = loadGitHubToken(getEnv("GITHUB_TOKEN", ""), getEnv("GITHUB_TOKEN_FILE", ""))
+
+/* loadGitHubToken はGITHUB_TOKENの値を返す（未設定の場合はGITHUB_TOKEN_FILEのファイルから読み込む） */
+func loadGitHubToken(token, path string) string {
+	if token != "" || path == "" {
+		return strings.TrimSpace(token)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to read GITHUB_TOKEN_FILE, requesting GitHub API without authentication")
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+/*
+authorizeGitHubRequest はGITHUB_TOKENを設定している場合、リクエストにAuthorizationヘッダーを付ける
+
+戻り値:
+  bool - トークンを付けた場合はtrue
+
+注意:
+  - /proxy/github の中継（upstreamOpProxy）には付けない
+    （任意のパスを中継するため、トークンの所有者のデータやプライベートリポジトリを閲覧者に返さないようにする）
+*/
+func authorizeGitHubRequest(req *http.Request, op string) bool {
+	if githubToken == "" || op == upstreamOpProxy {
+		return false
+	}
+	req.Header.Set("Authorization", "Bearer "+githubToken)
+	return true
+}
+
+/*
+githubResponse はGitHub APIのレスポンスをメモリ上に保持した形式
+レスポンスボディを読み切ってから返すため、呼び出し元でクローズする必要はない
+*/
+type githubResponse struct {
+	StatusCode  int         // HTTPステータスコード
+	Status      string      // HTTPステータス（例: "200 OK"）
+	Header      http.Header // レスポンスヘッダー
+	Body        []byte      // レスポンスボディ
+	CacheStatus string      // キャッシュの利用状況（cacheStatus* 定数）
+}
+
+/*
+githubCacheEntry はキャッシュしたGitHub APIのレスポンス
+200 OKのレスポンスのみキャッシュする
+*/
+type githubCacheEntry struct {
+	Response githubResponse // キャッシュしたレスポンス
+	ETag     string         // 条件付きリクエストに使用するETag
+	StoredAt time.Time      // 取得（または再検証）した日時
+
+	element    *list.Element // githubCache.lruの要素
+	size       int           // 保持しているレスポンスボディのバイト数（圧縮した場合は圧縮後）
+	rawSize    int           // レスポンスボディの展開後のバイト数
+	compressed bool          // Response.Bodyをgzipで圧縮しているかどうか
+}
+
+/*
+RateLimitState はGitHub APIのレスポンスヘッダーから把握したレート制限の状態
+*/
+type RateLimitState struct {
+	Limit     int       `json:"limit"`      // 1時間あたりのリクエスト上限（X-RateLimit-Limit）
+	Remaining int       `json:"remaining"`  // 残りリクエスト数（X-RateLimit-Remaining）
+	Reset     time.Time `json:"reset"`      // 残り回数がリセットされる日時（X-RateLimit-Reset）
+	UpdatedAt time.Time `json:"updated_at"` // 最後にヘッダーを受け取った日時（ゼロ値の場合は未取得）
+}
+
+/* githubRateLimit はアプリケーション全体で共有するレート制限の状態 */
+var githubRateLimit = struct {
+	mu    sync.Mutex
+	state RateLimitState
+}{}
+
+/* githubUnauthenticatedRateLimit は認証なしのリクエストの1時間あたりの上限 */
+const githubUnauthenticatedRateLimit = 60
+
+/*
+updateRateLimit はレスポンスヘッダーのX-RateLimit-*からレート制限の状態を更新する
+ヘッダーがないレスポンス（通信エラーなど）では何もしない
+*/
+func updateRateLimit(h http.Header) {
+	limit, err1 := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	reset, err3 := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+
+	githubRateLimit.mu.Lock()
+	previous := githubRateLimit.state.Limit
+	githubRateLimit.state = RateLimitState{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+		UpdatedAt: time.Now(),
+	}
+	githubRateLimit.mu.Unlock()
+
+	/* 上限が変わった場合（起動後の最初のレスポンス・トークンの変更）は、認証の状態とあわせて出力する */
+	if limit != previous {
+		event := log.Info()
+		if githubToken != "" && limit <= githubUnauthenticatedRateLimit {
+			event = log.Warn()
+		}
+		event.Int("limit", limit).Int("remaining", remaining).Bool("authenticated", githubToken != "").Msg("GitHub rate limit detected")
+	}
+	log.Debug().Int("limit", limit).Int("remaining", remaining).Time("reset", time.Unix(reset, 0)).Msg("GitHub rate limit updated")
+}
+
+/* currentRateLimit はレート制限の状態のコピーを返す */
+func currentRateLimit() RateLimitState {
+	githubRateLimit.mu.Lock()
+	defer githubRateLimit.mu.Unlock()
+	return githubRateLimit.state
+}
+
+/*
+rateLimitExhausted はレート制限の残り回数が0で、まだリセット時刻に達していないかを返す
+trueの場合、GitHubにリクエストしても403が返るだけなので送信しない
+*/
+func rateLimitExhausted(now time.Time) bool {
+	state := currentRateLimit()
+	return !state.UpdatedAt.IsZero() && state.Remaining == 0 && now.Before(state.Reset)
+}
+
+/*
+githubGet はGitHub APIにGETリクエストを送信する
+すべてのGitHub API呼び出しはこの関数を経由し、以下をまとめて処理する:
+  - キャッシュ: githubCacheTTL以内の同じリクエストはGitHubに送信せずキャッシュを返す
+  - ETag: 期限切れのキャッシュはIf-None-Matchで再検証し、304の場合はキャッシュを返す
+    （304のレスポンスはGitHubのレート制限の回数を消費しない）
+  - レート制限: 残り回数が0の間はリクエストを送信せず、キャッシュがあればそれを返す
+  - 予算: 機能ごとの割り当てを超え、ほかの機能の割り当てを使ってしまう場合も同様に送信しない（budget.go）
+
+引数:
+  op string - 操作の種類（upstreamOp* 定数、タイムアウト設定の選択に使用）
+  url string - リクエストURL（クエリパラメータを含む完全なURL）
+  accept string - Acceptヘッダーの値（空文字の場合はgithubAcceptV3）
+
+戻り値:
+  *githubResponse - レスポンス（200 OK以外のステータスもそのまま返す）
+  error - 通信エラー、またはレート制限中でキャッシュもない場合のエラー
+*/
+func githubGet(op, url, accept string) (*githubResponse, error) {
+	if accept == "" {
+		accept = githubAcceptV3
+	}
+	key := githubCacheKey(url, accept)
+	if githubToken != "" && op == upstreamOpProxy {
+		/* トークンを付けて取得したレスポンスを、トークンを付けない中継で返さないようキャッシュを分ける */
+		key = "anonymous " + key
+	}
+	now := time.Now()
+
+	entry, cached := githubCache.get(key)
+	entryKey := key
+	if !cached && op != upstreamOpProxy {
+		/* 再起動後の最初の取得は、同期の位置から復元したレスポンスで再検証する（seedGitHubCacheを参照） */
+		entryKey = seededCacheKey(key)
+		entry, cached = githubCache.get(entryKey)
+	}
+	if cached && now.Sub(entry.StoredAt) < githubCacheTTL {
+		return entry.cached(cacheStatusHit), nil
+	}
+
+	if rateLimitExhausted(now) {
+		if cached {
+			log.Debug().Str("url", url).Msg("GitHub rate limit exhausted, serving stale cache")
+			return entry.cached(cacheStatusStale), nil
+		}
+		return nil, fmt.Errorf("GitHub API rate limit exceeded (resets at %s)", currentRateLimit().Reset.Format(time.RFC3339))
+	}
+	if !budgetAllows(op) {
+		if cached {
+			log.Debug().Str("url", url).Str("feature", budgetFeatures[op]).Msg("GitHub rate limit budget exceeded, serving stale cache")
+			return entry.cached(cacheStatusStale), nil
+		}
+		return nil, fmt.Errorf("GitHub API rate limit budget for %s exceeded (resets at %s)", budgetFeatures[op], currentRateLimit().Reset.Format(time.RFC3339))
+	}
+
+	log.Debug().Str("url", url).Msg("Requesting GitHub API")
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	authenticated := authorizeGitHubRequest(req, op)
+	if cached && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	/* 操作の種類ごとに接続・TLS・レスポンスヘッダー・全体のタイムアウトを設定したクライアントを使用 */
+	resp, err := upstreamClient(op).Do(req)
+	if err != nil {
+		/* 通信エラー時は古いキャッシュがあればそれを返し、画面が空になるのを防ぐ */
+		if cached {
+			log.Warn().Err(err).Str("url", url).Msg("GitHub API request failed, serving stale cache")
+			return entry.cached(cacheStatusStale), nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	/* レート制限の状態と予算はトークンの回数を表すため、トークンを付けない中継の応答では更新しない */
+	if authenticated || githubToken == "" {
+		updateRateLimit(resp.Header)
+		budgetRecord(op, resp.StatusCode)
+	}
+	if authenticated && resp.StatusCode == http.StatusUnauthorized {
+		log.Error().Str("url", url).Msg("GitHub rejected GITHUB_TOKEN (expired or revoked?)")
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached {
+		githubCache.touch(entryKey, now)
+		return entry.cached(cacheStatusRevalidated), nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result := &githubResponse{
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		Header:      resp.Header.Clone(),
+		Body:        body,
+		CacheStatus: cacheStatusMiss,
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		githubCache.put(key, &githubCacheEntry{
+			Response: *result,
+			ETag:     resp.Header.Get("ETag"),
+			StoredAt: now,
+		}, true)
+	}
+	return result, nil
+}
+
+/* githubCacheKey はURLとAcceptヘッダーからキャッシュのキーを作成する */
+func githubCacheKey(url, accept string) string {
+	if accept == "" {
+		accept = githubAcceptV3
+	}
+	return accept + " " + url
+}
+
+/* cachedETag はキャッシュしたレスポンスのETagを返す（同期の位置から復元したレスポンスを含む、キャッシュがない場合は空文字） */
+func cachedETag(url, accept string) string {
+	key := githubCacheKey(url, accept)
+	if entry, ok := githubCache.get(key); ok {
+		return entry.ETag
+	}
+	if entry, ok := githubCache.get(seededCacheKey(key)); ok {
+		return entry.ETag
+	}
+	return ""
+}
+
+/*
+seededCacheKey は同期の位置から復元したレスポンスを登録するキャッシュのキーを返す
+復元したボディは呼び出し元がデコードに使用するフィールドのみのため、/proxy/github の中継からは参照しない
+*/
+func seededCacheKey(key string) string {
+	return "seeded " + key
+}
+
+/*
+seedGitHubCache は永続化しておいたレスポンスをキャッシュに登録する
+再起動後の最初のリクエストもETagによる条件付きリクエストになり、変更がなければ304で済む
+すでにキャッシュがある場合は上書きしない
+
+引数:
+  url, accept string - キャッシュのキー（githubGetに渡すものと同じ値）
+  etag string - レスポンスのETag
+  body []byte - 200 OKのレスポンスボディ
+  storedAt time.Time - レスポンスを取得した日時（githubCacheTTLの判定に使用）
+
+注意:
+  - bodyは呼び出し元がデコードに使用するフィールドのみの場合があるため、通常のキャッシュとは別のキー
+    （seededCacheKey）に登録する。/proxy/github の中継は参照せず、GitHubから完全なレスポンスを取得する
+*/
+func seedGitHubCache(url, accept, etag string, body []byte, storedAt time.Time) {
+	githubCache.put(seededCacheKey(githubCacheKey(url, accept)), &githubCacheEntry{
+		Response: githubResponse{
+			StatusCode:  http.StatusOK,
+			Status:      "200 OK",
+			Header:      http.Header{"Content-Type": {"application/json; charset=utf-8"}, "Etag": {etag}},
+			Body:        body,
+			CacheStatus: cacheStatusMiss,
+		},
+		ETag:     etag,
+		StoredAt: storedAt,
+	}, false)
+}
+
+/*
+expireGitHubCache はすべてのキャッシュを期限切れとして扱う
+キャッシュは削除しないため、次のリクエストはETagによる条件付きリクエストで再検証する（?refresh=true で使用）
+*/
+func expireGitHubCache() {
+	githubCache.mu.Lock()
+	defer githubCache.mu.Unlock()
+	for _, entry := range githubCache.entries {
+		entry.StoredAt = time.Time{}
+	}
+}
+
+/* cached はキャッシュしたレスポンスのコピー（ボディは展開したもの）を、指定したキャッシュ状況で返す */
+func (e *githubCacheEntry) cached(status string) *githubResponse {
+	resp := e.Response
+	resp.Header = e.Response.Header.Clone()
+	resp.Body = e.body()
+	resp.CacheStatus = status
+	return &resp
+}
+
+/*
+fetchGitHubJSON はGitHub APIにGETリクエストを送信し、レスポンスJSONをoutにデコードする
+リクエストはgithubGetを経由するため、キャッシュ・ETag・レート制限の管理が適用される
+
+引数:
+  op string - 操作の種類（upstreamOp* 定数）
+  url string - リクエストURL（クエリパラメータを含む完全なURL）
+  accept string - Acceptヘッダーの値（空文字の場合はgithubAcceptV3）
+  out interface{} - デコード先のポインタ（例: &[]Commit{}）
+
+戻り値:
+  error - リクエスト失敗、200 OK以外のステータス、JSONパース失敗の場合のエラー
+*/
+func fetchGitHubJSON(op, url, accept string, out interface{}) error {
+	resp, err := githubGet(op, url, accept)
+	if err != nil {
+		captureUpstreamFailure(op, url, err)
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Error().
+			Int("status_code", resp.StatusCode).
+			Str("status", resp.Status).
+			Str("url", url).
+			Str("response_body", string(resp.Body)).
+			Msg("GitHub API returned non-OK status")
+		err := fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(resp.Body))
+		captureUpstreamFailure(op, url, err)
+		return err
+	}
+
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to decode GitHub API JSON response")
+		return err
+	}
+	return nil
+}
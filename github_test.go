@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	fixtureCommitsPath      = "/repos/develop-suda/giter/commits"
+	fixtureCommitsQuery     = "per_page=100"
+	fixtureCommitsETag      = `W/"5f1c9a3e0d7b2c4a8e6f1d3b9c0a2e4f"`
+	fixtureReposPath        = "/users/develop-suda/repos"
+	fixtureReposQuery       = "type=public&per_page=100"
+	fixtureContributorsPath = "/repos/develop-suda/giter/stats/contributors"
+)
+
+func TestGitHubGetCachesAndRevalidates(t *testing.T) {
+	s := newGitHubReplayServer(t, "commits.json")
+	url := githubAPIBase + fixtureCommitsPath + "?" + fixtureCommitsQuery
+
+	var commits []Commit
+	if err := fetchGitHubJSON(upstreamOpCommits, url, githubAcceptV3, &commits); err != nil {
+		t.Fatalf("fetchGitHubJSON: %v", err)
+	}
+	if len(commits) != 2 || commits[0].SHA != "8e853c7a1f0b4d6e9c2a5b7d3f1e0c9a8b6d4f2e" || len(commits[0].Parents) != 1 {
+		t.Fatalf("unexpected commits: %+v", commits)
+	}
+	if state := currentRateLimit(); state.Limit != 60 || state.Remaining != 57 {
+		t.Errorf("rate limit = %+v, want limit 60 remaining 57", state)
+	}
+
+	resp, err := githubGet(upstreamOpCommits, url, githubAcceptV3)
+	if err != nil {
+		t.Fatalf("githubGet: %v", err)
+	}
+	if resp.CacheStatus != cacheStatusHit || s.hits(fixtureCommitsPath, fixtureCommitsQuery) != 1 {
+		t.Errorf("second request: cache %s, hits %d; want HIT without a request", resp.CacheStatus, s.hits(fixtureCommitsPath, fixtureCommitsQuery))
+	}
+
+	expireGitHubCache()
+	resp, err = githubGet(upstreamOpCommits, url, githubAcceptV3)
+	if err != nil {
+		t.Fatalf("githubGet after expiry: %v", err)
+	}
+	if resp.CacheStatus != cacheStatusRevalidated || resp.StatusCode != http.StatusOK {
+		t.Errorf("expired request: cache %s, status %d; want REVALIDATED 200", resp.CacheStatus, resp.StatusCode)
+	}
+	if got := s.ifNoneMatch(fixtureCommitsPath, fixtureCommitsQuery); len(got) != 2 || got[1] != fixtureCommitsETag {
+		t.Errorf("If-None-Match = %q, want the cached ETag on the second request", got)
+	}
+	if err := json.Unmarshal(resp.Body, &commits); err != nil || len(commits) != 2 {
+		t.Errorf("revalidated body: %v, %d commits", err, len(commits))
+	}
+}
+
+func TestGitHubGetRateLimited(t *testing.T) {
+	s := newGitHubReplayServer(t, "commits.json", "rate_limited.json")
+	commitsURL := githubAPIBase + fixtureCommitsPath + "?" + fixtureCommitsQuery
+	if _, err := githubGet(upstreamOpCommits, commitsURL, githubAcceptV3); err != nil {
+		t.Fatalf("githubGet: %v", err)
+	}
+
+	_, err := requestRepositories()
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("requestRepositories error = %v, want 403", err)
+	}
+	if state := currentRateLimit(); state.Remaining != 0 || !rateLimitExhausted(time.Now()) {
+		t.Fatalf("rate limit = %+v, want exhausted", state)
+	}
+
+	/* 残り回数が0の間はGitHubに送信しない */
+	_, err = requestRepositories()
+	if err == nil || !strings.Contains(err.Error(), "rate limit exceeded") {
+		t.Errorf("requestRepositories error = %v, want rate limit exceeded", err)
+	}
+	if hits := s.hits(fixtureReposPath, fixtureReposQuery); hits != 1 {
+		t.Errorf("repository list requested %d times, want 1", hits)
+	}
+
+	/* キャッシュがある場合は期限切れでも返す */
+	expireGitHubCache()
+	resp, err := githubGet(upstreamOpCommits, commitsURL, githubAcceptV3)
+	if err != nil || resp.CacheStatus != cacheStatusStale {
+		t.Errorf("githubGet = %v, %v; want STALE", resp, err)
+	}
+	if hits := s.hits(fixtureCommitsPath, fixtureCommitsQuery); hits != 1 {
+		t.Errorf("commits requested %d times, want 1", hits)
+	}
+}
+
+func TestGitHubGetAcceptedIsNotCached(t *testing.T) {
+	s := newGitHubReplayServer(t, "stats_contributors.json")
+	url := githubAPIBase + fixtureContributorsPath
+
+	/* 集計中の202はエラーとして扱い、キャッシュしない */
+	var contributors []struct {
+		Total int `json:"total"`
+	}
+	if err := fetchGitHubJSON(upstreamOpRepository, url, githubAcceptV3, &contributors); err == nil || !strings.Contains(err.Error(), "202") {
+		t.Fatalf("fetchGitHubJSON error = %v, want 202", err)
+	}
+
+	resp, err := githubGet(upstreamOpRepository, url, githubAcceptV3)
+	if err != nil {
+		t.Fatalf("githubGet: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.CacheStatus != cacheStatusMiss {
+		t.Errorf("second request: status %d, cache %s; want 200 MISS", resp.StatusCode, resp.CacheStatus)
+	}
+	if err := json.Unmarshal(resp.Body, &contributors); err != nil || len(contributors) != 1 || contributors[0].Total != 2 {
+		t.Errorf("contributors = %+v, %v", contributors, err)
+	}
+
+	if resp, _ := githubGet(upstreamOpRepository, url, githubAcceptV3); resp == nil || resp.CacheStatus != cacheStatusHit {
+		t.Errorf("third request was not served from the cache")
+	}
+	if hits := s.hits(fixtureContributorsPath, ""); hits != 2 {
+		t.Errorf("contributors requested %d times, want 2", hits)
+	}
+}
+
+func TestProxyRewritesPaginationLinks(t *testing.T) {
+	newGitHubReplayServer(t, "commits.json")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(proxyPathPrefix+"/*path", proxyGitHub)
+
+	req := httptest.NewRequest(http.MethodGet, proxyPathPrefix+fixtureCommitsPath+"?"+fixtureCommitsQuery, nil)
+	req.Host = "giter.example.com"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	want := `<http://giter.example.com/proxy/github/repositories/751204983/commits?per_page=100&page=2>; rel="next"`
+	if link := w.Header().Get("Link"); !strings.HasPrefix(link, want) || strings.Contains(link, githubAPIBase) {
+		t.Errorf("Link = %q, want it to start with %q", link, want)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "57" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 57", got)
+	}
+
+	/* クライアントのETagが一致する場合は304を返す */
+	req = httptest.NewRequest(http.MethodGet, proxyPathPrefix+fixtureCommitsPath+"?"+fixtureCommitsQuery, nil)
+	req.Header.Set("If-None-Match", fixtureCommitsETag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Header().Get("X-Giter-Cache") != cacheStatusHit {
+		t.Errorf("conditional request: status %d, cache %s; want 304 HIT", w.Code, w.Header().Get("X-Giter-Cache"))
+	}
+}
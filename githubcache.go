@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	/*
+		githubCacheMaxEntries はキャッシュに保持するレスポンスの最大件数
+		超えた場合は最も長く使われていないレスポンスから削除する（LRU）
+		環境変数 GITHUB_CACHE_MAX_ENTRIES で変更可能（デフォルト: 5000、0の場合は制限しない）
+	*/
+	githubCacheMaxEntries = getEnvInt("GITHUB_CACHE_MAX_ENTRIES", 5000)
+	/*
+		githubCacheMaxBytes はキャッシュに保持するレスポンスボディの合計の最大バイト数
+		環境変数 GITHUB_CACHE_MAX_BYTES で変更可能（デフォルト: 64MB、0の場合は制限しない）
+	*/
+	githubCacheMaxBytes = getEnvInt("GITHUB_CACHE_MAX_BYTES", 64*1024*1024)
+	/*
+		githubCacheCompress はレスポンスボディをgzipで圧縮して保持するかどうか
+		コミット一覧などのJSONは繰り返しが多く、メモリの使用量を大きく減らせる（キャッシュを返すたびに展開する）
+		環境変数 GITHUB_CACHE_COMPRESS で変更可能（デフォルト: true）
+	*/
+	githubCacheCompress = getEnvBool("GITHUB_CACHE_COMPRESS", true)
+	/*
+		githubCacheCompressMinBytes は圧縮するレスポンスボディの最小のバイト数（小さいボディは圧縮しても効果が小さいため）
+		環境変数 GITHUB_CACHE_COMPRESS_MIN_BYTES で変更可能（デフォルト: 1024）
+	*/
+	githubCacheCompressMinBytes = getEnvInt("GITHUB_CACHE_COMPRESS_MIN_BYTES", 1024)
+)
+
+/* キャッシュからレスポンスを削除した理由（giter_github_cache_evictions_totalのreason） */
+const (
+	cacheEvictionEntries = "entries" // GITHUB_CACHE_MAX_ENTRIESを超えた
+	cacheEvictionBytes   = "bytes"   // GITHUB_CACHE_MAX_BYTESを超えた
+)
+
+var (
+	githubCacheEvictionsTotal = newCounterVec(
+		"giter_github_cache_evictions_total",
+		"GitHub API responses evicted from the in-memory cache by the limit that was exceeded (entries, bytes).",
+		"reason",
+	)
+	githubCacheSize = newGaugeVec(
+		"giter_github_cache_size",
+		"Size of the in-memory GitHub API cache (unit: entries, bytes, uncompressed_bytes).",
+		"unit",
+	)
+)
+
+func init() {
+	/* キャッシュの大きさは追加・削除のたびに更新するため、/metrics にも出力する */
+	registerMetric(githubCacheSize)
+}
+
+/*
+githubCacheStore はURLとAcceptヘッダーの組み合わせをキーとするレスポンスキャッシュ
+件数とレスポンスボディの合計のバイト数に上限を設け、超えた場合は最も長く使われていないものから削除する
+レスポンスボディはGITHUB_CACHE_COMPRESSの場合は圧縮して保持し、バイト数の上限は圧縮後の大きさで判定する
+*/
+type githubCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]*githubCacheEntry
+	lru     *list.List // 使われた順のキー（先頭が最も新しい）
+	bytes   int        // 保持しているレスポンスボディの合計のバイト数（圧縮後）
+	rawSize int        // 保持しているレスポンスボディの展開後の合計のバイト数
+}
+
+/* githubCache はアプリケーション全体で共有するGitHub APIのレスポンスキャッシュ */
+var githubCache = newGitHubCacheStore()
+
+/* newGitHubCacheStore は空のキャッシュを作成する */
+func newGitHubCacheStore() *githubCacheStore {
+	return &githubCacheStore{entries: map[string]*githubCacheEntry{}, lru: list.New()}
+}
+
+/*
+get はキーのレスポンスのコピーを返し、最も新しく使われたものにする（ない場合はfalse）
+StoredAtなどは再検証（touch）・expireGitHubCacheがロックを取得して書き換えるため、コピーはロックを取得した状態で作成する
+*/
+func (s *githubCacheStore) get(key string) (githubCacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	if entry == nil {
+		return githubCacheEntry{}, false
+	}
+	s.lru.MoveToFront(entry.element)
+	return *entry, true
+}
+
+/* touch はキーのレスポンスの取得日時を更新する（304で再検証した場合に使用、ない場合は何もしない） */
+func (s *githubCacheStore) touch(key string, storedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.entries[key]; entry != nil {
+		entry.StoredAt = storedAt
+	}
+}
+
+/*
+put はレスポンスを保存し、上限を超えた分を使われていない順に削除する
+
+引数:
+  key string - キャッシュのキー（githubCacheKey）
+  entry *githubCacheEntry - 保存するレスポンス
+  replace bool - すでにキャッシュがある場合に上書きするかどうか
+*/
+func (s *githubCacheStore) put(key string, entry *githubCacheEntry, replace bool) {
+	/* 圧縮はロックの外で行い、ほかのリクエストのキャッシュの参照を待たせない */
+	entry.rawSize = len(entry.Response.Body)
+	if githubCacheCompress && entry.rawSize >= githubCacheCompressMinBytes {
+		if compressed, ok := gzipBody(entry.Response.Body); ok {
+			entry.Response.Body, entry.compressed = compressed, true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old := s.entries[key]; old != nil {
+		if !replace {
+			return
+		}
+		s.remove(key, old)
+	}
+	entry.element = s.lru.PushFront(key)
+	entry.size = len(entry.Response.Body)
+	s.entries[key] = entry
+	s.bytes += entry.size
+	s.rawSize += entry.rawSize
+
+	for s.lru.Len() > 0 {
+		reason := ""
+		switch {
+		case githubCacheMaxEntries > 0 && len(s.entries) > githubCacheMaxEntries:
+			reason = cacheEvictionEntries
+		case githubCacheMaxBytes > 0 && s.bytes > githubCacheMaxBytes:
+			reason = cacheEvictionBytes
+		default:
+			s.updateSize()
+			return
+		}
+		oldest := s.lru.Back().Value.(string)
+		s.remove(oldest, s.entries[oldest])
+		githubCacheEvictionsTotal.Inc(reason)
+	}
+	s.updateSize()
+}
+
+/* remove はレスポンスを削除する（s.muのロックを取得してから呼び出す） */
+func (s *githubCacheStore) remove(key string, entry *githubCacheEntry) {
+	s.lru.Remove(entry.element)
+	delete(s.entries, key)
+	s.bytes -= entry.size
+	s.rawSize -= entry.rawSize
+}
+
+/* reset はすべてのレスポンスを削除し、削除した件数を返す */
+func (s *githubCacheStore) reset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.entries)
+	s.entries = map[string]*githubCacheEntry{}
+	s.lru.Init()
+	s.bytes, s.rawSize = 0, 0
+	s.updateSize()
+	return n
+}
+
+/* len は保持しているレスポンスの件数を返す */
+func (s *githubCacheStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+/* updateSize はキャッシュの大きさのメトリクスを更新する（s.muのロックを取得してから呼び出す） */
+func (s *githubCacheStore) updateSize() {
+	githubCacheSize.Set(float64(len(s.entries)), "entries")
+	githubCacheSize.Set(float64(s.bytes), "bytes")
+	githubCacheSize.Set(float64(s.rawSize), "uncompressed_bytes")
+}
+
+/* gzipBody はレスポンスボディを圧縮する（圧縮しても小さくならない場合はfalse） */
+func gzipBody(body []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil || buf.Len() >= len(body) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+/*
+body はキャッシュしたレスポンスボディを展開して返す
+展開できない場合（メモリ上のデータの破損）はログを出力して空のボディを返し、呼び出し元のデコードを失敗させる
+*/
+func (e *githubCacheEntry) body() []byte {
+	if !e.compressed {
+		return e.Response.Body
+	}
+	r, err := gzip.NewReader(bytes.NewReader(e.Response.Body))
+	if err == nil {
+		var body []byte
+		if body, err = io.ReadAll(r); err == nil {
+			return body
+		}
+	}
+	log.Error().Err(err).Msg("Failed to decompress cached GitHub response")
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/* githubFixtureDir は記録したGitHub APIのレスポンスを置くディレクトリ */
+const githubFixtureDir = "testdata/github"
+
+/*
+githubFixture は1つのリクエストと、それに対して記録したレスポンス
+responsesは受け取った順に返し、最後のレスポンスは以降のリクエストにも返す（202の後に200を返す集計APIなど）
+
+ヘッダーの値の置き換え:
+  {{base}} - 再生するサーバーのURL（LinkヘッダーのURLなど）
+  {{reset}} - 1時間後のUNIX時刻（X-RateLimit-Reset）
+*/
+type githubFixture struct {
+	Request struct {
+		Path  string `json:"path"`  // リクエストのパス（例: /repos/develop-suda/giter/commits）
+		Query string `json:"query"` // クエリ文字列（例: per_page=100）
+	} `json:"request"`
+	Responses []struct {
+		Status  int               `json:"status"`  // ステータスコード
+		Headers map[string]string `json:"headers"` // レスポンスヘッダー
+		Body    json.RawMessage   `json:"body"`    // レスポンスボディ
+	} `json:"responses"`
+}
+
+/* githubReplayServer は記録したレスポンスを返すGitHub APIの代わりのサーバー */
+type githubReplayServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	fixtures map[string]*githubFixture
+	served   map[string]int      // リクエストごとにレスポンスを返した回数（304を除く）
+	requests map[string][]string // リクエストごとに受け取ったIf-None-Match
+}
+
+/*
+newGitHubReplayServer はtestdata/githubの記録を読み込んでサーバーを起動し、githubAPIBaseをそのURLに向ける
+キャッシュ・レート制限の状態・予算・トークンはテストの前に初期化し、終了時に元に戻す
+*/
+func newGitHubReplayServer(t *testing.T, names ...string) *githubReplayServer {
+	t.Helper()
+	s := &githubReplayServer{fixtures: map[string]*githubFixture{}, served: map[string]int{}, requests: map[string][]string{}}
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(githubFixtureDir, name))
+		if err != nil {
+			t.Fatalf("read fixture %s: %v", name, err)
+		}
+		var fixture githubFixture
+		if err := json.Unmarshal(raw, &fixture); err != nil {
+			t.Fatalf("parse fixture %s: %v", name, err)
+		}
+		s.fixtures[fixtureKey(fixture.Request.Path, fixture.Request.Query)] = &fixture
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	base, token := githubAPIBase, githubToken
+	githubAPIBase, githubToken = s.URL, ""
+	resetGitHubClientState()
+	t.Cleanup(func() {
+		s.Close()
+		githubAPIBase, githubToken = base, token
+		resetGitHubClientState()
+	})
+	return s
+}
+
+/* resetGitHubClientState はキャッシュ・レート制限の状態・予算を初期化する */
+func resetGitHubClientState() {
+	githubCache.reset()
+	githubRateLimit.mu.Lock()
+	githubRateLimit.state = RateLimitState{}
+	githubRateLimit.mu.Unlock()
+	rateBudget.mu.Lock()
+	rateBudget.reset, rateBudget.used, rateBudget.denied = time.Time{}, map[string]int{}, map[string]int{}
+	rateBudget.mu.Unlock()
+}
+
+/* fixtureKey は記録を探すキー */
+func fixtureKey(path, query string) string {
+	return path + "?" + query
+}
+
+func (s *githubReplayServer) serve(w http.ResponseWriter, r *http.Request) {
+	key := fixtureKey(r.URL.Path, r.URL.RawQuery)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fixture := s.fixtures[key]
+	if fixture == nil {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	s.requests[key] = append(s.requests[key], r.Header.Get("If-None-Match"))
+	i := s.served[key]
+	if i >= len(fixture.Responses) {
+		i = len(fixture.Responses) - 1
+	}
+	resp := fixture.Responses[i]
+
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	for name, value := range resp.Headers {
+		value = strings.ReplaceAll(value, "{{base}}", s.URL)
+		w.Header().Set(name, strings.ReplaceAll(value, "{{reset}}", reset))
+	}
+	/* GitHubと同じく、ETagが一致する条件付きリクエストには304を返す（ボディなし） */
+	if etag := w.Header().Get("Etag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.served[key]++
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+/* hits はリクエストを受け取った回数を返す（304を含む） */
+func (s *githubReplayServer) hits(path, query string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests[fixtureKey(path, query)])
+}
+
+/* ifNoneMatch はリクエストごとに受け取ったIf-None-Matchを返す */
+func (s *githubReplayServer) ifNoneMatch(path, query string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests[fixtureKey(path, query)]...)
+}
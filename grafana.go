@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* grafanaTargetCommits は全リポジトリのコミット数の時系列を表すターゲット名 */
+	grafanaTargetCommits = "commits"
+	/* grafanaRepoTargetPrefix はリポジトリごとのコミット数のターゲット名の接頭辞（例: "commits:my-project"） */
+	grafanaRepoTargetPrefix = grafanaTargetCommits + ":"
+	/* grafanaMinInterval は時系列の集計間隔の下限 */
+	grafanaMinInterval = time.Minute
+)
+
+/*
+GrafanaQueryRequest はGrafanaのSimple JSONデータソースが送信する /query のリクエスト
+Infinityデータソースから同じ形式のJSONを送信する場合も同様に扱う
+*/
+type GrafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"` // 表示期間の開始日時
+		To   time.Time `json:"to"`   // 表示期間の終了日時
+	} `json:"range"`
+	IntervalMs    int64           `json:"intervalMs"`    // Grafanaが指定する集計間隔（ミリ秒）
+	MaxDataPoints int             `json:"maxDataPoints"` // 1系列あたりの最大データ点数
+	Targets       []GrafanaTarget `json:"targets"`       // 取得する系列
+}
+
+/* GrafanaTarget はクエリで指定する1つの系列 */
+type GrafanaTarget struct {
+	Target string `json:"target"` // ターゲット名（/search で返した名前）
+	RefID  string `json:"refId"`  // Grafana側のクエリID
+	Type   string `json:"type"`   // "timeserie"（デフォルト）または "table"
+}
+
+/*
+GrafanaTimeSeries は時系列のレスポンス
+Datapointsは [値, Unix時間（ミリ秒）] の配列
+*/
+type GrafanaTimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+/* GrafanaTable はテーブル形式のレスポンス */
+type GrafanaTable struct {
+	Type    string          `json:"type"` // 常に "table"
+	Columns []GrafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+/* GrafanaColumn はテーブルの列の定義 */
+type GrafanaColumn struct {
+	Text string `json:"text"` // 列名
+	Type string `json:"type"` // "time", "string", "number"
+}
+
+/*
+getGrafanaRoot はデータソースの接続テスト（"Save & test"）に応答する
+Simple JSONデータソースは設定したURLにGETし、200が返れば接続成功とみなす
+*/
+func getGrafanaRoot(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+/*
+postGrafanaSearch はクエリエディタで選択できるターゲット名の一覧を返す
+
+リクエストボディ:
+  {"target": "検索文字列"} - 指定した場合は部分一致するターゲットのみ返す（省略可）
+
+レスポンス:
+  成功時: 200 OK, ["commits", "commits:<リポジトリ名>", ...]
+  失敗時: 502 Bad Gateway（リポジトリ一覧を取得できない場合）
+*/
+func postGrafanaSearch(c *gin.Context) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	/* ボディなしのリクエストも受け付ける */
+	_ = c.ShouldBindJSON(&req)
+
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for Grafana search")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	targets := []string{}
+	for _, name := range append([]string{grafanaTargetCommits}, grafanaRepoTargets(repos)...) {
+		if strings.Contains(name, req.Target) {
+			targets = append(targets, name)
+		}
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+/* grafanaRepoTargets はリポジトリごとのターゲット名を名前順で返す */
+func grafanaRepoTargets(repos []Repository) []string {
+	targets := []string{}
+	for _, name := range repositoryNames(repos) {
+		targets = append(targets, grafanaRepoTargetPrefix+name)
+	}
+	return targets
+}
+
+/*
+postGrafanaQuery はターゲットごとのコミット数の時系列（またはコミット一覧のテーブル）を返す
+
+ターゲット:
+  commits - 全リポジトリのコミット数
+  commits:<リポジトリ名> - 指定したリポジトリのコミット数
+
+集計間隔はintervalMsを使用し、データ点がmaxDataPointsを超えないように広げる（下限は1分）
+
+レスポンス:
+  成功時: 200 OK, [GrafanaTimeSeries または GrafanaTable, ...]（targetsと同じ順）
+  失敗時: 400 Bad Request（期間・ターゲットが不正）, 502 Bad Gateway（GitHubから取得できない）
+*/
+func postGrafanaQuery(c *gin.Context) {
+	var req GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !req.Range.To.After(req.Range.From) {
+		respondError(c, http.StatusBadRequest, "range.to must be after range.from")
+		return
+	}
+
+	commits, repos, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch commits for Grafana query")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	known := map[string]bool{}
+	for _, repo := range repos {
+		known[repo.Name] = true
+	}
+
+	commits = requestRedaction(c).commits(commits)
+
+	step := grafanaInterval(req)
+	results := []interface{}{}
+	for _, target := range req.Targets {
+		selected := commits
+		if repo := strings.TrimPrefix(target.Target, grafanaRepoTargetPrefix); repo != target.Target {
+			if !known[repo] {
+				respondError(c, http.StatusBadRequest, fmt.Sprintf("unknown target: %s", target.Target))
+				return
+			}
+			selected = commitsForRepository(commits, repo)
+		} else if target.Target != grafanaTargetCommits {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("unknown target: %s", target.Target))
+			return
+		}
+
+		if target.Type == "table" {
+			results = append(results, grafanaCommitTable(selected, req.Range.From, req.Range.To))
+			continue
+		}
+
+		series := GrafanaTimeSeries{Target: target.Target, Datapoints: [][2]float64{}}
+		for _, b := range bucketCommits(selected, req.Range.From, req.Range.To, step) {
+			series.Datapoints = append(series.Datapoints, [2]float64{float64(b.Count), float64(b.Start.UnixMilli())})
+		}
+		results = append(results, series)
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+/* grafanaInterval はクエリの集計間隔を決める（intervalMsを基準に、maxDataPointsと下限で調整） */
+func grafanaInterval(req GrafanaQueryRequest) time.Duration {
+	step := time.Duration(req.IntervalMs) * time.Millisecond
+	if req.MaxDataPoints > 0 {
+		if floor := req.Range.To.Sub(req.Range.From) / time.Duration(req.MaxDataPoints); step < floor {
+			step = floor
+		}
+	}
+	if step < grafanaMinInterval {
+		step = grafanaMinInterval
+	}
+	return step
+}
+
+/* commitsForRepository は指定したリポジトリのコミットのみを返す */
+func commitsForRepository(commits []CommitHistory, repo string) []CommitHistory {
+	var selected []CommitHistory
+	for _, commit := range commits {
+		if commit.RepositoryName == repo {
+			selected = append(selected, commit)
+		}
+	}
+	return selected
+}
+
+/* grafanaCommitTable は期間内のコミットを日時・リポジトリ・メッセージ・SHAのテーブルにする */
+func grafanaCommitTable(commits []CommitHistory, from, to time.Time) GrafanaTable {
+	table := GrafanaTable{
+		Type: "table",
+		Columns: []GrafanaColumn{
+			{Text: "Time", Type: "time"},
+			{Text: "Repository", Type: "string"},
+			{Text: "Message", Type: "string"},
+			{Text: "SHA", Type: "string"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for _, commit := range commits {
+		if commit.CommitTime.Before(from) || !commit.CommitTime.Before(to) {
+			continue
+		}
+		subject := strings.SplitN(commit.CommitMessage, "\n", 2)[0]
+		table.Rows = append(table.Rows, []interface{}{commit.CommitTime.UnixMilli(), commit.RepositoryName, subject, commit.CommitSHA})
+	}
+	return table
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	/* graphDefaultLimit はコミットグラフに含めるコミット数の既定値（fetchCommitsの1ページ分） */
+	graphDefaultLimit = 100
+	/* graphMaxLimit はコミットグラフに含めるコミット数の上限（GitHub APIへのリクエストは100件ごとに1回） */
+	graphMaxLimit = 500
+)
+
+/* graphQuery は GET /api/repos/:owner/:repo/graph のクエリパラメータ */
+type graphQuery struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=500"` // 含めるコミット数（省略時はgraphDefaultLimit）
+}
+
+/*
+GraphBranch はコミットグラフに表示するブランチの先頭
+*/
+type GraphBranch struct {
+	Name    string `json:"name"`    // ブランチ名
+	SHA     string `json:"sha"`     // 先頭のコミットハッシュ（短縮形、7文字）
+	Default bool   `json:"default"` // デフォルトブランチの場合はtrue
+}
+
+/*
+CommitGraph は /api/repos/:owner/:repo/graph のレスポンス
+コミットのparentsで辺をたどり、ブランチの分岐とマージのDAGを描画する
+*/
+type CommitGraph struct {
+	Repository    string          `json:"repository"`     // リポジトリのフルネーム
+	DefaultBranch string          `json:"default_branch"` // デフォルトブランチ名
+	Commits       []CommitHistory `json:"commits"`        // デフォルトブランチから辿れるコミット（新しい順、parentsを含む）
+	Branches      []GraphBranch   `json:"branches"`       // ブランチの先頭（最大100件）
+	Truncated     bool            `json:"truncated"`      // limitで打ち切り、より古いコミットがある場合はtrue
+}
+
+/* parentSHAs はコミットの親のハッシュを短縮形で返す（親のない最初のコミットはnil） */
+func parentSHAs(commit Commit) []string {
+	if len(commit.Parents) == 0 {
+		return nil
+	}
+	parents := make([]string, 0, len(commit.Parents))
+	for _, parent := range commit.Parents {
+		parents = append(parents, shortSHA(parent.SHA))
+	}
+	return parents
+}
+
+/*
+fetchCommitGraph はデフォルトブランチのコミットを新しい順にlimit件まで取得する
+1ページ目は同期と同じURL（fetchCommits）のため、キャッシュとETagを共有する
+
+戻り値:
+  []Commit - 取得したコミット（最大limit件）
+  bool - limitで打ち切り、より古いコミットがある場合はtrue
+  error - 取得に失敗した場合のエラー
+*/
+func fetchCommitGraph(repoFullName string, limit int) ([]Commit, bool, error) {
+	commits, err := fetchCommits(repoFullName)
+	if err != nil {
+		return nil, false, err
+	}
+	more := len(commits) == 100
+	for page := 2; more && len(commits) < limit; page++ {
+		url := fmt.Sprintf("%s/repos/%s/commits?per_page=100&page=%d", githubAPIBase, repoFullName, page)
+		var next []Commit
+		if err := fetchGitHubJSON(upstreamOpCommits, url, githubAcceptV3, &next); err != nil {
+			return nil, false, err
+		}
+		commits = append(commits, next...)
+		more = len(next) == 100
+	}
+	if len(commits) > limit {
+		return commits[:limit], true, nil
+	}
+	return commits, more, nil
+}
+
+/*
+getRepositoryGraph はリポジトリのコミットと親コミットの関係（コミットグラフ）を返すAPIハンドラー
+
+パスパラメータ:
+  owner string - 所有者名（usernameのみ）
+  repo string - リポジトリ名
+
+クエリパラメータ:
+  limit - 含めるコミット数（1〜500、省略時は100）
+
+レスポンス:
+  成功時: 200 OK, CommitGraph
+  失敗時: 404 Not Found, 422 Unprocessable Entity（limitが不正）, 502 Bad Gateway
+
+注意:
+  - コミットはデフォルトブランチから辿れるもののみ（マージ済みのブランチのコミットを含む）
+  - 親がcommitsに含まれないコミット（limitの境界）は、親のハッシュのみを返す
+  - マージしていないブランチは先頭のハッシュのみをbranchesで返す
+*/
+func getRepositoryGraph(c *gin.Context) {
+	query := graphQuery{Limit: graphDefaultLimit}
+	if !bindQuery(c, &query) {
+		return
+	}
+	fullName, err := repoFullNameParam(c)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	detail, err := fetchRepository(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	commits, truncated, err := fetchCommitGraph(fullName, query.Limit)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	branches, err := fetchBranches(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+
+	graph := CommitGraph{
+		Repository:    fullName,
+		DefaultBranch: detail.DefaultBranch,
+		Commits:       make([]CommitHistory, 0, len(commits)),
+		Branches:      make([]GraphBranch, 0, len(branches)),
+		Truncated:     truncated,
+	}
+	for _, commit := range commits {
+		graph.Commits = append(graph.Commits, newCommitHistory(detail.Name, commit))
+	}
+	graph.Commits = requestRedaction(c).commits(graph.Commits)
+	for _, branch := range branches {
+		graph.Branches = append(graph.Branches, GraphBranch{
+			Name:    branch.Name,
+			SHA:     shortSHA(branch.Commit.SHA),
+			Default: branch.Name == detail.DefaultBranch,
+		})
+	}
+	respondJSON(c, http.StatusOK, graph)
+}
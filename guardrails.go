@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+レスポンスの大きさのガードレール
+ページネーションを指定せずにすべての件数を返すAPIで、件数・JSONの大きさが上限を超える場合は
+上限に収まる件数の1ページ目を返し、Linkヘッダー（rel="next"）と warning で続きがあることを知らせる
+*/
+
+var (
+	/*
+		maxResponseItems はページネーションを指定しない場合に1回のレスポンスで返す件数の上限
+		環境変数 MAX_RESPONSE_ITEMS で変更可能（デフォルト: 10000、0の場合は制限しない）
+	*/
+	maxResponseItems = getEnvInt("MAX_RESPONSE_ITEMS", 10000)
+	/*
+		maxResponseBytes はページネーションを指定しない場合に1回のレスポンスで返すJSONの大きさの上限（バイト）
+		環境変数 MAX_RESPONSE_BYTES で変更可能（デフォルト: 5MB、0の場合は制限しない）
+	*/
+	maxResponseBytes = getEnvInt("MAX_RESPONSE_BYTES", 5*1024*1024)
+	/*
+		maxPerPage はページ番号によるページネーションで指定できるper_pageの上限
+		環境変数 MAX_PER_PAGE で変更可能（デフォルト: 200）
+	*/
+	maxPerPage = getEnvInt("MAX_PER_PAGE", 200)
+	/*
+		maxPaginationDepth はページ番号で取得できる範囲（先頭からの件数）の上限
+		深いページはすべての件数を並べ替えてから切り出すため、これを超えるページはcursorかエクスポートで取得させる
+		環境変数 MAX_PAGINATION_DEPTH で変更可能（デフォルト: 10000、0の場合は制限しない）
+	*/
+	maxPaginationDepth = getEnvInt("MAX_PAGINATION_DEPTH", 10000)
+)
+
+/* oversizedResponseWarning は上限を超えたためページネーションに切り替えた場合のwarning */
+const oversizedResponseWarning = "response exceeded the size limit and was paginated; follow the Link header (rel=\"next\") for the remaining items"
+
+/*
+responseLimit はすべての件数をそのまま返すとガードレールを超えるかを判定し、1ページの件数を返す
+JSONの大きさはitemsをエンコードして測り、項目の平均の大きさで上限に収まる件数を求める
+
+引数:
+  items interface{} - 返す予定の配列
+  n int - itemsの件数
+
+戻り値:
+  int - 上限に収まる1ページの件数（超えない場合はn）
+  bool - 上限を超える場合はtrue
+*/
+func responseLimit(items interface{}, n int) (int, bool) {
+	perPage := n
+	if maxResponseItems > 0 && perPage > maxResponseItems {
+		perPage = maxResponseItems
+	}
+	if maxResponseBytes > 0 && n > 0 {
+		if body, err := json.Marshal(items); err == nil && len(body) > maxResponseBytes {
+			if fit := int(int64(n) * int64(maxResponseBytes) / int64(len(body))); fit < perPage {
+				perPage = fit
+			}
+		}
+	}
+	if perPage < 1 {
+		perPage = 1
+	}
+	return perPage, perPage < n
+}
+
+/*
+paginationFieldErrors はページ番号によるページネーションのper_pageとページの深さを検証する（validateFieldsから呼び出す）
+
+引数:
+  page int - ページ番号（1始まり、未指定の場合は1）
+  perPage int - 1ページあたりの件数
+  alternative string - 深いページを拒否する場合に案内する代わりの取得方法
+
+戻り値:
+  []FieldError - per_pageがMAX_PER_PAGEを超える場合、ページの先頭がMAX_PAGINATION_DEPTH件目を超える場合のエラー
+*/
+func paginationFieldErrors(page, perPage int, alternative string) []FieldError {
+	var fields []FieldError
+	if perPage > maxPerPage {
+		fields = append(fields, FieldError{Field: "per_page", Rule: "max", Message: fmt.Sprintf("must be at most %d", maxPerPage)})
+	}
+	if maxPaginationDepth > 0 && (page-1)*perPage >= maxPaginationDepth {
+		fields = append(fields, FieldError{
+			Field:   "page",
+			Rule:    "depth",
+			Message: fmt.Sprintf("pages beyond the first %d items are not available; %s", maxPaginationDepth, alternative),
+		})
+	}
+	return fields
+}
+
+/*
+setNextLink は次のページのURLをLinkヘッダー（RFC 8288、rel="next"）に設定する
+今のリクエストのクエリパラメータをnextで上書きし、removeのパラメータを取り除いたURLにする
+*/
+func setNextLink(c *gin.Context, next url.Values, remove ...string) {
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", pageLinkURL(c, next, remove...)))
+}
+
+/* pageLinkURL は今のリクエストのクエリパラメータをnextで上書きし、removeのパラメータを取り除いたURLを返す */
+func pageLinkURL(c *gin.Context, next url.Values, remove ...string) string {
+	query := c.Request.URL.Query()
+	for _, name := range remove {
+		query.Del(name)
+	}
+	for name, values := range next {
+		query[name] = values
+	}
+	u := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
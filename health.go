@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+healthCheck はサーバーとGitHub APIへの疎通を確認するヘルスチェック用ハンドラー
+ロードバランサーや監視から頻繁に呼ばれるため、以下のようにしている:
+  - GitHubの /rate_limit を呼び出す（レート制限の回数を消費しない、GITHUB_TOKENを設定している場合はトークンの状態を確認する）
+  - キャッシュを経由せず、毎回実際に疎通を確認する
+  - タイムアウトにはhealth操作の短い設定（GITHUB_TIMEOUT_HEALTH_*）を使用する
+
+レスポンス:
+  成功時: 200 OK, {"status": "ok", "github": "ok", "latency_ms": 応答時間}
+  GitHubに到達できない場合: 503 Service Unavailable, {"status": "degraded", "github": "unreachable", "error": エラー内容}
+*/
+func healthCheck(c *gin.Context) {
+	start := time.Now()
+
+	req, err := http.NewRequest("GET", githubAPIBase+"/rate_limit", nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	req.Header.Set("Accept", githubAcceptV3)
+	authorizeGitHubRequest(req, upstreamOpHealth)
+
+	resp, err := upstreamClient(upstreamOpHealth).Do(req)
+	if err != nil {
+		log.Warn().Err(err).Msg("Health check: GitHub API unreachable")
+		respondJSON(c, http.StatusServiceUnavailable, gin.H{"status": "degraded", "github": "unreachable", "error": err.Error()})
+		return
+	}
+	resp.Body.Close()
+	updateRateLimit(resp.Header)
+
+	respondJSON(c, http.StatusOK, gin.H{"status": "ok", "github": "ok", "latency_ms": time.Since(start).Milliseconds()})
+}
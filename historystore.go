@@ -0,0 +1,517 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+HistoryStore はアーカイブ・バックフィルで保存したコミット履歴（記録用の保存先）を抽象化するインターフェース
+コミットは年月（UTC、"2006-01"）ごとに保存し、リポジトリ名とSHA（archiveKey）で重複を除く
+*/
+type HistoryStore interface {
+	/*
+		Append は年月にまだ保存されていないコミットを追加し、追加した件数を返す
+		追加するコミットがない場合は何も書き込まない
+	*/
+	Append(month string, commits []CommitHistory) (int, error)
+	/* LoadAll は保存済みのすべてのコミットを返す（新しい月から順に、各月の中は新しい順） */
+	LoadAll() ([]CommitHistory, error)
+	/* Purge は保存済みのすべてのコミットを削除し、削除した件数を返す（DELETE /api/admin/data で使用） */
+	Purge() (int, error)
+	/* Prune はコミット日時がbeforeより前のコミットを削除し、削除した件数を返す（保持期間の適用に使用） */
+	Prune(before time.Time) (int, error)
+}
+
+/*
+newHistoryStoreFromEnv は環境変数の設定に応じたHistoryStoreを作成する
+
+使用する環境変数:
+  HISTORY_STORE          - "file"（デフォルト）、"memory"、"sqlite" または "postgres"
+  HISTORY_STORE_DSN      - sqlite・postgresの接続先（sqliteはファイルのパス、postgresは接続URL）
+  HISTORY_STORE_FIXTURES - memoryの初期データ（CommitHistoryの配列のJSONファイル、省略時は空）
+
+注意:
+  - sqlite・postgresはデータベースドライバーを含めてビルドした場合のみ使用できる（-tags sqlite / -tags postgres）
+*/
+func newHistoryStoreFromEnv() (HistoryStore, error) {
+	switch kind := getEnv("HISTORY_STORE", "file"); kind {
+	case "file":
+		return newFileHistoryStore(archiveDir()), nil
+	case "memory":
+		store := newMemoryHistoryStore()
+		if path := getEnv("HISTORY_STORE_FIXTURES", ""); path != "" {
+			if err := store.loadFixtures(path); err != nil {
+				return nil, err
+			}
+		}
+		return store, nil
+	case "sqlite", "postgres":
+		dsn := getEnv("HISTORY_STORE_DSN", "")
+		if dsn == "" {
+			return nil, fmt.Errorf("HISTORY_STORE_DSN is required when HISTORY_STORE=%s", kind)
+		}
+		return newSQLHistoryStore(kind, dsn)
+	default:
+		return nil, fmt.Errorf("unsupported HISTORY_STORE: %s (supported: file, memory, sqlite, postgres)", kind)
+	}
+}
+
+/*
+history はアプリケーション全体で共有するHistoryStore
+main関数の起動時に newHistoryStoreFromEnv で初期化する
+*/
+var history HistoryStore = newFileHistoryStore(archiveDir())
+
+/* addMissingCommits はarchivedに含まれないコミットを追加したスライスと追加した件数を返す */
+func addMissingCommits(archived, commits []CommitHistory) ([]CommitHistory, int) {
+	seen := make(map[string]bool, len(archived))
+	for _, commit := range archived {
+		seen[archiveKey(commit)] = true
+	}
+	added := 0
+	for _, commit := range commits {
+		if !seen[archiveKey(commit)] {
+			seen[archiveKey(commit)] = true
+			archived = append(archived, commit)
+			added++
+		}
+	}
+	return archived, added
+}
+
+/* sortNewestFirst はコミットを日時の新しい順に並べ替える（同じ日時の場合は元の順序を保つ） */
+func sortNewestFirst(commits []CommitHistory) {
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].CommitTime.After(commits[j].CommitTime) })
+}
+
+/*
+fileHistoryStore はDATA_DIR/archive に年月ごとのgzip圧縮したJSONファイル（commits-2023-04.json.gz）として保存する実装
+*/
+type fileHistoryStore struct {
+	dir string
+	mu  sync.Mutex // ファイルの読み書きを直列化するロック
+}
+
+/* newFileHistoryStore はディレクトリに保存するHistoryStoreを作成する */
+func newFileHistoryStore(dir string) *fileHistoryStore {
+	return &fileHistoryStore{dir: dir}
+}
+
+func (s *fileHistoryStore) Append(month string, commits []CommitHistory) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, archiveFilePrefix+month+archiveFileSuffix)
+	archived, err := readArchiveFile(path)
+	if err != nil {
+		return 0, err
+	}
+	archived, added := addMissingCommits(archived, commits)
+	if added == 0 {
+		return 0, nil
+	}
+	sortNewestFirst(archived)
+	if err := writeArchiveFile(path, archived); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+func (s *fileHistoryStore) LoadAll() ([]CommitHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, archiveFilePrefix) && strings.HasSuffix(name, archiveFileSuffix) {
+			names = append(names, name)
+		}
+	}
+	/* ファイル名は年月を含むため、名前の降順 = 新しい月の順 */
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	var commits []CommitHistory
+	for _, name := range names {
+		archived, err := readArchiveFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, archived...)
+	}
+	return commits, nil
+}
+
+func (s *fileHistoryStore) Purge() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archive directory: %w", err)
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archiveFilePrefix) || !strings.HasSuffix(name, archiveFileSuffix) {
+			continue
+		}
+		path := filepath.Join(s.dir, name)
+		archived, err := readArchiveFile(path)
+		if err != nil {
+			return purged, err
+		}
+		if err := os.Remove(path); err != nil {
+			return purged, fmt.Errorf("failed to remove archive %s: %w", path, err)
+		}
+		purged += len(archived)
+	}
+	return purged, nil
+}
+
+func (s *fileHistoryStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archive directory: %w", err)
+	}
+
+	/* beforeの月より新しい月のファイルには対象のコミットがないため読み込まない */
+	lastMonth := before.UTC().Format(archiveMonthFormat)
+	pruned := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archiveFilePrefix) || !strings.HasSuffix(name, archiveFileSuffix) {
+			continue
+		}
+		if strings.TrimSuffix(strings.TrimPrefix(name, archiveFilePrefix), archiveFileSuffix) > lastMonth {
+			continue
+		}
+		path := filepath.Join(s.dir, name)
+		archived, err := readArchiveFile(path)
+		if err != nil {
+			return pruned, err
+		}
+		kept := commitsSince(archived, before)
+		switch {
+		case len(kept) == len(archived):
+			continue
+		case len(kept) == 0:
+			err = os.Remove(path)
+		default:
+			err = writeArchiveFile(path, kept)
+		}
+		if err != nil {
+			return pruned, fmt.Errorf("failed to prune archive %s: %w", path, err)
+		}
+		pruned += len(archived) - len(kept)
+	}
+	return pruned, nil
+}
+
+/* commitsSince はコミット日時がbefore以降のコミットを返す（元の順序を保つ） */
+func commitsSince(commits []CommitHistory, before time.Time) []CommitHistory {
+	var kept []CommitHistory
+	for _, commit := range commits {
+		if !commit.CommitTime.Before(before) {
+			kept = append(kept, commit)
+		}
+	}
+	return kept
+}
+
+/*
+memoryHistoryStore はプロセスのメモリに保存する実装
+再起動で内容が失われるため、開発やHTTPのハンドラー全体を決まった初期データで確認する場合に使用する
+*/
+type memoryHistoryStore struct {
+	mu     sync.Mutex
+	months map[string][]CommitHistory // 年月ごとのコミット（各月の中は新しい順）
+}
+
+/* newMemoryHistoryStore は空のメモリ上のHistoryStoreを作成する */
+func newMemoryHistoryStore() *memoryHistoryStore {
+	return &memoryHistoryStore{months: map[string][]CommitHistory{}}
+}
+
+/* loadFixtures はCommitHistoryの配列のJSONファイルを読み込み、コミットの年月ごとに追加する */
+func (s *memoryHistoryStore) loadFixtures(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read history fixtures: %w", err)
+	}
+	var commits []CommitHistory
+	if err := json.Unmarshal(data, &commits); err != nil {
+		return fmt.Errorf("failed to decode history fixtures %s: %w", path, err)
+	}
+	byMonth := map[string][]CommitHistory{}
+	for _, commit := range commits {
+		byMonth[archiveMonth(commit)] = append(byMonth[archiveMonth(commit)], commit)
+	}
+	for month, monthCommits := range byMonth {
+		s.Append(month, monthCommits)
+	}
+	log.Info().Str("path", path).Int("commits", len(commits)).Msg("Loaded history fixtures")
+	return nil
+}
+
+func (s *memoryHistoryStore) Append(month string, commits []CommitHistory) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archived, added := addMissingCommits(append([]CommitHistory{}, s.months[month]...), commits)
+	if added > 0 {
+		sortNewestFirst(archived)
+		s.months[month] = archived
+	}
+	return added, nil
+}
+
+func (s *memoryHistoryStore) LoadAll() ([]CommitHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	months := make([]string, 0, len(s.months))
+	for month := range s.months {
+		months = append(months, month)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+
+	var commits []CommitHistory
+	for _, month := range months {
+		commits = append(commits, s.months[month]...)
+	}
+	return commits, nil
+}
+
+func (s *memoryHistoryStore) Purge() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for _, commits := range s.months {
+		purged += len(commits)
+	}
+	s.months = map[string][]CommitHistory{}
+	return purged, nil
+}
+
+func (s *memoryHistoryStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruned := 0
+	for month, commits := range s.months {
+		kept := commitsSince(commits, before)
+		pruned += len(commits) - len(kept)
+		if len(kept) == 0 {
+			delete(s.months, month)
+			continue
+		}
+		s.months[month] = kept
+	}
+	return pruned, nil
+}
+
+/*
+historyDialect はSQLの実装でデータベースごとに異なる設定
+*/
+type historyDialect struct {
+	driver      string             // database/sqlのドライバー名
+	placeholder func(n int) string // n番目（1始まり）のパラメーターのプレースホルダー
+}
+
+/*
+historyDialects はHISTORY_STOREの値ごとのSQLの設定
+ドライバーは sqlite（modernc.org/sqlite）・postgres（github.com/jackc/pgx/v5/stdlib）のビルドタグで登録する
+*/
+var historyDialects = map[string]historyDialect{
+	"sqlite":   {driver: "sqlite", placeholder: func(int) string { return "?" }},
+	"postgres": {driver: "pgx", placeholder: func(n int) string { return fmt.Sprintf("$%d", n) }},
+}
+
+/*
+sqlHistoryStore はSQLite・PostgreSQLの archived_commits テーブルに保存する実装
+コミットはCommitHistoryのJSONのまま保存し、リポジトリ名とSHAを主キーにする
+*/
+type sqlHistoryStore struct {
+	db      *sql.DB
+	dialect historyDialect
+}
+
+/*
+newSQLHistoryStore はデータベースに接続する
+テーブルは起動時に migrateHistoryStore でマイグレーション（migrations/*.sql）を適用して作成する
+
+引数:
+  kind string - "sqlite" または "postgres"（historyDialectsのキー）
+  dsn string - 接続先
+
+戻り値:
+  error - ドライバーが登録されていない（ビルドタグなしでビルドした）場合や、接続に失敗した場合のエラー
+*/
+func newSQLHistoryStore(kind, dsn string) (*sqlHistoryStore, error) {
+	dialect := historyDialects[kind]
+	if !containsString(sql.Drivers(), dialect.driver) {
+		return nil, fmt.Errorf("database driver %q is not built in (rebuild with -tags %s)", dialect.driver, kind)
+	}
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to history database: %w", err)
+	}
+	return &sqlHistoryStore{db: db, dialect: dialect}, nil
+}
+
+func (s *sqlHistoryStore) Append(month string, commits []CommitHistory) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin archive transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p := s.dialect.placeholder
+	stmt, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO archived_commits (repository_name, commit_sha, month, data) VALUES (%s, %s, %s, %s) ON CONFLICT DO NOTHING",
+		p(1), p(2), p(3), p(4)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare archive insert: %w", err)
+	}
+	defer stmt.Close()
+
+	added := 0
+	for _, commit := range commits {
+		data, err := json.Marshal(commit)
+		if err != nil {
+			return 0, err
+		}
+		result, err := stmt.Exec(commit.RepositoryName, commit.CommitSHA, month, string(data))
+		if err != nil {
+			return 0, fmt.Errorf("failed to archive commit %s: %w", archiveKey(commit), err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			added += int(n)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archive transaction: %w", err)
+	}
+	return added, nil
+}
+
+func (s *sqlHistoryStore) LoadAll() ([]CommitHistory, error) {
+	rows, err := s.db.Query("SELECT month, data FROM archived_commits ORDER BY month DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archived commits: %w", err)
+	}
+	defer rows.Close()
+
+	var commits []CommitHistory
+	start, current := 0, ""
+	for rows.Next() {
+		var month, data string
+		if err := rows.Scan(&month, &data); err != nil {
+			return nil, fmt.Errorf("failed to read archived commit: %w", err)
+		}
+		var commit CommitHistory
+		if err := json.Unmarshal([]byte(data), &commit); err != nil {
+			return nil, fmt.Errorf("failed to decode archived commit: %w", err)
+		}
+		/* 月の中の順序はSQLで保証されないため、月が変わるごとに日時で並べ替える */
+		if month != current {
+			sortNewestFirst(commits[start:])
+			start, current = len(commits), month
+		}
+		commits = append(commits, commit)
+	}
+	sortNewestFirst(commits[start:])
+	return commits, rows.Err()
+}
+
+func (s *sqlHistoryStore) Purge() (int, error) {
+	result, err := s.db.Exec("DELETE FROM archived_commits")
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge archived commits: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil
+	}
+	return int(n), nil
+}
+
+/*
+Prune はbeforeの月以前の行を読み込み、コミット日時がbeforeより前の行を削除する
+コミット日時はdataのJSONにのみ含まれるため、SQLの条件では月までしか絞り込めない
+*/
+func (s *sqlHistoryStore) Prune(before time.Time) (int, error) {
+	p := s.dialect.placeholder
+	rows, err := s.db.Query(fmt.Sprintf("SELECT data FROM archived_commits WHERE month <= %s", p(1)), before.UTC().Format(archiveMonthFormat))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query archived commits: %w", err)
+	}
+	var expired []CommitHistory
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read archived commit: %w", err)
+		}
+		var commit CommitHistory
+		if err := json.Unmarshal([]byte(data), &commit); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode archived commit: %w", err)
+		}
+		if commit.CommitTime.Before(before) {
+			expired = append(expired, commit)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query archived commits: %w", err)
+	}
+
+	pruned := 0
+	for _, commit := range expired {
+		result, err := s.db.Exec(fmt.Sprintf("DELETE FROM archived_commits WHERE repository_name = %s AND commit_sha = %s", p(1), p(2)),
+			commit.RepositoryName, commit.CommitSHA)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to prune archived commit %s: %w", archiveKey(commit), err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			pruned += int(n)
+		}
+	}
+	return pruned, nil
+}
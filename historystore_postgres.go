@@ -0,0 +1,9 @@
+//go:build postgres
+
+package main
+
+/*
+HISTORY_STORE=postgres で使用するPostgreSQLのドライバー（pgxのdatabase/sql互換のドライバー）
+ドライバーはgo.modに含めており、go build -tags postgres でビルドした場合のみ組み込む
+*/
+import _ "github.com/jackc/pgx/v5/stdlib"
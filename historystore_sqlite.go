@@ -0,0 +1,9 @@
+//go:build sqlite
+
+package main
+
+/*
+HISTORY_STORE=sqlite で使用するSQLiteのドライバー（cgo不要の modernc.org/sqlite）
+ドライバーはgo.modに含めており、go build -tags sqlite でビルドした場合のみ組み込む
+*/
+import _ "modernc.org/sqlite"
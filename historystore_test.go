@@ -0,0 +1,200 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* historyFixturePath はメモリ上のHistoryStoreの初期データ（HISTORY_STORE_FIXTURESと同じ形式） */
+const historyFixturePath = "testdata/commits.json"
+
+/* testAdminToken はテストで使用するADMIN_TOKEN */
+const testAdminToken = "test-admin-token"
+
+/*
+newHistoryTestRouter はHTTPの層全体（newRouter）を、メモリ上のHistoryStoreと記録したGitHub APIのレスポンスで作成する
+DATA_DIRは一時ディレクトリに向け、検索・集計のビュー・レスポンスのキャッシュは前後で初期化する
+*/
+func newHistoryTestRouter(t *testing.T) (*gin.Engine, *memoryHistoryStore) {
+	t.Helper()
+	newGitHubReplayServer(t, "repos.json", "commits.json", "commits_dotfiles.json", "profile.json")
+
+	store := newMemoryHistoryStore()
+	if err := store.loadFixtures(historyFixturePath); err != nil {
+		t.Fatalf("load fixtures: %v", err)
+	}
+	dir, store0, token, keys := dataDir, history, adminToken, apiKeys
+	dataDir, history = t.TempDir(), store
+	adminToken = testAdminToken
+	apiKeys = loadAPIKeys(adminToken, "")
+	resetHistoryViews()
+	t.Cleanup(func() {
+		/* 同期の後に追加した集計・フォロワーのジョブが一時ディレクトリに書き込み終えるのを待つ */
+		waitForIdleJobs(t)
+		dataDir, history, adminToken, apiKeys = dir, store0, token, keys
+		resetHistoryViews()
+	})
+
+	gin.SetMode(gin.TestMode)
+	return newRouter(&backupHandlers{}), store
+}
+
+/* waitForIdleJobs はジョブキューの待機中・実行中のジョブがなくなるまで待つ */
+func waitForIdleJobs(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for status := jobs.status(); status.Depth > 0 || status.Running > 0; status = jobs.status() {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not finish: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+/* resetHistoryViews はHistoryStoreから作成する検索・集計のビューとレスポンスのキャッシュを破棄する */
+func resetHistoryViews() {
+	commitSearch.reset()
+	statsViews.reset()
+	clearResponseCache()
+}
+
+/* serveJSON はリクエストを処理し、ステータスを確認してレスポンスのJSONをoutにデコードする */
+func serveJSON(t *testing.T, r *gin.Engine, method, target string, status int, out interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Accept", "application/json")
+	if method != http.MethodGet {
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != status {
+		t.Fatalf("%s %s: status %d, want %d, body %s", method, target, w.Code, status, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, target, err)
+		}
+	}
+}
+
+func TestMemoryHistoryStoreFixtures(t *testing.T) {
+	store := newMemoryHistoryStore()
+	if err := store.loadFixtures(historyFixturePath); err != nil {
+		t.Fatalf("load fixtures: %v", err)
+	}
+	commits, _ := store.LoadAll()
+	if len(commits) != 3 || commits[0].CommitSHA != "b41c9e2" {
+		t.Fatalf("LoadAll = %+v, want 3 commits newest first", commits)
+	}
+
+	/* 同じコミットは追加しない */
+	if added, _ := store.Append("2025-02", commits[1:]); added != 0 {
+		t.Errorf("Append of archived commits added %d", added)
+	}
+	if pruned, _ := store.Prune(commits[0].CommitTime); pruned != 2 {
+		t.Errorf("Prune removed %d commits, want 2", pruned)
+	}
+	if purged, _ := store.Purge(); purged != 1 {
+		t.Errorf("Purge removed %d commits, want 1", purged)
+	}
+}
+
+func TestExportIncludesStore(t *testing.T) {
+	r, _ := newHistoryTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/export", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != exportHistoryFile {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		var commits []CommitHistory
+		if err := json.Unmarshal(data, &commits); err != nil {
+			t.Fatalf("decode %s: %v", f.Name, err)
+		}
+		if len(commits) != 3 {
+			t.Errorf("%s has %d commits, want the 3 in the store", f.Name, len(commits))
+		}
+		return
+	}
+	t.Fatalf("archive has no %s", exportHistoryFile)
+}
+
+func TestGitHistoryReadsArchiveFromStore(t *testing.T) {
+	r, _ := newHistoryTestRouter(t)
+
+	var hot []CommitHistory
+	serveJSON(t, r, http.MethodGet, "/api/git-history", http.StatusOK, &hot)
+	if len(hot) != 2 {
+		t.Fatalf("git-history returned %d commits, want the 2 from GitHub", len(hot))
+	}
+
+	var all []CommitHistory
+	serveJSON(t, r, http.MethodGet, "/api/git-history?include_archive=true", http.StatusOK, &all)
+	if len(all) != 5 {
+		t.Fatalf("git-history?include_archive=true returned %d commits, want 5", len(all))
+	}
+	found := false
+	for _, commit := range all {
+		found = found || (commit.RepositoryName == "dotfiles" && commit.CommitSHA == "e93b0c1")
+	}
+	if !found {
+		t.Errorf("archived commit e93b0c1 is missing: %+v", all)
+	}
+}
+
+func TestCommitSearchSeedsFromStore(t *testing.T) {
+	r, _ := newHistoryTestRouter(t)
+
+	var resp CommitSearchResponse
+	serveJSON(t, r, http.MethodGet, "/api/search?q=digest", http.StatusOK, &resp)
+	if resp.Total != 1 || resp.Results[0].CommitSHA != "7d20a5f" {
+		t.Fatalf("search = %+v, want the archived digest commit", resp)
+	}
+}
+
+func TestDeleteDataPurgesStore(t *testing.T) {
+	r, store := newHistoryTestRouter(t)
+
+	var plan DataDeletionPlan
+	serveJSON(t, r, http.MethodDelete, "/api/admin/data?user="+username, http.StatusPreconditionRequired, &plan)
+	if plan.Counts["commits"] != 3 || plan.ConfirmationToken == "" {
+		t.Fatalf("plan = %+v, want 3 archived commits and a confirmation token", plan)
+	}
+
+	serveJSON(t, r, http.MethodDelete, "/api/admin/data?user="+username+"&confirm="+plan.ConfirmationToken, http.StatusOK, nil)
+	if commits, _ := store.LoadAll(); len(commits) != 0 {
+		t.Errorf("store still has %d commits after deletion", len(commits))
+	}
+
+	var resp CommitSearchResponse
+	serveJSON(t, r, http.MethodGet, "/api/search?q=digest", http.StatusOK, &resp)
+	if resp.Total != 0 {
+		t.Errorf("search still finds %d deleted commits", resp.Total)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+const (
+	/* localesDir はメッセージカタログ（<言語>.json）を置くディレクトリ */
+	localesDir = "locales"
+	/* languageContextKey はgin.Contextにリクエストの言語を保存する際のキー */
+	languageContextKey = "language"
+	/* localizerContextKey はgin.Contextにリクエストの言語のLocalizerを保存する際のキー */
+	localizerContextKey = "localizer"
+)
+
+/*
+supportedLanguages は対応している言語
+先頭の言語（日本語）は、Accept-Languageが未指定または対応していない場合に使用する
+*/
+var supportedLanguages = []language.Tag{language.Japanese, language.English}
+
+/* languageMatcher は?lang=とAccept-Languageから対応言語を選ぶ */
+var languageMatcher = language.NewMatcher(supportedLanguages)
+
+/*
+i18nBundle はメッセージカタログを保持するバンドル
+翻訳が見つからない場合のフォールバックは英語とする
+（英語のカタログにないメッセージIDは、IDそのもの（英語のメッセージ）を返す）
+*/
+var i18nBundle = newI18nBundle()
+
+func newI18nBundle() *i18n.Bundle {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+	return bundle
+}
+
+/*
+loadLocales はディレクトリ内のメッセージカタログ（ja.json, en.jsonなど）を読み込む
+ファイル名の言語タグが、そのカタログの言語になる
+
+カタログの形式:
+  {"メッセージID": "翻訳", ...}
+  翻訳には {{.Username}} のようにテンプレートのデータを埋め込める
+
+注意:
+  - APIのエラーメッセージは英語のメッセージそのものをメッセージIDとし、日本語のカタログにのみ翻訳を置く
+*/
+func loadLocales(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := i18nBundle.LoadMessageFile(path); err != nil {
+			return err
+		}
+	}
+	log.Info().Int("catalogs", len(paths)).Msg("Message catalogs loaded")
+	return nil
+}
+
+/*
+i18nMiddleware はリクエストの言語を決定するミドルウェア
+優先順位:
+  1. クエリパラメータ ?lang=en
+  2. Accept-Languageヘッダー
+  3. 日本語
+
+レスポンスにはContent-Languageヘッダーを付ける
+*/
+func i18nMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, _ := language.MatchStrings(languageMatcher, c.Query("lang"), c.GetHeader("Accept-Language"))
+		base, _ := tag.Base()
+		lang := base.String()
+
+		c.Set(languageContextKey, lang)
+		c.Set(localizerContextKey, i18n.NewLocalizer(i18nBundle, lang))
+		c.Header("Content-Language", lang)
+		c.Header("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+/* requestLanguage はリクエストの言語（"ja" または "en"）を返す */
+func requestLanguage(c *gin.Context) string {
+	if lang := c.GetString(languageContextKey); lang != "" {
+		return lang
+	}
+	base, _ := supportedLanguages[0].Base()
+	return base.String()
+}
+
+/*
+localize はメッセージIDをリクエストの言語に翻訳する
+
+引数:
+  c *gin.Context - リクエストのコンテキスト
+  id string - メッセージID
+  data map[string]interface{} - 翻訳に埋め込むデータ（不要ならnil）
+
+戻り値:
+  string - 翻訳したメッセージ（翻訳が見つからない場合はメッセージIDそのもの）
+*/
+func localize(c *gin.Context, id string, data map[string]interface{}) string {
+	localizer, ok := c.Value(localizerContextKey).(*i18n.Localizer)
+	if !ok {
+		localizer = i18n.NewLocalizer(i18nBundle, requestLanguage(c))
+	}
+
+	message, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: id, TemplateData: data})
+	if err != nil {
+		var notFound *i18n.MessageNotFoundErr
+		if !errors.As(err, &notFound) {
+			log.Warn().Err(err).Str("message_id", id).Msg("Failed to localize message")
+		}
+		return id
+	}
+	return message
+}
+
+/*
+clientMessages はフロントエンドのJavaScriptで使用する翻訳をまとめて返す
+テンプレートで window に埋め込み、t('メッセージID') で参照する
+*/
+func clientMessages(c *gin.Context) map[string]string {
+	return map[string]string{
+		"notifications.empty":    localize(c, "notifications.empty", nil),
+		"commits.view_on_github": localize(c, "commits.view_on_github", nil),
+		/* {status} はJavaScript側でステータスコードに置き換える */
+		"error.http": localize(c, "error.http", map[string]interface{}{"Status": "{status}"}),
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* idempotencyKeyHeader は再送されたリクエストを識別するキーを指定するヘッダー名 */
+	idempotencyKeyHeader = "Idempotency-Key"
+	/* idempotencyReplayedHeader は保存したレスポンスを返した場合に付けるヘッダー名 */
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	/* maxIdempotencyKeyLength はキーの最大の長さ */
+	maxIdempotencyKeyLength = 255
+)
+
+/*
+idempotencyTTL はキーごとのレスポンスを保存する期間
+環境変数 IDEMPOTENCY_TTL で変更可能（デフォルト: 24時間）
+*/
+var idempotencyTTL = parseDurationEnv("IDEMPOTENCY_TTL", 24*time.Hour)
+
+/*
+idempotencyEntry はキーに対応するリクエストの状態と保存したレスポンス
+*/
+type idempotencyEntry struct {
+	fingerprint string      // メソッド・パス・ボディのハッシュ（同じキーで異なるリクエストを検出する）
+	done        bool        // レスポンスを保存済みの場合はtrue（falseの場合は処理中）
+	status      int         // HTTPステータスコード
+	header      http.Header // 返したレスポンスヘッダー
+	body        []byte      // 返したレスポンスボディ
+	storedAt    time.Time   // レスポンスを保存した日時
+}
+
+/* idempotencyStore はキーごとのレスポンスをメモリ上に保持するストア */
+var idempotencyStore = struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}{entries: map[string]*idempotencyEntry{}}
+
+/*
+idempotencyMiddleware は Idempotency-Key を指定したPOSTリクエストのレスポンスを保存し、
+同じキーで再送されたリクエストにはハンドラーを実行せずに保存したレスポンスを返すミドルウェア
+自動化ツールがタイムアウトなどで再送しても、バックフィルやバックアップを重複して開始しない
+
+レスポンス:
+  同じキーのリクエストが処理中の場合: 409 Conflict
+  同じキーで異なるリクエスト（パス・ボディ）の場合: 422 Unprocessable Entity
+  保存済みの場合: 保存したレスポンス（Idempotent-Replayed: true）
+
+注意:
+  - キーは管理者の認証を通過した後に照合する（認証前のリクエストでキーを埋めさせない）
+  - 5xxのレスポンスは保存せず、同じキーで再試行できる
+  - 保存はプロセスのメモリ上のみで、再起動すると失われる
+*/
+func idempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortWithError(c, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		storeKey := c.Request.URL.Path + " " + key
+
+		idempotencyStore.mu.Lock()
+		pruneIdempotencyEntries(time.Now())
+		entry, ok := idempotencyStore.entries[storeKey]
+		if !ok {
+			entry = &idempotencyEntry{fingerprint: fingerprint}
+			idempotencyStore.entries[storeKey] = entry
+		}
+		stored := *entry
+		idempotencyStore.mu.Unlock()
+
+		if ok {
+			switch {
+			case stored.fingerprint != fingerprint:
+				abortWithError(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			case !stored.done:
+				abortWithError(c, http.StatusConflict, "a request with the same Idempotency-Key is in progress")
+			default:
+				log.Info().Str("path", c.Request.URL.Path).Str("idempotency_key", key).Msg("Replaying stored response for Idempotency-Key")
+				replayIdempotentResponse(c, stored)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		idempotencyStore.mu.Lock()
+		defer idempotencyStore.mu.Unlock()
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			delete(idempotencyStore.entries, storeKey)
+			return
+		}
+		entry.done = true
+		entry.status = status
+		entry.header = recorder.Header().Clone()
+		entry.body = recorder.body.Bytes()
+		entry.storedAt = time.Now()
+	}
+}
+
+/*
+requestFingerprint はメソッド・パス・ボディのSHA-256を返す
+ボディは読み込んだ後、ハンドラーが同じ内容を読めるように戻す
+（大きなボディはmaxImportSizeまでをハッシュの対象にする）
+*/
+func requestFingerprint(c *gin.Context) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.Request.URL.Path+"\n")
+	if c.Request.Body != nil {
+		head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize))
+		if err != nil {
+			return "", err
+		}
+		h.Write(head)
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+/* replayIdempotentResponse は保存したレスポンスをそのまま返す */
+func replayIdempotentResponse(c *gin.Context, entry idempotencyEntry) {
+	for name, values := range entry.header {
+		c.Writer.Header()[name] = append([]string(nil), values...)
+	}
+	/* リクエストIDは今回のリクエストの値にする */
+	c.Header(requestIDHeader, requestID(c))
+	c.Header(idempotencyReplayedHeader, "true")
+	c.Data(entry.status, entry.header.Get("Content-Type"), entry.body)
+	c.Abort()
+}
+
+/* pruneIdempotencyEntries は保存期間を過ぎたレスポンスを削除する（呼び出し元でmuをロックしていること） */
+func pruneIdempotencyEntries(now time.Time) {
+	for key, entry := range idempotencyStore.entries {
+		if entry.done && now.Sub(entry.storedAt) > idempotencyTTL {
+			delete(idempotencyStore.entries, key)
+		}
+	}
+}
+
+/*
+responseRecorder はレスポンスボディを書き込みながら複製するgin.ResponseWriter
+*/
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
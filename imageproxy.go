@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // GIFのアバターのデコードに使用
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* imageProxyMaxBytes は中継する画像の最大サイズ（超える場合は502を返す） */
+	imageProxyMaxBytes = 5 << 20
+	/* imageProxyMaxPixels はリサイズのためにデコードする画像の最大ピクセル数（展開すると巨大になる画像を拒否する） */
+	imageProxyMaxPixels = 4096 * 4096
+)
+
+/*
+imageProxyHosts は /img/proxy で中継できる画像のホスト
+アバター（avatars.githubusercontent.com）とリポジトリのOGP画像のホストのみを許可し、任意のURLへの中継（SSRF）を防ぐ
+環境変数 IMAGE_PROXY_HOSTS（カンマ区切り）で変更可能
+*/
+var imageProxyHosts = splitList(getEnv("IMAGE_PROXY_HOSTS",
+	"avatars.githubusercontent.com,opengraph.githubassets.com,repository-images.githubusercontent.com"))
+
+/* imageProxyQuery は GET /img/proxy のクエリパラメータ */
+type imageProxyQuery struct {
+	URL  string `form:"url" binding:"required"`                   // 中継する画像のURL（httpsかつIMAGE_PROXY_HOSTSのホストのみ）
+	Size int    `form:"size" binding:"omitempty,min=16,max=1024"` // 縮小後の長辺のピクセル数（省略時は縮小しない）
+}
+
+/* validateFields はurlのスキームとホストを検証する */
+func (q *imageProxyQuery) validateFields() []FieldError {
+	if q.URL != "" && !imageProxyAllowed(q.URL) {
+		return []FieldError{{Field: "url", Rule: "host", Message: "must be an https URL on an allowed image host"}}
+	}
+	return nil
+}
+
+/* imageProxyAllowed はURLがhttpsかつIMAGE_PROXY_HOSTSのホストかどうかを返す */
+func imageProxyAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return false
+	}
+	return containsString(imageProxyHosts, strings.ToLower(u.Hostname()))
+}
+
+/*
+fetchProxiedImage は画像を取得する（リダイレクト先もIMAGE_PROXY_HOSTSのホストに限る）
+
+戻り値:
+  []byte - 画像のデータ
+  string - Content-Type（SVG以外の image/* のみ）
+  error - 取得に失敗した場合、または画像ではない・大きすぎる場合のエラー
+*/
+func fetchProxiedImage(raw string) ([]byte, string, error) {
+	resp, err := upstreamClient(upstreamOpImage).Get(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if !imageProxyAllowed(resp.Request.URL.String()) {
+		return nil, "", fmt.Errorf("image redirected to a disallowed host: %s", resp.Request.URL.Host)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("image request failed: %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	/* SVGはスクリプトを含められるため中継しない */
+	if !strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "image/svg") {
+		return nil, "", fmt.Errorf("unexpected content type: %s", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, imageProxyMaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > imageProxyMaxBytes {
+		return nil, "", fmt.Errorf("image exceeds %d bytes", imageProxyMaxBytes)
+	}
+	return data, contentType, nil
+}
+
+/*
+resizeImage は画像の長辺がsize以下になるよう縮小する（エリア平均法、拡大はしない）
+JPEGはJPEG、それ以外はPNGで出力する
+
+戻り値:
+  []byte - 縮小した画像（縮小が不要な場合は元のデータ）
+  string - Content-Type
+  error - デコード・エンコードに失敗した場合のエラー
+*/
+func resizeImage(data []byte, contentType string, size int) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > imageProxyMaxPixels {
+		return nil, "", fmt.Errorf("image is too large to resize: %dx%d", cfg.Width, cfg.Height)
+	}
+	if cfg.Width <= size && cfg.Height <= size {
+		return data, contentType, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	w, h := size, size
+	if cfg.Width > cfg.Height {
+		h = max(1, cfg.Height*size/cfg.Width)
+	} else {
+		w = max(1, cfg.Width*size/cfg.Height)
+	}
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	dst := scaleDown(rgba, w, h)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", err
+}
+
+/* scaleDown はsrcの各ピクセルを対応する出力のピクセルに平均して、w x h に縮小する */
+func scaleDown(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					p := src.RGBAAt(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+					r, g, b, a, n = r+int(p.R), g+int(p.G), b+int(p.B), a+int(p.A), n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+/*
+getImageProxy はGitHubのアバター・OGP画像を取得し、必要に応じて縮小して返すハンドラー
+フロントエンドが第三者のホストへ直接リクエストせず、サーバーのキャッシュとCache-Controlを利用できるようにする
+
+クエリパラメータ:
+  url string - 画像のURL（httpsかつIMAGE_PROXY_HOSTSのホストのみ）
+  size int - 縮小後の長辺のピクセル数（16〜1024、省略時は縮小しない）
+
+レスポンス:
+  成功時: 200 OK, 画像（縮小した場合はimage/pngまたはimage/jpeg）
+  失敗時: 422 Unprocessable Entity（urlが許可されていない・sizeが不正）, 502 Bad Gateway（取得できない・画像ではない）
+
+注意:
+  - レスポンスはキャッシュの方針（/img/proxy）に従い、url・sizeごとにサーバーで保持する
+  - 画像の取得はGitHub APIではないため、レート制限の予算の対象外
+*/
+func getImageProxy(c *gin.Context) {
+	var query imageProxyQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	data, contentType, err := fetchProxiedImage(query.URL)
+	if err == nil && query.Size > 0 {
+		data, contentType, err = resizeImage(data, contentType, query.Size)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("url", query.URL).Msg("Image proxy request failed")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	insightKindSilence   = "silence"    // 活発だったリポジトリのコミットが急に止まった
+	insightKindSpike     = "spike"      // コミット数が普段より大幅に増えた
+	insightKindLateNight = "late_night" // 深夜のコミットの割合が増えた
+)
+
+const (
+	/* insightRecentDays は「最近」とみなす期間（日） */
+	insightRecentDays = 7
+	/* insightBaselineDays は比較の基準とする期間（最近の期間の直前、日） */
+	insightBaselineDays = 28
+	/* insightSilenceMinBaseline は急な停止を検知するために必要な基準期間のコミット数（週2件程度） */
+	insightSilenceMinBaseline = 8
+	/* insightSpikeMinCommits は急増とみなす最近の期間の最小コミット数 */
+	insightSpikeMinCommits = 10
+	/* insightSpikeRatio は急増とみなす、基準期間の週平均に対する倍率 */
+	insightSpikeRatio = 3.0
+	/* insightLateNightMinCommits は深夜のコミットの傾向を判定する最近の期間の最小コミット数 */
+	insightLateNightMinCommits = 5
+	/* insightLateNightMinShare は深夜のコミットが多いとみなす割合 */
+	insightLateNightMinShare = 0.3
+	/* lateNightStartHour, lateNightEndHour は深夜とみなす時間帯（22時〜翌5時、STATS_TIMEZONE基準） */
+	lateNightStartHour = 22
+	lateNightEndHour   = 5
+)
+
+/*
+insightsInterval は異常検知ジョブの実行間隔
+環境変数 INSIGHTS_INTERVAL で変更可能（デフォルト: 1時間、0の場合はジョブを起動せず /api/insights の呼び出し時に検知する）
+*/
+var insightsInterval = parseDurationEnv("INSIGHTS_INTERVAL", time.Hour)
+
+/*
+Insight はコミット活動から検知した1件の異常を表す構造体
+*/
+type Insight struct {
+	Kind     string  `json:"kind"`           // 異常の種類（insightKind* 定数）
+	Repo     string  `json:"repo,omitempty"` // 対象のリポジトリ名（全リポジトリが対象の場合は省略）
+	Title    string  `json:"title"`          // 見出し
+	Message  string  `json:"message"`        // 説明
+	Recent   float64 `json:"recent"`         // 最近の期間の値（コミット数、または深夜のコミットの割合）
+	Baseline float64 `json:"baseline"`       // 基準期間の値（週平均のコミット数、または深夜のコミットの割合）
+}
+
+/* InsightsResponse は /api/insights のレスポンス */
+type InsightsResponse struct {
+	GeneratedAt time.Time `json:"generated_at"` // 検知を実行した日時
+	Insights    []Insight `json:"insights"`     // 検知した異常（ない場合は空配列）
+}
+
+/* latestInsights は異常検知ジョブの最新の結果 */
+var latestInsights = struct {
+	mu     sync.Mutex
+	result *InsightsResponse
+}{}
+
+/*
+detectInsights はコミットから異常を検知する
+最近の期間（insightRecentDays日）とその直前の基準期間（insightBaselineDays日）を比較する
+
+検知する異常:
+  silence - 基準期間に一定数のコミットがあるリポジトリで、最近の期間のコミットが0件
+  spike - 最近の期間のコミット数が基準期間の週平均のinsightSpikeRatio倍以上（リポジトリごと）
+  late_night - 最近の期間の深夜のコミットの割合が基準期間の2倍以上、かつinsightLateNightMinShare以上（全リポジトリ）
+
+注意:
+  - GitHub APIから取得できるのは1リポジトリあたり最新100件までのため、
+    コミットの多いリポジトリでは基準期間の一部が欠けることがある
+*/
+func detectInsights(commits []CommitHistory, repos []Repository, now time.Time) []Insight {
+	recentStart := now.AddDate(0, 0, -insightRecentDays)
+	baselineStart := recentStart.AddDate(0, 0, -insightBaselineDays)
+	baselineWeeks := float64(insightBaselineDays) / 7
+
+	recent := map[string]int{}
+	baseline := map[string]int{}
+	var recentTotal, recentLate, baselineTotal, baselineLate int
+	for _, commit := range commits {
+		late := isLateNight(commit.CommitTime.In(statsLocation))
+		switch {
+		case !commit.CommitTime.Before(recentStart) && !commit.CommitTime.After(now):
+			recent[commit.RepositoryName]++
+			recentTotal++
+			if late {
+				recentLate++
+			}
+		case !commit.CommitTime.Before(baselineStart) && commit.CommitTime.Before(recentStart):
+			baseline[commit.RepositoryName]++
+			baselineTotal++
+			if late {
+				baselineLate++
+			}
+		}
+	}
+
+	insights := []Insight{}
+	for _, name := range repositoryNames(repos) {
+		weekly := float64(baseline[name]) / baselineWeeks
+		if baseline[name] >= insightSilenceMinBaseline && recent[name] == 0 {
+			insights = append(insights, Insight{
+				Kind:     insightKindSilence,
+				Repo:     name,
+				Title:    "コミットが止まっています",
+				Message:  fmt.Sprintf("%s は直前の%d日間に %d 件のコミットがありましたが、直近%d日間は0件です", name, insightBaselineDays, baseline[name], insightRecentDays),
+				Recent:   0,
+				Baseline: weekly,
+			})
+		}
+		if recent[name] >= insightSpikeMinCommits && float64(recent[name]) >= weekly*insightSpikeRatio {
+			insights = append(insights, Insight{
+				Kind:     insightKindSpike,
+				Repo:     name,
+				Title:    "コミットが急増しています",
+				Message:  fmt.Sprintf("%s の直近%d日間のコミットは %d 件です（普段は週 %.1f 件）", name, insightRecentDays, recent[name], weekly),
+				Recent:   float64(recent[name]),
+				Baseline: weekly,
+			})
+		}
+	}
+
+	if recentTotal >= insightLateNightMinCommits {
+		recentShare := float64(recentLate) / float64(recentTotal)
+		baselineShare := 0.0
+		if baselineTotal > 0 {
+			baselineShare = float64(baselineLate) / float64(baselineTotal)
+		}
+		if recentShare >= insightLateNightMinShare && recentShare >= baselineShare*2 {
+			insights = append(insights, Insight{
+				Kind:     insightKindLateNight,
+				Title:    "深夜のコミットが増えています",
+				Message:  fmt.Sprintf("直近%d日間のコミットの %.0f%% が%d時〜%d時です（以前は %.0f%%）", insightRecentDays, recentShare*100, lateNightStartHour, lateNightEndHour, baselineShare*100),
+				Recent:   recentShare,
+				Baseline: baselineShare,
+			})
+		}
+	}
+	return insights
+}
+
+/* isLateNight は時刻が深夜の時間帯（lateNightStartHour時〜lateNightEndHour時）かどうかを返す */
+func isLateNight(t time.Time) bool {
+	return t.Hour() >= lateNightStartHour || t.Hour() < lateNightEndHour
+}
+
+/*
+runInsights はコミットを取得して異常を検知し、結果を保存して insight 通知を作成する
+通知はnotifyの重複抑制により、同じリポジトリの未読の insight 通知がある閲覧者には送られない
+
+引数:
+  priority jobPriority - コミットの取得のジョブを実行するレーン（定期実行時はjobPriorityBackground）
+*/
+func runInsights(priority jobPriority) (*InsightsResponse, error) {
+	commits, repos, err := fetchCommitHistoryAt(priority)
+	if err != nil {
+		return nil, err
+	}
+	/* 結果と通知は匿名の閲覧者にも返すため、PRIVACY_MODEに従いプライベートリポジトリを除く */
+	commits, repos = backgroundVisibility().commits(commits, repos), backgroundVisibility().repositories(repos)
+
+	now := time.Now()
+	result := &InsightsResponse{GeneratedAt: now, Insights: detectInsights(commits, repos, now)}
+	latestInsights.mu.Lock()
+	latestInsights.result = result
+	latestInsights.mu.Unlock()
+
+	for _, insight := range result.Insights {
+		notify(notificationKindInsight, insight.Repo, insight.Title, insight.Message)
+	}
+	log.Info().Int("insights", len(result.Insights)).Msg("Commit activity insights updated")
+	return result, nil
+}
+
+/*
+startInsightsScheduler はinsightsIntervalごとに異常検知を実行するゴルーチンを起動する
+間隔が0の場合は何もしない
+*/
+func startInsightsScheduler() {
+	if insightsInterval <= 0 {
+		return
+	}
+	log.Info().Dur("interval", insightsInterval).Msg("Insights scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(insightsInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if pausedForMaintenance(lockNameInsights) {
+				continue
+			}
+			/* 複数のレプリカで同じ通知を作成しないよう、ロックを取得した1つだけが検知する */
+			runExclusive(lockNameInsights, func() {
+				if _, err := runInsights(jobPriorityBackground); err != nil {
+					log.Error().Err(err).Msg("Scheduled insights detection failed")
+				}
+			})
+		}
+	}()
+}
+
+/*
+getInsights はコミット活動の異常の検知結果を返すAPIハンドラー
+ジョブの結果がない場合（起動直後・ジョブ無効時）や、結果がinsightsIntervalより古い場合はその場で検知する
+
+レスポンス:
+  成功時: 200 OK, InsightsResponse
+  失敗時: 502 Bad Gateway（GitHubからリポジトリ一覧を取得できない場合）
+*/
+func getInsights(c *gin.Context) {
+	latestInsights.mu.Lock()
+	result := latestInsights.result
+	latestInsights.mu.Unlock()
+
+	if result == nil || insightsInterval <= 0 || time.Since(result.GeneratedAt) > insightsInterval {
+		var err error
+		result, err = runInsights(requestPriority(c))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to detect insights")
+			respondError(c, http.StatusBadGateway, err.Error())
+			return
+		}
+	}
+	respondJSON(c, http.StatusOK, result)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* defaultIssueStatsMonths は月ごとの推移に含める月数のデフォルト */
+	defaultIssueStatsMonths = 6
+)
+
+/*
+issueAgeBuckets はオープン中のIssueの経過日数の区分
+MaxDaysが0の区分は上限なし（最後の区分）
+*/
+var issueAgeBuckets = []IssueAgeBucket{
+	{Label: "0-7d", MinDays: 0, MaxDays: 7},
+	{Label: "8-30d", MinDays: 8, MaxDays: 30},
+	{Label: "31-90d", MinDays: 31, MaxDays: 90},
+	{Label: "91-365d", MinDays: 91, MaxDays: 365},
+	{Label: "366d+", MinDays: 366},
+}
+
+/* issueStatsParams は /api/stats/issues のクエリパラメータ */
+type issueStatsParams struct {
+	Months int    `form:"months" binding:"min=1,max=24"`
+	Repo   string `form:"repo"`
+}
+
+/* IssueAgeBucket は経過日数の区分ごとのオープン中のIssue数 */
+type IssueAgeBucket struct {
+	Label   string `json:"label"`              // 区分の名前（例: "8-30d"）
+	MinDays int    `json:"min_days"`           // 経過日数の下限（この日数を含む）
+	MaxDays int    `json:"max_days,omitempty"` // 経過日数の上限（この日数を含む、上限なしの場合は省略）
+	Count   int    `json:"count"`              // Issue数
+}
+
+/* IssueBacklog はオープン中のIssueの経過日数の分布 */
+type IssueBacklog struct {
+	Open       int              `json:"open"`        // オープン中のIssue数
+	MedianDays *float64         `json:"median_days"` // 経過日数の中央値（オープン中のIssueがない場合はnull）
+	OldestDays int              `json:"oldest_days"` // 最も古いIssueの経過日数
+	Ages       []IssueAgeBucket `json:"ages"`        // 経過日数の区分ごとのIssue数
+}
+
+/* MonthlyIssueStats は1か月分のIssueの作成数とクローズ数 */
+type MonthlyIssueStats struct {
+	Month     string   `json:"month"`      // 月（"2006-01"形式）
+	Opened    int      `json:"opened"`     // 作成したIssue数
+	Closed    int      `json:"closed"`     // クローズしたIssue数（作成した月に関係なく、クローズした月で数える）
+	CloseRate *float64 `json:"close_rate"` // 作成数に対するクローズ数の比率（作成数が0の場合はnull）
+}
+
+/* RepositoryIssueStats はリポジトリごとのIssueの集計 */
+type RepositoryIssueStats struct {
+	Repo        string             `json:"repo"`          // リポジトリ名
+	Open        int                `json:"open"`          // オープン中のIssue数
+	TimeToClose LatencyPercentiles `json:"time_to_close"` // 作成からクローズまでの所要時間（集計の期間内にクローズしたIssue）
+}
+
+/* IssueStatsResponse は /api/stats/issues のレスポンス */
+type IssueStatsResponse struct {
+	GeneratedAt        time.Time              `json:"generated_at"`                  // 集計した日時
+	Since              time.Time              `json:"since"`                         // 集計の期間の開始
+	TimeToClose        LatencyPercentiles     `json:"time_to_close"`                 // 作成からクローズまでの所要時間（集計の期間内にクローズしたIssue）
+	Monthly            []MonthlyIssueStats    `json:"monthly"`                       // 月ごとの推移（古い順、今月を含む）
+	Backlog            IssueBacklog           `json:"backlog"`                       // 現在オープン中のIssue
+	Repositories       []RepositoryIssueStats `json:"repositories"`                  // リポジトリごと（リポジトリ名順、Issueのないリポジトリは含めない）
+	FailedRepositories []string               `json:"failed_repositories,omitempty"` // Issueを取得できなかったリポジトリ
+}
+
+/* issueAgeBucket はオープン中のIssueの経過日数が含まれる区分の位置を返す */
+func issueAgeBucket(days int) int {
+	for i, bucket := range issueAgeBuckets {
+		if bucket.MaxDays == 0 || days <= bucket.MaxDays {
+			return i
+		}
+	}
+	return len(issueAgeBuckets) - 1
+}
+
+/*
+getIssueStats はIssueのクローズまでの所要時間・月ごとの作成数とクローズ数・オープン中のIssueの経過日数の分布を返すAPIハンドラー
+プルリクエストは含めない（/api/stats/review-latency を参照）
+
+クエリパラメータ:
+  months int - 月ごとの推移に含める月数（1〜24、デフォルト: 6、今月を含む）
+  repo string - リポジトリ名で絞り込む
+
+レスポンス:
+  成功時: 200 OK, IssueStatsResponse
+  失敗時: 422 Unprocessable Entity（不正なmonths）, 502 Bad Gateway（GitHubからリポジトリ一覧を取得できない）
+
+注意:
+  - GitHub APIから取得できるのは1リポジトリあたり最新100件（プルリクエストを含む）までのため、
+    Issueの多いリポジトリでは古い月とオープン中の古いIssueの一部が欠けることがある
+  - Issueを取得できなかったリポジトリは failed_repositories に含め、集計から除く
+*/
+func getIssueStats(c *gin.Context) {
+	params := issueStatsParams{Months: defaultIssueStatsMonths}
+	if !bindQuery(c, &params) {
+		return
+	}
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for issue stats")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if name := params.Repo; name != "" {
+		var filtered []Repository
+		for _, repo := range repos {
+			if repo.Name == name {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+
+	issues := make([][]Issue, len(repos))
+	errs := make([]error, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		issues[i], errs[i] = fetchIssues(repos[i].FullName)
+	})
+
+	now := time.Now().In(statsLocation)
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, statsLocation).AddDate(0, 1-params.Months, 0)
+	resp := IssueStatsResponse{
+		GeneratedAt:  now,
+		Since:        since,
+		Monthly:      make([]MonthlyIssueStats, params.Months),
+		Backlog:      IssueBacklog{Ages: append([]IssueAgeBucket(nil), issueAgeBuckets...)},
+		Repositories: []RepositoryIssueStats{},
+	}
+	index := map[string]int{}
+	for i := range resp.Monthly {
+		month := since.AddDate(0, i, 0).Format("2006-01")
+		resp.Monthly[i].Month = month
+		index[month] = i
+	}
+
+	var closeHours, openDays []float64
+	for i, repo := range repos {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Str("repository", repo.Name).Msg("Failed to fetch issues for issue stats")
+			resp.FailedRepositories = append(resp.FailedRepositories, repo.Name)
+			continue
+		}
+		if len(issues[i]) == 0 {
+			continue
+		}
+		stats := RepositoryIssueStats{Repo: repo.Name}
+		var repoCloseHours []float64
+		for _, issue := range issues[i] {
+			if m, ok := index[issue.CreatedAt.In(statsLocation).Format("2006-01")]; ok {
+				resp.Monthly[m].Opened++
+			}
+			if issue.ClosedAt == nil {
+				days := int(now.Sub(issue.CreatedAt).Hours() / 24)
+				stats.Open++
+				resp.Backlog.Open++
+				resp.Backlog.Ages[issueAgeBucket(days)].Count++
+				resp.Backlog.OldestDays = max(resp.Backlog.OldestDays, days)
+				openDays = append(openDays, float64(days))
+				continue
+			}
+			if issue.ClosedAt.Before(since) {
+				continue
+			}
+			if m, ok := index[issue.ClosedAt.In(statsLocation).Format("2006-01")]; ok {
+				resp.Monthly[m].Closed++
+			}
+			repoCloseHours = append(repoCloseHours, issue.ClosedAt.Sub(issue.CreatedAt).Hours())
+		}
+		stats.TimeToClose = latencyPercentiles(repoCloseHours)
+		closeHours = append(closeHours, repoCloseHours...)
+		resp.Repositories = append(resp.Repositories, stats)
+	}
+
+	resp.TimeToClose = latencyPercentiles(closeHours)
+	resp.Backlog.MedianDays = latencyPercentiles(openDays).P50
+	for i := range resp.Monthly {
+		if opened := resp.Monthly[i].Opened; opened > 0 {
+			rate := math.Round(float64(resp.Monthly[i].Closed)/float64(opened)*1000) / 1000
+			resp.Monthly[i].CloseRate = &rate
+		}
+	}
+	sort.Slice(resp.Repositories, func(i, j int) bool { return resp.Repositories[i].Repo < resp.Repositories[j].Repo })
+	respondJSON(c, http.StatusOK, resp)
+}
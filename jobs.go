@@ -0,0 +1,408 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ジョブキュー
+GitHubからの取得と集計を種類ごとのジョブに分け、プロセス内のキューでsyncWorkers個のワーカーが実行する
+失敗したジョブは待機時間を倍にしながらJOB_MAX_ATTEMPTS回まで再試行する
+
+ジョブは優先度ごとのレーンに追加し、ワーカーは優先度の高いレーンから取り出す
+  - interactive: 閲覧者が ?refresh=true で明示的に更新したリクエスト
+  - normal: 通常のAPIリクエスト
+  - background: 定期ジョブ・バックフィル・メトリクスの収集
+backgroundのジョブはGitHub APIの残りリクエスト数がBACKFILL_RATE_RESERVE以下の間は取り出さず、
+画面表示のためのリクエスト数を残しておく
+*/
+
+/* jobPriority はジョブの優先度（値が小さいほど先に実行する） */
+type jobPriority int
+
+const (
+	jobPriorityInteractive jobPriority = iota // ?refresh=true による閲覧者の明示的な更新
+	jobPriorityNormal                         // 通常のAPIリクエスト
+	jobPriorityBackground                     // 定期ジョブ・バックフィル
+
+	jobPriorityCount = 3 // レーンの数
+)
+
+/* jobPriorityNames はメトリクス・管理者APIで使用する優先度の名前 */
+var jobPriorityNames = [jobPriorityCount]string{"interactive", "normal", "background"}
+
+/* String は優先度の名前を返す */
+func (p jobPriority) String() string {
+	if p < 0 || p >= jobPriorityCount {
+		return "unknown"
+	}
+	return jobPriorityNames[p]
+}
+
+const (
+	jobKindFetchRepoList    = "fetch-repo-list"    // リポジトリ一覧の取得
+	jobKindFetchRepoCommits = "fetch-repo-commits" // リポジトリごとのコミット履歴の取得
+	jobKindComputeStats     = "compute-stats"      // 取得結果の集計（通知・ヘッダーの集計）
+	jobKindBackfillPage     = "backfill-page"      // バックフィルの1ページの取得
+	jobKindFetchFollowers   = "fetch-followers"    // フォロワー数のスナップショットの記録
+
+	/* jobFailureHistory は /api/admin/jobs で返す最近の失敗の件数 */
+	jobFailureHistory = 20
+)
+
+var (
+	/* jobMaxAttempts はジョブの最大試行回数（環境変数 JOB_MAX_ATTEMPTS、デフォルト: 3） */
+	jobMaxAttempts = getEnvInt("JOB_MAX_ATTEMPTS", 3)
+	/*
+		jobRetryBackoff は最初の再試行までの待機時間（環境変数 JOB_RETRY_BACKOFF、デフォルト: 1秒）
+		再試行のたびに倍になる（1秒 → 2秒 → 4秒 ...）
+	*/
+	jobRetryBackoff = parseDurationEnv("JOB_RETRY_BACKOFF", time.Second)
+)
+
+var (
+	jobQueueDepth = newGaugeVec(
+		"giter_job_queue_depth",
+		"Number of jobs waiting in the queue.",
+		"priority",
+	)
+	jobsTotal = newCounterVec(
+		"giter_jobs_total",
+		"Job attempts by kind and result (succeeded, failed, retried).",
+		"kind", "result",
+	)
+	jobDuration = newHistogramVec(
+		"giter_job_duration_seconds",
+		"Time to run a single job attempt.",
+		defaultDurationBuckets,
+		"kind",
+	)
+)
+
+func init() {
+	/* キューの長さはジョブの追加・取り出しのたびに更新するため、/metrics にも出力する */
+	registerMetric(jobQueueDepth)
+}
+
+/*
+job はキューで実行する1つのジョブ
+runはワーカーのゴルーチンで実行されるため、run内でキューのジョブを待機してはいけない
+（すべてのワーカーが待機するとキューが停止する）
+*/
+type job struct {
+	kind     string       // ジョブの種類（jobKind* 定数）
+	priority jobPriority  // 実行するレーン
+	key      string       // 対象（リポジトリ名など、ログと失敗の記録に使用）
+	run      func() error // ジョブの処理
+	attempts int          // 実行した回数
+	err      error        // 最後の試行のエラー
+	done     chan struct{}
+}
+
+/* wait はジョブが成功するか、再試行を使い切るまで待機し、最後のエラーを返す */
+func (j *job) wait() error {
+	<-j.done
+	return j.err
+}
+
+/* JobKindStats はジョブの種類ごとの件数 */
+type JobKindStats struct {
+	Queued    int `json:"queued"`    // キューで待機中
+	Running   int `json:"running"`   // 実行中
+	Succeeded int `json:"succeeded"` // 成功した
+	Failed    int `json:"failed"`    // 再試行を使い切って失敗した
+	Retried   int `json:"retried"`   // 失敗して再試行した回数
+}
+
+/* JobFailure は再試行を使い切って失敗したジョブの記録 */
+type JobFailure struct {
+	Kind     string    `json:"kind"`      // ジョブの種類
+	Key      string    `json:"key"`       // 対象
+	Attempts int       `json:"attempts"`  // 試行回数
+	Error    string    `json:"error"`     // 最後のエラー
+	FailedAt time.Time `json:"failed_at"` // 失敗した日時
+}
+
+/* JobQueueStatus は /api/admin/jobs で返すキューの状態 */
+type JobQueueStatus struct {
+	Workers        int                     `json:"workers"`         // ワーカー数
+	Depth          int                     `json:"depth"`           // キューで待機中のジョブ数
+	Lanes          map[string]int          `json:"lanes"`           // 優先度ごとの待機中のジョブ数
+	Throttled      bool                    `json:"throttled"`       // レート制限の残り回数が少ないため、backgroundのジョブを止めている場合はtrue
+	Running        int                     `json:"running"`         // 実行中のジョブ数
+	Kinds          map[string]JobKindStats `json:"kinds"`           // 種類ごとの件数
+	RecentFailures []JobFailure            `json:"recent_failures"` // 最近の失敗（新しい順）
+}
+
+/* jobQueue はジョブを優先度の高いレーンから、同じレーン内は追加順に実行するキュー */
+type jobQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	lanes    [jobPriorityCount][]*job
+	running  int
+	kinds    map[string]*JobKindStats
+	failures []JobFailure
+	wake     *time.Timer // backgroundのジョブを止めている間、レート制限のリセット時刻にワーカーを起こすタイマー
+	start    sync.Once
+}
+
+/* jobs はアプリケーション全体で共有するジョブキュー */
+var jobs = newJobQueue()
+
+/* newJobQueue は空のキューを作成する（ワーカーは最初のジョブの追加時に起動する） */
+func newJobQueue() *jobQueue {
+	q := &jobQueue{kinds: map[string]*JobKindStats{}}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+/* workers はワーカー数を返す（GITHUB_SYNC_WORKERS、最低1） */
+func (q *jobQueue) workers() int {
+	if syncWorkers < 1 {
+		return 1
+	}
+	return syncWorkers
+}
+
+/*
+submit はジョブをキューに追加する
+
+引数:
+  priority jobPriority - 実行するレーン
+  kind string - ジョブの種類（jobKind* 定数）
+  key string - 対象（リポジトリ名など）
+  run func() error - ジョブの処理（エラーを返すと再試行する）
+
+戻り値:
+  *job - 追加したジョブ（waitで完了を待機できる）
+*/
+func (q *jobQueue) submit(priority jobPriority, kind, key string, run func() error) *job {
+	q.start.Do(func() {
+		for i := 0; i < q.workers(); i++ {
+			go q.work()
+		}
+	})
+
+	j := &job{kind: kind, priority: priority, key: key, run: run, done: make(chan struct{})}
+	q.mu.Lock()
+	q.push(j)
+	q.mu.Unlock()
+	return j
+}
+
+/* push はジョブをレーンの末尾に追加し、待機中のワーカーを起こす（呼び出し元でmuをロックしていること） */
+func (q *jobQueue) push(j *job) {
+	q.lanes[j.priority] = append(q.lanes[j.priority], j)
+	q.stats(j.kind).Queued++
+	jobQueueDepth.Set(float64(len(q.lanes[j.priority])), j.priority.String())
+	q.cond.Signal()
+}
+
+/*
+next は次に実行するジョブをレーンから取り出す（呼び出し元でmuをロックしていること）
+実行できるジョブがない場合はnilを返す
+
+注意:
+  - backgroundのジョブはレート制限の残り回数がBACKFILL_RATE_RESERVE以下の間は取り出さず、
+    リセット時刻にワーカーを起こすタイマーを設定する
+*/
+func (q *jobQueue) next() *job {
+	for p := jobPriority(0); p < jobPriorityCount; p++ {
+		if len(q.lanes[p]) == 0 {
+			continue
+		}
+		if p == jobPriorityBackground && maintenanceActive() {
+			/* メンテナンス中はバックグラウンドのジョブを止め、解除時のresumeで再開する */
+			return nil
+		}
+		if p == jobPriorityBackground && backgroundThrottled() {
+			q.scheduleWake()
+			return nil
+		}
+		j := q.lanes[p][0]
+		q.lanes[p] = q.lanes[p][1:]
+		jobQueueDepth.Set(float64(len(q.lanes[p])), p.String())
+		return j
+	}
+	return nil
+}
+
+/* scheduleWake はレート制限のリセット時刻に待機中のワーカーを起こす（呼び出し元でmuをロックしていること） */
+func (q *jobQueue) scheduleWake() {
+	if q.wake != nil {
+		return
+	}
+	/* リセット時刻の直後はヘッダーの更新が遅れる場合があるため、1秒余裕をみる */
+	q.wake = time.AfterFunc(time.Until(currentRateLimit().Reset)+time.Second, func() {
+		q.mu.Lock()
+		q.wake = nil
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+}
+
+/* resume は待機中のワーカーを起こす（メンテナンスモードの解除時に使用） */
+func (q *jobQueue) resume() {
+	q.mu.Lock()
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+/*
+backgroundThrottled はGitHub APIの残りリクエスト数が残す回数（rateReserve）以下で、
+まだリセット時刻に達していないかを返す（レート制限の状態をまだ受け取っていない場合はfalse）
+*/
+func backgroundThrottled() bool {
+	state := currentRateLimit()
+	return !state.UpdatedAt.IsZero() && state.Remaining <= rateReserve(state) && time.Now().Before(state.Reset)
+}
+
+/* stats は種類ごとの件数を返す（呼び出し元でmuをロックしていること） */
+func (q *jobQueue) stats(kind string) *JobKindStats {
+	s, ok := q.kinds[kind]
+	if !ok {
+		s = &JobKindStats{}
+		q.kinds[kind] = s
+	}
+	return s
+}
+
+/* work はキューからジョブを取り出して実行するワーカー */
+func (q *jobQueue) work() {
+	for {
+		q.mu.Lock()
+		j := q.next()
+		for j == nil {
+			q.cond.Wait()
+			j = q.next()
+		}
+		s := q.stats(j.kind)
+		s.Queued--
+		s.Running++
+		q.running++
+		q.mu.Unlock()
+
+		start := time.Now()
+		j.attempts++
+		j.err = j.run()
+		jobDuration.ObserveSince(start, j.kind)
+
+		q.finish(j)
+	}
+}
+
+/*
+finish は1回の試行の結果を記録する
+失敗した場合は待機時間の後にキューに戻し、再試行を使い切った場合は失敗として記録する
+
+注意:
+  - レート制限の残り回数が0の間は、再試行してもリセット時刻まで失敗するだけなので再試行しない
+*/
+func (q *jobQueue) finish(j *job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := q.stats(j.kind)
+	s.Running--
+	q.running--
+
+	switch {
+	case j.err == nil:
+		s.Succeeded++
+		jobsTotal.Inc(j.kind, "succeeded")
+	case j.attempts < jobMaxAttempts && !rateLimitExhausted(time.Now()):
+		s.Retried++
+		jobsTotal.Inc(j.kind, "retried")
+		backoff := jobRetryBackoff << (j.attempts - 1)
+		log.Warn().Err(j.err).Str("kind", j.kind).Str("key", j.key).Int("attempt", j.attempts).Dur("backoff", backoff).Msg("Job failed, retrying")
+		time.AfterFunc(backoff, func() {
+			q.mu.Lock()
+			q.push(j)
+			q.mu.Unlock()
+		})
+		return
+	default:
+		s.Failed++
+		jobsTotal.Inc(j.kind, "failed")
+		log.Error().Err(j.err).Str("kind", j.kind).Str("key", j.key).Int("attempts", j.attempts).Msg("Job failed")
+		q.failures = append([]JobFailure{{
+			Kind:     j.kind,
+			Key:      j.key,
+			Attempts: j.attempts,
+			Error:    j.err.Error(),
+			FailedAt: time.Now(),
+		}}, q.failures...)
+		if len(q.failures) > jobFailureHistory {
+			q.failures = q.failures[:jobFailureHistory]
+		}
+	}
+	close(j.done)
+}
+
+/* status はキューの状態のコピーを返す */
+func (q *jobQueue) status() JobQueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := JobQueueStatus{
+		Workers:        q.workers(),
+		Lanes:          make(map[string]int, jobPriorityCount),
+		Throttled:      backgroundThrottled(),
+		Running:        q.running,
+		Kinds:          make(map[string]JobKindStats, len(q.kinds)),
+		RecentFailures: append([]JobFailure{}, q.failures...),
+	}
+	for p := jobPriority(0); p < jobPriorityCount; p++ {
+		status.Lanes[p.String()] = len(q.lanes[p])
+		status.Depth += len(q.lanes[p])
+	}
+	for kind, s := range q.kinds {
+		status.Kinds[kind] = *s
+	}
+	return status
+}
+
+/* runJob はジョブを指定した優先度のレーンに追加し、完了を待機してエラーを返す */
+func runJob(priority jobPriority, kind, key string, run func() error) error {
+	return jobs.submit(priority, kind, key, run).wait()
+}
+
+/*
+requestPriority はリクエストのジョブの優先度を返す
+?refresh=true の場合は閲覧者が明示的に更新を求めているため、定期ジョブより先に実行する
+*/
+func requestPriority(c *gin.Context) jobPriority {
+	if c.Query("refresh") == "true" {
+		return jobPriorityInteractive
+	}
+	return jobPriorityNormal
+}
+
+/*
+waitJobs は複数のジョブの完了を待機する
+
+戻り値:
+  []error - submittedと同じ順序の各ジョブのエラー
+*/
+func waitJobs(submitted []*job) []error {
+	errs := make([]error, len(submitted))
+	for i, j := range submitted {
+		errs[i] = j.wait()
+	}
+	return errs
+}
+
+/*
+getJobs はジョブキューの状態を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, JobQueueStatus
+*/
+func getJobs(c *gin.Context) {
+	respondJSON(c, http.StatusOK, jobs.status())
+}
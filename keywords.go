@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* defaultKeywordLimit は返すキーワード数のデフォルト */
+	defaultKeywordLimit = 50
+	/* maxKeywordLimit は返すキーワード数の上限（keywordsParamsのbindingタグと合わせること） */
+	maxKeywordLimit = 200
+)
+
+/*
+keywordNoisePattern はキーワードの抽出前にコミットメッセージから取り除く部分
+URL・メールアドレス・コミットSHA（7文字以上の16進数）は作業内容を表さないため除外する
+*/
+var keywordNoisePattern = regexp.MustCompile(`https?://\S+|[\w.+-]+@[\w-]+(\.[\w-]+)+|\b[0-9a-f]{7,40}\b`)
+
+/*
+keywordStopwords はキーワードとして数えない英単語
+一般的な機能語に加え、Conventional Commitsの種類（feat, fix など）も含める
+*/
+var keywordStopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		a an and are as at be but by for from has have in into is it its of on or that the this to was were will with
+		add adds added update updates updated remove removes removed use uses used make makes new more some when now not
+		feat fix docs style refactor perf test tests chore build ci revert wip merge branch pull request main master
+	`) {
+		keywordStopwords[w] = true
+	}
+}
+
+/* Keyword はキーワードとその出現回数 */
+type Keyword struct {
+	Word  string `json:"word"`  // キーワード（英字は小文字）
+	Count int    `json:"count"` // キーワードを含むコミット数
+}
+
+/*
+tokenizeSubject はコミットメッセージの件名（1行目）をキーワードに分割する
+
+英数字: 英字・数字の連続を1語とし、小文字にする（2文字未満・数字のみ・ストップワードは除外）
+日本語: 漢字・カタカナの連続を1語とする（ひらがなは助詞・活用語尾として区切りに使い、語には含めない）
+        例: "ログイン画面のバグを修正" → "ログイン", "画面", "バグ", "修正"
+
+戻り値:
+  []string - 件名に含まれるキーワード（重複を除く）
+*/
+func tokenizeSubject(message string) []string {
+	subject := strings.SplitN(message, "\n", 2)[0]
+	subject = keywordNoisePattern.ReplaceAllString(strings.ToLower(subject), " ")
+
+	seen := map[string]bool{}
+	words := []string{}
+	add := func(word string, script int) {
+		if word == "" || seen[word] {
+			return
+		}
+		if script == scriptLatin {
+			if len(word) < 2 || keywordStopwords[word] || isDigits(word) {
+				return
+			}
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+
+	var current []rune
+	currentScript := scriptNone
+	for _, r := range subject {
+		script := runeScript(r)
+		if script != currentScript {
+			add(string(current), currentScript)
+			current = current[:0]
+			currentScript = script
+		}
+		if script != scriptNone {
+			current = append(current, r)
+		}
+	}
+	add(string(current), currentScript)
+	return words
+}
+
+/* 文字種（tokenizeSubjectで語の区切りに使用する） */
+const (
+	scriptNone     = iota // 区切り文字（記号・空白・ひらがな）
+	scriptLatin           // 英字・数字
+	scriptKanji           // 漢字
+	scriptKatakana        // カタカナ（長音記号を含む）
+)
+
+/* runeScript は文字の文字種を返す */
+func runeScript(r rune) int {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return scriptKanji
+	case unicode.Is(unicode.Katakana, r) || r == 'ー':
+		return scriptKatakana
+	case unicode.Is(unicode.Hiragana, r):
+		return scriptNone
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return scriptLatin
+	default:
+		return scriptNone
+	}
+}
+
+/* isDigits は文字列が数字のみで構成されているかを返す */
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+topKeywords はコミットの件名からキーワードを数え、多い順に最大limit件を返す
+同じコミットに同じ語が複数回現れても1回として数える（件数は「その語を含むコミット数」）
+*/
+func topKeywords(commits []CommitHistory, limit int) []Keyword {
+	counts := map[string]int{}
+	for _, commit := range commits {
+		for _, word := range tokenizeSubject(commit.CommitMessage) {
+			counts[word]++
+		}
+	}
+
+	keywords := make([]Keyword, 0, len(counts))
+	for word, count := range counts {
+		keywords = append(keywords, Keyword{Word: word, Count: count})
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].Count != keywords[j].Count {
+			return keywords[i].Count > keywords[j].Count
+		}
+		return keywords[i].Word < keywords[j].Word
+	})
+	if len(keywords) > limit {
+		keywords = keywords[:limit]
+	}
+	return keywords
+}
+
+/* keywordsParams は /api/stats/keywords のクエリパラメータ */
+type keywordsParams struct {
+	Limit int    `form:"limit" binding:"min=1,max=200"`
+	Repo  string `form:"repo"`
+}
+
+/*
+getKeywords はコミットメッセージの件名によく現れるキーワードを返すAPIハンドラー（ワードクラウド用）
+
+クエリパラメータ:
+  limit int - 返すキーワード数（1〜200、デフォルト: 50）
+  repo string - リポジトリ名で絞り込む（省略時は全リポジトリ）
+
+レスポンス:
+  成功時: 200 OK, {"commits": 対象のコミット数, "keywords": [Keyword, ...]}
+  失敗時: 422 Unprocessable Entity（不正なlimit）, 502 Bad Gateway（GitHubから取得できない）
+*/
+func getKeywords(c *gin.Context) {
+	params := keywordsParams{Limit: defaultKeywordLimit}
+	if !bindQuery(c, &params) {
+		return
+	}
+
+	commits, _, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch commits for keywords")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if params.Repo != "" {
+		commits = commitsForRepository(commits, params.Repo)
+	}
+	/* マスクした文字列（チケットIDなど）がキーワードとして集計されないよう、集計前に適用する */
+	commits = requestRedaction(c).commits(commits)
+
+	respondJSON(c, http.StatusOK, gin.H{"commits": len(commits), "keywords": topKeywords(commits, params.Limit)})
+}
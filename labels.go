@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* ラベルのルールで照合するコミットの項目 */
+const (
+	labelFieldMessage = "message" // コミットメッセージ
+	labelFieldRepo    = "repo"    // リポジトリ名
+)
+
+/* labelNamePattern はラベル名の形式（?label= に指定するため英小文字・数字・ハイフン・ドットのみ） */
+var labelNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{0,31}$`)
+
+/*
+labelRule はコミットにラベルを付けるルール
+*/
+type labelRule struct {
+	field   string         // 照合する項目（labelFieldMessage または labelFieldRepo）
+	pattern *regexp.Regexp // 照合する正規表現
+	label   string         // 一致した場合に付けるラベル
+}
+
+/*
+labelRules はコミットにラベルを付けるルール
+環境変数 LABEL_RULES で指定する（未設定の場合はラベルを付けない）。parseLabelRulesを参照
+*/
+var labelRules = parseLabelRules(getEnv("LABEL_RULES", ""))
+
+/*
+parseLabelRules は改行区切りのルールを読み込む
+  - "正規表現 => ラベル": コミットメッセージが一致した場合にラベルを付ける
+  - "repo:正規表現 => ラベル": リポジトリ名が一致した場合にラベルを付ける（"message:" も指定できる）
+
+例:
+  LABEL_RULES="(?i)^fix\b => bugfix
+  (?i)^docs|README => docs
+  repo:^(infra|terraform)- => infra"
+
+注意:
+  - 空行と "#" で始まる行は無視する
+  - 1つのコミットに複数のルールが一致した場合は、すべてのラベルを付ける
+  - 正規表現をコンパイルできない行、ラベルのない行、ラベル名の形式が不正な行は警告を出力して無視する
+*/
+func parseLabelRules(raw string) []labelRule {
+	var rules []labelRule
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expr, label, found := strings.Cut(line, "=>")
+		label = strings.TrimSpace(label)
+		if !found || !labelNamePattern.MatchString(label) {
+			log.Warn().Str("rule", line).Msg("Ignoring LABEL_RULES entry without a valid label")
+			continue
+		}
+		field, expr := labelFieldMessage, strings.TrimSpace(expr)
+		for _, prefix := range []string{labelFieldMessage, labelFieldRepo} {
+			if rest, ok := strings.CutPrefix(expr, prefix+":"); ok {
+				field, expr = prefix, rest
+				break
+			}
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			log.Warn().Err(err).Str("rule", line).Msg("Ignoring invalid LABEL_RULES entry")
+			continue
+		}
+		rules = append(rules, labelRule{field: field, pattern: pattern, label: label})
+	}
+	return rules
+}
+
+/* commitLabels はコミットに一致したルールのラベルを、ルールの順に重複なく返す */
+func commitLabels(commit CommitHistory) []string {
+	var labels []string
+	for _, rule := range labelRules {
+		value := commit.CommitMessage
+		if rule.field == labelFieldRepo {
+			value = commit.RepositoryName
+		}
+		if rule.pattern.MatchString(value) && !containsString(labels, rule.label) {
+			labels = append(labels, rule.label)
+		}
+	}
+	return labels
+}
+
+/*
+labelCommits はラベルを付けたコミットを返す
+キャッシュ・アーカイブと共有しているスライスを書き換えないよう、コピーを返す
+
+注意:
+  - REDACTION_RULESでマスクする前のメッセージで照合するため、requestRedactionより前に呼び出すこと
+*/
+func labelCommits(commits []CommitHistory) []CommitHistory {
+	if len(labelRules) == 0 {
+		return commits
+	}
+	labeled := make([]CommitHistory, len(commits))
+	for i, commit := range commits {
+		commit.Labels = commitLabels(commit)
+		labeled[i] = commit
+	}
+	return labeled
+}
+
+/* filterCommitsByLabel はラベルが付いたコミットのみを返す（labelが空文字の場合はそのまま） */
+func filterCommitsByLabel(commits []CommitHistory, label string) []CommitHistory {
+	if label == "" {
+		return commits
+	}
+	filtered := []CommitHistory{}
+	for _, commit := range commits {
+		if containsString(commit.Labels, label) {
+			filtered = append(filtered, commit)
+		}
+	}
+	return filtered
+}
+
+/* LabelCount はラベルごとのコミット数 */
+type LabelCount struct {
+	Label   string `json:"label"`   // ラベル
+	Commits int    `json:"commits"` // ラベルが付いたコミット数
+}
+
+/* LabelStatsResponse は /api/stats/labels のレスポンス */
+type LabelStatsResponse struct {
+	Commits   int          `json:"commits"`   // 対象のコミット数
+	Unlabeled int          `json:"unlabeled"` // どのルールにも一致しなかったコミット数
+	Labels    []LabelCount `json:"labels"`    // コミット数の多い順のラベル（同数の場合はラベル名順）
+}
+
+/* labelStats はラベルごとのコミット数を集計する（commitsはlabelCommits済み） */
+func labelStats(commits []CommitHistory) LabelStatsResponse {
+	counts := map[string]int{}
+	stats := LabelStatsResponse{Commits: len(commits), Labels: []LabelCount{}}
+	for _, commit := range commits {
+		if len(commit.Labels) == 0 {
+			stats.Unlabeled++
+		}
+		for _, label := range commit.Labels {
+			counts[label]++
+		}
+	}
+	for label, n := range counts {
+		stats.Labels = append(stats.Labels, LabelCount{Label: label, Commits: n})
+	}
+	sort.Slice(stats.Labels, func(i, j int) bool {
+		if stats.Labels[i].Commits != stats.Labels[j].Commits {
+			return stats.Labels[i].Commits > stats.Labels[j].Commits
+		}
+		return stats.Labels[i].Label < stats.Labels[j].Label
+	})
+	return stats
+}
+
+/*
+getLabelStats はLABEL_RULESのラベルごとのコミット数を返すAPIハンドラー
+
+クエリパラメータ:
+  repo string - リポジトリ名で絞り込む（省略時は全リポジトリ）
+
+レスポンス:
+  成功時: 200 OK, LabelStatsResponse
+  失敗時: 502 Bad Gateway（GitHubから取得できない）
+*/
+func getLabelStats(c *gin.Context) {
+	var params struct {
+		Repo string `form:"repo"`
+	}
+	if !bindQuery(c, &params) {
+		return
+	}
+
+	commits, _, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch commits for label stats")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if params.Repo != "" {
+		commits = commitsForRepository(commits, params.Repo)
+	}
+	respondJSON(c, http.StatusOK, labelStats(labelCommits(commits)))
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* lastSeenTable は閲覧者ごとの既読位置（最後に確認したコミット日時）を保存するテーブル名 */
+	lastSeenTable = "last_seen"
+)
+
+/*
+LastSeen は閲覧者が最後に確認したコミットの位置
+*/
+type LastSeen struct {
+	Cursor         time.Time `json:"cursor"`          // 確認済みの最新のコミット日時（これより新しいコミットが新着）
+	AcknowledgedAt time.Time `json:"acknowledged_at"` // 既読位置を更新した日時
+}
+
+/*
+NewCommitsResponse は前回の訪問以降の新着コミットAPIのレスポンス
+*/
+type NewCommitsResponse struct {
+	Since   *time.Time      `json:"since"`   // 閲覧者の既読位置（初回の訪問ではnull）
+	Cursor  *time.Time      `json:"cursor"`  // 確認するときに /ack に渡す既読位置（最新のコミット日時、コミットがない場合はnull）
+	Count   int             `json:"count"`   // 新着コミット数
+	Commits []CommitHistory `json:"commits"` // 新着コミット（新しい順）
+}
+
+/*
+lastSeenStore は全閲覧者の既読位置を保持するストア
+last_seenテーブルに永続化される
+*/
+type lastSeenStore struct {
+	mu sync.Mutex
+	/* Viewers は閲覧者IDごとの既読位置 */
+	Viewers map[string]LastSeen `json:"viewers"`
+}
+
+/* viewerLastSeen はアプリケーション全体で共有する既読位置ストア */
+var viewerLastSeen = &lastSeenStore{Viewers: map[string]LastSeen{}}
+
+func init() {
+	registerTable(lastSeenTable, loadLastSeen)
+}
+
+/*
+loadLastSeen はlast_seenテーブルから既読位置ストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadLastSeen() error {
+	viewerLastSeen.mu.Lock()
+	defer viewerLastSeen.mu.Unlock()
+
+	viewerLastSeen.Viewers = nil
+	if err := loadTable(lastSeenTable, viewerLastSeen); err != nil {
+		return err
+	}
+	if viewerLastSeen.Viewers == nil {
+		viewerLastSeen.Viewers = map[string]LastSeen{}
+	}
+	return nil
+}
+
+/* save は既読位置ストアをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *lastSeenStore) save() {
+	if err := saveTable(lastSeenTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save last seen cursors")
+	}
+}
+
+/* get は閲覧者の既読位置を返す（未登録の場合はfalse） */
+func (s *lastSeenStore) get(viewer string) (LastSeen, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen, ok := s.Viewers[viewer]
+	return seen, ok
+}
+
+/*
+advance は閲覧者の既読位置をcursorまで進める
+既読位置は戻さないため、現在の位置より古いcursorの場合は更新しない
+
+戻り値:
+  LastSeen - 更新後の既読位置
+*/
+func (s *lastSeenStore) advance(viewer string, cursor time.Time) LastSeen {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen, ok := s.Viewers[viewer]
+	if ok && !cursor.After(seen.Cursor) {
+		return seen
+	}
+	seen = LastSeen{Cursor: cursor, AcknowledgedAt: time.Now()}
+	s.Viewers[viewer] = seen
+	s.save()
+	return seen
+}
+
+/*
+newCommitsSince はcursorより新しいコミットを新しい順に返す
+
+戻り値:
+  []CommitHistory - 新着コミット（新しい順）
+  *time.Time - 全コミットのうち最新のコミット日時（コミットがない場合はnil）
+*/
+func newCommitsSince(commits []CommitHistory, cursor *time.Time) ([]CommitHistory, *time.Time) {
+	newer := []CommitHistory{}
+	var latest *time.Time
+	for i := range commits {
+		t := commits[i].CommitTime
+		if latest == nil || t.After(*latest) {
+			latest = &t
+		}
+		if cursor != nil && t.After(*cursor) {
+			newer = append(newer, commits[i])
+		}
+	}
+	sort.SliceStable(newer, func(i, j int) bool { return newer[i].CommitTime.After(newer[j].CommitTime) })
+	return newer, latest
+}
+
+/*
+getNewCommits は閲覧者の前回の訪問以降の新着コミットを返すAPIハンドラー
+「前回の訪問から12件の新しいコミット」のような表示に使用する
+既読位置はこのAPIでは進めず、POST /api/git-history/new/ack で確認したときに進める
+
+レスポンス:
+  成功時: 200 OK, NewCommitsResponse
+  失敗時: 500 Internal Server Error（リポジトリ一覧を取得できない）
+
+注意:
+  - 初回の訪問（既読位置がない閲覧者）では新着を0件として返す
+    cursor を /ack に渡すと、次回以降はそれより新しいコミットが新着になる
+*/
+func getNewCommits(c *gin.Context) {
+	commits, _, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch commit history for new commits")
+		respondError(c, http.StatusInternalServerError, "failed to fetch commit history from GitHub")
+		return
+	}
+
+	var since *time.Time
+	if seen, ok := viewerLastSeen.get(viewerID(c)); ok {
+		since = &seen.Cursor
+	}
+	newer, latest := newCommitsSince(commits, since)
+	respondJSON(c, http.StatusOK, NewCommitsResponse{
+		Since:   since,
+		Cursor:  latest,
+		Count:   len(newer),
+		Commits: requestRedaction(c).commits(newer),
+	})
+}
+
+/*
+ackNewCommits は新着コミットを確認済みにし、閲覧者の既読位置を進めるAPIハンドラー
+リクエストボディの {"cursor": "<日時>"} まで進める（GET /api/git-history/new の cursor を渡す）
+ボディを省略した場合は、現在の最新のコミット日時まで進める
+
+レスポンス:
+  成功時: 200 OK, LastSeen（更新後の既読位置）
+  失敗時: 400 Bad Request（JSON不正）, 422 Unprocessable Entity（未来の日時）, 500 Internal Server Error（コミット履歴を取得できない）
+
+注意:
+  - 既読位置は戻らない。現在の位置より古い cursor を指定した場合は変更しない
+*/
+func ackNewCommits(c *gin.Context) {
+	var req struct {
+		Cursor *time.Time `json:"cursor"`
+	}
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &req) {
+			return
+		}
+	}
+
+	cursor := req.Cursor
+	if cursor == nil {
+		commits, _, err := fetchVisibleCommitHistory(requestVisibility(c))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch commit history for new commits")
+			respondError(c, http.StatusInternalServerError, "failed to fetch commit history from GitHub")
+			return
+		}
+		_, cursor = newCommitsSince(commits, nil)
+	}
+	if cursor == nil {
+		/* コミットが1件もない場合は、現在時刻を既読位置にする */
+		now := time.Now()
+		cursor = &now
+	}
+	if cursor.After(time.Now()) {
+		respondValidationError(c, []FieldError{{Field: "cursor", Rule: "not_future", Message: "cursor must not be in the future"}})
+		return
+	}
+
+	seen := viewerLastSeen.advance(viewerID(c), *cursor)
+	log.Debug().Str("viewer", viewerID(c)).Time("cursor", seen.Cursor).Msg("Last seen cursor acknowledged")
+	respondJSON(c, http.StatusOK, seen)
+}
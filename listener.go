@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+systemdListenFDsStart はsystemdのソケットアクティベーションで渡される最初のファイルディスクリプタ番号
+（SD_LISTEN_FDS_START、0〜2は標準入出力）
+*/
+const systemdListenFDsStart = 3
+
+var (
+	/* listenAddr はTCPで待ち受けるアドレス（環境変数 LISTEN_ADDR） */
+	listenAddr = getEnv("LISTEN_ADDR", ":8080")
+	/*
+		listenSocket はUnixドメインソケットのパス（環境変数 LISTEN_SOCKET、例: /run/giter.sock）
+		設定した場合はTCPの代わりにこのソケットで待ち受ける
+	*/
+	listenSocket = getEnv("LISTEN_SOCKET", "")
+	/* listenSocketMode はUnixドメインソケットのパーミッション（環境変数 LISTEN_SOCKET_MODE、8進数） */
+	listenSocketMode = getEnv("LISTEN_SOCKET_MODE", "0660")
+	/*
+		trustedProxies はX-Forwarded-For・X-Real-IPを信頼するリバースプロキシ（環境変数 TRUSTED_PROXIES、カンマ区切りのIPアドレス・CIDR）
+		デフォルトは空（ヘッダーを信頼せず、接続元のアドレスをクライアントのIPアドレスとする）
+	*/
+	trustedProxies = splitList(getEnv("TRUSTED_PROXIES", ""))
+)
+
+/*
+newListener はWebサーバーが待ち受けるリスナーを作成する
+以下の優先順位で待ち受け方法を決める:
+  1. systemdのソケットアクティベーション（LISTEN_FDS, LISTEN_PID）
+  2. Unixドメインソケット（LISTEN_SOCKET）
+  3. TCP（LISTEN_ADDR、デフォルト: ":8080"）
+
+戻り値:
+  net.Listener - 作成したリスナー
+  string - ログに出力する待ち受け先の説明（例: "unix:/run/giter.sock"）
+  error - リスナーの作成に失敗した場合のエラー
+*/
+func newListener() (net.Listener, string, error) {
+	if ln, err := systemdListener(); ln != nil || err != nil {
+		if err != nil {
+			return nil, "", err
+		}
+		return ln, "systemd:" + ln.Addr().String(), nil
+	}
+
+	if listenSocket != "" {
+		ln, err := unixSocketListener(listenSocket)
+		if err != nil {
+			return nil, "", err
+		}
+		return ln, "unix:" + listenSocket, nil
+	}
+
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, "", err
+	}
+	return ln, "tcp:" + ln.Addr().String(), nil
+}
+
+/*
+systemdListener はsystemdのソケットアクティベーションで渡されたソケットからリスナーを作成する
+LISTEN_PIDが自分のプロセスIDと一致し、LISTEN_FDSが1以上の場合のみ有効（sd_listen_fdsと同じ判定）
+
+戻り値:
+  net.Listener - 渡されたソケットのリスナー（ソケットアクティベーションでない場合はnil）
+  error - ソケットからリスナーを作成できなかった場合のエラー
+
+注意:
+  - 複数のソケットが渡された場合は最初の1つのみ使用する
+  - 子プロセスに引き継がないよう、LISTEN_PID, LISTEN_FDS, LISTEN_FDNAMES を削除する
+*/
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if fds > 1 {
+		log.Warn().Int("listen_fds", fds).Msg("Multiple sockets passed by systemd, using the first one")
+	}
+	f := os.NewFile(uintptr(systemdListenFDsStart), "LISTEN_FD_3")
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use systemd socket: %w", err)
+	}
+	/* FileListenerはファイルディスクリプタを複製するため、元のファイルは閉じてよい */
+	f.Close()
+	return ln, nil
+}
+
+/*
+unixSocketListener はUnixドメインソケットで待ち受けるリスナーを作成する
+前回の起動で残ったソケットファイルは削除してから作成し、LISTEN_SOCKET_MODEのパーミッションを設定する
+
+引数:
+  path string - ソケットファイルのパス
+*/
+func unixSocketListener(path string) (net.Listener, error) {
+	mode, err := strconv.ParseUint(listenSocketMode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_SOCKET_MODE %q: %w", listenSocketMode, err)
+	}
+
+	/* ソケット以外のファイルを誤って削除しないよう、種類を確認する */
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	/* サーバー終了時（リスナーのClose時）にソケットファイルを削除する */
+	if unixLn, ok := ln.(*net.UnixListener); ok {
+		unixLn.SetUnlinkOnClose(true)
+	}
+	return ln, nil
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+Locker は複数のレプリカで実行する場合に、定期ジョブを1つのレプリカだけで実行するためのロック
+ロックには有効期限（リース）があり、保持している間はRenewで延長する
+保持したレプリカが停止した場合も、有効期限が切れると他のレプリカが取得できる
+*/
+type Locker interface {
+	/* Acquire はロックの取得を試みる（有効期限内のロックがある場合は、同じ所有者が保持していてもfalse） */
+	Acquire(name, owner string, ttl time.Duration) (bool, error)
+	/* Renew は保持しているロックの有効期限を延長する（有効期限が切れて失っていた場合はfalse） */
+	Renew(name, owner string, ttl time.Duration) (bool, error)
+	/* Release は保持しているロックを解放する（他の所有者のロックは解放しない） */
+	Release(name, owner string) error
+	/* Holder は現在ロックを保持している所有者と有効期限を返す（保持されていない場合はfalse） */
+	Holder(name string) (LockLease, bool, error)
+	/* Heartbeat はインスタンスの生存情報を有効期限付きで記録する（/api/admin/cluster で使用） */
+	Heartbeat(beat PeerHeartbeat, ttl time.Duration) error
+	/* Peers は有効期限内の生存情報を返す（ロックを共有しているすべてのインスタンス） */
+	Peers() ([]PeerHeartbeat, error)
+}
+
+/* LockLease はロックの所有者と有効期限 */
+type LockLease struct {
+	Owner     string    `json:"owner"`      // 保持しているインスタンス（lockOwner）
+	ExpiresAt time.Time `json:"expires_at"` // 有効期限（保持している間は延長される）
+}
+
+const (
+	lockNameBackup    = "backup"    // 定期バックアップ
+	lockNameInsights  = "insights"  // コミット活動の異常検知
+	lockNameBackfill  = "backfill"  // 全コミットのバックフィル
+	lockNameSnapshot  = "snapshot"  // 同期した状態のスナップショット
+	lockNameRetention = "retention" // 保持期間を過ぎたデータの削除
+	lockNameReport    = "report"    // 月ごとのレポートの作成
+	lockNameDiscovery = "discovery" // 新しいリポジトリの検出
+)
+
+/* lockNames はすべてのロック名（/api/admin/cluster で保持している所有者を返す） */
+var lockNames = []string{lockNameBackup, lockNameInsights, lockNameBackfill, lockNameSnapshot, lockNameRetention, lockNameReport, lockNameDiscovery}
+
+var (
+	/*
+		lockTTL はロックの有効期限（環境変数 LOCK_TTL、デフォルト: 30秒）
+		ジョブの実行中は lockTTL/3 ごとに延長する
+	*/
+	lockTTL = parseDurationEnv("LOCK_TTL", 30*time.Second)
+	/* lockOwner はこのプロセスをロックの所有者として識別する値（ホスト名 + ランダムな値） */
+	lockOwner = hostname() + "-" + newSessionID()[:8]
+	/* locker はアプリケーション全体で使用するロック（LOCK_BACKENDで選択、起動時に初期化） */
+	locker Locker = newLocalLocker()
+)
+
+/*
+newLockerFromEnv は環境変数の設定に応じたLockerを作成する
+
+使用する環境変数:
+  LOCK_BACKEND - "local"（デフォルト、プロセス内のみ）または "redis"
+  REDIS_URL    - redisの接続先（例: redis://:password@localhost:6379/0、TLSの場合は rediss://）
+*/
+func newLockerFromEnv() (Locker, error) {
+	switch kind := getEnv("LOCK_BACKEND", "local"); kind {
+	case "local":
+		return newLocalLocker(), nil
+	case "redis":
+		rawURL := getEnv("REDIS_URL", "")
+		if rawURL == "" {
+			return nil, fmt.Errorf("REDIS_URL is required when LOCK_BACKEND=redis")
+		}
+		client, err := newRedisClient(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return &redisLocker{client: client, prefix: getEnv("REDIS_LOCK_PREFIX", "giter:lock:")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported LOCK_BACKEND: %s (supported: local, redis)", kind)
+	}
+}
+
+/*
+runExclusive はロックを取得できた場合のみfnを実行する
+実行中はバックグラウンドでロックを延長し、終了後に解放する
+
+引数:
+  name string - ロック名（lockName* 定数）
+  fn func() - ロックを保持している間に実行する処理
+
+戻り値:
+  bool - fnを実行した場合はtrue（他のレプリカ・このインスタンスで実行中、またはロックの取得に失敗した場合はfalse）
+
+注意:
+  - 延長に失敗してロックを失っても、実行中のfnは中断しない（警告のログのみ）
+    ロックを失うのはfnがLOCK_TTLの間に延長できないほど停止した場合に限られる
+*/
+func runExclusive(name string, fn func()) bool {
+	ok, err := locker.Acquire(name, lockOwner, lockTTL)
+	if err != nil {
+		log.Error().Err(err).Str("lock", name).Msg("Failed to acquire lock")
+		return false
+	}
+	if !ok {
+		log.Info().Str("lock", name).Msg("Skipped job already running on this or another replica")
+		return false
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				renewed, err := locker.Renew(name, lockOwner, lockTTL)
+				if err != nil {
+					log.Warn().Err(err).Str("lock", name).Msg("Failed to renew lock")
+				} else if !renewed {
+					log.Warn().Str("lock", name).Msg("Lock lease lost while job is running")
+				}
+			}
+		}
+	}()
+
+	fn()
+
+	close(stop)
+	<-done
+	if err := locker.Release(name, lockOwner); err != nil {
+		log.Warn().Err(err).Str("lock", name).Msg("Failed to release lock")
+	}
+	return true
+}
+
+/*
+localLocker はプロセス内でのみ有効なLockerの実装（LOCK_BACKEND=local）
+レプリカが1つの場合の既定値で、同じプロセス内でのジョブの重複実行を防ぐ
+*/
+type localLocker struct {
+	mu     sync.Mutex
+	leases map[string]localLease
+	/* peers は生存情報（プロセス内のため、このインスタンスのみ） */
+	peers map[string]localPeer
+}
+
+/* localLease はlocalLockerが保持するロックの所有者と有効期限 */
+type localLease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+/* localPeer はlocalLockerが保持する生存情報と有効期限 */
+type localPeer struct {
+	beat      PeerHeartbeat
+	expiresAt time.Time
+}
+
+/* newLocalLocker はプロセス内のLockerを作成する */
+func newLocalLocker() *localLocker {
+	return &localLocker{leases: map[string]localLease{}, peers: map[string]localPeer{}}
+}
+
+/*
+Acquire はロックが未取得または有効期限切れの場合に取得する
+所有者はプロセス内で共通（lockOwner）のため、同じ所有者の有効期限内のロックも取得し直さない（RedisのSET NXと同じ）
+*/
+func (l *localLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if lease, ok := l.leases[name]; ok && now.Before(lease.expiresAt) {
+		return false, nil
+	}
+	l.leases[name] = localLease{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+/* Renew は同じ所有者が有効期限内のロックを保持している場合に延長する */
+func (l *localLocker) Renew(name, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	lease, ok := l.leases[name]
+	if !ok || lease.owner != owner || !now.Before(lease.expiresAt) {
+		return false, nil
+	}
+	l.leases[name] = localLease{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+/* Release は同じ所有者が保持しているロックを解放する */
+func (l *localLocker) Release(name, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lease, ok := l.leases[name]; ok && lease.owner == owner {
+		delete(l.leases, name)
+	}
+	return nil
+}
+
+/* Holder は有効期限内のロックの所有者を返す */
+func (l *localLocker) Holder(name string) (LockLease, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lease, ok := l.leases[name]
+	if !ok || !time.Now().Before(lease.expiresAt) {
+		return LockLease{}, false, nil
+	}
+	return LockLease{Owner: lease.owner, ExpiresAt: lease.expiresAt}, true, nil
+}
+
+/* Heartbeat は生存情報を記録する */
+func (l *localLocker) Heartbeat(beat PeerHeartbeat, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.peers[beat.Owner] = localPeer{beat: beat, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+/* Peers は有効期限内の生存情報を返す */
+func (l *localLocker) Peers() ([]PeerHeartbeat, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var peers []PeerHeartbeat
+	for owner, peer := range l.peers {
+		if !now.Before(peer.expiresAt) {
+			delete(l.peers, owner)
+			continue
+		}
+		peers = append(peers, peer.beat)
+	}
+	return peers, nil
+}
+
+/*
+所有者が一致する場合のみ延長・削除するLuaスクリプト
+GETとPEXPIRE/DELの間に他のレプリカが取得した場合も、そのロックを操作しない
+*/
+const (
+	redisRenewScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+/*
+redisLocker はRedisのキーでロックを表すLockerの実装（LOCK_BACKEND=redis）
+取得は SET NX PX、延長と解放は所有者を確認するLuaスクリプトで行う
+*/
+type redisLocker struct {
+	client *redisClient
+	prefix string // キーの接頭辞（環境変数 REDIS_LOCK_PREFIX、デフォルト: "giter:lock:"）
+}
+
+/* Acquire はキーが存在しない場合のみ、所有者を値として有効期限付きで設定する */
+func (l *redisLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	reply, err := l.client.do("SET", l.prefix+name, owner, "NX", "PX", fmt.Sprint(ttl.Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+	/* 取得できなかった場合はnilが返る */
+	return reply == "OK", nil
+}
+
+/* Renew は所有者が一致する場合のみ有効期限を延長する */
+func (l *redisLocker) Renew(name, owner string, ttl time.Duration) (bool, error) {
+	reply, err := l.client.do("EVAL", redisRenewScript, "1", l.prefix+name, owner, fmt.Sprint(ttl.Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+/* Release は所有者が一致する場合のみキーを削除する */
+func (l *redisLocker) Release(name, owner string) error {
+	_, err := l.client.do("EVAL", redisReleaseScript, "1", l.prefix+name, owner)
+	return err
+}
+
+/* Holder はキーの値（所有者）と残りの有効期限（PTTL）を返す */
+func (l *redisLocker) Holder(name string) (LockLease, bool, error) {
+	owner, err := l.client.do("GET", l.prefix+name)
+	if err != nil || owner == nil {
+		return LockLease{}, false, err
+	}
+	ttl, err := l.client.do("PTTL", l.prefix+name)
+	if err != nil {
+		return LockLease{}, false, err
+	}
+	ms, _ := ttl.(int64)
+	if ms < 0 {
+		/* GETとPTTLの間に解放・失効した場合 */
+		return LockLease{}, false, nil
+	}
+	return LockLease{Owner: fmt.Sprint(owner), ExpiresAt: time.Now().Add(time.Duration(ms) * time.Millisecond)}, true, nil
+}
+
+/* peerKey は生存情報のキー（例: giter:lock:peer:host-1a2b3c4d） */
+func (l *redisLocker) peerKey(owner string) string {
+	return l.prefix + "peer:" + owner
+}
+
+/* Heartbeat は生存情報をJSONとして有効期限付きで設定する */
+func (l *redisLocker) Heartbeat(beat PeerHeartbeat, ttl time.Duration) error {
+	data, err := json.Marshal(beat)
+	if err != nil {
+		return err
+	}
+	_, err = l.client.do("SET", l.peerKey(beat.Owner), string(data), "PX", fmt.Sprint(ttl.Milliseconds()))
+	return err
+}
+
+/*
+Peers は生存情報のキーをSCANで列挙し、それぞれの値を読み込む
+列挙した後に失効したキーは読み飛ばす
+*/
+func (l *redisLocker) Peers() ([]PeerHeartbeat, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := l.client.do("SCAN", cursor, "MATCH", l.peerKey("*"), "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return nil, fmt.Errorf("unexpected redis SCAN reply: %v", reply)
+		}
+		cursor = fmt.Sprint(items[0])
+		found, _ := items[1].([]interface{})
+		for _, key := range found {
+			keys = append(keys, fmt.Sprint(key))
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	var peers []PeerHeartbeat
+	for _, key := range keys {
+		value, err := l.client.do("GET", key)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		var beat PeerHeartbeat
+		if err := json.Unmarshal([]byte(fmt.Sprint(value)), &beat); err != nil {
+			log.Warn().Err(err).Str("key", strings.TrimPrefix(key, l.prefix)).Msg("Ignored invalid peer heartbeat")
+			continue
+		}
+		peers = append(peers, beat)
+	}
+	return peers, nil
+}
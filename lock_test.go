@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+/* useLocalLocker はテストの間だけlockerを新しいプロセス内のLockerに差し替える */
+func useLocalLocker(t *testing.T) *localLocker {
+	t.Helper()
+	l, prev := newLocalLocker(), locker
+	locker = l
+	t.Cleanup(func() { locker = prev })
+	return l
+}
+
+/* held は有効期限内のロックがあるかどうかを返す */
+func (l *localLocker) held(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease, ok := l.leases[name]
+	return ok && time.Now().Before(lease.expiresAt)
+}
+
+func TestRunExclusiveNested(t *testing.T) {
+	l := useLocalLocker(t)
+
+	var inner bool
+	ran := runExclusive(lockNameBackfill, func() {
+		inner = runExclusive(lockNameBackfill, func() { t.Error("nested job ran while the lock was held") })
+		/* 取得できなかった呼び出しは、保持しているロックを解放しない */
+		if !l.held(lockNameBackfill) {
+			t.Error("nested runExclusive released the outer lease")
+		}
+	})
+	if !ran || inner {
+		t.Fatalf("runExclusive = %v, nested = %v; want true, false", ran, inner)
+	}
+	if l.held(lockNameBackfill) {
+		t.Error("lease was not released after the job finished")
+	}
+	if !runExclusive(lockNameBackfill, func() {}) {
+		t.Error("lock could not be acquired again after release")
+	}
+}
+
+func TestRunExclusiveConcurrent(t *testing.T) {
+	useLocalLocker(t)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan bool)
+	go func() {
+		done <- runExclusive(lockNameBackfill, func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ran := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if runExclusive(lockNameBackfill, func() {}) {
+				mu.Lock()
+				ran++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ran != 0 {
+		t.Errorf("%d concurrent jobs ran while the lock was held", ran)
+	}
+
+	close(release)
+	if !<-done {
+		t.Error("first job did not run")
+	}
+}
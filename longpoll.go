@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	/*
+		longPollTimeout はロングポーリングでリクエストを保持する最大の時間
+		環境変数 LONG_POLL_TIMEOUT で変更可能（デフォルト: 30s）
+		リバースプロキシのタイムアウト（nginxのproxy_read_timeoutなど）より短くする
+	*/
+	longPollTimeout = parseDurationEnv("LONG_POLL_TIMEOUT", 30*time.Second)
+	/*
+		longPollInterval は待機中にコミット履歴を確認し直す間隔
+		同期の通知（commitUpdates）がない場合（他のインスタンスが同期した場合など）も新着を検出するために使用する
+		環境変数 LONG_POLL_INTERVAL で変更可能（デフォルト: 10s）
+	*/
+	longPollInterval = parseDurationEnv("LONG_POLL_INTERVAL", 10*time.Second)
+)
+
+/* longPollContextKey はロングポーリングのリクエストであることを示すコンテキストのキー（遅いリクエストの警告から除外する） */
+const longPollContextKey = "long_poll"
+
+/*
+commitNotifier は新しいコミットの同期を待機中のリクエストに通知する
+同期のたびにチャネルを閉じて作り直すため、待機中の全てのリクエストが同時に起きる
+*/
+type commitNotifier struct {
+	mu      sync.Mutex
+	updated chan struct{}
+}
+
+/* commitUpdates はコミット履歴の同期を待機中のロングポーリングに通知する */
+var commitUpdates = &commitNotifier{updated: make(chan struct{})}
+
+/* wait は次の通知で閉じられるチャネルを返す */
+func (n *commitNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.updated
+}
+
+/* notify は待機中の全てのリクエストを起こす */
+func (n *commitNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.updated)
+	n.updated = make(chan struct{})
+}
+
+/*
+pollQuery は GET /api/git-history/poll のクエリパラメータ
+*/
+type pollQuery struct {
+	Cursor  string `form:"cursor"`                            // 取得済みの最新のコミット日時（RFC3339、前回のレスポンスのcursor）
+	Timeout int    `form:"timeout" binding:"omitempty,min=1"` // 待機する最大の秒数（LONG_POLL_TIMEOUTを上限とする）
+}
+
+/* validateFields はcursorの日時の形式を検証する */
+func (q *pollQuery) validateFields() []FieldError {
+	if _, err := q.cursor(); err != nil {
+		return []FieldError{{Field: "cursor", Rule: "datetime", Message: "must be an RFC3339 timestamp"}}
+	}
+	return nil
+}
+
+/* cursor はcursorの日時を返す（省略時はnil） */
+func (q *pollQuery) cursor() (*time.Time, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+/*
+pollNewCommits はcursorより新しいコミットが届くまでリクエストを保持するAPIハンドラー（ロングポーリング）
+SSE・WebSocketを通さないプロキシの環境で、新着コミットをほぼリアルタイムに受け取るために使用する
+レスポンスのcursorを次のリクエストに渡して繰り返し呼び出す
+
+クエリパラメータ:
+  cursor - 取得済みの最新のコミット日時（RFC3339、省略時は待機せず現在の最新のコミット日時を返す）
+  timeout - 待機する最大の秒数（省略時・上限はLONG_POLL_TIMEOUT）
+
+レスポンス:
+  成功時: 200 OK, NewCommitsResponse（タイムアウトした場合はcommitsが空で、cursorは指定した値のまま）
+  失敗時: 422 Unprocessable Entity（cursor・timeoutが不正）, 500 Internal Server Error（コミット履歴を取得できない）
+
+注意:
+  - コミット履歴の同期（GET /api/git-history）の完了時と、LONG_POLL_INTERVALごとに新着を確認する
+  - クライアントが切断した場合は待機をやめる
+  - 既読位置（GET /api/git-history/new）は変更しない
+*/
+func pollNewCommits(c *gin.Context) {
+	var query pollQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	c.Set(longPollContextKey, true)
+	/* validateFieldsで読み込めることを確認済み */
+	cursor, _ := query.cursor()
+
+	timeout := longPollTimeout
+	if query.Timeout > 0 {
+		timeout = min(timeout, time.Duration(query.Timeout)*time.Second)
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	recheck := time.NewTicker(longPollInterval)
+	defer recheck.Stop()
+
+	v := requestVisibility(c)
+	for {
+		/* 確認中に届いた同期の通知を取りこぼさないよう、確認の前に待機するチャネルを取得する */
+		updated := commitUpdates.wait()
+		commits, _, err := fetchVisibleCommitHistory(v)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch commit history for long polling")
+			respondError(c, http.StatusInternalServerError, "failed to fetch commit history from GitHub")
+			return
+		}
+		newer, latest := newCommitsSince(commits, cursor)
+		if cursor == nil || len(newer) > 0 {
+			respondPoll(c, cursor, latest, newer)
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-recheck.C:
+		case <-deadline.C:
+			respondPoll(c, cursor, cursor, []CommitHistory{})
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+/* respondPoll はロングポーリングの結果を返す */
+func respondPoll(c *gin.Context, since, cursor *time.Time, commits []CommitHistory) {
+	respondJSON(c, http.StatusOK, NewCommitsResponse{
+		Since:   since,
+		Cursor:  cursor,
+		Count:   len(commits),
+		Commits: requestRedaction(c).commits(commits),
+	})
+}
@@ -1,13 +1,14 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/develop-suda/giter/web"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -19,10 +20,28 @@ Repository はGitHub APIから取得するリポジトリ情報を表す構造
 GitHub API v3のリポジトリレスポンスの一部フィールドをマッピング
 */
 type Repository struct {
-	Name        string `json:"name"`        // リポジトリ名（例: "my-project"）
-	FullName    string `json:"full_name"`   // フルネーム（例: "develop-suda/my-project"）
-	Description string `json:"description"` // リポジトリの説明文
-	HTMLURL     string `json:"html_url"`    // GitHubのリポジトリURL
+	Name            string             `json:"name"`              // リポジトリ名（例: "my-project"）
+	FullName        string             `json:"full_name"`         // フルネーム（例: "develop-suda/my-project"）
+	Description     string             `json:"description"`       // リポジトリの説明文
+	HTMLURL         string             `json:"html_url"`          // GitHubのリポジトリURL
+	Language        string             `json:"language"`          // 主な言語（GitHubが判定できない場合は空文字）
+	Private         bool               `json:"private"`           // プライベートリポジトリの場合はtrue（PRIVACY_MODEで匿名の閲覧者から隠す）
+	License         *RepositoryLicense `json:"license"`           // ライセンス（GitHubが判定できない場合はnull）
+	StargazersCount int                `json:"stargazers_count"`  // スター数
+	OpenIssuesCount int                `json:"open_issues_count"` // オープン中のIssue数（PRを含む）
+	Archived        bool               `json:"archived"`          // アーカイブ（読み取り専用）されている場合はtrue
+	DefaultBranch   string             `json:"default_branch"`    // デフォルトブランチ名（例: "main"）
+	Topics          []string           `json:"topics"`            // トピック（例: ["go", "cli"]）
+}
+
+/*
+RepositoryLicense はGitHubが判定したリポジトリのライセンス
+API仕様: https://docs.github.com/ja/rest/licenses/licenses
+*/
+type RepositoryLicense struct {
+	Key    string `json:"key"`     // ライセンスのキー（例: "mit"）
+	Name   string `json:"name"`    // ライセンス名（例: "MIT License"）
+	SPDXID string `json:"spdx_id"` // SPDX識別子（例: "MIT"、判定できない場合は "NOASSERTION"）
 }
 
 /*
@@ -42,6 +61,10 @@ type Commit struct {
 		} `json:"author"`
 	} `json:"commit"`
 	HTMLURL string `json:"html_url"` // GitHubのコミットURL
+	/* Parentsは親コミット（通常のコミットは1件、マージコミットは2件以上、最初のコミットは0件） */
+	Parents []struct {
+		SHA string `json:"sha"` // 親コミットのハッシュ
+	} `json:"parents"`
 }
 
 /*
@@ -49,21 +72,26 @@ CommitHistory はフロントエンドに返却するレスポンス用の構造
 GitHub APIのレスポンスを整形し、必要な情報のみを含む
 */
 type CommitHistory struct {
-	RepositoryName string    `json:"repository_name"` // リポジトリ名
-	CommitMessage  string    `json:"commit_message"`  // コミットメッセージ
-	CommitSHA      string    `json:"commit_sha"`      // コミットハッシュ（短縮形、7文字）
-	CommitTime     time.Time `json:"commit_time"`     // コミット作成日時
-	CommitURL      string    `json:"commit_url"`      // GitHubのコミットページへのリンク
+	RepositoryName string    `json:"repository_name"`        // リポジトリ名
+	CommitMessage  string    `json:"commit_message"`         // コミットメッセージ
+	CommitSHA      string    `json:"commit_sha"`             // コミットハッシュ（短縮形、7文字）
+	CommitTime     time.Time `json:"commit_time"`            // コミット作成日時
+	CommitURL      string    `json:"commit_url"`             // GitHubのコミットページへのリンク
+	AuthorName     string    `json:"author_name,omitempty"`  // 作成者名
+	AuthorEmail    string    `json:"author_email,omitempty"` // 作成者のメールアドレス（EMAIL_PRIVACYで変換済み）
+	Labels         []string  `json:"labels,omitempty"`       // LABEL_RULESで付けたラベル（レスポンスを返す段階で付ける）
+	Orphaned       bool      `json:"orphaned,omitempty"`     // 強制プッシュなどでブランチから外れたアーカイブのコミットの場合はtrue
+	Parents        []string  `json:"parents,omitempty"`      // 親コミットのハッシュ（短縮形、7文字、コミットグラフに使用）
 }
 
 const (
-	/* githubAPIBase はGitHub REST API v3のベースURL */
-	githubAPIBase = "https://api.github.com"
 	/*
 		username は取得対象のGitHubユーザー名
 		定数として定義することで、変更が容易になる
 	*/
 	username = "develop-suda"
+	/* logRootDir はログファイルを保存するディレクトリ（RETENTION_LOGS_DAYSで古い日付から削除する） */
+	logRootDir = "log"
 )
 
 /*
@@ -83,7 +111,7 @@ func setupLogger() (*os.File, error) {
 	yearMonthDay := now.Format("20060102") // YYYYMMDD形式
 
 	// ログディレクトリのパスを構築
-	logDir := fmt.Sprintf("log/%s/%s", yearMonth, yearMonthDay)
+	logDir := fmt.Sprintf("%s/%s/%s", logRootDir, yearMonth, yearMonthDay)
 
 	// ディレクトリを作成（0755は読み取り・実行は全ユーザー、書き込みは所有者のみ）
 	if err := os.MkdirAll(logDir, 0755); err != nil {
@@ -146,68 +174,559 @@ func main() {
 
 	log.Info().Msg("Starting application initialization")
 
+	if githubToken != "" {
+		log.Info().Msg("GitHub API requests are authenticated with GITHUB_TOKEN")
+	} else {
+		log.Warn().Int("limit_per_hour", githubUnauthenticatedRateLimit).Msg("GITHUB_TOKEN is not set, GitHub API requests are unauthenticated")
+	}
+
+	/*
+		永続化されたデータ（通知の受信箱・通知設定など）を読み込む
+		読み込みに失敗したテーブルは空の状態で起動を続ける
+	*/
+	if err := loadTables(); err != nil {
+		log.Error().Err(err).Msg("Failed to load stored data")
+	}
+
+	/*
+		Sentry（エラー・パフォーマンス監視）の初期化
+		SENTRY_DSNが未設定の場合は何もしない
+	*/
+	if err := initSentry(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid Sentry configuration")
+	}
+
+	/*
+		BlobStore（エクスポートなど大きなデータの保存先）の初期化
+		BLOB_STORAGE=s3 の場合はS3互換バケット、それ以外はローカルディレクトリ
+	*/
+	if blobs, err = newBlobStoreFromEnv(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize blob storage")
+	}
+
+	/*
+		定期ジョブの重複実行を防ぐロックの初期化
+		LOCK_BACKEND=redis の場合は複数のレプリカの間で共有する
+	*/
+	if locker, err = newLockerFromEnv(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize lock backend")
+	}
+
+	/*
+		HistoryStore（アーカイブ・バックフィルしたコミット履歴の保存先）の初期化
+		HISTORY_STORE=memory・sqlite・postgres の場合はファイル以外に保存する
+	*/
+	if history, err = newHistoryStoreFromEnv(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize history store")
+	}
+
+	/*
+		HistoryStoreのスキーマのマイグレーション（HISTORY_STORE=sqlite・postgres の場合のみ）
+		--migrate-only・--rollback はコマンドの中で適用・戻すため、ここでは適用しない
+	*/
+	if len(os.Args) < 2 || !isMigrationCommand(os.Args[1]) {
+		if err := migrateHistoryStore(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to migrate history store")
+		}
+	}
+
+	/*
+		サブコマンド（backup, restore など）が指定された場合は
+		Webサーバーを起動せずにコマンドを実行して終了する
+	*/
+	if len(os.Args) > 1 {
+		code := runCommand(os.Args[1:])
+		flushSentry()
+		logFile.Close()
+		os.Exit(code)
+	}
+
 	/*
-		gin.Default()はロガーとリカバリーミドルウェアが組み込まれたGinエンジンを作成
-		リカバリーミドルウェアはpanicを検知し、500エラーを返す
+		バックアップ設定の読み込みと定期バックアップの開始
+		BACKUP_INTERVALが未設定の場合、定期バックアップは行わない
 	*/
-	r := gin.Default()
+	backupCfg, err := loadBackupConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid backup configuration")
+	}
+	storage, err := newBackupStorage(backupCfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize backup storage")
+	}
+	startBackupScheduler(backupCfg, storage)
+	/* コミット活動の異常検知ジョブ（INSIGHTS_INTERVAL=0で無効） */
+	startInsightsScheduler()
+	/* 同期した状態のスナップショットの作成（SNAPSHOT_INTERVAL=0で無効） */
+	startSnapshotScheduler()
+	/* 保持期間を過ぎたデータの削除（RETENTION_*_DAYSのいずれかを設定した場合のみ） */
+	startRetentionScheduler()
+	/* 前月のレポート（PDF）の作成（REPORT_MONTHLY=trueの場合のみ） */
+	startReportScheduler()
+	/* 新しく作成されたリポジトリの検出（REPO_DISCOVERY_INTERVAL=0で無効） */
+	startRepoDiscoveryScheduler()
+	/* ロックのバックエンドへの生存情報の記録（/api/admin/cluster） */
+	startClusterHeartbeat()
+	/* 再起動前に未完了だったバックフィルをチェックポイントから再開する */
+	resumeBackfill()
+	backups := &backupHandlers{cfg: backupCfg, storage: storage}
+
+	r := newRouter(backups)
+
+	/*
+		待ち受け先のリスナーを作成する
+		systemdのソケットアクティベーション → Unixドメインソケット（LISTEN_SOCKET） → TCP（LISTEN_ADDR、デフォルト: ":8080"）
+	*/
+	ln, addr, err := newListener()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to listen")
+	}
+
+	/* サーバー起動メッセージ */
+	log.Info().Str("listen", addr).Msg("Server starting")
+
+	/*
+		Webサーバーを起動し、リスナーでリクエストを待ち受ける
+		この関数はブロッキングで、サーバーが停止するまで戻らない
+	*/
+	if err := r.RunListener(ln); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start server")
+	}
+}
+
+/*
+newRouter はミドルウェアとすべてのルートを登録したGinエンジンを作成する
+main関数から呼び出すほか、テストではHTTPの層全体をメモリ上のHistoryStore（HISTORY_STORE=memory）で確認するために使用する
+
+引数:
+  backups *backupHandlers - バックアップの一覧・作成の管理者APIのハンドラー
+*/
+func newRouter(backups *backupHandlers) *gin.Engine {
+	/*
+		gin.New()でミドルウェアなしのGinエンジンを作成し、以下を登録する
+		  - requestIDMiddleware(): リクエストIDの割り当て（X-Request-ID）
+		  - accessLogMiddleware(): アクセスログ（GitHub APIへの送信ログと同じくzerologに出力する）
+		  - slowRequestMiddleware(): SLOW_REQUEST_THRESHOLDを超えたリクエストの警告
+		  - sentryMiddleware(): Sentryへのトランザクション・5xxエラーの送信（SENTRY_DSN設定時のみ）
+		  - recoveryMiddleware(): panicを検知し、スタックトレースを記録・報告して500エラーを返す
+		Gin標準のリカバリーミドルウェアは使用しない
+	*/
+	r := gin.New()
+	/*
+		クライアントのIPアドレス（c.ClientIP()）は認証の総当たり対策・監査ログに使用するため、
+		X-Forwarded-ForはTRUSTED_PROXIESからの接続の場合のみ信頼する
+	*/
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal().Err(err).Msg("Invalid TRUSTED_PROXIES")
+	}
+	r.Use(requestIDMiddleware(), accessLogMiddleware(), slowRequestMiddleware(), sentryMiddleware(), recoveryMiddleware())
+
+	/*
+		Content-Security-Policy
+		リクエストごとのnonceを生成し、nonceを付けたインラインスクリプトのみ実行を許可する
+	*/
+	r.Use(cspMiddleware())
 
 	/*
 		CORS（Cross-Origin Resource Sharing）ミドルウェアの設定
 		フロントエンドが異なるオリジンから API を呼び出せるようにする
 	*/
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},                                      // すべてのオリジンからのアクセスを許可（本番環境では制限を推奨）
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}, // 許可するHTTPメソッド
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},        // 許可するリクエストヘッダー
-		ExposeHeaders:    []string{"Content-Length"},                          // フロントエンドに公開するレスポンスヘッダー
-		AllowCredentials: true,                                                // クッキーなどの認証情報の送信を許可
-		MaxAge:           12 * time.Hour,                                      // プリフライトリクエストのキャッシュ時間
+		AllowOrigins:     []string{"*"},                                                         // すべてのオリジンからのアクセスを許可（本番環境では制限を推奨）
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},                   // 許可するHTTPメソッド
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},                          // 許可するリクエストヘッダー
+		ExposeHeaders:    []string{"Content-Length", requestIDHeader, "Link", totalCountHeader}, // フロントエンドに公開するレスポンスヘッダー
+		AllowCredentials: true,                                                                  // クッキーなどの認証情報の送信を許可
+		MaxAge:           12 * time.Hour,                                                        // プリフライトリクエストのキャッシュ時間
 	}))
 
+	/*
+		セッションミドルウェア
+		閲覧者ごとのセッションIDをクッキーで発行し、通知の受信箱などの識別に使用する
+	*/
+	r.Use(sessionMiddleware())
+
+	/*
+		認証のミドルウェアチェーン（APIキー → OAuthのセッション → 共有URLのトークン → 匿名）
+		閲覧者のロールをルートごとの方針（authPolicies）と照合し、満たさないリクエストを401・403で拒否する
+	*/
+	r.Use(authMiddleware())
+
+	/*
+		CSRF対策
+		セッションのクッキーを送るブラウザからのPOST・PUT・DELETEに、X-CSRF-Tokenヘッダー（画面の<meta name="csrf-token">）を要求する
+	*/
+	r.Use(csrfMiddleware())
+
+	/*
+		機能フラグミドルウェア
+		FEATURE_FLAGSの設定をもとに、管理者はX-Giter-Featuresヘッダーでリクエスト単位に上書きできる
+	*/
+	r.Use(featureFlagMiddleware())
+
+	/*
+		言語の決定（?lang= → Accept-Language → 日本語）
+		画面とAPIのエラーメッセージの翻訳に使用する
+	*/
+	r.Use(i18nMiddleware())
+
+	/*
+		メンテナンスモード（POST /api/admin/maintenance）
+		メンテナンス中は管理者API・ヘルスチェック以外のリクエストに503とメンテナンス中のページを返す
+	*/
+	r.Use(maintenanceMiddleware())
+
+	/*
+		非推奨のルート（DEPRECATED_ROUTES）
+		Deprecation・Sunset・Warningヘッダーを付け、ルートごとの利用状況を記録する（GET /api/admin/deprecations）
+	*/
+	r.Use(deprecationMiddleware())
+
+	/*
+		レスポンスのキャッシュ（RESPONSE_CACHE_POLICIES）
+		ルートごとの方針に従ってCache-Controlを付け、集計APIやグラフの画像のレスポンスをメモリ上に保持する
+	*/
+	r.Use(responseCacheMiddleware())
+
+	/*
+		すべてのルートはBASE_PATH（例: "/giter"）の下に登録する
+		BASE_PATH未設定の場合、appはルート（"/"）のグループになる
+	*/
+	app := r.Group(basePath)
+	if basePath != "" {
+		r.GET("/", redirectToBasePath)
+	}
+
 	/*
 		静的ファイルの配信設定
 		URLパス "/static" へのアクセスを "./static" ディレクトリにマッピング
 		例: /static/css/style.css -> ./static/css/style.css
 	*/
-	r.Static("/static", "./static")
+	app.Static("/static", "./static")
 
 	/*
 		HTMLテンプレートファイルの読み込み
 		"templates/*" パターンに一致するすべてのファイルをテンプレートとして登録
+		SetFuncMapで相対時間・3桁区切りなどのカスタム関数を先に登録しておく
 	*/
+	r.SetFuncMap(web.FuncMap())
 	r.LoadHTMLGlob("templates/*")
 
+	/*
+		メッセージカタログ（locales/ja.json, locales/en.json）の読み込み
+		画面の文言とAPIのエラーメッセージをリクエストの言語に翻訳する
+	*/
+	if err := loadLocales(localesDir); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load message catalogs")
+	}
+
 	/*
 		ルートページ（"/"）へのGETリクエストのハンドラー
 		index.htmlテンプレートをレンダリングして返す
 	*/
-	r.GET("/", func(c *gin.Context) {
-		/*
-			第一引数: HTTPステータスコード（200 OK）
-			第二引数: テンプレート名
-			第三引数: テンプレートに渡すデータ（今回はnil）
-		*/
-		c.HTML(http.StatusOK, "index.html", nil)
-	})
+	app.GET("/", showIndexPage)
+
+	/*
+		リポジトリ詳細ページ
+		説明・最近のコミット・ブランチ・統計をサーバーでレンダリングする（repo.html）
+		トップページのコミットカードのリポジトリ名からリンクされる
+	*/
+	app.GET("/repos/:owner/:repo", showRepositoryPage)
+	/* 週次ダイジェストページ（?week=2025-W30、省略時は前週） */
+	app.GET("/digest/weekly", showWeeklyDigestPage)
+	/* 年間のまとめページ（"GitHub Wrapped" 風） */
+	app.GET("/wrapped/:year", showWrappedPage)
+	/* 共有URLのページ（POST /api/share で作成した署名付きのトークン、認証なしで閲覧できる） */
+	app.GET("/share/:token", showSharePage)
+
+	/*
+		GitHubのOAuthによるログイン（GITHUB_OAUTH_CLIENT_ID・GITHUB_OAUTH_CLIENT_SECRET）
+		ログインしたユーザーはOAUTH_USERSのロールで認証される。/auth/session で認証の状態を返す
+	*/
+	app.GET("/auth/github", startGitHubLogin)
+	app.GET("/auth/github/callback", githubLoginCallback)
+	app.POST("/auth/logout", postLogout)
+	app.GET("/auth/session", getAuthSession)
+	/* 他のサイトのiframeに埋め込むための最近のコミットのタイムライン（EMBED_ALLOWED_ORIGINS） */
+	app.GET("/embed/timeline", showEmbedTimeline)
+	/* READMEやメールに埋め込むための日ごとのコミット数のグラフ（PNG） */
+	app.GET("/charts/activity.png", getActivityChartPNG)
+	/* プロフィールのREADMEなどに小さく埋め込むための日ごとのコミット数のスパークライン（SVG） */
+	app.GET("/charts/sparkline.svg", getSparklineSVG)
+	/* GitHubのアバター・OGP画像の中継と縮小（フロントエンドから第三者のホストへのリクエストをなくす） */
+	app.GET("/img/proxy", getImageProxy)
+
+	/*
+		検索エンジン向けのrobots.txtとsitemap.xml
+		SEARCH_INDEXABLE=false でインデックスを拒否できる
+	*/
+	app.GET("/robots.txt", getRobotsTxt)
+	app.GET("/sitemap.xml", getSitemap)
+
+	/*
+		ファビコンとPWA（マニフェスト・アイコン・Service Worker）
+		アイコンとマニフェストはSITE_NAME・THEME_COLORから生成する
+	*/
+	app.GET("/favicon.ico", getFavicon)
+	app.GET("/icons/:name", getIcon)
+	app.GET("/manifest.webmanifest", getManifest)
+	app.GET("/sw.js", getServiceWorker)
 
 	/*
 		Git履歴APIエンドポイント
 		"/api/git-history" へのGETリクエストをgetGitHistory関数で処理
 		このエンドポイントは全リポジトリのコミット履歴をJSON形式で返す
 	*/
-	r.GET("/api/git-history", getGitHistory)
+	app.GET("/api/git-history", getGitHistory)
 
-	/* サーバー起動メッセージ */
-	log.Info().Str("port", "8080").Msg("Server starting")
+	/*
+		前回の訪問以降の新着コミット
+		閲覧者（セッション）ごとの既読位置より新しいコミットを返し、/ack で既読位置を進める
+	*/
+	app.GET("/api/git-history/new", getNewCommits)
+	app.GET("/api/git-history/poll", pollNewCommits)
+	app.POST("/api/git-history/new/ack", ackNewCommits)
 
 	/*
-		Webサーバーを起動し、ポート8080でリクエストを待ち受ける
-		この関数はブロッキングで、サーバーが停止するまで戻らない
+		リポジトリのAPIエンドポイント
+		メタデータ（ライセンス・スター数・言語など）で絞り込んだ一覧と、
+		リポジトリごとの詳細と統計、コミット履歴、ブランチ一覧を返す（対象はusernameのリポジトリのみ）
 	*/
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal().Err(err).Msg("Failed to start server")
+	app.GET("/api/repositories", getRepositories)
+	/* リポジトリの名前・トピック・説明文の検索（UIのクイックスイッチャー用、入力途中の語は前方一致） */
+	app.GET("/api/search/repos", getRepoSearch)
+	/* コミットメッセージの全文検索（フレーズ・前方一致、BM25のスコアの高い順） */
+	app.GET("/api/search", getCommitSearch)
+	app.GET("/api/repos/:owner/:repo", getRepository)
+	app.GET("/api/repos/:owner/:repo/commits", getRepositoryCommits)
+	app.GET("/api/repos/:owner/:repo/branches", getRepositoryBranches)
+	/* コミットと親コミットの関係（ブランチの分岐とマージのグラフの描画用） */
+	app.GET("/api/repos/:owner/:repo/graph", getRepositoryGraph)
+	/* トップレベルのファイル・ディレクトリごとの最後のコミット（しばらく変更していない部分の一覧） */
+	app.GET("/api/repos/:owner/:repo/files", getRepositoryFiles)
+	/* READMEをサニタイズしたHTMLで返す（リポジトリ詳細ページへの埋め込み用） */
+	app.GET("/api/repos/:owner/:repo/readme", getRepositoryReadme)
+
+	/*
+		アクティビティAPIエンドポイント
+		コミット・PR・Issue・リリース・スターを統合したフィードを返す
+		?kind=commit,release のように種類で絞り込み可能
+	*/
+	app.GET("/api/activity", getActivity)
+	/* GitHubのプロフィールと、同期したデータから計算した合計（ダッシュボードのヘッダー用） */
+	app.GET("/api/profile", getProfile)
+
+	/*
+		異常検知APIエンドポイント
+		コミットの急な停止・急増・深夜のコミットの増加を返す
+	*/
+	app.GET("/api/insights", getInsights)
+
+	/*
+		コミットのペースのSLO
+		「7日間に1件以上」のような期待値の登録（reader以上）と、同期のたびに評価した結果を返す
+	*/
+	app.GET("/api/slo", getCommitSLOs)
+	app.POST("/api/slo", postCommitSLO)
+	app.PUT("/api/slo/:id", putCommitSLO)
+	app.DELETE("/api/slo/:id", deleteCommitSLO)
+
+	/*
+		スナップショットAPIエンドポイント
+		定期的に保存した同期の状態の一覧と、2つのスナップショットの差分を返す
+	*/
+	app.GET("/api/snapshots", getSnapshots)
+	app.GET("/api/snapshots/:a/diff/:b", getSnapshotDiff)
+
+	/*
+		リポジトリのヘルススコアAPIエンドポイント
+		最終コミット・コミット頻度・Issueへの対応・CIの結果から計算し、重みはHEALTH_SCORE_WEIGHTSで調整できる
+	*/
+	app.GET("/api/stats/health", getHealthScores)
+	/* 今月の残りの日のコミット数の予測 */
+	app.GET("/api/stats/forecast", getForecast)
+	/* コミットメッセージのキーワードの出現回数（ワードクラウド用） */
+	app.GET("/api/stats/keywords", getKeywords)
+	/* コミットした時間帯（勤務時間内・勤務時間外・休日）の内訳と月ごとの推移 */
+	app.GET("/api/stats/working-hours", getWorkingHours)
+	/* プルリクエストの最初のレビューまでとマージまでの所要時間（リポジトリごと・月ごとのパーセンタイル） */
+	app.GET("/api/stats/review-latency", getReviewLatency)
+	/* Issueのクローズまでの所要時間・月ごとの作成数とクローズ数・オープン中のIssueの経過日数の分布 */
+	app.GET("/api/stats/issues", getIssueStats)
+	/* デプロイの頻度（Deployments、ない場合はリリース・タグ）の週ごとの推移と環境ごとの内訳 */
+	app.GET("/api/stats/deployments", getDeploymentStats)
+	/* CUSTOM_METRICSに定義したカスタムメトリクスの一覧と集計結果 */
+	app.GET("/api/metrics/custom", getCustomMetrics)
+	app.GET("/api/metrics/custom/:name", getCustomMetric)
+	/* LABEL_RULESのラベル（bugfix, docs など）ごとのコミット数 */
+	app.GET("/api/stats/labels", getLabelStats)
+	/* リポジトリごとの直近7・30・90日間のコミット数（集計のビューから返す） */
+	app.GET("/api/stats/windows", getStatsWindows)
+	/* フォロワー数・フォロー数の推移と、期間内に増えた・減った数（同期のたびに記録したスナップショットから返す） */
+	app.GET("/api/stats/followers", getFollowerStats)
+	/* 1週間の活動のまとめ（/digest/weekly と同じ内容） */
+	app.GET("/api/digest", getDigest)
+	/* 1年間の活動のまとめ（/wrapped/:year と同じ内容） */
+	app.GET("/api/wrapped/:year", getWrapped)
+
+	/*
+		期間限定の共有URLの作成
+		特定のリポジトリ・期間のコミット履歴を認証なしで閲覧できるURLを発行するため、管理者のみ
+	*/
+	app.POST("/api/share", idempotencyMiddleware(), postShare)
+
+	/*
+		活動のレポート（PDF）
+		作成はGitHubへのリクエストが多いため管理者のみ。一覧とダウンロードはデータの範囲（PRIVACY_MODE）に従う
+	*/
+	app.GET("/api/reports", getReports)
+	app.POST("/api/reports", idempotencyMiddleware(), postReport)
+	app.GET("/api/reports/:id", downloadReport)
+
+	/*
+		通知APIエンドポイント
+		閲覧者ごとの受信箱の取得、既読化、通知設定の取得・更新を行う
+	*/
+	app.GET("/api/notifications", getNotifications)
+	app.POST("/api/notifications/read-all", markAllNotificationsRead)
+	app.POST("/api/notifications/:id/read", markNotificationRead)
+	app.GET("/api/notifications/preferences", getNotificationPreferences)
+	app.PUT("/api/notifications/preferences", putNotificationPreferences)
+
+	/* 表示設定API（テーマなど、閲覧者ごとの表示設定の取得・更新） */
+	app.GET("/api/preferences", getPreferences)
+	app.PUT("/api/preferences", putPreferences)
+
+	/*
+		保存した検索条件API（名前を付けた /api/git-history のクエリパラメータ）
+		/api/git-history?saved=<名前> で使用する。workspaceの検索条件の変更は管理者のみ
+	*/
+	app.GET("/api/saved-searches", getSavedSearches)
+	app.POST("/api/saved-searches", postSavedSearch)
+	app.GET("/api/saved-searches/:name", getSavedSearch)
+	app.PUT("/api/saved-searches/:name", putSavedSearch)
+	app.DELETE("/api/saved-searches/:name", deleteSavedSearch)
+
+	/*
+		GitHub APIプロキシ
+		/proxy/github/* へのGETリクエストをキャッシュ・ETag・レート制限の管理を通して中継する
+	*/
+	app.GET(proxyPathPrefix+"/*path", proxyGitHub)
+	app.GET("/api/rate-limit", getRateLimit)
+
+	/* 機能フラグ（リクエストで有効なフラグの一覧） */
+	app.GET("/api/features", getFeatures)
+
+	/*
+		複数のGETリクエストをまとめて実行する（ダッシュボードの往復を減らす）
+		サブリクエストはこのエンジン自身で処理するため、認証・PRIVACY_MODE・キャッシュは個別のリクエストと同じに適用される
+	*/
+	app.POST("/api/batch", batchHandler(r))
+
+	/*
+		ヘルスチェック
+		GitHub APIへの疎通を短いタイムアウト（GITHUB_TIMEOUT_HEALTH_*）で確認する
+	*/
+	app.GET("/healthz", healthCheck)
+
+	/*
+		メトリクス
+		GitHub APIへの接続の再利用状況やレイテンシをPrometheusのテキスト形式で返す
+	*/
+	app.GET("/metrics", getMetrics)
+	/* コミットのデータのエクスポーター（リポジトリごとのコミット数・最終コミット日時） */
+	app.GET("/metrics/activity", getActivityMetrics)
+
+	/*
+		GrafanaのSimple JSON / Infinityデータソース
+		データソースのURLに /grafana を指定すると、コミット数の時系列をグラフにできる
+	*/
+	grafana := app.Group("/grafana")
+	{
+		grafana.GET("", getGrafanaRoot)
+		grafana.POST("/search", postGrafanaSearch)
+		grafana.POST("/query", postGrafanaQuery)
 	}
+
+	/*
+		管理者APIエンドポイント
+		authMiddlewareで管理者のロール（ADMIN_TOKEN・adminのAPI_KEYS・OAuthの管理者）を確認する
+		POSTはIdempotency-Keyを指定すると、再送されたリクエストに保存したレスポンスを返す（idempotencyMiddleware）
+	*/
+	admin := app.Group("/api/admin", idempotencyMiddleware())
+	{
+		/* 保存データ一式のエクスポート（zip）とインポート */
+		admin.GET("/export", exportData)
+		admin.POST("/import", importData)
+		/* BlobStore（ローカルまたはS3）へのエクスポートの保存・一覧・ダウンロード */
+		admin.POST("/exports", storeExport)
+		admin.GET("/exports", listStoredExports)
+		admin.GET("/exports/:name", downloadStoredExport)
+		/* バックアップの一覧と即時作成（復元は ./giter restore コマンドで行う） */
+		admin.GET("/backups", backups.list)
+		admin.POST("/backups", backups.create)
+		/* 全リポジトリの全コミットのバックフィル（チェックポイントから再開可能） */
+		admin.GET("/backfill", getBackfill)
+		admin.POST("/backfill", postBackfill)
+		/* 取得・集計のジョブキューの状態（種類ごとの件数と最近の失敗） */
+		admin.GET("/jobs", getJobs)
+		/* 機能ごとのGitHub APIのレート制限の予算 */
+		admin.GET("/budget", getBudget)
+		/* スナップショットの即時作成（一覧と差分は /api/snapshots） */
+		admin.POST("/snapshots", postSnapshot)
+		/* コミットメッセージのマスク（REDACTION_RULES）のセッション単位の切り替え */
+		admin.GET("/redaction", getRedaction)
+		admin.PUT("/redaction", putRedaction)
+		/* 保存データの削除（確認トークンが必要）と監査ログ */
+		admin.DELETE("/data", deleteData)
+		admin.GET("/audit", getAuditLog)
+		/* 保持期間の設定と、保持期間を過ぎたデータの削除（dry-run可） */
+		admin.GET("/retention", getRetention)
+		admin.POST("/retention", postRetention)
+		/* 定期ジョブのロックの保持状況とインスタンスの生存情報 */
+		admin.GET("/cluster", getCluster)
+		/* ルートごとのレスポンスのキャッシュの方針と利用状況 */
+		admin.GET("/cache/policies", getCachePolicies)
+		/* 非推奨のルートの利用状況（削除してよいかの判断用） */
+		admin.GET("/deprecations", getDeprecations)
+		/* 認証の失敗によるロックの一覧と解除 */
+		admin.GET("/auth/lockouts", getAuthLockouts)
+		admin.DELETE("/auth/lockouts", deleteAuthLockout)
+		/* メンテナンスモードの状態の取得と切り替え */
+		admin.GET("/maintenance", getMaintenance)
+		admin.POST("/maintenance", postMaintenance)
+		/* 送信Webhook（通知のイベントを送信するHTTPの送信先）の登録と、再試行・デッドレターの確認 */
+		admin.GET("/webhooks", getWebhooks)
+		admin.POST("/webhooks", postWebhook)
+		admin.GET("/webhooks/deliveries", getWebhookDeliveries)
+		admin.PUT("/webhooks/:id", putWebhook)
+		admin.DELETE("/webhooks/:id", deleteWebhook)
+		admin.POST("/webhooks/:id/test", postWebhookTest)
+		/* 同期の対象のリポジトリの追加・除外（再起動なしで反映し、追加したリポジトリはすぐに同期する） */
+		admin.GET("/repos", getRepoTracking)
+		admin.POST("/repos/track", postTrackRepository)
+		admin.DELETE("/repos/untrack", deleteUntrackRepository)
+		/* 閲覧者から隠すリポジトリ（GitHubで公開されていても管理者以外には返さない） */
+		admin.GET("/repos/hidden", getHiddenRepositories)
+		admin.PUT("/repos/hidden/:name", putHiddenRepository)
+		admin.DELETE("/repos/hidden/:name", deleteHiddenRepository)
+		/* 強制プッシュ・デフォルトブランチの変更の検出結果（孤立したコミットの一覧） */
+		admin.GET("/branches", getBranchHeads)
+		/* レポート（/api/reports）のテンプレート（REPORT_TEMPLATE_DIRのファイルも一覧に含める） */
+		admin.GET("/report-templates", getReportTemplates)
+		admin.GET("/report-templates/:name", getReportTemplate)
+		admin.PUT("/report-templates/:name", putReportTemplate)
+		admin.DELETE("/report-templates/:name", deleteReportTemplate)
+	}
+
+	/*
+		どのルートにも一致しないリクエスト（404）と、許可されていないメソッド（405）
+		ブラウザにはエラーページ（error.html）、/api/* やJSONを求めるクライアントには共通形式のJSONを返す
+		HandleMethodNotAllowedを有効にしない場合、メソッド違いも404として扱われる
+	*/
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(notFoundHandler)
+	r.NoMethod(methodNotAllowedHandler)
+	return r
 }
 
 /*
@@ -216,32 +735,58 @@ getGitHistory はGit履歴を取得するAPIハンドラー
 1. fetchRepositories()でユーザーの全公開リポジトリを取得
 2. 各リポジトリのコミット履歴をfetchCommits()で取得
 3. 全コミットを統合してJSON形式で返却
+4. ARCHIVE_HOT_MONTHSの設定時は、それより古いコミットをアーカイブに移してレスポンスから除外
 
 引数:
   c *gin.Context - Ginのコンテキスト。リクエスト・レスポンス情報を含む
 
+クエリパラメータ:
+  include_archive - "true" の場合、アーカイブ済みのコミットも含めて返す
+  refresh - "true" の場合、キャッシュをETagで再検証し、取得のジョブを定期ジョブより優先して実行する
+  repo - リポジトリ名で絞り込む
+  page, per_page - ページ番号と1ページあたりの件数（デフォルト: 30件）
+  cursor - 前のページのnext_cursor（指定した場合、絞り込みとper_pageはcursorに含まれる値を使用する）
+
 レスポンス:
   成功時: 200 OK, []CommitHistory（全コミット履歴のJSON配列）
-  失敗時: 500 Internal Server Error, {"error": "エラーメッセージ"}
+         page・per_page・cursorを指定した場合は HistoryPage（新しい順の1ページ分と次のページのcursor）
+         配列がMAX_RESPONSE_ITEMS・MAX_RESPONSE_BYTESを超える場合も HistoryPage（1ページ目とwarning）
+         次のページがある場合は Link ヘッダー（rel="next"）に次のページのURLを設定する
+  失敗時: 422 Unprocessable Entity（page・per_page・cursorが不正）, 500 Internal Server Error, {"error": "エラーメッセージ"}
 */
 func getGitHistory(c *gin.Context) {
+	query, ok := bindHistoryQuery(c)
+	if !ok {
+		return
+	}
+
 	log.Info().Msg("Fetching git history")
+	syncStart := time.Now()
+	priority := requestPriority(c)
+	if priority == jobPriorityInteractive {
+		/* 有効期間内のキャッシュも再検証する（変更がなければ304でレート制限の回数を消費しない） */
+		expireGitHubCache()
+	}
 
 	/*
 		fetchRepositories()を呼び出し、対象ユーザーの全公開リポジトリを取得
 		戻り値: repos（リポジトリのスライス）, err（エラー）
 	*/
-	repos, err := fetchRepositories()
+	repos, err := fetchRepositoriesAt(priority)
 	if err != nil {
 		/*
 			エラーが発生した場合、500エラーとエラーメッセージをJSON形式で返す
-			gin.Hは map[string]interface{} のエイリアスで、JSON生成に使用
+			respondErrorは共通形式（ErrorResponse）の {"error", "code", "request_id"} を返す
 		*/
 		log.Error().Err(err).Msg("Failed to fetch repositories")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		notify(notificationKindSyncFailure, "", "リポジトリ一覧の取得に失敗しました", err.Error())
+		respondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	/* PRIVACY_MODE=anonymous の場合、匿名の閲覧者にはプライベートリポジトリのコミットを取得・集計しない */
+	visible := requestVisibility(c)
+	allRepos, repos := repos, visible.repositories(repos)
 	log.Info().Int("count", len(repos)).Msg("Repositories fetched successfully")
 
 	/*
@@ -249,14 +794,17 @@ func getGitHistory(c *gin.Context) {
 		初期容量は指定せず、append()で動的に拡張
 	*/
 	var allCommits []CommitHistory
+	/* failedReposはコミット取得に失敗したリポジトリ名（sync_failure通知に使用） */
+	var failedRepos []string
 
 	/*
-		各リポジトリをイテレートしてコミット履歴を取得
-		range repos は (index, value) を返すが、indexは使用しないので _ で無視
+		各リポジトリのコミット履歴をワーカーで並行して取得
+		結果はreposと同じ順序で返るため、レスポンスの並びは逐次取得の場合と変わらない
 	*/
-	for _, repo := range repos {
-		/* repo.FullName（例: "develop-suda/project-name"）を使用してコミットを取得 */
-		commits, err := fetchCommits(repo.FullName)
+	results := fetchCommitsConcurrently(repos, priority)
+
+	for i, repo := range repos {
+		commits, err := results[i].Commits, results[i].Err
 		if err != nil {
 			/*
 				個別リポジトリのエラーは全体の処理を停止せず、ログ出力のみ
@@ -267,6 +815,7 @@ func getGitHistory(c *gin.Context) {
 				Str("repository", repo.Name).
 				Str("full_name", repo.FullName).
 				Msg("Failed to fetch commits for repository")
+			failedRepos = append(failedRepos, repo.Name)
 			continue /* 次のリポジトリの処理に進む */
 		}
 
@@ -277,13 +826,7 @@ func getGitHistory(c *gin.Context) {
 
 		/* 取得したコミットをCommitHistory形式に変換してスライスに追加 */
 		for _, commit := range commits {
-			allCommits = append(allCommits, CommitHistory{
-				RepositoryName: repo.Name,             // リポジトリ名
-				CommitMessage:  commit.Commit.Message, // コミットメッセージ
-				CommitSHA:      commit.SHA[:7],        // コミットハッシュを7文字に短縮（Gitの慣習）
-				CommitTime:     commit.Commit.Author.Date, // コミット作成日時
-				CommitURL:      commit.HTMLURL,        // GitHubのコミットページURL
-			})
+			allCommits = append(allCommits, newCommitHistory(repo.Name, commit))
 		}
 	}
 
@@ -291,12 +834,125 @@ func getGitHistory(c *gin.Context) {
 		全コミット履歴をJSON形式でレスポンスとして返す
 		Ginが自動的にContent-Type: application/jsonヘッダーを設定
 	*/
+	/*
+		取得結果から新着コミット・取得失敗・目標未達成の通知の作成と、トップページのヘッダーに表示する集計の更新を
+		compute-statsジョブとして実行する（レスポンスは集計の完了を待たずに返す）
+	*/
+	synced, repoCount := allCommits, len(repos)
+	jobs.submit(priority, jobKindComputeStats, "", func() error {
+		evaluateHistoryNotifications(synced, failedRepos)
+		evaluateCommitSLOs(synced, allRepos, failedRepos, time.Now())
+		recordSyncSummary(repoCount, synced, len(failedRepos))
+		commitSearch.add(synced)
+		statsViews.sync(synced, allRepos)
+		eventBroker.publishCommits(backgroundVisibility().commits(synced, allRepos))
+		if len(synced) > 0 {
+			commitUpdates.notify()
+		}
+		return nil
+	})
+	/* GitHubはフォロワー数の履歴を提供しないため、同期のたびにスナップショットを記録する */
+	jobs.submit(jobPriorityBackground, jobKindFetchFollowers, username, recordFollowers)
+
+	/* 古いコミットはアーカイブに移し、普段のレスポンスを小さく保つ */
+	allCommits = archiveColdCommits(allCommits)
+	if query.IncludeArchive {
+		archived, err := loadArchivedCommits()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load archived commits")
+			respondError(c, http.StatusInternalServerError, "failed to load archived commits")
+			return
+		}
+		/* アーカイブにはプライベートリポジトリのコミットも含まれるため、同じ範囲で絞り込む */
+		allCommits = mergeArchivedCommits(allCommits, visible.commits(archived, allRepos))
+	}
+
+	allCommits = filterCommitsByRepo(allCommits, query.Repo)
+	/* ラベルはマスク前のメッセージで照合する */
+	allCommits = filterCommitsByLabel(labelCommits(allCommits), query.Label)
+	/* アーカイブ・通知には元のメッセージを残し、レスポンスに返す段階でREDACTION_RULESを適用する */
+	allCommits = requestRedaction(c).commits(allCommits)
+
+	syncDuration.ObserveSince(syncStart)
 	log.Info().Int("total_commits", len(allCommits)).Msg("Returning git history")
-	c.JSON(http.StatusOK, allCommits)
+	warning := ""
+	if !query.Paginated {
+		/* そのまま返すとMAX_RESPONSE_ITEMS・MAX_RESPONSE_BYTESを超える場合は、1ページ目とLinkヘッダーを返す */
+		perPage, exceeded := responseLimit(allCommits, len(allCommits))
+		if !exceeded {
+			respondJSON(c, http.StatusOK, allCommits)
+			return
+		}
+		log.Warn().Int("total_commits", len(allCommits)).Int("per_page", perPage).Msg("Git history exceeds response size limit, paginating")
+		query.Paginated, query.Page, query.PerPage = true, 1, perPage
+		warning = oversizedResponseWarning
+	}
+	page := paginateCommits(allCommits, query)
+	page.Warning = warning
+	if query.After == nil && warning == "" {
+		setPageLinks(c, query.Page, query.PerPage, page.Total)
+	} else {
+		/*
+			cursorの場合はページ番号がないため、次のページのcursorのみ返す
+			上限を超えてページネーションに切り替えた場合も、per_pageがMAX_PER_PAGEを超えうるためcursorで返す
+		*/
+		c.Header(totalCountHeader, strconv.Itoa(page.Total))
+		if page.NextCursor != "" {
+			setNextLink(c, url.Values{"cursor": {page.NextCursor}}, "page", "per_page")
+		}
+	}
+	respondJSON(c, http.StatusOK, page)
+}
+
+/*
+newCommitHistory はGitHub APIのコミットをフロントエンドに返すCommitHistory形式に変換する
+
+引数:
+  repoName string - リポジトリ名（例: "my-project"）
+  commit Commit - GitHub APIから取得したコミット
+*/
+func newCommitHistory(repoName string, commit Commit) CommitHistory {
+	return CommitHistory{
+		RepositoryName: repoName,                  // リポジトリ名
+		CommitMessage:  commit.Commit.Message,     // コミットメッセージ
+		CommitSHA:      commit.SHA[:7],            // コミットハッシュを7文字に短縮（Gitの慣習）
+		CommitTime:     commit.Commit.Author.Date, // コミット作成日時
+		CommitURL:      commit.HTMLURL,            // GitHubのコミットページURL
+		AuthorName:     commit.Commit.Author.Name, // 作成者名
+		/* メールアドレスはキャッシュ・アーカイブにも元の値を残さないよう、作成時に変換する */
+		AuthorEmail: privateEmail(commit.Commit.Author.Email),
+		Parents:     parentSHAs(commit),
+	}
+}
+
+/* fetchRepositories はリポジトリ一覧を通常の優先度で取得する（fetchRepositoriesAtを参照） */
+func fetchRepositories() ([]Repository, error) {
+	return fetchRepositoriesAt(jobPriorityNormal)
+}
+
+/*
+fetchRepositoriesAt はリポジトリ一覧をfetch-repo-listジョブとしてキューで取得し、完了を待機する
+失敗した場合はジョブキューが待機時間をおいて再試行する（JOB_MAX_ATTEMPTS）
+
+引数:
+  priority jobPriority - ジョブを実行するレーン
+
+戻り値:
+  []Repository - 取得したリポジトリ情報のスライス（最大100件）
+  error - 再試行を使い切っても取得できなかった場合のエラー
+*/
+func fetchRepositoriesAt(priority jobPriority) ([]Repository, error) {
+	var repos []Repository
+	err := runJob(priority, jobKindFetchRepoList, username, func() error {
+		var err error
+		repos, err = requestRepositories()
+		return err
+	})
+	return repos, err
 }
 
 /*
-fetchRepositories はGitHub APIから指定ユーザーの公開リポジトリ一覧を取得する
+requestRepositories はGitHub APIから指定ユーザーの公開リポジトリ一覧を取得する
 GitHub REST API v3のリポジトリ一覧取得エンドポイントを使用
 API仕様: https://docs.github.com/ja/rest/repos/repos#list-repositories-for-a-user
 
@@ -305,10 +961,10 @@ API仕様: https://docs.github.com/ja/rest/repos/repos#list-repositories-for-a-u
   error - エラーが発生した場合のエラーオブジェクト、正常時はnil
 
 注意:
-  - GitHub APIは認証なしで60リクエスト/時間の制限あり
+  - GitHub APIは認証なしで60リクエスト/時間、GITHUB_TOKENを設定した場合は5,000リクエスト/時間の制限あり
   - per_page=100で最大100件を取得（デフォルトは30件）
 */
-func fetchRepositories() ([]Repository, error) {
+func requestRepositories() ([]Repository, error) {
 	/*
 		GitHub API URLを構築
 		クエリパラメータ:
@@ -323,65 +979,18 @@ func fetchRepositories() ([]Repository, error) {
 		Msg("Fetching repositories from GitHub API")
 
 	/*
-		HTTPリクエストを作成
-		第一引数: HTTPメソッド（GET）
-		第二引数: リクエストURL
-		第三引数: リクエストボディ（GETなのでnil）
-	*/
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		/* リクエスト作成に失敗した場合（通常は発生しない） */
-		return nil, err
-	}
-
-	/*
-		GitHub API v3用のAcceptヘッダーを設定
-		これによりAPI v3のレスポンス形式が保証される
+		GitHub APIを呼び出し、レスポンスをRepository構造体のスライスにデコード
+		fetchGitHubJSONがキャッシュ・ETagによる条件付きリクエスト・レート制限の管理と、
+		200 OK以外のステータスやJSONパースエラーの処理を行う
 	*/
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	/*
-		HTTPクライアントを作成
-		Timeout: 10秒でタイムアウト（長時間のリクエストを防ぐ）
-	*/
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	/* HTTPリクエストを実行 */
-	resp, err := client.Do(req)
-	if err != nil {
-		/* ネットワークエラーやタイムアウトの場合 */
-		return nil, err
-	}
-	/*
-		deferでレスポンスボディを確実にクローズ
-		これによりリソースリークを防ぐ
-	*/
-	defer resp.Body.Close()
-
-	/* HTTPステータスコードが200 OK以外の場合はエラー */
-	if resp.StatusCode != http.StatusOK {
-		/* エラー詳細をレスポンスボディから読み取る */
-		body, _ := io.ReadAll(resp.Body)
-		/* ステータスコードとボディを含むエラーメッセージを返す */
-		log.Error().
-			Int("status_code", resp.StatusCode).
-			Str("status", resp.Status).
-			Str("response_body", string(body)).
-			Msg("GitHub API returned non-OK status")
-		return nil, fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(body))
-	}
-
-	/* レスポンスボディをRepository構造体のスライスにデコード */
 	var repos []Repository
-	/*
-		json.NewDecoder()はストリーミング処理を行うため、大きなレスポンスでもメモリ効率が良い
-	*/
-	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
-		/* JSONパースエラー（APIレスポンス形式が期待と異なる場合） */
-		log.Error().Err(err).Msg("Failed to decode repositories JSON response")
+	if err := fetchGitHubJSON(upstreamOpRepositories, url, githubAcceptV3, &repos); err != nil {
 		return nil, err
 	}
 
+	/* 管理者APIで追加・除外したリポジトリを反映する（trackedrepos.go） */
+	repos = applyRepositoryTracking(repos)
+
 	/* 取得したリポジトリ一覧を返す */
 	log.Info().Int("repository_count", len(repos)).Msg("Successfully fetched repositories")
 	return repos, nil
@@ -403,7 +1012,7 @@ API仕様: https://docs.github.com/ja/rest/commits/commits#list-commits
 注意:
   - デフォルトブランチのコミットのみ取得される
   - per_page=100で最大100件を取得（APIの最大値）
-  - GitHub APIは認証なしで60リクエスト/時間の制限あり
+  - GitHub APIは認証なしで60リクエスト/時間、GITHUB_TOKENを設定した場合は5,000リクエスト/時間の制限あり
 */
 func fetchCommits(repoFullName string) ([]Commit, error) {
 	/*
@@ -420,73 +1029,19 @@ func fetchCommits(repoFullName string) ([]Commit, error) {
 		Msg("Fetching commits from GitHub API")
 
 	/*
-		HTTPリクエストを作成
-		第一引数: HTTPメソッド（GET）
-		第二引数: リクエストURL
-		第三引数: リクエストボディ（GETなのでnil）
-	*/
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		/* リクエスト作成に失敗した場合（通常は発生しない） */
-		return nil, err
-	}
-
-	/*
-		GitHub API v3用のAcceptヘッダーを設定
-		これによりAPI v3のレスポンス形式が保証される
-	*/
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	/*
-		HTTPクライアントを作成
-		Timeout: 10秒でタイムアウト（長時間のリクエストを防ぐ）
-	*/
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	/* HTTPリクエストを実行 */
-	resp, err := client.Do(req)
-	if err != nil {
-		/* ネットワークエラーやタイムアウトの場合 */
-		return nil, err
-	}
-	/*
-		deferでレスポンスボディを確実にクローズ
-		これによりリソースリークを防ぐ
-	*/
-	defer resp.Body.Close()
-
-	/*
-		HTTPステータスコードが200 OK以外の場合はエラー
+		GitHub APIを呼び出し、レスポンスをCommit構造体のスライスにデコード
 		404 Not Foundの場合はリポジトリが存在しないか、アクセス権限がない
 		403 Forbiddenの場合はAPIレート制限に到達した可能性がある
+		（いずれもfetchGitHubJSONがエラーとして返す）
 	*/
-	if resp.StatusCode != http.StatusOK {
-		/* エラー詳細をレスポンスボディから読み取る */
-		body, _ := io.ReadAll(resp.Body)
-		/* ステータスコードとボディを含むエラーメッセージを返す */
-		log.Error().
-			Int("status_code", resp.StatusCode).
-			Str("status", resp.Status).
-			Str("repository", repoFullName).
-			Str("response_body", string(body)).
-			Msg("GitHub API returned non-OK status for commits")
-		return nil, fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(body))
-	}
-
-	/* レスポンスボディをCommit構造体のスライスにデコード */
 	var commits []Commit
-	/*
-		json.NewDecoder()はストリーミング処理を行うため、大きなレスポンスでもメモリ効率が良い
-	*/
-	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
-		/* JSONパースエラー（APIレスポンス形式が期待と異なる場合） */
-		log.Error().
-			Err(err).
-			Str("repository", repoFullName).
-			Msg("Failed to decode commits JSON response")
+	if err := fetchGitHubJSON(upstreamOpCommits, url, githubAcceptV3, &commits); err != nil {
 		return nil, err
 	}
 
+	/* 再起動後も条件付きリクエストから再開できるよう、最新のコミットとETagを記録する */
+	syncCursors.record(repoFullName, url, commits)
+
 	/* 取得したコミット一覧を返す（新しい順にソート済み） */
 	log.Debug().
 		Str("repository", repoFullName).
@@ -494,3 +1049,54 @@ func fetchCommits(repoFullName string) ([]Commit, error) {
 		Msg("Successfully fetched commits")
 	return commits, nil
 }
+
+/*
+syncWorkers はジョブキュー（jobs.go）でコミット履歴などを並行して取得するワーカー数
+環境変数 GITHUB_SYNC_WORKERS で変更可能（デフォルト: 4）
+*/
+var syncWorkers = getEnvInt("GITHUB_SYNC_WORKERS", 4)
+
+/* syncDuration は /api/git-history の全リポジトリの取得にかかった時間 */
+var syncDuration = newHistogramVec(
+	"giter_sync_duration_seconds",
+	"Time to fetch commit history for all repositories in /api/git-history.",
+	defaultDurationBuckets,
+)
+
+/* commitFetchResult は1リポジトリ分のコミット取得結果 */
+type commitFetchResult struct {
+	Commits []Commit // 取得したコミット
+	Err     error    // 取得に失敗した場合のエラー
+}
+
+/*
+fetchCommitsConcurrently は複数リポジトリのコミット履歴をfetch-repo-commitsジョブとしてキューに追加し、すべての完了を待機する
+ジョブはsyncWorkers個のワーカーで並行して実行され、同じHTTPクライアント（upstreamClient）を共有するため、GitHubへの接続は再利用される
+
+引数:
+  repos []Repository - 取得対象のリポジトリ
+  priority jobPriority - ジョブを実行するレーン
+
+戻り値:
+  []commitFetchResult - reposと同じ順序の取得結果
+*/
+func fetchCommitsConcurrently(repos []Repository, priority jobPriority) []commitFetchResult {
+	results := make([]commitFetchResult, len(repos))
+	submitted := make([]*job, len(repos))
+	for i, repo := range repos {
+		i, repo := i, repo
+		/* repo.FullName（例: "develop-suda/project-name"）を使用してコミットを取得 */
+		submitted[i] = jobs.submit(priority, jobKindFetchRepoCommits, repo.FullName, func() error {
+			commits, err := fetchCommits(repo.FullName)
+			results[i].Commits = commits
+			if err == nil {
+				branchHeads.observe(repo, commits)
+			}
+			return err
+		})
+	}
+	for i, err := range waitJobs(submitted) {
+		results[i].Err = err
+	}
+	return results
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* maintenanceTable はメンテナンスモードの状態を保存するテーブル名 */
+	maintenanceTable = "maintenance"
+)
+
+/*
+maintenanceExemptPathPrefixes はメンテナンス中も通常どおり処理するパスの接頭辞
+管理者API（メンテナンスモードの解除を含む）と、監視・静的ファイルは止めない
+*/
+var maintenanceExemptPathPrefixes = []string{"/api/admin/", "/healthz", "/metrics", "/static/"}
+
+/*
+MaintenanceState はメンテナンスモードの状態
+データの移行中などに、閲覧者へのページ・APIの提供とバックグラウンドの同期を止める
+*/
+type MaintenanceState struct {
+	Enabled bool      `json:"enabled"` // メンテナンス中かどうか
+	Message string    `json:"message"` // メンテナンス中のページ・エラーレスポンスに表示するメッセージ
+	Since   time.Time `json:"since"`   // メンテナンスを開始した日時
+	Until   time.Time `json:"until"`   // 終了予定の日時（ゼロ値の場合は未定、Retry-Afterヘッダーに使用する）
+}
+
+/*
+maintenanceStore はメンテナンスモードの状態を保持するストア
+maintenanceテーブルに永続化され、再起動後もメンテナンス中のまま起動する
+*/
+type maintenanceStore struct {
+	mu sync.Mutex
+	MaintenanceState
+}
+
+/* maintenance はアプリケーション全体で共有するメンテナンスモードの状態 */
+var maintenance = &maintenanceStore{}
+
+func init() {
+	registerTable(maintenanceTable, loadMaintenance)
+}
+
+/*
+loadMaintenance はmaintenanceテーブルからメンテナンスモードの状態を復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadMaintenance() error {
+	maintenance.mu.Lock()
+	defer maintenance.mu.Unlock()
+
+	maintenance.MaintenanceState = MaintenanceState{}
+	if err := loadTable(maintenanceTable, maintenance); err != nil {
+		return err
+	}
+	if maintenance.Enabled {
+		log.Warn().Time("since", maintenance.Since).Msg("Starting in maintenance mode")
+	}
+	return nil
+}
+
+/* state はメンテナンスモードの状態を返す */
+func (s *maintenanceStore) state() MaintenanceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.MaintenanceState
+}
+
+/* set はメンテナンスモードの状態を変更して保存する */
+func (s *maintenanceStore) set(state MaintenanceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MaintenanceState = state
+	if err := saveTable(maintenanceTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save maintenance state")
+	}
+}
+
+/* maintenanceActive はメンテナンス中かどうかを返す */
+func maintenanceActive() bool {
+	return maintenance.state().Enabled
+}
+
+/*
+pausedForMaintenance はメンテナンス中の場合に定期ジョブの実行を見送る（定期実行のゴルーチン用）
+
+引数:
+  job string - 定期ジョブの名前（ログ出力用）
+
+戻り値:
+  bool - メンテナンス中の場合はtrue（今回の実行を見送る）
+*/
+func pausedForMaintenance(job string) bool {
+	if !maintenanceActive() {
+		return false
+	}
+	log.Info().Str("job", job).Msg("Scheduled job skipped during maintenance")
+	return true
+}
+
+/*
+maintenanceMiddleware はメンテナンス中にリクエストを503 Service Unavailableで拒否するミドルウェア
+ページはメンテナンス中のページ（maintenance.html）、APIは共通形式のエラーレスポンスを返す
+終了予定の日時を設定している場合は、Retry-Afterヘッダーに残りの秒数を返す
+
+注意:
+  - 管理者のトークン付きのリクエストは通常どおり処理する（移行後の確認のため）
+  - maintenanceExemptPathPrefixesのパスは対象外
+  - i18nMiddlewareの後に登録し、リクエストの言語で表示する
+*/
+func maintenanceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := maintenance.state()
+		if !state.Enabled || isAdminRequest(c) || maintenanceExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		if !state.Until.IsZero() {
+			if wait := time.Until(state.Until); wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		c.Abort()
+		if wantsJSONError(c) {
+			message := "service is under maintenance"
+			if state.Message != "" {
+				message = state.Message
+			}
+			respondError(c, http.StatusServiceUnavailable, message)
+			return
+		}
+		c.HTML(http.StatusServiceUnavailable, "maintenance.html", web.NewMaintenancePage(siteInfo(), webRequest(c), state.Message, state.Since, state.Until))
+	}
+}
+
+/* maintenanceExempt はメンテナンス中も処理するパスかどうかを返す */
+func maintenanceExempt(path string) bool {
+	for _, prefix := range maintenanceExemptPathPrefixes {
+		if strings.HasPrefix(path, appPath(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+getMaintenance はメンテナンスモードの状態を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, MaintenanceState
+*/
+func getMaintenance(c *gin.Context) {
+	respondJSON(c, http.StatusOK, maintenance.state())
+}
+
+/*
+postMaintenance はメンテナンスモードを有効/無効にする管理者APIハンドラー
+有効にすると、閲覧者へのページ・APIは503を返し、バックグラウンドのジョブ（バックフィルなど）と
+定期的なスナップショット・異常検知・保持期間の適用を止める。無効にすると止めていたジョブを再開する
+
+リクエストボディ:
+  {"enabled": true, "message": "データベースの移行中です", "until": "2025-08-01T12:00:00Z"}
+  message, until は省略可能（enabled=false の場合は無視する）
+
+レスポンス:
+  成功時: 200 OK, MaintenanceState
+  失敗時: 400 Bad Request（不正なJSON）, 422 Unprocessable Entity（enabled未指定、messageが長すぎる）
+
+注意:
+  - 状態はストアに保存し、再起動後も引き継ぐ（他のレプリカには再起動またはデータのインポート時に反映される）
+  - 変更は監査ログに記録する
+*/
+func postMaintenance(c *gin.Context) {
+	var req struct {
+		Enabled *bool     `json:"enabled" binding:"required"`
+		Message string    `json:"message" binding:"max=500"`
+		Until   time.Time `json:"until"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	state := MaintenanceState{}
+	action := "maintenance.disable"
+	if *req.Enabled {
+		state = MaintenanceState{Enabled: true, Message: req.Message, Since: time.Now().UTC(), Until: req.Until.UTC()}
+		/* 有効なまま更新した場合（メッセージの変更など）は開始日時を引き継ぐ */
+		if current := maintenance.state(); current.Enabled {
+			state.Since = current.Since
+		}
+		action = "maintenance.enable"
+	}
+	maintenance.set(state)
+	if !state.Enabled {
+		/* メンテナンス中に待機していたバックグラウンドのジョブを再開する */
+		jobs.resume()
+	}
+
+	auditLog.record(c, action, "", nil)
+	log.Warn().Bool("enabled", state.Enabled).Str("message", state.Message).Msg("Maintenance mode changed")
+	respondJSON(c, http.StatusOK, state)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+メトリクス
+外部ライブラリを使わずに、Prometheusのテキスト形式（text/plain; version=0.0.4）で
+/metrics から公開するための最小限のカウンターとヒストグラムを実装する
+*/
+
+/* metricCollector は /metrics に出力できるメトリクス */
+type metricCollector interface {
+	writeTo(w io.Writer)
+}
+
+/* metricsRegistry は登録済みのメトリクス（登録順に出力する） */
+var metricsRegistry = struct {
+	mu         sync.Mutex
+	collectors []metricCollector
+}{}
+
+/* registerMetric はメトリクスを /metrics の出力対象に登録する */
+func registerMetric(m metricCollector) {
+	metricsRegistry.mu.Lock()
+	defer metricsRegistry.mu.Unlock()
+	metricsRegistry.collectors = append(metricsRegistry.collectors, m)
+}
+
+/*
+CounterVec はラベルの組み合わせごとに値を持つ、増加のみのカウンター
+*/
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+/*
+newCounterVec はカウンターを作成して登録する
+
+引数:
+  name string - メトリクス名（例: "giter_github_connections_total"）
+  help string - # HELP に出力する説明
+  labels ...string - ラベル名
+*/
+func newCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+	registerMetric(c)
+	return c
+}
+
+/* Inc は指定したラベル値のカウンターを1増やす（ラベル値はラベル名と同じ順で指定） */
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+/* Add は指定したラベル値のカウンターをv増やす */
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := metricKey(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, ""), formatMetricValue(c.values[key]))
+	}
+}
+
+/*
+GaugeVec はラベルの組み合わせごとに任意の値を持つゲージ
+CounterVec・HistogramVecと異なり /metrics には登録しない
+/metrics/activity のように、リクエストごとに最新のデータから値を作り直すメトリクスに使用する
+*/
+type GaugeVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+/*
+newGaugeVec はゲージを作成する（/metrics には登録しない）
+
+引数:
+  name string - メトリクス名（例: "giter_last_commit_timestamp"）
+  help string - # HELP に出力する説明
+  labels ...string - ラベル名
+*/
+func newGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+}
+
+/* Set は指定したラベル値のゲージに値を設定する（ラベル値はラベル名と同じ順で指定） */
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	key := metricKey(labelValues)
+	g.mu.Lock()
+	g.values[key] = v
+	g.mu.Unlock()
+}
+
+func (g *GaugeVec) writeTo(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, key, ""), formatMetricValue(g.values[key]))
+	}
+}
+
+/* defaultDurationBuckets はレイテンシ計測用のヒストグラムの区切り（秒） */
+var defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+/*
+HistogramVec はラベルの組み合わせごとに値の分布を記録するヒストグラム
+*/
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+/* histogramSeries は1つのラベルの組み合わせの集計値 */
+type histogramSeries struct {
+	counts []uint64 // 各バケット以下の観測数（累積ではない）
+	count  uint64   // 観測数の合計
+	sum    float64  // 観測値の合計
+}
+
+/*
+newHistogramVec はヒストグラムを作成して登録する
+
+引数:
+  name string - メトリクス名（例: "giter_github_request_duration_seconds"）
+  help string - # HELP に出力する説明
+  buckets []float64 - バケットの上限値（昇順）
+  labels ...string - ラベル名
+*/
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+	registerMetric(h)
+	return h
+}
+
+/* Observe は指定したラベル値のヒストグラムに値を記録する */
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := metricKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+/* ObserveSince は開始時刻からの経過秒数を記録する */
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			le := `le="` + formatMetricValue(upper) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatMetricValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), s.count)
+	}
+}
+
+/* metricKey はラベル値をmapのキーにまとめる（ラベル値に現れない区切り文字を使用） */
+func metricKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+/* sortedKeys は出力を安定させるためにキーをソートして返す */
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+formatLabels はラベル名とmetricKeyでまとめたラベル値から {name="value",...} を作成する
+
+引数:
+  names []string - ラベル名
+  key string - metricKeyで作成したキー
+  extra string - 末尾に追加するラベル（ヒストグラムのle用、不要なら空文字）
+*/
+func formatLabels(names []string, key, extra string) string {
+	var parts []string
+	if len(names) > 0 {
+		values := strings.Split(key, "\xff")
+		for i, name := range names {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			parts = append(parts, name+`="`+escapeLabelValue(value)+`"`)
+		}
+	}
+	if extra != "" {
+		parts = append(parts, extra)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+/* escapeLabelValue はPrometheusのテキスト形式に合わせてラベル値をエスケープする */
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+/* formatMetricValue は値をPrometheusのテキスト形式で出力する文字列に変換する */
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+/*
+getMetrics は登録済みのメトリクスをPrometheusのテキスト形式で返すハンドラー
+
+レスポンス:
+  200 OK, text/plain; version=0.0.4
+*/
+func getMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	metricsRegistry.mu.Lock()
+	collectors := append([]metricCollector(nil), metricsRegistry.collectors...)
+	metricsRegistry.mu.Unlock()
+
+	for _, m := range collectors {
+		m.writeTo(c.Writer)
+	}
+}
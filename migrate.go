@@ -0,0 +1,303 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+migrationFiles はSQLのHistoryStoreのスキーマのマイグレーション（migrations/*.sql）
+ファイル名は "<バージョン>_<名前>.up.sql" と、戻すための "<バージョン>_<名前>.down.sql" の組
+例: 0002_index_archived_commits_month.up.sql
+*/
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+/*
+schemaMigrationsSchema は適用済みのマイグレーションを記録するテーブルの定義（SQLite・PostgreSQL共通）
+*/
+const schemaMigrationsSchema = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+)`
+
+/* Migration は1つのスキーマのマイグレーション */
+type Migration struct {
+	Version int    // バージョン（ファイル名の先頭の数字、1始まりで昇順に適用する）
+	Name    string // 名前（ファイル名のバージョンと拡張子を除いた部分）
+	Up      string // 適用するSQL
+	Down    string // 戻すSQL
+}
+
+/*
+migrator はスキーマのマイグレーションに対応したHistoryStore（SQLの実装のみ）
+ファイル・メモリの実装はスキーマがないため対象外
+*/
+type migrator interface {
+	/* Migrate は未適用のマイグレーションをバージョン順に適用し、適用したマイグレーションを返す */
+	Migrate() ([]Migration, error)
+	/* Rollback はtargetより新しいバージョンのマイグレーションを新しい順に戻し、戻したマイグレーションを返す */
+	Rollback(target int) ([]Migration, error)
+	/* SchemaVersion は適用済みの最新のバージョンを返す（未適用の場合は0） */
+	SchemaVersion() (int, error)
+}
+
+/*
+loadMigrations は埋め込んだマイグレーションをバージョン順に読み込む
+
+戻り値:
+  error - ファイル名の形式が不正、バージョンの重複、upまたはdownのSQLがない場合のエラー
+*/
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		base, direction := strings.TrimSuffix(file, ".sql"), ""
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, direction = strings.TrimSuffix(base, ".up"), "up"
+		case strings.HasSuffix(base, ".down"):
+			base, direction = strings.TrimSuffix(base, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("invalid migration file name %q (expected <version>_<name>.up.sql or .down.sql)", file)
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 || name == "" {
+			return nil, fmt.Errorf("invalid migration file name %q (expected <version>_<name>.up.sql or .down.sql)", file)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("duplicate migration version %d (%s, %s)", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+/* SchemaVersion は schema_migrations に記録した最新のバージョンを返す */
+func (s *sqlHistoryStore) SchemaVersion() (int, error) {
+	if _, err := s.db.Exec(schemaMigrationsSchema); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	var version sql.NullInt64
+	if err := s.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+/*
+Migrate は未適用のマイグレーションを1つずつトランザクションで適用する
+途中で失敗した場合はそのマイグレーションのみ取り消し、それまでに適用したものは残す
+
+注意:
+  - データベースのバージョンがこのビルドのマイグレーションより新しい場合（新しいバージョンから戻した場合など）は警告のみ出力する
+*/
+func (s *sqlHistoryStore) Migrate() ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if latest := migrations[len(migrations)-1].Version; current > latest {
+		log.Warn().Int("schema_version", current).Int("latest_migration", latest).Msg("Database schema is newer than this build")
+	}
+
+	applied := []Migration{}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := s.execMigration(m, m.Up, true); err != nil {
+			return applied, err
+		}
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("Migration applied")
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+/*
+Rollback はtargetより新しいバージョンのマイグレーションを新しい順に1つずつトランザクションで戻す
+
+引数:
+  target int - 戻した後のバージョン（0の場合はすべて戻す）
+*/
+func (s *sqlHistoryStore) Rollback(target int) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	rolledBack := []Migration{}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		if err := s.execMigration(m, m.Down, false); err != nil {
+			return rolledBack, err
+		}
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("Migration rolled back")
+		rolledBack = append(rolledBack, m)
+	}
+	return rolledBack, nil
+}
+
+/*
+execMigration はマイグレーションのSQLと schema_migrations の更新を1つのトランザクションで実行する
+
+引数:
+  m Migration - 対象のマイグレーション
+  query string - 実行するSQL（m.Up または m.Down）
+  up bool - trueの場合はバージョンを記録し、falseの場合は記録を削除する
+*/
+func (s *sqlHistoryStore) execMigration(m Migration, query string, up bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+	}
+	p := s.dialect.placeholder
+	if up {
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)", p(1), p(2), p(3)),
+			m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	} else {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %s", p(1)), m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+/*
+migrateHistoryStore は起動時にHistoryStoreの未適用のマイグレーションを適用する
+SQL以外の実装（file, memory）の場合は何もしない
+*/
+func migrateHistoryStore() error {
+	store, ok := history.(migrator)
+	if !ok {
+		return nil
+	}
+	_, err := store.Migrate()
+	return err
+}
+
+/*
+runMigrateCommand はマイグレーションのみを適用して終了する（--migrate-only）
+デプロイの前にスキーマを更新しておく場合に使用する
+*/
+func runMigrateCommand() int {
+	store, ok := history.(migrator)
+	if !ok {
+		fmt.Println("history store has no schema to migrate (HISTORY_STORE=sqlite or postgres only)")
+		return 0
+	}
+	applied, err := store.Migrate()
+	for _, m := range applied {
+		fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return printSchemaVersion(store)
+}
+
+/*
+runRollbackCommand はマイグレーションを戻して終了する（--rollback [version]）
+versionを省略した場合は最新の1つのみ戻す
+*/
+func runRollbackCommand(args []string) int {
+	store, ok := history.(migrator)
+	if !ok {
+		fmt.Println("history store has no schema to roll back (HISTORY_STORE=sqlite or postgres only)")
+		return 0
+	}
+	current, err := store.SchemaVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	target := current - 1
+	if len(args) > 0 {
+		if target, err = strconv.Atoi(args[0]); err != nil || target < 0 {
+			fmt.Fprintln(os.Stderr, "usage: giter --rollback [version]")
+			return 2
+		}
+	}
+	rolledBack, err := store.Rollback(target)
+	for _, m := range rolledBack {
+		fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return printSchemaVersion(store)
+}
+
+/* printSchemaVersion は現在のスキーマのバージョンを表示する */
+func printSchemaVersion(store migrator) int {
+	version, err := store.SchemaVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("schema version: %d\n", version)
+	return 0
+}
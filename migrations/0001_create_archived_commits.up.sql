@@ -0,0 +1,9 @@
+-- アーカイブ（HistoryStore）のコミット。CommitHistoryのJSONをdataに保存する
+-- マイグレーション導入前に作成したデータベースにも適用できるよう IF NOT EXISTS を付ける
+CREATE TABLE IF NOT EXISTS archived_commits (
+	repository_name TEXT NOT NULL,
+	commit_sha TEXT NOT NULL,
+	month TEXT NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (repository_name, commit_sha)
+);
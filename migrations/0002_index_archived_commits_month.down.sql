@@ -0,0 +1 @@
+DROP INDEX IF EXISTS archived_commits_month;
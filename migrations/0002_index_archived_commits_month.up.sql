@@ -0,0 +1,2 @@
+-- 月ごとの読み込み（LoadAll）と保持期間の適用（Prune）の絞り込みに使用する
+CREATE INDEX IF NOT EXISTS archived_commits_month ON archived_commits (month);
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	notificationKindNewCommits    = "new_commits"    // ウォッチ中のリポジトリに新しいコミットがあった
+	notificationKindGoalAtRisk    = "goal_at_risk"   // 1日のコミット目標が未達成のまま夜になった
+	notificationKindSyncFailure   = "sync_failure"   // GitHub APIからの取得に失敗した
+	notificationKindInsight       = "insight"        // コミット活動の異常（急な停止・急増・深夜のコミットの増加）を検知した
+	notificationKindNewRepository = "new_repository" // 新しく作成されたリポジトリを検出した
+	notificationKindSLOViolation  = "slo_violation"  // リポジトリのコミットのペースがSLOを下回った
+)
+
+const (
+	notificationChannelInApp   = "in_app"  // 画面上の受信箱（ベルアイコン）
+	notificationChannelSlack   = "slack"   // SlackのIncoming Webhook
+	notificationChannelDiscord = "discord" // DiscordのWebhook
+)
+
+const (
+	/* notificationsTable は通知データを保存するテーブル名 */
+	notificationsTable = "notifications"
+	/* maxNotificationsPerViewer は閲覧者ごとに保持する通知の最大件数（古いものから削除） */
+	maxNotificationsPerViewer = 200
+	/* goalAtRiskHour はコミット目標未達成の通知を出し始める時刻（時） */
+	goalAtRiskHour = 20
+)
+
+/* notificationKinds はサポートする通知の種類の一覧 */
+var notificationKinds = []string{
+	notificationKindNewCommits,
+	notificationKindGoalAtRisk,
+	notificationKindSyncFailure,
+	notificationKindInsight,
+	notificationKindNewRepository,
+	notificationKindSLOViolation,
+}
+
+/*
+Notification は受信箱に表示される1件の通知を表す構造体
+*/
+type Notification struct {
+	ID        string     `json:"id"`             // 通知ID（ランダムな16進数文字列）
+	Kind      string     `json:"kind"`           // 通知の種類（notificationKind* 定数）
+	Title     string     `json:"title"`          // 通知のタイトル
+	Message   string     `json:"message"`        // 通知の本文
+	Repo      string     `json:"repo,omitempty"` // 関連するリポジトリ名（ない場合は省略）
+	CreatedAt time.Time  `json:"created_at"`     // 通知の作成日時
+	ReadAt    *time.Time `json:"read_at"`        // 既読にした日時（未読の場合はnull）
+}
+
+/*
+NotificationPreferences は閲覧者ごとの通知設定を表す構造体
+*/
+type NotificationPreferences struct {
+	/* WatchedRepos は新しいコミットを通知するリポジトリ名の一覧 */
+	WatchedRepos []string `json:"watched_repos"`
+	/*
+		Channels は通知の種類ごとの配信先チャネル
+		例: {"sync_failure": ["in_app", "slack"]}
+		種類がキーに存在しない場合はその種類の通知を受け取らない
+	*/
+	Channels map[string][]string `json:"channels"`
+	/* DailyCommitGoal は1日のコミット目標数（0の場合は目標なし） */
+	DailyCommitGoal int `json:"daily_commit_goal" binding:"min=0"`
+	/* SlackWebhookURL はslackチャネルの送信先URL */
+	SlackWebhookURL string `json:"slack_webhook_url"`
+	/* DiscordWebhookURL はdiscordチャネルの送信先URL */
+	DiscordWebhookURL string `json:"discord_webhook_url"`
+}
+
+/*
+defaultNotificationPreferences は初めてアクセスした閲覧者に適用する通知設定を返す
+ウォッチ対象がないため、初期状態ではアプリ内での失敗・目標・SLOの通知のみ受け取る
+（insight 通知は受け取る場合のみチャネルを設定する）
+*/
+func defaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		WatchedRepos: []string{},
+		Channels: map[string][]string{
+			notificationKindNewCommits:   {notificationChannelInApp},
+			notificationKindGoalAtRisk:   {notificationChannelInApp},
+			notificationKindSyncFailure:  {notificationChannelInApp},
+			notificationKindSLOViolation: {notificationChannelInApp},
+		},
+	}
+}
+
+/*
+notificationStore は全閲覧者の受信箱と通知設定を保持するストア
+notificationsテーブルに永続化され、サーバー再起動後も内容が維持される
+*/
+type notificationStore struct {
+	mu sync.Mutex
+	/* Inboxes は閲覧者IDごとの通知一覧（新しい順） */
+	Inboxes map[string][]Notification `json:"inboxes"`
+	/* Preferences は閲覧者IDごとの通知設定 */
+	Preferences map[string]NotificationPreferences `json:"preferences"`
+	/*
+		LatestCommitTimes はリポジトリごとに前回確認した最新コミット日時
+		これより新しいコミットが現れた場合に new_commits 通知を作成する
+	*/
+	LatestCommitTimes map[string]time.Time `json:"latest_commit_times"`
+}
+
+/* notifications はアプリケーション全体で共有する通知ストア */
+var notifications = &notificationStore{
+	Inboxes:           map[string][]Notification{},
+	Preferences:       map[string]NotificationPreferences{},
+	LatestCommitTimes: map[string]time.Time{},
+}
+
+func init() {
+	registerTable(notificationsTable, loadNotifications)
+}
+
+/*
+loadNotifications はnotificationsテーブルから通知ストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadNotifications() error {
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	/*
+		json.Unmarshalは既存のマップにキーを追加するため、
+		インポート時に古い内容が残らないよう一度空にしてから読み込む
+	*/
+	notifications.Inboxes = nil
+	notifications.Preferences = nil
+	notifications.LatestCommitTimes = nil
+	if err := loadTable(notificationsTable, notifications); err != nil {
+		return err
+	}
+	/* 古いファイルや空ファイルから読み込んだ場合に備えてnilマップを初期化 */
+	if notifications.Inboxes == nil {
+		notifications.Inboxes = map[string][]Notification{}
+	}
+	if notifications.Preferences == nil {
+		notifications.Preferences = map[string]NotificationPreferences{}
+	}
+	if notifications.LatestCommitTimes == nil {
+		notifications.LatestCommitTimes = map[string]time.Time{}
+	}
+	return nil
+}
+
+/* save は通知ストアをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *notificationStore) save() {
+	if err := saveTable(notificationsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save notifications")
+	}
+}
+
+/*
+preferencesFor は閲覧者の通知設定を返す。未登録の場合はデフォルト設定を登録して返す
+（呼び出し元でmuをロックしていること）
+*/
+func (s *notificationStore) preferencesFor(viewer string) NotificationPreferences {
+	prefs, ok := s.Preferences[viewer]
+	if !ok {
+		prefs = defaultNotificationPreferences()
+		s.Preferences[viewer] = prefs
+	}
+	return prefs
+}
+
+/*
+notify は通知を作成し、設定に従って各閲覧者へ配信する
+通知設定を持つすべての閲覧者が対象で、種類のチャネル設定がない閲覧者には配信しない
+
+引数:
+  kind string - 通知の種類（notificationKind* 定数）
+  repo string - 関連するリポジトリ名（ない場合は空文字）
+  title string - 通知のタイトル
+  message string - 通知の本文
+
+注意:
+  - 同じ種類・リポジトリの未読通知が既にある閲覧者には、どのチャネルにも重複して配信しない
+    （ページを再読み込みするたびに同じ失敗通知が積み上がるのを防ぐ）
+  - new_commits はリポジトリをウォッチしている閲覧者のみが対象
+  - 閲覧者の設定とは別に、管理者が登録した送信Webhookと、EVENT_BROKER_URLのブローカーにも送信する
+*/
+func notify(kind, repo, title, message string) {
+	now := time.Now()
+	event := WebhookEvent{ID: newSessionID(), Kind: kind, Title: title, Message: message, Repo: repo, CreatedAt: now.UTC()}
+	webhooks.dispatch(event)
+	eventBroker.publish(kind, event)
+
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	changed := false
+	for viewer, prefs := range notifications.Preferences {
+		if kind == notificationKindNewCommits && !containsString(prefs.WatchedRepos, repo) {
+			continue
+		}
+		if notifications.hasUnread(viewer, kind, repo) {
+			continue
+		}
+		for _, channel := range prefs.Channels[kind] {
+			switch channel {
+			case notificationChannelInApp:
+				notifications.push(viewer, Notification{
+					ID:        newSessionID(),
+					Kind:      kind,
+					Title:     title,
+					Message:   message,
+					Repo:      repo,
+					CreatedAt: now,
+				})
+				changed = true
+			case notificationChannelSlack:
+				if prefs.SlackWebhookURL != "" {
+					notifyChannelWebhook(notificationChannelSlack, prefs.SlackWebhookURL, event)
+				}
+			case notificationChannelDiscord:
+				if prefs.DiscordWebhookURL != "" {
+					notifyChannelWebhook(notificationChannelDiscord, prefs.DiscordWebhookURL, event)
+				}
+			}
+		}
+	}
+
+	if changed {
+		notifications.save()
+	}
+}
+
+/*
+push は閲覧者の受信箱の先頭に通知を追加する（呼び出し元でmuをロックしていること）
+maxNotificationsPerViewerを超えた分は古い通知から削除する
+*/
+func (s *notificationStore) push(viewer string, n Notification) {
+	inbox := append([]Notification{n}, s.Inboxes[viewer]...)
+	if len(inbox) > maxNotificationsPerViewer {
+		inbox = inbox[:maxNotificationsPerViewer]
+	}
+	s.Inboxes[viewer] = inbox
+}
+
+/* hasUnread は閲覧者の受信箱に同じ種類・リポジトリの未読通知があるかを返す（呼び出し元でmuをロックしていること） */
+func (s *notificationStore) hasUnread(viewer, kind, repo string) bool {
+	for _, n := range s.Inboxes[viewer] {
+		if n.ReadAt == nil && n.Kind == kind && n.Repo == repo {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+evaluateHistoryNotifications はコミット履歴の取得結果から通知を作成する
+getGitHistoryの集計後に呼び出される
+
+引数:
+  commits []CommitHistory - 取得できた全コミット
+  failedRepos []string - コミット取得に失敗したリポジトリ名
+
+作成する通知:
+  - sync_failure: 取得に失敗したリポジトリごと
+  - new_commits: 前回確認時より新しいコミットがあるリポジトリごと
+  - goal_at_risk: goalAtRiskHour時以降に、今日のコミット数が目標未満の閲覧者
+*/
+func evaluateHistoryNotifications(commits []CommitHistory, failedRepos []string) {
+	for _, repo := range failedRepos {
+		notify(notificationKindSyncFailure, repo,
+			"コミット履歴の取得に失敗しました",
+			fmt.Sprintf("%s のコミット履歴をGitHubから取得できませんでした", repo))
+	}
+
+	/* リポジトリごとの最新コミット日時と、前回確認時より新しいコミット数を集計 */
+	latest := map[string]time.Time{}
+	for _, commit := range commits {
+		if commit.CommitTime.After(latest[commit.RepositoryName]) {
+			latest[commit.RepositoryName] = commit.CommitTime
+		}
+	}
+
+	notifications.mu.Lock()
+	newCounts := map[string]int{}
+	for _, commit := range commits {
+		previous, known := notifications.LatestCommitTimes[commit.RepositoryName]
+		/* 初めて見るリポジトリは基準日時の記録のみ行い、通知しない */
+		if known && commit.CommitTime.After(previous) {
+			newCounts[commit.RepositoryName]++
+		}
+	}
+	for repo, t := range latest {
+		notifications.LatestCommitTimes[repo] = t
+	}
+	notifications.save()
+	notifications.mu.Unlock()
+
+	for repo, count := range newCounts {
+		notify(notificationKindNewCommits, repo,
+			"新しいコミットがあります",
+			fmt.Sprintf("%s に %d 件の新しいコミットがあります", repo, count))
+	}
+
+	now := time.Now()
+	if now.Hour() >= goalAtRiskHour {
+		evaluateGoalAtRisk(commits, now)
+	}
+}
+
+/*
+evaluateGoalAtRisk は今日のコミット数が目標に届いていない閲覧者に goal_at_risk 通知を作成する
+通知は閲覧者ごとに1日1回まで
+*/
+func evaluateGoalAtRisk(commits []CommitHistory, now time.Time) {
+	today := 0
+	for _, commit := range commits {
+		if sameDay(commit.CommitTime.In(now.Location()), now) {
+			today++
+		}
+	}
+
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	changed := false
+	for viewer, prefs := range notifications.Preferences {
+		if prefs.DailyCommitGoal <= 0 || today >= prefs.DailyCommitGoal {
+			continue
+		}
+		if !containsString(prefs.Channels[notificationKindGoalAtRisk], notificationChannelInApp) {
+			continue
+		}
+		if notifications.notifiedToday(viewer, notificationKindGoalAtRisk, now) {
+			continue
+		}
+		notifications.push(viewer, Notification{
+			ID:        newSessionID(),
+			Kind:      notificationKindGoalAtRisk,
+			Title:     "今日のコミット目標が未達成です",
+			Message:   fmt.Sprintf("今日のコミットは %d 件です（目標: %d 件）", today, prefs.DailyCommitGoal),
+			CreatedAt: now,
+		})
+		changed = true
+	}
+
+	if changed {
+		notifications.save()
+	}
+}
+
+/* notifiedToday は閲覧者に今日すでに同じ種類の通知を作成済みかを返す（呼び出し元でmuをロックしていること） */
+func (s *notificationStore) notifiedToday(viewer, kind string, now time.Time) bool {
+	for _, n := range s.Inboxes[viewer] {
+		if n.Kind == kind && sameDay(n.CreatedAt.In(now.Location()), now) {
+			return true
+		}
+	}
+	return false
+}
+
+/* sameDay は2つの日時が同じ日付かどうかを返す */
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+/* containsString はスライスに指定した文字列が含まれるかを返す */
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+getNotifications は閲覧者の受信箱を返すAPIハンドラー
+
+クエリパラメータ:
+  unread bool - true の場合は未読の通知のみ返す
+
+レスポンス:
+  成功時: 200 OK, {"notifications": []Notification, "unread_count": 未読件数}
+  失敗時: 422 Unprocessable Entity（unreadが真偽値でない）
+*/
+func getNotifications(c *gin.Context) {
+	var params struct {
+		Unread bool `form:"unread"`
+	}
+	if !bindQuery(c, &params) {
+		return
+	}
+	viewer := viewerID(c)
+	unreadOnly := params.Unread
+
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	/* 初回アクセス時にデフォルト設定を登録し、以降の通知の配信対象にする */
+	if _, ok := notifications.Preferences[viewer]; !ok {
+		notifications.preferencesFor(viewer)
+		notifications.save()
+	}
+
+	list := []Notification{}
+	unread := 0
+	for _, n := range notifications.Inboxes[viewer] {
+		if n.ReadAt == nil {
+			unread++
+		} else if unreadOnly {
+			continue
+		}
+		list = append(list, n)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+
+	respondJSON(c, http.StatusOK, gin.H{"notifications": list, "unread_count": unread})
+}
+
+/*
+markNotificationRead は指定した通知を既読にするAPIハンドラー
+
+パスパラメータ:
+  id string - 通知ID
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 404 Not Found（閲覧者の受信箱に該当する通知がない）
+*/
+func markNotificationRead(c *gin.Context) {
+	viewer := viewerID(c)
+	id := c.Param("id")
+
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	inbox := notifications.Inboxes[viewer]
+	for i := range inbox {
+		if inbox[i].ID != id {
+			continue
+		}
+		if inbox[i].ReadAt == nil {
+			now := time.Now()
+			inbox[i].ReadAt = &now
+			notifications.save()
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+	respondError(c, http.StatusNotFound, "notification not found")
+}
+
+/*
+markAllNotificationsRead は閲覧者の未読通知をすべて既読にするAPIハンドラー
+
+レスポンス:
+  200 OK, {"marked": 既読にした件数}
+*/
+func markAllNotificationsRead(c *gin.Context) {
+	viewer := viewerID(c)
+
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	now := time.Now()
+	marked := 0
+	inbox := notifications.Inboxes[viewer]
+	for i := range inbox {
+		if inbox[i].ReadAt == nil {
+			inbox[i].ReadAt = &now
+			marked++
+		}
+	}
+	if marked > 0 {
+		notifications.save()
+	}
+	respondJSON(c, http.StatusOK, gin.H{"marked": marked})
+}
+
+/*
+getNotificationPreferences は閲覧者の通知設定を返すAPIハンドラー
+
+レスポンス:
+  200 OK, NotificationPreferences
+*/
+func getNotificationPreferences(c *gin.Context) {
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	_, existed := notifications.Preferences[viewerID(c)]
+	prefs := notifications.preferencesFor(viewerID(c))
+	if !existed {
+		notifications.save()
+	}
+	respondJSON(c, http.StatusOK, prefs)
+}
+
+/*
+putNotificationPreferences は閲覧者の通知設定を更新するAPIハンドラー
+リクエストボディの内容で設定全体を置き換える
+
+レスポンス:
+  成功時: 200 OK, 更新後のNotificationPreferences
+  失敗時: 400 Bad Request（JSON不正）, 422 Unprocessable Entity（負の目標数、未知の種類・チャネル、URL未設定のチャネル）
+*/
+func putNotificationPreferences(c *gin.Context) {
+	var prefs NotificationPreferences
+	if !bindJSON(c, &prefs) {
+		return
+	}
+	if prefs.WatchedRepos == nil {
+		prefs.WatchedRepos = []string{}
+	}
+	if prefs.Channels == nil {
+		prefs.Channels = map[string][]string{}
+	}
+
+	notifications.mu.Lock()
+	defer notifications.mu.Unlock()
+
+	notifications.Preferences[viewerID(c)] = prefs
+	notifications.save()
+	respondJSON(c, http.StatusOK, prefs)
+}
+
+/*
+validateFields はbindingタグで表せない通知設定の内容を検証する（bindJSONから呼び出される）
+負の目標数はbindingタグで検証する
+
+戻り値:
+  []FieldError - 未知の通知種類・チャネル、Webhook URLが未設定（またはhttps以外）のチャネル（問題がない場合は空）
+*/
+func (prefs *NotificationPreferences) validateFields() []FieldError {
+	var fields []FieldError
+	needsURL := map[string]bool{}
+	kinds := make([]string, 0, len(prefs.Channels))
+	for kind := range prefs.Channels {
+		kinds = append(kinds, kind)
+	}
+	/* 同じリクエストに同じ順序でエラーを返すため、種類の名前順に検証する */
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		field := "channels." + kind
+		if !containsString(notificationKinds, kind) {
+			fields = append(fields, FieldError{Field: field, Rule: "oneof", Message: fmt.Sprintf("unsupported notification kind: %s", kind)})
+			continue
+		}
+		for _, channel := range prefs.Channels[kind] {
+			switch channel {
+			case notificationChannelInApp:
+			case notificationChannelSlack, notificationChannelDiscord:
+				needsURL[channel] = true
+			default:
+				fields = append(fields, FieldError{Field: field, Rule: "oneof", Message: fmt.Sprintf("unsupported notification channel: %s", channel)})
+			}
+		}
+	}
+	if needsURL[notificationChannelSlack] && !strings.HasPrefix(prefs.SlackWebhookURL, "https://") {
+		fields = append(fields, FieldError{Field: "slack_webhook_url", Rule: "https_url", Message: "slack_webhook_url must be an https URL for slack channel"})
+	}
+	if needsURL[notificationChannelDiscord] && !strings.HasPrefix(prefs.DiscordWebhookURL, "https://") {
+		fields = append(fields, FieldError{Field: "discord_webhook_url", Rule: "https_url", Message: "discord_webhook_url must be an https URL for discord channel"})
+	}
+	return fields
+}
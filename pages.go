@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+version はビルドのバージョン
+ビルド時に -ldflags "-X main.version=v1.2.0" のように埋め込む（未指定の場合は "dev"）
+*/
+var version = "dev"
+
+/* siteInfo はすべてのページで共通のサーバー全体の情報 */
+func siteInfo() web.Site {
+	return web.Site{Title: siteName, Username: username, Version: version, ThemeColor: themeColor, BasePath: basePath}
+}
+
+/*
+lastSync は直近のコミット履歴の取得の集計
+トップページのヘッダーにサーバーレンダリングで表示する
+*/
+var lastSync = struct {
+	mu    sync.Mutex
+	stats web.SummaryStats
+}{}
+
+/*
+recordSyncSummary はコミット履歴の取得結果を集計して保存する
+getGitHistoryの集計後に呼び出される
+
+引数:
+  repos int - 取得対象のリポジトリ数
+  commits []CommitHistory - 取得できた全コミット
+  failed int - コミットの取得に失敗したリポジトリ数
+*/
+func recordSyncSummary(repos int, commits []CommitHistory, failed int) {
+	stats := web.SummaryStats{
+		Repositories:       repos,
+		Commits:            len(commits),
+		FailedRepositories: failed,
+		SyncedAt:           time.Now(),
+	}
+	for _, commit := range commits {
+		if commit.CommitTime.After(stats.LatestCommitAt) {
+			stats.LatestCommitAt = commit.CommitTime
+		}
+	}
+
+	lastSync.mu.Lock()
+	lastSync.stats = stats
+	lastSync.mu.Unlock()
+}
+
+/*
+showIndexPage はトップページ（"/"）を表示するハンドラー
+コミット履歴はページのJavaScriptが /api/git-history から取得する
+*/
+func showIndexPage(c *gin.Context) {
+	/*
+		第一引数: HTTPステータスコード（200 OK）
+		第二引数: テンプレート名
+		第三引数: テンプレートに渡すビューモデル（web.IndexPage）
+	*/
+	c.HTML(http.StatusOK, "index.html", web.NewIndexPage(siteInfo(), webRequest(c), currentSyncSummary()))
+}
+
+/* currentSyncSummary は直近のコミット履歴の取得の集計を返す */
+func currentSyncSummary() web.SummaryStats {
+	lastSync.mu.Lock()
+	defer lastSync.mu.Unlock()
+	return lastSync.stats
+}
+
+/*
+webRequest はビューモデルの作成に必要なリクエストごとの情報を集める
+表示言語と翻訳、テーマ、インラインスクリプトのnonceを含む
+*/
+func webRequest(c *gin.Context) web.Request {
+	return web.Request{
+		Lang:      requestLanguage(c),
+		Theme:     requestTheme(c),
+		Themes:    availableThemes(),
+		CSPNonce:  cspNonce(c),
+		CSRFToken: csrfToken(c),
+		T: func(id string) string {
+			return localize(c, id, map[string]interface{}{"Username": username})
+		},
+		Messages: clientMessages(c),
+	}
+}
+
+/*
+newCSPNonce はContent-Security-Policyのnonceとして使用する128ビットのランダムな値を返す
+リクエストごとに異なる値を生成する（cspMiddleware）
+*/
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		/* newSessionIDと同様、乱数生成の失敗は起動環境の異常とみなす */
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+/api/git-history のページネーション
+?page=&per_page= のページ番号による指定と、?cursor= の不透明なトークンによる指定に対応する
+cursorは最後に返したコミットの位置（日時・リポジトリ名・SHA）と絞り込み条件を署名付きで含むため、
+バックグラウンドの同期で新しいコミットが追加されても、次のページの位置がずれない
+*/
+
+const (
+	/* defaultPerPage はper_page省略時の1ページあたりの件数 */
+	defaultPerPage = 30
+	/* cursorVersion はcursorの形式のバージョン（形式を変更した場合に古いcursorを拒否する） */
+	cursorVersion = 1
+	/* totalCountHeader はページネーションしたAPIで絞り込み後の全件数を返すレスポンスヘッダー名 */
+	totalCountHeader = "X-Total-Count"
+	/* historyDeepPageAlternative はMAX_PAGINATION_DEPTHを超えるページを拒否する場合に案内する取得方法 */
+	historyDeepPageAlternative = "follow next_cursor (?cursor=) or use the export API instead"
+)
+
+/*
+cursorSecret はcursorの署名に使用する鍵
+環境変数 CURSOR_SECRET で設定する。未設定の場合は起動ごとにランダムな鍵を生成する
+（再起動やレプリカの間でcursorを引き継ぐ場合は設定すること）
+*/
+var cursorSecret = loadCursorSecret(getEnv("CURSOR_SECRET", ""))
+
+/* loadCursorSecret は鍵を読み込む（空文字の場合はランダムな32バイト） */
+func loadCursorSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate cursor secret")
+	}
+	return key
+}
+
+/* errInvalidCursor はcursorの署名・形式が不正な場合のエラー */
+var errInvalidCursor = errors.New("invalid cursor")
+
+/*
+historyCursor はcursorに含める位置と絞り込み条件
+JSONにエンコードして署名し、クライアントには不透明な文字列として渡す
+*/
+type historyCursor struct {
+	Version        int       `json:"v"`           // cursorVersion
+	Time           time.Time `json:"t"`           // 最後に返したコミットの日時
+	Repository     string    `json:"r"`           // 最後に返したコミットのリポジトリ名
+	SHA            string    `json:"s"`           // 最後に返したコミットのSHA
+	Repo           string    `json:"f,omitempty"` // ?repo= の絞り込み
+	IncludeArchive bool      `json:"a,omitempty"` // ?include_archive=true
+	Label          string    `json:"l,omitempty"` // ?label= の絞り込み
+	PerPage        int       `json:"n"`           // 1ページあたりの件数
+}
+
+/* historyParams は /api/git-history のクエリパラメータ（bindQueryで読み込む） */
+type historyParams struct {
+	Repo           string `form:"repo"`
+	Label          string `form:"label"`
+	IncludeArchive bool   `form:"include_archive"`
+	Page           *int   `form:"page" binding:"omitempty,min=1"`
+	PerPage        *int   `form:"per_page" binding:"omitempty,min=1"`
+	Cursor         string `form:"cursor"`
+	Saved          string `form:"saved"`
+}
+
+/*
+historyQuery は /api/git-history のクエリパラメータから決めた取得・ページの条件
+*/
+type historyQuery struct {
+	Repo           string         // リポジトリ名で絞り込む（空文字の場合はすべて）
+	Label          string         // LABEL_RULESのラベルで絞り込む（空文字の場合はすべて）
+	IncludeArchive bool           // アーカイブ済みのコミットも含める
+	Paginated      bool           // page・per_page・cursorのいずれかを指定した場合はtrue（レスポンスをHistoryPageで返す）
+	Page           int            // ページ番号（1始まり、cursor指定時は0）
+	PerPage        int            // 1ページあたりの件数
+	After          *historyCursor // cursor指定時の開始位置
+}
+
+/*
+HistoryPage はページネーションを指定した場合の /api/git-history のレスポンス
+*/
+type HistoryPage struct {
+	Commits    []CommitHistory `json:"commits"`               // このページのコミット（新しい順）
+	Page       int             `json:"page,omitempty"`        // ページ番号（pageで指定した場合のみ）
+	PerPage    int             `json:"per_page"`              // 1ページあたりの件数
+	Total      int             `json:"total"`                 // 絞り込み後の全件数
+	NextCursor string          `json:"next_cursor,omitempty"` // 次のページのcursor（最後のページの場合は省略）
+	Warning    string          `json:"warning,omitempty"`     // レスポンスの大きさの上限を超えたためページネーションに切り替えた場合の警告
+}
+
+/*
+bindHistoryQuery はクエリパラメータを読み込む
+cursorを指定した場合は、絞り込み条件とper_pageにcursorに含まれる値を使用する
+savedを指定した場合は、保存した検索条件のうちリクエストで指定していないパラメータを使用する
+
+戻り値:
+  historyQuery - 読み込んだ条件
+  bool - 読み込めた場合はtrue（page・per_pageが正の整数でない・上限を超える場合、cursorが不正な場合は422、savedの検索条件がない場合は404を返し済み）
+*/
+func bindHistoryQuery(c *gin.Context) (historyQuery, bool) {
+	var params historyParams
+	if !bindQuery(c, &params) {
+		return historyQuery{}, false
+	}
+	if params.Saved != "" {
+		saved, ok := savedSearches.lookup(viewerID(c), params.Saved, "")
+		if !ok {
+			respondError(c, http.StatusNotFound, errSavedSearchNotFound.Error())
+			return historyQuery{}, false
+		}
+		applySavedSearch(c, &params, saved)
+	}
+	q := historyQuery{
+		Repo:           params.Repo,
+		Label:          params.Label,
+		IncludeArchive: params.IncludeArchive,
+		PerPage:        defaultPerPage,
+	}
+
+	if params.Cursor != "" {
+		cursor, err := decodeHistoryCursor(params.Cursor)
+		if err != nil {
+			respondValidationError(c, []FieldError{{Field: "cursor", Rule: "cursor", Message: err.Error()}})
+			return q, false
+		}
+		q.Repo, q.Label, q.IncludeArchive, q.PerPage = cursor.Repo, cursor.Label, cursor.IncludeArchive, cursor.PerPage
+		q.Paginated, q.After = true, &cursor
+		return q, true
+	}
+
+	if params.PerPage != nil {
+		q.PerPage, q.Paginated = *params.PerPage, true
+	}
+	if params.Page != nil {
+		q.Page, q.Paginated = *params.Page, true
+	} else if q.Paginated {
+		q.Page = 1
+	}
+	/* 保存した検索条件のpage・per_pageも対象にするため、bindQueryの後で検証する */
+	if fields := paginationFieldErrors(q.Page, q.PerPage, historyDeepPageAlternative); q.Paginated && len(fields) > 0 {
+		respondValidationError(c, fields)
+		return q, false
+	}
+	return q, true
+}
+
+/* filterCommitsByRepo はリポジトリ名が一致するコミットのみを返す（repoが空文字の場合はそのまま） */
+func filterCommitsByRepo(commits []CommitHistory, repo string) []CommitHistory {
+	if repo == "" {
+		return commits
+	}
+	filtered := []CommitHistory{}
+	for _, commit := range commits {
+		if commit.RepositoryName == repo {
+			filtered = append(filtered, commit)
+		}
+	}
+	return filtered
+}
+
+/*
+commitBefore はページネーションの並び順でaがbより前かを返す
+日時の新しい順、同じ日時の場合はリポジトリ名・SHAの順に並べ、位置を一意に決める
+*/
+func commitBefore(a, b CommitHistory) bool {
+	if !a.CommitTime.Equal(b.CommitTime) {
+		return a.CommitTime.After(b.CommitTime)
+	}
+	if a.RepositoryName != b.RepositoryName {
+		return a.RepositoryName < b.RepositoryName
+	}
+	return a.CommitSHA < b.CommitSHA
+}
+
+/*
+paginateCommits はコミットを並べ替え、条件のページを切り出す
+
+引数:
+  commits []CommitHistory - 絞り込み済みのコミット（並べ替えのためコピーして使用する）
+  q historyQuery - ページの条件
+
+戻り値:
+  HistoryPage - このページのコミットと次のページのcursor
+*/
+func paginateCommits(commits []CommitHistory, q historyQuery) HistoryPage {
+	sorted := append([]CommitHistory(nil), commits...)
+	sort.Slice(sorted, func(i, j int) bool { return commitBefore(sorted[i], sorted[j]) })
+
+	start := 0
+	if q.After != nil {
+		last := CommitHistory{CommitTime: q.After.Time, RepositoryName: q.After.Repository, CommitSHA: q.After.SHA}
+		start = sort.Search(len(sorted), func(i int) bool { return commitBefore(last, sorted[i]) })
+	} else if q.Page > 1 {
+		start = (q.Page - 1) * q.PerPage
+	}
+	if start > len(sorted) {
+		start = len(sorted)
+	}
+	end := start + q.PerPage
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+
+	page := HistoryPage{
+		Commits: append([]CommitHistory{}, sorted[start:end]...),
+		Page:    q.Page,
+		PerPage: q.PerPage,
+		Total:   len(sorted),
+	}
+	if end < len(sorted) {
+		last := sorted[end-1]
+		page.NextCursor = encodeHistoryCursor(historyCursor{
+			Version:        cursorVersion,
+			Time:           last.CommitTime,
+			Repository:     last.RepositoryName,
+			SHA:            last.CommitSHA,
+			Repo:           q.Repo,
+			Label:          q.Label,
+			IncludeArchive: q.IncludeArchive,
+			PerPage:        q.PerPage,
+		})
+	}
+	return page
+}
+
+/*
+setPageLinks はページ番号によるページネーションのLinkヘッダー（RFC 8288）とX-Total-Countを設定する
+GitHub APIと同じく rel="first"・"prev"・"next"・"last" のURLを返し、クライアントがレスポンスの形式を解釈せずにページをたどれるようにする
+
+引数:
+  page int - 今のページ番号（1始まり）
+  perPage int - 1ページあたりの件数
+  total int - 絞り込み後の全件数
+
+注意:
+  - URLは今のリクエストのpage・per_pageを上書きしたもの（cursorは取り除く）
+  - "prev" は2ページ目以降、"next" は続きがある場合のみ設定する
+*/
+func setPageLinks(c *gin.Context, page, perPage, total int) {
+	last := (total + perPage - 1) / perPage
+	if last < 1 {
+		last = 1
+	}
+	link := func(p int, rel string) string {
+		next := url.Values{"page": {strconv.Itoa(p)}, "per_page": {strconv.Itoa(perPage)}}
+		return fmt.Sprintf("<%s>; rel=\"%s\"", pageLinkURL(c, next, "cursor"), rel)
+	}
+	links := []string{}
+	if page*perPage < total {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(last, "last"), link(1, "first"))
+	if page > 1 {
+		links = append(links, link(min(page-1, last), "prev"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+	c.Header(totalCountHeader, strconv.Itoa(total))
+}
+
+/* encodeHistoryCursor はcursorを "<JSONのbase64url>.<HMAC-SHA256のbase64url>" の形式にする */
+func encodeHistoryCursor(cursor historyCursor) string {
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signCursor(payload))
+}
+
+/* decodeHistoryCursor はcursorの署名を検証して読み込む（署名・形式・バージョンが不正な場合はerrInvalidCursor） */
+func decodeHistoryCursor(raw string) (historyCursor, error) {
+	var cursor historyCursor
+	encoded, sig, ok := strings.Cut(raw, ".")
+	if !ok {
+		return cursor, errInvalidCursor
+	}
+	payload, err1 := base64.RawURLEncoding.DecodeString(encoded)
+	mac, err2 := base64.RawURLEncoding.DecodeString(sig)
+	if err1 != nil || err2 != nil || !hmac.Equal(mac, signCursor(payload)) {
+		return cursor, errInvalidCursor
+	}
+	if err := json.Unmarshal(payload, &cursor); err != nil || cursor.Version != cursorVersion || cursor.PerPage < 1 {
+		return cursor, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+/* signCursor はcursorのJSONのHMAC-SHA256を計算する */
+func signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDecodeHistoryCursor(t *testing.T) {
+	cursor := historyCursor{Version: cursorVersion, Time: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), Repository: "giter", SHA: "b41c9e2", Repo: "giter", PerPage: 30}
+	valid := encodeHistoryCursor(cursor)
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	/* 署名し直さずにペイロードだけを書き換えたcursor */
+	tamper := func(modify func(*historyCursor)) string {
+		c := cursor
+		modify(&c)
+		body, _ := json.Marshal(c)
+		return base64.RawURLEncoding.EncodeToString(body) + "." + signature
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want error
+	}{
+		{"valid", valid, nil},
+		{"tampered signature", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("not-the-signature")), errInvalidCursor},
+		{"filter changed without signing", tamper(func(c *historyCursor) { c.Repo = "dotfiles" }), errInvalidCursor},
+		{"per_page changed without signing", tamper(func(c *historyCursor) { c.PerPage = 1000 }), errInvalidCursor},
+		{"missing signature", payload, errInvalidCursor},
+		{"not base64url", "%%%.%%%", errInvalidCursor},
+		{"other version", encodeHistoryCursor(historyCursor{Version: cursorVersion + 1, PerPage: 30}), errInvalidCursor},
+		{"zero per_page", encodeHistoryCursor(historyCursor{Version: cursorVersion}), errInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeHistoryCursor(tt.raw)
+			if err != tt.want {
+				t.Fatalf("decodeHistoryCursor error = %v, want %v", err, tt.want)
+			}
+			if err == nil && got != cursor {
+				t.Errorf("cursor = %+v, want %+v", got, cursor)
+			}
+		})
+	}
+}
+
+/* cursorを別の絞り込み条件のリクエストで使っても、cursorを作成したときの条件で続きを返す */
+func TestHistoryCursorReplayedWithOtherFilters(t *testing.T) {
+	cursor := encodeHistoryCursor(historyCursor{Version: cursorVersion, Repository: "giter", SHA: "b41c9e2", Repo: "giter", PerPage: 2})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"same filters", "cursor=" + cursor},
+		{"other repo", "cursor=" + cursor + "&repo=dotfiles"},
+		{"other per_page and page", "cursor=" + cursor + "&per_page=100&page=3"},
+		{"include_archive", "cursor=" + cursor + "&include_archive=true"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/git-history?"+tt.query, nil)
+
+			q, ok := bindHistoryQuery(c)
+			if !ok {
+				t.Fatalf("bindHistoryQuery rejected the cursor: %d %s", w.Code, w.Body.String())
+			}
+			if q.Repo != "giter" || q.PerPage != 2 || q.IncludeArchive || q.Page != 0 || q.After == nil || q.After.SHA != "b41c9e2" {
+				t.Errorf("query = %+v, want the filters and position of the cursor", q)
+			}
+		})
+	}
+}
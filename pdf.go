@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/*
+最小限のPDFの書き出し（レポート用）
+A4の縦のページに、標準フォント（Helvetica）の文字列・塗りつぶした矩形・線のみを描画する
+外部のライブラリ（gofpdfなど）やフォントの埋め込みを使わないため、文字はWinAnsi（Latin-1）の範囲のみ表示できる
+
+座標はポイント（1/72インチ）で、左上を原点とする（PDFの左下の原点への変換はpdfDocumentで行う）
+*/
+
+const (
+	pdfPageWidth  = 595.28 // A4の幅（ポイント）
+	pdfPageHeight = 841.89 // A4の高さ（ポイント）
+)
+
+/* PDFのフォント（標準の14フォントのため埋め込まない） */
+const (
+	pdfFontRegular = "F1" // Helvetica
+	pdfFontBold    = "F2" // Helvetica-Bold
+)
+
+/* pdfDocument は作成中のPDF */
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+/* newPDFDocument は1ページ目のみの空のPDFを作成する */
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.addPage()
+	return doc
+}
+
+/* addPage はページを追加し、以降の描画の対象にする */
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+/* page は描画の対象のページ（最後に追加したページ）を返す */
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+/*
+text は文字列を描画する
+
+引数:
+  x, y float64 - 文字列の左端とベースラインの位置
+  font string - フォント（pdfFontRegular または pdfFontBold）
+  size float64 - 文字の大きさ（ポイント）
+  s string - 描画する文字列（Latin-1で表せない文字は "?" に置き換える）
+*/
+func (d *pdfDocument) text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, pdfPageHeight-y, pdfEscape(s))
+}
+
+/*
+rect は塗りつぶした矩形を描画する
+
+引数:
+  x, y float64 - 左上の位置
+  w, h float64 - 幅と高さ
+  gray float64 - 塗りつぶす色の明るさ（0: 黒 〜 1: 白）
+*/
+func (d *pdfDocument) rect(x, y, w, h, gray float64) {
+	fmt.Fprintf(d.page(), "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, pdfPageHeight-y-h, w, h)
+}
+
+/* line は幅0.5ポイントの灰色の線を描画する */
+func (d *pdfDocument) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.7 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+/* pdfEscape はPDFの文字列リテラルに含めるため、Latin-1以外の文字を置き換えて括弧とバックスラッシュをエスケープする */
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r > 0xff:
+			b.WriteByte('?')
+		case r >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+/*
+bytes はPDFのファイルの内容を返す
+オブジェクトは カタログ(1)・ページツリー(2)・フォント(3, 4)・各ページとその内容 の順に書き出す
+*/
+func (d *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, pdfFontRegular, pdfFontBold, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* preferencesTable は閲覧者ごとの表示設定を保存するテーブル名 */
+	preferencesTable = "preferences"
+)
+
+/* テーマ名 */
+const (
+	themeLight  = "light"  // ライトテーマ（static/themes/light.css）
+	themeDark   = "dark"   // ダークテーマ（static/themes/dark.css）
+	themeCustom = "custom" // THEME_CUSTOM_CSSで指定したCSS
+)
+
+var (
+	/* defaultTheme は表示設定でテーマを選んでいない閲覧者に適用するテーマ（環境変数 DEFAULT_THEME） */
+	defaultTheme = getEnv("DEFAULT_THEME", themeLight)
+	/*
+		themeCustomCSS はcustomテーマで読み込むCSSのURL（環境変数 THEME_CUSTOM_CSS）
+		例: /static/themes/custom.css, https://example.com/giter.css
+		未設定の場合、customテーマは選択できない
+	*/
+	themeCustomCSS = getEnv("THEME_CUSTOM_CSS", "")
+)
+
+/*
+ViewerPreferences は閲覧者ごとの表示設定を表す構造体
+*/
+type ViewerPreferences struct {
+	/* Theme は選択したテーマ（空文字の場合はDEFAULT_THEMEを使用） */
+	Theme string `json:"theme" binding:"omitempty,theme"`
+}
+
+/*
+PreferencesResponse は表示設定APIのレスポンス
+保存されている設定に加え、実際に適用されるテーマと選択可能なテーマを返す
+*/
+type PreferencesResponse struct {
+	ViewerPreferences
+	EffectiveTheme  string   `json:"effective_theme"`  // 実際に適用されるテーマ
+	AvailableThemes []string `json:"available_themes"` // 選択可能なテーマ
+}
+
+/*
+preferenceStore は全閲覧者の表示設定を保持するストア
+preferencesテーブルに永続化される
+*/
+type preferenceStore struct {
+	mu sync.Mutex
+	/* Viewers は閲覧者IDごとの表示設定 */
+	Viewers map[string]ViewerPreferences `json:"viewers"`
+}
+
+/* viewerPreferences はアプリケーション全体で共有する表示設定ストア */
+var viewerPreferences = &preferenceStore{Viewers: map[string]ViewerPreferences{}}
+
+func init() {
+	registerTable(preferencesTable, loadPreferences)
+}
+
+/*
+loadPreferences はpreferencesテーブルから表示設定ストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadPreferences() error {
+	viewerPreferences.mu.Lock()
+	defer viewerPreferences.mu.Unlock()
+
+	/* loadNotificationsと同様、古い内容が残らないよう一度空にしてから読み込む */
+	viewerPreferences.Viewers = nil
+	if err := loadTable(preferencesTable, viewerPreferences); err != nil {
+		return err
+	}
+	if viewerPreferences.Viewers == nil {
+		viewerPreferences.Viewers = map[string]ViewerPreferences{}
+	}
+	return nil
+}
+
+/* save は表示設定ストアをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *preferenceStore) save() {
+	if err := saveTable(preferencesTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save preferences")
+	}
+}
+
+/* get は閲覧者の表示設定を返す（未登録の場合はゼロ値） */
+func (s *preferenceStore) get(viewer string) ViewerPreferences {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Viewers[viewer]
+}
+
+/* availableThemes は選択可能なテーマの一覧を返す（customはTHEME_CUSTOM_CSS設定時のみ） */
+func availableThemes() []string {
+	themes := []string{themeLight, themeDark}
+	if themeCustomCSS != "" {
+		themes = append(themes, themeCustom)
+	}
+	return themes
+}
+
+/*
+effectiveTheme は閲覧者に適用するテーマ名を返す
+閲覧者の設定 → DEFAULT_THEME → light の順に、選択可能なものを使用する
+*/
+func effectiveTheme(prefs ViewerPreferences) string {
+	for _, theme := range []string{prefs.Theme, defaultTheme} {
+		if containsString(availableThemes(), theme) {
+			return theme
+		}
+	}
+	return themeLight
+}
+
+/*
+themeAssets はテーマ名からテンプレートに渡すテーマ（<html>のクラスと読み込むCSS）を作成する
+light・darkは static/themes/<テーマ名>.css（BASE_PATH配下）を読み込み、customはTHEME_CUSTOM_CSSを読み込む
+*/
+func themeAssets(name string) web.Theme {
+	switch name {
+	case themeDark:
+		/* dark クラスはindex.htmlのCSS変数（.dark）の切り替えにも使用する */
+		return web.Theme{Name: themeDark, Class: "dark", Stylesheet: appPath("/static/themes/dark.css")}
+	case themeCustom:
+		return web.Theme{Name: themeCustom, Class: "theme-custom", Stylesheet: themeCustomCSS}
+	default:
+		return web.Theme{Name: themeLight, Class: "", Stylesheet: appPath("/static/themes/light.css")}
+	}
+}
+
+/* requestTheme はリクエストの閲覧者に適用するテーマを返す */
+func requestTheme(c *gin.Context) web.Theme {
+	return themeAssets(effectiveTheme(viewerPreferences.get(viewerID(c))))
+}
+
+/* preferencesResponse は表示設定APIのレスポンスを作成する */
+func preferencesResponse(prefs ViewerPreferences) PreferencesResponse {
+	return PreferencesResponse{
+		ViewerPreferences: prefs,
+		EffectiveTheme:    effectiveTheme(prefs),
+		AvailableThemes:   availableThemes(),
+	}
+}
+
+/*
+getPreferences は閲覧者の表示設定を返すAPIハンドラー
+
+レスポンス:
+  200 OK, PreferencesResponse
+*/
+func getPreferences(c *gin.Context) {
+	respondJSON(c, http.StatusOK, preferencesResponse(viewerPreferences.get(viewerID(c))))
+}
+
+/*
+putPreferences は閲覧者の表示設定を更新するAPIハンドラー
+リクエストボディの内容で設定全体を置き換える
+theme に空文字を指定するとDEFAULT_THEMEに戻る
+
+レスポンス:
+  成功時: 200 OK, PreferencesResponse
+  失敗時: 400 Bad Request（JSON不正）, 422 Unprocessable Entity（選択できないテーマ）
+*/
+func putPreferences(c *gin.Context) {
+	var prefs ViewerPreferences
+	if !bindJSON(c, &prefs) {
+		return
+	}
+
+	viewerPreferences.mu.Lock()
+	defer viewerPreferences.mu.Unlock()
+
+	viewerPreferences.Viewers[viewerID(c)] = prefs
+	viewerPreferences.save()
+	respondJSON(c, http.StatusOK, preferencesResponse(prefs))
+}
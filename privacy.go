@@ -0,0 +1,134 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+/* プライバシーモード（PRIVACY_MODE） */
+const (
+	privacyModeOff       = "off"       // すべての閲覧者にプライベートリポジトリのデータも返す
+	privacyModeAnonymous = "anonymous" // 匿名の閲覧者には公開リポジトリのデータのみ返す
+)
+
+/*
+privacyMode はプライベートリポジトリのデータを返す閲覧者の範囲
+環境変数 PRIVACY_MODE で変更可能（デフォルト: anonymous）
+anonymousの場合、プライベートリポジトリのデータはreaderのロール以上で認証したリクエストにのみ返し、
+匿名の閲覧者には集計の段階で取り除く
+*/
+var privacyMode = getEnv("PRIVACY_MODE", privacyModeAnonymous)
+
+/*
+visibility はリクエストに返すデータの範囲
+*/
+type visibility int
+
+const (
+	visibilityPublic visibility = iota // 公開リポジトリのデータのみ
+	visibilityAll                      // プライベートリポジトリのデータも含める（閲覧者から隠すリポジトリは除く）
+	visibilityOwner                    // 閲覧者から隠すリポジトリ（repoacl.go）も含める。管理者のみ
+)
+
+/*
+requestVisibility はリクエストの閲覧者に返すデータの範囲を返す
+管理者は隠すリポジトリを含むすべて、readerのロール以上で認証した閲覧者（とPRIVACY_MODE=off）はプライベートリポジトリを含む
+*/
+func requestVisibility(c *gin.Context) visibility {
+	role := requestPrincipal(c).Role
+	switch {
+	case role >= roleAdmin:
+		return visibilityOwner
+	case privacyMode == privacyModeOff || role >= roleReader:
+		return visibilityAll
+	}
+	return visibilityPublic
+}
+
+/*
+shared は他の閲覧者に見せる成果物（レポートなど）に含めるデータの範囲を返す
+管理者が作成した場合も、閲覧者から隠すリポジトリは含めない
+*/
+func (v visibility) shared() visibility {
+	return min(v, visibilityAll)
+}
+
+/* includes はリポジトリがデータの範囲に含まれるかどうかを返す */
+func (v visibility) includes(name string, private bool) bool {
+	if private && v < visibilityAll {
+		return false
+	}
+	return v >= visibilityOwner || !repoACL.isHidden(name)
+}
+
+/*
+backgroundVisibility はリクエストのない処理（sitemap.xml・/metrics/activity・異常検知の通知など）のデータの範囲
+誰でも参照できる出力になるため、PRIVACY_MODE=off 以外では公開リポジトリのみ（閲覧者から隠すリポジトリは常に除く）
+*/
+func backgroundVisibility() visibility {
+	if privacyMode == privacyModeOff {
+		return visibilityAll
+	}
+	return visibilityPublic
+}
+
+/* repositories はデータの範囲に含まれるリポジトリのみを返す */
+func (v visibility) repositories(repos []Repository) []Repository {
+	if v == visibilityOwner {
+		return repos
+	}
+	visible := make([]Repository, 0, len(repos))
+	for _, repo := range repos {
+		if v.includes(repo.Name, repo.Private) {
+			visible = append(visible, repo)
+		}
+	}
+	return visible
+}
+
+/*
+commits はデータの範囲に含まれないリポジトリ（reposのうちプライベートなものと、閲覧者から隠すもの）のコミットを取り除く
+閲覧者から隠すリポジトリは、reposに含まれない場合（アーカイブしたコミットなど）も取り除く
+*/
+func (v visibility) commits(commits []CommitHistory, repos []Repository) []CommitHistory {
+	if v == visibilityOwner {
+		return commits
+	}
+	private := map[string]bool{}
+	for _, repo := range repos {
+		if repo.Private {
+			private[repo.Name] = true
+		}
+	}
+	excluded := map[string]bool{}
+	visible := make([]CommitHistory, 0, len(commits))
+	for _, commit := range commits {
+		name := commit.RepositoryName
+		hide, known := excluded[name]
+		if !known {
+			hide = !v.includes(name, private[name])
+			excluded[name] = hide
+		}
+		if !hide {
+			visible = append(visible, commit)
+		}
+	}
+	return visible
+}
+
+/* fetchVisibleRepositories はデータの範囲に含まれるリポジトリの一覧を取得する（fetchRepositoriesを参照） */
+func fetchVisibleRepositories(v visibility) ([]Repository, error) {
+	repos, err := fetchRepositories()
+	if err != nil {
+		return nil, err
+	}
+	return v.repositories(repos), nil
+}
+
+/* fetchVisibleCommitHistory はデータの範囲に含まれるリポジトリのコミット履歴を取得する（fetchCommitHistoryを参照） */
+func fetchVisibleCommitHistory(v visibility) ([]CommitHistory, []Repository, error) {
+	commits, repos, err := fetchCommitHistory()
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.commits(commits, repos), v.repositories(repos), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+GitHubProfile はGitHub APIから取得するユーザーのプロフィール
+API仕様: https://docs.github.com/ja/rest/users/users#get-a-user
+*/
+type GitHubProfile struct {
+	Login       string    `json:"login"`        // ユーザー名
+	Name        string    `json:"name"`         // 表示名（未設定の場合は空文字）
+	AvatarURL   string    `json:"avatar_url"`   // アバター画像のURL
+	HTMLURL     string    `json:"html_url"`     // GitHubのプロフィールページURL
+	Bio         string    `json:"bio"`          // 自己紹介
+	Company     string    `json:"company"`      // 所属
+	Location    string    `json:"location"`     // 場所
+	Blog        string    `json:"blog"`         // WebサイトのURL
+	Followers   int       `json:"followers"`    // フォロワー数
+	Following   int       `json:"following"`    // フォロー数
+	PublicRepos int       `json:"public_repos"` // 公開リポジトリ数
+	CreatedAt   time.Time `json:"created_at"`   // アカウントの作成日時
+}
+
+/*
+ProfileTotals は同期したデータから計算したダッシュボードの合計
+*/
+type ProfileTotals struct {
+	Repositories      int        `json:"repositories"`         // 対象のリポジトリ数（データの範囲内）
+	Stars             int        `json:"stars"`                // 対象のリポジトリのスター数の合計
+	Commits           int        `json:"commits"`              // 集計のビューのコミット数（アーカイブを含む）
+	CommitsLast30Days int        `json:"commits_last_30_days"` // 直近30日間のコミット数
+	LatestCommitAt    *time.Time `json:"latest_commit_at"`     // 最も新しいコミットの日時（コミットがない場合はnull）
+	SyncedAt          *time.Time `json:"synced_at"`            // 集計のビューに最後に同期の結果を加算した日時
+}
+
+/*
+ProfileResponse は /api/profile のレスポンス
+*/
+type ProfileResponse struct {
+	Profile GitHubProfile `json:"profile"` // GitHubのプロフィール
+	Totals  ProfileTotals `json:"totals"`  // 同期したデータから計算した合計
+}
+
+/* fetchProfile はusernameのGitHubのプロフィールを取得する（githubGetのキャッシュを経由する） */
+func fetchProfile() (GitHubProfile, error) {
+	var profile GitHubProfile
+	url := fmt.Sprintf("%s/users/%s", githubAPIBase, username)
+	err := fetchGitHubJSON(upstreamOpRepository, url, githubAcceptV3, &profile)
+	return profile, err
+}
+
+/*
+profileTotals はリポジトリ一覧と集計のビューから合計を計算する
+
+引数:
+  repos []Repository - データの範囲で絞り込んだリポジトリ一覧
+  v visibility - データの範囲
+  today time.Time - 直近30日間の最終日（statsLocationの0時）
+*/
+func profileTotals(repos []Repository, v visibility, today time.Time) ProfileTotals {
+	totals := ProfileTotals{Repositories: len(repos)}
+	for _, repo := range repos {
+		totals.Stars += repo.StargazersCount
+	}
+	for _, n := range statsViews.dailyCounts(v, "") {
+		totals.Commits += n
+	}
+	windows := statsViews.windowCounts(today)
+
+	statsViews.mu.RLock()
+	defer statsViews.mu.RUnlock()
+	for repo, w := range windows {
+		if statsViews.visible(repo, v) {
+			totals.CommitsLast30Days += w.Last30Days
+		}
+	}
+	for repo, latest := range statsViews.latest {
+		if statsViews.visible(repo, v) && (totals.LatestCommitAt == nil || latest.After(*totals.LatestCommitAt)) {
+			latest := latest
+			totals.LatestCommitAt = &latest
+		}
+	}
+	if !statsViews.syncedAt.IsZero() {
+		syncedAt := statsViews.syncedAt
+		totals.SyncedAt = &syncedAt
+	}
+	return totals
+}
+
+/*
+getProfile はGitHubのプロフィールと、同期したデータから計算した合計を返すAPIハンドラー
+ダッシュボードのヘッダー（アバター・自己紹介・フォロワー数・コミット数など）を1回のリクエストで表示するために使用する
+
+レスポンス:
+  成功時: 200 OK, ProfileResponse
+  失敗時: 500 Internal Server Error（アーカイブを読み込めない）, 502 Bad Gateway（GitHubから取得できない）
+
+注意:
+  - PRIVACY_MODE=anonymous の匿名の閲覧者には、プライベートリポジトリを合計に含めない
+  - 最後の同期からSTATS_VIEWS_MAX_AGEが経過している場合は、応答の前に同期する
+*/
+func getProfile(c *gin.Context) {
+	profile, err := fetchProfile()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch GitHub profile")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	v := requestVisibility(c)
+	repos, err := fetchVisibleRepositories(v)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if !refreshStatsViews(c) {
+		return
+	}
+
+	now := time.Now().In(statsLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, statsLocation)
+	respondJSON(c, http.StatusOK, ProfileResponse{Profile: profile, Totals: profileTotals(repos, v, today)})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* proxyPathPrefix はGitHub APIプロキシのURLパスの接頭辞 */
+const proxyPathPrefix = "/proxy/github"
+
+/*
+proxyForwardHeaders はGitHubのレスポンスからクライアントへ転送するヘッダー
+Cookieなどの不要なヘッダーは転送しない
+*/
+var proxyForwardHeaders = []string{
+	"Content-Type",
+	"ETag",
+	"Last-Modified",
+	"Link",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+}
+
+/*
+proxyGitHub はGitHub REST APIへのGETリクエストを中継するハンドラー
+/proxy/github/{path} へのリクエストを githubAPIBase（デフォルト: https://api.github.com）/{path} に転送し、
+サーバーのキャッシュ・ETag・レート制限の仕組み（githubGet）を経由させる
+これにより、フロントエンドや他のツールが任意のGitHub APIを呼び出しても、
+同じリクエストはキャッシュから返され、認証なしのレート制限を無駄に消費しない
+
+例:
+  GET /proxy/github/repos/develop-suda/giter/commits?per_page=10
+  -> GET https://api.github.com/repos/develop-suda/giter/commits?per_page=10
+
+レスポンスヘッダー:
+  X-Giter-Cache - キャッシュの利用状況（MISS / HIT / REVALIDATED / STALE）
+  Link - ページネーションのURLをプロキシ経由のURLに書き換えて転送
+
+注意:
+  - GETのみ対応（書き込み系のAPIは中継しない）
+  - クライアントのIf-None-MatchがキャッシュのETagと一致する場合は304を返す
+  - レスポンスの "email" の値はEMAIL_PRIVACYに従って変換する
+  - 閲覧者から隠すリポジトリ（/repos/{username}/{リポジトリ名}/...）と、隠すリポジトリを含みうる一覧・検索のAPIは、
+    管理者以外には404を返す（proxyHidesRepositoryを参照）
+  - GITHUB_TOKENを設定している場合も、中継するリクエストにはトークンを付けない（認証なしのレート制限で取得する）
+*/
+func proxyGitHub(c *gin.Context) {
+	path := c.Param("path")
+	if proxyHidesRepository(c, path) {
+		respondError(c, http.StatusNotFound, errRepositoryNotFound.Error())
+		return
+	}
+	url := githubAPIBase + path
+	if c.Request.URL.RawQuery != "" {
+		url += "?" + c.Request.URL.RawQuery
+	}
+
+	resp, err := githubGet(upstreamOpProxy, url, c.GetHeader("Accept"))
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("GitHub proxy request failed")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	for _, name := range proxyForwardHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if name == "Link" {
+				value = strings.ReplaceAll(value, githubAPIBase, proxyBaseURL(c))
+			}
+			c.Header(name, value)
+		}
+	}
+	c.Header("X-Giter-Cache", resp.CacheStatus)
+
+	etag := resp.Header.Get("ETag")
+	if etag != "" && c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	c.Data(resp.StatusCode, contentType, privateJSONEmails(resp.Body))
+}
+
+/*
+proxyBaseURL はLinkヘッダーの書き換えに使用するプロキシのベースURLを返す
+例: http://localhost:8080/proxy/github
+*/
+func proxyBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + appPath(proxyPathPrefix)
+}
+
+/*
+getRateLimit はサーバーが把握しているGitHub APIのレート制限の状態を返すAPIハンドラー
+GitHubへのリクエストは行わず、直近のレスポンスヘッダーから得た値を返す
+
+レスポンス:
+  200 OK, RateLimitState（一度もGitHubにリクエストしていない場合はupdated_atがゼロ値）
+*/
+func getRateLimit(c *gin.Context) {
+	respondJSON(c, http.StatusOK, currentRateLimit())
+}
+
+/*
+proxyListingPaths は複数のリポジトリを返しうるGitHub APIのパス（先頭の "/" を除く）
+リポジトリ名をパスに含まないため、閲覧者から隠すリポジトリを個別に判定できない
+*/
+var proxyListingPaths = []string{"search", "repositories", "events", "networks"}
+
+/*
+proxyHidesRepository はパスが閲覧者から隠すリポジトリを返しうるAPIで、リクエストのデータの範囲に含まれない場合にtrueを返す
+
+注意:
+  - /repos/{username}/{リポジトリ名}/... はリポジトリ名で判定する（GitHubと同じく大文字・小文字を区別しない）
+  - 隠すリポジトリが1つ以上ある場合、管理者以外には一覧・検索・IDによる取得（/search, /repositories, /events, /networks,
+    /users/{username}/...、/repos/.../forks）も拒否する（プロフィール /users/{username} は中継する）
+*/
+func proxyHidesRepository(c *gin.Context, path string) bool {
+	if requestVisibility(c) >= visibilityOwner || !repoACL.any() {
+		return false
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case containsString(proxyListingPaths, strings.ToLower(parts[0])):
+		return true
+	case strings.EqualFold(parts[0], "users") && len(parts) > 2 && strings.EqualFold(parts[1], username):
+		return true
+	case strings.EqualFold(parts[0], "repos") && len(parts) > 3 && strings.EqualFold(parts[3], "forks"):
+		return true
+	case strings.EqualFold(parts[0], "repos") && len(parts) > 2 && strings.EqualFold(parts[1], username):
+		return repoACL.isHidden(parts[2])
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* defaultThemeColor はTHEME_COLOR未設定時のテーマカラー（index.htmlの --primary と同じ色） */
+const defaultThemeColor = "#0f172a"
+
+var (
+	/* siteName はページのタイトルやPWAのマニフェストに使用するサイト名（環境変数 SITE_NAME） */
+	siteName = getEnv("SITE_NAME", "Giter")
+	/*
+		themeColor はブラウザのUIやPWA、アイコンに使用するテーマカラー（環境変数 THEME_COLOR、例: "#0f172a"）
+		#RRGGBB形式でない値はデフォルトに戻す
+	*/
+	themeColor = parseThemeColor(getEnv("THEME_COLOR", defaultThemeColor))
+)
+
+/* themeColorPattern はTHEME_COLORとして受け付ける形式（#RRGGBB） */
+var themeColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+/* parseThemeColor はTHEME_COLORの値を検証し、不正な場合はデフォルトのテーマカラーを返す */
+func parseThemeColor(value string) string {
+	if !themeColorPattern.MatchString(value) {
+		log.Warn().Str("theme_color", value).Msg("Invalid THEME_COLOR, using default")
+		return defaultThemeColor
+	}
+	return value
+}
+
+/* themeColorRGBA はテーマカラー（THEME_COLOR）を画像の描画に使用する色に変換する */
+func themeColorRGBA() color.RGBA {
+	r, _ := strconv.ParseUint(themeColor[1:3], 16, 8)
+	g, _ := strconv.ParseUint(themeColor[3:5], 16, 8)
+	b, _ := strconv.ParseUint(themeColor[5:7], 16, 8)
+	return color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
+}
+
+/* pwaIconSizes はマニフェストに記載するアイコンのサイズ（/icons/icon-<サイズ>.png） */
+var pwaIconSizes = []int{192, 512}
+
+/*
+WebManifest はPWAのマニフェスト（/manifest.webmanifest）
+仕様: https://www.w3.org/TR/appmanifest/
+*/
+type WebManifest struct {
+	Name            string         `json:"name"`             // アプリ名
+	ShortName       string         `json:"short_name"`       // ホーム画面に表示する短い名前
+	StartURL        string         `json:"start_url"`        // 起動時に開くURL
+	Scope           string         `json:"scope"`            // PWAとして扱うURLの範囲
+	Display         string         `json:"display"`          // 表示モード（"standalone"）
+	BackgroundColor string         `json:"background_color"` // スプラッシュ画面の背景色
+	ThemeColor      string         `json:"theme_color"`      // ブラウザのUIの色
+	Icons           []ManifestIcon `json:"icons"`            // アイコン
+}
+
+/* ManifestIcon はマニフェストに記載するアイコン */
+type ManifestIcon struct {
+	Src     string `json:"src"`     // アイコンのURL
+	Sizes   string `json:"sizes"`   // サイズ（例: "192x192"）
+	Type    string `json:"type"`    // MIMEタイプ
+	Purpose string `json:"purpose"` // 用途（"any maskable"）
+}
+
+/*
+getManifest はPWAのマニフェストを返すハンドラー
+内容はSITE_NAMEとTHEME_COLORから生成する
+
+レスポンス:
+  200 OK, application/manifest+json
+*/
+func getManifest(c *gin.Context) {
+	manifest := WebManifest{
+		Name:            siteName,
+		ShortName:       siteName,
+		StartURL:        appPath("/"),
+		Scope:           appPath("/"),
+		Display:         "standalone",
+		BackgroundColor: "#ffffff",
+		ThemeColor:      themeColor,
+		Icons:           []ManifestIcon{},
+	}
+	for _, size := range pwaIconSizes {
+		manifest.Icons = append(manifest.Icons, ManifestIcon{
+			Src:     appPath(fmt.Sprintf("/icons/icon-%d.png", size)),
+			Sizes:   fmt.Sprintf("%dx%d", size, size),
+			Type:    "image/png",
+			Purpose: "any maskable",
+		})
+	}
+	c.Header("Content-Type", "application/manifest+json; charset=utf-8")
+	c.JSON(http.StatusOK, manifest)
+}
+
+/*
+iconCache は生成したアイコン（PNG・ICO）のキャッシュ
+テーマカラーは起動中に変わらないため、サイズごとに1度だけ生成する
+*/
+var iconCache = struct {
+	mu    sync.Mutex
+	icons map[string][]byte
+}{icons: map[string][]byte{}}
+
+/* cachedIcon はキャッシュしたアイコンを返す（未生成の場合はrenderで生成して保存する） */
+func cachedIcon(key string, render func() ([]byte, error)) ([]byte, error) {
+	iconCache.mu.Lock()
+	defer iconCache.mu.Unlock()
+	if data, ok := iconCache.icons[key]; ok {
+		return data, nil
+	}
+	data, err := render()
+	if err != nil {
+		return nil, err
+	}
+	iconCache.icons[key] = data
+	return data, nil
+}
+
+/*
+renderIconPNG はテーマカラーの背景に白いコミットの丸を描いたアイコンをPNGで生成する
+
+引数:
+  size int - アイコンの一辺のピクセル数
+*/
+func renderIconPNG(size int) ([]byte, error) {
+	background := themeColorRGBA()
+	foreground := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	center := float64(size) / 2
+	outer, inner := float64(size)*0.28, float64(size)*0.16
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-center, float64(y)+0.5-center
+			d := dx*dx + dy*dy
+			/* 輪（コミットの丸）と、それを横切る線（ブランチ）を描く */
+			ring := d <= outer*outer && d >= inner*inner
+			line := (dy*dy <= (float64(size)*0.05)*(float64(size)*0.05)) && d > outer*outer && (x > size/10 && x < size-size/10)
+			if ring || line {
+				img.Set(x, y, foreground)
+			} else {
+				img.Set(x, y, background)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+renderFaviconICO は32x32のPNGを1枚含むICO形式のファビコンを生成する
+ICOはPNGをそのまま格納できる（Windows Vista以降・主要ブラウザが対応）
+*/
+func renderFaviconICO() ([]byte, error) {
+	const size = 32
+	pngData, err := renderIconPNG(size)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	/* ICONDIR: 予約(0), 種類(1=アイコン), 画像数(1) */
+	binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, 1})
+	/* ICONDIRENTRY: 幅, 高さ, 色数, 予約, プレーン数, ビット数, データサイズ, データの位置 */
+	buf.Write([]byte{size, size, 0, 0})
+	binary.Write(&buf, binary.LittleEndian, [2]uint16{1, 32})
+	binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(len(pngData)), 6 + 16})
+	buf.Write(pngData)
+	return buf.Bytes(), nil
+}
+
+/*
+getFavicon はファビコン（/favicon.ico）を返すハンドラー
+
+レスポンス:
+  200 OK, image/x-icon（1日キャッシュ）
+*/
+func getFavicon(c *gin.Context) {
+	data, err := cachedIcon("favicon.ico", renderFaviconICO)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "image/x-icon", data)
+}
+
+/*
+getIcon はPWAのアイコン（/icons/icon-<サイズ>.png）を返すハンドラー
+マニフェストに記載したサイズ（pwaIconSizes）のみ対応する
+
+レスポンス:
+  成功時: 200 OK, image/png（1日キャッシュ）
+  失敗時: 404 Not Found
+*/
+func getIcon(c *gin.Context) {
+	name := c.Param("name")
+	for _, size := range pwaIconSizes {
+		if name != fmt.Sprintf("icon-%d.png", size) {
+			continue
+		}
+		size := size
+		data, err := cachedIcon(name, func() ([]byte, error) { return renderIconPNG(size) })
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "image/png", data)
+		return
+	}
+	respondErrorPage(c, http.StatusNotFound, "page not found")
+}
+
+/*
+serviceWorkerScript はPWAのService Worker（/sw.js）
+静的ファイルはキャッシュ優先、ページはネットワーク優先（オフライン時はキャッシュ）で返す
+APIのレスポンスはキャッシュしない
+1つ目の %s にはキャッシュ名に使用するバージョン、2つ目の %s にはBASE_PATH（JavaScriptの文字列）が入る
+*/
+const serviceWorkerScript = `const CACHE = 'giter-%s';
+const BASE = %s;
+const SHELL = [BASE + '/', BASE + '/manifest.webmanifest', BASE + '/favicon.ico'];
+
+self.addEventListener('install', (event) => {
+    event.waitUntil(caches.open(CACHE).then((cache) => cache.addAll(SHELL)));
+    self.skipWaiting();
+});
+
+self.addEventListener('activate', (event) => {
+    event.waitUntil(caches.keys().then((keys) =>
+        Promise.all(keys.filter((key) => key !== CACHE).map((key) => caches.delete(key)))
+    ));
+    self.clients.claim();
+});
+
+self.addEventListener('fetch', (event) => {
+    const url = new URL(event.request.url);
+    if (event.request.method !== 'GET' || url.origin !== location.origin || url.pathname.startsWith(BASE + '/api/')) {
+        return;
+    }
+    if (url.pathname.startsWith(BASE + '/static/') || url.pathname.startsWith(BASE + '/icons/')) {
+        event.respondWith(caches.match(event.request).then((cached) => cached || fetch(event.request).then((response) => {
+            const copy = response.clone();
+            caches.open(CACHE).then((cache) => cache.put(event.request, copy));
+            return response;
+        })));
+        return;
+    }
+    if (event.request.mode === 'navigate') {
+        event.respondWith(fetch(event.request).then((response) => {
+            const copy = response.clone();
+            caches.open(CACHE).then((cache) => cache.put(event.request, copy));
+            return response;
+        }).catch(() => caches.match(event.request).then((cached) => cached || caches.match(BASE + '/'))));
+    }
+});
+`
+
+/*
+getServiceWorker はPWAのService Worker（/sw.js）を返すハンドラー
+更新がすぐに反映されるよう、ブラウザにキャッシュさせない
+
+レスポンス:
+  200 OK, application/javascript
+*/
+func getServiceWorker(c *gin.Context) {
+	c.Header("Service-Worker-Allowed", appPath("/"))
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(fmt.Sprintf(serviceWorkerScript, version, strconv.Quote(basePath))))
+}
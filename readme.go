@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/html"
+)
+
+/* errReadmeNotFound はリポジトリにREADMEがない場合のエラー */
+var errReadmeNotFound = errors.New("readme not found")
+
+/*
+readmeAllowedTags はREADMEのHTMLに残すタグ（値は空要素かどうか）
+これ以外のタグは取り除き、中のテキストのみ残す
+*/
+var readmeAllowedTags = map[string]bool{
+	"a": false, "abbr": false, "b": false, "blockquote": false, "br": true, "code": false,
+	"dd": false, "del": false, "details": false, "div": false, "dl": false, "dt": false, "em": false,
+	"h1": false, "h2": false, "h3": false, "h4": false, "h5": false, "h6": false, "hr": true,
+	"i": false, "img": true, "ins": false, "kbd": false, "li": false, "ol": false, "p": false, "pre": false,
+	"q": false, "s": false, "span": false, "strong": false, "sub": false, "summary": false, "sup": false,
+	"table": false, "tbody": false, "td": false, "tfoot": false, "th": false, "thead": false, "tr": false, "ul": false,
+}
+
+/* readmeDroppedTags は中身ごと取り除くタグ（スクリプト・スタイル・埋め込み・フォーム） */
+var readmeDroppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "form": true,
+	"noscript": true, "template": true, "svg": true, "math": true, "textarea": true, "select": true,
+}
+
+/* readmeAllowedAttrs はタグごとに残す属性（"*"はすべてのタグ共通） */
+var readmeAllowedAttrs = map[string][]string{
+	"*":       {"align", "title", "lang", "dir"},
+	"a":       {"href"},
+	"img":     {"src", "alt", "width", "height"},
+	"td":      {"colspan", "rowspan"},
+	"th":      {"colspan", "rowspan"},
+	"ol":      {"start"},
+	"details": {"open"},
+}
+
+/*
+Readme は /api/repos/:owner/:repo/readme のレスポンス
+*/
+type Readme struct {
+	Repository string `json:"repository"` // リポジトリのフルネーム
+	Name       string `json:"name"`       // ファイル名（例: "README.md"）
+	Path       string `json:"path"`       // リポジトリ内のパス
+	HTMLURL    string `json:"html_url"`   // GitHubのファイルページURL
+	HTML       string `json:"html"`       // サニタイズしたHTML（そのままページに埋め込める）
+}
+
+/*
+fetchReadme はリポジトリのREADMEを取得し、サニタイズしたHTMLに変換する
+Markdownの変換はGitHubのREADME API（HTML形式）に任せ、結果をreadmeAllowedTagsの範囲に絞り込む
+どちらのリクエストもgithubGetのキャッシュ・ETagを経由する
+
+戻り値:
+  Readme - README（HTMLはサニタイズ済み）
+  error - READMEがない場合はerrReadmeNotFound、それ以外の失敗はそのエラー
+*/
+func fetchReadme(repoFullName, branch string) (Readme, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/readme", githubAPIBase, repoFullName)
+	readme := Readme{Repository: repoFullName}
+
+	resp, err := githubGet(upstreamOpRepository, apiURL, githubAcceptV3)
+	if err != nil {
+		captureUpstreamFailure(upstreamOpRepository, apiURL, err)
+		return readme, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return readme, errReadmeNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(resp.Body))
+		captureUpstreamFailure(upstreamOpRepository, apiURL, err)
+		return readme, err
+	}
+	var meta struct {
+		Name    string `json:"name"`
+		Path    string `json:"path"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(resp.Body, &meta); err != nil {
+		return readme, err
+	}
+	readme.Name, readme.Path, readme.HTMLURL = meta.Name, meta.Path, meta.HTMLURL
+
+	rendered, err := githubGet(upstreamOpRepository, apiURL, githubAcceptHTML)
+	if err != nil {
+		captureUpstreamFailure(upstreamOpRepository, apiURL, err)
+		return readme, err
+	}
+	if rendered.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GitHub API error: %s - %s", rendered.Status, string(rendered.Body))
+		captureUpstreamFailure(upstreamOpRepository, apiURL, err)
+		return readme, err
+	}
+	readme.HTML, err = sanitizeReadmeHTML(rendered.Body, repoFullName, branch, meta.Path)
+	return readme, err
+}
+
+/*
+sanitizeReadmeHTML はREADMEのHTMLから許可したタグ・属性のみを残す
+リンクと画像の相対パスは、READMEの場所を基準にGitHub（リンク）とraw.githubusercontent.com（画像）の絶対URLにする
+
+引数:
+  body []byte - GitHubが変換したHTML
+  repoFullName string - リポジトリのフルネーム
+  branch string - デフォルトブランチ名（相対パスの解決に使用）
+  path string - リポジトリ内のREADMEのパス
+
+戻り値:
+  string - サニタイズしたHTML
+  error - HTMLを読み込めない場合のエラー
+
+注意:
+  - http・https・mailto以外のスキーム（javascript:など）のURLは属性ごと取り除く
+  - リンクには rel="nofollow noopener noreferrer" を付ける
+*/
+func sanitizeReadmeHTML(body []byte, repoFullName, branch, path string) (string, error) {
+	dir := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir = path[:i+1]
+	}
+	linkBase, _ := url.Parse(fmt.Sprintf("https://github.com/%s/blob/%s/%s", repoFullName, url.PathEscape(branch), dir))
+	imageBase, _ := url.Parse(fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", repoFullName, url.PathEscape(branch), dir))
+
+	var b strings.Builder
+	z := html.NewTokenizer(bytes.NewReader(body))
+	dropped := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return b.String(), nil
+			}
+			return "", z.Err()
+		case html.TextToken:
+			if dropped == 0 {
+				b.WriteString(html.EscapeString(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			if readmeDroppedTags[tag] {
+				if tt == html.StartTagToken {
+					dropped++
+				}
+				continue
+			}
+			if _, ok := readmeAllowedTags[tag]; dropped > 0 || !ok {
+				continue
+			}
+			b.WriteString("<" + tag)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attr := string(key)
+				if !readmeAttrAllowed(tag, attr) {
+					continue
+				}
+				value := string(val)
+				if attr == "href" || attr == "src" {
+					base := linkBase
+					if attr == "src" {
+						base = imageBase
+					}
+					var ok bool
+					if value, ok = readmeURL(base, value); !ok {
+						continue
+					}
+				}
+				fmt.Fprintf(&b, ` %s="%s"`, attr, html.EscapeString(value))
+			}
+			if tag == "a" {
+				b.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			b.WriteString(">")
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if readmeDroppedTags[tag] {
+				if dropped > 0 {
+					dropped--
+				}
+				continue
+			}
+			if void, ok := readmeAllowedTags[tag]; ok && !void && dropped == 0 {
+				b.WriteString("</" + tag + ">")
+			}
+		}
+	}
+}
+
+/* readmeAttrAllowed はタグに属性を残すかどうかを返す */
+func readmeAttrAllowed(tag, attr string) bool {
+	return containsString(readmeAllowedAttrs["*"], attr) || containsString(readmeAllowedAttrs[tag], attr)
+}
+
+/* readmeURL は相対パスをbaseで絶対URLにし、許可したスキームの場合のみ返す（ページ内リンクはそのまま返す） */
+func readmeURL(base *url.URL, raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	resolved := base.ResolveReference(ref)
+	switch resolved.Scheme {
+	case "http", "https", "mailto":
+		return resolved.String(), true
+	}
+	return "", false
+}
+
+/*
+getRepositoryReadme はリポジトリのREADMEをサニタイズしたHTMLで返すAPIハンドラー
+リポジトリ詳細ページにプロジェクトの説明を埋め込んで表示するために使用する
+
+パスパラメータ:
+  owner string - 所有者名（usernameのみ）
+  repo string - リポジトリ名
+
+レスポンス:
+  成功時: 200 OK, Readme
+  失敗時: 404 Not Found（リポジトリまたはREADMEがない）, 502 Bad Gateway
+
+注意:
+  - レスポンスはキャッシュの方針（/api/repos/:owner/:repo/readme）に従いサーバーで保持する
+*/
+func getRepositoryReadme(c *gin.Context) {
+	fullName, err := repoFullNameParam(c)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	detail, err := fetchRepository(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	readme, err := fetchReadme(fullName, detail.DefaultBranch)
+	if errors.Is(err, errReadmeNotFound) {
+		respondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	respondJSON(c, http.StatusOK, readme)
+}
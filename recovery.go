@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+ErrorReport はエラー報告サービスに送信する1件のエラー
+*/
+type ErrorReport struct {
+	Message   string    // エラーメッセージ（panicの値）
+	Stack     string    // スタックトレース
+	Method    string    // HTTPメソッド
+	Path      string    // リクエストパス
+	Route     string    // マッチしたルート（例: "/api/notifications/:id/read"）
+	ClientIP  string    // クライアントのIPアドレス
+	RequestID string    // リクエストID
+	ViewerID  string    // 閲覧者ID（セッションID）
+	Timestamp time.Time // 発生日時
+}
+
+/*
+ErrorReporter はpanicなどのエラーを外部のエラー報告サービスへ送信するフック
+*/
+type ErrorReporter interface {
+	/* Name はログに出力する報告先の名前 */
+	Name() string
+	/* Report はエラーを送信する（失敗してもリクエストの処理には影響させない） */
+	Report(report ErrorReport) error
+}
+
+/* errorReporters は環境変数から設定したエラー報告先（未設定の場合は空） */
+var errorReporters = newErrorReportersFromEnv()
+
+/*
+newErrorReportersFromEnv は環境変数からエラー報告先を作成する
+  - ROLLBAR_ACCESS_TOKEN: Rollbarに送信する（post_server_itemスコープのトークン）
+  - ERROR_REPORT_ENVIRONMENT: 報告に付ける環境名（デフォルト: "production"）
+SentryはSDKの初期化が必要なため、ここではなくinitSentryで追加する
+*/
+func newErrorReportersFromEnv() []ErrorReporter {
+	var reporters []ErrorReporter
+	if token := getEnv("ROLLBAR_ACCESS_TOKEN", ""); token != "" {
+		reporters = append(reporters, &rollbarReporter{
+			token:       token,
+			environment: getEnv("ERROR_REPORT_ENVIRONMENT", "production"),
+			client:      &http.Client{Timeout: 5 * time.Second},
+		})
+	}
+	return reporters
+}
+
+/*
+reportError はエラーをすべての報告先に非同期で送信する
+報告先への送信に時間がかかってもレスポンスを遅らせないよう、goroutineで送信する
+*/
+func reportError(report ErrorReport) {
+	for _, reporter := range errorReporters {
+		go func(reporter ErrorReporter) {
+			if err := reporter.Report(report); err != nil {
+				log.Warn().Err(err).Str("reporter", reporter.Name()).Msg("Failed to send error report")
+			}
+		}(reporter)
+	}
+}
+
+/*
+recoveryMiddleware はGin標準のリカバリーミドルウェアの代わりにpanicを処理するミドルウェア
+  - panicの値・スタックトレース・リクエストの情報をzerologで構造化して記録する
+  - 設定されたエラー報告先（ROLLBAR_ACCESS_TOKENなど）に送信する
+  - 共通形式のエラーレスポンス（ErrorResponse）で500を返す
+
+注意:
+  - クライアントが切断した（broken pipe / connection reset）場合はエラーとして報告せず、
+    レスポンスも書き込まない
+*/
+func recoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			if isBrokenConnection(rec) {
+				log.Warn().Interface("panic", rec).Str("path", c.Request.URL.Path).Msg("Client connection closed during response")
+				c.Abort()
+				return
+			}
+
+			report := ErrorReport{
+				Message:   fmt.Sprint(rec),
+				Stack:     string(debug.Stack()),
+				Method:    c.Request.Method,
+				Path:      c.Request.URL.Path,
+				Route:     c.FullPath(),
+				ClientIP:  c.ClientIP(),
+				RequestID: requestID(c),
+				ViewerID:  viewerID(c),
+				Timestamp: time.Now(),
+			}
+			log.Error().
+				Str("panic", report.Message).
+				Str("method", report.Method).
+				Str("path", report.Path).
+				Str("route", report.Route).
+				Str("client_ip", report.ClientIP).
+				Str("request_id", report.RequestID).
+				Str("stack", report.Stack).
+				Msg("Recovered from panic")
+			reportError(report)
+			c.Set(panicRecoveredContextKey, true)
+
+			if c.Writer.Written() {
+				/* すでにレスポンスを書き始めている場合はステータスを変更できない */
+				c.Abort()
+				return
+			}
+			abortWithError(c, http.StatusInternalServerError, "internal server error")
+		}()
+		c.Next()
+	}
+}
+
+/* isBrokenConnection はpanicの原因がクライアントの切断によるものかを返す */
+func isBrokenConnection(rec interface{}) bool {
+	err, ok := rec.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		return errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
+
+/*
+rollbarReporter はRollbarのAPI（POST /api/1/item/）にエラーを送信するErrorReporter
+API仕様: https://docs.rollbar.com/reference/create-item
+*/
+type rollbarReporter struct {
+	token       string       // post_server_itemスコープのアクセストークン
+	environment string       // 環境名
+	client      *http.Client // 送信に使用するHTTPクライアント
+}
+
+func (r *rollbarReporter) Name() string { return "rollbar" }
+
+func (r *rollbarReporter) Report(report ErrorReport) error {
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"environment": r.environment,
+			"level":       "error",
+			"timestamp":   report.Timestamp.Unix(),
+			"platform":    "go",
+			"framework":   "gin",
+			"uuid":        report.RequestID,
+			"body": map[string]interface{}{
+				"message": map[string]interface{}{
+					"body":  report.Message,
+					"stack": report.Stack,
+				},
+			},
+			"request": map[string]interface{}{
+				"url":     report.Path,
+				"method":  report.Method,
+				"user_ip": report.ClientIP,
+			},
+			"context": report.Route,
+			"person":  map[string]interface{}{"id": report.ViewerID},
+			"server":  map[string]interface{}{"host": hostname()},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", "https://api.rollbar.com/api/1/item/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rollbar-Access-Token", r.token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("rollbar returned %s", resp.Status)
+	}
+	return nil
+}
+
+/* hostname はエラー報告に含めるサーバーのホスト名を返す（取得できない場合は空文字） */
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
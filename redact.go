@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* defaultRedactionReplacement は置換後の文字列を指定しないルールで使用する文字列 */
+const defaultRedactionReplacement = "[redacted]"
+
+/*
+redactionRule はコミットメッセージから取り除く文字列のルール
+*/
+type redactionRule struct {
+	pattern     *regexp.Regexp // 取り除く文字列の正規表現
+	replacement string         // 置換後の文字列（$1 などでグループを参照できる）
+}
+
+/*
+redactionRules はコミットメッセージに適用するルール
+環境変数 REDACTION_RULES で指定する（未設定の場合はマスクしない）。parseRedactionRulesを参照
+*/
+var redactionRules = parseRedactionRules(getEnv("REDACTION_RULES", ""))
+
+/*
+parseRedactionRules は改行区切りのルールを読み込む
+  - "正規表現": 一致した部分を "[redacted]" に置き換える
+  - "正規表現 => 置換後の文字列": 一致した部分を置換後の文字列に置き換える
+
+例:
+  REDACTION_RULES="[A-Z]+-[0-9]+
+  [\w.+-]+@[\w-]+\.[\w.]+ => [email]"
+
+注意:
+  - 空行と "#" で始まる行は無視する
+  - コンパイルできない正規表現は警告を出力して無視する
+*/
+func parseRedactionRules(raw string) []redactionRule {
+	var rules []redactionRule
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expr, replacement, found := strings.Cut(line, "=>")
+		if found {
+			expr, replacement = strings.TrimSpace(expr), strings.TrimSpace(replacement)
+		} else {
+			replacement = defaultRedactionReplacement
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			log.Warn().Err(err).Str("rule", line).Msg("Ignoring invalid REDACTION_RULES entry")
+			continue
+		}
+		rules = append(rules, redactionRule{pattern: pattern, replacement: replacement})
+	}
+	return rules
+}
+
+/*
+redactionBypass はマスクを無効にした管理者のセッション（閲覧者ID）
+管理者がマスク前のメッセージを確認するために PUT /api/admin/redaction で切り替える。
+サーバーの再起動で元に戻る（永続化しない）
+*/
+var redactionBypass = struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+}{sessions: map[string]bool{}}
+
+/*
+redaction はリクエストに返すコミットメッセージをマスクするかどうか
+*/
+type redaction bool
+
+const (
+	redactionOff redaction = false // マスクしない
+	redactionOn  redaction = true  // REDACTION_RULESでマスクする
+)
+
+/*
+requestRedaction はリクエストに返すコミットメッセージをマスクするかどうかを返す
+管理者のトークンで認証したリクエストで、かつそのセッションでマスクを無効にしている場合のみマスクしない
+（セッションのクッキーだけではマスクを無効にできない）
+*/
+func requestRedaction(c *gin.Context) redaction {
+	if len(redactionRules) == 0 {
+		return redactionOff
+	}
+	if isAdminRequest(c) && sessionRedactionDisabled(viewerID(c)) {
+		return redactionOff
+	}
+	return redactionOn
+}
+
+/* sessionRedactionDisabled はセッションでマスクを無効にしているかどうかを返す */
+func sessionRedactionDisabled(id string) bool {
+	if id == "" {
+		return false
+	}
+	redactionBypass.mu.Lock()
+	defer redactionBypass.mu.Unlock()
+	return redactionBypass.sessions[id]
+}
+
+/* message はコミットメッセージにルールを順に適用する */
+func (r redaction) message(message string) string {
+	if r == redactionOff {
+		return message
+	}
+	for _, rule := range redactionRules {
+		message = rule.pattern.ReplaceAllString(message, rule.replacement)
+	}
+	return message
+}
+
+/*
+commits はコミットメッセージをマスクしたコミットを返す
+キャッシュ・アーカイブと共有しているスライスを書き換えないよう、マスクする場合はコピーを返す
+*/
+func (r redaction) commits(commits []CommitHistory) []CommitHistory {
+	if r == redactionOff {
+		return commits
+	}
+	redacted := make([]CommitHistory, len(commits))
+	for i, commit := range commits {
+		commit.CommitMessage = r.message(commit.CommitMessage)
+		redacted[i] = commit
+	}
+	return redacted
+}
+
+/* RedactionStatus は /api/admin/redaction のレスポンス */
+type RedactionStatus struct {
+	Rules   []string `json:"rules"`   // REDACTION_RULESの正規表現
+	Enabled bool     `json:"enabled"` // このセッションのリクエストでマスクする場合はtrue
+}
+
+/* redactionStatus はセッションのマスクの状態を返す */
+func redactionStatus(c *gin.Context) RedactionStatus {
+	status := RedactionStatus{Rules: []string{}, Enabled: requestRedaction(c) == redactionOn}
+	for _, rule := range redactionRules {
+		status.Rules = append(status.Rules, rule.pattern.String())
+	}
+	return status
+}
+
+/*
+getRedaction はコミットメッセージのマスクのルールと、セッションでの有効/無効を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, RedactionStatus
+*/
+func getRedaction(c *gin.Context) {
+	respondJSON(c, http.StatusOK, redactionStatus(c))
+}
+
+/*
+putRedaction は管理者のセッションでコミットメッセージのマスクを有効/無効にする管理者APIハンドラー
+マスクを無効にしても、ほかの閲覧者（同じセッションの管理者のトークンのないリクエストを含む）には影響しない
+
+リクエストボディ:
+  {"enabled": false}
+
+レスポンス:
+  成功時: 200 OK, RedactionStatus
+  失敗時: 400 Bad Request（不正なJSON）, 422 Unprocessable Entity（enabled未指定）
+*/
+func putRedaction(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	id := viewerID(c)
+	redactionBypass.mu.Lock()
+	if *req.Enabled {
+		delete(redactionBypass.sessions, id)
+	} else {
+		redactionBypass.sessions[id] = true
+	}
+	redactionBypass.mu.Unlock()
+
+	log.Info().Bool("enabled", *req.Enabled).Msg("Commit message redaction changed for admin session")
+	respondJSON(c, http.StatusOK, redactionStatus(c))
+}
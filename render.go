@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+レスポンスのフィールド名の形式
+構造体のJSONタグ（snake_case）は変更せず、レンダリングの際にフィールド名だけを変換する
+*/
+const (
+	responseCaseSnake = "snake" // repository_name（デフォルト）
+	responseCaseCamel = "camel" // repositoryName
+)
+
+/*
+defaultResponseCase はフィールド名の形式のデフォルト
+環境変数 RESPONSE_CASE で変更可能（"snake" または "camel"、デフォルト: "snake"）
+リクエストごとに ?case=camel / ?case=snake で上書きできる
+*/
+var defaultResponseCase = loadResponseCase(getEnv("RESPONSE_CASE", responseCaseSnake))
+
+/* loadResponseCase は形式の名前を読み込む（不正な値の場合はsnake） */
+func loadResponseCase(name string) string {
+	if name == responseCaseCamel {
+		return responseCaseCamel
+	}
+	return responseCaseSnake
+}
+
+/* responseCase はリクエストに適用するフィールド名の形式を返す（?case= → RESPONSE_CASE） */
+func responseCase(c *gin.Context) string {
+	switch c.Query("case") {
+	case responseCaseCamel, responseCaseSnake:
+		return c.Query("case")
+	}
+	return defaultResponseCase
+}
+
+/*
+respondJSON はvをJSONで返す（APIの成功・エラーレスポンスの共通の出力）
+camelの場合はフィールド名をcamelCaseに変換してから出力する
+
+注意:
+  - PWAのマニフェスト・Grafanaのデータソースなど、外部の仕様でフィールド名が決まっているレスポンスには使用しない
+*/
+func respondJSON(c *gin.Context, status int, v interface{}) {
+	if responseCase(c) == responseCaseCamel {
+		v = camelCaseJSON(v)
+	}
+	c.JSON(status, v)
+}
+
+/* snakeToCamel はsnake_caseの名前をcamelCaseに変換する（例: "repository_name" -> "repositoryName"） */
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+/*
+camelCaseJSON はvのフィールド名をcamelCaseに変換した、JSONにエンコードできる値を返す
+構造体はJSONタグ（omitempty・"-" を含む）に従ってフィールドを出力する
+
+注意:
+  - map[string]interface{}（gin.Hなど）のキーはフィールド名として変換する
+  - それ以外のmapのキー（リポジトリ名・日付など）はデータのため変換しない
+  - json.Marshalerを実装した型（time.Timeなど）は独自のエンコードをそのまま使用する
+*/
+func camelCaseJSON(v interface{}) interface{} {
+	return camelCaseValue(reflect.ValueOf(v))
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+/* camelCaseValue はcamelCaseJSONの再帰処理 */
+func camelCaseValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if t := v.Type(); t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return camelCaseValue(v.Elem())
+	case reflect.Struct:
+		obj := jsonObject{}
+		appendStructFields(&obj, v)
+		return obj
+	case reflect.Map:
+		return camelCaseMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = camelCaseValue(v.Index(i))
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+/* appendStructFields は構造体の公開フィールドを、JSONタグの名前をcamelCaseにして追加する（埋め込み構造体は展開する） */
+func appendStructFields(obj *jsonObject, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				appendStructFields(obj, fv)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyJSONValue(fv) {
+			continue
+		}
+		*obj = append(*obj, jsonField{key: snakeToCamel(name), value: camelCaseValue(fv)})
+	}
+}
+
+/* camelCaseMap はmapの値を変換する（map[string]interface{} の場合のみキーも変換し、キーの順に並べる） */
+func camelCaseMap(v reflect.Value) interface{} {
+	if v.IsNil() {
+		return nil
+	}
+	convertKeys := v.Type().Elem().Kind() == reflect.Interface
+
+	obj := make(jsonObject, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, ok := jsonMapKey(iter.Key())
+		if !ok {
+			/* JSONのキーにできない型はencoding/jsonに任せる（エラーになる） */
+			return v.Interface()
+		}
+		if convertKeys {
+			key = snakeToCamel(key)
+		}
+		obj = append(obj, jsonField{key: key, value: camelCaseValue(iter.Value())})
+	}
+	sort.Slice(obj, func(i, j int) bool { return obj[i].key < obj[j].key })
+	return obj
+}
+
+/* jsonMapKey はencoding/jsonと同じ規則でmapのキーを文字列にする */
+func jsonMapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+/* isEmptyJSONValue はomitemptyで省略される値（encoding/jsonと同じ判定）かを返す */
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+/* jsonField はjsonObjectの1つのフィールド */
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+/* jsonObject はフィールドの順序を保ったままJSONのオブジェクトとしてエンコードされる値 */
+type jsonObject []jsonField
+
+/* MarshalJSON はフィールドを追加した順に {"key":value,...} を出力する */
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* repoACLTable は閲覧者から隠すリポジトリを保存するテーブル名 */
+	repoACLTable = "repository_acl"
+)
+
+/*
+HiddenRepository は閲覧者から隠すリポジトリ
+GitHubで公開されているリポジトリでも、管理者以外の閲覧者（reader・匿名・共有URL）に返すデータから取り除く
+同期・検索インデックスへの追加は続ける（隠すのをやめるとすぐに表示される）
+*/
+type HiddenRepository struct {
+	Name     string    `json:"name"`             // リポジトリ名
+	Reason   string    `json:"reason,omitempty"` // 隠す理由（管理者向けのメモ）
+	HiddenAt time.Time `json:"hidden_at"`        // 隠した日時
+}
+
+/*
+repoACLStore は閲覧者から隠すリポジトリを保持するストア
+repository_aclテーブルに永続化される
+GitHubはリポジトリ名の大文字・小文字を区別しないため、キーは小文字に揃える
+*/
+type repoACLStore struct {
+	mu     sync.Mutex
+	Hidden map[string]HiddenRepository `json:"hidden"` // リポジトリ名（小文字）ごとの設定
+}
+
+/* repoACL はアプリケーション全体で共有する隠すリポジトリのストア */
+var repoACL = &repoACLStore{Hidden: map[string]HiddenRepository{}}
+
+func init() {
+	registerTable(repoACLTable, loadRepoACL)
+}
+
+/*
+loadRepoACL はrepository_aclテーブルからストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadRepoACL() error {
+	repoACL.mu.Lock()
+	defer repoACL.mu.Unlock()
+
+	repoACL.Hidden = map[string]HiddenRepository{}
+	if err := loadTable(repoACLTable, repoACL); err != nil {
+		return err
+	}
+	/* 大文字・小文字を区別していた頃に保存したキーを小文字に揃える */
+	hidden := make(map[string]HiddenRepository, len(repoACL.Hidden))
+	for name, entry := range repoACL.Hidden {
+		entry.Name = strings.ToLower(entry.Name)
+		hidden[strings.ToLower(name)] = entry
+	}
+	repoACL.Hidden = hidden
+	return nil
+}
+
+/* isHidden はリポジトリを閲覧者から隠しているかどうかを返す（大文字・小文字を区別しない） */
+func (s *repoACLStore) isHidden(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.Hidden[strings.ToLower(name)]
+	return ok
+}
+
+/* any は閲覧者から隠しているリポジトリが1つ以上あるかどうかを返す */
+func (s *repoACLStore) any() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Hidden) > 0
+}
+
+/* list は隠しているリポジトリをリポジトリ名順に返す */
+func (s *repoACLStore) list() []HiddenRepository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]HiddenRepository, 0, len(s.Hidden))
+	for _, hidden := range s.Hidden {
+		list = append(list, hidden)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+/*
+set はリポジトリを隠す（hidden=true）または隠すのをやめて（hidden=false）保存する
+リポジトリ名は小文字に揃えて保存する
+
+戻り値:
+  bool - 設定が変わったかどうか（すでに同じ状態の場合はfalse、隠す理由の変更は変わったとみなす）
+  error - 保存に失敗した場合のエラー
+*/
+func (s *repoACLStore) set(name string, hidden bool, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name = strings.ToLower(name)
+	existing, ok := s.Hidden[name]
+	if hidden {
+		if ok && existing.Reason == reason {
+			return false, nil
+		}
+		entry := HiddenRepository{Name: name, Reason: reason, HiddenAt: time.Now().UTC()}
+		if ok {
+			entry.HiddenAt = existing.HiddenAt
+		}
+		s.Hidden[name] = entry
+	} else {
+		if !ok {
+			return false, nil
+		}
+		delete(s.Hidden, name)
+	}
+	return true, saveTable(repoACLTable, s)
+}
+
+/* hideRepositoryRequest は PUT /api/admin/repos/hidden/:name のリクエストボディ（省略可） */
+type hideRepositoryRequest struct {
+	Reason string `json:"reason" binding:"max=200"` // 隠す理由（管理者向けのメモ）
+}
+
+/* hiddenRepositoryName はパスパラメータのリポジトリ名を検証し、所有者を除いて小文字に揃えた名前を返す */
+func hiddenRepositoryName(c *gin.Context) (string, bool) {
+	req := trackRequest{Repo: c.Param("name")}
+	name, ok := req.name()
+	if !ok {
+		respondValidationError(c, []FieldError{{Field: "name", Rule: "repo", Message: "must be a repository name owned by " + username}})
+	}
+	return strings.ToLower(name), ok
+}
+
+/*
+getHiddenRepositories は閲覧者から隠しているリポジトリを返すAPIハンドラー（管理者のみ）
+
+レスポンス:
+  200 OK, {"hidden": []HiddenRepository（リポジトリ名順）}
+*/
+func getHiddenRepositories(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{"hidden": repoACL.list()})
+}
+
+/*
+putHiddenRepository はリポジトリを閲覧者から隠すAPIハンドラー（管理者のみ）
+GitHubで公開されているリポジトリも、コミット履歴・集計・検索・ダイジェスト・レポートなど、管理者以外に返すすべてのデータから取り除く
+
+パスパラメータ:
+  name string - リポジトリ名（例: "giter"）
+
+リクエストボディ（省略可）:
+  {"reason": "顧客向けの作業リポジトリ"}
+
+レスポンス:
+  成功時: 200 OK, HiddenRepository
+  失敗時: 400 Bad Request（JSON不正）, 422 Unprocessable Entity（リポジトリ名が不正）, 500 Internal Server Error（保存できない）
+
+注意:
+  - リポジトリが存在するかは確認しない（これから作成するリポジトリも隠せる）
+  - 変更するとサーバーで保持したレスポンス（レスポンスのキャッシュ）を破棄する。ブラウザのキャッシュはmax-ageまで残る
+*/
+func putHiddenRepository(c *gin.Context) {
+	name, ok := hiddenRepositoryName(c)
+	if !ok {
+		return
+	}
+	var req hideRepositoryRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	changed, err := repoACL.set(name, true, req.Reason)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save repository ACL")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if changed {
+		clearResponseCache()
+		auditLog.record(c, "repository.hide", name, nil)
+	}
+	repoACL.mu.Lock()
+	hidden := repoACL.Hidden[name]
+	repoACL.mu.Unlock()
+	respondJSON(c, http.StatusOK, hidden)
+}
+
+/*
+deleteHiddenRepository はリポジトリを閲覧者から隠すのをやめるAPIハンドラー（管理者のみ）
+
+パスパラメータ:
+  name string - リポジトリ名
+
+レスポンス:
+  成功時: 204 No Content（隠していなかった場合も同じ）
+  失敗時: 422 Unprocessable Entity（リポジトリ名が不正）, 500 Internal Server Error（保存できない）
+*/
+func deleteHiddenRepository(c *gin.Context) {
+	name, ok := hiddenRepositoryName(c)
+	if !ok {
+		return
+	}
+	changed, err := repoACL.set(name, false, "")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save repository ACL")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if changed {
+		clearResponseCache()
+		auditLog.record(c, "repository.unhide", name, nil)
+	}
+	c.Status(http.StatusNoContent)
+}
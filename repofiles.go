@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+repoFilesMaxEntries は /api/repos/:owner/:repo/files で最終コミットを調べるトップレベルのエントリの最大数
+エントリごとにGitHub APIへ1回リクエストするため、超えた分は名前順で打ち切る
+認証なしのレート制限（60回/時）で1回のリクエストが使い切らないよう、小さく抑える
+*/
+const repoFilesMaxEntries = 20
+
+/*
+gitTree はGit trees APIのレスポンス（トップレベルのエントリのみ使用）
+API仕様: https://docs.github.com/ja/rest/git/trees#get-a-tree
+*/
+type gitTree struct {
+	Tree []struct {
+		Path string `json:"path"` // ファイル・ディレクトリ名
+		Type string `json:"type"` // "blob"（ファイル）、"tree"（ディレクトリ）、"commit"（サブモジュール）
+	} `json:"tree"`
+}
+
+/*
+RepositoryFile はトップレベルのファイル・ディレクトリと、最後に変更したコミット
+*/
+type RepositoryFile struct {
+	Path       string         `json:"path"`        // ファイル・ディレクトリ名
+	Type       string         `json:"type"`        // "file"、"dir"、"submodule"
+	LastCommit *CommitHistory `json:"last_commit"` // 最後に変更したコミット（取得できない場合はnull）
+	DaysSince  *int           `json:"days_since"`  // 最後に変更してからの日数（last_commitがnullの場合はnull）
+}
+
+/*
+RepositoryFiles は /api/repos/:owner/:repo/files のレスポンス
+*/
+type RepositoryFiles struct {
+	Repository string           `json:"repository"` // リポジトリのフルネーム
+	Branch     string           `json:"branch"`     // 対象のブランチ（デフォルトブランチ）
+	Files      []RepositoryFile `json:"files"`      // トップレベルのファイル・ディレクトリ
+	Truncated  bool             `json:"truncated"`  // エントリがrepoFilesMaxEntriesを超え、打ち切った場合はtrue
+}
+
+/* repoFilesQuery は GET /api/repos/:owner/:repo/files のクエリパラメータ */
+type repoFilesQuery struct {
+	Sort string `form:"sort" binding:"omitempty,oneof=stale name"` // 並び順（stale: 最後の変更が古い順、name: 名前順）
+}
+
+/* gitTreeEntryTypes はGit trees APIのエントリの種類とレスポンスの種類の対応 */
+var gitTreeEntryTypes = map[string]string{"blob": "file", "tree": "dir", "commit": "submodule"}
+
+/*
+fetchTopLevelEntries はブランチのトップレベルのファイル・ディレクトリを名前順で返す
+
+引数:
+  repoFullName string - リポジトリのフルネーム
+  branch string - ブランチ名
+
+戻り値:
+  []RepositoryFile - トップレベルのエントリ（LastCommitは未設定）
+  error - 取得に失敗した場合のエラー
+*/
+func fetchTopLevelEntries(repoFullName, branch string) ([]RepositoryFile, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/git/trees/%s", githubAPIBase, repoFullName, url.PathEscape(branch))
+	var tree gitTree
+	if err := fetchGitHubJSON(upstreamOpRepository, apiURL, githubAcceptV3, &tree); err != nil {
+		return nil, err
+	}
+	files := make([]RepositoryFile, 0, len(tree.Tree))
+	for _, entry := range tree.Tree {
+		files = append(files, RepositoryFile{Path: entry.Path, Type: gitTreeEntryTypes[entry.Type]})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+/*
+fetchLastCommitForPath はブランチでパスを最後に変更したコミットを返す（該当するコミットがない場合はnil）
+リポジトリの詳細ページのためのリクエストとして、コミット履歴の同期（history）ではなくstatsの予算から消費する
+*/
+func fetchLastCommitForPath(repoFullName, branch, path string) (*Commit, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/commits?sha=%s&path=%s&per_page=1",
+		githubAPIBase, repoFullName, url.QueryEscape(branch), url.QueryEscape(path))
+	var commits []Commit
+	if err := fetchGitHubJSON(upstreamOpRepository, apiURL, githubAcceptV3, &commits); err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	return &commits[0], nil
+}
+
+/*
+getRepositoryFiles はトップレベルのファイル・ディレクトリごとに、最後に変更したコミットを返すAPIハンドラー
+しばらく変更していない部分（"what's stale"）を一覧するために使用する
+
+パスパラメータ:
+  owner string - 所有者名（usernameのみ）
+  repo string - リポジトリ名
+
+クエリパラメータ:
+  sort - "stale"（デフォルト、最後の変更が古い順）または "name"（名前順）
+
+レスポンス:
+  成功時: 200 OK, RepositoryFiles
+  失敗時: 404 Not Found, 422 Unprocessable Entity（sortが不正）, 502 Bad Gateway
+
+注意:
+  - エントリごとにGitHub APIのcommits?path=を呼び出す（syncWorkers個まで並行、githubGetのキャッシュを経由する）
+  - レスポンスはキャッシュの方針（defaultCachePolicies）に従ってサーバーで保持する
+  - 空のリポジトリはGit trees APIが失敗するため502を返す
+*/
+func getRepositoryFiles(c *gin.Context) {
+	query := repoFilesQuery{Sort: "stale"}
+	if !bindQuery(c, &query) {
+		return
+	}
+	fullName, err := repoFullNameParam(c)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	detail, err := fetchRepository(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	files, err := fetchTopLevelEntries(fullName, detail.DefaultBranch)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+
+	resp := RepositoryFiles{Repository: fullName, Branch: detail.DefaultBranch}
+	if len(files) > repoFilesMaxEntries {
+		files, resp.Truncated = files[:repoFilesMaxEntries], true
+	}
+
+	errs := make([]error, len(files))
+	now := time.Now()
+	runConcurrently(len(files), func(i int) {
+		commit, err := fetchLastCommitForPath(fullName, detail.DefaultBranch, files[i].Path)
+		if err != nil || commit == nil {
+			errs[i] = err
+			return
+		}
+		history := newCommitHistory(detail.Name, *commit)
+		days := int(now.Sub(history.CommitTime).Hours() / 24)
+		files[i].LastCommit, files[i].DaysSince = &history, &days
+	})
+	for _, err := range errs {
+		if err != nil {
+			respondRepositoryError(c, err)
+			return
+		}
+	}
+
+	redact := requestRedaction(c)
+	for i := range files {
+		if files[i].LastCommit != nil {
+			files[i].LastCommit = &redact.commits([]CommitHistory{*files[i].LastCommit})[0]
+		}
+	}
+	if query.Sort == "stale" {
+		/* 最後の変更が古い順（コミットが見つからないエントリは末尾） */
+		sort.SliceStable(files, func(i, j int) bool {
+			a, b := files[i].LastCommit, files[j].LastCommit
+			if a == nil || b == nil {
+				return a != nil
+			}
+			return a.CommitTime.Before(b.CommitTime)
+		})
+	}
+	resp.Files = files
+	respondJSON(c, http.StatusOK, resp)
+}
+
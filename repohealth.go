@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	healthComponentRecency   = "recency"   // 最終コミットからの経過日数
+	healthComponentFrequency = "frequency" // 直近30日間のコミット数
+	healthComponentIssues    = "issues"    // Issueへの対応の速さ
+	healthComponentCI        = "ci"        // デフォルトブランチのCIの結果
+)
+
+/*
+defaultHealthWeights はヘルススコアの要素ごとの重み
+環境変数 HEALTH_SCORE_WEIGHTS で上書きできる（例: "recency=40,frequency=30,issues=20,ci=10"）
+指定しなかった要素はデフォルトの重みのまま
+*/
+var defaultHealthWeights = map[string]float64{
+	healthComponentRecency:   40,
+	healthComponentFrequency: 30,
+	healthComponentIssues:    20,
+	healthComponentCI:        10,
+}
+
+var (
+	/* healthWeights は適用するヘルススコアの重み */
+	healthWeights = parseHealthWeights(getEnv("HEALTH_SCORE_WEIGHTS", ""))
+	/* healthRecencyDays は最終コミットからこの日数が経過するとrecencyが0になる（HEALTH_RECENCY_DAYS） */
+	healthRecencyDays = getEnvInt("HEALTH_RECENCY_DAYS", 30)
+	/* healthWeeklyCommits はfrequencyが満点になる週あたりのコミット数（HEALTH_WEEKLY_COMMITS） */
+	healthWeeklyCommits = getEnvInt("HEALTH_WEEKLY_COMMITS", 3)
+	/* healthIssueResponseDays はIssueへの対応が速いとみなすクローズまでの日数（HEALTH_ISSUE_RESPONSE_DAYS） */
+	healthIssueResponseDays = getEnvInt("HEALTH_ISSUE_RESPONSE_DAYS", 7)
+)
+
+/*
+parseHealthWeights は "要素=重み" のカンマ区切りのリストを読み込む
+未知の要素名・負の値・数値でない値は警告を出して無視する
+*/
+func parseHealthWeights(raw string) map[string]float64 {
+	weights := map[string]float64{}
+	for name, w := range defaultHealthWeights {
+		weights[name] = w
+	}
+	for _, item := range splitList(raw) {
+		name, value, _ := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if _, ok := weights[name]; !ok || err != nil || w < 0 {
+			log.Warn().Str("weight", item).Msg("Ignoring invalid HEALTH_SCORE_WEIGHTS entry")
+			continue
+		}
+		weights[name] = w
+	}
+	return weights
+}
+
+/*
+HealthComponent はヘルススコアの1つの要素の評価
+*/
+type HealthComponent struct {
+	Score  *float64 `json:"score"`  // 0〜1の評価（データがない場合はnullで、総合スコアの計算から除外する）
+	Weight float64  `json:"weight"` // 重み
+	Detail string   `json:"detail"` // 評価の根拠
+}
+
+/*
+RepositoryHealth は1リポジトリのヘルススコア
+*/
+type RepositoryHealth struct {
+	Repo       string                     `json:"repo"`       // リポジトリ名
+	Score      float64                    `json:"score"`      // 総合スコア（0〜100、評価できた要素の重み付き平均）
+	Components map[string]HealthComponent `json:"components"` // 要素ごとの評価
+}
+
+/* HealthScoresResponse は /api/stats/health のレスポンス */
+type HealthScoresResponse struct {
+	GeneratedAt  time.Time          `json:"generated_at"` // 集計日時
+	Weights      map[string]float64 `json:"weights"`      // 適用した重み
+	Repositories []RepositoryHealth `json:"repositories"` // スコアの高い順
+}
+
+/*
+CheckRun はGitHub APIから取得するCIのチェック結果
+API仕様: https://docs.github.com/ja/rest/checks/runs#list-check-runs-for-a-git-reference
+*/
+type CheckRun struct {
+	Name       string `json:"name"`       // チェック名
+	Status     string `json:"status"`     // "queued", "in_progress", "completed"
+	Conclusion string `json:"conclusion"` // 完了時の結果（"success", "failure" など）
+}
+
+/*
+fetchCheckRuns は指定したブランチ（またはコミット）のCIのチェック結果を取得する
+
+引数:
+  repoFullName string - リポジトリのフルネーム
+  ref string - ブランチ名またはコミットSHA
+*/
+func fetchCheckRuns(repoFullName, ref string) ([]CheckRun, error) {
+	var resp struct {
+		CheckRuns []CheckRun `json:"check_runs"`
+	}
+	url := fmt.Sprintf("%s/repos/%s/commits/%s/check-runs?per_page=100", githubAPIBase, repoFullName, ref)
+	if err := fetchGitHubJSON(upstreamOpRepository, url, githubAcceptV3, &resp); err != nil {
+		return nil, err
+	}
+	return resp.CheckRuns, nil
+}
+
+/*
+scoreRecency は最終コミットからの経過日数を評価する
+経過0日で1、healthRecencyDays日以上で0の線形
+*/
+func scoreRecency(commits []Commit, now time.Time) HealthComponent {
+	var latest time.Time
+	for _, commit := range commits {
+		if commit.Commit.Author.Date.After(latest) {
+			latest = commit.Commit.Author.Date
+		}
+	}
+	if latest.IsZero() {
+		score := 0.0
+		return HealthComponent{Score: &score, Detail: "コミットがありません"}
+	}
+	days := now.Sub(latest).Hours() / 24
+	score := math.Round(math.Max(0, 1-days/float64(healthRecencyDays))*100) / 100
+	return HealthComponent{Score: &score, Detail: fmt.Sprintf("最終コミットから%.0f日", days)}
+}
+
+/* scoreFrequency は直近30日間のコミット数を、週healthWeeklyCommits件を満点として評価する */
+func scoreFrequency(commits []Commit, now time.Time) HealthComponent {
+	since := now.AddDate(0, 0, -30)
+	count := 0
+	for _, commit := range commits {
+		if commit.Commit.Author.Date.After(since) {
+			count++
+		}
+	}
+	target := float64(healthWeeklyCommits) * 30 / 7
+	score := 1.0
+	if target > 0 {
+		score = math.Round(math.Min(1, float64(count)/target)*100) / 100
+	}
+	return HealthComponent{Score: &score, Detail: fmt.Sprintf("直近30日間のコミット %d 件", count)}
+}
+
+/*
+scoreIssues はIssueのうち、作成からhealthIssueResponseDays日以内にクローズしたものの割合を評価する
+オープン中で作成からhealthIssueResponseDays日未満のIssueはまだ判断できないため除外する
+*/
+func scoreIssues(issues []Issue, now time.Time) HealthComponent {
+	limit := time.Duration(healthIssueResponseDays) * 24 * time.Hour
+	responsive, total := 0, 0
+	for _, issue := range issues {
+		if issue.ClosedAt != nil {
+			total++
+			if issue.ClosedAt.Sub(issue.CreatedAt) <= limit {
+				responsive++
+			}
+			continue
+		}
+		if now.Sub(issue.CreatedAt) > limit {
+			total++
+		}
+	}
+	if total == 0 {
+		return HealthComponent{Detail: "評価できるIssueがありません"}
+	}
+	score := math.Round(float64(responsive)/float64(total)*100) / 100
+	return HealthComponent{Score: &score, Detail: fmt.Sprintf("%d 件中 %d 件を%d日以内にクローズ", total, responsive, healthIssueResponseDays)}
+}
+
+/* scoreCI はデフォルトブランチのCIの結果を評価する（失敗を含む場合は0、実行中を含む場合は0.5、すべて成功なら1） */
+func scoreCI(runs []CheckRun) HealthComponent {
+	if len(runs) == 0 {
+		return HealthComponent{Detail: "CIのチェックがありません"}
+	}
+	score := 1.0
+	detail := "すべてのチェックが成功"
+	for _, run := range runs {
+		if run.Status != "completed" {
+			score = 0.5
+			detail = fmt.Sprintf("%s を実行中", run.Name)
+			continue
+		}
+		switch run.Conclusion {
+		case "success", "neutral", "skipped":
+		default:
+			failed := 0.0
+			return HealthComponent{Score: &failed, Detail: fmt.Sprintf("%s が %s", run.Name, run.Conclusion)}
+		}
+	}
+	return HealthComponent{Score: &score, Detail: detail}
+}
+
+/*
+repositoryHealth はリポジトリのヘルススコアを計算する
+データがない要素は除外し、残りの要素の重みで加重平均する
+*/
+func repositoryHealth(repo string, components map[string]HealthComponent) RepositoryHealth {
+	var sum, weights float64
+	for name, component := range components {
+		component.Weight = healthWeights[name]
+		components[name] = component
+		if component.Score == nil {
+			continue
+		}
+		sum += *component.Score * component.Weight
+		weights += component.Weight
+	}
+	score := 0.0
+	if weights > 0 {
+		score = math.Round(sum/weights*1000) / 10
+	}
+	return RepositoryHealth{Repo: repo, Score: score, Components: components}
+}
+
+/*
+getHealthScores はリポジトリごとのヘルススコアを返すAPIハンドラー
+
+クエリパラメータ:
+  repo string - リポジトリ名で絞り込む（省略時は全リポジトリ）
+
+レスポンス:
+  成功時: 200 OK, HealthScoresResponse
+  失敗時: 502 Bad Gateway（GitHubからリポジトリ一覧を取得できない場合）
+
+注意:
+  - リポジトリごとにコミット・Issue・リポジトリ詳細・CIのチェック結果を取得する
+    （githubGetのキャッシュを経由するが、初回はリポジトリ数の4倍のリクエストが発生する）
+  - Issue・CIの取得に失敗した要素はデータなしとして扱い、スコアの計算から除外する
+*/
+func getHealthScores(c *gin.Context) {
+	var params struct {
+		Repo string `form:"repo"`
+	}
+	if !bindQuery(c, &params) {
+		return
+	}
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for health scores")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if name := params.Repo; name != "" {
+		var filtered []Repository
+		for _, repo := range repos {
+			if repo.Name == name {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+
+	now := time.Now()
+	results := make([]RepositoryHealth, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		repo := repos[i]
+		components := map[string]HealthComponent{}
+
+		if commits, err := fetchCommits(repo.FullName); err == nil {
+			components[healthComponentRecency] = scoreRecency(commits, now)
+			components[healthComponentFrequency] = scoreFrequency(commits, now)
+		} else {
+			components[healthComponentRecency] = HealthComponent{Detail: "コミットを取得できませんでした"}
+			components[healthComponentFrequency] = HealthComponent{Detail: "コミットを取得できませんでした"}
+		}
+
+		if issues, err := fetchIssues(repo.FullName); err == nil {
+			components[healthComponentIssues] = scoreIssues(issues, now)
+		} else {
+			components[healthComponentIssues] = HealthComponent{Detail: "Issueを取得できませんでした"}
+		}
+
+		components[healthComponentCI] = HealthComponent{Detail: "CIの結果を取得できませんでした"}
+		if detail, err := fetchRepository(repo.FullName); err == nil && detail.DefaultBranch != "" {
+			if runs, err := fetchCheckRuns(repo.FullName, detail.DefaultBranch); err == nil {
+				components[healthComponentCI] = scoreCI(runs)
+			}
+		}
+
+		results[i] = repositoryHealth(repo.Name, components)
+	})
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	respondJSON(c, http.StatusOK, HealthScoresResponse{GeneratedAt: now, Weights: healthWeights, Repositories: results})
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* reportsTable は作成したレポートの情報を保存するテーブル名（PDFはBlobStoreに保存する） */
+	reportsTable = "reports"
+	/* reportBlobPrefix はBlobStoreにレポートのPDFを保存する際のキーの接頭辞 */
+	reportBlobPrefix = "reports/"
+	/* reportTopRepos はレポートのリポジトリ別の表に載せるリポジトリ数 */
+	reportTopRepos = 20
+)
+
+/* レポートに含めるセクション（指定した順に描画する） */
+const (
+	reportSectionSummary      = "summary"      // コミット数・活動日数などの概要
+	reportSectionRepositories = "repositories" // リポジトリ別のコミット数
+	reportSectionDaily        = "daily"        // 日ごとのコミット数
+	reportSectionLabels       = "labels"       // LABEL_RULESのラベル別のコミット数
+)
+
+/* reportSections はすべてのセクション（sectionsを省略した場合の既定値） */
+var reportSections = []string{reportSectionSummary, reportSectionRepositories, reportSectionDaily, reportSectionLabels}
+
+/* reportMonthPattern は月のレポートの期間の形式（"2025-09"） */
+var reportMonthPattern = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+
+/* errReportNotFound はレポートが存在しない、または閲覧できない場合のエラー */
+var errReportNotFound = errors.New("report not found")
+
+var (
+	/*
+		reportMonthly は毎月1日に前月のレポートを自動で作成するかどうか
+		環境変数 REPORT_MONTHLY で変更可能（デフォルト: false）
+	*/
+	reportMonthly = getEnvBool("REPORT_MONTHLY", false)
+	/*
+		reportMonthlyTemplate は自動で作成するレポートに使用するテンプレート名
+		環境変数 REPORT_MONTHLY_TEMPLATE で変更可能（デフォルト: 空文字、すべてのセクションを含める）
+	*/
+	reportMonthlyTemplate = getEnv("REPORT_MONTHLY_TEMPLATE", "")
+)
+
+/*
+Report は作成したレポートの情報
+*/
+type Report struct {
+	ID        string    `json:"id"`                  // レポートID
+	Title     string    `json:"title"`               // タイトル（PDFの見出し）
+	Period    string    `json:"period"`              // 期間（"2025-09" または "2025-W38"）
+	From      time.Time `json:"from"`                // 期間の開始日時
+	To        time.Time `json:"to"`                  // 期間の終了日時（この日時を含まない）
+	Sections  []string  `json:"sections"`            // 含めたセクション（テンプレートの場合は空）
+	Template  string    `json:"template,omitempty"`  // 使用したレポートのテンプレート名
+	Commits   int       `json:"commits"`             // 期間のコミット数
+	Truncated bool      `json:"truncated,omitempty"` // 取得ページ数の上限に達したリポジトリがある
+	Size      int       `json:"size"`                // PDFのバイト数
+	Scheduled bool      `json:"scheduled"`           // REPORT_MONTHLYで自動作成したかどうか
+	CreatedAt time.Time `json:"created_at"`          // 作成日時
+	/* Private はプライベートリポジトリのデータを含めて作成したかどうか（匿名の閲覧者には返さない） */
+	Private bool `json:"private"`
+}
+
+/*
+reportStore は作成したレポートの情報を保持するストア
+reportsテーブルに永続化される
+*/
+type reportStore struct {
+	mu sync.Mutex
+	/* Reports は作成日時の古い順のレポート */
+	Reports []Report `json:"reports"`
+}
+
+/* reports はアプリケーション全体で共有するレポートのストア */
+var reports = &reportStore{Reports: []Report{}}
+
+func init() {
+	registerTable(reportsTable, loadReports)
+}
+
+/*
+loadReports はreportsテーブルからレポートの情報を復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadReports() error {
+	reports.mu.Lock()
+	defer reports.mu.Unlock()
+
+	reports.Reports = nil
+	if err := loadTable(reportsTable, reports); err != nil {
+		return err
+	}
+	if reports.Reports == nil {
+		reports.Reports = []Report{}
+	}
+	return nil
+}
+
+/* list はデータの範囲に含まれるレポートを新しい順に返す */
+func (s *reportStore) list(v visibility) []Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Report, 0, len(s.Reports))
+	for i := len(s.Reports) - 1; i >= 0; i-- {
+		if s.Reports[i].Private && v < visibilityAll {
+			continue
+		}
+		list = append(list, s.Reports[i])
+	}
+	return list
+}
+
+/* get はIDのレポートを返す（データの範囲に含まれない場合はfalse） */
+func (s *reportStore) get(id string, v visibility) (Report, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, report := range s.Reports {
+		if report.ID == id {
+			return report, !report.Private || v >= visibilityAll
+		}
+	}
+	return Report{}, false
+}
+
+/* scheduledExists は期間のレポートをREPORT_MONTHLYで作成済みかどうかを返す */
+func (s *reportStore) scheduledExists(period string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, report := range s.Reports {
+		if report.Scheduled && report.Period == period {
+			return true
+		}
+	}
+	return false
+}
+
+/* add はレポートを追加して保存する */
+func (s *reportStore) add(report Report) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Reports = append(s.Reports, report)
+	if err := saveTable(reportsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save reports")
+	}
+}
+
+/*
+purge はすべてのレポートの情報とBlobStoreに保存したPDFを削除し、削除したレポート数を返す（DELETE /api/admin/data で使用）
+PDFを削除できなかった場合はログを出力して続ける
+*/
+func (s *reportStore) purge() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, report := range s.Reports {
+		if err := blobs.Delete(reportBlobPrefix + report.ID + ".pdf"); err != nil && !errors.Is(err, errBlobNotFound) {
+			log.Error().Err(err).Str("report", report.ID).Msg("Failed to delete report PDF")
+		}
+	}
+	n := len(s.Reports)
+	s.Reports = []Report{}
+	if err := saveTable(reportsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save reports")
+	}
+	return n
+}
+
+/*
+parseReportPeriod はレポートの期間を開始・終了日時に変換する
+
+引数:
+  period string - 月（"2025-09"）またはISO 8601の週（"2025-W38"）
+
+戻り値:
+  time.Time - 期間の開始日時（statsLocationの0時）
+  time.Time - 期間の終了日時（この日時を含まない）
+  error - 形式が不正な場合
+*/
+func parseReportPeriod(period string) (time.Time, time.Time, error) {
+	if m := reportMonthPattern.FindStringSubmatch(period); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		if month < 1 || month > 12 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid month: %s", period)
+		}
+		start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, statsLocation)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	start, err := parseISOWeek(period, statsLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.AddDate(0, 0, 7), nil
+}
+
+/*
+reportRequest は POST /api/reports のリクエストボディ
+*/
+type reportRequest struct {
+	Period   string   `json:"period" binding:"required"` // 期間（"2025-09" または "2025-W38"）
+	Sections []string `json:"sections"`                  // 含めるセクション（省略時はすべて）
+	Template string   `json:"template"`                  // レポートのテンプレート名（指定時はsectionsの代わりにテンプレートで描画する）
+	Title    string   `json:"title" binding:"max=200"`   // タイトル（省略時は "Activity report <期間>"）
+}
+
+/* validateFields は期間・セクション・テンプレート・タイトルを検証する */
+func (req *reportRequest) validateFields() []FieldError {
+	var fields []FieldError
+	if req.Period != "" {
+		if _, _, err := parseReportPeriod(req.Period); err != nil {
+			fields = append(fields, FieldError{Field: "period", Rule: "period", Message: "must be a month (2025-09) or an ISO week (2025-W38)"})
+		}
+	}
+	for _, section := range req.Sections {
+		if !containsString(reportSections, section) {
+			fields = append(fields, FieldError{Field: "sections", Rule: "oneof", Message: "unknown section: " + section})
+		}
+	}
+	/* PDFの標準フォントはLatin-1のみのため、それ以外の文字は描画できない（pdfEscapeで "?" になる） */
+	if strings.IndexFunc(req.Title, func(r rune) bool { return r > 0xff }) >= 0 {
+		fields = append(fields, FieldError{Field: "title", Rule: "latin1", Message: "must only contain Latin-1 characters"})
+	}
+	if req.Template != "" {
+		if len(req.Sections) > 0 {
+			fields = append(fields, FieldError{Field: "sections", Rule: "excluded_with", Message: "cannot be combined with template"})
+		}
+		if _, _, ok := reportTemplateSource(req.Template); !ok {
+			fields = append(fields, FieldError{Field: "template", Rule: "exists", Message: "unknown report template"})
+		}
+	}
+	return fields
+}
+
+/* reportData はレポートに描画する集計結果 */
+type reportData struct {
+	Commits      int
+	ActiveDays   int
+	Repositories []RepoCommitCount
+	Daily        []DailyCommitCount
+	Labels       LabelStatsResponse
+	Truncated    bool
+}
+
+/*
+collectReportData は期間のコミットを取得して集計する
+buildYearInReviewと同様に、各リポジトリの期間内のコミットをGitHubから取得する（1リポジトリあたりwrappedMaxPagesまで）
+*/
+func collectReportData(from, to time.Time, v visibility) (reportData, error) {
+	data := reportData{Repositories: []RepoCommitCount{}, Daily: []DailyCommitCount{}}
+	repos, err := fetchVisibleRepositories(v)
+	if err != nil {
+		return data, err
+	}
+
+	results := make([][]Commit, len(repos))
+	truncated := make([]bool, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		commits, more, err := fetchCommitsInRange(repos[i].FullName, from, to, wrappedMaxPages)
+		if err != nil {
+			log.Warn().Err(err).Str("repository", repos[i].Name).Msg("Failed to fetch commits for report")
+			return
+		}
+		results[i], truncated[i] = commits, more
+	})
+
+	var commits []CommitHistory
+	for i, repo := range repos {
+		data.Truncated = data.Truncated || truncated[i]
+		repoCommits := 0
+		for _, c := range results[i] {
+			commit := newCommitHistory(repo.Name, c)
+			if commit.CommitTime.Before(from) || !commit.CommitTime.Before(to) {
+				continue
+			}
+			commits = append(commits, commit)
+			repoCommits++
+		}
+		if repoCommits > 0 {
+			data.Repositories = append(data.Repositories, RepoCommitCount{Repo: repo.Name, Commits: repoCommits})
+		}
+	}
+	sort.Slice(data.Repositories, func(i, j int) bool {
+		a, b := data.Repositories[i], data.Repositories[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Repo < b.Repo
+	})
+
+	counts := dailyCommitCounts(commits)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(statsDateFormat)
+		data.Daily = append(data.Daily, DailyCommitCount{Date: date, Commits: counts[date]})
+	}
+	data.Commits = len(commits)
+	data.ActiveDays = len(counts)
+	data.Labels = labelStats(labelCommits(commits))
+	return data, nil
+}
+
+/* reportPDF はレポートのPDFを描画する際の現在の位置を保持する */
+type reportPDF struct {
+	doc *pdfDocument
+	y   float64
+}
+
+const (
+	reportMargin     = 50.0  // 左右・上下の余白
+	reportLineHeight = 16.0  // 表の1行の高さ
+	reportBarWidth   = 240.0 // 値が最大の行の棒の長さ
+)
+
+/* ensure は残りの高さがheightに満たない場合に改ページする */
+func (p *reportPDF) ensure(height float64) {
+	if p.y+height > pdfPageHeight-reportMargin {
+		p.doc.addPage()
+		p.y = reportMargin
+	}
+}
+
+/* heading はセクションの見出しを描画する */
+func (p *reportPDF) heading(title string) {
+	p.ensure(3 * reportLineHeight)
+	p.y += reportLineHeight
+	p.doc.text(reportMargin, p.y, pdfFontBold, 14, title)
+	p.y += 6
+	p.doc.line(reportMargin, p.y, pdfPageWidth-reportMargin, p.y)
+	p.y += reportLineHeight
+}
+
+/* row はラベルと値の1行を描画する（peakが0より大きい場合はvalue/peakの長さの棒を添える） */
+func (p *reportPDF) row(label string, value, peak int) {
+	p.ensure(reportLineHeight)
+	p.doc.text(reportMargin, p.y, pdfFontRegular, 10, label)
+	p.doc.text(reportMargin+180, p.y, pdfFontRegular, 10, strconv.Itoa(value))
+	if peak > 0 && value > 0 {
+		p.doc.rect(reportMargin+220, p.y-8, reportBarWidth*float64(value)/float64(peak), 9, 0.55)
+	}
+	p.y += reportLineHeight
+}
+
+/* newReportPDF はタイトル・期間・作成日時を描画したレポートのPDFを作成する */
+func newReportPDF(report Report) *reportPDF {
+	p := &reportPDF{doc: newPDFDocument(), y: reportMargin + 10}
+	p.doc.text(reportMargin, p.y, pdfFontBold, 20, report.Title)
+	p.y += 20
+	p.doc.text(reportMargin, p.y, pdfFontRegular, 10, fmt.Sprintf("%s - %s (%s)",
+		report.From.Format(statsDateFormat), report.To.AddDate(0, 0, -1).Format(statsDateFormat), statsLocation.String()))
+	p.y += 14
+	p.doc.text(reportMargin, p.y, pdfFontRegular, 8, "Generated by Giter at "+report.CreatedAt.Format(time.RFC3339))
+	p.y += reportLineHeight
+	return p
+}
+
+/* renderReportPDF はレポートのPDFを作成する（テンプレートの場合はrenderTemplateReportPDF） */
+func renderReportPDF(report Report, data reportData) []byte {
+	p := newReportPDF(report)
+	for _, section := range report.Sections {
+		switch section {
+		case reportSectionSummary:
+			p.heading("Summary")
+			p.row("Commits", data.Commits, 0)
+			p.row("Active days", data.ActiveDays, 0)
+			p.row("Active repositories", len(data.Repositories), 0)
+			if data.Truncated {
+				p.ensure(reportLineHeight)
+				p.doc.text(reportMargin, p.y, pdfFontRegular, 8, "Some repositories had more commits than could be fetched; counts may be incomplete.")
+				p.y += reportLineHeight
+			}
+		case reportSectionRepositories:
+			p.heading("Commits by repository")
+			repos := data.Repositories
+			if len(repos) > reportTopRepos {
+				repos = repos[:reportTopRepos]
+			}
+			for _, repo := range repos {
+				p.row(repo.Repo, repo.Commits, data.Repositories[0].Commits)
+			}
+			if len(data.Repositories) == 0 {
+				p.doc.text(reportMargin, p.y, pdfFontRegular, 10, "No commits in this period.")
+				p.y += reportLineHeight
+			}
+		case reportSectionDaily:
+			p.heading("Commits by day")
+			peak := 0
+			for _, day := range data.Daily {
+				peak = max(peak, day.Commits)
+			}
+			for _, day := range data.Daily {
+				p.row(day.Date, day.Commits, peak)
+			}
+		case reportSectionLabels:
+			p.heading("Commits by label")
+			peak := data.Labels.Unlabeled
+			if len(data.Labels.Labels) > 0 {
+				peak = max(peak, data.Labels.Labels[0].Commits)
+			}
+			for _, label := range data.Labels.Labels {
+				p.row(label.Label, label.Commits, peak)
+			}
+			p.row("(unlabeled)", data.Labels.Unlabeled, peak)
+		}
+	}
+	return p.doc.bytes()
+}
+
+/*
+generateReport は期間のコミットを集計してレポートを作成し、PDFをBlobStoreに保存する
+
+引数:
+  report Report - 作成するレポートの期間（Period）・タイトル・セクション・テンプレート名など
+  v visibility - 集計するデータの範囲
+
+戻り値:
+  Report - 作成したレポート
+  error - 期間が不正、GitHubから取得できない、テンプレートを実行できない、またはPDFを保存できない場合
+*/
+func generateReport(report Report, v visibility) (Report, error) {
+	from, to, err := parseReportPeriod(report.Period)
+	if err != nil {
+		return Report{}, err
+	}
+	data, err := collectReportData(from, to, v)
+	if err != nil {
+		return Report{}, err
+	}
+	report.From, report.To, report.Private = from, to, v >= visibilityAll
+	return storeReport(report, data)
+}
+
+/* storeReport は集計結果からPDFを作成してBlobStoreに保存し、レポートの情報を追加する */
+func storeReport(report Report, data reportData) (Report, error) {
+	report.ID = newSessionID()
+	report.CreatedAt = time.Now().UTC()
+	report.Commits = data.Commits
+	report.Truncated = data.Truncated
+	switch {
+	case report.Template != "":
+		report.Sections = []string{}
+	case len(report.Sections) == 0:
+		report.Sections = reportSections
+	}
+	if report.Title == "" {
+		report.Title = "Activity report " + report.Period
+	}
+
+	var pdf []byte
+	if report.Template != "" {
+		var err error
+		if pdf, err = renderTemplateReportPDF(report, data); err != nil {
+			return Report{}, err
+		}
+	} else {
+		pdf = renderReportPDF(report, data)
+	}
+	report.Size = len(pdf)
+	if err := blobs.Put(reportBlobPrefix+report.ID+".pdf", pdf, "application/pdf"); err != nil {
+		return Report{}, err
+	}
+	reports.add(report)
+	log.Info().Str("report", report.ID).Str("period", report.Period).Int("commits", report.Commits).Int("size", report.Size).Msg("Report generated")
+	return report, nil
+}
+
+/*
+getReports は作成したレポートの一覧を返すAPIハンドラー
+
+レスポンス:
+  200 OK, {"reports": [Report（新しい順）]}
+
+注意:
+  - プライベートリポジトリのデータを含むレポートは、PRIVACY_MODEで匿名の閲覧者には返さない
+*/
+func getReports(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{"reports": reports.list(requestVisibility(c))})
+}
+
+/*
+postReport はレポートを作成する管理者APIハンドラー
+期間のコミットをGitHubから取得して集計し、PDFをBlobStoreに保存する
+
+リクエストボディ:
+  {"period": "2025-09", "sections": ["summary", "repositories", "daily", "labels"], "title": "September report"}
+  period のみ必須（月 "2025-09" またはISO 8601の週 "2025-W38"）
+  sections の代わりに "template": "client-monthly" でレポートのテンプレート（reporttemplates.goを参照）を指定できる
+
+レスポンス:
+  成功時: 201 Created, Report（PDFは GET /api/reports/:id でダウンロードする）
+  失敗時: 400 Bad Request（不正なJSON）, 422 Unprocessable Entity（期間・セクション・テンプレート・タイトルの不正）,
+          502 Bad Gateway（GitHubから取得できない）, 500 Internal Server Error（テンプレートを実行できない、PDFを保存できない）
+*/
+func postReport(c *gin.Context) {
+	var req reportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	/* レポートは他の閲覧者も閲覧するため、閲覧者から隠すリポジトリは含めない */
+	v := requestVisibility(c).shared()
+	/* validateFieldsで読み込めることを確認済み */
+	from, to, _ := parseReportPeriod(req.Period)
+	data, err := collectReportData(from, to, v)
+	if err != nil {
+		log.Error().Err(err).Str("period", req.Period).Msg("Failed to fetch commits for report")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	report, err := storeReport(Report{Title: req.Title, Period: req.Period, From: from, To: to, Sections: req.Sections, Template: req.Template, Private: v >= visibilityAll}, data)
+	if err != nil {
+		log.Error().Err(err).Str("period", req.Period).Msg("Failed to store report")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	auditLog.record(c, "report.create", report.ID, map[string]int{"commits": report.Commits})
+	respondJSON(c, http.StatusCreated, report)
+}
+
+/*
+downloadReport はレポートのPDFをダウンロードさせるAPIハンドラー
+
+パスパラメータ:
+  id string - レポートID
+
+レスポンス:
+  成功時: 200 OK, application/pdf（Content-Dispositionでファイル名を指定）
+  失敗時: 404 Not Found（レポートがない、またはプライベートリポジトリを含むレポートを匿名の閲覧者が要求した）
+*/
+func downloadReport(c *gin.Context) {
+	report, ok := reports.get(c.Param("id"), requestVisibility(c))
+	if !ok {
+		respondError(c, http.StatusNotFound, errReportNotFound.Error())
+		return
+	}
+	data, err := blobs.Get(reportBlobPrefix + report.ID + ".pdf")
+	if errors.Is(err, errBlobNotFound) {
+		respondError(c, http.StatusNotFound, errReportNotFound.Error())
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="giter-report-%s.pdf"`, report.Period))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+/*
+startReportScheduler は毎月1日に前月のレポートを作成するゴルーチンを起動する
+1時間ごとに確認し、前月のレポートを作成済みでない場合に作成する（REPORT_MONTHLYがfalseの場合は何もしない）
+
+注意:
+  - 集計はbackgroundVisibility（PRIVACY_MODEの場合は公開リポジトリのみ）で行う
+  - REPORT_MONTHLY_TEMPLATE を指定した場合はそのテンプレートで描画する
+*/
+func startReportScheduler() {
+	if !reportMonthly {
+		return
+	}
+	if _, _, ok := reportTemplateSource(reportMonthlyTemplate); reportMonthlyTemplate != "" && !ok {
+		log.Warn().Str("template", reportMonthlyTemplate).Msg("REPORT_MONTHLY_TEMPLATE not found; scheduled reports will fail until it is added")
+	}
+	log.Info().Msg("Monthly report scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			now := time.Now().In(statsLocation)
+			period := now.AddDate(0, 0, -now.Day()).Format("2006-01")
+			if now.Day() != 1 || reports.scheduledExists(period) || pausedForMaintenance(lockNameReport) {
+				continue
+			}
+			runExclusive(lockNameReport, func() {
+				/* 他のインスタンスがロックを保持している間に作成した場合 */
+				if err := loadReports(); err != nil || reports.scheduledExists(period) {
+					return
+				}
+				if _, err := generateReport(Report{Period: period, Template: reportMonthlyTemplate, Scheduled: true}, backgroundVisibility()); err != nil {
+					log.Error().Err(err).Str("period", period).Msg("Scheduled report failed")
+				}
+			})
+		}
+	}()
+}
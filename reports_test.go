@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestReportRequestTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		valid bool
+	}{
+		{"", true},
+		{"September report", true},
+		{"Café review (Q3)", true},
+		{"9月のレポート", false},
+	}
+	for _, tt := range tests {
+		req := reportRequest{Period: "2025-09", Title: tt.title}
+		if fields := req.validateFields(); (len(fields) == 0) != tt.valid {
+			t.Errorf("title %q: fields = %+v, want valid = %v", tt.title, fields, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+レポートのテンプレート
+レポートのPDFの内容をGoのテンプレート（text/template）で定義する。テンプレートは次のいずれかに置く
+  - REPORT_TEMPLATE_DIR のディレクトリの "<名前>.tmpl"（作成のたびに読み込むため、変更に再起動は不要）
+  - PUT /api/admin/report-templates/:name でアップロードしたもの（report_templatesテーブル、同じ名前の場合はこちらを優先する）
+
+テンプレートの出力は1行ごとに次のように描画する
+  - "# 見出し": セクションの見出し
+  - "row ラベル | 値": ラベルと値の行
+  - "bar ラベル | 値 | 最大値": 値/最大値の長さの棒を添えた行
+  - 空行: 少し間隔を空ける
+  - それ以外: 本文の行
+
+例:
+  # Commits by repository
+  {{$repos := query "repositories"}}{{range $repos}}bar {{.Repo}} | {{.Commits}} | {{peak $repos}}
+  {{end}}
+*/
+
+const (
+	/* reportTemplatesTable はアップロードしたレポートのテンプレートを保存するテーブル名 */
+	reportTemplatesTable = "report_templates"
+	/* reportTemplateExt はREPORT_TEMPLATE_DIRに置くテンプレートのファイルの拡張子 */
+	reportTemplateExt = ".tmpl"
+)
+
+/* テンプレートの置き場所 */
+const (
+	reportTemplateOriginUploaded = "uploaded" // APIでアップロードしたもの
+	reportTemplateOriginFile     = "file"     // REPORT_TEMPLATE_DIRのファイル
+)
+
+/*
+reportTemplateDir はレポートのテンプレートのファイルを置くディレクトリ
+環境変数 REPORT_TEMPLATE_DIR で変更可能（デフォルト: "report-templates"）
+*/
+var reportTemplateDir = getEnv("REPORT_TEMPLATE_DIR", "report-templates")
+
+/* reportTemplateNamePattern はテンプレート名の形式（ファイル名に使用するため英小文字・数字・ハイフン・アンダースコアのみ） */
+var reportTemplateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+/* errReportTemplateNotFound はテンプレートが存在しない場合のエラー */
+var errReportTemplateNotFound = errors.New("report template not found")
+
+/*
+ReportTemplate はレポートのテンプレート
+*/
+type ReportTemplate struct {
+	Name      string    `json:"name"`             // テンプレート名
+	Origin    string    `json:"origin"`           // 置き場所（uploaded または file）
+	Source    string    `json:"source,omitempty"` // テンプレートの内容（一覧では省略する）
+	UpdatedAt time.Time `json:"updated_at"`       // 更新日時（fileの場合はファイルの更新日時）
+}
+
+/*
+reportTemplateStore はアップロードしたレポートのテンプレートを保持するストア
+report_templatesテーブルに永続化される
+*/
+type reportTemplateStore struct {
+	mu sync.Mutex
+	/* Templates はテンプレート名ごとのテンプレート */
+	Templates map[string]ReportTemplate `json:"templates"`
+}
+
+/* reportTemplates はアプリケーション全体で共有するレポートのテンプレートのストア */
+var reportTemplates = &reportTemplateStore{Templates: map[string]ReportTemplate{}}
+
+func init() {
+	registerTable(reportTemplatesTable, loadReportTemplates)
+}
+
+/*
+loadReportTemplates はreport_templatesテーブルからテンプレートを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadReportTemplates() error {
+	reportTemplates.mu.Lock()
+	defer reportTemplates.mu.Unlock()
+
+	reportTemplates.Templates = nil
+	if err := loadTable(reportTemplatesTable, reportTemplates); err != nil {
+		return err
+	}
+	if reportTemplates.Templates == nil {
+		reportTemplates.Templates = map[string]ReportTemplate{}
+	}
+	return nil
+}
+
+/*
+reportTemplateSource はテンプレートの内容を返す（アップロードしたものを優先する）
+
+戻り値:
+  string - テンプレートの内容
+  string - 置き場所（reportTemplateOriginUploaded または reportTemplateOriginFile）
+  bool - テンプレートが存在するかどうか（名前の形式が不正な場合もfalse）
+*/
+func reportTemplateSource(name string) (string, string, bool) {
+	if !reportTemplateNamePattern.MatchString(name) {
+		return "", "", false
+	}
+	reportTemplates.mu.Lock()
+	tmpl, ok := reportTemplates.Templates[name]
+	reportTemplates.mu.Unlock()
+	if ok {
+		return tmpl.Source, reportTemplateOriginUploaded, true
+	}
+	data, err := os.ReadFile(filepath.Join(reportTemplateDir, name+reportTemplateExt))
+	if err != nil {
+		return "", "", false
+	}
+	return string(data), reportTemplateOriginFile, true
+}
+
+/* listReportTemplates はアップロードしたテンプレートとREPORT_TEMPLATE_DIRのテンプレートを名前順に返す（内容は含めない） */
+func listReportTemplates() []ReportTemplate {
+	byName := map[string]ReportTemplate{}
+	if entries, err := os.ReadDir(reportTemplateDir); err == nil {
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), reportTemplateExt)
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), reportTemplateExt) || !reportTemplateNamePattern.MatchString(name) {
+				continue
+			}
+			tmpl := ReportTemplate{Name: name, Origin: reportTemplateOriginFile}
+			if info, err := entry.Info(); err == nil {
+				tmpl.UpdatedAt = info.ModTime().UTC()
+			}
+			byName[name] = tmpl
+		}
+	}
+
+	reportTemplates.mu.Lock()
+	for name, tmpl := range reportTemplates.Templates {
+		tmpl.Source = ""
+		byName[name] = tmpl
+	}
+	reportTemplates.mu.Unlock()
+
+	list := make([]ReportTemplate, 0, len(byName))
+	for _, tmpl := range byName {
+		list = append(list, tmpl)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+/* ReportSummary は query "summary" で取得できるレポートの概要 */
+type ReportSummary struct {
+	Commits      int  // 期間のコミット数
+	ActiveDays   int  // コミットのあった日数
+	Repositories int  // コミットのあったリポジトリ数
+	Truncated    bool // 取得ページ数の上限に達したリポジトリがある
+}
+
+/*
+reportQueries はテンプレートの query で取得できる名前付きの集計
+  - summary: ReportSummary
+  - repositories: []RepoCommitCount（コミット数の多い順、すべてのリポジトリ）
+  - daily: []DailyCommitCount（期間のすべての日）
+  - labels: []LabelCount（LABEL_RULESのラベル、コミット数の多い順）
+  - unlabeled: int（どのラベルにも一致しなかったコミット数）
+*/
+var reportQueries = map[string]func(reportData) interface{}{
+	"summary": func(d reportData) interface{} {
+		return ReportSummary{Commits: d.Commits, ActiveDays: d.ActiveDays, Repositories: len(d.Repositories), Truncated: d.Truncated}
+	},
+	"repositories": func(d reportData) interface{} { return d.Repositories },
+	"daily":        func(d reportData) interface{} { return d.Daily },
+	"labels":       func(d reportData) interface{} { return d.Labels.Labels },
+	"unlabeled":    func(d reportData) interface{} { return d.Labels.Unlabeled },
+}
+
+/* reportTemplateData はテンプレートの値（.Title など） */
+type reportTemplateData struct {
+	Title       string    // レポートのタイトル
+	Period      string    // 期間（"2025-09" または "2025-W38"）
+	From        time.Time // 期間の開始日時
+	To          time.Time // 期間の終了日時（この日時を含まない）
+	Timezone    string    // 集計に使用したタイムゾーン
+	GeneratedAt time.Time // 作成日時
+}
+
+/*
+executeReportTemplate はテンプレートを実行して出力を返す
+
+使用できる関数:
+  query "名前" - 名前付きの集計（reportQueriesを参照、存在しない名前はエラー）
+  peak 一覧 - repositories・daily・labels の一覧のコミット数の最大値（bar の最大値に使用する）
+  date 日時 - 日時を "2006-01-02" 形式（STATS_TIMEZONE）で返す
+*/
+func executeReportTemplate(source string, report Report, data reportData) (string, error) {
+	t, err := template.New("report").Option("missingkey=error").Funcs(template.FuncMap{
+		"query": func(name string) (interface{}, error) {
+			q, ok := reportQueries[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown query: %s", name)
+			}
+			return q(data), nil
+		},
+		"peak": reportPeak,
+		"date": func(t time.Time) string { return t.In(statsLocation).Format(statsDateFormat) },
+	}).Parse(source)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = t.Execute(&out, reportTemplateData{
+		Title:       report.Title,
+		Period:      report.Period,
+		From:        report.From,
+		To:          report.To,
+		Timezone:    statsLocation.String(),
+		GeneratedAt: report.CreatedAt,
+	})
+	return out.String(), err
+}
+
+/* reportPeak は集計の一覧のコミット数の最大値を返す */
+func reportPeak(list interface{}) (int, error) {
+	peak := 0
+	switch list := list.(type) {
+	case []RepoCommitCount:
+		for _, item := range list {
+			peak = max(peak, item.Commits)
+		}
+	case []DailyCommitCount:
+		for _, item := range list {
+			peak = max(peak, item.Commits)
+		}
+	case []LabelCount:
+		for _, item := range list {
+			peak = max(peak, item.Commits)
+		}
+	default:
+		return 0, fmt.Errorf("peak: unsupported value %T", list)
+	}
+	return peak, nil
+}
+
+/* renderTemplateReportPDF はレポートのテンプレート（report.Template）を実行してPDFを作成する */
+func renderTemplateReportPDF(report Report, data reportData) ([]byte, error) {
+	source, _, ok := reportTemplateSource(report.Template)
+	if !ok {
+		return nil, errReportTemplateNotFound
+	}
+	return renderReportTemplate(source, report, data)
+}
+
+/*
+renderReportTemplate はテンプレートを実行し、出力を1行ずつPDFに描画する
+出力の形式はファイルの先頭のコメントを参照
+*/
+func renderReportTemplate(source string, report Report, data reportData) ([]byte, error) {
+	out, err := executeReportTemplate(source, report, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render report template: %w", err)
+	}
+
+	p := newReportPDF(report)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			p.y += reportLineHeight / 2
+		case strings.HasPrefix(line, "# "):
+			p.heading(strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "row "), strings.HasPrefix(line, "bar "):
+			cols := strings.Split(line[4:], "|")
+			if len(cols) < 2 {
+				return nil, fmt.Errorf("report template line needs \"label | value\": %q", line)
+			}
+			value, err := strconv.Atoi(strings.TrimSpace(cols[1]))
+			if err != nil {
+				return nil, fmt.Errorf("report template line has a non-integer value: %q", line)
+			}
+			peak := 0
+			if line[:3] == "bar" && len(cols) > 2 {
+				if peak, err = strconv.Atoi(strings.TrimSpace(cols[2])); err != nil {
+					return nil, fmt.Errorf("report template line has a non-integer peak: %q", line)
+				}
+			}
+			p.row(strings.TrimSpace(cols[0]), value, peak)
+		default:
+			p.ensure(reportLineHeight)
+			p.doc.text(reportMargin, p.y, pdfFontRegular, 10, line)
+			p.y += reportLineHeight
+		}
+	}
+	return p.doc.bytes(), nil
+}
+
+/* sampleReportData はアップロード時にテンプレートを試しに実行するための集計結果 */
+func sampleReportData() (Report, reportData) {
+	from, to, _ := parseReportPeriod(time.Now().In(statsLocation).Format("2006-01"))
+	report := Report{Title: "Sample report", Period: from.Format("2006-01"), From: from, To: to, CreatedAt: time.Now().UTC()}
+	data := reportData{
+		Commits:      3,
+		ActiveDays:   2,
+		Repositories: []RepoCommitCount{{Repo: "sample", Commits: 3}},
+		Daily:        []DailyCommitCount{{Date: from.Format(statsDateFormat), Commits: 3}},
+		Labels:       LabelStatsResponse{Commits: 3, Unlabeled: 1, Labels: []LabelCount{{Label: "bugfix", Commits: 2}}},
+	}
+	return report, data
+}
+
+/*
+getReportTemplates はレポートのテンプレートの一覧を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"templates": [ReportTemplate（内容を除く、名前順）]}
+*/
+func getReportTemplates(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{"templates": listReportTemplates()})
+}
+
+/*
+getReportTemplate はレポートのテンプレートの内容を返す管理者APIハンドラー
+
+レスポンス:
+  成功時: 200 OK, ReportTemplate
+  失敗時: 404 Not Found
+*/
+func getReportTemplate(c *gin.Context) {
+	name := c.Param("name")
+	source, origin, ok := reportTemplateSource(name)
+	if !ok {
+		respondError(c, http.StatusNotFound, errReportTemplateNotFound.Error())
+		return
+	}
+	tmpl := ReportTemplate{Name: name, Origin: origin, Source: source}
+	if origin == reportTemplateOriginUploaded {
+		reportTemplates.mu.Lock()
+		tmpl.UpdatedAt = reportTemplates.Templates[name].UpdatedAt
+		reportTemplates.mu.Unlock()
+	} else if info, err := os.Stat(filepath.Join(reportTemplateDir, name+reportTemplateExt)); err == nil {
+		tmpl.UpdatedAt = info.ModTime().UTC()
+	}
+	respondJSON(c, http.StatusOK, tmpl)
+}
+
+/* reportTemplateRequest は PUT /api/admin/report-templates/:name のリクエストボディ */
+type reportTemplateRequest struct {
+	Source string `json:"source" binding:"required,max=20000"` // テンプレートの内容
+}
+
+/* validateFields はテンプレートをサンプルの集計結果で実行し、PDFに描画できることを検証する */
+func (req *reportTemplateRequest) validateFields() []FieldError {
+	if req.Source == "" {
+		return nil
+	}
+	report, data := sampleReportData()
+	if _, err := renderReportTemplate(req.Source, report, data); err != nil {
+		return []FieldError{{Field: "source", Rule: "template", Message: err.Error()}}
+	}
+	return nil
+}
+
+/*
+putReportTemplate はレポートのテンプレートをアップロードする管理者APIハンドラー
+同じ名前のテンプレートがある場合は置き換える（REPORT_TEMPLATE_DIRに同じ名前のファイルがある場合もこちらを優先する）
+
+リクエストボディ:
+  {"source": "# Summary\nrow Commits | {{(query \"summary\").Commits}}"}
+
+レスポンス:
+  成功時: 200 OK, ReportTemplate
+  失敗時: 400 Bad Request（不正なJSON）, 422 Unprocessable Entity（名前の形式が不正、テンプレートを実行できない）
+*/
+func putReportTemplate(c *gin.Context) {
+	name := c.Param("name")
+	if !reportTemplateNamePattern.MatchString(name) {
+		respondValidationError(c, []FieldError{{Field: "name", Rule: "pattern", Message: "must match " + reportTemplateNamePattern.String()}})
+		return
+	}
+	var req reportTemplateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tmpl := ReportTemplate{Name: name, Origin: reportTemplateOriginUploaded, Source: req.Source, UpdatedAt: time.Now().UTC()}
+	reportTemplates.mu.Lock()
+	reportTemplates.Templates[name] = tmpl
+	if err := saveTable(reportTemplatesTable, reportTemplates); err != nil {
+		log.Error().Err(err).Msg("Failed to save report templates")
+	}
+	reportTemplates.mu.Unlock()
+
+	auditLog.record(c, "report_template.update", name, map[string]int{"size": len(req.Source)})
+	respondJSON(c, http.StatusOK, tmpl)
+}
+
+/*
+deleteReportTemplate はアップロードしたレポートのテンプレートを削除する管理者APIハンドラー
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 404 Not Found（アップロードしたテンプレートがない。REPORT_TEMPLATE_DIRのファイルは削除できない）
+*/
+func deleteReportTemplate(c *gin.Context) {
+	name := c.Param("name")
+	reportTemplates.mu.Lock()
+	_, ok := reportTemplates.Templates[name]
+	if ok {
+		delete(reportTemplates.Templates, name)
+		if err := saveTable(reportTemplatesTable, reportTemplates); err != nil {
+			log.Error().Err(err).Msg("Failed to save report templates")
+		}
+	}
+	reportTemplates.mu.Unlock()
+
+	if !ok {
+		respondError(c, http.StatusNotFound, errReportTemplateNotFound.Error())
+		return
+	}
+	auditLog.record(c, "report_template.delete", name, nil)
+	c.Status(http.StatusNoContent)
+}
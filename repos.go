@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+RepositoryDetail はGitHub APIから取得する個別のリポジトリの詳細情報
+一覧（Repository）のフィールドに加え、フォーク数や作成日時を含む
+API仕様: https://docs.github.com/ja/rest/repos/repos#get-a-repository
+*/
+type RepositoryDetail struct {
+	Repository
+	ForksCount int       `json:"forks_count"` // フォーク数
+	CreatedAt  time.Time `json:"created_at"`  // 作成日時
+	PushedAt   time.Time `json:"pushed_at"`   // 最後にプッシュされた日時
+}
+
+/*
+Branch はGitHub APIから取得するブランチ情報を表す構造体
+API仕様: https://docs.github.com/ja/rest/branches/branches#list-branches
+*/
+type Branch struct {
+	Name   string `json:"name"` // ブランチ名
+	Commit struct {
+		SHA string `json:"sha"` // ブランチの先頭のコミットハッシュ
+	} `json:"commit"`
+	Protected bool `json:"protected"` // 保護されたブランチかどうか
+}
+
+/*
+RepositoryStats は取得したコミットから集計したリポジトリの統計
+コミットは最大100件（fetchCommitsの取得範囲）を集計対象とする
+*/
+type RepositoryStats struct {
+	Commits           int       `json:"commits"`              // 集計対象のコミット数
+	CommitsLast30Days int       `json:"commits_last_30_days"` // 直近30日間のコミット数
+	Authors           int       `json:"authors"`              // コミット作成者の人数
+	Branches          int       `json:"branches"`             // ブランチ数
+	FirstCommitAt     time.Time `json:"first_commit_at"`      // 集計対象で最も古いコミットの日時
+	LatestCommitAt    time.Time `json:"latest_commit_at"`     // 最も新しいコミットの日時
+}
+
+/*
+RepositoryOverview は /api/repos/:owner/:repo のレスポンス
+リポジトリの詳細と統計をまとめて返す
+*/
+type RepositoryOverview struct {
+	Repository RepositoryDetail `json:"repository"` // リポジトリの詳細
+	Stats      RepositoryStats  `json:"stats"`      // 統計
+}
+
+/* errRepositoryNotFound は対象のリポジトリが存在しない（またはusername以外の所有者）場合のエラー */
+var errRepositoryNotFound = errors.New("repository not found")
+
+/*
+repoNamePattern はGitHubの所有者名・リポジトリ名として使用できる文字列
+パスパラメータをそのままGitHub APIのURLに埋め込むため、事前にこのパターンで検証する
+*/
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+/*
+repoFullNameParam はパスパラメータ :owner と :repo からリポジトリのフルネームを作成する
+このダッシュボードの対象はusernameのリポジトリのみのため、他の所有者は存在しない扱いにする
+
+戻り値:
+  string - リポジトリのフルネーム（例: "develop-suda/giter"）
+  error - 名前が不正、所有者がusername以外、または匿名の閲覧者にプライベートリポジトリを指定された場合はerrRepositoryNotFound
+
+注意:
+  - PRIVACY_MODE=anonymous の匿名の閲覧者には、プライベートリポジトリを存在しないものとして扱う
+    （リポジトリの詳細はgithubGetのキャッシュを経由し、取得に失敗した場合は各ハンドラーの取得でエラーを返す）
+*/
+func repoFullNameParam(c *gin.Context) (string, error) {
+	owner, repo := c.Param("owner"), c.Param("repo")
+	if !strings.EqualFold(owner, username) || !repoNamePattern.MatchString(repo) || repo == "." || repo == ".." {
+		return "", errRepositoryNotFound
+	}
+	fullName := username + "/" + repo
+	v := requestVisibility(c)
+	if !v.includes(repo, false) {
+		return "", errRepositoryNotFound
+	}
+	if v == visibilityPublic {
+		if detail, err := fetchRepository(fullName); err == nil && detail.Private {
+			return "", errRepositoryNotFound
+		}
+	}
+	return fullName, nil
+}
+
+/*
+fetchRepository は指定されたリポジトリの詳細を取得する
+存在しないリポジトリを区別するため、fetchGitHubJSONではなくgithubGetを直接使用する
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+
+戻り値:
+  RepositoryDetail - リポジトリの詳細
+  error - 404の場合はerrRepositoryNotFound、それ以外の失敗はそのエラー
+*/
+func fetchRepository(repoFullName string) (RepositoryDetail, error) {
+	var detail RepositoryDetail
+	url := fmt.Sprintf("%s/repos/%s", githubAPIBase, repoFullName)
+
+	resp, err := githubGet(upstreamOpRepository, url, githubAcceptV3)
+	if err != nil {
+		captureUpstreamFailure(upstreamOpRepository, url, err)
+		return detail, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return detail, errRepositoryNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(resp.Body))
+		captureUpstreamFailure(upstreamOpRepository, url, err)
+		return detail, err
+	}
+	if err := json.Unmarshal(resp.Body, &detail); err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to decode GitHub API JSON response")
+		return detail, err
+	}
+	return detail, nil
+}
+
+/*
+fetchBranches は指定されたリポジトリのブランチ一覧を取得する
+API仕様: https://docs.github.com/ja/rest/branches/branches#list-branches
+
+引数:
+  repoFullName string - リポジトリのフルネーム
+
+戻り値:
+  []Branch - ブランチ一覧（最大100件）
+  error - 取得に失敗した場合のエラー
+*/
+func fetchBranches(repoFullName string) ([]Branch, error) {
+	url := fmt.Sprintf("%s/repos/%s/branches?per_page=100", githubAPIBase, repoFullName)
+	branches := []Branch{}
+	if err := fetchGitHubJSON(upstreamOpRepository, url, githubAcceptV3, &branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+/*
+repositoryStats はコミットとブランチからリポジトリの統計を集計する
+
+引数:
+  commits []Commit - fetchCommitsで取得したコミット
+  branches int - ブランチ数
+  now time.Time - 直近30日間の基準日時
+*/
+func repositoryStats(commits []Commit, branches int, now time.Time) RepositoryStats {
+	stats := RepositoryStats{Commits: len(commits), Branches: branches}
+	authors := map[string]bool{}
+	since := now.AddDate(0, 0, -30)
+	for _, commit := range commits {
+		date := commit.Commit.Author.Date
+		if date.After(stats.LatestCommitAt) {
+			stats.LatestCommitAt = date
+		}
+		if stats.FirstCommitAt.IsZero() || date.Before(stats.FirstCommitAt) {
+			stats.FirstCommitAt = date
+		}
+		if date.After(since) {
+			stats.CommitsLast30Days++
+		}
+		authors[commit.Commit.Author.Name] = true
+	}
+	stats.Authors = len(authors)
+	return stats
+}
+
+/*
+respondRepositoryError はリポジトリの取得エラーをAPIのエラーレスポンスとして返す
+errRepositoryNotFoundは404、それ以外はGitHub APIの失敗として502を返す
+*/
+func respondRepositoryError(c *gin.Context, err error) {
+	if errors.Is(err, errRepositoryNotFound) {
+		respondError(c, http.StatusNotFound, errRepositoryNotFound.Error())
+		return
+	}
+	log.Warn().Err(err).Str("path", c.Request.URL.Path).Msg("Failed to fetch repository")
+	respondError(c, http.StatusBadGateway, err.Error())
+}
+
+/* repositoryListQuery は /api/repositories の絞り込み・並び順のクエリパラメータ */
+type repositoryListQuery struct {
+	Language string `form:"language"`                                         // 主な言語（大文字小文字を区別しない）
+	License  string `form:"license"`                                          // ライセンスのSPDX識別子またはキー（例: "MIT", "mit"）
+	Archived *bool  `form:"archived"`                                         // アーカイブされているかどうか（省略時はすべて）
+	MinStars int    `form:"min_stars" binding:"min=0"`                        // スター数の下限
+	Sort     string `form:"sort" binding:"omitempty,oneof=name stars issues"` // 並び順（name: 名前順, stars: スターの多い順, issues: オープン中のIssueの多い順）
+}
+
+/* matches はリポジトリが絞り込みの条件に一致するかどうかを返す */
+func (q repositoryListQuery) matches(repo Repository) bool {
+	if q.Language != "" && !strings.EqualFold(repo.Language, q.Language) {
+		return false
+	}
+	if q.License != "" && (repo.License == nil ||
+		!strings.EqualFold(repo.License.SPDXID, q.License) && !strings.EqualFold(repo.License.Key, q.License)) {
+		return false
+	}
+	if q.Archived != nil && repo.Archived != *q.Archived {
+		return false
+	}
+	return repo.StargazersCount >= q.MinStars
+}
+
+/*
+getRepositories はリポジトリの一覧をライセンス・スター数・言語などのメタデータとともに返すAPIハンドラー
+メタデータはリポジトリ一覧の取得（fetchRepositories、キャッシュを経由）で同時に取得するため、追加のリクエストは発生しない
+
+クエリパラメータ:
+  repositoryListQueryを参照（例: ?language=go&license=mit&archived=false&min_stars=5&sort=stars）
+
+レスポンス:
+  成功時: 200 OK, []Repository
+  失敗時: 422 Unprocessable Entity（不正なパラメータ）, 502 Bad Gateway（GitHub APIの失敗）
+
+注意:
+  - PRIVACY_MODE=anonymous の匿名の閲覧者にはプライベートリポジトリを含めない
+*/
+func getRepositories(c *gin.Context) {
+	query := repositoryListQuery{Sort: "name"}
+	if !bindQuery(c, &query) {
+		return
+	}
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	filtered := make([]Repository, 0, len(repos))
+	for _, repo := range repos {
+		if query.matches(repo) {
+			filtered = append(filtered, repo)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		switch {
+		case query.Sort == "stars" && a.StargazersCount != b.StargazersCount:
+			return a.StargazersCount > b.StargazersCount
+		case query.Sort == "issues" && a.OpenIssuesCount != b.OpenIssuesCount:
+			return a.OpenIssuesCount > b.OpenIssuesCount
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	respondJSON(c, http.StatusOK, filtered)
+}
+
+/*
+getRepository はリポジトリの詳細と統計を返すAPIハンドラー
+
+パスパラメータ:
+  owner string - 所有者名（usernameのみ）
+  repo string - リポジトリ名
+
+レスポンス:
+  成功時: 200 OK, RepositoryOverview
+  失敗時: 404 Not Found, 502 Bad Gateway（GitHub APIの失敗）
+*/
+func getRepository(c *gin.Context) {
+	fullName, err := repoFullNameParam(c)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	detail, err := fetchRepository(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	commits, err := fetchCommits(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	branches, err := fetchBranches(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	respondJSON(c, http.StatusOK, RepositoryOverview{
+		Repository: detail,
+		Stats:      repositoryStats(commits, len(branches), time.Now()),
+	})
+}
+
+/*
+getRepositoryCommits はリポジトリのコミット履歴を返すAPIハンドラー
+/api/git-history と同じCommitHistory形式で返す
+
+レスポンス:
+  成功時: 200 OK, []CommitHistory（新しい順、最大100件）
+  失敗時: 404 Not Found, 502 Bad Gateway
+*/
+func getRepositoryCommits(c *gin.Context) {
+	fullName, err := repoFullNameParam(c)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	commits, err := fetchCommits(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	name := c.Param("repo")
+	history := make([]CommitHistory, 0, len(commits))
+	for _, commit := range commits {
+		history = append(history, newCommitHistory(name, commit))
+	}
+	respondJSON(c, http.StatusOK, requestRedaction(c).commits(history))
+}
+
+/*
+getRepositoryBranches はリポジトリのブランチ一覧を返すAPIハンドラー
+
+レスポンス:
+  成功時: 200 OK, []Branch
+  失敗時: 404 Not Found, 502 Bad Gateway
+*/
+func getRepositoryBranches(c *gin.Context) {
+	fullName, err := repoFullNameParam(c)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	branches, err := fetchBranches(fullName)
+	if err != nil {
+		respondRepositoryError(c, err)
+		return
+	}
+	respondJSON(c, http.StatusOK, branches)
+}
+
+/* repoPageCommits はリポジトリ詳細ページに表示するコミットの件数 */
+const repoPageCommits = 20
+
+/*
+showRepositoryPage はリポジトリ詳細ページ（/repos/:owner/:repo）を表示するハンドラー
+/api/repos/:owner/:repo 系のAPIと同じ取得処理（fetchRepository, fetchCommits, fetchBranches）で
+説明・最近のコミット・ブランチ・統計をサーバーでレンダリングする
+
+レスポンス:
+  成功時: 200 OK, repo.html
+  失敗時: 404 Not Found / 502 Bad Gateway（エラーメッセージを表示したrepo.html）
+*/
+func showRepositoryPage(c *gin.Context) {
+	req := webRequest(c)
+	fullName, err := repoFullNameParam(c)
+	if err == nil {
+		var page web.RepoPage
+		page, err = buildRepoPage(req, fullName, requestRedaction(c))
+		if err == nil {
+			c.HTML(http.StatusOK, "repo.html", page)
+			return
+		}
+	}
+
+	status := http.StatusBadGateway
+	message := "failed to fetch repository from GitHub"
+	if errors.Is(err, errRepositoryNotFound) {
+		status, message = http.StatusNotFound, errRepositoryNotFound.Error()
+	} else {
+		log.Warn().Err(err).Str("path", c.Request.URL.Path).Msg("Failed to render repository page")
+	}
+	c.HTML(status, "repo.html", web.NewRepoErrorPage(siteInfo(), req, c.Param("repo"), localize(c, message, nil)))
+}
+
+/* buildRepoPage はリポジトリ詳細ページのビューモデルを作成する */
+func buildRepoPage(req web.Request, fullName string, r redaction) (web.RepoPage, error) {
+	detail, err := fetchRepository(fullName)
+	if err != nil {
+		return web.RepoPage{}, err
+	}
+	commits, err := fetchCommits(fullName)
+	if err != nil {
+		return web.RepoPage{}, err
+	}
+	branches, err := fetchBranches(fullName)
+	if err != nil {
+		return web.RepoPage{}, err
+	}
+
+	stats := repositoryStats(commits, len(branches), time.Now())
+	repo := web.RepoSummary{
+		Name:          detail.Name,
+		FullName:      detail.FullName,
+		Description:   detail.Description,
+		HTMLURL:       detail.HTMLURL,
+		Language:      detail.Language,
+		DefaultBranch: detail.DefaultBranch,
+		Stars:         detail.StargazersCount,
+		Forks:         detail.ForksCount,
+		OpenIssues:    detail.OpenIssuesCount,
+		PushedAt:      detail.PushedAt,
+	}
+	items := make([]web.CommitItem, 0, repoPageCommits)
+	for i, commit := range commits {
+		if i == repoPageCommits {
+			break
+		}
+		items = append(items, web.CommitItem{
+			SHA:     commit.SHA,
+			Message: r.message(commit.Commit.Message),
+			Author:  commit.Commit.Author.Name,
+			Time:    commit.Commit.Author.Date,
+			URL:     commit.HTMLURL,
+		})
+	}
+	branchItems := make([]web.BranchItem, 0, len(branches))
+	for _, branch := range branches {
+		branchItems = append(branchItems, web.BranchItem{
+			Name:      branch.Name,
+			SHA:       branch.Commit.SHA,
+			Protected: branch.Protected,
+			Default:   branch.Name == detail.DefaultBranch,
+		})
+	}
+	return web.NewRepoPage(siteInfo(), req, repo, web.RepoStats{
+		Commits:           stats.Commits,
+		CommitsLast30Days: stats.CommitsLast30Days,
+		Authors:           stats.Authors,
+		Branches:          stats.Branches,
+		FirstCommitAt:     stats.FirstCommitAt,
+		LatestCommitAt:    stats.LatestCommitAt,
+	}, items, branchItems), nil
+}
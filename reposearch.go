@@ -0,0 +1,300 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* リポジトリの検索で語を照合するフィールド */
+const (
+	repoSearchFieldName        = "name"        // リポジトリ名
+	repoSearchFieldTopics      = "topics"      // トピック
+	repoSearchFieldDescription = "description" // 説明文
+)
+
+/* repoSearchFieldWeights はフィールドごとのスコアの重み（名前に一致したものを優先する） */
+var repoSearchFieldWeights = map[string]float64{
+	repoSearchFieldName:        3,
+	repoSearchFieldTopics:      2,
+	repoSearchFieldDescription: 1,
+}
+
+const (
+	/* repoSearchPrefixFactor は語の先頭のみが一致した場合（入力途中の語）のスコアの割合 */
+	repoSearchPrefixFactor = 0.5
+	/* defaultRepoSearchLimit は返す結果の件数のデフォルト */
+	defaultRepoSearchLimit = 10
+)
+
+/* repoPosting は語を含むリポジトリとフィールド */
+type repoPosting struct {
+	doc   int    // repoSearchIndex.reposの添字
+	field string // 語を含むフィールド
+}
+
+/*
+repoSearchIndex はリポジトリのメタデータ（名前・トピック・説明文）の転置インデックス
+語からリポジトリを引き、terms（ソート済み）の二分探索で入力途中の語の前方一致も調べる
+*/
+type repoSearchIndex struct {
+	repos    []Repository
+	postings map[string][]repoPosting
+	terms    []string
+}
+
+/*
+repoSearchCache は最後に作成したインデックス
+リポジトリ一覧（fetchRepositoriesのキャッシュ）の内容が変わった場合のみ作り直す
+*/
+var repoSearchCache = struct {
+	mu          sync.Mutex
+	fingerprint string
+	index       *repoSearchIndex
+}{}
+
+/* RepoSearchResult は検索結果の1件 */
+type RepoSearchResult struct {
+	Repository Repository `json:"repository"` // リポジトリ
+	Score      float64    `json:"score"`      // 一致の度合い（大きいほど上位）
+	Matched    []string   `json:"matched"`    // 語が一致したフィールド（name, topics, description）
+}
+
+/* RepoSearchResponse は /api/search/repos のレスポンス */
+type RepoSearchResponse struct {
+	Query   string             `json:"query"`   // 検索語
+	Total   int                `json:"total"`   // 一致したリポジトリの件数（limitで切り詰める前）
+	Results []RepoSearchResult `json:"results"` // スコアの高い順の結果
+}
+
+/*
+searchTokens はテキストを検索用の語に分割する
+英数字は連続を1語とし小文字にする。漢字・カタカナは分かち書きしないため、連続した2文字ずつ（bi-gram）に分割する
+例: "Git履歴ビューア" → "git", "履歴", "ビュ", "ュー", "ーア"
+
+注意:
+  - 記号・空白・ひらがなは区切りとして扱う（runeScriptを参照）
+  - "-" や "_" で区切ったリポジトリ名（例: "my-project"）は語ごとに分割する
+*/
+func searchTokens(text string) []string {
+	tokens := []string{}
+	add := func(run []rune, script int) {
+		switch {
+		case len(run) == 0:
+		case script == scriptLatin:
+			tokens = append(tokens, strings.ToLower(string(run)))
+		case len(run) == 1:
+			tokens = append(tokens, string(run))
+		default:
+			for i := 0; i+1 < len(run); i++ {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+	}
+
+	var current []rune
+	currentScript := scriptNone
+	for _, r := range text {
+		script := runeScript(r)
+		/* 漢字とカタカナの境目は区切らない（"履歴ビューア" を続けてbi-gramにする） */
+		if script == scriptKatakana && currentScript == scriptKanji || script == scriptKanji && currentScript == scriptKatakana {
+			script = currentScript
+		}
+		if script != currentScript {
+			add(current, currentScript)
+			current = current[:0]
+			currentScript = script
+		}
+		if script != scriptNone {
+			current = append(current, r)
+		}
+	}
+	add(current, currentScript)
+	return tokens
+}
+
+/* newRepoSearchIndex はリポジトリのメタデータからインデックスを作成する */
+func newRepoSearchIndex(repos []Repository) *repoSearchIndex {
+	index := &repoSearchIndex{repos: repos, postings: map[string][]repoPosting{}}
+	addField := func(doc int, field, text string) {
+		seen := map[string]bool{}
+		for _, token := range searchTokens(text) {
+			if seen[token] {
+				continue
+			}
+			seen[token] = true
+			index.postings[token] = append(index.postings[token], repoPosting{doc: doc, field: field})
+		}
+	}
+	for doc, repo := range repos {
+		addField(doc, repoSearchFieldName, repo.Name)
+		addField(doc, repoSearchFieldTopics, strings.Join(repo.Topics, " "))
+		addField(doc, repoSearchFieldDescription, repo.Description)
+	}
+	for term := range index.postings {
+		index.terms = append(index.terms, term)
+	}
+	sort.Strings(index.terms)
+	return index
+}
+
+/*
+search は検索語のすべての語を含むリポジトリをスコアの高い順に返す
+語ごとに一致したフィールドの重みのうち最大のものを加算し、完全一致の語は重みのまま、前方一致の語はrepoSearchPrefixFactorを掛ける
+名前が検索語と完全に一致、または検索語で始まる場合はさらに加算する
+
+引数:
+  query string - 検索語（空白区切りで複数の語を指定できる）
+  visible func(Repository) bool - 結果に含めるリポジトリ（閲覧者のデータの範囲）
+*/
+func (idx *repoSearchIndex) search(query string, visible func(Repository) bool) []RepoSearchResult {
+	tokens := searchTokens(query)
+	if len(tokens) == 0 {
+		return []RepoSearchResult{}
+	}
+
+	scores := map[int]float64{}
+	matched := map[int]map[string]bool{}
+	for i, token := range tokens {
+		best := map[int]float64{}
+		fields := map[int][]string{}
+		collect := func(postings []repoPosting, factor float64) {
+			for _, p := range postings {
+				if i > 0 {
+					if _, ok := scores[p.doc]; !ok {
+						continue
+					}
+				}
+				if weight := repoSearchFieldWeights[p.field] * factor; weight > best[p.doc] {
+					best[p.doc] = weight
+				}
+				fields[p.doc] = append(fields[p.doc], p.field)
+			}
+		}
+		collect(idx.postings[token], 1)
+		for j := sort.SearchStrings(idx.terms, token); j < len(idx.terms) && strings.HasPrefix(idx.terms[j], token); j++ {
+			if idx.terms[j] != token {
+				collect(idx.postings[idx.terms[j]], repoSearchPrefixFactor)
+			}
+		}
+
+		/* すべての語を含むリポジトリのみ残す */
+		next := map[int]float64{}
+		for doc, weight := range best {
+			next[doc] = scores[doc] + weight
+			if matched[doc] == nil {
+				matched[doc] = map[string]bool{}
+			}
+			for _, field := range fields[doc] {
+				matched[doc][field] = true
+			}
+		}
+		scores = next
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(query))
+	results := []RepoSearchResult{}
+	for doc, score := range scores {
+		repo := idx.repos[doc]
+		if !visible(repo) {
+			continue
+		}
+		name := strings.ToLower(repo.Name)
+		switch {
+		case name == normalized:
+			score += 5
+		case strings.HasPrefix(name, normalized):
+			score += 2
+		}
+		result := RepoSearchResult{Repository: repo, Score: score, Matched: []string{}}
+		for _, field := range []string{repoSearchFieldName, repoSearchFieldTopics, repoSearchFieldDescription} {
+			if matched[doc][field] {
+				result.Matched = append(result.Matched, field)
+			}
+		}
+		results = append(results, result)
+	}
+	/* 同じスコアの場合はスターの多い順、名前順 */
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Repository.StargazersCount != b.Repository.StargazersCount {
+			return a.Repository.StargazersCount > b.Repository.StargazersCount
+		}
+		return a.Repository.Name < b.Repository.Name
+	})
+	return results
+}
+
+/*
+repoSearchIndexFor はリポジトリ一覧のインデックスを返す
+一覧の内容（名前・トピック・説明文）が前回と同じ場合は作成済みのインデックスを使用する
+*/
+func repoSearchIndexFor(repos []Repository) *repoSearchIndex {
+	var b strings.Builder
+	for _, repo := range repos {
+		b.WriteString(repo.FullName)
+		b.WriteByte(0)
+		b.WriteString(repo.Description)
+		b.WriteByte(0)
+		b.WriteString(strings.Join(repo.Topics, ","))
+		b.WriteByte(0)
+	}
+	fingerprint := b.String()
+
+	repoSearchCache.mu.Lock()
+	defer repoSearchCache.mu.Unlock()
+	if repoSearchCache.index == nil || repoSearchCache.fingerprint != fingerprint {
+		repoSearchCache.index = newRepoSearchIndex(repos)
+		repoSearchCache.fingerprint = fingerprint
+		log.Debug().Int("repositories", len(repos)).Int("terms", len(repoSearchCache.index.terms)).Msg("Repository search index rebuilt")
+	}
+	return repoSearchCache.index
+}
+
+/*
+getRepoSearch はリポジトリを名前・トピック・説明文で検索するAPIハンドラー
+UIのリポジトリの切り替え（クイックスイッチャー）で入力ごとに呼び出すことを想定し、入力途中の語は前方一致で検索する
+
+クエリパラメータ:
+  q string - 検索語（必須、空白区切りの語をすべて含むリポジトリを返す）
+  limit int - 返す件数（1〜50、デフォルト: 10）
+
+レスポンス:
+  成功時: 200 OK, RepoSearchResponse
+  失敗時: 422 Unprocessable Entity（q未指定）, 502 Bad Gateway（GitHub APIの失敗）
+
+注意:
+  - PRIVACY_MODE=anonymous の匿名の閲覧者にはプライベートリポジトリを含めない
+*/
+func getRepoSearch(c *gin.Context) {
+	query := struct {
+		Q     string `form:"q" binding:"required,max=200"`
+		Limit int    `form:"limit" binding:"min=1,max=50"`
+	}{Limit: defaultRepoSearchLimit}
+	if !bindQuery(c, &query) {
+		return
+	}
+	repos, err := fetchRepositories()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for search")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	v := requestVisibility(c)
+	results := repoSearchIndexFor(repos).search(query.Q, func(repo Repository) bool {
+		return v.includes(repo.Name, repo.Private)
+	})
+	total := len(results)
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	respondJSON(c, http.StatusOK, RepoSearchResponse{Query: query.Q, Total: total, Results: results})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+リクエストのログ
+受け付けたリクエスト（アクセスログ）とGitHub APIへのリクエスト（送信ログ）を同じzerologの出力
+（コンソールと log/YYYYMM/YYYYMMDD/app.log）に構造化ログとして書き込み、"log" フィールドで区別する
+上流の遅さが原因の遅いリクエストを、時刻とリクエストIDで突き合わせて調べられるようにする
+*/
+
+/* "log" フィールドの値 */
+const (
+	requestLogAccess   = "access"   // 受け付けたリクエスト
+	requestLogOutbound = "outbound" // GitHub APIへのリクエスト
+)
+
+var (
+	/*
+		outboundLogEnabled はGitHub APIへのリクエストをログに出力するかどうか
+		環境変数 OUTBOUND_LOG で変更可能（デフォルト: true）
+	*/
+	outboundLogEnabled = getEnvBool("OUTBOUND_LOG", true)
+	/*
+		outboundLogSampleRate はGitHub APIへのリクエストのうちログに出力する割合（0〜1）
+		環境変数 OUTBOUND_LOG_SAMPLE_RATE で変更可能（デフォルト: 1 = すべて）
+		失敗したリクエスト（通信エラー・4xx・5xx）は割合にかかわらず出力する
+	*/
+	outboundLogSampleRate = loadSampleRate("OUTBOUND_LOG_SAMPLE_RATE", 1)
+)
+
+/*
+redactedQueryParams はログに値を出力しないクエリパラメータ名（小文字）
+GitHub APIでは使用しないが、プロキシの中継などで認証情報が含まれる場合に備える
+*/
+var redactedQueryParams = map[string]bool{
+	"access_token":  true,
+	"client_secret": true,
+	"token":         true,
+	"code":          true,
+	"sig":           true,
+	"signature":     true,
+}
+
+/* loadSampleRate は0〜1の割合を環境変数から読み込む（不正な値の場合はデフォルト値） */
+func loadSampleRate(key string, defaultValue float64) float64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		log.Warn().Str("key", key).Str("value", value).Msg("Invalid sample rate, using default")
+		return defaultValue
+	}
+	return rate
+}
+
+/*
+redactURL はURLのユーザー情報と、redactedQueryParamsのクエリパラメータの値を "[redacted]" に置き換える
+例: https://api.github.com/x?access_token=abc&page=2 -> https://api.github.com/x?access_token=%5Bredacted%5D&page=2
+*/
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("[redacted]")
+	}
+	query := redacted.Query()
+	changed := false
+	for key := range query {
+		if redactedQueryParams[strings.ToLower(key)] {
+			query.Set(key, "[redacted]")
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+/*
+accessLogMiddleware は受け付けたリクエストをアクセスログとして出力するミドルウェア
+requestIDMiddlewareの後に登録し、リクエストIDを含める
+
+注意:
+  - 5xxはERROR、4xxはWARN、それ以外はINFOのレベルで出力する
+  - クエリパラメータの値は redactURL で認証情報を取り除く（共有URLのトークンなどパスに含まれる値は対象外）
+*/
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		var event *zerolog.Event
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = log.Error()
+		case status >= http.StatusBadRequest:
+			event = log.Warn()
+		default:
+			event = log.Info()
+		}
+		event.
+			Str("log", requestLogAccess).
+			Str("method", c.Request.Method).
+			Str("url", redactURL(c.Request.URL)).
+			Int("status", status).
+			Dur("duration", time.Since(start)).
+			Int("size", c.Writer.Size()).
+			Str("client_ip", c.ClientIP()).
+			Str("request_id", requestID(c)).
+			Msg("Request handled")
+	}
+}
+
+/*
+logOutbound はGitHub APIへのリクエストの結果を送信ログとして出力する
+成功したリクエストはoutboundLogSampleRateの割合で間引き、失敗したリクエストは常に出力する
+
+引数:
+  op string - 操作の種類
+  req *http.Request - 送信したリクエスト（Authorizationなどのヘッダーは出力しない）
+  resp *http.Response - レスポンス（通信エラーの場合はnil）
+  err error - 通信エラー
+  duration time.Duration - レスポンスヘッダーを受け取るまで（または失敗するまで）の時間
+*/
+func logOutbound(op string, req *http.Request, resp *http.Response, err error, duration time.Duration) {
+	if !outboundLogEnabled {
+		return
+	}
+	failed := err != nil || resp.StatusCode >= http.StatusBadRequest
+	if !failed && outboundLogSampleRate < 1 && rand.Float64() >= outboundLogSampleRate {
+		return
+	}
+
+	event := log.Info()
+	if failed {
+		event = log.Warn()
+	}
+	event = event.
+		Str("log", requestLogOutbound).
+		Str("operation", op).
+		Str("method", req.Method).
+		Str("url", redactURL(req.URL)).
+		Dur("duration", duration)
+	if err != nil {
+		event.Err(err).Msg("GitHub API request failed")
+		return
+	}
+	event.
+		Int("status", resp.StatusCode).
+		Str("protocol", resp.Proto).
+		Str("rate_limit", resp.Header.Get("X-RateLimit-Limit")).
+		Str("rate_remaining", resp.Header.Get("X-RateLimit-Remaining")).
+		Str("rate_reset", resp.Header.Get("X-RateLimit-Reset")).
+		Str("github_request_id", resp.Header.Get("X-GitHub-Request-Id")).
+		Msg("GitHub API request sent")
+}
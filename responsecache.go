@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+レスポンスのキャッシュ
+ルートごとのキャッシュの方針（cachePolicies）をresponseCacheMiddlewareで適用する
+  - Cache-Controlヘッダー（ブラウザ・プロキシでのキャッシュ）
+  - サーバーのメモリ上でのレスポンスの保持（GitHubからの取得や集計・画像の描画を省略する）
+  - ハンドラーが5xxを返した場合に、期限切れのレスポンスを返す（stale-if-error）
+*/
+
+/* cacheResult はキャッシュの利用結果（X-Cacheヘッダーとメトリクスのラベル） */
+const (
+	cacheResultHit   = "HIT"   // 保持したレスポンスを返した
+	cacheResultMiss  = "MISS"  // ハンドラーを実行した
+	cacheResultStale = "STALE" // ハンドラーが5xxを返したため、期限切れのレスポンスを返した
+)
+
+/* cacheHeader はキャッシュの利用結果を返すレスポンスヘッダー名 */
+const cacheHeader = "X-Cache"
+
+/* cachedResponseHeaders は保持したレスポンスから返すヘッダー（Set-Cookieなど閲覧者ごとのヘッダーは保持しない） */
+var cachedResponseHeaders = []string{"Content-Type", "Content-Disposition", "ETag", "Link", totalCountHeader}
+
+/*
+cachePolicy はルートのキャッシュの方針
+*/
+type cachePolicy struct {
+	TTL    time.Duration // Cache-Controlのmax-ageとサーバーで保持する期間（0の場合は "no-cache" でキャッシュしない）
+	Vary   []string      // キャッシュのキーに含めるクエリパラメータ（パスパラメータは常に含める）
+	Stale  time.Duration // TTL経過後、ハンドラーが5xxを返した場合に期限切れのレスポンスを返せる期間（0の場合は返さない）
+	Shared bool          // Cache-Controlを "public" にする（falseの場合は "private"、閲覧者によって内容が変わるレスポンス。requestCacheControlを参照）
+	Store  bool          // サーバーのメモリ上にレスポンスを保持する（falseの場合はCache-Controlのみ）
+}
+
+/*
+defaultCachePolicies はルート（BASE_PATHを除くルートのパターン）ごとのキャッシュの方針
+ここにないルートはキャッシュしない（ハンドラーがヘッダーを設定しない限りCache-Controlも付けない）
+
+注意:
+  - 保持したレスポンスのキーには、Varyのクエリパラメータに加えてデータの範囲（PRIVACY_MODE）・マスクの有無・
+    フィールド名の形式（?case=）・言語を含める
+  - リクエストのホスト名から組み立てたURLを含むレスポンス（robots.txt・sitemap.xml）は保持しない
+*/
+var defaultCachePolicies = map[string]cachePolicy{
+	"/api/activity":                  {TTL: 30 * time.Second, Vary: []string{"kind", "repo", "page", "per_page"}, Stale: 10 * time.Minute, Store: true},
+	"/api/stats/health":              {TTL: 5 * time.Minute, Vary: []string{"repo"}, Stale: time.Hour, Store: true},
+	"/api/stats/forecast":            {TTL: 5 * time.Minute, Vary: []string{"model"}, Stale: time.Hour, Store: true},
+	"/api/stats/keywords":            {TTL: 5 * time.Minute, Vary: []string{"limit", "repo"}, Stale: time.Hour, Store: true},
+	"/api/stats/working-hours":       {TTL: 5 * time.Minute, Vary: []string{"tz", "months"}, Stale: time.Hour, Store: true},
+	"/api/stats/labels":              {TTL: 5 * time.Minute, Vary: []string{"repo"}, Stale: time.Hour, Store: true},
+	"/api/stats/review-latency":      {TTL: 15 * time.Minute, Vary: []string{"months", "repo"}, Stale: time.Hour, Store: true},
+	"/api/stats/issues":              {TTL: 15 * time.Minute, Vary: []string{"months", "repo"}, Stale: time.Hour, Store: true},
+	"/api/stats/deployments":         {TTL: 15 * time.Minute, Vary: []string{"weeks", "repo"}, Stale: time.Hour, Store: true},
+	"/api/metrics/custom/:name":      {TTL: 5 * time.Minute, Stale: time.Hour, Store: true},
+	"/api/stats/windows":             {TTL: time.Minute, Vary: []string{"repo"}},
+	"/api/stats/followers":           {TTL: time.Minute, Vary: []string{"days"}},
+	"/api/digest":                    {TTL: 15 * time.Minute, Vary: []string{"week"}, Stale: 24 * time.Hour, Store: true},
+	"/api/wrapped/:year":             {TTL: time.Hour, Stale: 24 * time.Hour, Store: true},
+	"/api/profile":                   {TTL: 5 * time.Minute, Stale: time.Hour, Store: true},
+	"/api/repos/:owner/:repo/readme": {TTL: 10 * time.Minute, Stale: 24 * time.Hour, Store: true},
+	"/api/repos/:owner/:repo/files":  {TTL: 15 * time.Minute, Vary: []string{"sort"}, Stale: 24 * time.Hour, Store: true},
+	/* グラフの画像はREADMEの画像プロキシなどで使い回せるよう、共有キャッシュを許可する */
+	"/charts/activity.png":  {TTL: 15 * time.Minute, Vary: []string{"range", "repo", "style", "width", "height"}, Stale: time.Hour, Shared: true, Store: true},
+	"/charts/sparkline.svg": {TTL: 15 * time.Minute, Vary: []string{"days", "repo", "width", "height"}, Stale: time.Hour, Shared: true, Store: true},
+	"/img/proxy":            {TTL: 24 * time.Hour, Vary: []string{"url", "size"}, Stale: 7 * 24 * time.Hour, Shared: true, Store: true},
+	"/robots.txt":           {TTL: time.Hour, Shared: true},
+	"/sitemap.xml":          {TTL: time.Hour, Shared: true},
+	"/manifest.webmanifest": {TTL: time.Hour, Shared: true},
+	"/favicon.ico":          {TTL: 24 * time.Hour, Shared: true},
+	"/icons/:name":          {TTL: 24 * time.Hour, Shared: true},
+	/* Service Workerは更新がすぐに反映されるよう、ブラウザにキャッシュさせない */
+	"/sw.js": {},
+}
+
+var (
+	/*
+		cachePolicies は適用するキャッシュの方針
+		環境変数 RESPONSE_CACHE_POLICIES で上書き・追加できる。parseCachePoliciesを参照
+	*/
+	cachePolicies = parseCachePolicies(getEnv("RESPONSE_CACHE_POLICIES", ""))
+	/*
+		responseCacheMaxEntries はサーバーで保持するレスポンスの最大件数
+		環境変数 RESPONSE_CACHE_MAX_ENTRIES で変更可能（デフォルト: 1000、超えた場合は古いものから削除する）
+	*/
+	responseCacheMaxEntries = getEnvInt("RESPONSE_CACHE_MAX_ENTRIES", 1000)
+)
+
+var responseCacheRequestsTotal = newCounterVec(
+	"giter_response_cache_requests_total",
+	"Requests to routes with a cache policy by route and result (HIT, MISS, STALE).",
+	"route", "result",
+)
+
+/*
+parseCachePolicies はdefaultCachePoliciesに改行区切りの上書きを適用する
+各行は "ルート 項目=値 ..." の形式で、指定した項目のみ上書きする（ないルートは追加する）
+  - ttl=5m: 保持する期間（0でキャッシュしない）
+  - stale=1h: 期限切れのレスポンスを返せる期間
+  - vary=repo,limit: キーに含めるクエリパラメータ（"vary=" で空にする）
+  - shared=true: Cache-Controlをpublicにする（匿名の閲覧者より広いデータの範囲のリクエストにはprivateを付ける）
+  - store=false: サーバーで保持しない
+
+例:
+  RESPONSE_CACHE_POLICIES="/api/stats/health ttl=10m stale=6h
+  /api/activity ttl=0"
+
+注意:
+  - 解釈できない項目は警告を出力して無視する
+*/
+func parseCachePolicies(raw string) map[string]cachePolicy {
+	policies := map[string]cachePolicy{}
+	for route, policy := range defaultCachePolicies {
+		policies[route] = policy
+	}
+	for _, line := range strings.Split(raw, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		route, policy := fields[0], policies[fields[0]]
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(field, "=")
+			var err error
+			switch name {
+			case "ttl":
+				policy.TTL, err = time.ParseDuration(value)
+			case "stale":
+				policy.Stale, err = time.ParseDuration(value)
+			case "vary":
+				policy.Vary = splitList(value)
+			case "shared":
+				policy.Shared, err = strconv.ParseBool(value)
+			case "store":
+				policy.Store, err = strconv.ParseBool(value)
+			default:
+				err = fmt.Errorf("unknown field: %s", name)
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("route", route).Str("field", field).Msg("Ignoring invalid RESPONSE_CACHE_POLICIES entry")
+			}
+		}
+		policies[route] = policy
+	}
+	return policies
+}
+
+/* cacheControl はCache-Controlヘッダーの値を返す */
+func (p cachePolicy) cacheControl() string {
+	if p.TTL <= 0 {
+		return "no-cache"
+	}
+	scope := "private"
+	if p.Shared {
+		scope = "public"
+	}
+	value := fmt.Sprintf("%s, max-age=%d", scope, int(p.TTL.Seconds()))
+	if p.Stale > 0 {
+		value += fmt.Sprintf(", stale-if-error=%d", int(p.Stale.Seconds()))
+	}
+	return value
+}
+
+/*
+requestCacheControl はリクエストに付けるCache-Controlヘッダーの値を返す
+共有キャッシュを許可したルートでも、匿名の閲覧者より広いデータの範囲（reader・管理者）のリクエストには
+閲覧者によって内容が変わるため "private" を付ける（CDN・リバースプロキシが匿名の閲覧者に返さないようにする）
+*/
+func (p cachePolicy) requestCacheControl(c *gin.Context) string {
+	if p.Shared && requestVisibility(c) != backgroundVisibility() {
+		p.Shared = false
+	}
+	return p.cacheControl()
+}
+
+/* cachedResponse はサーバーで保持したレスポンス */
+type cachedResponse struct {
+	route    string      // ルート
+	status   int         // HTTPステータスコード
+	header   http.Header // cachedResponseHeadersのヘッダー
+	body     []byte      // レスポンスボディ
+	storedAt time.Time   // 保持した日時
+}
+
+/* responseCacheStats はルートごとのキャッシュの利用回数 */
+type responseCacheStats struct {
+	hits      int // 保持したレスポンスを返した回数
+	misses    int // ハンドラーを実行した回数
+	staleHits int // 期限切れのレスポンスを返した回数
+}
+
+/* responseCache はサーバーで保持したレスポンスのストア（メモリ上のみ、再起動すると失われる） */
+var responseCache = struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	stats   map[string]*responseCacheStats
+}{entries: map[string]*cachedResponse{}, stats: map[string]*responseCacheStats{}}
+
+/* responseCacheKey はルート・パスパラメータ・Varyのクエリパラメータ・閲覧者の表示の条件からキーを作成する */
+func responseCacheKey(c *gin.Context, route string, policy cachePolicy) string {
+	var b strings.Builder
+	b.WriteString(route)
+	for _, param := range c.Params {
+		fmt.Fprintf(&b, "\x00%s=%s", param.Key, param.Value)
+	}
+	query := c.Request.URL.Query()
+	for _, name := range policy.Vary {
+		fmt.Fprintf(&b, "\x00?%s=%s", name, strings.Join(query[name], ","))
+	}
+	fmt.Fprintf(&b, "\x00%d\x00%t\x00%s\x00%s", requestVisibility(c), bool(requestRedaction(c)), responseCase(c), requestLanguage(c))
+	return b.String()
+}
+
+/* countCacheResult はルートのキャッシュの利用結果を記録する（呼び出し元でmuをロックしていること） */
+func countCacheResult(route, result string) {
+	stats := responseCache.stats[route]
+	if stats == nil {
+		stats = &responseCacheStats{}
+		responseCache.stats[route] = stats
+	}
+	switch result {
+	case cacheResultHit:
+		stats.hits++
+	case cacheResultMiss:
+		stats.misses++
+	case cacheResultStale:
+		stats.staleHits++
+	}
+	responseCacheRequestsTotal.Inc(route, result)
+}
+
+/*
+storeCachedResponse はレスポンスを保持する（呼び出し元でmuをロックしていること）
+期限切れ（stale-if-errorの期間も過ぎた）レスポンスを削除し、なおresponseCacheMaxEntriesを超える場合は古いものから削除する
+*/
+func storeCachedResponse(key string, entry *cachedResponse, now time.Time) {
+	responseCache.entries[key] = entry
+	if len(responseCache.entries) <= responseCacheMaxEntries {
+		return
+	}
+	for k, e := range responseCache.entries {
+		policy := cachePolicies[e.route]
+		if now.Sub(e.storedAt) > policy.TTL+policy.Stale {
+			delete(responseCache.entries, k)
+		}
+	}
+	for len(responseCache.entries) > responseCacheMaxEntries {
+		oldestKey, oldest := "", now
+		for k, e := range responseCache.entries {
+			if !e.storedAt.After(oldest) {
+				oldestKey, oldest = k, e.storedAt
+			}
+		}
+		delete(responseCache.entries, oldestKey)
+	}
+}
+
+/*
+clearResponseCache は保持したすべてのレスポンスを破棄し、破棄した件数を返す
+返すデータの範囲が変わる設定の変更（閲覧者から隠すリポジトリなど）やデータの削除の後に呼び出す
+*/
+func clearResponseCache() int {
+	responseCache.mu.Lock()
+	defer responseCache.mu.Unlock()
+	n := len(responseCache.entries)
+	responseCache.entries = map[string]*cachedResponse{}
+	return n
+}
+
+/* responseCacheLen は保持しているレスポンスの件数を返す */
+func responseCacheLen() int {
+	responseCache.mu.Lock()
+	defer responseCache.mu.Unlock()
+	return len(responseCache.entries)
+}
+
+/* writeCachedResponse は保持したレスポンスを返す（If-None-MatchがETagと一致する場合は304） */
+func writeCachedResponse(c *gin.Context, entry *cachedResponse, result string) {
+	for _, name := range cachedResponseHeaders {
+		if value := entry.header.Get(name); value != "" {
+			c.Header(name, value)
+		}
+	}
+	c.Header(cacheHeader, result)
+	c.Header("Age", strconv.Itoa(int(time.Since(entry.storedAt).Seconds())))
+	if etag := entry.header.Get("ETag"); etag != "" && c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(entry.status, entry.header.Get("Content-Type"), entry.body)
+}
+
+/*
+responseCacheMiddleware はcachePoliciesに従ってレスポンスをキャッシュするミドルウェア
+
+注意:
+  - GETリクエストのみが対象
+  - 200以外のレスポンスは保持しない。4xx・5xxのレスポンスにはCache-Controlを付けない
+  - 機能フラグを上書きしたリクエスト（X-Giter-Features）は保持したレスポンスを使わない
+*/
+func responseCacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := strings.TrimPrefix(c.FullPath(), basePath)
+		policy, ok := cachePolicies[route]
+		if !ok || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		c.Header("Cache-Control", policy.requestCacheControl(c))
+		if policy.Shared {
+			/* 共有キャッシュが認証したリクエストと匿名のリクエストを区別できるようにする */
+			c.Writer.Header().Add("Vary", "Authorization, Cookie")
+		}
+		store := policy.Store && policy.TTL > 0 && c.GetHeader(featureOverrideHeader) == ""
+
+		var key string
+		var stale *cachedResponse
+		if store {
+			key = responseCacheKey(c, route, policy)
+			responseCache.mu.Lock()
+			entry := responseCache.entries[key]
+			if entry != nil && time.Since(entry.storedAt) < policy.TTL {
+				countCacheResult(route, cacheResultHit)
+				responseCache.mu.Unlock()
+				writeCachedResponse(c, entry, cacheResultHit)
+				c.Abort()
+				return
+			}
+			if entry != nil && time.Since(entry.storedAt) < policy.TTL+policy.Stale {
+				stale = entry
+			}
+			countCacheResult(route, cacheResultMiss)
+			responseCache.mu.Unlock()
+		}
+
+		/* 5xxの場合に期限切れのレスポンスに差し替えられるよう、書き込みを保留する */
+		writer := &bufferedResponseWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		status := writer.status
+		if status >= http.StatusInternalServerError && stale != nil {
+			responseCache.mu.Lock()
+			countCacheResult(route, cacheResultStale)
+			responseCache.mu.Unlock()
+			log.Warn().Str("route", route).Int("status", status).Msg("Serving stale cached response")
+			writeCachedResponse(c, stale, cacheResultStale)
+			return
+		}
+		if status >= http.StatusBadRequest {
+			c.Writer.Header().Del("Cache-Control")
+		}
+		if store {
+			c.Writer.Header().Set(cacheHeader, cacheResultMiss)
+		}
+		if store && status == http.StatusOK {
+			header := http.Header{}
+			for _, name := range cachedResponseHeaders {
+				if value := c.Writer.Header().Get(name); value != "" {
+					header.Set(name, value)
+				}
+			}
+			responseCache.mu.Lock()
+			storeCachedResponse(key, &cachedResponse{route: route, status: status, header: header, body: writer.body.Bytes(), storedAt: time.Now()}, time.Now())
+			responseCache.mu.Unlock()
+		}
+		c.Writer.WriteHeader(status)
+		if writer.body.Len() > 0 {
+			c.Writer.Write(writer.body.Bytes())
+		}
+	}
+}
+
+/*
+bufferedResponseWriter はステータスコードとレスポンスボディをクライアントに書き込まずに保持するgin.ResponseWriter
+ヘッダーは元のResponseWriterに設定する
+*/
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.written
+}
+
+func (w *bufferedResponseWriter) Flush() {}
+
+/* CachePolicyStatus は /api/admin/cache/policies で返すルートのキャッシュの方針と利用状況 */
+type CachePolicyStatus struct {
+	Route        string   `json:"route"`         // ルート
+	TTL          string   `json:"ttl"`           // 保持する期間（"0s" の場合はキャッシュしない）
+	Stale        string   `json:"stale"`         // 期限切れのレスポンスを返せる期間
+	Vary         []string `json:"vary"`          // キーに含めるクエリパラメータ
+	Shared       bool     `json:"shared"`        // Cache-Controlがpublicかどうか
+	Store        bool     `json:"store"`         // サーバーで保持するかどうか
+	CacheControl string   `json:"cache_control"` // 付けるCache-Controlヘッダー
+	Entries      int      `json:"entries"`       // 保持しているレスポンスの件数
+	Hits         int      `json:"hits"`          // 起動後に保持したレスポンスを返した回数
+	Misses       int      `json:"misses"`        // 起動後にハンドラーを実行した回数
+	StaleHits    int      `json:"stale_hits"`    // 起動後に期限切れのレスポンスを返した回数
+}
+
+/*
+getCachePolicies はルートごとのキャッシュの方針と利用状況を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"policies": [CachePolicyStatus（ルート順）], "entries": 保持しているレスポンスの件数, "max_entries": RESPONSE_CACHE_MAX_ENTRIES}
+*/
+func getCachePolicies(c *gin.Context) {
+	responseCache.mu.Lock()
+	entries := map[string]int{}
+	for _, entry := range responseCache.entries {
+		entries[entry.route]++
+	}
+	policies := make([]CachePolicyStatus, 0, len(cachePolicies))
+	for route, policy := range cachePolicies {
+		status := CachePolicyStatus{
+			Route:        route,
+			TTL:          policy.TTL.String(),
+			Stale:        policy.Stale.String(),
+			Vary:         policy.Vary,
+			Shared:       policy.Shared,
+			Store:        policy.Store,
+			CacheControl: policy.cacheControl(),
+			Entries:      entries[route],
+		}
+		if status.Vary == nil {
+			status.Vary = []string{}
+		}
+		if stats := responseCache.stats[route]; stats != nil {
+			status.Hits, status.Misses, status.StaleHits = stats.hits, stats.misses, stats.staleHits
+		}
+		policies = append(policies, status)
+	}
+	total := len(responseCache.entries)
+	responseCache.mu.Unlock()
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Route < policies[j].Route })
+	respondJSON(c, http.StatusOK, gin.H{"policies": policies, "entries": total, "max_entries": responseCacheMaxEntries})
+}
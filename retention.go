@@ -0,0 +1,299 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* 保持期間を適用するデータの種類 */
+const (
+	retentionTargetCommits   = "commits"   // アーカイブ（HistoryStore）のコミット（コミット日時で判定）
+	retentionTargetAuditLog  = "audit_log" // 監査ログ（操作した日時で判定）
+	retentionTargetSnapshots = "snapshots" // スナップショット（作成日時で判定）
+	retentionTargetLogs      = "logs"      // ログファイル（log/YYYYMM/YYYYMMDD の日付で判定）
+)
+
+var (
+	/*
+		retentionPolicies はデータの種類ごとの保持期間（日数、0の場合は期間で削除しない）
+		環境変数 RETENTION_COMMITS_DAYS / RETENTION_AUDIT_DAYS / RETENTION_SNAPSHOTS_DAYS / RETENTION_LOGS_DAYS で設定する
+	*/
+	retentionPolicies = []RetentionPolicy{
+		{Target: retentionTargetCommits, KeepDays: getEnvInt("RETENTION_COMMITS_DAYS", 0)},
+		{Target: retentionTargetAuditLog, KeepDays: getEnvInt("RETENTION_AUDIT_DAYS", 0)},
+		{Target: retentionTargetSnapshots, KeepDays: getEnvInt("RETENTION_SNAPSHOTS_DAYS", 0)},
+		{Target: retentionTargetLogs, KeepDays: getEnvInt("RETENTION_LOGS_DAYS", 0)},
+	}
+	/*
+		retentionInterval は保持期間を過ぎたデータを削除する間隔
+		環境変数 RETENTION_INTERVAL で変更可能（デフォルト: 24時間、0の場合は定期的に削除しない）
+	*/
+	retentionInterval = parseDurationEnv("RETENTION_INTERVAL", 24*time.Hour)
+	/*
+		retentionDryRun は定期的な削除を削除する件数のログ出力のみにするかどうか
+		環境変数 RETENTION_DRY_RUN で変更可能（デフォルト: false）
+	*/
+	retentionDryRun = getEnvBool("RETENTION_DRY_RUN", false)
+)
+
+/* RetentionPolicy はデータの種類ごとの保持期間 */
+type RetentionPolicy struct {
+	Target   string `json:"target"`    // データの種類（retentionTarget* 定数）
+	KeepDays int    `json:"keep_days"` // 保持する日数（0の場合は期間で削除しない）
+}
+
+/* RetentionResult はデータの種類ごとの削除の結果 */
+type RetentionResult struct {
+	RetentionPolicy
+	Cutoff time.Time `json:"cutoff"`          // これより前のデータを削除する
+	Count  int       `json:"count"`           // 削除した件数（dry-runの場合は削除する件数）
+	Error  string    `json:"error,omitempty"` // 削除に失敗した場合のエラー
+}
+
+/* RetentionReport は保持期間の適用1回分の結果 */
+type RetentionReport struct {
+	DryRun  bool              `json:"dry_run"` // trueの場合は削除せずに件数のみ数えた
+	RanAt   time.Time         `json:"ran_at"`  // 実行した日時
+	Results []RetentionResult `json:"results"` // 保持期間を設定したデータの種類ごとの結果
+}
+
+/* lastRetentionReport は最後に保持期間を適用した結果（dry-runを含む、再起動で失われる） */
+var lastRetentionReport = struct {
+	mu     sync.Mutex
+	report *RetentionReport
+}{}
+
+/*
+applyRetention は保持期間を過ぎたデータを削除する
+保持期間が0の種類は対象外。1つの種類で失敗しても残りの種類は続ける
+
+引数:
+  now time.Time - 保持期間の起点
+  dryRun bool - trueの場合は削除せずに件数のみ数える
+*/
+func applyRetention(now time.Time, dryRun bool) RetentionReport {
+	report := RetentionReport{DryRun: dryRun, RanAt: now.UTC(), Results: []RetentionResult{}}
+	for _, policy := range retentionPolicies {
+		if policy.KeepDays <= 0 {
+			continue
+		}
+		result := RetentionResult{RetentionPolicy: policy, Cutoff: now.AddDate(0, 0, -policy.KeepDays).UTC()}
+		var err error
+		switch policy.Target {
+		case retentionTargetCommits:
+			result.Count, err = pruneArchivedCommits(result.Cutoff, dryRun)
+		case retentionTargetAuditLog:
+			result.Count = auditLog.prune(result.Cutoff, dryRun)
+		case retentionTargetSnapshots:
+			result.Count = snapshots.prune(result.Cutoff, dryRun)
+		case retentionTargetLogs:
+			result.Count, err = pruneLogDirs(result.Cutoff, dryRun)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("target", policy.Target).Msg("Failed to apply retention policy")
+			result.Error = err.Error()
+		}
+		log.Info().Str("target", policy.Target).Time("cutoff", result.Cutoff).Int("count", result.Count).Bool("dry_run", dryRun).Msg("Retention policy applied")
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+/* runRetention は保持期間を適用し、結果をlast_runとして記録する（定期実行と POST /api/admin/retention で使用） */
+func runRetention(now time.Time, dryRun bool) RetentionReport {
+	report := applyRetention(now, dryRun)
+	lastRetentionReport.mu.Lock()
+	lastRetentionReport.report = &report
+	lastRetentionReport.mu.Unlock()
+	return report
+}
+
+/* pruneArchivedCommits はアーカイブからcutoffより前のコミットを削除する */
+func pruneArchivedCommits(cutoff time.Time, dryRun bool) (int, error) {
+	if !dryRun {
+		defer commitSearch.reset()
+		defer statsViews.reset()
+		return history.Prune(cutoff)
+	}
+	archived, err := history.LoadAll()
+	if err != nil {
+		return 0, err
+	}
+	return len(archived) - len(commitsSince(archived, cutoff)), nil
+}
+
+/* prune はcutoffより前の監査ログを削除する */
+func (s *auditStore) prune(cutoff time.Time, dryRun bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := []AuditEntry{}
+	for _, entry := range s.Entries {
+		if !entry.Time.Before(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	pruned := len(s.Entries) - len(kept)
+	if !dryRun && pruned > 0 {
+		s.Entries = kept
+		s.save()
+	}
+	return pruned
+}
+
+/* prune はcutoffより前に作成したスナップショットを削除する（SNAPSHOT_RETENTIONの件数の上限とは別に適用する） */
+func (s *snapshotStore) prune(cutoff time.Time, dryRun bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := []Snapshot{}
+	for _, snapshot := range s.Snapshots {
+		if !snapshot.CreatedAt.Before(cutoff) {
+			kept = append(kept, snapshot)
+		}
+	}
+	pruned := len(s.Snapshots) - len(kept)
+	if !dryRun && pruned > 0 {
+		s.Snapshots = kept
+		if err := saveTable(snapshotsTable, s); err != nil {
+			log.Error().Err(err).Msg("Failed to save snapshots")
+		}
+	}
+	return pruned
+}
+
+/*
+pruneLogDirs はcutoffより前の日付のログディレクトリ（log/YYYYMM/YYYYMMDD）を削除する
+日付のディレクトリが空になった月のディレクトリも削除する
+
+戻り値:
+  int - 削除した日付のディレクトリ数
+*/
+func pruneLogDirs(cutoff time.Time, dryRun bool) (int, error) {
+	months, err := os.ReadDir(logRootDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, month := range months {
+		if !month.IsDir() {
+			continue
+		}
+		monthDir := filepath.Join(logRootDir, month.Name())
+		days, err := os.ReadDir(monthDir)
+		if err != nil {
+			return pruned, err
+		}
+		remaining := len(days)
+		for _, day := range days {
+			date, err := time.ParseInLocation("20060102", day.Name(), time.Local)
+			/* ログは日付ごとのため、その日の終わりがcutoffより前の場合のみ削除する */
+			if !day.IsDir() || err != nil || !date.AddDate(0, 0, 1).Before(cutoff) {
+				continue
+			}
+			pruned++
+			if dryRun {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(monthDir, day.Name())); err != nil {
+				return pruned, err
+			}
+			remaining--
+		}
+		if !dryRun && remaining == 0 && len(days) > 0 {
+			if err := os.Remove(monthDir); err != nil {
+				return pruned, err
+			}
+		}
+	}
+	return pruned, nil
+}
+
+/*
+startRetentionScheduler はretentionIntervalごとに保持期間を過ぎたデータを削除するゴルーチンを起動する
+間隔が0の場合や、保持期間がどの種類にも設定されていない場合は何もしない
+*/
+func startRetentionScheduler() {
+	if retentionInterval <= 0 || !retentionConfigured() {
+		return
+	}
+	log.Info().Dur("interval", retentionInterval).Bool("dry_run", retentionDryRun).Msg("Retention scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if pausedForMaintenance(lockNameRetention) {
+				continue
+			}
+			runExclusive(lockNameRetention, func() {
+				runRetention(time.Now(), retentionDryRun)
+			})
+		}
+	}()
+}
+
+/* retentionConfigured は保持期間がいずれかの種類に設定されているかどうかを返す */
+func retentionConfigured() bool {
+	for _, policy := range retentionPolicies {
+		if policy.KeepDays > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+getRetention は保持期間の設定と、いま適用した場合に削除する件数（dry-run）を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"policies": []RetentionPolicy, "interval": "24h0m0s", "dry_run": bool, "preview": RetentionReport, "last_run": RetentionReport（未実行の場合はnull）}
+*/
+func getRetention(c *gin.Context) {
+	lastRetentionReport.mu.Lock()
+	last := lastRetentionReport.report
+	lastRetentionReport.mu.Unlock()
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"policies": retentionPolicies,
+		"interval": retentionInterval.String(),
+		"dry_run":  retentionDryRun,
+		"preview":  applyRetention(time.Now(), true),
+		"last_run": last,
+	})
+}
+
+/*
+postRetention は保持期間を過ぎたデータをその場で削除する管理者APIハンドラー
+
+クエリパラメータ:
+  dry_run bool - trueの場合は削除せずに件数のみ返す（デフォルト: false）
+
+レスポンス:
+  成功時: 200 OK, RetentionReport
+  失敗時: 409 Conflict（定期実行または他のレプリカで実行中）
+*/
+func postRetention(c *gin.Context) {
+	var query struct {
+		DryRun bool `form:"dry_run"`
+	}
+	if !bindQuery(c, &query) {
+		return
+	}
+	var report RetentionReport
+	if !runExclusive(lockNameRetention, func() { report = runRetention(time.Now(), query.DryRun) }) {
+		respondError(c, http.StatusConflict, "retention is already running")
+		return
+	}
+	respondJSON(c, http.StatusOK, report)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* defaultReviewLatencyMonths は集計する月数のデフォルト */
+	defaultReviewLatencyMonths = 6
+)
+
+/*
+reviewLatencyMaxPulls はリポジトリごとにレビューを取得するプルリクエストの上限（新しい順）
+環境変数 REVIEW_LATENCY_MAX_PULLS で変更可能（デフォルト: 30）
+プルリクエストごとにレビューの一覧を取得するため、初回はリポジトリ数×この値のリクエストが発生する
+*/
+var reviewLatencyMaxPulls = getEnvInt("REVIEW_LATENCY_MAX_PULLS", 30)
+
+/*
+PullRequestReview はGitHub APIから取得するプルリクエストのレビューを表す構造体
+API仕様: https://docs.github.com/ja/rest/pulls/reviews#list-reviews-for-a-pull-request
+*/
+type PullRequestReview struct {
+	User        githubUser `json:"user"`         // レビューしたユーザー
+	State       string     `json:"state"`        // "APPROVED", "CHANGES_REQUESTED", "COMMENTED" など
+	SubmittedAt *time.Time `json:"submitted_at"` // 送信日時（送信前のレビューの場合はnull）
+}
+
+/* reviewLatencyParams は /api/stats/review-latency のクエリパラメータ */
+type reviewLatencyParams struct {
+	Months int    `form:"months" binding:"min=1,max=24"`
+	Repo   string `form:"repo"`
+}
+
+/* LatencyPercentiles は所要時間（時間）のパーセンタイル */
+type LatencyPercentiles struct {
+	Count int      `json:"count"`     // 集計したプルリクエスト数
+	P50   *float64 `json:"p50_hours"` // 中央値（データがない場合はnull）
+	P75   *float64 `json:"p75_hours"` // 75パーセンタイル
+	P90   *float64 `json:"p90_hours"` // 90パーセンタイル
+}
+
+/* ReviewLatency はプルリクエストの最初のレビューまでとマージまでの所要時間 */
+type ReviewLatency struct {
+	PullRequests      int                `json:"pull_requests"`        // 集計の対象のプルリクエスト数
+	TimeToFirstReview LatencyPercentiles `json:"time_to_first_review"` // 作成から最初のレビューまで
+	TimeToMerge       LatencyPercentiles `json:"time_to_merge"`        // 作成からマージまで
+}
+
+/* RepositoryReviewLatency はリポジトリごとの所要時間 */
+type RepositoryReviewLatency struct {
+	Repo string `json:"repo"` // リポジトリ名
+	ReviewLatency
+}
+
+/* MonthlyReviewLatency は月ごと（プルリクエストを作成した月）の所要時間 */
+type MonthlyReviewLatency struct {
+	Month string `json:"month"` // 月（"2006-01"形式）
+	ReviewLatency
+}
+
+/* ReviewLatencyResponse は /api/stats/review-latency のレスポンス */
+type ReviewLatencyResponse struct {
+	GeneratedAt        time.Time                 `json:"generated_at"`                  // 集計した日時
+	Since              time.Time                 `json:"since"`                         // 集計の対象の期間の開始（この日時以降に作成したプルリクエスト）
+	Total              ReviewLatency             `json:"total"`                         // 全リポジトリ
+	Repositories       []RepositoryReviewLatency `json:"repositories"`                  // リポジトリごと（リポジトリ名順、プルリクエストのないリポジトリは含めない）
+	Monthly            []MonthlyReviewLatency    `json:"monthly"`                       // 月ごとの推移（古い順、今月を含む）
+	FailedRepositories []string                  `json:"failed_repositories,omitempty"` // プルリクエストを取得できなかったリポジトリ
+}
+
+/* pullRequestLatency は1件のプルリクエストの所要時間 */
+type pullRequestLatency struct {
+	createdAt   time.Time
+	firstReview *time.Duration // 最初のレビューまで（レビューがない場合はnil）
+	merge       *time.Duration // マージまで（未マージの場合はnil）
+}
+
+/* latencySamples は所要時間を集計するためのサンプル */
+type latencySamples struct {
+	pulls        int
+	firstReviews []float64
+	merges       []float64
+}
+
+/* add はプルリクエストの所要時間をサンプルに加える */
+func (s *latencySamples) add(pull pullRequestLatency) {
+	s.pulls++
+	if pull.firstReview != nil {
+		s.firstReviews = append(s.firstReviews, pull.firstReview.Hours())
+	}
+	if pull.merge != nil {
+		s.merges = append(s.merges, pull.merge.Hours())
+	}
+}
+
+/* result はサンプルからパーセンタイルを計算する */
+func (s *latencySamples) result() ReviewLatency {
+	return ReviewLatency{
+		PullRequests:      s.pulls,
+		TimeToFirstReview: latencyPercentiles(s.firstReviews),
+		TimeToMerge:       latencyPercentiles(s.merges),
+	}
+}
+
+/* latencyPercentiles は所要時間（時間）のパーセンタイルを計算する（小数第1位に丸める） */
+func latencyPercentiles(hours []float64) LatencyPercentiles {
+	result := LatencyPercentiles{Count: len(hours)}
+	if len(hours) == 0 {
+		return result
+	}
+	sorted := append([]float64(nil), hours...)
+	sort.Float64s(sorted)
+	rank := func(p float64) *float64 {
+		/* nearest-rank法（サンプルにある値のいずれかを返す） */
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		v := math.Round(sorted[max(i, 0)]*10) / 10
+		return &v
+	}
+	result.P50, result.P75, result.P90 = rank(0.5), rank(0.75), rank(0.9)
+	return result
+}
+
+/*
+fetchPullRequestReviews は指定されたプルリクエストのレビューを取得する（最大100件）
+
+引数:
+  repoFullName string - リポジトリのフルネーム（例: "develop-suda/project-name"）
+  number int - プルリクエストの番号
+*/
+func fetchPullRequestReviews(repoFullName string, number int) ([]PullRequestReview, error) {
+	var reviews []PullRequestReview
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/reviews?per_page=100", githubAPIBase, repoFullName, number)
+	if err := fetchGitHubJSON(upstreamOpActivity, url, "", &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+/*
+repositoryPullRequestLatencies はリポジトリのsince以降に作成したプルリクエストの所要時間を返す
+新しい順にreviewLatencyMaxPulls件までを対象にする
+
+注意:
+  - 作成者自身のレビュー（コメントへの返信など）と、送信前のレビューは最初のレビューに数えない
+  - レビューの取得に失敗したプルリクエストは、マージまでの所要時間のみ集計する
+*/
+func repositoryPullRequestLatencies(repo Repository, since time.Time) ([]pullRequestLatency, error) {
+	pulls, err := fetchPullRequests(repo.FullName)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pulls, func(i, j int) bool { return pulls[i].CreatedAt.After(pulls[j].CreatedAt) })
+
+	var latencies []pullRequestLatency
+	for _, pull := range pulls {
+		if pull.CreatedAt.Before(since) || len(latencies) >= reviewLatencyMaxPulls {
+			break
+		}
+		latency := pullRequestLatency{createdAt: pull.CreatedAt}
+		if pull.MergedAt != nil {
+			d := pull.MergedAt.Sub(pull.CreatedAt)
+			latency.merge = &d
+		}
+
+		reviews, err := fetchPullRequestReviews(repo.FullName, pull.Number)
+		if err != nil {
+			log.Warn().Err(err).Str("repository", repo.Name).Int("pull_request", pull.Number).Msg("Failed to fetch pull request reviews")
+		}
+		for _, review := range reviews {
+			if review.SubmittedAt == nil || review.User.Login == pull.User.Login {
+				continue
+			}
+			if d := review.SubmittedAt.Sub(pull.CreatedAt); latency.firstReview == nil || d < *latency.firstReview {
+				latency.firstReview = &d
+			}
+		}
+		latencies = append(latencies, latency)
+	}
+	return latencies, nil
+}
+
+/*
+getReviewLatency はプルリクエストの最初のレビューまでとマージまでの所要時間のパーセンタイルを返すAPIハンドラー
+全体・リポジトリごと・月ごと（プルリクエストを作成した月、STATS_TIMEZONE基準）に集計する
+
+クエリパラメータ:
+  months int - 集計する月数（1〜24、デフォルト: 6、今月を含む）
+  repo string - リポジトリ名で絞り込む
+
+レスポンス:
+  成功時: 200 OK, ReviewLatencyResponse
+  失敗時: 422 Unprocessable Entity（不正なmonths）, 502 Bad Gateway（GitHubからリポジトリ一覧を取得できない）
+
+注意:
+  - GitHub APIから取得できるのは1リポジトリあたり最新100件のプルリクエストまでのため、古い月ほど一部が欠けることがある
+  - プルリクエストを取得できなかったリポジトリは failed_repositories に含め、集計から除く
+*/
+func getReviewLatency(c *gin.Context) {
+	params := reviewLatencyParams{Months: defaultReviewLatencyMonths}
+	if !bindQuery(c, &params) {
+		return
+	}
+	repos, err := fetchVisibleRepositories(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch repositories for review latency")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if name := params.Repo; name != "" {
+		var filtered []Repository
+		for _, repo := range repos {
+			if repo.Name == name {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+
+	now := time.Now().In(statsLocation)
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, statsLocation).AddDate(0, 1-params.Months, 0)
+
+	latencies := make([][]pullRequestLatency, len(repos))
+	errs := make([]error, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		latencies[i], errs[i] = repositoryPullRequestLatencies(repos[i], since)
+	})
+
+	resp := ReviewLatencyResponse{
+		GeneratedAt:  now,
+		Since:        since,
+		Repositories: []RepositoryReviewLatency{},
+		Monthly:      make([]MonthlyReviewLatency, params.Months),
+	}
+	var total latencySamples
+	monthly := make([]latencySamples, params.Months)
+	index := map[string]int{}
+	for i := range resp.Monthly {
+		month := since.AddDate(0, i, 0).Format("2006-01")
+		resp.Monthly[i].Month = month
+		index[month] = i
+	}
+
+	for i, repo := range repos {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Str("repository", repo.Name).Msg("Failed to fetch pull requests for review latency")
+			resp.FailedRepositories = append(resp.FailedRepositories, repo.Name)
+			continue
+		}
+		if len(latencies[i]) == 0 {
+			continue
+		}
+		var samples latencySamples
+		for _, pull := range latencies[i] {
+			samples.add(pull)
+			total.add(pull)
+			if m, ok := index[pull.createdAt.In(statsLocation).Format("2006-01")]; ok {
+				monthly[m].add(pull)
+			}
+		}
+		resp.Repositories = append(resp.Repositories, RepositoryReviewLatency{Repo: repo.Name, ReviewLatency: samples.result()})
+	}
+
+	resp.Total = total.result()
+	for i := range resp.Monthly {
+		resp.Monthly[i].ReviewLatency = monthly[i].result()
+	}
+	sort.Slice(resp.Repositories, func(i, j int) bool { return resp.Repositories[i].Repo < resp.Repositories[j].Repo })
+	respondJSON(c, http.StatusOK, resp)
+}
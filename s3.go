@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+errObjectNotFound はS3上に指定したキーのオブジェクトが存在しない場合のエラー
+*/
+var errObjectNotFound = errors.New("object not found")
+
+/*
+s3Client はS3互換ストレージ（AWS S3、MinIOなど）の最小限のクライアント
+外部SDKに依存せず、AWS Signature Version 4で署名したREST APIリクエストを送信する
+バケットはパス形式（https://endpoint/bucket/key）でアクセスするため、
+MinIOなど仮想ホスト形式に対応していないサービスでも利用できる
+*/
+type s3Client struct {
+	endpoint     *url.URL     // APIエンドポイント（例: https://s3.ap-northeast-1.amazonaws.com）
+	region       string       // リージョン（署名に使用）
+	bucket       string       // バケット名
+	accessKey    string       // アクセスキーID
+	secretKey    string       // シークレットアクセスキー
+	sessionToken string       // 一時認証情報のセッショントークン（任意）
+	httpClient   *http.Client // リクエストに使用するHTTPクライアント
+}
+
+/*
+S3Object はListObjectsV2で取得するオブジェクトの情報
+*/
+type S3Object struct {
+	Key          string    `xml:"Key"`          // オブジェクトキー
+	LastModified time.Time `xml:"LastModified"` // 最終更新日時
+	Size         int64     `xml:"Size"`         // サイズ（バイト）
+}
+
+/*
+newS3ClientFromEnv は標準的なAWSの環境変数からS3クライアントを作成する
+
+使用する環境変数:
+  AWS_ACCESS_KEY_ID     - アクセスキーID（必須）
+  AWS_SECRET_ACCESS_KEY - シークレットアクセスキー（必須）
+  AWS_SESSION_TOKEN     - セッショントークン（任意）
+  AWS_REGION            - リージョン（デフォルト: us-east-1）
+  S3_ENDPOINT           - エンドポイントURL（MinIO等で指定、デフォルトはAWSのリージョンエンドポイント）
+
+引数:
+  bucket string - バケット名
+
+戻り値:
+  *s3Client - S3クライアント
+  error - 認証情報が未設定、またはエンドポイントURLが不正な場合のエラー
+*/
+func newS3ClientFromEnv(bucket string) (*s3Client, error) {
+	region := getEnv("AWS_REGION", "us-east-1")
+	accessKey := getEnv("AWS_ACCESS_KEY_ID", "")
+	secretKey := getEnv("AWS_SECRET_ACCESS_KEY", "")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for S3 storage")
+	}
+
+	endpoint, err := url.Parse(getEnv("S3_ENDPOINT", fmt.Sprintf("https://s3.%s.amazonaws.com", region)))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %s", getEnv("S3_ENDPOINT", ""))
+	}
+
+	return &s3Client{
+		endpoint:     endpoint,
+		region:       region,
+		bucket:       bucket,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: getEnv("AWS_SESSION_TOKEN", ""),
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+/* PutObject はオブジェクトをアップロードする（同じキーが存在する場合は上書き） */
+func (c *s3Client) PutObject(key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do("PUT", key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkS3Response(resp)
+}
+
+/*
+GetObject はオブジェクトの内容を取得する
+
+戻り値:
+  []byte - オブジェクトの内容
+  error - オブジェクトが存在しない場合は errObjectNotFound
+*/
+func (c *s3Client) GetObject(key string) ([]byte, error) {
+	resp, err := c.do("GET", key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errObjectNotFound
+	}
+	if err := checkS3Response(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+/* DeleteObject はオブジェクトを削除する（存在しないキーの削除もエラーにならない） */
+func (c *s3Client) DeleteObject(key string) error {
+	resp, err := c.do("DELETE", key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkS3Response(resp)
+}
+
+/*
+ListObjects は指定したプレフィックスで始まるオブジェクトの一覧を取得する
+1回のレスポンスは最大1000件のため、継続トークンを使って全件取得する
+*/
+func (c *s3Client) ListObjects(prefix string) ([]S3Object, error) {
+	var objects []S3Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do("GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents              []S3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		err = checkS3Response(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+/*
+do は署名付きのS3 APIリクエストを送信する
+
+引数:
+  method string - HTTPメソッド
+  key string - オブジェクトキー（空文字の場合はバケット自体へのリクエスト）
+  query url.Values - クエリパラメータ（nil可）
+  header http.Header - 追加のリクエストヘッダー（nil可）
+  body []byte - リクエストボディ（nil可）
+*/
+func (c *s3Client) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req, body, time.Now().UTC())
+	return c.httpClient.Do(req)
+}
+
+/*
+sign はリクエストにAWS Signature Version 4の署名ヘッダーを付与する
+仕様: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+
+署名の手順:
+1. 正規リクエスト（メソッド、パス、クエリ、署名対象ヘッダー、ボディのハッシュ）を作成
+2. 日付・リージョン・サービスのスコープと正規リクエストのハッシュから署名文字列を作成
+3. シークレットキーから日付・リージョン・サービスの順にHMACで導出した鍵で署名
+*/
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	/* 署名対象ヘッダーは小文字化してソートする */
+	var names []string
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+/* checkS3Response はステータスコードが2xx以外の場合にレスポンスボディを含むエラーを返す */
+func checkS3Response(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("S3 API error: %s - %s", resp.Status, string(body))
+}
+
+/*
+s3EscapePath はパスの各セグメントをSigV4の規則でURIエンコードする
+S3ではスラッシュはエンコードせず、非予約文字（A-Z a-z 0-9 - _ . ~）以外をエンコードする
+*/
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = s3Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+/*
+s3CanonicalQuery はクエリパラメータをキーでソートし、SigV4の規則でエンコードする
+url.Values.Encode()は空白を "+" にするため、署名と一致させるために独自に組み立てる
+*/
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+/* s3Escape は非予約文字以外を %XX 形式（大文字の16進数）にエンコードする */
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+/* sha256Hex はデータのSHA-256ハッシュを16進数文字列で返す */
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/* hmacSHA256 はHMAC-SHA256を計算する */
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* savedSearchesTable は保存した検索条件を保存するテーブル名 */
+	savedSearchesTable = "saved_searches"
+)
+
+/* 保存した検索条件の範囲 */
+const (
+	savedSearchScopeViewer    = "viewer"    // 保存した閲覧者のみが使用できる
+	savedSearchScopeWorkspace = "workspace" // すべての閲覧者が使用できる（作成・更新・削除は管理者のみ）
+)
+
+/* savedSearchNamePattern は検索条件の名前の形式（?saved= に指定するため英小文字・数字・ハイフンのみ） */
+var savedSearchNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+/*
+savedSearchParams は検索条件に保存できる /api/git-history のクエリパラメータ
+page・cursorはページの位置のため保存しない
+*/
+var savedSearchParams = []string{"repo", "label", "include_archive", "per_page"}
+
+/* errSavedSearchNotFound は指定した名前の検索条件がない場合のエラー */
+var errSavedSearchNotFound = fmt.Errorf("saved search not found")
+
+/*
+SavedSearch は名前を付けて保存した /api/git-history の検索条件
+?saved=<名前> を指定すると、保存したクエリパラメータで履歴を取得する
+*/
+type SavedSearch struct {
+	Name      string            `json:"name"`       // 名前（例: weekly-review）
+	Scope     string            `json:"scope"`      // 範囲（"viewer" または "workspace"）
+	Params    map[string]string `json:"params"`     // クエリパラメータ（savedSearchParamsのみ）
+	CreatedAt time.Time         `json:"created_at"` // 作成日時
+	UpdatedAt time.Time         `json:"updated_at"` // 更新日時
+}
+
+/* SavedSearchList は /api/saved-searches のレスポンス */
+type SavedSearchList struct {
+	SavedSearches []SavedSearch `json:"saved_searches"` // 閲覧者の検索条件とworkspaceの検索条件（範囲・名前順）
+}
+
+/*
+savedSearchStore は保存した検索条件を保持するストア
+saved_searchesテーブルに永続化される
+*/
+type savedSearchStore struct {
+	mu sync.Mutex
+	/* Workspace は名前ごとのworkspaceの検索条件 */
+	Workspace map[string]SavedSearch `json:"workspace"`
+	/* Viewers は閲覧者IDごとの、名前ごとの検索条件 */
+	Viewers map[string]map[string]SavedSearch `json:"viewers"`
+}
+
+/* savedSearches はアプリケーション全体で共有する検索条件のストア */
+var savedSearches = &savedSearchStore{Workspace: map[string]SavedSearch{}, Viewers: map[string]map[string]SavedSearch{}}
+
+func init() {
+	registerTable(savedSearchesTable, loadSavedSearches)
+}
+
+/*
+loadSavedSearches はsaved_searchesテーブルから検索条件を復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadSavedSearches() error {
+	savedSearches.mu.Lock()
+	defer savedSearches.mu.Unlock()
+
+	savedSearches.Workspace, savedSearches.Viewers = nil, nil
+	if err := loadTable(savedSearchesTable, savedSearches); err != nil {
+		return err
+	}
+	if savedSearches.Workspace == nil {
+		savedSearches.Workspace = map[string]SavedSearch{}
+	}
+	if savedSearches.Viewers == nil {
+		savedSearches.Viewers = map[string]map[string]SavedSearch{}
+	}
+	return nil
+}
+
+/* save は検索条件のストアをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *savedSearchStore) save() {
+	if err := saveTable(savedSearchesTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save saved searches")
+	}
+}
+
+/* entries は範囲に対応する名前ごとの検索条件を返す（呼び出し元でmuをロックしていること） */
+func (s *savedSearchStore) entries(viewer, scope string, create bool) map[string]SavedSearch {
+	if scope == savedSearchScopeWorkspace {
+		return s.Workspace
+	}
+	if s.Viewers[viewer] == nil && create {
+		s.Viewers[viewer] = map[string]SavedSearch{}
+	}
+	return s.Viewers[viewer]
+}
+
+/*
+lookup は閲覧者が使用できる検索条件を名前で探す
+scopeが空文字の場合は閲覧者の検索条件、workspaceの検索条件の順に探す
+*/
+func (s *savedSearchStore) lookup(viewer, name, scope string) (SavedSearch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scopes := []string{savedSearchScopeViewer, savedSearchScopeWorkspace}
+	if scope != "" {
+		scopes = []string{scope}
+	}
+	for _, scope := range scopes {
+		if saved, ok := s.entries(viewer, scope, false)[name]; ok {
+			return saved, true
+		}
+	}
+	return SavedSearch{}, false
+}
+
+/* list は閲覧者が使用できる検索条件を範囲（viewer, workspace）・名前の順で返す */
+func (s *savedSearchStore) list(viewer string) []SavedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []SavedSearch{}
+	for _, scope := range []string{savedSearchScopeViewer, savedSearchScopeWorkspace} {
+		start := len(list)
+		for _, saved := range s.entries(viewer, scope, false) {
+			list = append(list, saved)
+		}
+		sort.Slice(list[start:], func(i, j int) bool { return list[start+i].Name < list[start+j].Name })
+	}
+	return list
+}
+
+/*
+validateSavedSearchParams は保存するクエリパラメータを /api/git-history と同じ規則で検証する
+
+戻り値:
+  []FieldError - 保存できないパラメータ・値のエラー（フィールド名は "params.<名前>"）
+*/
+func validateSavedSearchParams(params map[string]string) []FieldError {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	/* 同じリクエストに同じ順序でエラーを返すため、名前順に検証する */
+	sort.Strings(names)
+
+	var fields []FieldError
+	var hp historyParams
+	for _, name := range names {
+		if !containsString(savedSearchParams, name) {
+			fields = append(fields, FieldError{Field: "params." + name, Rule: "oneof", Message: fmt.Sprintf("must be one of: %s", strings.Join(savedSearchParams, ", "))})
+			continue
+		}
+		if fe := setQueryField(queryField(&hp, name), name, params[name]); fe != nil {
+			fields = append(fields, *fe)
+		}
+	}
+	fields = append(fields, validateStruct(&hp, fields)...)
+	for i := range fields {
+		if !strings.HasPrefix(fields[i].Field, "params.") {
+			fields[i].Field = "params." + fields[i].Field
+		}
+	}
+	return fields
+}
+
+/* queryField はform タグの名前が一致するフィールドを返す（objは構造体のポインタ） */
+func queryField(obj interface{}, name string) reflect.Value {
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("form"), ","); tag == name {
+			return v.Field(i)
+		}
+	}
+	panic(fmt.Sprintf("queryField: no field for %q in %s", name, t))
+}
+
+/*
+applySavedSearch は保存した検索条件のクエリパラメータをobjに設定する
+リクエストで直接指定したパラメータはそのまま残し、保存した値より優先する
+（例: ?saved=weekly-review&repo=giter は保存した条件のリポジトリのみ置き換える）
+*/
+func applySavedSearch(c *gin.Context, obj interface{}, saved SavedSearch) {
+	for name, raw := range saved.Params {
+		if _, ok := c.GetQuery(name); ok || !containsString(savedSearchParams, name) {
+			continue
+		}
+		/* 保存時に検証済みのため、変換のエラーは発生しない */
+		setQueryField(queryField(obj, name), name, raw)
+	}
+}
+
+/* savedSearchScopeQuery は /api/saved-searches/:name のクエリパラメータ */
+type savedSearchScopeQuery struct {
+	Scope string `form:"scope" binding:"omitempty,oneof=viewer workspace"` // 範囲（省略時は閲覧者、workspaceの順に探す）
+}
+
+/* savedSearchRequest は検索条件の作成・更新のリクエストボディ */
+type savedSearchRequest struct {
+	Name   string            `json:"name"`                                             // 名前（作成時のみ）
+	Scope  string            `json:"scope" binding:"omitempty,oneof=viewer workspace"` // 範囲（デフォルト: viewer）
+	Params map[string]string `json:"params" binding:"required"`                        // クエリパラメータ
+}
+
+/* validateFields は名前の形式と保存するクエリパラメータを検証する */
+func (req *savedSearchRequest) validateFields() []FieldError {
+	var fields []FieldError
+	if req.Name != "" && !savedSearchNamePattern.MatchString(req.Name) {
+		fields = append(fields, FieldError{Field: "name", Rule: "pattern", Message: "must be 1-64 lowercase letters, digits or hyphens"})
+	}
+	return append(fields, validateSavedSearchParams(req.Params)...)
+}
+
+/*
+authorizeSavedSearchScope はworkspaceの検索条件の変更を管理者のみに制限する
+
+戻り値:
+  bool - 変更できる場合はtrue（できない場合は403を返し済み）
+*/
+func authorizeSavedSearchScope(c *gin.Context, scope string) bool {
+	if scope == savedSearchScopeWorkspace && !isAdminRequest(c) {
+		respondError(c, http.StatusForbidden, "workspace saved searches can only be changed by an admin")
+		return false
+	}
+	return true
+}
+
+/*
+getSavedSearches は閲覧者が使用できる検索条件の一覧を返すAPIハンドラー
+
+レスポンス:
+  200 OK, SavedSearchList
+*/
+func getSavedSearches(c *gin.Context) {
+	respondJSON(c, http.StatusOK, SavedSearchList{SavedSearches: savedSearches.list(viewerID(c))})
+}
+
+/*
+getSavedSearch は検索条件を名前で返すAPIハンドラー
+
+クエリパラメータ:
+  scope string - 範囲（viewer, workspace、省略時は閲覧者の検索条件を優先する）
+
+レスポンス:
+  成功時: 200 OK, SavedSearch
+  失敗時: 404 Not Found（検索条件がない）
+*/
+func getSavedSearch(c *gin.Context) {
+	var query savedSearchScopeQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	saved, ok := savedSearches.lookup(viewerID(c), c.Param("name"), query.Scope)
+	if !ok {
+		respondError(c, http.StatusNotFound, errSavedSearchNotFound.Error())
+		return
+	}
+	respondJSON(c, http.StatusOK, saved)
+}
+
+/*
+postSavedSearch は検索条件を作成するAPIハンドラー
+
+リクエストボディ:
+  {"name": "weekly-review", "scope": "viewer", "params": {"repo": "giter", "per_page": "50"}}
+  params に指定できるのは repo, label, include_archive, per_page のみ（値は /api/git-history のクエリパラメータと同じ形式の文字列）
+
+レスポンス:
+  成功時: 201 Created, SavedSearch
+  失敗時: 400 Bad Request（JSON不正）, 403 Forbidden（管理者以外のworkspaceの作成）,
+          409 Conflict（同じ範囲に同じ名前がある）, 422 Unprocessable Entity（名前・パラメータの不正）
+*/
+func postSavedSearch(c *gin.Context) {
+	var req savedSearchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Name == "" {
+		respondValidationError(c, []FieldError{{Field: "name", Rule: "required", Message: "is required"}})
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = savedSearchScopeViewer
+	}
+	if !authorizeSavedSearchScope(c, req.Scope) {
+		return
+	}
+
+	savedSearches.mu.Lock()
+	defer savedSearches.mu.Unlock()
+
+	entries := savedSearches.entries(viewerID(c), req.Scope, true)
+	if _, ok := entries[req.Name]; ok {
+		respondError(c, http.StatusConflict, "a saved search with the same name already exists")
+		return
+	}
+	now := time.Now().UTC()
+	saved := SavedSearch{Name: req.Name, Scope: req.Scope, Params: req.Params, CreatedAt: now, UpdatedAt: now}
+	entries[req.Name] = saved
+	savedSearches.save()
+	respondJSON(c, http.StatusCreated, saved)
+}
+
+/*
+putSavedSearch は検索条件のクエリパラメータを置き換えるAPIハンドラー
+
+リクエストボディ:
+  {"scope": "viewer", "params": {"include_archive": "true"}}（nameは無視する）
+
+レスポンス:
+  成功時: 200 OK, SavedSearch
+  失敗時: 403 Forbidden（管理者以外のworkspaceの更新）, 404 Not Found（検索条件がない）, 422 Unprocessable Entity
+*/
+func putSavedSearch(c *gin.Context) {
+	var req savedSearchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = savedSearchScopeViewer
+	}
+	if !authorizeSavedSearchScope(c, req.Scope) {
+		return
+	}
+
+	savedSearches.mu.Lock()
+	defer savedSearches.mu.Unlock()
+
+	entries := savedSearches.entries(viewerID(c), req.Scope, false)
+	saved, ok := entries[c.Param("name")]
+	if !ok {
+		respondError(c, http.StatusNotFound, errSavedSearchNotFound.Error())
+		return
+	}
+	saved.Params, saved.UpdatedAt = req.Params, time.Now().UTC()
+	entries[saved.Name] = saved
+	savedSearches.save()
+	respondJSON(c, http.StatusOK, saved)
+}
+
+/*
+deleteSavedSearch は検索条件を削除するAPIハンドラー
+
+クエリパラメータ:
+  scope string - 範囲（viewer, workspace、デフォルト: viewer）
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 403 Forbidden（管理者以外のworkspaceの削除）, 404 Not Found（検索条件がない）
+*/
+func deleteSavedSearch(c *gin.Context) {
+	var query savedSearchScopeQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	if query.Scope == "" {
+		query.Scope = savedSearchScopeViewer
+	}
+	if !authorizeSavedSearchScope(c, query.Scope) {
+		return
+	}
+
+	savedSearches.mu.Lock()
+	defer savedSearches.mu.Unlock()
+
+	viewer := viewerID(c)
+	entries := savedSearches.entries(viewer, query.Scope, false)
+	if _, ok := entries[c.Param("name")]; !ok {
+		respondError(c, http.StatusNotFound, errSavedSearchNotFound.Error())
+		return
+	}
+	delete(entries, c.Param("name"))
+	if query.Scope == savedSearchScopeViewer && len(entries) == 0 {
+		delete(savedSearches.Viewers, viewer)
+	}
+	savedSearches.save()
+	c.Status(http.StatusNoContent)
+}
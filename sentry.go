@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/* panicRecoveredContextKey はrecoveryMiddlewareがpanicを処理したことを示すgin.Contextのキー */
+const panicRecoveredContextKey = "panic_recovered"
+
+/*
+SentryConfig はSentryへの送信設定
+環境変数から読み込み、SENTRY_DSNが未設定の場合はSentryを使用しない
+*/
+type SentryConfig struct {
+	DSN               string        // 送信先のDSN（SENTRY_DSN）
+	Environment       string        // 環境名（SENTRY_ENVIRONMENT、デフォルト: ERROR_REPORT_ENVIRONMENTの値）
+	Release           string        // リリース名（SENTRY_RELEASE）
+	SampleRate        float64       // エラーイベントを送信する割合 0.0〜1.0（SENTRY_SAMPLE_RATE、デフォルト: 1.0）
+	TracesSampleRate  float64       // トランザクション（リクエストのトレース）を記録する割合 0.0〜1.0（SENTRY_TRACES_SAMPLE_RATE、デフォルト: 0）
+	SlowTransaction   time.Duration // この時間以上かかったトランザクションのみ送信する（SENTRY_SLOW_TRANSACTION_THRESHOLD、0の場合はすべて）
+	CaptureUpstream   bool          // GitHub APIの呼び出し失敗を送信する（SENTRY_CAPTURE_UPSTREAM、デフォルト: true）
+}
+
+/* sentryEnabled はSentryの初期化に成功し、送信が有効になっているか */
+var sentryEnabled bool
+
+/* sentryConfig は起動時に読み込んだSentryの設定 */
+var sentryConfig SentryConfig
+
+/*
+loadSentryConfig は環境変数からSentryの設定を読み込む
+
+戻り値:
+  SentryConfig - 読み込んだ設定
+  error - サンプリング割合が数値でない、または0.0〜1.0の範囲外の場合のエラー
+*/
+func loadSentryConfig() (SentryConfig, error) {
+	cfg := SentryConfig{
+		DSN:             getEnv("SENTRY_DSN", ""),
+		Environment:     getEnv("SENTRY_ENVIRONMENT", getEnv("ERROR_REPORT_ENVIRONMENT", "production")),
+		Release:         getEnv("SENTRY_RELEASE", ""),
+		SlowTransaction: parseDurationEnv("SENTRY_SLOW_TRANSACTION_THRESHOLD", 0),
+		CaptureUpstream: getEnv("SENTRY_CAPTURE_UPSTREAM", "true") != "false",
+	}
+
+	var err error
+	if cfg.SampleRate, err = parseRateEnv("SENTRY_SAMPLE_RATE", 1.0); err != nil {
+		return cfg, err
+	}
+	if cfg.TracesSampleRate, err = parseRateEnv("SENTRY_TRACES_SAMPLE_RATE", 0); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+/* parseRateEnv は0.0〜1.0の割合を環境変数から読み込む（未設定の場合はdef） */
+func parseRateEnv(key string, def float64) (float64, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return def, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("%s must be a number between 0 and 1: %q", key, value)
+	}
+	return rate, nil
+}
+
+/*
+initSentry はSentryのSDKを初期化する
+SENTRY_DSNが未設定の場合は何もしない（ミドルウェアやフックもすべて何もしなくなる）
+
+注意:
+  - 初期化に成功した場合、panicの報告先（errorReporters）にSentryを追加する
+  - SlowTransactionを設定した場合、サンプリングされたトランザクションのうち
+    閾値未満で終わったものは送信しない
+*/
+func initSentry() error {
+	cfg, err := loadSentryConfig()
+	if err != nil {
+		return err
+	}
+	sentryConfig = cfg
+	if cfg.DSN == "" {
+		return nil
+	}
+
+	err = sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          cfg.Release,
+		SampleRate:       cfg.SampleRate,
+		EnableTracing:    cfg.TracesSampleRate > 0,
+		TracesSampleRate: cfg.TracesSampleRate,
+		ServerName:       hostname(),
+		BeforeSendTransaction: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			if cfg.SlowTransaction > 0 && event.Timestamp.Sub(event.StartTime) < cfg.SlowTransaction {
+				return nil
+			}
+			return event
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize sentry: %w", err)
+	}
+
+	sentryEnabled = true
+	errorReporters = append(errorReporters, sentryReporter{})
+	log.Info().
+		Str("environment", cfg.Environment).
+		Float64("sample_rate", cfg.SampleRate).
+		Float64("traces_sample_rate", cfg.TracesSampleRate).
+		Dur("slow_transaction_threshold", cfg.SlowTransaction).
+		Msg("Sentry enabled")
+	return nil
+}
+
+/* flushSentry は送信待ちのイベントを送信する（プロセス終了前に呼び出す） */
+func flushSentry() {
+	if sentryEnabled {
+		sentry.Flush(2 * time.Second)
+	}
+}
+
+/*
+sentryMiddleware はリクエストごとにSentryのトランザクションを記録し、
+5xxのエラーレスポンスをイベントとして送信するミドルウェア
+  - トランザクション名はルート（例: "GET /api/notifications/:id/read"）
+  - 上流のsentry-traceヘッダーがあればトレースを引き継ぐ
+  - respondError / abortWithError で返した5xxのエラーメッセージを送信する
+  - panicはrecoveryMiddlewareからErrorReporterとして送信されるため、ここでは送信しない
+*/
+func sentryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !sentryEnabled {
+			c.Next()
+			return
+		}
+
+		hub := sentry.CurrentHub().Clone()
+		hub.Scope().SetRequest(c.Request)
+		hub.Scope().SetTag("request_id", requestID(c))
+		ctx := sentry.SetHubOnContext(c.Request.Context(), hub)
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		tx := sentry.StartTransaction(ctx,
+			c.Request.Method+" "+route,
+			sentry.ContinueFromRequest(c.Request),
+			sentry.WithOpName("http.server"),
+			sentry.WithTransactionSource(sentry.SourceRoute),
+		)
+		c.Request = c.Request.WithContext(tx.Context())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		tx.Status = sentry.HTTPtoSpanStatus(status)
+		tx.SetData("http.response.status_code", status)
+		tx.Finish()
+
+		if status < http.StatusInternalServerError || c.GetBool(panicRecoveredContextKey) {
+			return
+		}
+		for _, ginErr := range c.Errors {
+			hub.WithScope(func(scope *sentry.Scope) {
+				scope.SetTag("route", route)
+				scope.SetTag("status_code", strconv.Itoa(status))
+				scope.SetUser(sentry.User{ID: viewerID(c)})
+				hub.CaptureException(ginErr.Err)
+			})
+		}
+	}
+}
+
+/*
+captureUpstreamFailure はGitHub APIの呼び出し失敗をSentryに送信する
+Sentryが無効、またはSENTRY_CAPTURE_UPSTREAM=falseの場合は何もしない
+
+引数:
+  op string - 操作の種類（upstreamOp* 定数）
+  url string - リクエストURL
+  err error - 失敗の内容
+*/
+func captureUpstreamFailure(op, url string, err error) {
+	if !sentryEnabled || !sentryConfig.CaptureUpstream {
+		return
+	}
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("upstream", "github")
+		scope.SetTag("operation", op)
+		scope.SetExtra("url", url)
+		/* 同じ操作の失敗が1つの課題にまとまるよう、URLではなく操作の種類でグルーピングする */
+		scope.SetFingerprint([]string{"github-upstream", op})
+		sentry.CaptureException(err)
+	})
+}
+
+/*
+sentryReporter はpanicをSentryに送信するErrorReporter
+*/
+type sentryReporter struct{}
+
+func (sentryReporter) Name() string { return "sentry" }
+
+func (sentryReporter) Report(report ErrorReport) error {
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelFatal
+	event.Message = report.Message
+	event.Timestamp = report.Timestamp
+	event.Transaction = report.Method + " " + report.Route
+	event.Tags = map[string]string{
+		"request_id": report.RequestID,
+		"route":      report.Route,
+		"panic":      "true",
+	}
+	event.User = sentry.User{ID: report.ViewerID, IPAddress: report.ClientIP}
+	event.Request = &sentry.Request{URL: report.Path, Method: report.Method}
+	event.Extra = map[string]interface{}{"stack": report.Stack}
+
+	/* サンプリングで送信されなかった場合もnilを返すため、戻り値のIDは確認しない */
+	sentry.CaptureEvent(event)
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	/*
+		searchIndexable は検索エンジンにダッシュボードのインデックスを許可するかどうか（環境変数 SEARCH_INDEXABLE）
+		falseの場合、robots.txtですべてのクロールを拒否し、sitemap.xmlは404を返す
+	*/
+	searchIndexable = getEnvBool("SEARCH_INDEXABLE", true)
+	/*
+		robotsDisallow はrobots.txtでクロールを拒否する追加のパス（環境変数 ROBOTS_DISALLOW、カンマ区切り、BASE_PATHからの相対パス）
+		APIとプロキシ（/api/, /proxy/）は常に拒否する
+	*/
+	robotsDisallow = splitList(getEnv("ROBOTS_DISALLOW", ""))
+	/*
+		publicBaseURL はsitemap.xmlなどに記載する公開URLのオリジン（環境変数 PUBLIC_BASE_URL、例: https://giter.example.com）
+		BASE_PATHは含めない（URLの組み立て時に付ける）。未設定の場合はリクエストのHostヘッダーから組み立てる
+	*/
+	publicBaseURL = strings.TrimSuffix(getEnv("PUBLIC_BASE_URL", ""), "/")
+)
+
+/* sitemapXMLNS はsitemap.xmlの名前空間 */
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+/* sitemapURLSet はsitemap.xmlのルート要素 */
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+/* sitemapURL はsitemap.xmlの1件のURL */
+type sitemapURL struct {
+	Loc        string `xml:"loc"`                  // ページの絶対URL
+	LastMod    string `xml:"lastmod,omitempty"`    // 最終更新日（YYYY-MM-DD）
+	ChangeFreq string `xml:"changefreq,omitempty"` // 更新頻度の目安
+}
+
+/*
+siteBaseURL は公開URLのベース（末尾のスラッシュなし）を返す
+PUBLIC_BASE_URLが設定されていればその値、未設定の場合はリクエストのスキームとHost
+*/
+func siteBaseURL(c *gin.Context) string {
+	if publicBaseURL != "" {
+		return publicBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+/* splitList はカンマ区切りの文字列を空白を除いた要素のスライスに分割する（空の要素は除く） */
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+/*
+getRobotsTxt はrobots.txtを返すハンドラー
+SEARCH_INDEXABLE=falseの場合はすべてのパスを拒否する
+それ以外はAPI・プロキシとROBOTS_DISALLOWのパスを拒否し、sitemap.xmlの場所を記載する
+
+レスポンス:
+  200 OK, text/plain
+*/
+func getRobotsTxt(c *gin.Context) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if !searchIndexable {
+		b.WriteString("Disallow: /\n")
+	} else {
+		for _, path := range append([]string{"/api/", proxyPathPrefix + "/"}, robotsDisallow...) {
+			fmt.Fprintf(&b, "Disallow: %s\n", appPath(path))
+		}
+		fmt.Fprintf(&b, "\nSitemap: %s%s\n", siteBaseURL(c), appPath("/sitemap.xml"))
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(b.String()))
+}
+
+/*
+getSitemap はsitemap.xmlを返すハンドラー
+トップページと、対象ユーザーの各リポジトリの詳細ページ（/repos/:owner/:repo）を列挙する
+リポジトリ一覧はfetchRepositories（キャッシュ経由）で取得し、失敗した場合はトップページのみを返す
+
+レスポンス:
+  成功時: 200 OK, application/xml
+  SEARCH_INDEXABLE=false: 404 Not Found
+*/
+func getSitemap(c *gin.Context) {
+	if !searchIndexable {
+		respondErrorPage(c, http.StatusNotFound, "page not found")
+		return
+	}
+
+	base := siteBaseURL(c) + basePath
+	home := sitemapURL{Loc: base + "/", ChangeFreq: "hourly"}
+	if stats := currentSyncSummary(); stats.HasData() {
+		home.LastMod = stats.SyncedAt.Format(time.DateOnly)
+	}
+	set := sitemapURLSet{XMLNS: sitemapXMLNS, URLs: []sitemapURL{home}}
+
+	repos, err := fetchVisibleRepositories(backgroundVisibility())
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch repositories for sitemap")
+	}
+	for _, repo := range repos {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:        fmt.Sprintf("%s/repos/%s/%s", base, url.PathEscape(username), url.PathEscape(repo.Name)),
+			ChangeFreq: "daily",
+		})
+	}
+
+	body, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
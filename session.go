@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* sessionCookieName は閲覧者を識別するセッションIDを保存するクッキー名 */
+	sessionCookieName = "giter_session"
+	/* sessionCookieMaxAge はセッションクッキーの有効期間（秒）。1年間 */
+	sessionCookieMaxAge = 365 * 24 * 60 * 60
+	/* sessionContextKey はgin.Contextに閲覧者IDを保存する際のキー */
+	sessionContextKey = "viewer_id"
+)
+
+/*
+sessionCookieSameSite はセッションクッキーのSameSite属性
+環境変数 SESSION_COOKIE_SAMESITE（lax / strict / none）で変更可能（デフォルト: lax）
+none はフロントエンドを別のサイトから埋め込む場合のみ使用する（Secure属性を付けるため、HTTPSが必要）
+*/
+var sessionCookieSameSite = parseSameSite(getEnv("SESSION_COOKIE_SAMESITE", "lax"))
+
+/* parseSameSite はSameSite属性の名前を読み込む（不明な値の場合は警告を出してlaxにする） */
+func parseSameSite(name string) http.SameSite {
+	switch strings.ToLower(name) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	log.Warn().Str("value", name).Msg("Unknown SESSION_COOKIE_SAMESITE, using lax")
+	return http.SameSiteLaxMode
+}
+
+/*
+setSessionCookie はセッションIDをクッキーに保存する
+SameSite=None の場合は、ブラウザの要件に従いSecure属性を付ける
+*/
+func setSessionCookie(c *gin.Context, id string) {
+	c.SetSameSite(sessionCookieSameSite)
+	c.SetCookie(sessionCookieName, id, sessionCookieMaxAge, appPath("/"), "", sessionCookieSameSite == http.SameSiteNoneMode, true)
+}
+
+/*
+sessionMiddleware は閲覧者ごとのセッションIDを発行・識別するミドルウェア
+ブラウザごとにランダムなIDをクッキーで払い出し、
+通知の受信箱など閲覧者単位のデータのキーとして使用する
+GitHubでログインした場合は、このIDにログインが紐付けられる（authsession.go）
+
+注意:
+  - HttpOnlyを指定し、JavaScriptからクッキーを読み取れないようにする
+  - SameSite=Lax（SESSION_COOKIE_SAMESITE）で他サイトからのPOSTにクッキーが送信されるのを防ぐ
+  - 画面からの状態を変更するリクエストは、さらにcsrfMiddlewareでトークンを確認する
+*/
+func sessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := c.Cookie(sessionCookieName)
+		if err != nil || !isValidSessionID(id) {
+			id = newSessionID()
+			setSessionCookie(c, id)
+		}
+		c.Set(sessionContextKey, id)
+		c.Next()
+	}
+}
+
+/*
+viewerID はリクエストの閲覧者ID（セッションID）を返す
+sessionMiddlewareを通過していない場合は空文字を返す
+*/
+func viewerID(c *gin.Context) string {
+	return c.GetString(sessionContextKey)
+}
+
+/*
+newSessionID は128ビットのランダムなセッションIDを16進数文字列で生成する
+crypto/randを使用し、推測困難なIDにする
+*/
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		/* 乱数生成に失敗することは通常ないため、発生した場合は起動環境の異常とみなす */
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+/* isValidSessionID はクッキーの値がnewSessionIDで生成した形式（32文字の16進数）かどうかを返す */
+func isValidSessionID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	/*
+		shareSecret は共有URLの署名（HMAC-SHA256）に使用する秘密鍵
+		環境変数 SHARE_SECRET で設定する。未設定の場合、共有URLは作成・表示ともに無効になる
+	*/
+	shareSecret = getEnv("SHARE_SECRET", "")
+	/*
+		shareDefaultTTL は有効期間を指定しない場合の共有URLの有効期間
+		環境変数 SHARE_DEFAULT_TTL で変更可能（デフォルト: 24時間）
+	*/
+	shareDefaultTTL = parseDurationEnv("SHARE_DEFAULT_TTL", 24*time.Hour)
+	/*
+		shareMaxTTL は共有URLに指定できる有効期間の上限
+		環境変数 SHARE_MAX_TTL で変更可能（デフォルト: 7日間）
+	*/
+	shareMaxTTL = parseDurationEnv("SHARE_MAX_TTL", 7*24*time.Hour)
+)
+
+var (
+	errShareInvalid = errors.New("share link is invalid")
+	errShareExpired = errors.New("share link has expired")
+)
+
+/*
+ShareView は共有URLで閲覧できる範囲
+トークンに埋め込んで署名するため、サーバーには保存しない
+*/
+type ShareView struct {
+	Repo      string     `json:"repo,omitempty"` // 対象のリポジトリ名（空文字の場合はすべてのリポジトリ）
+	From      *time.Time `json:"from,omitempty"` // 期間の開始日時（この日時を含む）
+	To        *time.Time `json:"to,omitempty"`   // 期間の終了日時（この日時を含まない）
+	ExpiresAt time.Time  `json:"exp"`            // 共有URLの有効期限
+	IssuedAt  time.Time  `json:"iat"`            // 共有URLの作成日時（DELETE /api/admin/data による取り消しの判定に使用）
+}
+
+/* includes はコミットが共有の範囲に含まれるかどうかを返す */
+func (v ShareView) includes(commit CommitHistory) bool {
+	if v.Repo != "" && commit.RepositoryName != v.Repo {
+		return false
+	}
+	if v.From != nil && commit.CommitTime.Before(*v.From) {
+		return false
+	}
+	return v.To == nil || commit.CommitTime.Before(*v.To)
+}
+
+/* shareSignature はペイロード（base64url）のHMAC-SHA256の署名を返す */
+func shareSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(shareSecret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+/*
+signShareToken は共有の範囲に署名したトークンを作成する
+形式は "<ShareViewのJSONのbase64url>.<署名のbase64url>"
+*/
+func signShareToken(view ShareView) (string, error) {
+	body, err := json.Marshal(view)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(shareSignature(payload)), nil
+}
+
+/*
+verifyShareToken はトークンの署名と有効期限を検証し、共有の範囲を返す
+
+戻り値:
+  error - 形式・署名が不正な場合と取り消された場合はerrShareInvalid、有効期限切れの場合はerrShareExpired
+
+注意:
+  - 署名の比較には hmac.Equal を使用し、タイミング攻撃を防ぐ
+*/
+func verifyShareToken(token string) (ShareView, error) {
+	var view ShareView
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || shareSecret == "" {
+		return view, errShareInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, shareSignature(payload)) {
+		return view, errShareInvalid
+	}
+	body, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(body, &view) != nil {
+		return view, errShareInvalid
+	}
+	if view.IssuedAt.Before(dataDeletions.shareTokensRevokedAt()) {
+		return view, errShareInvalid
+	}
+	if !time.Now().Before(view.ExpiresAt) {
+		return view, errShareExpired
+	}
+	return view, nil
+}
+
+/*
+shareRequest は POST /api/share のリクエストボディ
+*/
+type shareRequest struct {
+	Repo string     `json:"repo"` // 対象のリポジトリ名（省略時はすべてのリポジトリ）
+	From *time.Time `json:"from"` // 期間の開始日時（省略時は制限しない）
+	To   *time.Time `json:"to"`   // 期間の終了日時（省略時は制限しない）
+	TTL  string     `json:"ttl"`  // 有効期間（例: "1h"、省略時はSHARE_DEFAULT_TTL）
+}
+
+/* validateFields は期間と有効期間を検証する */
+func (req *shareRequest) validateFields() []FieldError {
+	var fields []FieldError
+	if req.From != nil && req.To != nil && !req.To.After(*req.From) {
+		fields = append(fields, FieldError{Field: "to", Rule: "gtfield", Message: "must be after from"})
+	}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		switch {
+		case err != nil:
+			fields = append(fields, FieldError{Field: "ttl", Rule: "duration", Message: "must be a duration (e.g. 1h)"})
+		case ttl <= 0 || ttl > shareMaxTTL:
+			fields = append(fields, FieldError{Field: "ttl", Rule: "max", Message: "must be positive and at most " + shareMaxTTL.String()})
+		}
+	}
+	return fields
+}
+
+/* ShareLink は作成した共有URL */
+type ShareLink struct {
+	URL       string    `json:"url"`        // 共有ページのURL
+	Token     string    `json:"token"`      // 署名したトークン
+	ExpiresAt time.Time `json:"expires_at"` // 有効期限
+	View      ShareView `json:"view"`       // 共有の範囲
+}
+
+/*
+postShare は期間限定の共有URLを作成する管理者APIハンドラー
+共有URLでは認証なしで、指定したリポジトリ・期間のコミット履歴（プライベートリポジトリを含む）を閲覧できる
+
+リクエストボディ:
+  {"repo": "my-project", "from": "2025-01-01T00:00:00Z", "to": "2025-02-01T00:00:00Z", "ttl": "72h"}（いずれも省略可）
+
+レスポンス:
+  成功時: 201 Created, ShareLink
+  失敗時: 403 Forbidden（SHARE_SECRET未設定）, 400 Bad Request（不正なJSON）,
+          422 Unprocessable Entity（不正な期間・有効期間、存在しないリポジトリ）, 502 Bad Gateway（GitHubから取得できない）
+
+注意:
+  - 共有URLは個別には取り消せない。取り消す場合はSHARE_SECRETを変更するか、DELETE /api/admin/data でデータを削除する
+    （いずれもそれまでに作成したすべての共有URLが無効になる）
+*/
+func postShare(c *gin.Context) {
+	if shareSecret == "" {
+		respondError(c, http.StatusForbidden, "sharing is disabled (set SHARE_SECRET to enable)")
+		return
+	}
+	var req shareRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if req.Repo != "" {
+		repos, err := fetchRepositories()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch repositories for share link")
+			respondError(c, http.StatusBadGateway, err.Error())
+			return
+		}
+		if !containsString(repositoryNames(repos), req.Repo) {
+			respondValidationError(c, []FieldError{{Field: "repo", Rule: "exists", Message: "unknown repository"}})
+			return
+		}
+	}
+
+	ttl := shareDefaultTTL
+	if req.TTL != "" {
+		/* validateFieldsで読み込めることを確認済み */
+		ttl, _ = time.ParseDuration(req.TTL)
+	}
+	now := time.Now().UTC()
+	view := ShareView{Repo: req.Repo, From: req.From, To: req.To, ExpiresAt: now.Add(ttl).Truncate(time.Second), IssuedAt: now}
+	token, err := signShareToken(view)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Info().Str("repo", view.Repo).Time("expires_at", view.ExpiresAt).Msg("Share link created")
+	respondJSON(c, http.StatusCreated, ShareLink{
+		URL:       siteBaseURL(c) + appPath("/share/"+token),
+		Token:     token,
+		ExpiresAt: view.ExpiresAt,
+		View:      view,
+	})
+}
+
+/*
+showSharePage は共有URL（/share/:token）のページを表示するハンドラー
+トークンの範囲のコミット履歴を、管理者と同じくプライベートリポジトリを含めて表示する
+
+レスポンス:
+  成功時: 200 OK, share.html
+  失敗時: 404 Not Found（不正なトークン・SHARE_SECRET未設定）, 410 Gone（有効期限切れ）, 502 Bad Gateway
+
+注意:
+  - URLにトークンが含まれるため、リンク先にRefererで送らないよう Referrer-Policy: no-referrer を付ける
+  - 検索エンジンに登録されないよう X-Robots-Tag: noindex を付ける
+  - コミットメッセージにはREDACTION_RULESを適用する（共有URLの閲覧者は匿名のため）
+*/
+func showSharePage(c *gin.Context) {
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Header("Cache-Control", "private, no-store")
+
+	req := webRequest(c)
+	principal := requestPrincipal(c)
+	if principal.Share == nil {
+		/* トークンはauthMiddlewareのshareAuthenticatorで検証済み */
+		err := requestAuthError(c)
+		if err == nil {
+			err = errShareInvalid
+		}
+		status := http.StatusNotFound
+		if errors.Is(err, errShareExpired) {
+			status = http.StatusGone
+		}
+		c.HTML(status, "share.html", web.NewShareErrorPage(siteInfo(), req, localize(c, err.Error(), nil)))
+		return
+	}
+	view := *principal.Share
+
+	commits, _, err := fetchVisibleCommitHistory(visibilityAll)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to render share page")
+		c.HTML(http.StatusBadGateway, "share.html", web.NewShareErrorPage(siteInfo(), req, localize(c, "failed to fetch commit history from GitHub", nil)))
+		return
+	}
+
+	redact := requestRedaction(c)
+	items := []web.CommitItem{}
+	for _, commit := range commits {
+		if !view.includes(commit) {
+			continue
+		}
+		subject := strings.SplitN(redact.message(commit.CommitMessage), "\n", 2)[0]
+		items = append(items, web.CommitItem{SHA: commit.CommitSHA, Message: commit.RepositoryName + ": " + subject, Time: commit.CommitTime, URL: commit.CommitURL})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Time.After(items[j].Time) })
+
+	summary := web.ShareSummary{Repo: view.Repo, ExpiresAt: view.ExpiresAt.In(statsLocation)}
+	if view.From != nil {
+		summary.From = view.From.In(statsLocation)
+	}
+	if view.To != nil {
+		summary.To = view.To.In(statsLocation)
+	}
+	c.HTML(http.StatusOK, "share.html", web.NewSharePage(siteInfo(), req, summary, items))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyShareToken(t *testing.T) {
+	dir, secret := dataDir, shareSecret
+	dataDir, shareSecret = t.TempDir(), "test-share-secret"
+	revokedAt := dataDeletions.shareTokensRevokedAt()
+	t.Cleanup(func() {
+		dataDir, shareSecret = dir, secret
+		dataDeletions.mu.Lock()
+		dataDeletions.ShareTokensRevokedAt = revokedAt
+		dataDeletions.mu.Unlock()
+	})
+
+	now := time.Now().UTC()
+	sign := func(view ShareView) string {
+		token, err := signShareToken(view)
+		if err != nil {
+			t.Fatalf("signShareToken: %v", err)
+		}
+		return token
+	}
+	valid := sign(ShareView{Repo: "giter", ExpiresAt: now.Add(time.Hour), IssuedAt: now})
+	payload, signature, _ := strings.Cut(valid, ".")
+	/* 署名はそのままで、対象のリポジトリだけを書き換えたペイロード */
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"repo":"private-repo","exp":"` + now.Add(time.Hour).Format(time.RFC3339) + `","iat":"` + now.Format(time.RFC3339) + `"}`))
+
+	/* DELETE /api/admin/data による取り消しより前に作成したトークン */
+	beforeDeletion := sign(ShareView{ExpiresAt: now.Add(time.Hour), IssuedAt: now.Add(-time.Minute)})
+	dataDeletions.revokeShareTokens(now.Add(-time.Second))
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"tampered signature", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("not-the-signature")), errShareInvalid},
+		{"tampered payload", forged + "." + signature, errShareInvalid},
+		{"signature is not base64url", payload + ".%%%", errShareInvalid},
+		{"missing signature", payload, errShareInvalid},
+		{"expired", sign(ShareView{ExpiresAt: now.Add(-time.Minute), IssuedAt: now}), errShareExpired},
+		{"issued before data deletion", beforeDeletion, errShareInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view, err := verifyShareToken(tt.token)
+			if err != tt.want {
+				t.Fatalf("verifyShareToken error = %v, want %v", err, tt.want)
+			}
+			if err == nil && view.Repo != "giter" {
+				t.Errorf("view = %+v, want repo giter", view)
+			}
+		})
+	}
+
+	/* SHARE_SECRETを設定していない場合は、署名が正しくても無効 */
+	shareSecret = ""
+	if _, err := verifyShareToken(valid); err != errShareInvalid {
+		t.Errorf("verifyShareToken without SHARE_SECRET = %v, want %v", err, errShareInvalid)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+コミットのペースのSLO
+「リポジトリXには7日間に1件以上のコミットがある」のような期待値を登録し、同期のたびに評価する
+満たさなくなった時点で slo_violation 通知（受信箱・送信Webhook・イベントブローカー）を作成する
+*/
+
+const (
+	/* commitSLOsTable はSLOと評価の結果を保存するテーブル名 */
+	commitSLOsTable = "commit_slos"
+)
+
+/* SLOの状態 */
+const (
+	sloStatusPending  = "pending"  // まだ評価していない（登録・変更の直後）
+	sloStatusOK       = "ok"       // 期間内のコミット数が目標以上
+	sloStatusViolated = "violated" // 期間内のコミット数が目標未満
+)
+
+/*
+CommitSLO はリポジトリのコミットのペースの期待値と、直近の評価の結果
+*/
+type CommitSLO struct {
+	ID            string     `json:"id"`             // SLOのID
+	Team          string     `json:"team,omitempty"` // 担当のチーム名（通知の本文と絞り込みに使用）
+	Repo          string     `json:"repo"`           // リポジトリ名
+	MinCommits    int        `json:"min_commits"`    // 期間内に必要なコミット数
+	WindowDays    int        `json:"window_days"`    // 期間（直近の日数）
+	Private       bool       `json:"private"`        // プライベートリポジトリかどうか（閲覧者への表示と通知の判定に使用）
+	Status        string     `json:"status"`         // 状態（sloStatus* 定数）
+	Commits       int        `json:"commits"`        // 直近の評価での期間内のコミット数
+	EvaluatedAt   *time.Time `json:"evaluated_at"`   // 直近の評価の日時（未評価の場合はnull）
+	ViolatedSince *time.Time `json:"violated_since"` // 満たさなくなった日時（満たしている場合はnull）
+	CreatedAt     time.Time  `json:"created_at"`     // 作成日時
+	UpdatedAt     time.Time  `json:"updated_at"`     // 更新日時
+}
+
+/*
+commitSLOStore は登録したSLOを保持するストア
+commit_slosテーブルに永続化される
+*/
+type commitSLOStore struct {
+	mu   sync.Mutex
+	SLOs []CommitSLO `json:"slos"` // 登録順
+}
+
+/* commitSLOs はアプリケーション全体で共有するSLOのストア */
+var commitSLOs = &commitSLOStore{SLOs: []CommitSLO{}}
+
+func init() {
+	registerTable(commitSLOsTable, loadCommitSLOs)
+}
+
+/*
+loadCommitSLOs はcommit_slosテーブルからストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadCommitSLOs() error {
+	commitSLOs.mu.Lock()
+	defer commitSLOs.mu.Unlock()
+
+	commitSLOs.SLOs = nil
+	if err := loadTable(commitSLOsTable, commitSLOs); err != nil {
+		return err
+	}
+	if commitSLOs.SLOs == nil {
+		commitSLOs.SLOs = []CommitSLO{}
+	}
+	return nil
+}
+
+/* save はストアをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *commitSLOStore) save() {
+	if err := saveTable(commitSLOsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save commit SLOs")
+	}
+}
+
+/* find はIDのSLOの位置を返す（ない場合は-1、呼び出し元でmuをロックしていること） */
+func (s *commitSLOStore) find(id string) int {
+	for i, slo := range s.SLOs {
+		if slo.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+evaluateCommitSLOs は同期で取得したコミットからすべてのSLOを評価する
+getGitHistoryの集計後（compute-statsジョブ）に呼び出される
+
+引数:
+  commits []CommitHistory - 取得できた全コミット
+  repos []Repository - 同期の対象の全リポジトリ
+  failedRepos []string - コミットの取得に失敗したリポジトリ名
+  now time.Time - 評価の基準の日時
+
+注意:
+  - 取得に失敗したリポジトリと、同期の対象にないリポジトリのSLOは評価せず、前回の状態を残す
+  - 通知は満たさなくなった時点（ok・pendingからviolated）のみ作成し、満たさないあいだは繰り返さない
+  - GitHub APIから取得できるのは1リポジトリあたり最新100件までのため、min_commitsは100以下に制限している
+*/
+func evaluateCommitSLOs(commits []CommitHistory, repos []Repository, failedRepos []string, now time.Time) {
+	private := map[string]bool{}
+	for _, repo := range repos {
+		private[repo.Name] = repo.Private
+	}
+
+	commitSLOs.mu.Lock()
+	var violated []CommitSLO
+	for i := range commitSLOs.SLOs {
+		slo := &commitSLOs.SLOs[i]
+		isPrivate, synced := private[slo.Repo]
+		if !synced || containsString(failedRepos, slo.Repo) {
+			continue
+		}
+
+		since := now.AddDate(0, 0, -slo.WindowDays)
+		count := 0
+		for _, commit := range commits {
+			if commit.RepositoryName == slo.Repo && commit.CommitTime.After(since) && !commit.CommitTime.After(now) {
+				count++
+			}
+		}
+
+		previous := slo.Status
+		evaluatedAt := now.UTC()
+		slo.Private, slo.Commits, slo.EvaluatedAt = isPrivate, count, &evaluatedAt
+		if count >= slo.MinCommits {
+			slo.Status, slo.ViolatedSince = sloStatusOK, nil
+			if previous == sloStatusViolated {
+				log.Info().Str("slo", slo.ID).Str("repo", slo.Repo).Int("commits", count).Msg("Commit SLO recovered")
+			}
+			continue
+		}
+		slo.Status = sloStatusViolated
+		if previous != sloStatusViolated {
+			slo.ViolatedSince = &evaluatedAt
+			violated = append(violated, *slo)
+		}
+	}
+	commitSLOs.save()
+	commitSLOs.mu.Unlock()
+
+	for _, slo := range violated {
+		log.Warn().Str("slo", slo.ID).Str("repo", slo.Repo).Int("commits", slo.Commits).Int("min_commits", slo.MinCommits).Msg("Commit SLO violated")
+		/* 通知は匿名の閲覧者の受信箱にも届くため、PRIVACY_MODEに従いプライベートリポジトリは通知しない */
+		if !backgroundVisibility().includes(slo.Repo, slo.Private) {
+			continue
+		}
+		notify(notificationKindSLOViolation, slo.Repo, "コミットのペースが目標を下回っています", slo.violationMessage())
+	}
+}
+
+/* violationMessage はslo_violation通知の本文を返す */
+func (slo CommitSLO) violationMessage() string {
+	message := fmt.Sprintf("%s の直近%d日間のコミットは %d 件です（目標: %d 件以上）", slo.Repo, slo.WindowDays, slo.Commits, slo.MinCommits)
+	if slo.Team != "" {
+		message = fmt.Sprintf("[%s] %s", slo.Team, message)
+	}
+	return message
+}
+
+/* commitSLORequest はSLOの作成・更新のリクエストボディ */
+type commitSLORequest struct {
+	Team       string `json:"team" binding:"max=100"`
+	Repo       string `json:"repo" binding:"required"`
+	MinCommits int    `json:"min_commits" binding:"required,min=1,max=100"`
+	WindowDays int    `json:"window_days" binding:"required,min=1,max=90"`
+}
+
+/* commitSLOQuery は GET /api/slo のクエリパラメータ */
+type commitSLOQuery struct {
+	Team   string `form:"team"`                                                 // チーム名で絞り込む
+	Status string `form:"status" binding:"omitempty,oneof=pending ok violated"` // 状態で絞り込む
+}
+
+/*
+resolveSLORepository はリクエストのリポジトリを確認し、リポジトリ名とプライベートかどうかを返す
+失敗した場合はレスポンスを返し済み
+*/
+func resolveSLORepository(c *gin.Context, repo string) (string, bool, bool) {
+	req := trackRequest{Repo: repo}
+	name, ok := req.name()
+	if !ok {
+		respondValidationError(c, []FieldError{{Field: "repo", Rule: "repo", Message: "must be a repository name owned by " + username}})
+		return "", false, false
+	}
+	detail, err := fetchRepository(username + "/" + name)
+	switch {
+	case errors.Is(err, errRepositoryNotFound):
+		respondError(c, http.StatusNotFound, err.Error())
+		return "", false, false
+	case err != nil:
+		respondError(c, http.StatusBadGateway, err.Error())
+		return "", false, false
+	}
+	if !requestVisibility(c).includes(name, detail.Private) {
+		respondError(c, http.StatusNotFound, errRepositoryNotFound.Error())
+		return "", false, false
+	}
+	return name, detail.Private, true
+}
+
+/*
+getCommitSLOs は登録したSLOと直近の評価の結果を返すAPIハンドラー
+閲覧者のデータの範囲（PRIVACY_MODE・閲覧者から隠すリポジトリ）に含まれないリポジトリのSLOは返さない
+
+クエリパラメータ:
+  team - チーム名で絞り込む
+  status - 状態（pending, ok, violated）で絞り込む
+
+レスポンス:
+  成功時: 200 OK, {"slos": []CommitSLO（チーム名・リポジトリ名順）}
+  失敗時: 422 Unprocessable Entity（statusが不正）
+*/
+func getCommitSLOs(c *gin.Context) {
+	var query commitSLOQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	v := requestVisibility(c)
+
+	commitSLOs.mu.Lock()
+	slos := []CommitSLO{}
+	for _, slo := range commitSLOs.SLOs {
+		if !v.includes(slo.Repo, slo.Private) {
+			continue
+		}
+		if (query.Team != "" && slo.Team != query.Team) || (query.Status != "" && slo.Status != query.Status) {
+			continue
+		}
+		slos = append(slos, slo)
+	}
+	commitSLOs.mu.Unlock()
+
+	sort.SliceStable(slos, func(i, j int) bool {
+		if slos[i].Team != slos[j].Team {
+			return slos[i].Team < slos[j].Team
+		}
+		return slos[i].Repo < slos[j].Repo
+	})
+	respondJSON(c, http.StatusOK, gin.H{"slos": slos})
+}
+
+/*
+postCommitSLO はSLOを登録するAPIハンドラー（reader以上）
+
+リクエストボディ:
+  {"team": "backend", "repo": "giter", "min_commits": 1, "window_days": 7}
+  team は省略可
+
+レスポンス:
+  成功時: 201 Created, CommitSLO（状態はpending、次の同期で評価する）
+  失敗時: 400 Bad Request（不正なJSON）, 404 Not Found（リポジトリがない）, 422 Unprocessable Entity, 502 Bad Gateway（GitHubに確認できない）
+*/
+func postCommitSLO(c *gin.Context) {
+	var req commitSLORequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	name, private, ok := resolveSLORepository(c, req.Repo)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	slo := CommitSLO{
+		ID:         newSessionID(),
+		Team:       req.Team,
+		Repo:       name,
+		MinCommits: req.MinCommits,
+		WindowDays: req.WindowDays,
+		Private:    private,
+		Status:     sloStatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	commitSLOs.mu.Lock()
+	commitSLOs.SLOs = append(commitSLOs.SLOs, slo)
+	commitSLOs.save()
+	commitSLOs.mu.Unlock()
+
+	auditLog.record(c, "slo.create", slo.ID, nil)
+	respondJSON(c, http.StatusCreated, slo)
+}
+
+/*
+putCommitSLO はSLOの設定を置き換えるAPIハンドラー（reader以上）
+リクエストボディはpostCommitSLOと同じ。評価の結果は破棄し、次の同期で評価し直す
+
+レスポンス:
+  成功時: 200 OK, CommitSLO
+  失敗時: 404 Not Found（SLOまたはリポジトリがない）, 422 Unprocessable Entity, 502 Bad Gateway
+*/
+func putCommitSLO(c *gin.Context) {
+	var req commitSLORequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	name, private, ok := resolveSLORepository(c, req.Repo)
+	if !ok {
+		return
+	}
+
+	commitSLOs.mu.Lock()
+	i := commitSLOs.find(c.Param("id"))
+	if i < 0 || !requestVisibility(c).includes(commitSLOs.SLOs[i].Repo, commitSLOs.SLOs[i].Private) {
+		commitSLOs.mu.Unlock()
+		respondError(c, http.StatusNotFound, "slo not found")
+		return
+	}
+	slo := &commitSLOs.SLOs[i]
+	slo.Team, slo.Repo, slo.MinCommits, slo.WindowDays, slo.Private = req.Team, name, req.MinCommits, req.WindowDays, private
+	slo.Status, slo.Commits, slo.EvaluatedAt, slo.ViolatedSince = sloStatusPending, 0, nil, nil
+	slo.UpdatedAt = time.Now().UTC()
+	updated := *slo
+	commitSLOs.save()
+	commitSLOs.mu.Unlock()
+
+	auditLog.record(c, "slo.update", updated.ID, nil)
+	respondJSON(c, http.StatusOK, updated)
+}
+
+/*
+deleteCommitSLO はSLOを削除するAPIハンドラー（reader以上）
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 404 Not Found（SLOがない）
+*/
+func deleteCommitSLO(c *gin.Context) {
+	commitSLOs.mu.Lock()
+	i := commitSLOs.find(c.Param("id"))
+	if i < 0 || !requestVisibility(c).includes(commitSLOs.SLOs[i].Repo, commitSLOs.SLOs[i].Private) {
+		commitSLOs.mu.Unlock()
+		respondError(c, http.StatusNotFound, "slo not found")
+		return
+	}
+	commitSLOs.SLOs = append(commitSLOs.SLOs[:i], commitSLOs.SLOs[i+1:]...)
+	commitSLOs.save()
+	commitSLOs.mu.Unlock()
+
+	auditLog.record(c, "slo.delete", c.Param("id"), nil)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+遅いリクエストの警告
+ハンドラーの処理時間と、GitHub APIへの1回のリクエストの時間がしきい値を超えた場合にWARNのログを出力し、
+メトリクスを増やす。タイムアウトやエラーになる前の性能の劣化に気付けるようにする
+*/
+
+var (
+	/*
+		slowRequestThreshold はハンドラーの処理時間のしきい値
+		環境変数 SLOW_REQUEST_THRESHOLD で変更可能（デフォルト: 2秒、0の場合は警告しない）
+	*/
+	slowRequestThreshold = parseDurationEnv("SLOW_REQUEST_THRESHOLD", 2*time.Second)
+	/*
+		slowGitHubThreshold はGitHub APIへの1回のリクエスト（レスポンスヘッダーを受け取るまで）のしきい値
+		環境変数 SLOW_GITHUB_THRESHOLD で変更可能（デフォルト: 1秒、0の場合は警告しない）
+		操作ごとに SLOW_GITHUB_THRESHOLD_<操作>（例: SLOW_GITHUB_THRESHOLD_COMMITS=5s）で上書きできる
+	*/
+	slowGitHubThreshold = parseDurationEnv("SLOW_GITHUB_THRESHOLD", time.Second)
+)
+
+var (
+	slowRequestsTotal = newCounterVec(
+		"giter_slow_requests_total",
+		"Requests whose handler took longer than SLOW_REQUEST_THRESHOLD, by route.",
+		"route",
+	)
+	slowGitHubRequestsTotal = newCounterVec(
+		"giter_slow_github_requests_total",
+		"GitHub API requests that took longer than the operation's slow threshold.",
+		"operation",
+	)
+)
+
+/* slowGitHubThresholdFor は操作の種類のしきい値を返す（SLOW_GITHUB_THRESHOLD_<操作>、なければSLOW_GITHUB_THRESHOLD） */
+func slowGitHubThresholdFor(op string) time.Duration {
+	return parseDurationEnv("SLOW_GITHUB_THRESHOLD_"+strings.ToUpper(op), slowGitHubThreshold)
+}
+
+/*
+slowRequestMiddleware はハンドラーの処理時間がSLOW_REQUEST_THRESHOLDを超えたリクエストを警告するミドルウェア
+
+注意:
+  - SSE・WebSocket・ロングポーリングなど接続を保持し続けるリクエストは対象外（Content-Type: text/event-stream、Upgradeヘッダー、GET /api/git-history/poll）
+  - メトリクスのラベルにはルートのパターン（例: /api/repos/:owner/:repo）を使い、値の種類が増えないようにする
+*/
+func slowRequestMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		duration := time.Since(start)
+		if slowRequestThreshold <= 0 || duration <= slowRequestThreshold {
+			return
+		}
+		if c.GetHeader("Upgrade") != "" || strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") || c.GetBool(longPollContextKey) {
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		slowRequestsTotal.Inc(route)
+		log.Warn().
+			Str("route", route).
+			Str("method", c.Request.Method).
+			Int("status", c.Writer.Status()).
+			Dur("duration", duration).
+			Dur("threshold", slowRequestThreshold).
+			Str("request_id", requestID(c)).
+			Msg("Slow request")
+	}
+}
+
+/*
+checkSlowGitHubRequest はGitHub APIへのリクエストの時間が操作のしきい値を超えた場合に警告する
+instrumentedTransportから、レスポンスヘッダーを受け取った（または失敗した）時点で呼び出す
+*/
+func checkSlowGitHubRequest(op string, req *http.Request, duration time.Duration) {
+	threshold := slowGitHubThresholdFor(op)
+	if threshold <= 0 || duration <= threshold {
+		return
+	}
+	slowGitHubRequestsTotal.Inc(op)
+	log.Warn().
+		Str("operation", op).
+		Str("url", redactURL(req.URL)).
+		Dur("duration", duration).
+		Dur("threshold", threshold).
+		Msg("Slow GitHub API request")
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* snapshotsTable は同期した状態のスナップショットを保存するテーブル名 */
+	snapshotsTable = "snapshots"
+	/* snapshotIDFormat はスナップショットIDの形式（作成日時、UTC） */
+	snapshotIDFormat = "20060102T150405Z"
+)
+
+var (
+	/*
+		snapshotInterval はスナップショットを作成する間隔
+		環境変数 SNAPSHOT_INTERVAL で変更可能（デフォルト: 24時間、0の場合は定期的に作成しない）
+	*/
+	snapshotInterval = parseDurationEnv("SNAPSHOT_INTERVAL", 24*time.Hour)
+	/*
+		snapshotRetention は保持するスナップショットの数
+		環境変数 SNAPSHOT_RETENTION で変更可能（デフォルト: 14、超えた分は古いものから削除する）
+	*/
+	snapshotRetention = getEnvInt("SNAPSHOT_RETENTION", 14)
+)
+
+/*
+Snapshot はある時点で同期したリポジトリとコミットの状態
+*/
+type Snapshot struct {
+	ID        string          `json:"id"`         // スナップショットID（作成日時、例: "20250101T000000Z"）
+	CreatedAt time.Time       `json:"created_at"` // 作成日時
+	Repos     []string        `json:"repos"`      // リポジトリ名（名前順）
+	Commits   []CommitHistory `json:"commits"`    // 取得できたコミット（1リポジトリあたり最新100件まで）
+	/* PrivateRepos はReposのうちプライベートリポジトリの名前（PRIVACY_MODEで匿名の閲覧者から隠す） */
+	PrivateRepos []string `json:"private_repos,omitempty"`
+}
+
+/* SnapshotSummary はスナップショットの一覧に返す概要 */
+type SnapshotSummary struct {
+	ID        string    `json:"id"`         // スナップショットID
+	CreatedAt time.Time `json:"created_at"` // 作成日時
+	Repos     int       `json:"repos"`      // リポジトリ数
+	Commits   int       `json:"commits"`    // コミット数
+}
+
+/*
+SnapshotDiff は2つのスナップショットの差分
+*/
+type SnapshotDiff struct {
+	From         SnapshotSummary `json:"from"`          // 比較元のスナップショット
+	To           SnapshotSummary `json:"to"`            // 比較先のスナップショット
+	AddedRepos   []string        `json:"added_repos"`   // 比較先にのみあるリポジトリ
+	RemovedRepos []string        `json:"removed_repos"` // 比較元にのみあるリポジトリ
+	AddedCommits []CommitHistory `json:"added_commits"` // 比較先にのみあるコミット（新しい順）
+}
+
+/*
+snapshotStore はスナップショットを保持するストア
+snapshotsテーブルに永続化される
+*/
+type snapshotStore struct {
+	mu sync.Mutex
+	/* Snapshots は作成日時の古い順のスナップショット */
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+/* snapshots はアプリケーション全体で共有するスナップショットのストア */
+var snapshots = &snapshotStore{Snapshots: []Snapshot{}}
+
+func init() {
+	registerTable(snapshotsTable, loadSnapshots)
+}
+
+/*
+loadSnapshots はsnapshotsテーブルからスナップショットを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadSnapshots() error {
+	snapshots.mu.Lock()
+	defer snapshots.mu.Unlock()
+
+	snapshots.Snapshots = nil
+	if err := loadTable(snapshotsTable, snapshots); err != nil {
+		return err
+	}
+	if snapshots.Snapshots == nil {
+		snapshots.Snapshots = []Snapshot{}
+	}
+	return nil
+}
+
+/* summary はスナップショットの概要を返す */
+func (s Snapshot) summary() SnapshotSummary {
+	return SnapshotSummary{ID: s.ID, CreatedAt: s.CreatedAt, Repos: len(s.Repos), Commits: len(s.Commits)}
+}
+
+/*
+visible はデータの範囲に含まれないリポジトリとそのコミットを取り除いたスナップショットを返す
+閲覧者から隠すリポジトリは、スナップショットを作成した時点ではなく現在の設定（repoacl.go）で取り除く
+*/
+func (s Snapshot) visible(v visibility) Snapshot {
+	if v == visibilityOwner {
+		return s
+	}
+	private := map[string]bool{}
+	for _, name := range s.PrivateRepos {
+		private[name] = true
+	}
+	included := map[string]bool{}
+	for _, name := range s.Repos {
+		included[name] = v.includes(name, private[name])
+	}
+	visible := Snapshot{ID: s.ID, CreatedAt: s.CreatedAt, Repos: []string{}, Commits: []CommitHistory{}}
+	for _, name := range s.Repos {
+		if included[name] {
+			visible.Repos = append(visible.Repos, name)
+			if private[name] {
+				visible.PrivateRepos = append(visible.PrivateRepos, name)
+			}
+		}
+	}
+	for _, commit := range s.Commits {
+		if show, ok := included[commit.RepositoryName]; show || !ok && v.includes(commit.RepositoryName, false) {
+			visible.Commits = append(visible.Commits, commit)
+		}
+	}
+	return visible
+}
+
+/* list はデータの範囲に含まれるスナップショットの概要を新しい順に返す */
+func (s *snapshotStore) list(v visibility) []SnapshotSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := make([]SnapshotSummary, 0, len(s.Snapshots))
+	for i := len(s.Snapshots) - 1; i >= 0; i-- {
+		summaries = append(summaries, s.Snapshots[i].visible(v).summary())
+	}
+	return summaries
+}
+
+/* get はIDのスナップショットを返す */
+func (s *snapshotStore) get(id string) (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, snapshot := range s.Snapshots {
+		if snapshot.ID == id {
+			return snapshot, true
+		}
+	}
+	return Snapshot{}, false
+}
+
+/*
+add はスナップショットを追加し、snapshotRetentionを超えた古いものを削除して保存する
+同じIDのスナップショット（1秒以内に作成した場合）は置き換える
+*/
+func (s *snapshotStore) add(snapshot Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.Snapshots); n > 0 && s.Snapshots[n-1].ID == snapshot.ID {
+		s.Snapshots = s.Snapshots[:n-1]
+	}
+	s.Snapshots = append(s.Snapshots, snapshot)
+	if snapshotRetention > 0 && len(s.Snapshots) > snapshotRetention {
+		s.Snapshots = append([]Snapshot{}, s.Snapshots[len(s.Snapshots)-snapshotRetention:]...)
+	}
+	if err := saveTable(snapshotsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save snapshots")
+	}
+}
+
+/*
+takeSnapshot はリポジトリとコミットを取得してスナップショットを作成する
+
+引数:
+  priority jobPriority - コミットの取得のジョブを実行するレーン（定期実行時はjobPriorityBackground）
+*/
+func takeSnapshot(priority jobPriority) (Snapshot, error) {
+	commits, repos, err := fetchCommitHistoryAt(priority)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	now := time.Now().UTC()
+	snapshot := Snapshot{
+		ID:        now.Format(snapshotIDFormat),
+		CreatedAt: now,
+		Repos:     repositoryNames(repos),
+		Commits:   commits,
+	}
+	if snapshot.Commits == nil {
+		snapshot.Commits = []CommitHistory{}
+	}
+	for _, repo := range repos {
+		if repo.Private {
+			snapshot.PrivateRepos = append(snapshot.PrivateRepos, repo.Name)
+		}
+	}
+	sort.Strings(snapshot.Repos)
+	sort.Strings(snapshot.PrivateRepos)
+	snapshots.add(snapshot)
+	log.Info().Str("snapshot", snapshot.ID).Int("repos", len(snapshot.Repos)).Int("commits", len(snapshot.Commits)).Msg("Snapshot created")
+	return snapshot, nil
+}
+
+/*
+startSnapshotScheduler はsnapshotIntervalごとにスナップショットを作成するゴルーチンを起動する
+間隔が0の場合は何もしない
+*/
+func startSnapshotScheduler() {
+	if snapshotInterval <= 0 {
+		return
+	}
+	log.Info().Dur("interval", snapshotInterval).Msg("Snapshot scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if pausedForMaintenance(lockNameSnapshot) {
+				continue
+			}
+			runExclusive(lockNameSnapshot, func() {
+				if _, err := takeSnapshot(jobPriorityBackground); err != nil {
+					log.Error().Err(err).Msg("Scheduled snapshot failed")
+				}
+			})
+		}
+	}()
+}
+
+/*
+diffSnapshots はfromからtoまでに追加・削除されたリポジトリと、追加されたコミットを返す
+コミットはリポジトリ名とSHAで照合する
+*/
+func diffSnapshots(from, to Snapshot) SnapshotDiff {
+	diff := SnapshotDiff{
+		From:         from.summary(),
+		To:           to.summary(),
+		AddedRepos:   []string{},
+		RemovedRepos: []string{},
+		AddedCommits: []CommitHistory{},
+	}
+
+	fromRepos := map[string]bool{}
+	for _, name := range from.Repos {
+		fromRepos[name] = true
+	}
+	toRepos := map[string]bool{}
+	for _, name := range to.Repos {
+		toRepos[name] = true
+		if !fromRepos[name] {
+			diff.AddedRepos = append(diff.AddedRepos, name)
+		}
+	}
+	for _, name := range from.Repos {
+		if !toRepos[name] {
+			diff.RemovedRepos = append(diff.RemovedRepos, name)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, commit := range from.Commits {
+		seen[archiveKey(commit)] = true
+	}
+	for _, commit := range to.Commits {
+		if !seen[archiveKey(commit)] {
+			diff.AddedCommits = append(diff.AddedCommits, commit)
+		}
+	}
+	sort.SliceStable(diff.AddedCommits, func(i, j int) bool {
+		return diff.AddedCommits[i].CommitTime.After(diff.AddedCommits[j].CommitTime)
+	})
+	return diff
+}
+
+/*
+getSnapshots はスナップショットの一覧を返すAPIハンドラー
+
+レスポンス:
+  200 OK, {"snapshots": []SnapshotSummary（新しい順）}
+*/
+func getSnapshots(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{"snapshots": snapshots.list(requestVisibility(c))})
+}
+
+/*
+getSnapshotDiff は2つのスナップショットの差分を返すAPIハンドラー
+同期で何が変わったかの監査に使用する
+
+パスパラメータ:
+  a string - 比較元のスナップショットID
+  b string - 比較先のスナップショットID
+
+レスポンス:
+  成功時: 200 OK, SnapshotDiff
+  失敗時: 404 Not Found（スナップショットが存在しない）
+
+注意:
+  - スナップショットに含まれるのは1リポジトリあたり最新100件までのため、
+    比較元にだけあるコミット（古くなって取得範囲から外れたコミット）は差分に含めない
+*/
+func getSnapshotDiff(c *gin.Context) {
+	from, ok := snapshots.get(c.Param("a"))
+	if !ok {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("snapshot not found: %s", c.Param("a")))
+		return
+	}
+	to, ok := snapshots.get(c.Param("b"))
+	if !ok {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("snapshot not found: %s", c.Param("b")))
+		return
+	}
+	v := requestVisibility(c)
+	diff := diffSnapshots(from.visible(v), to.visible(v))
+	diff.AddedCommits = requestRedaction(c).commits(diff.AddedCommits)
+	respondJSON(c, http.StatusOK, diff)
+}
+
+/*
+postSnapshot はその場でスナップショットを作成する管理者APIハンドラー
+（管理者のリクエストのため、概要はプライベートリポジトリを含めて返す）
+
+レスポンス:
+  成功時: 201 Created, SnapshotSummary
+  失敗時: 502 Bad Gateway（GitHubから取得できない）
+*/
+func postSnapshot(c *gin.Context) {
+	snapshot, err := takeSnapshot(requestPriority(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create snapshot")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	respondJSON(c, http.StatusCreated, snapshot.summary())
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+statsLocation は日付・時刻ごとの集計に使用するタイムゾーン
+環境変数 STATS_TIMEZONE でIANAのタイムゾーン名を指定する（例: "Asia/Tokyo"、デフォルト: サーバーのローカル時刻）
+*/
+var statsLocation = loadStatsLocation(getEnv("STATS_TIMEZONE", ""))
+
+/* loadStatsLocation はタイムゾーン名を読み込む（空文字・不正な名前の場合はローカル時刻） */
+func loadStatsLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Warn().Err(err).Str("timezone", name).Msg("Invalid STATS_TIMEZONE, using local time")
+		return time.Local
+	}
+	return loc
+}
+
+/* fetchCommitHistory は全リポジトリのコミット履歴を通常の優先度で取得する（fetchCommitHistoryAtを参照） */
+func fetchCommitHistory() ([]CommitHistory, []Repository, error) {
+	return fetchCommitHistoryAt(jobPriorityNormal)
+}
+
+/*
+fetchCommitHistoryAt は全リポジトリのコミット履歴を取得する（集計APIの共通の入力）
+コミットを取得できなかったリポジトリはログ出力のみで結果から除外する
+
+引数:
+  priority jobPriority - 取得のジョブを実行するレーン（定期ジョブはjobPriorityBackground）
+
+戻り値:
+  []CommitHistory - 全リポジトリのコミット（リポジトリの順、各リポジトリ内は新しい順）
+  []Repository - 対象のリポジトリ一覧
+  error - リポジトリ一覧を取得できなかった場合のエラー
+
+注意:
+  - GitHub APIへのリクエストはgithubGetのキャッシュを経由するため、集計APIを続けて呼び出してもリクエスト数は増えない
+*/
+func fetchCommitHistoryAt(priority jobPriority) ([]CommitHistory, []Repository, error) {
+	repos, err := fetchRepositoriesAt(priority)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var commits []CommitHistory
+	results := fetchCommitsConcurrently(repos, priority)
+	for i, repo := range repos {
+		if results[i].Err != nil {
+			log.Warn().Err(results[i].Err).Str("repository", repo.Name).Msg("Failed to fetch commits for stats")
+			continue
+		}
+		for _, commit := range results[i].Commits {
+			commits = append(commits, newCommitHistory(repo.Name, commit))
+		}
+	}
+	commitSearch.add(commits)
+	statsViews.sync(commits, repos)
+	return commits, repos, nil
+}
+
+/*
+CommitBucket は一定間隔で区切った期間ごとのコミット数
+*/
+type CommitBucket struct {
+	Start time.Time // 期間の開始日時
+	Count int       // 期間内のコミット数
+}
+
+/*
+bucketCommits はコミットを from から to までの step 間隔の期間に振り分けて数える
+コミットのない期間も0件として含める
+
+引数:
+  commits []CommitHistory - 集計対象のコミット
+  from, to time.Time - 集計期間（from以上to未満）
+  step time.Duration - 期間の長さ（0以下の場合は1日）
+
+戻り値:
+  []CommitBucket - 古い順の期間ごとのコミット数
+*/
+func bucketCommits(commits []CommitHistory, from, to time.Time, step time.Duration) []CommitBucket {
+	if step <= 0 {
+		step = 24 * time.Hour
+	}
+	if !to.After(from) {
+		return []CommitBucket{}
+	}
+
+	n := int((to.Sub(from) + step - 1) / step)
+	buckets := make([]CommitBucket, n)
+	for i := range buckets {
+		buckets[i].Start = from.Add(time.Duration(i) * step)
+	}
+	for _, commit := range commits {
+		if commit.CommitTime.Before(from) || !commit.CommitTime.Before(to) {
+			continue
+		}
+		buckets[int(commit.CommitTime.Sub(from)/step)].Count++
+	}
+	return buckets
+}
+
+/* repositoryNames はリポジトリ名を名前順で返す */
+func repositoryNames(repos []Repository) []string {
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+runConcurrently はfn(0)〜fn(n-1)をsyncWorkers個のワーカーで並行して実行し、すべての完了を待つ
+リポジトリごとにGitHub APIを呼び出す集計で使用する（fnは結果をインデックスの位置に書き込むこと）
+*/
+func runConcurrently(n int, fn func(i int)) {
+	workers := syncWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+/* statsDateFormat は日ごとの集計のキーに使用する日付の形式 */
+const statsDateFormat = "2006-01-02"
+
+/*
+dailyCommitCounts はコミットを日付（statsLocationの日付、"2006-01-02"形式）ごとに数える
+
+戻り値:
+  map[string]int - 日付ごとのコミット数（コミットのない日はキーなし）
+*/
+func dailyCommitCounts(commits []CommitHistory) map[string]int {
+	counts := map[string]int{}
+	for _, commit := range commits {
+		counts[commit.CommitTime.In(statsLocation).Format(statsDateFormat)]++
+	}
+	return counts
+}
+
+/*
+currentStreak はdayまで連続してコミットがある日数を返す
+dayにまだコミットがない場合は前日までの連続日数を返す（その日のうちにコミットすれば継続できるため）
+
+戻り値:
+  int - 連続日数
+  bool - dayにコミットがあるかどうか
+*/
+func currentStreak(counts map[string]int, day time.Time) (int, bool) {
+	today := counts[day.Format(statsDateFormat)] > 0
+	if !today {
+		day = day.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for counts[day.Format(statsDateFormat)] > 0 {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak, today
+}
+
+/*
+longestStreak はfromからtoまで（両端の日を含む）の間で、最も長くコミットが続いた期間を返す
+
+引数:
+  counts map[string]int - dailyCommitCountsの結果
+  from, to time.Time - 対象期間の初日と最終日（statsLocationの0時）
+
+戻り値:
+  int - 連続日数（コミットがない場合は0）
+  time.Time, time.Time - 期間の初日と最終日
+*/
+func longestStreak(counts map[string]int, from, to time.Time) (int, time.Time, time.Time) {
+	best, run := 0, 0
+	var bestStart, bestEnd, runStart time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if counts[d.Format(statsDateFormat)] == 0 {
+			run = 0
+			continue
+		}
+		if run == 0 {
+			runStart = d
+		}
+		run++
+		if run > best {
+			best, bestStart, bestEnd = run, runStart, d
+		}
+	}
+	return best, bestStart, bestEnd
+}
+
+/*
+fetchCommitsInRange は指定した期間のコミットをページを辿って取得する
+fetchCommitsは最新100件のみのため、年単位の集計など長い期間を対象にする場合に使用する
+API仕様: https://docs.github.com/ja/rest/commits/commits#list-commits
+
+引数:
+  repoFullName string - リポジトリのフルネーム
+  since, until time.Time - 取得する期間
+  maxPages int - 取得する最大ページ数（1ページ100件）
+
+戻り値:
+  []Commit - 期間内のコミット（新しい順）
+  bool - maxPagesに達して取得を打ち切った場合はtrue
+  error - 取得に失敗した場合のエラー
+*/
+func fetchCommitsInRange(repoFullName string, since, until time.Time, maxPages int) ([]Commit, bool, error) {
+	var all []Commit
+	for page := 1; page <= maxPages; page++ {
+		url := fmt.Sprintf("%s/repos/%s/commits?since=%s&until=%s&per_page=100&page=%d", githubAPIBase, repoFullName,
+			since.UTC().Format(time.RFC3339), until.UTC().Format(time.RFC3339), page)
+		var commits []Commit
+		if err := fetchGitHubJSON(upstreamOpCommits, url, githubAcceptV3, &commits); err != nil {
+			return nil, false, err
+		}
+		all = append(all, commits...)
+		if len(commits) < 100 {
+			return all, false, nil
+		}
+	}
+	return all, true, nil
+}
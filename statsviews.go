@@ -0,0 +1,341 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+集計のマテリアライズドビュー
+同期（fetchCommitHistoryAt と /api/git-history）で取得したコミットを、リポジトリ・日付ごとのコミット数と
+直近7・30・90日間のコミット数に加算しておき、集計APIがリクエストのたびに全コミットを走査しないようにする
+アーカイブ（HistoryStore）のコミットは最初の参照の際にまとめて加算する
+*/
+
+/* statsWindowMaxDays はビューで集計する最も長い直近の期間（日数） */
+const statsWindowMaxDays = 90
+
+/*
+statsViewsMaxAge はビューを同期せずに返す最大の経過時間
+最後の同期からこれより経過した場合は、参照の前にコミット履歴を取得し直す
+環境変数 STATS_VIEWS_MAX_AGE で変更可能（デフォルト: 5m）
+*/
+var statsViewsMaxAge = parseDurationEnv("STATS_VIEWS_MAX_AGE", 5*time.Minute)
+
+/*
+WindowCounts は直近の期間ごとのコミット数（今日を含む、statsLocationの日付で区切る）
+*/
+type WindowCounts struct {
+	Last7Days  int `json:"last_7_days"`  // 直近7日間のコミット数
+	Last30Days int `json:"last_30_days"` // 直近30日間のコミット数
+	Last90Days int `json:"last_90_days"` // 直近90日間のコミット数
+}
+
+/* add は別のリポジトリの期間ごとのコミット数を加算する */
+func (w *WindowCounts) add(other WindowCounts) {
+	w.Last7Days += other.Last7Days
+	w.Last30Days += other.Last30Days
+	w.Last90Days += other.Last90Days
+}
+
+/*
+statsViewStore はリポジトリ・日付ごとのコミット数のビュー
+コミットは追加のみ行い、アーカイブの削除（保持期間・データの削除）の際はresetで作り直す
+*/
+type statsViewStore struct {
+	mu         sync.RWMutex
+	seeded     bool                      // アーカイブのコミットを加算済みかどうか
+	syncedAt   time.Time                 // 最後に同期の結果を加算した日時
+	keys       map[string]bool           // 加算したコミットのarchiveKey（重複の加算を防ぐ）
+	private    map[string]bool           // プライベートリポジトリの名前（データの範囲の絞り込みに使用）
+	daily      map[string]map[string]int // リポジトリごとの日付（statsDateFormat）ごとのコミット数
+	latest     map[string]time.Time      // リポジトリごとの最も新しいコミットの日時
+	windowsDay string                    // windowsを計算した基準日（コミットの加算・日付の変更で計算し直す）
+	windows    map[string]WindowCounts   // リポジトリごとの直近の期間のコミット数
+}
+
+/* statsViews はアプリケーション全体で共有する集計のビュー */
+var statsViews = newStatsViewStore()
+
+/* newStatsViewStore は空のビューを作成する */
+func newStatsViewStore() *statsViewStore {
+	return &statsViewStore{
+		keys:    map[string]bool{},
+		private: map[string]bool{},
+		daily:   map[string]map[string]int{},
+		latest:  map[string]time.Time{},
+	}
+}
+
+/*
+sync は同期で取得したコミットをビューに加算する（同期のたびに呼び出す）
+
+引数:
+  commits []CommitHistory - 取得したコミット（加算済みのコミットは無視する）
+  repos []Repository - 取得したリポジトリ一覧（プライベートかどうかを記録する）
+*/
+func (s *statsViewStore) sync(commits []CommitHistory, repos []Repository) {
+	s.merge(commits, repos)
+	s.mu.Lock()
+	s.syncedAt = time.Now()
+	s.mu.Unlock()
+}
+
+/* merge は一部のリポジトリのみの同期（追加したリポジトリなど）で取得したコミットを、最後の同期の日時を変えずに加算する */
+func (s *statsViewStore) merge(commits []CommitHistory, repos []Repository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, repo := range repos {
+		s.private[repo.Name] = repo.Private
+	}
+	s.add(commits)
+}
+
+/* add はまだ加算していないコミットを加算する（s.muのロックを取得してから呼び出す） */
+func (s *statsViewStore) add(commits []CommitHistory) int {
+	added := 0
+	for _, commit := range commits {
+		key := archiveKey(commit)
+		if s.keys[key] {
+			continue
+		}
+		s.keys[key] = true
+
+		repo := commit.RepositoryName
+		if s.daily[repo] == nil {
+			s.daily[repo] = map[string]int{}
+		}
+		s.daily[repo][commit.CommitTime.In(statsLocation).Format(statsDateFormat)]++
+		if commit.CommitTime.After(s.latest[repo]) {
+			s.latest[repo] = commit.CommitTime
+		}
+		added++
+	}
+	if added > 0 {
+		s.windowsDay = ""
+	}
+	return added
+}
+
+/* reset はビューを空にし、次の参照でアーカイブと同期の結果から作り直す（アーカイブのコミットを削除した場合に使用） */
+func (s *statsViewStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fresh := newStatsViewStore()
+	s.seeded = false
+	s.syncedAt = time.Time{}
+	s.keys, s.private, s.daily, s.latest = fresh.keys, fresh.private, fresh.daily, fresh.latest
+	s.windowsDay, s.windows = "", nil
+}
+
+/*
+refresh はアーカイブのコミットをまだ加算していなければ加算し、最後の同期からSTATS_VIEWS_MAX_AGEが経過していれば同期する
+
+戻り値:
+  error - アーカイブを読み込めない場合、または一度も同期していない状態でGitHubから取得できない場合のエラー
+          同期済みのビューがある場合、取得の失敗はログ出力のみで古いビューを返す
+*/
+func (s *statsViewStore) refresh() error {
+	s.mu.RLock()
+	seeded, syncedAt := s.seeded, s.syncedAt
+	s.mu.RUnlock()
+
+	if !seeded {
+		archived, err := history.LoadAll()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		added := s.add(branchHeads.withoutOrphans(archived))
+		s.seeded = true
+		s.mu.Unlock()
+		log.Info().Int("added", added).Msg("Stats views seeded from archive")
+	}
+
+	if !syncedAt.IsZero() && time.Since(syncedAt) < statsViewsMaxAge {
+		return nil
+	}
+	/* fetchCommitHistoryAtがsyncを呼び出す */
+	if _, _, err := fetchCommitHistory(); err != nil {
+		if syncedAt.IsZero() {
+			return err
+		}
+		log.Warn().Err(err).Time("synced_at", syncedAt).Msg("Failed to refresh stats views, serving stale views")
+	}
+	return nil
+}
+
+/*
+visible はリポジトリがデータの範囲に含まれるかどうかを返す（s.muのロックを取得してから呼び出す）
+管理者APIで除外したリポジトリは、アーカイブから加算したコミットがあっても含めない
+*/
+func (s *statsViewStore) visible(repo string, v visibility) bool {
+	return v.includes(repo, s.private[repo]) && !repoTracking.isUntracked(repo)
+}
+
+/*
+dailyCounts はデータの範囲に含まれるリポジトリの日付ごとのコミット数を返す（dailyCommitCountsと同じ形式）
+
+引数:
+  v visibility - データの範囲
+  repo string - リポジトリ名で絞り込む（空文字の場合は全リポジトリ）
+*/
+func (s *statsViewStore) dailyCounts(v visibility, repo string) map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for name, days := range s.daily {
+		if (repo != "" && name != repo) || !s.visible(name, v) {
+			continue
+		}
+		for day, n := range days {
+			counts[day] += n
+		}
+	}
+	return counts
+}
+
+/* windowCounts はリポジトリごとの直近の期間のコミット数を返す（基準日が変わった場合とコミットを加算した場合のみ計算し直す） */
+func (s *statsViewStore) windowCounts(today time.Time) map[string]WindowCounts {
+	day := today.Format(statsDateFormat)
+	s.mu.RLock()
+	if s.windowsDay == day {
+		defer s.mu.RUnlock()
+		return s.windows
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.windowsDay != day {
+		windows := make(map[string]WindowCounts, len(s.daily))
+		for repo, days := range s.daily {
+			var w WindowCounts
+			for i := 0; i < statsWindowMaxDays; i++ {
+				n := days[today.AddDate(0, 0, -i).Format(statsDateFormat)]
+				if i < 7 {
+					w.Last7Days += n
+				}
+				if i < 30 {
+					w.Last30Days += n
+				}
+				w.Last90Days += n
+			}
+			windows[repo] = w
+		}
+		s.windows, s.windowsDay = windows, day
+	}
+	return s.windows
+}
+
+/*
+RepositoryWindows はリポジトリの直近の期間のコミット数
+*/
+type RepositoryWindows struct {
+	Repo         string     `json:"repo"`           // リポジトリ名
+	LastCommitAt *time.Time `json:"last_commit_at"` // 最も新しいコミットの日時（アーカイブを含む）
+	WindowCounts
+}
+
+/*
+StatsWindowsResponse は /api/stats/windows のレスポンス
+*/
+type StatsWindowsResponse struct {
+	Timezone     string              `json:"timezone"`     // 日付の区切りに使用したタイムゾーン
+	Today        string              `json:"today"`        // 期間の最終日（"2006-01-02"形式）
+	SyncedAt     *time.Time          `json:"synced_at"`    // ビューに最後に同期の結果を加算した日時
+	Total        WindowCounts        `json:"total"`        // 全リポジトリの合計
+	Repositories []RepositoryWindows `json:"repositories"` // リポジトリごとのコミット数（直近30日間の多い順）
+}
+
+/*
+getStatsWindows はリポジトリごとの直近7・30・90日間のコミット数を返すAPIハンドラー
+集計のビューから返すため、コミット数に関係なく一定の時間で応答する
+
+クエリパラメータ:
+  repo string - リポジトリ名で絞り込む（省略時は全リポジトリ）
+
+レスポンス:
+  成功時: 200 OK, StatsWindowsResponse
+  失敗時: 500 Internal Server Error（アーカイブを読み込めない）, 502 Bad Gateway（一度も同期しておらず、GitHubから取得できない）
+
+注意:
+  - 最後の同期からSTATS_VIEWS_MAX_AGEが経過している場合は、応答の前に同期する
+*/
+func getStatsWindows(c *gin.Context) {
+	var params struct {
+		Repo string `form:"repo"`
+	}
+	if !bindQuery(c, &params) {
+		return
+	}
+	if !refreshStatsViews(c) {
+		return
+	}
+
+	now := time.Now().In(statsLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, statsLocation)
+	windows := statsViews.windowCounts(today)
+
+	v := requestVisibility(c)
+	resp := StatsWindowsResponse{
+		Timezone:     statsLocation.String(),
+		Today:        today.Format(statsDateFormat),
+		Repositories: []RepositoryWindows{},
+	}
+	statsViews.mu.RLock()
+	if !statsViews.syncedAt.IsZero() {
+		syncedAt := statsViews.syncedAt
+		resp.SyncedAt = &syncedAt
+	}
+	for repo, w := range windows {
+		if (params.Repo != "" && repo != params.Repo) || !statsViews.visible(repo, v) {
+			continue
+		}
+		entry := RepositoryWindows{Repo: repo, WindowCounts: w}
+		if latest, ok := statsViews.latest[repo]; ok {
+			entry.LastCommitAt = &latest
+		}
+		resp.Repositories = append(resp.Repositories, entry)
+		resp.Total.add(w)
+	}
+	statsViews.mu.RUnlock()
+
+	sort.Slice(resp.Repositories, func(i, j int) bool {
+		a, b := resp.Repositories[i], resp.Repositories[j]
+		if a.Last30Days != b.Last30Days {
+			return a.Last30Days > b.Last30Days
+		}
+		return a.Repo < b.Repo
+	})
+	respondJSON(c, http.StatusOK, resp)
+}
+
+/* refreshStatsViews はビューを参照の前に更新する（失敗した場合はエラーのレスポンスを返し済みでfalse） */
+func refreshStatsViews(c *gin.Context) bool {
+	if err := statsViews.refresh(); err != nil {
+		log.Error().Err(err).Msg("Failed to refresh stats views")
+		status := http.StatusBadGateway
+		if !statsViews.isSeeded() {
+			status = http.StatusInternalServerError
+		}
+		respondError(c, status, err.Error())
+		return false
+	}
+	return true
+}
+
+/* isSeeded はアーカイブのコミットを加算済みかどうかを返す */
+func (s *statsViewStore) isSeeded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seeded
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+dataDir は永続化データ（JSONテーブル）の保存先ディレクトリ
+環境変数 DATA_DIR で変更可能（デフォルト: "data"）
+*/
+var dataDir = getEnv("DATA_DIR", "data")
+
+/*
+tablePath はテーブル名に対応するJSONファイルのパスを返す
+例: "notifications" -> data/notifications.json
+*/
+func tablePath(name string) string {
+	return filepath.Join(dataDir, name+".json")
+}
+
+/*
+loadTable はJSONテーブルを読み込み、vにデコードする
+ファイルが存在しない場合（初回起動時など）はvを変更せずnilを返す
+
+引数:
+  name string - テーブル名（拡張子なし）
+  v interface{} - デコード先のポインタ
+
+戻り値:
+  error - 読み込みまたはJSONパースに失敗した場合のエラー
+*/
+func loadTable(name string, v interface{}) error {
+	data, err := os.ReadFile(tablePath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read table %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode table %s: %w", name, err)
+	}
+	return nil
+}
+
+/*
+saveTable はvをJSONにエンコードしてテーブルに保存する
+書き込みはwriteTableFileで一時ファイルからのリネームにより行うため、
+書き込み途中でプロセスが停止してもファイルが壊れない
+
+引数:
+  name string - テーブル名（拡張子なし）
+  v interface{} - 保存する値
+
+戻り値:
+  error - ディレクトリ作成、書き込み、リネームに失敗した場合のエラー
+*/
+func saveTable(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode table %s: %w", name, err)
+	}
+
+	return writeTableFile(name, data)
+}
+
+/*
+tableLoaders はテーブル名ごとの読み込み関数
+各ストアがinit()でregisterTableを呼び出して登録し、起動時やインポート後に
+loadTablesでまとめてメモリ上の状態を復元する
+*/
+var tableLoaders = map[string]func() error{}
+
+/*
+registerTable はテーブルとその読み込み関数を登録する
+
+引数:
+  name string - テーブル名（拡張子なし）
+  load func() error - テーブルを読み込んでメモリ上の状態を置き換える関数
+*/
+func registerTable(name string, load func() error) {
+	tableLoaders[name] = load
+}
+
+/*
+loadTables は登録されたすべてのテーブルを読み込む
+1つのテーブルの読み込みに失敗しても残りのテーブルの読み込みは続け、
+最初に発生したエラーを返す
+*/
+func loadTables() error {
+	var firstErr error
+	for name, load := range tableLoaders {
+		if err := load(); err != nil {
+			log.Error().Err(err).Str("table", name).Msg("Failed to load table")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+/*
+tableNames は登録されているテーブル名をソートして返す
+エクスポートやバックアップの対象を決めるために使用する
+*/
+func tableNames() []string {
+	names := make([]string, 0, len(tableLoaders))
+	for name := range tableLoaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+readTableFile はテーブルのJSONファイルの内容をそのまま返す
+ファイルが存在しない場合は (nil, nil) を返す
+*/
+func readTableFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(tablePath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+/*
+writeTableFile はJSONデータをそのままテーブルのファイルに書き込む
+一時ファイルに書き込んでからリネームすることで、
+書き込み途中でプロセスが停止してもファイルが壊れないようにする
+*/
+func writeTableFile(name string, data []byte) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	path := tablePath(name)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write table %s: %w", name, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace table %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* syncCursorsTable はリポジトリごとの同期の位置（最後に取得したコミットとETag）を保存するテーブル名 */
+	syncCursorsTable = "sync_cursors"
+)
+
+/*
+SyncCursor はリポジトリごとのコミット同期の位置
+再起動後はこの内容をGitHub APIのキャッシュに戻し、最初の同期からETagによる条件付きリクエストにする
+*/
+type SyncCursor struct {
+	URL      string    `json:"url"`       // コミット一覧の取得に使用したURL
+	LastSHA  string    `json:"last_sha"`  // 最後に取得した最新のコミットのSHA
+	ETag     string    `json:"etag"`      // コミット一覧のレスポンスのETag
+	SyncedAt time.Time `json:"synced_at"` // 最後に内容が変わった日時
+	Commits  []Commit  `json:"commits"`   // 最後に取得したコミット（304が返った場合にこの内容を返す）
+}
+
+/*
+syncCursorStore は全リポジトリの同期の位置を保持するストア
+sync_cursorsテーブルに永続化される
+*/
+type syncCursorStore struct {
+	mu sync.Mutex
+	/* Repositories はリポジトリのフルネームごとの同期の位置 */
+	Repositories map[string]SyncCursor `json:"repositories"`
+}
+
+/* syncCursors はアプリケーション全体で共有する同期の位置のストア */
+var syncCursors = &syncCursorStore{Repositories: map[string]SyncCursor{}}
+
+func init() {
+	registerTable(syncCursorsTable, loadSyncCursors)
+}
+
+/*
+loadSyncCursors はsync_cursorsテーブルから同期の位置を復元し、GitHub APIのキャッシュに登録する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadSyncCursors() error {
+	syncCursors.mu.Lock()
+	defer syncCursors.mu.Unlock()
+
+	syncCursors.Repositories = nil
+	if err := loadTable(syncCursorsTable, syncCursors); err != nil {
+		return err
+	}
+	if syncCursors.Repositories == nil {
+		syncCursors.Repositories = map[string]SyncCursor{}
+	}
+
+	seeded := 0
+	for name, cursor := range syncCursors.Repositories {
+		if cursor.ETag == "" || cursor.URL == "" {
+			continue
+		}
+		body, err := json.Marshal(cursor.Commits)
+		if err != nil {
+			log.Warn().Err(err).Str("repository", name).Msg("Failed to restore sync cursor")
+			continue
+		}
+		seedGitHubCache(cursor.URL, githubAcceptV3, cursor.ETag, body, cursor.SyncedAt)
+		seeded++
+	}
+	log.Debug().Int("repositories", seeded).Msg("Sync cursors restored")
+	return nil
+}
+
+/* save は同期の位置をテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *syncCursorStore) save() {
+	if err := saveTable(syncCursorsTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save sync cursors")
+	}
+}
+
+/*
+record はコミット一覧の取得結果を同期の位置として記録する
+最新のコミットとETagが前回と同じ場合は保存しない（304で再検証しただけの同期で書き込まない）
+
+引数:
+  repoFullName string - リポジトリのフルネーム
+  url string - コミット一覧の取得に使用したURL
+  commits []Commit - 取得したコミット（新しい順）
+*/
+func (s *syncCursorStore) record(repoFullName, url string, commits []Commit) {
+	cursor := SyncCursor{
+		URL:      url,
+		ETag:     cachedETag(url, githubAcceptV3),
+		SyncedAt: time.Now(),
+		Commits:  commits,
+	}
+	if len(commits) > 0 {
+		cursor.LastSHA = commits[0].SHA
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.Repositories[repoFullName]
+	if ok && prev.URL == cursor.URL && prev.LastSHA == cursor.LastSHA && prev.ETag == cursor.ETag {
+		return
+	}
+	s.Repositories[repoFullName] = cursor
+	s.save()
+}
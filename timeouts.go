@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+上流（GitHub API）への操作の種類
+操作ごとに適切なタイムアウトが異なるため（大きなリポジトリのコミット取得は遅く、
+ヘルスチェックはすぐに失敗を返したい）、種類ごとに別のHTTPクライアントを使用する
+*/
+const (
+	upstreamOpRepositories = "repositories" // リポジトリ一覧の取得
+	upstreamOpRepository   = "repository"   // 個別のリポジトリの詳細・ブランチの取得
+	upstreamOpCommits      = "commits"      // コミット履歴の取得
+	upstreamOpActivity     = "activity"     // PR・Issue・リリース・スターの取得
+	upstreamOpProxy        = "proxy"        // /proxy/github による中継
+	upstreamOpHealth       = "health"       // ヘルスチェック
+	upstreamOpImage        = "image"        // /img/proxy によるアバター・OGP画像の取得
+	upstreamOpOAuth        = "oauth"        // GitHubのOAuthによるログイン（アクセストークンの交換・ユーザーの取得）
+)
+
+/*
+TimeoutConfig は1つの操作の種類に適用するタイムアウト設定
+*/
+type TimeoutConfig struct {
+	Connect        time.Duration `json:"connect"`         // TCP接続の確立までのタイムアウト
+	TLSHandshake   time.Duration `json:"tls_handshake"`   // TLSハンドシェイクのタイムアウト
+	ResponseHeader time.Duration `json:"response_header"` // リクエスト送信後、レスポンスヘッダーを受け取るまでのタイムアウト
+	Overall        time.Duration `json:"overall"`         // 接続からレスポンスボディの読み込み完了までの全体のタイムアウト
+}
+
+/*
+defaultTimeouts は環境変数で上書きされない場合の操作ごとのタイムアウト
+defaultの値は従来の固定値（全体10秒）を引き継いでいる
+*/
+var defaultTimeouts = map[string]TimeoutConfig{
+	"default":          {Connect: 5 * time.Second, TLSHandshake: 5 * time.Second, ResponseHeader: 10 * time.Second, Overall: 10 * time.Second},
+	upstreamOpCommits:  {Connect: 5 * time.Second, TLSHandshake: 5 * time.Second, ResponseHeader: 20 * time.Second, Overall: 30 * time.Second},
+	upstreamOpActivity: {Connect: 5 * time.Second, TLSHandshake: 5 * time.Second, ResponseHeader: 15 * time.Second, Overall: 20 * time.Second},
+	upstreamOpHealth:   {Connect: 2 * time.Second, TLSHandshake: 2 * time.Second, ResponseHeader: 3 * time.Second, Overall: 3 * time.Second},
+}
+
+/*
+loadTimeoutConfig は操作の種類に適用するタイムアウト設定を環境変数から読み込む
+優先順位は以下の通り（上にあるものほど優先される）:
+  1. GITHUB_TIMEOUT_<操作>_<種類>   例: GITHUB_TIMEOUT_COMMITS_OVERALL=60s
+  2. GITHUB_TIMEOUT_<種類>          例: GITHUB_TIMEOUT_CONNECT=3s（全操作に適用）
+  3. defaultTimeoutsの操作ごとの値、なければdefaultの値
+
+<種類> は CONNECT, TLS, RESPONSE_HEADER, OVERALL のいずれか
+
+引数:
+  op string - 操作の種類（upstreamOp* 定数）
+
+戻り値:
+  TimeoutConfig - 適用するタイムアウト設定
+*/
+func loadTimeoutConfig(op string) TimeoutConfig {
+	cfg, ok := defaultTimeouts[op]
+	if !ok {
+		cfg = defaultTimeouts["default"]
+	}
+
+	prefix := "GITHUB_TIMEOUT_" + strings.ToUpper(op) + "_"
+	resolve := func(kind string, fallback time.Duration) time.Duration {
+		return parseDurationEnv(prefix+kind, parseDurationEnv("GITHUB_TIMEOUT_"+kind, fallback))
+	}
+	cfg.Connect = resolve("CONNECT", cfg.Connect)
+	cfg.TLSHandshake = resolve("TLS", cfg.TLSHandshake)
+	cfg.ResponseHeader = resolve("RESPONSE_HEADER", cfg.ResponseHeader)
+	cfg.Overall = resolve("OVERALL", cfg.Overall)
+	return cfg
+}
+
+/*
+upstreamClients は操作の種類ごとに作成したHTTPクライアント
+http.Clientは並行して安全に使用でき、使い回すことで接続も再利用されるため、
+操作の種類ごとに1つだけ作成する
+*/
+var upstreamClients = struct {
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}{clients: map[string]*http.Client{}}
+
+/*
+upstreamClient は操作の種類に応じたタイムアウトを設定したHTTPクライアントを返す
+初回呼び出し時に作成し、以降は同じクライアントを返す
+
+引数:
+  op string - 操作の種類（upstreamOp* 定数）
+*/
+func upstreamClient(op string) *http.Client {
+	upstreamClients.mu.Lock()
+	defer upstreamClients.mu.Unlock()
+
+	if client, ok := upstreamClients.clients[op]; ok {
+		return client
+	}
+
+	cfg := loadTimeoutConfig(op)
+	client := &http.Client{
+		Timeout:   cfg.Overall,
+		Transport: newUpstreamTransport(op, cfg),
+	}
+	upstreamClients.clients[op] = client
+
+	log.Debug().
+		Str("operation", op).
+		Dur("connect", cfg.Connect).
+		Dur("tls_handshake", cfg.TLSHandshake).
+		Dur("response_header", cfg.ResponseHeader).
+		Dur("overall", cfg.Overall).
+		Msg("Upstream HTTP client created")
+	return client
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* repoTrackingTable は実行中に追加・除外したリポジトリを保存するテーブル名 */
+	repoTrackingTable = "tracked_repositories"
+)
+
+/*
+RepositoryTracking は管理者APIで追加・除外したリポジトリ
+GitHubのリポジトリ一覧（usernameの公開リポジトリ、最大100件）に対して、追加したリポジトリを加え、除外したリポジトリを取り除く
+*/
+type RepositoryTracking struct {
+	Tracked   map[string]time.Time `json:"tracked"`   // 一覧に追加したリポジトリ名と追加した日時
+	Untracked map[string]time.Time `json:"untracked"` // 一覧から除外したリポジトリ名と除外した日時
+}
+
+/*
+repoTrackingStore は追加・除外したリポジトリを保持するストア
+tracked_repositoriesテーブルに永続化される
+*/
+type repoTrackingStore struct {
+	mu sync.Mutex
+	RepositoryTracking
+}
+
+/* repoTracking はアプリケーション全体で共有するリポジトリの追加・除外のストア */
+var repoTracking = &repoTrackingStore{RepositoryTracking: RepositoryTracking{Tracked: map[string]time.Time{}, Untracked: map[string]time.Time{}}}
+
+func init() {
+	registerTable(repoTrackingTable, loadRepoTracking)
+}
+
+/*
+loadRepoTracking はtracked_repositoriesテーブルからストアを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadRepoTracking() error {
+	repoTracking.mu.Lock()
+	defer repoTracking.mu.Unlock()
+
+	repoTracking.RepositoryTracking = RepositoryTracking{}
+	if err := loadTable(repoTrackingTable, &repoTracking.RepositoryTracking); err != nil {
+		return err
+	}
+	if repoTracking.Tracked == nil {
+		repoTracking.Tracked = map[string]time.Time{}
+	}
+	if repoTracking.Untracked == nil {
+		repoTracking.Untracked = map[string]time.Time{}
+	}
+	return nil
+}
+
+/* snapshot は追加・除外したリポジトリのコピーを返す */
+func (s *repoTrackingStore) snapshot() RepositoryTracking {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := RepositoryTracking{Tracked: map[string]time.Time{}, Untracked: map[string]time.Time{}}
+	for name, at := range s.Tracked {
+		copied.Tracked[name] = at
+	}
+	for name, at := range s.Untracked {
+		copied.Untracked[name] = at
+	}
+	return copied
+}
+
+/* isUntracked はリポジトリを一覧から除外しているかどうかを返す */
+func (s *repoTrackingStore) isUntracked(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.Untracked[name]
+	return ok
+}
+
+/*
+set はリポジトリを追加（tracked=true）または除外（tracked=false）して保存する
+追加と除外は互いに取り消し合うため、最後の操作のみ残る
+*/
+func (s *repoTrackingStore) set(name string, tracked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if tracked {
+		delete(s.Untracked, name)
+		s.Tracked[name] = now
+	} else {
+		delete(s.Tracked, name)
+		s.Untracked[name] = now
+	}
+	return saveTable(repoTrackingTable, &s.RepositoryTracking)
+}
+
+/*
+applyRepositoryTracking はGitHubから取得したリポジトリ一覧に、追加・除外したリポジトリを反映する
+追加したリポジトリのうち一覧にないものは個別に取得する（githubGetのキャッシュを経由する）
+
+注意:
+  - 追加したリポジトリを取得できない場合（削除された場合など）はログ出力のみで一覧に含めない
+*/
+func applyRepositoryTracking(repos []Repository) []Repository {
+	tracking := repoTracking.snapshot()
+	if len(tracking.Tracked) == 0 && len(tracking.Untracked) == 0 {
+		return repos
+	}
+
+	listed := map[string]bool{}
+	applied := make([]Repository, 0, len(repos)+len(tracking.Tracked))
+	for _, repo := range repos {
+		listed[repo.Name] = true
+		if _, ok := tracking.Untracked[repo.Name]; !ok {
+			applied = append(applied, repo)
+		}
+	}
+
+	names := make([]string, 0, len(tracking.Tracked))
+	for name := range tracking.Tracked {
+		if !listed[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		detail, err := fetchRepository(username + "/" + name)
+		if err != nil {
+			log.Warn().Err(err).Str("repository", name).Msg("Failed to fetch tracked repository")
+			continue
+		}
+		applied = append(applied, detail.Repository)
+	}
+	return applied
+}
+
+/*
+trackRequest は POST /api/admin/repos/track と DELETE /api/admin/repos/untrack のリクエストボディ
+*/
+type trackRequest struct {
+	Repo string `json:"repo" binding:"required"` // リポジトリ名（例: "giter"、"develop-suda/giter" の形式も可）
+}
+
+/* validateFields はリポジトリ名の形式と所有者を検証する */
+func (req *trackRequest) validateFields() []FieldError {
+	if req.Repo == "" {
+		return nil
+	}
+	if _, ok := req.name(); !ok {
+		return []FieldError{{Field: "repo", Rule: "repo", Message: "must be a repository name owned by " + username}}
+	}
+	return nil
+}
+
+/* name は所有者を除いたリポジトリ名を返す（形式が不正、または所有者がusername以外の場合はfalse） */
+func (req *trackRequest) name() (string, bool) {
+	name := req.Repo
+	if owner, repo, found := strings.Cut(name, "/"); found {
+		if !strings.EqualFold(owner, username) {
+			return "", false
+		}
+		name = repo
+	}
+	if !repoNamePattern.MatchString(name) || name == "." || name == ".." {
+		return "", false
+	}
+	return name, true
+}
+
+/*
+TrackResponse はリポジトリを追加した結果
+*/
+type TrackResponse struct {
+	Repository Repository `json:"repository"`           // 追加したリポジトリ
+	Synced     int        `json:"synced"`               // 追加の直後の同期で取得したコミット数
+	SyncError  string     `json:"sync_error,omitempty"` // 同期に失敗した場合のエラー（追加は保存済み、次の同期で取得する）
+}
+
+/*
+getRepoTracking は実行中に追加・除外したリポジトリを返すAPIハンドラー（管理者のみ）
+
+レスポンス:
+  200 OK, RepositoryTracking
+*/
+func getRepoTracking(c *gin.Context) {
+	respondJSON(c, http.StatusOK, repoTracking.snapshot())
+}
+
+/*
+postTrackRepository はリポジトリを同期の対象に追加するAPIハンドラー（管理者のみ）
+設定の変更と再起動なしに、GitHubのリポジトリ一覧にないリポジトリ（101件目以降など）を追加する
+追加の直後にそのリポジトリのみを同期し、検索・集計のビュー・ロングポーリングに反映する
+
+リクエストボディ:
+  {"repo": "giter"}
+
+レスポンス:
+  成功時: 201 Created, TrackResponse
+  失敗時: 400 Bad Request（JSON不正）, 404 Not Found（リポジトリが存在しない）, 422 Unprocessable Entity（リポジトリ名が不正）,
+          500 Internal Server Error（保存できない）, 502 Bad Gateway（GitHubから取得できない）
+
+注意:
+  - 除外していたリポジトリを追加した場合は、除外を取り消す
+*/
+func postTrackRepository(c *gin.Context) {
+	var req trackRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	name, _ := req.name()
+
+	detail, err := fetchRepository(username + "/" + name)
+	if err != nil {
+		if errors.Is(err, errRepositoryNotFound) {
+			respondError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Error().Err(err).Str("repository", name).Msg("Failed to fetch repository to track")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err := repoTracking.set(name, true); err != nil {
+		log.Error().Err(err).Msg("Failed to save tracked repositories")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := TrackResponse{Repository: detail.Repository}
+	resp.Synced, err = syncTrackedRepository(detail.Repository)
+	if err != nil {
+		log.Warn().Err(err).Str("repository", name).Msg("Failed to sync tracked repository")
+		resp.SyncError = err.Error()
+	}
+	auditLog.record(c, "repository.track", name, map[string]int{"commits": resp.Synced})
+	respondJSON(c, http.StatusCreated, resp)
+}
+
+/*
+deleteUntrackRepository はリポジトリを同期の対象から除外するAPIハンドラー（管理者のみ）
+GitHubのリポジトリ一覧に含まれるリポジトリも、以降の同期・集計・リポジトリ一覧から取り除く
+
+リクエストボディ:
+  {"repo": "giter"}
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 400 Bad Request（JSON不正）, 422 Unprocessable Entity（リポジトリ名が不正）, 500 Internal Server Error（保存できない）
+
+注意:
+  - アーカイブ・検索インデックスのコミットは削除しない（削除する場合は DELETE /api/admin/data を使用する）
+*/
+func deleteUntrackRepository(c *gin.Context) {
+	var req trackRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	name, _ := req.name()
+
+	if err := repoTracking.set(name, false); err != nil {
+		log.Error().Err(err).Msg("Failed to save tracked repositories")
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	auditLog.record(c, "repository.untrack", name, nil)
+	c.Status(http.StatusNoContent)
+}
+
+/*
+syncTrackedRepository は追加したリポジトリのコミットを取得し、検索インデックス・集計のビューに加える
+
+戻り値:
+  int - 取得したコミット数
+  error - コミットを取得できない場合のエラー
+*/
+func syncTrackedRepository(repo Repository) (int, error) {
+	result := fetchCommitsConcurrently([]Repository{repo}, jobPriorityInteractive)[0]
+	if result.Err != nil {
+		return 0, result.Err
+	}
+	commits := make([]CommitHistory, 0, len(result.Commits))
+	for _, commit := range result.Commits {
+		commits = append(commits, newCommitHistory(repo.Name, commit))
+	}
+
+	jobs.submit(jobPriorityInteractive, jobKindComputeStats, repo.FullName, func() error {
+		commitSearch.add(commits)
+		statsViews.merge(commits, []Repository{repo})
+		eventBroker.publishCommits(backgroundVisibility().commits(commits, []Repository{repo}))
+		if len(commits) > 0 {
+			commitUpdates.notify()
+		}
+		return nil
+	})
+	return len(commits), nil
+}
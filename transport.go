@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+)
+
+/*
+GitHub APIへの接続の再利用に関する設定
+コミットの取得はワーカーが並行して行うため、ワーカー数以上のアイドル接続を保持しておかないと
+リクエストのたびに接続（TCP + TLSハンドシェイク）をやり直すことになる
+*/
+var (
+	/* githubMaxIdleConnsPerHost はapi.github.comに対して保持するアイドル接続の上限（環境変数 GITHUB_MAX_IDLE_CONNS_PER_HOST） */
+	githubMaxIdleConnsPerHost = getEnvInt("GITHUB_MAX_IDLE_CONNS_PER_HOST", 16)
+	/* githubIdleConnTimeout はアイドル接続を閉じるまでの時間（環境変数 GITHUB_IDLE_CONN_TIMEOUT） */
+	githubIdleConnTimeout = parseDurationEnv("GITHUB_IDLE_CONN_TIMEOUT", 90*time.Second)
+	/* githubKeepAlive はTCPキープアライブの間隔（環境変数 GITHUB_KEEPALIVE） */
+	githubKeepAlive = parseDurationEnv("GITHUB_KEEPALIVE", 30*time.Second)
+)
+
+/*
+GitHub APIへの接続とリクエストのメトリクス
+接続の再利用率とリクエストのレイテンシを比較し、チューニングの効果を確認できるようにする
+*/
+var (
+	githubConnectionsTotal = newCounterVec(
+		"giter_github_connections_total",
+		"Connections obtained for GitHub API requests, by whether an existing connection was reused.",
+		"operation", "reused",
+	)
+	githubRequestsTotal = newCounterVec(
+		"giter_github_requests_total",
+		"GitHub API requests sent, by HTTP protocol and status code.",
+		"operation", "protocol", "code",
+	)
+	githubRequestDuration = newHistogramVec(
+		"giter_github_request_duration_seconds",
+		"Latency of GitHub API requests until response headers are received.",
+		defaultDurationBuckets,
+		"operation",
+	)
+	githubDialDuration = newHistogramVec(
+		"giter_github_dial_duration_seconds",
+		"Time spent establishing new connections (TCP connect and TLS handshake).",
+		defaultDurationBuckets,
+		"operation",
+	)
+)
+
+/*
+newUpstreamTransport はGitHub APIへの接続に使用するTransportを作成する
+  - HTTP/2を明示的に有効にする（DialContextを独自に指定すると自動では有効にならないため）
+  - MaxIdleConnsPerHostをワーカー数以上にし、並行リクエスト間で接続を再利用する
+  - 接続の取得・リクエストの完了をメトリクスに記録する
+  - CHAOS_ENABLED=true の場合は障害を注入する（chaos.go）
+
+引数:
+  op string - 操作の種類（メトリクスのラベルに使用）
+  cfg TimeoutConfig - 接続・TLS・レスポンスヘッダーのタイムアウト
+*/
+func newUpstreamTransport(op string, cfg TimeoutConfig) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: cfg.Connect, KeepAlive: githubKeepAlive}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+		TLSHandshakeTimeout:   cfg.TLSHandshake,
+		ResponseHeaderTimeout: cfg.ResponseHeader,
+		MaxIdleConns:          githubMaxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost:   githubMaxIdleConnsPerHost,
+		IdleConnTimeout:       githubIdleConnTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &instrumentedTransport{op: op, base: withChaos(op, transport)}
+}
+
+/*
+instrumentedTransport はリクエストごとに接続の再利用状況とレイテンシを記録するRoundTripper
+送信ログ（requestlog.go）と遅いリクエストの警告（slowlog.go）もここで出力する
+*/
+type instrumentedTransport struct {
+	op   string            // 操作の種類
+	base http.RoundTripper // 実際にリクエストを送信するTransport
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var dialStart time.Time
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			githubConnectionsTotal.Inc(t.op, strconv.FormatBool(info.Reused))
+		},
+		ConnectStart: func(network, addr string) {
+			dialStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if !dialStart.IsZero() {
+				githubDialDuration.ObserveSince(dialStart, t.op)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+	logOutbound(t.op, req, resp, err, duration)
+	checkSlowGitHubRequest(t.op, req, duration)
+	if err != nil {
+		githubRequestsTotal.Inc(t.op, "", "error")
+		return nil, err
+	}
+	githubRequestDuration.ObserveSince(start, t.op)
+	githubRequestsTotal.Inc(t.op, resp.Proto, strconv.Itoa(resp.StatusCode))
+	return resp, nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+/*
+リクエストの検証
+クエリパラメータとリクエストボディは、formタグ・jsonタグとbindingタグ（validatorのルール）を付けた構造体に読み込む
+不正な値はまとめて 422 Unprocessable Entity で返し、details に項目ごとの理由を含める
+*/
+
+/* FieldError は検証に失敗した1つの項目 */
+type FieldError struct {
+	Field   string `json:"field"`   // クエリパラメータ名・JSONのフィールド名
+	Rule    string `json:"rule"`    // 満たさなかったルール（例: "min", "oneof", "type"）
+	Message string `json:"message"` // 理由
+}
+
+/* validationFailedMessage は検証に失敗した場合のエラーメッセージ */
+const validationFailedMessage = "request validation failed"
+
+/*
+fieldValidator はbindingタグで表せない検証を行う構造体が実装するインターフェース
+bindQuery・bindJSONはbindingタグの検証結果とまとめて返す
+*/
+type fieldValidator interface {
+	/* validateFields は不正な項目を返す（問題がない場合は空） */
+	validateFields() []FieldError
+}
+
+func init() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	/* FieldErrorのFieldにはGoのフィールド名ではなく、クエリパラメータ名・JSONのフィールド名を使用する */
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"form", "json"} {
+			if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	engine.RegisterValidation("timezone", func(fl validator.FieldLevel) bool {
+		_, err := time.LoadLocation(fl.Field().String())
+		return err == nil
+	})
+	engine.RegisterValidation("isoweek", func(fl validator.FieldLevel) bool {
+		_, err := parseISOWeek(fl.Field().String(), statsLocation)
+		return err == nil
+	})
+	engine.RegisterValidation("activitykinds", func(fl validator.FieldLevel) bool {
+		_, err := parseActivityKinds(fl.Field().String())
+		return err == nil
+	})
+	engine.RegisterValidation("theme", func(fl validator.FieldLevel) bool {
+		return containsString(availableThemes(), fl.Field().String())
+	})
+}
+
+/*
+respondValidationError は 422 Unprocessable Entity と項目ごとの理由を返す
+メッセージはリクエストの言語に翻訳する（カタログにないメッセージはそのまま返す）
+*/
+func respondValidationError(c *gin.Context, fields []FieldError) {
+	resp := newErrorResponse(c, http.StatusUnprocessableEntity, validationFailedMessage)
+	for i := range fields {
+		fields[i].Message = localize(c, fields[i].Message, nil)
+	}
+	resp.Details = fields
+	respondJSON(c, http.StatusUnprocessableEntity, resp)
+}
+
+/*
+bindQuery はクエリパラメータをobjに読み込んで検証する
+objのフィールドはformタグのパラメータ名で読み込み、指定されなかったフィールドは元の値（デフォルト値）のまま残す
+対応する型: string, bool, int, およびそれらのポインタ（指定されなかった場合はnil）
+
+戻り値:
+  bool - 検証に成功した場合はtrue（失敗した場合は422を返し済み）
+*/
+func bindQuery(c *gin.Context, obj interface{}) bool {
+	fields := mapQuery(c, obj)
+	fields = append(fields, validateStruct(obj, fields)...)
+	fields = append(fields, customFieldErrors(obj)...)
+	if len(fields) > 0 {
+		respondValidationError(c, fields)
+		return false
+	}
+	return true
+}
+
+/*
+bindJSON はリクエストボディのJSONをobjに読み込んで検証する
+
+戻り値:
+  bool - 読み込みと検証に成功した場合はtrue
+         JSONとして読み込めない場合は 400 Bad Request、型の不一致と検証の失敗は 422 を返し済み
+*/
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindWith(obj, binding.JSON)
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil || errors.As(err, &validationErrs):
+		fields := append(fieldErrors(validationErrs), customFieldErrors(obj)...)
+		if len(fields) == 0 {
+			return true
+		}
+		respondValidationError(c, fields)
+	case errors.As(err, &typeErr):
+		respondValidationError(c, []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("must be %s", jsonTypeName(typeErr.Type)),
+		}})
+	default:
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %s", err.Error()))
+	}
+	return false
+}
+
+/* mapQuery はクエリパラメータをobjのフィールドに設定し、型の不一致を返す */
+func mapQuery(c *gin.Context, obj interface{}) []FieldError {
+	var fields []FieldError
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		raw, ok := c.GetQuery(name)
+		if !ok {
+			continue
+		}
+		if fe := setQueryField(v.Field(i), name, raw); fe != nil {
+			fields = append(fields, *fe)
+		}
+	}
+	return fields
+}
+
+/* setQueryField はクエリパラメータの値をフィールドの型に変換して設定する */
+func setQueryField(field reflect.Value, name, raw string) *FieldError {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if fe := setQueryField(elem.Elem(), name, raw); fe != nil {
+			return fe
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return &FieldError{Field: name, Rule: "type", Message: "must be a boolean (true or false)"}
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return &FieldError{Field: name, Rule: "type", Message: "must be an integer"}
+		}
+		field.SetInt(int64(n))
+	default:
+		panic(fmt.Sprintf("bindQuery: unsupported field type %s", field.Type()))
+	}
+	return nil
+}
+
+/* validateStruct はbindingタグのルールでobjを検証する（型の不一致があった項目は除く） */
+func validateStruct(obj interface{}, typeErrors []FieldError) []FieldError {
+	err := binding.Validator.ValidateStruct(obj)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+
+	skip := map[string]bool{}
+	for _, fe := range typeErrors {
+		skip[fe.Field] = true
+	}
+	var fields []FieldError
+	for _, fe := range fieldErrors(validationErrs) {
+		if !skip[fe.Field] {
+			fields = append(fields, fe)
+		}
+	}
+	return fields
+}
+
+/* customFieldErrors はobjがfieldValidatorを実装している場合に検証する */
+func customFieldErrors(obj interface{}) []FieldError {
+	if v, ok := obj.(fieldValidator); ok {
+		return v.validateFields()
+	}
+	return nil
+}
+
+/* fieldErrors はvalidatorのエラーを項目ごとのFieldErrorに変換する */
+func fieldErrors(errs validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, FieldError{Field: e.Field(), Rule: e.Tag(), Message: ruleMessage(e)})
+	}
+	return fields
+}
+
+/* ruleMessage はvalidatorのルールごとの理由を作成する */
+func ruleMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		switch e.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be at least %s characters long", e.Param())
+		case reflect.Slice, reflect.Map:
+			return fmt.Sprintf("must contain at least %s items", e.Param())
+		}
+		return fmt.Sprintf("must be at least %s", e.Param())
+	case "max":
+		switch e.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be at most %s characters long", e.Param())
+		case reflect.Slice, reflect.Map:
+			return fmt.Sprintf("must contain at most %s items", e.Param())
+		}
+		return fmt.Sprintf("must be at most %s", e.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(e.Param(), " ", ", "))
+	case "url":
+		return "must be a URL"
+	case "startswith":
+		return fmt.Sprintf("must start with %s", e.Param())
+	case "timezone":
+		return fmt.Sprintf("unknown timezone: %v", e.Value())
+	case "isoweek":
+		return "must be an ISO week (e.g. 2025-W30)"
+	case "activitykinds":
+		return fmt.Sprintf("must be a comma-separated list of: %s", strings.Join(activityKinds, ", "))
+	case "theme":
+		return fmt.Sprintf("must be one of: %s", strings.Join(availableThemes(), ", "))
+	}
+	return fmt.Sprintf("failed on the %s rule", e.Tag())
+}
+
+/* jsonTypeName はJSONの型の不一致を説明する型名を返す */
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return "an RFC 3339 timestamp"
+		}
+		return "an object"
+	}
+	return t.String()
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+FuncMap はHTMLテンプレートで使用するカスタム関数を返す
+Ginのレンダラーに r.SetFuncMap(web.FuncMap()) で登録する（LoadHTMLGlobより前に呼び出すこと）
+
+関数:
+  timeAgo 日時 言語  - 相対時間（例: "3時間前", "3 hours ago"）
+  number 整数        - 3桁区切り（例: 1234567 -> "1,234,567"）
+  shortSHA ハッシュ   - コミットハッシュを7文字に短縮
+  truncate 文字列 長さ - 指定した文字数を超える部分を "…" に置き換える
+*/
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"timeAgo":  TimeAgo,
+		"number":   FormatNumber,
+		"shortSHA": ShortSHA,
+		"truncate": Truncate,
+	}
+}
+
+/*
+TimeAgo は日時を現在からの相対時間で表す
+
+引数:
+  t time.Time - 対象の日時（ゼロ値の場合は空文字を返す）
+  lang string - 表示言語（"en" 以外は日本語）
+*/
+func TimeAgo(t time.Time, lang string) string {
+	return timeAgoFrom(t, time.Now(), lang)
+}
+
+/* relativeUnit は相対時間の単位 */
+type relativeUnit struct {
+	size time.Duration
+	ja   string
+	en   string
+}
+
+/* relativeUnits は大きい順の相対時間の単位（月・年は30日・365日として扱う） */
+var relativeUnits = []relativeUnit{
+	{365 * 24 * time.Hour, "年", "year"},
+	{30 * 24 * time.Hour, "か月", "month"},
+	{24 * time.Hour, "日", "day"},
+	{time.Hour, "時間", "hour"},
+	{time.Minute, "分", "minute"},
+}
+
+func timeAgoFrom(t, now time.Time, lang string) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	for _, unit := range relativeUnits {
+		if d < unit.size {
+			continue
+		}
+		n := int(d / unit.size)
+		if lang == "en" {
+			label := unit.en
+			if n != 1 {
+				label += "s"
+			}
+			if future {
+				return fmt.Sprintf("in %d %s", n, label)
+			}
+			return fmt.Sprintf("%d %s ago", n, label)
+		}
+		if future {
+			return fmt.Sprintf("%d%s後", n, unit.ja)
+		}
+		return fmt.Sprintf("%d%s前", n, unit.ja)
+	}
+
+	if lang == "en" {
+		return "just now"
+	}
+	return "たった今"
+}
+
+/*
+FormatNumber は整数を3桁区切りの文字列にする
+例: 1234567 -> "1,234,567", -1000 -> "-1,000"
+*/
+func FormatNumber(n int) string {
+	s := strconv.Itoa(n)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String()
+}
+
+/* ShortSHA はコミットハッシュをGitの慣習に合わせて7文字に短縮する（7文字以下はそのまま） */
+func ShortSHA(sha string) string {
+	if len(sha) <= 7 {
+		return sha
+	}
+	return sha[:7]
+}
+
+/*
+Truncate は文字列が指定した文字数を超える場合に切り詰め、末尾に "…" を付ける
+文字数はバイト数ではなく文字（rune）単位で数えるため、日本語の途中で切れることはない
+
+引数:
+  s string - 対象の文字列
+  max int - 最大文字数（"…" を含む）
+*/
+func Truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
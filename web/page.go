@@ -0,0 +1,470 @@
+/*
+Package web はサーバーでレンダリングするHTMLページのビューモデルを定義する
+ハンドラーはリクエストごとにビューモデルを組み立ててテンプレートに渡し、
+テンプレートはビューモデルのフィールドだけを参照する（gin.Hやnilを渡さない）
+*/
+package web
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+Site はすべてのページで共通の、サーバー全体の情報
+起動時に1度だけ作成する
+*/
+type Site struct {
+	Title      string // サイト名（例: "Giter"）
+	Username   string // 対象のGitHubユーザー名
+	Version    string // ビルドのバージョン（例: "v1.2.0", 未指定の場合は "dev"）
+	ThemeColor string // ブラウザのUI・PWAに使用するテーマカラー（THEME_COLOR、#RRGGBB）
+	BasePath   string // URLパスの接頭辞（BASE_PATH、例: "/giter"。ルートで公開する場合は空文字）
+}
+
+/*
+Request はページを表示するリクエストごとの情報
+*/
+type Request struct {
+	Lang      string                 // 表示言語（"ja" または "en"）
+	Theme     Theme                  // 閲覧者に適用するテーマ
+	Themes    []string               // 閲覧者が選択可能なテーマ
+	CSPNonce  string                 // インラインスクリプトに付けるContent-Security-Policyのnonce
+	CSRFToken string                 // 状態を変更するリクエストのX-CSRF-Tokenヘッダーに付けるトークン（セッションごと）
+	T         func(id string) string // メッセージIDを表示言語に翻訳する関数
+	Messages  map[string]string      // JavaScriptで使用する翻訳
+}
+
+/*
+Theme はページに適用するテーマ
+テンプレートでは <html class="{{.Theme.Class}}"> と <link rel="stylesheet" href="{{.Theme.Stylesheet}}"> に使用する
+*/
+type Theme struct {
+	Name       string // テーマ名（"light", "dark", "custom"）
+	Class      string // <html>に付けるクラス（例: "dark"）
+	Stylesheet string // テーマのCSSのURL
+}
+
+/*
+Page はすべてのページのビューモデルに埋め込む共通部分
+テンプレートでは {{.SiteTitle}} や {{call .T "メッセージID"}} のように参照する
+*/
+type Page struct {
+	SiteTitle  string                 // サイト名
+	PageTitle  string                 // ページのタイトル（<title>に使用、翻訳済み）
+	Username   string                 // 対象のGitHubユーザー名
+	Version    string                 // ビルドのバージョン
+	ThemeColor string                 // テーマカラー（#RRGGBB）
+	BasePath   string                 // URLパスの接頭辞（リンク・API呼び出しは {{.BasePath}}/api/... のように組み立てる）
+	Lang       string                 // 表示言語
+	Theme      Theme                  // 閲覧者に適用するテーマ
+	Themes     []string               // 閲覧者が選択可能なテーマ（テーマ切り替えの選択肢）
+	CSPNonce   string                 // インラインスクリプトのnonce
+	CSRFToken  string                 // CSRFトークン（<meta name="csrf-token"> に出力する）
+	T          func(id string) string // 翻訳関数
+	Messages   map[string]string      // JavaScriptで使用する翻訳
+}
+
+/*
+NewPage はページの共通部分を作成する
+
+引数:
+  site Site - サーバー全体の情報
+  req Request - リクエストごとの情報
+  titleID string - ページタイトルのメッセージID
+*/
+func NewPage(site Site, req Request, titleID string) Page {
+	t := req.T
+	if t == nil {
+		t = func(id string) string { return id }
+	}
+	return Page{
+		SiteTitle:  site.Title,
+		PageTitle:  t(titleID),
+		Username:   site.Username,
+		Version:    site.Version,
+		ThemeColor: site.ThemeColor,
+		BasePath:   site.BasePath,
+		Lang:       req.Lang,
+		Theme:      req.Theme,
+		Themes:     req.Themes,
+		CSPNonce:   req.CSPNonce,
+		CSRFToken:  req.CSRFToken,
+		T:          t,
+		Messages:   req.Messages,
+	}
+}
+
+/*
+SummaryStats は直近のコミット履歴の取得（/api/git-history）の集計
+サーバー起動後にまだ一度も取得していない場合はゼロ値
+*/
+type SummaryStats struct {
+	Repositories       int       // 取得対象のリポジトリ数
+	Commits            int       // 取得したコミット数
+	FailedRepositories int       // コミットの取得に失敗したリポジトリ数
+	LatestCommitAt     time.Time // 最も新しいコミットの日時
+	SyncedAt           time.Time // 取得した日時
+}
+
+/* HasData は一度でもコミット履歴を取得したかどうかを返す */
+func (s SummaryStats) HasData() bool {
+	return !s.SyncedAt.IsZero()
+}
+
+/*
+IndexPage はトップページ（index.html）のビューモデル
+*/
+type IndexPage struct {
+	Page
+	Stats SummaryStats // 直近の取得の集計（ヘッダーに表示）
+}
+
+/* NewIndexPage はトップページのビューモデルを作成する */
+func NewIndexPage(site Site, req Request, stats SummaryStats) IndexPage {
+	return IndexPage{
+		Page:  NewPage(site, req, "page.title"),
+		Stats: stats,
+	}
+}
+
+/*
+RepoSummary はリポジトリ詳細ページに表示するリポジトリの情報
+*/
+type RepoSummary struct {
+	Name          string    // リポジトリ名
+	FullName      string    // フルネーム（例: "develop-suda/giter"）
+	Description   string    // 説明文
+	HTMLURL       string    // GitHubのリポジトリURL
+	Language      string    // 主な言語
+	DefaultBranch string    // デフォルトブランチ名
+	Stars         int       // スター数
+	Forks         int       // フォーク数
+	OpenIssues    int       // オープン中のIssue数
+	PushedAt      time.Time // 最後にプッシュされた日時
+}
+
+/*
+RepoStats はリポジトリ詳細ページに表示する統計
+*/
+type RepoStats struct {
+	Commits           int       // 集計対象のコミット数
+	CommitsLast30Days int       // 直近30日間のコミット数
+	Authors           int       // コミット作成者の人数
+	Branches          int       // ブランチ数
+	FirstCommitAt     time.Time // 集計対象で最も古いコミットの日時
+	LatestCommitAt    time.Time // 最も新しいコミットの日時
+}
+
+/* CommitItem はページに表示する1件のコミット */
+type CommitItem struct {
+	SHA     string    // コミットハッシュ（テンプレートでshortSHAを使用して短縮する）
+	Message string    // コミットメッセージ
+	Author  string    // 作成者名
+	Time    time.Time // コミット作成日時
+	URL     string    // GitHubのコミットページURL
+}
+
+/* BranchItem はページに表示する1件のブランチ */
+type BranchItem struct {
+	Name      string // ブランチ名
+	SHA       string // 先頭のコミットハッシュ
+	Protected bool   // 保護されたブランチかどうか
+	Default   bool   // デフォルトブランチかどうか
+}
+
+/*
+RepoPage はリポジトリ詳細ページ（repo.html）のビューモデル
+取得に失敗した場合はErrorにメッセージが入り、その他のフィールドはゼロ値
+*/
+type RepoPage struct {
+	Page
+	Repo     RepoSummary  // リポジトリの情報
+	Stats    RepoStats    // 統計
+	Commits  []CommitItem // 最近のコミット（新しい順）
+	Branches []BranchItem // ブランチ
+	Error    string       // エラーメッセージ（翻訳済み）
+}
+
+/* NewRepoPage はリポジトリ詳細ページのビューモデルを作成する */
+func NewRepoPage(site Site, req Request, repo RepoSummary, stats RepoStats, commits []CommitItem, branches []BranchItem) RepoPage {
+	page := NewPage(site, req, "repo.page_title")
+	page.PageTitle = repo.Name + " - " + page.PageTitle
+	return RepoPage{
+		Page:     page,
+		Repo:     repo,
+		Stats:    stats,
+		Commits:  commits,
+		Branches: branches,
+	}
+}
+
+/*
+NewRepoErrorPage はリポジトリを表示できない場合のリポジトリ詳細ページのビューモデルを作成する
+
+引数:
+  name string - URLで指定されたリポジトリ名
+  message string - 表示するエラーメッセージ（翻訳済み）
+*/
+func NewRepoErrorPage(site Site, req Request, name, message string) RepoPage {
+	page := NewPage(site, req, "repo.page_title")
+	page.PageTitle = name + " - " + page.PageTitle
+	return RepoPage{
+		Page:  page,
+		Repo:  RepoSummary{Name: name},
+		Error: message,
+	}
+}
+
+/*
+ErrorPage はエラーページ（error.html）のビューモデル
+存在しないページ（404）や許可されていないメソッド（405）で表示する
+*/
+type ErrorPage struct {
+	Page
+	Status    int    // HTTPステータスコード
+	Message   string // エラーメッセージ（翻訳済み）
+	RequestID string // リクエストID（問い合わせ時にログと突き合わせるため表示する）
+}
+
+/* NewErrorPage はエラーページのビューモデルを作成する */
+func NewErrorPage(site Site, req Request, status int, message, requestID string) ErrorPage {
+	return ErrorPage{
+		Page:      NewPage(site, req, "errorpage.title"),
+		Status:    status,
+		Message:   message,
+		RequestID: requestID,
+	}
+}
+
+/*
+MaintenancePage はメンテナンス中のページ（maintenance.html）のビューモデル
+管理者がメンテナンスモードを有効にしている間、すべてのページの代わりに503で表示する
+*/
+type MaintenancePage struct {
+	Page
+	Message string    // 管理者が設定したメッセージ（空文字の場合は既定の文言を表示する）
+	Since   time.Time // メンテナンスを開始した日時
+	Until   time.Time // 終了予定の日時（ゼロ値の場合は未定）
+}
+
+/* NewMaintenancePage はメンテナンス中のページのビューモデルを作成する */
+func NewMaintenancePage(site Site, req Request, message string, since, until time.Time) MaintenancePage {
+	return MaintenancePage{
+		Page:    NewPage(site, req, "maintenance.title"),
+		Message: message,
+		Since:   since,
+		Until:   until,
+	}
+}
+
+/*
+DigestSummary は週次ダイジェストページ（digest.html）に表示する週の概要
+*/
+type DigestSummary struct {
+	Week       string    // 週（"2025-W30"形式）
+	PrevWeek   string    // 前の週
+	NextWeek   string    // 次の週（未来の週の場合は空文字）
+	Start      time.Time // 週の初日（月曜日）
+	End        time.Time // 週の最終日（日曜日）
+	Commits    int       // コミット数
+	ActiveDays int       // コミットのあった日数
+}
+
+/* DigestDay は週次ダイジェストページの日ごとのコミット数 */
+type DigestDay struct {
+	Date    time.Time // 日付
+	Commits int       // コミット数
+}
+
+/* DigestRepo は週次ダイジェストページのコミットの多いリポジトリ */
+type DigestRepo struct {
+	Name    string // リポジトリ名
+	Commits int    // コミット数
+}
+
+/* DigestPull は週次ダイジェストページのマージされたプルリクエスト */
+type DigestPull struct {
+	Repo     string    // リポジトリ名
+	Number   int       // PR番号
+	Title    string    // タイトル
+	URL      string    // GitHubのPRページURL
+	MergedAt time.Time // マージ日時
+}
+
+/* DigestStar は週次ダイジェストページの新しいスター */
+type DigestStar struct {
+	Repo      string    // リポジトリ名
+	User      string    // スターしたユーザー
+	StarredAt time.Time // スターされた日時
+}
+
+/*
+DigestPage は週次ダイジェストページ（digest.html）のビューモデル
+取得に失敗した場合はErrorにメッセージが入り、その他のフィールドはゼロ値
+*/
+type DigestPage struct {
+	Page
+	Summary DigestSummary // 週の概要
+	Days    []DigestDay   // 日ごとのコミット数（月曜日から7日分）
+	Repos   []DigestRepo  // コミットの多いリポジトリ
+	Pulls   []DigestPull  // マージされたプルリクエスト
+	Stars   []DigestStar  // 新しいスター
+	Error   string        // エラーメッセージ（翻訳済み）
+}
+
+/* NewDigestPage は週次ダイジェストページのビューモデルを作成する */
+func NewDigestPage(site Site, req Request, summary DigestSummary, days []DigestDay, repos []DigestRepo, pulls []DigestPull, stars []DigestStar) DigestPage {
+	page := NewPage(site, req, "digest.page_title")
+	page.PageTitle = summary.Week + " - " + page.PageTitle
+	return DigestPage{
+		Page:    page,
+		Summary: summary,
+		Days:    days,
+		Repos:   repos,
+		Pulls:   pulls,
+		Stars:   stars,
+	}
+}
+
+/* NewDigestErrorPage はダイジェストを作成できない場合の週次ダイジェストページのビューモデルを作成する */
+func NewDigestErrorPage(site Site, req Request, message string) DigestPage {
+	return DigestPage{
+		Page:  NewPage(site, req, "digest.page_title"),
+		Error: message,
+	}
+}
+
+/*
+WrappedSummary は年間のまとめページ（wrapped.html）に表示する概要
+*/
+type WrappedSummary struct {
+	Year              int       // 対象の年
+	PrevYear          int       // 前の年（前年のまとめへのリンクに使用）
+	TotalCommits      int       // 年間のコミット数
+	ActiveDays        int       // コミットのあった日数
+	BusiestDay        time.Time // 最もコミットの多かった日（コミットがない場合はゼロ値）
+	BusiestDayCommits int       // その日のコミット数
+	LongestStreak     int       // 最も長くコミットが続いた日数
+	Truncated         bool      // 取得の上限に達し、一部のコミットが含まれていない
+}
+
+/* WrappedBar は年間のまとめページの棒グラフの1本（月・リポジトリ・言語） */
+type WrappedBar struct {
+	Label   string // ラベル
+	Count   int    // コミット数
+	Percent int    // 棒の長さ（0〜100）
+}
+
+/*
+WrappedPage は年間のまとめページ（wrapped.html）のビューモデル
+取得に失敗した場合はErrorにメッセージが入り、その他のフィールドはゼロ値
+*/
+type WrappedPage struct {
+	Page
+	Summary     WrappedSummary // 概要
+	Months      []WrappedBar   // 月ごとのコミット数（1月から12月）
+	Repos       []WrappedBar   // コミットの多いリポジトリ
+	Languages   []WrappedBar   // 言語ごとのコミット数
+	FirstCommit *CommitItem    // 年の最初のコミット（コミットがない場合はnil）
+	LastCommit  *CommitItem    // 年の最後のコミット
+	Error       string         // エラーメッセージ（翻訳済み）
+}
+
+/* NewWrappedPage は年間のまとめページのビューモデルを作成する */
+func NewWrappedPage(site Site, req Request, summary WrappedSummary, months, repos, languages []WrappedBar, first, last *CommitItem) WrappedPage {
+	page := NewPage(site, req, "wrapped.page_title")
+	page.PageTitle = fmt.Sprintf("%d - %s", summary.Year, page.PageTitle)
+	return WrappedPage{
+		Page:        page,
+		Summary:     summary,
+		Months:      months,
+		Repos:       repos,
+		Languages:   languages,
+		FirstCommit: first,
+		LastCommit:  last,
+	}
+}
+
+/* NewWrappedErrorPage はまとめを作成できない場合の年間のまとめページのビューモデルを作成する */
+func NewWrappedErrorPage(site Site, req Request, year, message string) WrappedPage {
+	page := NewPage(site, req, "wrapped.page_title")
+	page.PageTitle = year + " - " + page.PageTitle
+	return WrappedPage{
+		Page:  page,
+		Error: message,
+	}
+}
+
+/*
+ShareSummary は共有ページ（share.html）に表示する共有の範囲
+*/
+type ShareSummary struct {
+	Repo      string    // 対象のリポジトリ名（空文字の場合はすべてのリポジトリ）
+	From      time.Time // 期間の開始日時（指定がない場合はゼロ値）
+	To        time.Time // 期間の終了日時（指定がない場合はゼロ値）
+	ExpiresAt time.Time // 共有URLの有効期限
+}
+
+/*
+SharePage は共有ページ（share.html）のビューモデル
+共有URLが不正・期限切れの場合や取得に失敗した場合はErrorにメッセージが入り、その他のフィールドはゼロ値
+*/
+type SharePage struct {
+	Page
+	Summary ShareSummary // 共有の範囲
+	Commits []CommitItem // 範囲内のコミット（新しい順、Messageは "リポジトリ名: 件名"）
+	Error   string       // エラーメッセージ（翻訳済み）
+}
+
+/* NewSharePage は共有ページのビューモデルを作成する */
+func NewSharePage(site Site, req Request, summary ShareSummary, commits []CommitItem) SharePage {
+	page := NewPage(site, req, "share.page_title")
+	if summary.Repo != "" {
+		page.PageTitle = summary.Repo + " - " + page.PageTitle
+	}
+	return SharePage{
+		Page:    page,
+		Summary: summary,
+		Commits: commits,
+	}
+}
+
+/* NewShareErrorPage は共有URLを表示できない場合の共有ページのビューモデルを作成する */
+func NewShareErrorPage(site Site, req Request, message string) SharePage {
+	return SharePage{
+		Page:  NewPage(site, req, "share.page_title"),
+		Error: message,
+	}
+}
+
+/*
+EmbedPage は埋め込み用のタイムライン（embed.html）のビューモデル
+他のサイトのiframeに表示するため、<head>の共通部分（layout.html）は使わずに最小限のHTMLにする
+*/
+type EmbedPage struct {
+	Page
+	Repo         string       // 対象のリポジトリ名（空文字の場合はすべてのリポジトリ）
+	Commits      []CommitItem // 最近のコミット（新しい順）
+	DashboardURL string       // ダッシュボードのURL（ウィジェットのフッターからリンクする）
+	Error        string       // エラーメッセージ（翻訳済み）
+}
+
+/* NewEmbedPage は埋め込み用のタイムラインのビューモデルを作成する */
+func NewEmbedPage(site Site, req Request, repo string, commits []CommitItem, dashboardURL string) EmbedPage {
+	return EmbedPage{
+		Page:         NewPage(site, req, "embed.page_title"),
+		Repo:         repo,
+		Commits:      commits,
+		DashboardURL: dashboardURL,
+	}
+}
+
+/* NewEmbedErrorPage はタイムラインを表示できない場合の埋め込み用のビューモデルを作成する */
+func NewEmbedErrorPage(site Site, req Request, message, dashboardURL string) EmbedPage {
+	return EmbedPage{
+		Page:         NewPage(site, req, "embed.page_title"),
+		DashboardURL: dashboardURL,
+		Error:        message,
+	}
+}
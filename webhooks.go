@@ -0,0 +1,671 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+送信Webhook
+通知（notify）と同じイベントを、管理者が登録したHTTPの送信先にPOSTする
+閲覧者の通知設定のSlack/Discordチャネルも同じ仕組み（テンプレートと再試行のキュー）で送信する
+
+送信に失敗した場合は待機時間を倍にしながらWEBHOOK_MAX_ATTEMPTS回まで再試行し、
+再試行を使い切った送信（と、再試行しても成功しない4xxの応答）はデッドレターとしてログに出力して記録する
+*/
+
+const (
+	/* webhooksTable は送信先とデッドレターを保存するテーブル名 */
+	webhooksTable = "webhooks"
+	/* maxWebhookDeadLetters は保持するデッドレターの最大件数（古いものから削除） */
+	maxWebhookDeadLetters = 100
+	/* webhookEventTest は POST /api/admin/webhooks/:id/test で送信するイベントの種類 */
+	webhookEventTest = "test"
+)
+
+/* 送信する本文の形式 */
+const (
+	webhookFormatJSON     = "json"     // WebhookEventをそのままJSONにする
+	webhookFormatSlack    = "slack"    // SlackのIncoming Webhookの形式（{"text": ...}）
+	webhookFormatDiscord  = "discord"  // DiscordのWebhookの形式（{"content": ...}）
+	webhookFormatTemplate = "template" // Goのテンプレート（text/template）で作成する
+)
+
+/* webhookFormatTemplates はSlack・Discordの形式の本文のテンプレート */
+var webhookFormatTemplates = map[string]string{
+	webhookFormatSlack:   `{"text": {{json (printf "%s\n%s" .Title .Message)}}}`,
+	webhookFormatDiscord: `{"content": {{json (printf "%s\n%s" .Title .Message)}}}`,
+}
+
+var (
+	/* webhookMaxAttempts は1つの送信の最大試行回数（環境変数 WEBHOOK_MAX_ATTEMPTS、デフォルト: 5） */
+	webhookMaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5)
+	/*
+		webhookRetryBackoff は最初の再試行までの待機時間（環境変数 WEBHOOK_RETRY_BACKOFF、デフォルト: 30秒）
+		再試行のたびに倍になる（30秒 → 1分 → 2分 ...）
+	*/
+	webhookRetryBackoff = parseDurationEnv("WEBHOOK_RETRY_BACKOFF", 30*time.Second)
+	/* webhookTimeout は1回の送信のタイムアウト（環境変数 WEBHOOK_TIMEOUT、デフォルト: 10秒） */
+	webhookTimeout = parseDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second)
+	/*
+		webhookDedupWindow は同じ送信先に同じ内容のイベントを再び送信しない期間（環境変数 WEBHOOK_DEDUP_WINDOW、デフォルト: 1時間）
+		ページを読み込むたびに同じ取得の失敗を送信するのを防ぐ。0の場合は毎回送信する
+	*/
+	webhookDedupWindow = parseDurationEnv("WEBHOOK_DEDUP_WINDOW", time.Hour)
+)
+
+var webhookDeliveriesTotal = newCounterVec(
+	"giter_webhook_deliveries_total",
+	"Webhook delivery attempts by event and result (succeeded, retried, failed).",
+	"event", "result",
+)
+
+/*
+WebhookEvent は送信するイベント
+formatがjsonの送信先にはこの構造体をそのまま送信し、templateの送信先ではテンプレートの値（.Title など）として使用する
+*/
+type WebhookEvent struct {
+	ID        string    `json:"id"`             // イベントID（X-Giter-Deliveryヘッダーにも使用する）
+	Kind      string    `json:"event"`          // イベントの種類（notificationKind* 定数、または "test"）
+	Title     string    `json:"title"`          // タイトル
+	Message   string    `json:"message"`        // 本文
+	Repo      string    `json:"repo,omitempty"` // 関連するリポジトリ名（ない場合は省略）
+	CreatedAt time.Time `json:"created_at"`     // 作成日時
+}
+
+/* WebhookTarget は管理者が登録した送信先 */
+type WebhookTarget struct {
+	ID          string    `json:"id"`               // 送信先ID
+	Name        string    `json:"name"`             // 表示名
+	URL         string    `json:"url"`              // 送信先URL（https）
+	Events      []string  `json:"events"`           // 送信するイベントの種類（空の場合はすべて）
+	Repos       []string  `json:"repos"`            // 送信するリポジトリ名（空の場合はすべて、リポジトリのないイベントは常に送信する）
+	Format      string    `json:"format"`           // 本文の形式（json, slack, discord, template）
+	Template    string    `json:"template"`         // formatがtemplateの場合の本文のテンプレート
+	ContentType string    `json:"content_type"`     // Content-Typeヘッダー
+	Secret      string    `json:"secret,omitempty"` // 署名の鍵（APIのレスポンスには含めない）
+	HasSecret   bool      `json:"has_secret"`       // 署名の鍵を設定している場合はtrue
+	Enabled     bool      `json:"enabled"`          // 送信するかどうか
+	CreatedAt   time.Time `json:"created_at"`       // 作成日時
+	UpdatedAt   time.Time `json:"updated_at"`       // 更新日時
+}
+
+/* matches はイベントが送信先のフィルターに一致するかを返す */
+func (t WebhookTarget) matches(event WebhookEvent) bool {
+	if !t.Enabled {
+		return false
+	}
+	if len(t.Events) > 0 && !containsString(t.Events, event.Kind) {
+		return false
+	}
+	return event.Repo == "" || len(t.Repos) == 0 || containsString(t.Repos, event.Repo)
+}
+
+/* public は署名の鍵を除いた送信先を返す */
+func (t WebhookTarget) public() WebhookTarget {
+	t.HasSecret, t.Secret = t.Secret != "", ""
+	return t
+}
+
+/* WebhookDeadLetter は再試行を使い切った送信の記録 */
+type WebhookDeadLetter struct {
+	DeliveryID string    `json:"delivery_id"` // 送信ID
+	Target     string    `json:"target"`      // 送信先ID（閲覧者の通知設定の場合は "slack" / "discord"）
+	Event      string    `json:"event"`       // イベントの種類
+	EventID    string    `json:"event_id"`    // イベントID
+	Attempts   int       `json:"attempts"`    // 試行回数
+	Error      string    `json:"error"`       // 最後のエラー
+	FailedAt   time.Time `json:"failed_at"`   // 失敗した日時
+}
+
+/* WebhookPendingDelivery は再試行を待っている送信 */
+type WebhookPendingDelivery struct {
+	DeliveryID  string    `json:"delivery_id"`  // 送信ID
+	Target      string    `json:"target"`       // 送信先ID
+	Event       string    `json:"event"`        // イベントの種類
+	Attempts    int       `json:"attempts"`     // 試行回数
+	LastError   string    `json:"last_error"`   // 最後のエラー
+	NextAttempt time.Time `json:"next_attempt"` // 次に送信する日時
+}
+
+/* WebhookDeliveries は /api/admin/webhooks/deliveries のレスポンス */
+type WebhookDeliveries struct {
+	Pending     []WebhookPendingDelivery `json:"pending"`      // 再試行を待っている送信（次に送信する順）
+	DeadLetters []WebhookDeadLetter      `json:"dead_letters"` // デッドレター（新しい順）
+}
+
+/* webhookDelivery は1つの送信先への1つのイベントの送信 */
+type webhookDelivery struct {
+	id          string
+	target      string
+	url         string
+	contentType string
+	secret      string
+	event       WebhookEvent
+	body        []byte
+	attempts    int
+	lastError   string
+	nextAttempt time.Time
+}
+
+/*
+webhookStore は送信先・デッドレターと、再試行を待っている送信を保持するストア
+送信先とデッドレターはwebhooksテーブルに永続化される（再試行を待っている送信は再起動で失われる）
+*/
+type webhookStore struct {
+	mu sync.Mutex
+	/* Targets は登録した送信先（作成順） */
+	Targets []WebhookTarget `json:"targets"`
+	/* DeadLetters は再試行を使い切った送信（新しい順、最大maxWebhookDeadLetters件） */
+	DeadLetters []WebhookDeadLetter `json:"dead_letters"`
+
+	pending map[string]*webhookDelivery
+	sent    map[string]time.Time // 送信先とイベントの内容ごとの最後に送信した日時（WEBHOOK_DEDUP_WINDOW）
+}
+
+/* webhooks はアプリケーション全体で共有する送信Webhookのストア */
+var webhooks = &webhookStore{pending: map[string]*webhookDelivery{}, sent: map[string]time.Time{}}
+
+func init() {
+	registerTable(webhooksTable, loadWebhooks)
+}
+
+/*
+loadWebhooks はwebhooksテーブルから送信先とデッドレターを復元する
+起動時とデータのインポート後にloadTablesから呼び出される
+*/
+func loadWebhooks() error {
+	webhooks.mu.Lock()
+	defer webhooks.mu.Unlock()
+
+	webhooks.Targets, webhooks.DeadLetters = nil, nil
+	if err := loadTable(webhooksTable, webhooks); err != nil {
+		return err
+	}
+	if webhooks.Targets == nil {
+		webhooks.Targets = []WebhookTarget{}
+	}
+	if webhooks.DeadLetters == nil {
+		webhooks.DeadLetters = []WebhookDeadLetter{}
+	}
+	return nil
+}
+
+/* save は送信先とデッドレターをテーブルに保存する（呼び出し元でmuをロックしていること） */
+func (s *webhookStore) save() {
+	if err := saveTable(webhooksTable, s); err != nil {
+		log.Error().Err(err).Msg("Failed to save webhooks")
+	}
+}
+
+/* find は送信先の添字を返す（呼び出し元でmuをロックしていること、ない場合は-1） */
+func (s *webhookStore) find(id string) int {
+	for i, target := range s.Targets {
+		if target.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+renderWebhookBody は送信する本文を作成する
+
+引数:
+  format string - 本文の形式（webhookFormat* 定数）
+  tmpl string - formatがtemplateの場合のテンプレート
+  event WebhookEvent - 送信するイベント
+*/
+func renderWebhookBody(format, tmpl string, event WebhookEvent) ([]byte, error) {
+	switch format {
+	case webhookFormatSlack, webhookFormatDiscord:
+		tmpl = webhookFormatTemplates[format]
+	case webhookFormatTemplate:
+	default:
+		return json.Marshal(event)
+	}
+	t, err := parseWebhookTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := t.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return body.Bytes(), nil
+}
+
+/*
+parseWebhookTemplate は本文のテンプレートを読み込む
+JSONの文字列を安全に埋め込むため、値をJSONにエンコードする関数 json を使用できる
+例: {"text": {{json .Title}}}
+*/
+func parseWebhookTemplate(tmpl string) (*template.Template, error) {
+	return template.New("webhook").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(tmpl)
+}
+
+/*
+dispatch はイベントを一致するすべての送信先に送信する（notifyから呼び出される）
+同じ送信先に同じ内容のイベントをWEBHOOK_DEDUP_WINDOW以内に送信済みの場合は送信しない
+*/
+func (s *webhookStore) dispatch(event WebhookEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, at := range s.sent {
+		if now.Sub(at) >= webhookDedupWindow {
+			delete(s.sent, key)
+		}
+	}
+	for _, target := range s.Targets {
+		if !target.matches(event) {
+			continue
+		}
+		key := strings.Join([]string{target.ID, event.Kind, event.Repo, event.Title, event.Message}, "\x00")
+		if _, ok := s.sent[key]; ok {
+			continue
+		}
+		if webhookDedupWindow > 0 {
+			s.sent[key] = now
+		}
+		s.enqueue(target.ID, target.URL, target.ContentType, target.Secret, target.Format, target.Template, event)
+	}
+}
+
+/*
+enqueue は本文を作成して送信を開始する（呼び出し元でmuをロックしていること）
+本文を作成できない場合（テンプレートの実行時のエラー）は再試行せずデッドレターにする
+
+戻り値:
+  string - 送信ID
+*/
+func (s *webhookStore) enqueue(target, url, contentType, secret, format, tmpl string, event WebhookEvent) string {
+	d := &webhookDelivery{id: newSessionID(), target: target, url: url, contentType: contentType, secret: secret, event: event}
+	if d.contentType == "" {
+		d.contentType = "application/json"
+	}
+	body, err := renderWebhookBody(format, tmpl, event)
+	if err != nil {
+		d.lastError = err.Error()
+		s.deadLetter(d)
+		return d.id
+	}
+	d.body = body
+	s.pending[d.id] = d
+	go s.attempt(d)
+	return d.id
+}
+
+/*
+attempt は送信を1回試行する（ゴルーチンで実行する）
+失敗した場合は待機時間の後に再試行し、再試行を使い切った場合・4xx（408, 429を除く）の応答の場合はデッドレターにする
+*/
+func (s *webhookStore) attempt(d *webhookDelivery) {
+	s.mu.Lock()
+	if _, ok := s.pending[d.id]; !ok {
+		/* 再試行を待つ間にデータの削除（dropPending）で破棄された */
+		s.mu.Unlock()
+		return
+	}
+	d.attempts++
+	attempts := d.attempts
+	s.mu.Unlock()
+
+	retryable, err := sendWebhook(d)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err == nil:
+		delete(s.pending, d.id)
+		webhookDeliveriesTotal.Inc(d.event.Kind, "succeeded")
+	case retryable && attempts < webhookMaxAttempts:
+		backoff := webhookRetryBackoff << (attempts - 1)
+		d.lastError, d.nextAttempt = err.Error(), time.Now().Add(backoff)
+		webhookDeliveriesTotal.Inc(d.event.Kind, "retried")
+		log.Warn().Err(err).Str("target", d.target).Str("event", d.event.Kind).Int("attempt", attempts).Dur("backoff", backoff).Msg("Webhook delivery failed, retrying")
+		time.AfterFunc(backoff, func() { s.attempt(d) })
+	default:
+		delete(s.pending, d.id)
+		d.lastError = err.Error()
+		webhookDeliveriesTotal.Inc(d.event.Kind, "failed")
+		s.deadLetter(d)
+	}
+}
+
+/* deadLetter は送信をデッドレターとしてログに出力して記録する（呼び出し元でmuをロックしていること） */
+func (s *webhookStore) deadLetter(d *webhookDelivery) {
+	log.Error().
+		Str("delivery_id", d.id).
+		Str("target", d.target).
+		Str("event", d.event.Kind).
+		Str("event_id", d.event.ID).
+		Int("attempts", d.attempts).
+		Str("error", d.lastError).
+		Msg("Webhook delivery moved to dead letters")
+	s.DeadLetters = append([]WebhookDeadLetter{{
+		DeliveryID: d.id,
+		Target:     d.target,
+		Event:      d.event.Kind,
+		EventID:    d.event.ID,
+		Attempts:   d.attempts,
+		Error:      d.lastError,
+		FailedAt:   time.Now().UTC(),
+	}}, s.DeadLetters...)
+	if len(s.DeadLetters) > maxWebhookDeadLetters {
+		s.DeadLetters = s.DeadLetters[:maxWebhookDeadLetters]
+	}
+	s.save()
+}
+
+/*
+sendWebhook は本文をPOSTする
+署名の鍵を設定している場合は、本文のHMAC-SHA256を X-Giter-Signature-256 ヘッダー（"sha256=<16進数>"）に付ける
+
+戻り値:
+  bool - 再試行で成功する可能性がある場合はtrue（接続のエラー、5xx・408・429の応答）
+  error - 2xx以外の応答、または送信できない場合のエラー
+*/
+func sendWebhook(d *webhookDelivery) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(d.body))
+	if err != nil {
+		return false, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", d.contentType)
+	req.Header.Set("User-Agent", "giter-webhook")
+	req.Header.Set("X-Giter-Event", d.event.Kind)
+	req.Header.Set("X-Giter-Delivery", d.id)
+	if d.secret != "" {
+		mac := hmac.New(sha256.New, []byte(d.secret))
+		mac.Write(d.body)
+		req.Header.Set("X-Giter-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
+
+/*
+notifyChannelWebhook は閲覧者の通知設定のSlack/Discordチャネルにイベントを送信する
+送信先を登録したWebhookと同じく、失敗した場合は再試行してデッドレターに記録する
+*/
+func notifyChannelWebhook(channel, url string, event WebhookEvent) {
+	webhooks.mu.Lock()
+	defer webhooks.mu.Unlock()
+	webhooks.enqueue(channel, url, "application/json", "", channel, "", event)
+}
+
+/* pendingLen は再試行を待っている送信（最初の試行中を含む）の件数を返す */
+func (s *webhookStore) pendingLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+/*
+dropPending は再試行を待っている送信を破棄し、破棄した件数を返す（DELETE /api/admin/data で使用）
+送信のボディはコミットメッセージなどのイベントの内容を含むため、待機中の再試行も送信しない
+*/
+func (s *webhookStore) dropPending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.pending)
+	s.pending = map[string]*webhookDelivery{}
+	s.sent = map[string]time.Time{}
+	return n
+}
+
+/* deliveries は再試行を待っている送信とデッドレターを返す */
+func (s *webhookStore) deliveries() WebhookDeliveries {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := WebhookDeliveries{Pending: []WebhookPendingDelivery{}, DeadLetters: append([]WebhookDeadLetter{}, s.DeadLetters...)}
+	for _, d := range s.pending {
+		if d.nextAttempt.IsZero() {
+			/* 最初の試行中の送信は含めない */
+			continue
+		}
+		result.Pending = append(result.Pending, WebhookPendingDelivery{
+			DeliveryID:  d.id,
+			Target:      d.target,
+			Event:       d.event.Kind,
+			Attempts:    d.attempts,
+			LastError:   d.lastError,
+			NextAttempt: d.nextAttempt,
+		})
+	}
+	sort.Slice(result.Pending, func(i, j int) bool { return result.Pending[i].NextAttempt.Before(result.Pending[j].NextAttempt) })
+	return result
+}
+
+/* webhookTargetRequest は送信先の作成・更新のリクエストボディ */
+type webhookTargetRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	URL         string   `json:"url" binding:"required,url,startswith=https://"`
+	Events      []string `json:"events"`
+	Repos       []string `json:"repos"`
+	Format      string   `json:"format" binding:"omitempty,oneof=json slack discord template"`
+	Template    string   `json:"template" binding:"max=10000"`
+	ContentType string   `json:"content_type" binding:"max=100"`
+	Secret      *string  `json:"secret" binding:"omitempty,max=200"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+/* validateFields はイベントの種類とテンプレートを検証する */
+func (req *webhookTargetRequest) validateFields() []FieldError {
+	var fields []FieldError
+	for _, kind := range req.Events {
+		if !containsString(notificationKinds, kind) {
+			fields = append(fields, FieldError{Field: "events", Rule: "oneof", Message: fmt.Sprintf("unsupported event: %s", kind)})
+		}
+	}
+	if req.Format != webhookFormatTemplate {
+		return fields
+	}
+	if req.Template == "" {
+		return append(fields, FieldError{Field: "template", Rule: "required", Message: "is required for template format"})
+	}
+	/* 送信時に失敗しないよう、テストのイベントで実行できることを確認する */
+	sample := WebhookEvent{ID: "sample", Kind: webhookEventTest, Title: "title", Message: "message", Repo: "repo", CreatedAt: time.Now().UTC()}
+	body, err := renderWebhookBody(webhookFormatTemplate, req.Template, sample)
+	switch {
+	case err != nil:
+		fields = append(fields, FieldError{Field: "template", Rule: "template", Message: err.Error()})
+	case (req.ContentType == "" || strings.Contains(req.ContentType, "json")) && !json.Valid(body):
+		fields = append(fields, FieldError{Field: "template", Rule: "json", Message: "must render valid JSON (use {{json .Message}} to embed values)"})
+	}
+	return fields
+}
+
+/* apply はリクエストの内容を送信先に設定する（secretを省略した場合は設定済みの鍵を残す） */
+func (req *webhookTargetRequest) apply(target *WebhookTarget) {
+	target.Name, target.URL = req.Name, req.URL
+	target.Events, target.Repos = req.Events, req.Repos
+	if target.Events == nil {
+		target.Events = []string{}
+	}
+	if target.Repos == nil {
+		target.Repos = []string{}
+	}
+	target.Format, target.Template, target.ContentType = req.Format, req.Template, req.ContentType
+	if target.Format == "" {
+		target.Format = webhookFormatJSON
+	}
+	if target.ContentType == "" {
+		target.ContentType = "application/json"
+	}
+	if req.Secret != nil {
+		target.Secret = *req.Secret
+	}
+	target.Enabled = req.Enabled == nil || *req.Enabled
+	target.UpdatedAt = time.Now().UTC()
+}
+
+/*
+getWebhooks は登録した送信先の一覧を返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, {"targets": [WebhookTarget, ...]}（署名の鍵は含めない）
+*/
+func getWebhooks(c *gin.Context) {
+	webhooks.mu.Lock()
+	targets := make([]WebhookTarget, 0, len(webhooks.Targets))
+	for _, target := range webhooks.Targets {
+		targets = append(targets, target.public())
+	}
+	webhooks.mu.Unlock()
+	respondJSON(c, http.StatusOK, gin.H{"targets": targets})
+}
+
+/*
+postWebhook は送信先を登録する管理者APIハンドラー
+
+リクエストボディ:
+  {"name": "ops", "url": "https://example.com/hooks/giter", "events": ["sync_failure"], "format": "json", "secret": "..."}
+  events, repos は省略時すべて、format は省略時 json、enabled は省略時 true
+
+レスポンス:
+  成功時: 201 Created, WebhookTarget
+  失敗時: 400 Bad Request（不正なJSON）, 422 Unprocessable Entity（httpsでないURL、未知のイベント、実行できないテンプレート）
+*/
+func postWebhook(c *gin.Context) {
+	var req webhookTargetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	target := WebhookTarget{ID: newSessionID(), CreatedAt: time.Now().UTC()}
+	req.apply(&target)
+
+	webhooks.mu.Lock()
+	webhooks.Targets = append(webhooks.Targets, target)
+	webhooks.save()
+	webhooks.mu.Unlock()
+
+	auditLog.record(c, "webhook.create", target.ID, nil)
+	respondJSON(c, http.StatusCreated, target.public())
+}
+
+/*
+putWebhook は送信先の設定を置き換える管理者APIハンドラー
+リクエストボディはpostWebhookと同じ（secretを省略した場合は設定済みの鍵を残し、空文字の場合は署名しない）
+
+レスポンス:
+  成功時: 200 OK, WebhookTarget
+  失敗時: 404 Not Found（送信先がない）, 422 Unprocessable Entity
+*/
+func putWebhook(c *gin.Context) {
+	var req webhookTargetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	webhooks.mu.Lock()
+	i := webhooks.find(c.Param("id"))
+	if i < 0 {
+		webhooks.mu.Unlock()
+		respondError(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	req.apply(&webhooks.Targets[i])
+	target := webhooks.Targets[i]
+	webhooks.save()
+	webhooks.mu.Unlock()
+
+	auditLog.record(c, "webhook.update", target.ID, nil)
+	respondJSON(c, http.StatusOK, target.public())
+}
+
+/*
+deleteWebhook は送信先を削除する管理者APIハンドラー
+再試行を待っている送信は削除後も送信する
+
+レスポンス:
+  成功時: 204 No Content
+  失敗時: 404 Not Found（送信先がない）
+*/
+func deleteWebhook(c *gin.Context) {
+	webhooks.mu.Lock()
+	i := webhooks.find(c.Param("id"))
+	if i < 0 {
+		webhooks.mu.Unlock()
+		respondError(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	webhooks.Targets = append(webhooks.Targets[:i], webhooks.Targets[i+1:]...)
+	webhooks.save()
+	webhooks.mu.Unlock()
+
+	auditLog.record(c, "webhook.delete", c.Param("id"), nil)
+	c.Status(http.StatusNoContent)
+}
+
+/*
+postWebhookTest は送信先にテストのイベント（event: "test"）を送信する管理者APIハンドラー
+イベントのフィルター・無効化・重複の抑止に関係なく送信する
+
+レスポンス:
+  成功時: 202 Accepted, {"delivery_id": 送信ID}（結果はログと /api/admin/webhooks/deliveries で確認する）
+  失敗時: 404 Not Found（送信先がない）
+*/
+func postWebhookTest(c *gin.Context) {
+	webhooks.mu.Lock()
+	defer webhooks.mu.Unlock()
+
+	i := webhooks.find(c.Param("id"))
+	if i < 0 {
+		respondError(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	target := webhooks.Targets[i]
+	event := WebhookEvent{
+		ID:        newSessionID(),
+		Kind:      webhookEventTest,
+		Title:     "Giterからのテスト送信",
+		Message:   fmt.Sprintf("送信先 %s の設定を確認しています", target.Name),
+		CreatedAt: time.Now().UTC(),
+	}
+	id := webhooks.enqueue(target.ID, target.URL, target.ContentType, target.Secret, target.Format, target.Template, event)
+	respondJSON(c, http.StatusAccepted, gin.H{"delivery_id": id})
+}
+
+/*
+getWebhookDeliveries は再試行を待っている送信とデッドレターを返す管理者APIハンドラー
+
+レスポンス:
+  200 OK, WebhookDeliveries
+*/
+func getWebhookDeliveries(c *gin.Context) {
+	respondJSON(c, http.StatusOK, webhooks.deliveries())
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	commitTimeWork    = "work"    // 勤務日の勤務時間内
+	commitTimeEvening = "evening" // 勤務日の勤務時間外（早朝・夜）
+	commitTimeWeekend = "weekend" // 勤務日以外（休日）
+)
+
+const (
+	/* defaultWorkingHoursMonths は月ごとの推移に含める月数のデフォルト */
+	defaultWorkingHoursMonths = 6
+	/* maxWorkingHoursMonths は月ごとの推移に含める月数の上限（workingHoursParamsのbindingタグと合わせること） */
+	maxWorkingHoursMonths = 24
+)
+
+var (
+	/* workHoursStart は勤務時間の開始時刻（時、環境変数 WORK_HOURS_START、デフォルト: 9） */
+	workHoursStart = getEnvInt("WORK_HOURS_START", 9)
+	/* workHoursEnd は勤務時間の終了時刻（時、この時刻を含まない、環境変数 WORK_HOURS_END、デフォルト: 18） */
+	workHoursEnd = getEnvInt("WORK_HOURS_END", 18)
+	/* workDays は勤務日の曜日（環境変数 WORK_DAYS、例: "mon,tue,wed,thu,fri"） */
+	workDays = parseWorkDays(getEnv("WORK_DAYS", "mon,tue,wed,thu,fri"))
+)
+
+/* weekdayNames はWORK_DAYSで指定する曜日の名前 */
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+/* parseWorkDays は曜日の名前のカンマ区切りのリストを読み込む（未知の名前は警告を出して無視する） */
+func parseWorkDays(raw string) map[time.Weekday]bool {
+	days := map[time.Weekday]bool{}
+	for _, name := range splitList(raw) {
+		day, ok := weekdayNames[strings.ToLower(name)]
+		if !ok {
+			log.Warn().Str("day", name).Msg("Ignoring unknown WORK_DAYS entry")
+			continue
+		}
+		days[day] = true
+	}
+	return days
+}
+
+/*
+classifyCommitTime はコミット日時を勤務時間内・勤務時間外・休日に分類する
+tはあらかじめ集計するタイムゾーンに変換しておくこと
+*/
+func classifyCommitTime(t time.Time) string {
+	if !workDays[t.Weekday()] {
+		return commitTimeWeekend
+	}
+	if t.Hour() >= workHoursStart && t.Hour() < workHoursEnd {
+		return commitTimeWork
+	}
+	return commitTimeEvening
+}
+
+/* workingHoursParams は /api/stats/working-hours のクエリパラメータ */
+type workingHoursParams struct {
+	Timezone string `form:"tz" binding:"omitempty,timezone"`
+	Months   int    `form:"months" binding:"min=1,max=24"`
+}
+
+/* WorkingHoursBreakdown は分類ごとのコミット数 */
+type WorkingHoursBreakdown struct {
+	Work         int     `json:"work"`          // 勤務時間内
+	Evening      int     `json:"evening"`       // 勤務時間外
+	Weekend      int     `json:"weekend"`       // 休日
+	OutsideShare float64 `json:"outside_share"` // 勤務時間外・休日の割合（0〜1）
+}
+
+/* add は分類に応じてコミット数を加算する */
+func (b *WorkingHoursBreakdown) add(category string) {
+	switch category {
+	case commitTimeWork:
+		b.Work++
+	case commitTimeEvening:
+		b.Evening++
+	case commitTimeWeekend:
+		b.Weekend++
+	}
+}
+
+/* finish は勤務時間外・休日の割合を計算する */
+func (b *WorkingHoursBreakdown) finish() {
+	if total := b.Work + b.Evening + b.Weekend; total > 0 {
+		b.OutsideShare = math.Round(float64(b.Evening+b.Weekend)/float64(total)*1000) / 1000
+	}
+}
+
+/* MonthlyWorkingHours は1か月分の分類ごとのコミット数 */
+type MonthlyWorkingHours struct {
+	Month string `json:"month"` // 月（"2006-01"形式）
+	WorkingHoursBreakdown
+}
+
+/* WorkingHoursResponse は /api/stats/working-hours のレスポンス */
+type WorkingHoursResponse struct {
+	Timezone string                `json:"timezone"`  // 集計に使用したタイムゾーン
+	WorkDays []string              `json:"work_days"` // 勤務日の曜日
+	WorkFrom int                   `json:"work_from"` // 勤務時間の開始時刻（時）
+	WorkTo   int                   `json:"work_to"`   // 勤務時間の終了時刻（時）
+	Total    WorkingHoursBreakdown `json:"total"`     // 対象期間全体の分類ごとのコミット数
+	Monthly  []MonthlyWorkingHours `json:"monthly"`   // 月ごとの推移（古い順、今月を含む）
+}
+
+/*
+getWorkingHours はコミットした時間帯を勤務時間内・勤務時間外・休日に分類して返すAPIハンドラー
+勤務時間と勤務日はWORK_HOURS_START・WORK_HOURS_END・WORK_DAYSで設定する
+
+クエリパラメータ:
+  tz string - 集計するタイムゾーン（IANAのタイムゾーン名、省略時はSTATS_TIMEZONE）
+  months int - 月ごとの推移に含める月数（1〜24、デフォルト: 6）
+
+レスポンス:
+  成功時: 200 OK, WorkingHoursResponse
+  失敗時: 422 Unprocessable Entity（不正なtz・months）, 502 Bad Gateway（GitHubから取得できない）
+
+注意:
+  - GitHub APIから取得できるのは1リポジトリあたり最新100件までのため、古い月ほど一部が欠けることがある
+*/
+func getWorkingHours(c *gin.Context) {
+	params := workingHoursParams{Months: defaultWorkingHoursMonths}
+	if !bindQuery(c, &params) {
+		return
+	}
+	loc := statsLocation
+	if params.Timezone != "" {
+		/* bindingのtimezoneルールで読み込めることを確認済み */
+		loc, _ = time.LoadLocation(params.Timezone)
+	}
+	months := params.Months
+
+	commits, _, err := fetchVisibleCommitHistory(requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch commits for working hours")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	now := time.Now().In(loc)
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, 1-months, 0)
+	resp := WorkingHoursResponse{
+		Timezone: loc.String(),
+		WorkDays: []string{},
+		WorkFrom: workHoursStart,
+		WorkTo:   workHoursEnd,
+		Monthly:  make([]MonthlyWorkingHours, months),
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if workDays[day] {
+			resp.WorkDays = append(resp.WorkDays, strings.ToLower(day.String()[:3]))
+		}
+	}
+	index := map[string]int{}
+	for i := range resp.Monthly {
+		month := since.AddDate(0, i, 0).Format("2006-01")
+		resp.Monthly[i].Month = month
+		index[month] = i
+	}
+
+	for _, commit := range commits {
+		t := commit.CommitTime.In(loc)
+		if t.Before(since) {
+			continue
+		}
+		i, ok := index[t.Format("2006-01")]
+		if !ok {
+			continue
+		}
+		category := classifyCommitTime(t)
+		resp.Total.add(category)
+		resp.Monthly[i].add(category)
+	}
+	resp.Total.finish()
+	for i := range resp.Monthly {
+		resp.Monthly[i].finish()
+	}
+	respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/develop-suda/giter/web"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	/* wrappedTopRepos は年間のまとめに含めるコミットの多いリポジトリの件数 */
+	wrappedTopRepos = 5
+	/* wrappedFirstYear は年間のまとめを作成できる最初の年（GitHubのサービス開始年） */
+	wrappedFirstYear = 2008
+	/* wrappedUnknownLanguage は言語が判定されていないリポジトリの集計に使用する名前 */
+	wrappedUnknownLanguage = "Other"
+)
+
+/*
+wrappedMaxPages は年間のまとめで1リポジトリあたりに取得するコミットの最大ページ数（1ページ100件）
+環境変数 WRAPPED_MAX_PAGES で変更可能（デフォルト: 10）
+*/
+var wrappedMaxPages = getEnvInt("WRAPPED_MAX_PAGES", 10)
+
+/* WrappedCommit は年間のまとめに含める1件のコミット（最初・最後のコミット） */
+type WrappedCommit struct {
+	Repo    string    `json:"repo"`    // リポジトリ名
+	SHA     string    `json:"sha"`     // コミットハッシュ（短縮形）
+	Message string    `json:"message"` // コミットメッセージの件名
+	Time    time.Time `json:"time"`    // コミット日時
+	URL     string    `json:"url"`     // GitHubのコミットページURL
+}
+
+/* WrappedStreak は最も長くコミットが続いた期間 */
+type WrappedStreak struct {
+	Days  int    `json:"days"`            // 連続日数
+	Start string `json:"start,omitempty"` // 初日（"2006-01-02"形式）
+	End   string `json:"end,omitempty"`   // 最終日
+}
+
+/* LanguageTrend は言語ごとのコミット数と月ごとの推移 */
+type LanguageTrend struct {
+	Language string  `json:"language"` // 言語（リポジトリの主な言語）
+	Commits  int     `json:"commits"`  // 年間のコミット数
+	Monthly  [12]int `json:"monthly"`  // 1月から12月までのコミット数
+}
+
+/*
+YearInReview は1年間の活動のまとめ（"GitHub Wrapped" 風のページ用）
+*/
+type YearInReview struct {
+	Year            int               `json:"year"`             // 対象の年
+	Timezone        string            `json:"timezone"`         // 集計に使用したタイムゾーン
+	TotalCommits    int               `json:"total_commits"`    // 年間のコミット数
+	ActiveDays      int               `json:"active_days"`      // コミットのあった日数
+	Monthly         [12]int           `json:"monthly"`          // 1月から12月までのコミット数
+	BusiestDay      *DailyCommitCount `json:"busiest_day"`      // 最もコミットの多かった日（コミットがない場合はnull）
+	LongestStreak   WrappedStreak     `json:"longest_streak"`   // 最も長くコミットが続いた期間
+	TopRepositories []RepoCommitCount `json:"top_repositories"` // コミットの多いリポジトリ（最大5件）
+	Languages       []LanguageTrend   `json:"languages"`        // 言語ごとのコミット数（多い順）
+	FirstCommit     *WrappedCommit    `json:"first_commit"`     // 年の最初のコミット
+	LastCommit      *WrappedCommit    `json:"last_commit"`      // 年の最後のコミット
+	Truncated       bool              `json:"truncated"`        // 取得の上限（WRAPPED_MAX_PAGES）に達したリポジトリがある場合はtrue
+}
+
+/*
+parseWrappedYear はパスパラメータの年を検証する
+wrappedFirstYearから今年までを受け付ける
+*/
+func parseWrappedYear(raw string) (int, error) {
+	year, err := strconv.Atoi(raw)
+	if err != nil || year < wrappedFirstYear || year > time.Now().In(statsLocation).Year() {
+		return 0, fmt.Errorf("invalid year: %s", raw)
+	}
+	return year, nil
+}
+
+/* newWrappedCommit はCommitHistoryを年間のまとめの形式に変換する */
+func newWrappedCommit(commit CommitHistory) *WrappedCommit {
+	return &WrappedCommit{
+		Repo:    commit.RepositoryName,
+		SHA:     commit.CommitSHA,
+		Message: strings.SplitN(commit.CommitMessage, "\n", 2)[0],
+		Time:    commit.CommitTime,
+		URL:     commit.CommitURL,
+	}
+}
+
+/* redacted は最初と最後のコミットのメッセージをマスクしたまとめを返す */
+func (review YearInReview) redacted(r redaction) YearInReview {
+	for _, commit := range []**WrappedCommit{&review.FirstCommit, &review.LastCommit} {
+		if *commit != nil {
+			redacted := **commit
+			redacted.Message = r.message(redacted.Message)
+			*commit = &redacted
+		}
+	}
+	return review
+}
+
+/*
+buildYearInReview は指定した年の活動のまとめを作成する
+リポジトリごとにその年のコミットをfetchCommitsInRangeでページを辿って取得する
+
+注意:
+  - 初回はリポジトリ数×ページ数のリクエストが発生する（結果はgithubGetのキャッシュを経由する）
+  - 言語はリポジトリの主な言語で集計する（コミットごとの言語ではない）
+  - vがvisibilityPublicの場合、プライベートリポジトリは含めない
+*/
+func buildYearInReview(year int, v visibility) (YearInReview, error) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, statsLocation)
+	end := start.AddDate(1, 0, 0)
+	review := YearInReview{
+		Year:            year,
+		Timezone:        statsLocation.String(),
+		TopRepositories: []RepoCommitCount{},
+		Languages:       []LanguageTrend{},
+	}
+
+	repos, err := fetchVisibleRepositories(v)
+	if err != nil {
+		return review, err
+	}
+
+	results := make([][]Commit, len(repos))
+	truncated := make([]bool, len(repos))
+	runConcurrently(len(repos), func(i int) {
+		commits, more, err := fetchCommitsInRange(repos[i].FullName, start, end, wrappedMaxPages)
+		if err != nil {
+			log.Warn().Err(err).Str("repository", repos[i].Name).Msg("Failed to fetch commits for year in review")
+			return
+		}
+		results[i], truncated[i] = commits, more
+	})
+
+	var commits []CommitHistory
+	languages := map[string]*LanguageTrend{}
+	for i, repo := range repos {
+		review.Truncated = review.Truncated || truncated[i]
+		language := repo.Language
+		if language == "" {
+			language = wrappedUnknownLanguage
+		}
+		repoCommits := 0
+		for _, c := range results[i] {
+			commit := newCommitHistory(repo.Name, c)
+			if commit.CommitTime.Before(start) || !commit.CommitTime.Before(end) {
+				continue
+			}
+			commits = append(commits, commit)
+			repoCommits++
+
+			month := commit.CommitTime.In(statsLocation).Month() - 1
+			review.Monthly[month]++
+			if languages[language] == nil {
+				languages[language] = &LanguageTrend{Language: language}
+			}
+			languages[language].Commits++
+			languages[language].Monthly[month]++
+
+			if review.FirstCommit == nil || commit.CommitTime.Before(review.FirstCommit.Time) {
+				review.FirstCommit = newWrappedCommit(commit)
+			}
+			if review.LastCommit == nil || commit.CommitTime.After(review.LastCommit.Time) {
+				review.LastCommit = newWrappedCommit(commit)
+			}
+		}
+		if repoCommits > 0 {
+			review.TopRepositories = append(review.TopRepositories, RepoCommitCount{Repo: repo.Name, Commits: repoCommits})
+		}
+	}
+	review.TotalCommits = len(commits)
+
+	sort.Slice(review.TopRepositories, func(i, j int) bool {
+		a, b := review.TopRepositories[i], review.TopRepositories[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Repo < b.Repo
+	})
+	if len(review.TopRepositories) > wrappedTopRepos {
+		review.TopRepositories = review.TopRepositories[:wrappedTopRepos]
+	}
+	for _, trend := range languages {
+		review.Languages = append(review.Languages, *trend)
+	}
+	sort.Slice(review.Languages, func(i, j int) bool {
+		a, b := review.Languages[i], review.Languages[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Language < b.Language
+	})
+
+	counts := dailyCommitCounts(commits)
+	review.ActiveDays = len(counts)
+	for date, n := range counts {
+		if review.BusiestDay == nil || n > review.BusiestDay.Commits || (n == review.BusiestDay.Commits && date < review.BusiestDay.Date) {
+			review.BusiestDay = &DailyCommitCount{Date: date, Commits: n}
+		}
+	}
+	days, streakStart, streakEnd := longestStreak(counts, start, end.AddDate(0, 0, -1))
+	review.LongestStreak = WrappedStreak{Days: days}
+	if days > 0 {
+		review.LongestStreak.Start = streakStart.Format(statsDateFormat)
+		review.LongestStreak.End = streakEnd.Format(statsDateFormat)
+	}
+	return review, nil
+}
+
+/*
+getWrapped は1年間の活動のまとめを返すAPIハンドラー
+
+パスパラメータ:
+  year int - 対象の年（2008年から今年まで）
+
+レスポンス:
+  成功時: 200 OK, YearInReview
+  失敗時: 400 Bad Request（不正な年）, 502 Bad Gateway（GitHubから取得できない）
+*/
+func getWrapped(c *gin.Context) {
+	year, err := parseWrappedYear(c.Param("year"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	review, err := buildYearInReview(year, requestVisibility(c))
+	if err != nil {
+		log.Error().Err(err).Int("year", year).Msg("Failed to build year in review")
+		respondError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	respondJSON(c, http.StatusOK, review.redacted(requestRedaction(c)))
+}
+
+/*
+showWrappedPage は1年間の活動のまとめのページ（/wrapped/:year）を表示するハンドラー
+
+レスポンス:
+  成功時: 200 OK, wrapped.html
+  失敗時: 400 Bad Request / 502 Bad Gateway（エラーメッセージを表示したwrapped.html）
+*/
+func showWrappedPage(c *gin.Context) {
+	req := webRequest(c)
+	year, err := parseWrappedYear(c.Param("year"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "wrapped.html", web.NewWrappedErrorPage(siteInfo(), req, c.Param("year"), localize(c, "invalid year", nil)))
+		return
+	}
+	review, err := buildYearInReview(year, requestVisibility(c))
+	if err != nil {
+		log.Warn().Err(err).Int("year", year).Msg("Failed to render year in review page")
+		c.HTML(http.StatusBadGateway, "wrapped.html", web.NewWrappedErrorPage(siteInfo(), req, c.Param("year"), localize(c, "failed to fetch commit history from GitHub", nil)))
+		return
+	}
+	review = review.redacted(requestRedaction(c))
+
+	summary := web.WrappedSummary{
+		Year:          review.Year,
+		PrevYear:      review.Year - 1,
+		TotalCommits:  review.TotalCommits,
+		ActiveDays:    review.ActiveDays,
+		LongestStreak: review.LongestStreak.Days,
+		Truncated:     review.Truncated,
+	}
+	if review.BusiestDay != nil {
+		summary.BusiestDay, _ = time.ParseInLocation(statsDateFormat, review.BusiestDay.Date, statsLocation)
+		summary.BusiestDayCommits = review.BusiestDay.Commits
+	}
+
+	months := make([]web.WrappedBar, 0, 12)
+	peak := 0
+	for _, n := range review.Monthly {
+		if n > peak {
+			peak = n
+		}
+	}
+	for i, n := range review.Monthly {
+		months = append(months, web.WrappedBar{Label: strconv.Itoa(i + 1), Count: n, Percent: percentOf(n, peak)})
+	}
+	repos := make([]web.WrappedBar, 0, len(review.TopRepositories))
+	for _, r := range review.TopRepositories {
+		repos = append(repos, web.WrappedBar{Label: r.Repo, Count: r.Commits, Percent: percentOf(r.Commits, review.TotalCommits)})
+	}
+	languages := make([]web.WrappedBar, 0, len(review.Languages))
+	for _, l := range review.Languages {
+		languages = append(languages, web.WrappedBar{Label: l.Language, Count: l.Commits, Percent: percentOf(l.Commits, review.TotalCommits)})
+	}
+
+	var first, last *web.CommitItem
+	if review.FirstCommit != nil {
+		first = &web.CommitItem{SHA: review.FirstCommit.SHA, Message: review.FirstCommit.Repo + ": " + review.FirstCommit.Message, Time: review.FirstCommit.Time, URL: review.FirstCommit.URL}
+		last = &web.CommitItem{SHA: review.LastCommit.SHA, Message: review.LastCommit.Repo + ": " + review.LastCommit.Message, Time: review.LastCommit.Time, URL: review.LastCommit.URL}
+	}
+	c.HTML(http.StatusOK, "wrapped.html", web.NewWrappedPage(siteInfo(), req, summary, months, repos, languages, first, last))
+}
+
+/* percentOf はnがtotalに占める割合（0〜100の整数）を返す */
+func percentOf(n, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return n * 100 / total
+}